@@ -210,7 +210,9 @@ func (c *Client) Query(q Query) (*Response, error) {
 				break
 			}
 
-			response.Results = append(response.Results, r.Results...)
+			for _, result := range r.Results {
+				response.Results = appendResult(response.Results, result)
+			}
 			if r.Err != nil {
 				response.Err = r.Err
 				break
@@ -398,6 +400,10 @@ type Result struct {
 	Series   []models.Row
 	Messages []*Message
 	Err      error
+
+	// Partial is set if the result of the statement continues in the next
+	// chunk of a chunked response.
+	Partial bool
 }
 
 // MarshalJSON encodes the result into JSON.
@@ -406,12 +412,14 @@ func (r *Result) MarshalJSON() ([]byte, error) {
 	var o struct {
 		Series   []models.Row `json:"series,omitempty"`
 		Messages []*Message   `json:"messages,omitempty"`
+		Partial  bool         `json:"partial,omitempty"`
 		Err      string       `json:"error,omitempty"`
 	}
 
 	// Copy fields to output struct.
 	o.Series = r.Series
 	o.Messages = r.Messages
+	o.Partial = r.Partial
 	if r.Err != nil {
 		o.Err = r.Err.Error()
 	}
@@ -424,6 +432,7 @@ func (r *Result) UnmarshalJSON(b []byte) error {
 	var o struct {
 		Series   []models.Row `json:"series,omitempty"`
 		Messages []*Message   `json:"messages,omitempty"`
+		Partial  bool         `json:"partial,omitempty"`
 		Err      string       `json:"error,omitempty"`
 	}
 
@@ -435,6 +444,7 @@ func (r *Result) UnmarshalJSON(b []byte) error {
 	}
 	r.Series = o.Series
 	r.Messages = o.Messages
+	r.Partial = o.Partial
 	if o.Err != "" {
 		r.Err = errors.New(o.Err)
 	}
@@ -523,6 +533,28 @@ func (r *ChunkedResponse) NextResponse() (*Response, error) {
 	return &response, nil
 }
 
+// appendResult appends result to results. A result continuing a partial
+// result is combined with it, appending to the last series if that series
+// is also partial.
+func appendResult(results []Result, result Result) []Result {
+	n := len(results)
+	if n == 0 || !results[n-1].Partial || result.Err != nil {
+		return append(results, result)
+	}
+
+	prev := &results[n-1]
+	if len(prev.Series) > 0 && len(result.Series) > 0 && prev.Series[len(prev.Series)-1].Partial {
+		last := &prev.Series[len(prev.Series)-1]
+		last.Values = append(last.Values, result.Series[0].Values...)
+		last.Partial = result.Series[0].Partial
+		result.Series = result.Series[1:]
+	}
+	prev.Series = append(prev.Series, result.Series...)
+	prev.Messages = append(prev.Messages, result.Messages...)
+	prev.Partial = result.Partial
+	return results
+}
+
 // Point defines the fields that will be written to the database
 // Measurement, Time, and Fields are required
 // Precision can be specified if the time is in epoch format (integer).
@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/influxdata/influxdb/models"
@@ -486,6 +488,19 @@ type Query struct {
 	Command   string
 	Database  string
 	Precision string
+
+	// Chunked tells the server to stream the results in chunks instead of
+	// buffering the whole response. The chunks are combined into a single
+	// Response.
+	Chunked bool
+
+	// ChunkSize sets the maximum number of values of a series returned in
+	// each chunk. The server default is used if zero.
+	ChunkSize int
+
+	// Parameters holds the values bound to the $name parameters of the
+	// command. Strings, numbers and bools are supported.
+	Parameters map[string]interface{}
 }
 
 // NewQuery returns a query object
@@ -530,6 +545,32 @@ type Result struct {
 	Series   []models.Row
 	Messages []*Message
 	Err      string `json:"error,omitempty"`
+
+	// Partial is set if the result of the statement continues in the next
+	// chunk of a chunked response.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// appendResult appends result to results. A result continuing a partial
+// result is combined with it, appending to the last series if that series
+// is also partial.
+func appendResult(results []Result, result Result) []Result {
+	n := len(results)
+	if n == 0 || !results[n-1].Partial || result.Err != "" {
+		return append(results, result)
+	}
+
+	prev := &results[n-1]
+	if len(prev.Series) > 0 && len(result.Series) > 0 && prev.Series[len(prev.Series)-1].Partial {
+		last := &prev.Series[len(prev.Series)-1]
+		last.Values = append(last.Values, result.Series[0].Values...)
+		last.Partial = result.Series[0].Partial
+		result.Series = result.Series[1:]
+	}
+	prev.Series = append(prev.Series, result.Series...)
+	prev.Messages = append(prev.Messages, result.Messages...)
+	prev.Partial = result.Partial
+	return results
 }
 
 func (uc *udpclient) Query(q Query) (*Response, error) {
@@ -557,6 +598,19 @@ func (c *client) Query(q Query) (*Response, error) {
 	if q.Precision != "" {
 		params.Set("epoch", q.Precision)
 	}
+	if q.Chunked {
+		params.Set("chunked", "true")
+		if q.ChunkSize > 0 {
+			params.Set("chunk_size", strconv.Itoa(q.ChunkSize))
+		}
+	}
+	if len(q.Parameters) > 0 {
+		b, err := json.Marshal(q.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("params", string(b))
+	}
 	req.URL.RawQuery = params.Encode()
 
 	resp, err := c.httpClient.Do(req)
@@ -570,6 +624,30 @@ func (c *client) Query(q Query) (*Response, error) {
 	dec.UseNumber()
 	decErr := dec.Decode(&response)
 
+	// Read the remaining chunks of a chunked response.
+	if q.Chunked && decErr == nil {
+		results := response.Results
+		response.Results = nil
+		for _, result := range results {
+			response.Results = appendResult(response.Results, result)
+		}
+
+		for response.Err == "" {
+			var chunk Response
+			if err := dec.Decode(&chunk); err == io.EOF {
+				break
+			} else if err != nil {
+				decErr = err
+				break
+			}
+
+			for _, result := range chunk.Results {
+				response.Results = appendResult(response.Results, result)
+			}
+			response.Err = chunk.Err
+		}
+	}
+
 	// ignore this error if we got an invalid status code
 	if decErr != nil && decErr.Error() == "EOF" && resp.StatusCode != http.StatusOK {
 		decErr = nil
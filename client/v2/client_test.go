@@ -9,6 +9,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/influxdata/influxdb/models"
 )
 
 func TestUDPClient_Query(t *testing.T) {
@@ -91,6 +93,71 @@ func TestClient_Query(t *testing.T) {
 	}
 }
 
+func TestClient_BoundParameterQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(r.FormValue("params")), &params); err != nil {
+			t.Errorf("unexpected error decoding params: %s", err)
+		} else if params["host"] != "server01" || params["value"] != float64(10) {
+			t.Errorf("unexpected params: %v", params)
+		}
+		var data Response
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(data)
+	}))
+	defer ts.Close()
+
+	config := HTTPConfig{Addr: ts.URL}
+	c, _ := NewHTTPClient(config)
+	defer c.Close()
+
+	query := Query{
+		Command:    "SELECT value FROM cpu WHERE host = $host AND value > $value",
+		Parameters: map[string]interface{}{"host": "server01", "value": 10},
+	}
+	if _, err := c.Query(query); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClient_ChunkedQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("chunked") != "true" || r.FormValue("chunk_size") != "1" {
+			t.Errorf("unexpected chunk parameters: %s", r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[{"series":[{"name":"cpu","values":[[1]],"partial":true}],"partial":true}]}
+{"results":[{"series":[{"name":"cpu","values":[[2]]}],"partial":true}]}
+{"results":[{"series":[{"name":"mem","values":[[3]]}]}]}
+{"results":[{"series":[{"name":"disk","values":[[4]]}]}]}
+`))
+	}))
+	defer ts.Close()
+
+	config := HTTPConfig{Addr: ts.URL}
+	c, _ := NewHTTPClient(config)
+	defer c.Close()
+
+	query := Query{Chunked: true, ChunkSize: 1}
+	resp, err := c.Query(query)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	exp := []Result{
+		{Series: []models.Row{
+			{Name: "cpu", Values: [][]interface{}{{json.Number("1")}, {json.Number("2")}}},
+			{Name: "mem", Values: [][]interface{}{{json.Number("3")}}},
+		}},
+		{Series: []models.Row{
+			{Name: "disk", Values: [][]interface{}{{json.Number("4")}}},
+		}},
+	}
+	if !reflect.DeepEqual(resp.Results, exp) {
+		t.Fatalf("unexpected results: %#v", resp.Results)
+	}
+}
+
 func TestClient_BasicAuth(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		u, p, ok := r.BasicAuth()
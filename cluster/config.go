@@ -25,6 +25,24 @@ const (
 	// A value of zero will make the maximum query limit unlimited.
 	DefaultMaxConcurrentQueries = 0
 
+	// DefaultMaxQueuedQueries is the maximum number of queries waiting for a
+	// running query to finish. A value of zero will reject queries as soon as
+	// the maximum number of running queries is reached.
+	DefaultMaxQueuedQueries = 0
+
+	// DefaultQueueTimeout is the maximum time a query waits for a running
+	// query to finish. A value of zero will wait until the query can run.
+	DefaultQueueTimeout = time.Duration(0)
+
+	// DefaultMaxConcurrentStatements is the maximum number of statements of a
+	// query executed at the same time. A value of zero will execute the
+	// statements one after another.
+	DefaultMaxConcurrentStatements = 0
+
+	// DefaultMaxConcurrentShardQueries is the maximum number of shards a
+	// SELECT reads at once. A value of zero will use the number of CPUs.
+	DefaultMaxConcurrentShardQueries = 0
+
 	// DefaultMaxSelectPointN is the maximum number of points a SELECT can process.
 	// A value of zero will make the maximum point count unlimited.
 	DefaultMaxSelectPointN = 0
@@ -32,6 +50,30 @@ const (
 	// DefaultMaxSelectSeriesN is the maximum number of series a SELECT can run.
 	// A value of zero will make the maximum series count unlimited.
 	DefaultMaxSelectSeriesN = 0
+
+	// DefaultMaxSelectMemory is the maximum number of bytes a SELECT can buffer.
+	// A value of zero will make the memory unlimited.
+	DefaultMaxSelectMemory = 0
+
+	// DefaultMaxTotalSelectMemory is the maximum number of bytes all running
+	// SELECT statements can buffer. A value of zero will make the memory unlimited.
+	DefaultMaxTotalSelectMemory = 0
+
+	// DefaultMaxSelectBucketsN is the maximum number of GROUP BY time buckets
+	// a SELECT can create. A value of zero will make the bucket count unlimited.
+	DefaultMaxSelectBucketsN = 0
+
+	// DefaultIntoBatchSize is the maximum number of points a SELECT ... INTO
+	// statement writes at once.
+	DefaultIntoBatchSize = 10000
+
+	// DefaultSlowQueryDuration is the time after which a finished query is
+	// written to the slow query log.
+	DefaultSlowQueryDuration = 10 * time.Second
+
+	// DefaultQueryCacheSize is the maximum number of values of SELECT results
+	// cached. A value of zero disables the cache.
+	DefaultQueryCacheSize = 0
 )
 
 // Config represents the configuration for the clustering service.
@@ -42,11 +84,21 @@ type Config struct {
 	MaxRemoteWriteConnections int           `toml:"max-remote-write-connections"`
 	ShardMapperTimeout        toml.Duration `toml:"shard-mapper-timeout"`
 	MaxConcurrentQueries      int           `toml:"max-concurrent-queries"`
+	MaxQueuedQueries          int           `toml:"max-queued-queries"`
+	QueueTimeout              toml.Duration `toml:"queue-timeout"`
+	MaxConcurrentStatements   int           `toml:"max-concurrent-statements"`
+	MaxConcurrentShardQueries int           `toml:"max-concurrent-shard-queries"`
 	QueryTimeout              toml.Duration `toml:"query-timeout"`
 	LogQueriesAfter           toml.Duration `toml:"log-queries-after"`
+	SlowQueryLogPath          string        `toml:"slow-query-log-path"`
+	SlowQueryDuration         toml.Duration `toml:"slow-query-duration"`
 	MaxSelectPointN           int           `toml:"max-select-point"`
 	MaxSelectSeriesN          int           `toml:"max-select-series"`
 	MaxSelectBucketsN         int           `toml:"max-select-buckets"`
+	MaxSelectMemory           int64         `toml:"max-select-memory"`
+	MaxTotalSelectMemory      int64         `toml:"max-total-select-memory"`
+	IntoBatchSize             int           `toml:"into-batch-size"`
+	QueryCacheSize            int           `toml:"query-cache-size"`
 }
 
 // NewConfig returns an instance of Config with defaults.
@@ -58,7 +110,17 @@ func NewConfig() Config {
 		QueryTimeout:              toml.Duration(influxql.DefaultQueryTimeout),
 		MaxRemoteWriteConnections: DefaultMaxRemoteWriteConnections,
 		MaxConcurrentQueries:      DefaultMaxConcurrentQueries,
+		MaxQueuedQueries:          DefaultMaxQueuedQueries,
+		QueueTimeout:              toml.Duration(DefaultQueueTimeout),
+		MaxConcurrentStatements:   DefaultMaxConcurrentStatements,
+		MaxConcurrentShardQueries: DefaultMaxConcurrentShardQueries,
 		MaxSelectPointN:           DefaultMaxSelectPointN,
 		MaxSelectSeriesN:          DefaultMaxSelectSeriesN,
+		MaxSelectBucketsN:         DefaultMaxSelectBucketsN,
+		MaxSelectMemory:           DefaultMaxSelectMemory,
+		MaxTotalSelectMemory:      DefaultMaxTotalSelectMemory,
+		IntoBatchSize:             DefaultIntoBatchSize,
+		QueryCacheSize:            DefaultQueryCacheSize,
+		SlowQueryDuration:         toml.Duration(DefaultSlowQueryDuration),
 	}
 }
@@ -14,6 +14,20 @@ func TestConfig_Parse(t *testing.T) {
 	if _, err := toml.Decode(`
 shard-writer-timeout = "10s"
 write-timeout = "20s"
+query-timeout = "30s"
+max-concurrent-shard-queries = 4
+max-select-point = 100
+max-select-series = 200
+max-select-buckets = 300
+max-select-memory = 400
+max-total-select-memory = 500
+into-batch-size = 600
+query-cache-size = 700
+max-concurrent-statements = 8
+max-queued-queries = 9
+queue-timeout = "40s"
+slow-query-log-path = "/var/log/influxdb/slow.log"
+slow-query-duration = "2s"
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -23,5 +37,33 @@ write-timeout = "20s"
 		t.Fatalf("unexpected shard-writer timeout: %s", c.ShardWriterTimeout)
 	} else if time.Duration(c.WriteTimeout) != 20*time.Second {
 		t.Fatalf("unexpected write timeout s: %s", c.WriteTimeout)
+	} else if time.Duration(c.QueryTimeout) != 30*time.Second {
+		t.Fatalf("unexpected query timeout: %s", c.QueryTimeout)
+	} else if c.MaxConcurrentShardQueries != 4 {
+		t.Fatalf("unexpected max concurrent shard queries: %d", c.MaxConcurrentShardQueries)
+	} else if c.MaxSelectPointN != 100 {
+		t.Fatalf("unexpected max select points: %d", c.MaxSelectPointN)
+	} else if c.MaxSelectSeriesN != 200 {
+		t.Fatalf("unexpected max select series: %d", c.MaxSelectSeriesN)
+	} else if c.MaxSelectBucketsN != 300 {
+		t.Fatalf("unexpected max select buckets: %d", c.MaxSelectBucketsN)
+	} else if c.MaxSelectMemory != 400 {
+		t.Fatalf("unexpected max select memory: %d", c.MaxSelectMemory)
+	} else if c.MaxTotalSelectMemory != 500 {
+		t.Fatalf("unexpected max total select memory: %d", c.MaxTotalSelectMemory)
+	} else if c.IntoBatchSize != 600 {
+		t.Fatalf("unexpected into batch size: %d", c.IntoBatchSize)
+	} else if c.QueryCacheSize != 700 {
+		t.Fatalf("unexpected query cache size: %d", c.QueryCacheSize)
+	} else if c.MaxConcurrentStatements != 8 {
+		t.Fatalf("unexpected max concurrent statements: %d", c.MaxConcurrentStatements)
+	} else if c.MaxQueuedQueries != 9 {
+		t.Fatalf("unexpected max queued queries: %d", c.MaxQueuedQueries)
+	} else if time.Duration(c.QueueTimeout) != 40*time.Second {
+		t.Fatalf("unexpected queue timeout: %s", c.QueueTimeout)
+	} else if c.SlowQueryLogPath != "/var/log/influxdb/slow.log" {
+		t.Fatalf("unexpected slow query log path: %s", c.SlowQueryLogPath)
+	} else if time.Duration(c.SlowQueryDuration) != 2*time.Second {
+		t.Fatalf("unexpected slow query duration: %s", c.SlowQueryDuration)
 	}
 }
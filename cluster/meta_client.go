@@ -12,6 +12,7 @@ type MetaClient interface {
 	CreateContinuousQuery(database, name, query string) error
 	CreateDatabase(name string) (*meta.DatabaseInfo, error)
 	CreateDatabaseWithRetentionPolicy(name string, rpi *meta.RetentionPolicyInfo) (*meta.DatabaseInfo, error)
+	CreateMeasurementSchema(database string, msi *meta.MeasurementSchemaInfo) error
 	CreateRetentionPolicy(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error)
 	CreateSubscription(database, rp, name, mode string, destinations []string) error
 	CreateUser(name, password string, admin bool) (*meta.UserInfo, error)
@@ -20,6 +21,7 @@ type MetaClient interface {
 	DropShard(id uint64) error
 	DropContinuousQuery(database, name string) error
 	DropDatabase(name string) error
+	DropMeasurementSchema(database, name string) error
 	DropRetentionPolicy(database, name string) error
 	DropSubscription(database, rp, name string) error
 	DropUser(name string) error
@@ -27,6 +29,7 @@ type MetaClient interface {
 	SetAdminPrivilege(username string, admin bool) error
 	SetDefaultRetentionPolicy(database, name string) error
 	SetPrivilege(username, database string, p influxql.Privilege) error
+	ShardGroupsByTimeRange(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error)
 	ShardsByTimeRange(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error)
 	UpdateRetentionPolicy(database, name string, rpu *meta.RetentionPolicyUpdate) error
 	UpdateUser(name, password string) error
@@ -12,6 +12,7 @@ type MetaClient struct {
 	CreateContinuousQueryFn             func(database, name, query string) error
 	CreateDatabaseFn                    func(name string) (*meta.DatabaseInfo, error)
 	CreateDatabaseWithRetentionPolicyFn func(name string, rpi *meta.RetentionPolicyInfo) (*meta.DatabaseInfo, error)
+	CreateMeasurementSchemaFn           func(database string, msi *meta.MeasurementSchemaInfo) error
 	CreateRetentionPolicyFn             func(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error)
 	CreateSubscriptionFn                func(database, rp, name, mode string, destinations []string) error
 	CreateUserFn                        func(name, password string, admin bool) (*meta.UserInfo, error)
@@ -23,6 +24,7 @@ type MetaClient struct {
 	DeleteMetaNodeFn                    func(id uint64) error
 	DropContinuousQueryFn               func(database, name string) error
 	DropDatabaseFn                      func(name string) error
+	DropMeasurementSchemaFn             func(database, name string) error
 	DropRetentionPolicyFn               func(database, name string) error
 	DropSubscriptionFn                  func(database, rp, name string) error
 	DropShardFn                         func(id uint64) error
@@ -32,6 +34,7 @@ type MetaClient struct {
 	SetAdminPrivilegeFn                 func(username string, admin bool) error
 	SetDefaultRetentionPolicyFn         func(database, name string) error
 	SetPrivilegeFn                      func(username, database string, p influxql.Privilege) error
+	ShardGroupsByTimeRangeFn            func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error)
 	ShardsByTimeRangeFn                 func(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error)
 	UpdateRetentionPolicyFn             func(database, name string, rpu *meta.RetentionPolicyUpdate) error
 	UpdateUserFn                        func(name, password string) error
@@ -52,6 +55,10 @@ func (c *MetaClient) CreateDatabaseWithRetentionPolicy(name string, rpi *meta.Re
 	return c.CreateDatabaseWithRetentionPolicyFn(name, rpi)
 }
 
+func (c *MetaClient) CreateMeasurementSchema(database string, msi *meta.MeasurementSchemaInfo) error {
+	return c.CreateMeasurementSchemaFn(database, msi)
+}
+
 func (c *MetaClient) CreateRetentionPolicy(database string, rpi *meta.RetentionPolicyInfo) (*meta.RetentionPolicyInfo, error) {
 	return c.CreateRetentionPolicyFn(database, rpi)
 }
@@ -99,6 +106,10 @@ func (c *MetaClient) DropDatabase(name string) error {
 	return c.DropDatabaseFn(name)
 }
 
+func (c *MetaClient) DropMeasurementSchema(database, name string) error {
+	return c.DropMeasurementSchemaFn(database, name)
+}
+
 func (c *MetaClient) DropRetentionPolicy(database, name string) error {
 	return c.DropRetentionPolicyFn(database, name)
 }
@@ -131,6 +142,10 @@ func (c *MetaClient) SetPrivilege(username, database string, p influxql.Privileg
 	return c.SetPrivilegeFn(username, database, p)
 }
 
+func (c *MetaClient) ShardGroupsByTimeRange(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error) {
+	return c.ShardGroupsByTimeRangeFn(database, policy, min, max)
+}
+
 func (c *MetaClient) ShardsByTimeRange(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error) {
 	return c.ShardsByTimeRangeFn(sources, tmin, tmax)
 }
@@ -3,6 +3,7 @@ package cluster
 import (
 	"errors"
 	"expvar"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -56,6 +57,7 @@ type PointsWriter struct {
 		RetentionPolicy(database, policy string) (*meta.RetentionPolicyInfo, error)
 		CreateShardGroup(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error)
 		ShardOwner(shardID uint64) (string, string, *meta.ShardGroupInfo)
+		MeasurementSchemas(database string) map[string]*meta.MeasurementSchemaInfo
 	}
 
 	TSDBStore interface {
@@ -204,6 +206,10 @@ func (w *PointsWriter) WritePoints(database, retentionPolicy string, consistency
 		retentionPolicy = db.DefaultRetentionPolicy
 	}
 
+	if err := w.checkSchemas(database, points); err != nil {
+		return err
+	}
+
 	shardMappings, err := w.MapShards(&WritePointsRequest{Database: database, RetentionPolicy: retentionPolicy, Points: points})
 	if err != nil {
 		return err
@@ -247,6 +253,26 @@ func (w *PointsWriter) WritePoints(database, retentionPolicy string, consistency
 	return nil
 }
 
+// checkSchemas returns an error if any of the points does not conform to the
+// declared schema of its measurement.
+func (w *PointsWriter) checkSchemas(database string, points []models.Point) error {
+	schemas := w.MetaClient.MeasurementSchemas(database)
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	for _, p := range points {
+		msi := schemas[p.Name()]
+		if msi == nil {
+			continue
+		}
+		if err := msi.CheckPoint(p.Tags(), p.Fields()); err != nil {
+			return fmt.Errorf("%s: measurement %q: %s", influxdb.ErrSchemaViolation, p.Name(), err)
+		}
+	}
+	return nil
+}
+
 // writeToShards writes points to a shard.
 func (w *PointsWriter) writeToShard(shard *meta.ShardInfo, database, retentionPolicy string, points []models.Point) error {
 	w.statMap.Add(statPointWriteReqLocal, int64(len(points)))
@@ -257,6 +283,13 @@ func (w *PointsWriter) writeToShard(shard *meta.ShardInfo, database, retentionPo
 		return nil
 	}
 
+	// The remaining points were written, and retrying would drop the same
+	// points again.
+	if _, ok := err.(tsdb.PartialWriteError); ok {
+		w.statMap.Add(statWriteErr, 1)
+		return err
+	}
+
 	// If we've written to shard that should exist on the current node, but the store has
 	// not actually created this shard, tell it to create it and retry the write
 	if err == tsdb.ErrShardNotFound {
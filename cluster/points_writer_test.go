@@ -10,6 +10,7 @@ import (
 
 	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/cluster"
+	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/services/meta"
 )
@@ -258,6 +259,67 @@ func TestPointsWriter_WritePoints(t *testing.T) {
 	}
 }
 
+// Ensures the points writer rejects writes that do not conform to the
+// declared schema of a measurement.
+func TestPointsWriter_WritePoints_SchemaViolation(t *testing.T) {
+	var n int64
+	store := &fakeStore{
+		WriteFn: func(shardID uint64, points []models.Point) error {
+			atomic.AddInt64(&n, int64(len(points)))
+			return nil
+		},
+	}
+
+	ms := NewPointsWriterMetaClient()
+	ms.MeasurementSchemasFn = func(database string) map[string]*meta.MeasurementSchemaInfo {
+		return map[string]*meta.MeasurementSchemaInfo{
+			"cpu": {
+				Name:    "cpu",
+				TagKeys: []string{"host"},
+				Fields:  map[string]influxql.DataType{"value": influxql.Float},
+				Strict:  true,
+			},
+		}
+	}
+
+	c := cluster.NewPointsWriter()
+	c.MetaClient = ms
+	c.TSDBStore = store
+	c.Subscriber = Subscriber{PointsFn: func() chan<- *cluster.WritePointsRequest { return nil }}
+	c.Node = &influxdb.Node{ID: 1}
+	c.Open()
+	defer c.Close()
+
+	for _, tt := range []struct {
+		point models.Point
+		err   string
+	}{
+		{point: models.MustNewPoint("cpu", models.Tags{"host": "a"}, models.Fields{"value": 1.0}, time.Unix(0, 0))},
+		{point: models.MustNewPoint("mem", models.Tags{"dc": "a"}, models.Fields{"free": int64(1)}, time.Unix(0, 0))},
+		{point: models.MustNewPoint("cpu", models.Tags{"dc": "a"}, models.Fields{"value": 1.0}, time.Unix(0, 0)), err: `schema violation: measurement "cpu": tag "dc" is not declared`},
+		{point: models.MustNewPoint("cpu", nil, models.Fields{"value": int64(1)}, time.Unix(0, 0)), err: `schema violation: measurement "cpu": field "value" is type integer, declared as type float`},
+	} {
+		atomic.StoreInt64(&n, 0)
+		err := c.WritePoints("mydb", "myrp", models.ConsistencyLevelOne, []models.Point{tt.point})
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", tt.point, err)
+			} else if atomic.LoadInt64(&n) != 1 {
+				t.Errorf("%s: point not written", tt.point)
+			}
+			continue
+		}
+
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: unexpected error: %v", tt.point, err)
+		} else if !influxdb.IsClientError(err) {
+			t.Errorf("%s: expected client error", tt.point)
+		} else if atomic.LoadInt64(&n) != 0 {
+			t.Errorf("%s: point written", tt.point)
+		}
+	}
+}
+
 var shardID uint64
 
 type fakeShardWriter struct {
@@ -316,6 +378,7 @@ type PointsWriterMetaClient struct {
 	CreateShardGroupIfNotExistsFn func(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error)
 	DatabaseFn                    func(database string) *meta.DatabaseInfo
 	ShardOwnerFn                  func(shardID uint64) (string, string, *meta.ShardGroupInfo)
+	MeasurementSchemasFn          func(database string) map[string]*meta.MeasurementSchemaInfo
 }
 
 func (m PointsWriterMetaClient) NodeID() uint64 { return m.NodeIDFn() }
@@ -336,6 +399,13 @@ func (m PointsWriterMetaClient) ShardOwner(shardID uint64) (string, string, *met
 	return m.ShardOwnerFn(shardID)
 }
 
+func (m PointsWriterMetaClient) MeasurementSchemas(database string) map[string]*meta.MeasurementSchemaInfo {
+	if m.MeasurementSchemasFn == nil {
+		return nil
+	}
+	return m.MeasurementSchemasFn(database)
+}
+
 type Subscriber struct {
 	PointsFn func() chan<- *cluster.WritePointsRequest
 }
@@ -0,0 +1,148 @@
+package cluster
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// QueryCache is an LRU cache of the rows returned by SELECT statements.  A
+// result is only reused while the shards it was read from are unchanged.  Its
+// size is the total number of values of the cached rows plus one for each
+// result, so empty results are counted too.
+type QueryCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	ll      *list.List
+	items   map[string]*list.Element
+}
+
+type queryCacheItem struct {
+	key         string
+	shards      []uint64 // ids of the shards the rows were read from
+	generations []uint64 // generations of the shards when they were read
+	rows        models.Rows
+	size        int
+}
+
+// NewQueryCache returns a cache holding up to maxSize values, or nil if
+// maxSize is zero.
+func NewQueryCache(maxSize int) *QueryCache {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &QueryCache{
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// get returns a copy of the rows cached for key if they were read from the
+// same shards at the same generations.  Rows read from shards that have
+// changed since are removed.
+func (c *QueryCache) get(key string, shards, generations []uint64) (models.Rows, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	item := e.Value.(*queryCacheItem)
+	if !equalUint64s(item.shards, shards) || !equalUint64s(item.generations, generations) {
+		c.removeElement(e)
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return copyRows(item.rows), true
+}
+
+// add caches the rows of key read from shards at generations and evicts the
+// least recently used results until the cache is within its size.  The rows
+// must not be modified after they are added.  Results larger than the cache
+// are not added.
+func (c *QueryCache) add(key string, shards, generations []uint64, rows models.Rows) {
+	size := rowsSize(rows)
+	if size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+	}
+
+	c.items[key] = c.ll.PushFront(&queryCacheItem{
+		key:         key,
+		shards:      shards,
+		generations: generations,
+		rows:        rows,
+		size:        size,
+	})
+	c.size += size
+
+	for c.size > c.maxSize {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *QueryCache) removeElement(e *list.Element) {
+	item := e.Value.(*queryCacheItem)
+	c.ll.Remove(e)
+	delete(c.items, item.key)
+	c.size -= item.size
+}
+
+// len returns the number of results in the cache.
+func (c *QueryCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// rowsSize returns the size of rows in the cache.
+func rowsSize(rows models.Rows) int {
+	n := 1
+	for _, row := range rows {
+		n += len(row.Values)
+	}
+	return n
+}
+
+// copyRow returns a copy of row whose values can be modified without
+// changing row.  The tags and columns are shared.
+func copyRow(row *models.Row) *models.Row {
+	other := *row
+	other.Values = make([][]interface{}, len(row.Values))
+	for i, values := range row.Values {
+		other.Values[i] = append([]interface{}(nil), values...)
+	}
+	return &other
+}
+
+// copyRows returns a copy of rows made with copyRow.
+func copyRows(rows models.Rows) models.Rows {
+	other := make(models.Rows, len(rows))
+	for i, row := range rows {
+		other[i] = copyRow(row)
+	}
+	return other
+}
+
+func equalUint64s(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
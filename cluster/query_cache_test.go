@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// Ensure the query cache evicts the least recently used results once it is
+// full and drops results read from shards that have changed.
+func TestQueryCache(t *testing.T) {
+	c := NewQueryCache(8)
+	rows := func(n int) models.Rows {
+		row := &models.Row{Name: "cpu", Columns: []string{"time", "value"}}
+		for i := 0; i < n; i++ {
+			row.Values = append(row.Values, []interface{}{int64(i), float64(i)})
+		}
+		return models.Rows{row}
+	}
+
+	c.add("a", []uint64{1}, []uint64{0}, rows(3))
+	c.add("b", []uint64{2}, []uint64{0}, rows(3))
+	if a, ok := c.get("a", []uint64{1}, []uint64{0}); !ok || !reflect.DeepEqual(a, rows(3)) {
+		t.Fatalf("unexpected result for a: %v", a)
+	}
+
+	// Adding c evicts b as a was read more recently.
+	c.add("c", []uint64{3}, []uint64{0}, rows(2))
+	if _, ok := c.get("b", []uint64{2}, []uint64{0}); ok {
+		t.Fatal("expected b to be evicted")
+	} else if n := c.len(); n != 2 {
+		t.Fatalf("unexpected number of results: %d", n)
+	}
+
+	// A result larger than the cache is not added.
+	c.add("d", []uint64{4}, []uint64{0}, rows(8))
+	if _, ok := c.get("d", []uint64{4}, []uint64{0}); ok {
+		t.Fatal("expected d not to be cached")
+	}
+
+	// A result is dropped once a shard it was read from changes.
+	if _, ok := c.get("a", []uint64{1}, []uint64{1}); ok {
+		t.Fatal("expected a to be stale")
+	} else if _, ok := c.get("a", []uint64{1}, []uint64{0}); ok {
+		t.Fatal("expected a to be removed")
+	}
+	if _, ok := c.get("c", []uint64{3, 5}, []uint64{0, 0}); ok {
+		t.Fatal("expected c to be stale")
+	} else if n := c.len(); n != 0 {
+		t.Fatalf("unexpected number of results: %d", n)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/influxdata/influxdb"
@@ -31,10 +33,27 @@ type StatementExecutor struct {
 		WritePointsInto(*IntoWriteRequest) error
 	}
 
+	// Maximum number of shards a select statement reads at once.
+	// Zero uses the number of CPUs.
+	MaxConcurrentShardQueries int
+
 	// Select statement limits
 	MaxSelectPointN   int
 	MaxSelectSeriesN  int
 	MaxSelectBucketsN int
+
+	// Maximum number of bytes buffered by a select statement. Zero is unlimited.
+	MaxSelectMemory int64
+
+	// Memory budget shared by all select statements.
+	SelectMemoryBudget *influxql.MemoryBudget
+
+	// Maximum number of points a SELECT ... INTO statement writes at once.
+	// Zero uses DefaultIntoBatchSize.
+	IntoBatchSize int
+
+	// Cache of the results of repeated select statements. Nil disables it.
+	QueryCache *QueryCache
 }
 
 func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
@@ -47,6 +66,21 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx *influ
 	var messages []*influxql.Message
 	var err error
 	switch stmt := stmt.(type) {
+	case *influxql.CompactShardStatement:
+		if ctx.ReadOnly {
+			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
+		}
+		err = e.executeCompactShardStatement(stmt)
+	case *influxql.PauseCompactionsStatement:
+		if ctx.ReadOnly {
+			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
+		}
+		err = e.executePauseCompactionsStatement(stmt)
+	case *influxql.AlterShardTierStatement:
+		if ctx.ReadOnly {
+			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
+		}
+		err = e.executeAlterShardTierStatement(stmt)
 	case *influxql.AlterRetentionPolicyStatement:
 		if ctx.ReadOnly {
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
@@ -79,13 +113,18 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx *influ
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
 		}
 		err = e.executeCreateSubscriptionStatement(stmt)
+	case *influxql.CreateSchemaStatement:
+		if ctx.ReadOnly {
+			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
+		}
+		err = e.executeCreateSchemaStatement(stmt)
 	case *influxql.CreateUserStatement:
 		if ctx.ReadOnly {
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
 		}
 		err = e.executeCreateUserStatement(stmt)
 	case *influxql.DeleteSeriesStatement:
-		err = e.executeDeleteSeriesStatement(stmt, ctx.Database)
+		err = e.executeDeleteSeriesStatement(stmt, ctx)
 	case *influxql.DropContinuousQueryStatement:
 		if ctx.ReadOnly {
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
@@ -96,6 +135,11 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx *influ
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
 		}
 		err = e.executeDropDatabaseStatement(stmt)
+	case *influxql.DropSchemaStatement:
+		if ctx.ReadOnly {
+			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
+		}
+		err = e.executeDropSchemaStatement(stmt)
 	case *influxql.DropMeasurementStatement:
 		if ctx.ReadOnly {
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
@@ -126,6 +170,8 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx *influ
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
 		}
 		err = e.executeDropUserStatement(stmt)
+	case *influxql.ExplainStatement:
+		rows, err = e.executeExplainStatement(stmt, ctx)
 	case *influxql.GrantStatement:
 		if ctx.ReadOnly {
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
@@ -150,12 +196,18 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx *influ
 		rows, err = e.executeShowContinuousQueriesStatement(stmt)
 	case *influxql.ShowDatabasesStatement:
 		rows, err = e.executeShowDatabasesStatement(stmt)
+	case *influxql.ShowSchemasStatement:
+		rows, err = e.executeShowSchemasStatement(stmt)
 	case *influxql.ShowDiagnosticsStatement:
 		rows, err = e.executeShowDiagnosticsStatement(stmt)
 	case *influxql.ShowGrantsForUserStatement:
 		rows, err = e.executeShowGrantsForUserStatement(stmt)
+	case *influxql.ShowMeasurementCardinalityStatement:
+		rows, err = e.executeShowMeasurementCardinalityStatement(stmt, ctx.Database)
 	case *influxql.ShowRetentionPoliciesStatement:
 		rows, err = e.executeShowRetentionPoliciesStatement(stmt)
+	case *influxql.ShowSeriesCardinalityStatement:
+		rows, err = e.executeShowSeriesCardinalityStatement(stmt, ctx.Database)
 	case *influxql.ShowShardsStatement:
 		rows, err = e.executeShowShardsStatement(stmt)
 	case *influxql.ShowShardGroupsStatement:
@@ -164,6 +216,8 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx *influ
 		rows, err = e.executeShowStatsStatement(stmt)
 	case *influxql.ShowSubscriptionsStatement:
 		rows, err = e.executeShowSubscriptionsStatement(stmt)
+	case *influxql.ShowTagValuesCardinalityStatement:
+		rows, err = e.executeShowTagValuesCardinalityStatement(stmt, ctx.Database)
 	case *influxql.ShowUsersStatement:
 		rows, err = e.executeShowUsersStatement(stmt)
 	case *influxql.SetPasswordUserStatement:
@@ -193,6 +247,9 @@ func (e *StatementExecutor) executeAlterRetentionPolicyStatement(stmt *influxql.
 		ReplicaN:           stmt.Replication,
 		ShardGroupDuration: stmt.ShardGroupDuration,
 	}
+	if stmt.MeasurementDuration != nil {
+		rpu.SetMeasurementDuration(stmt.Measurement, *stmt.MeasurementDuration)
+	}
 
 	// Update the retention policy.
 	if err := e.MetaClient.UpdateRetentionPolicy(stmt.Database, stmt.Name, rpu); err != nil {
@@ -209,6 +266,18 @@ func (e *StatementExecutor) executeAlterRetentionPolicyStatement(stmt *influxql.
 	return nil
 }
 
+func (e *StatementExecutor) executeCompactShardStatement(stmt *influxql.CompactShardStatement) error {
+	return e.TSDBStore.ScheduleFullCompaction(stmt.ID)
+}
+
+func (e *StatementExecutor) executePauseCompactionsStatement(stmt *influxql.PauseCompactionsStatement) error {
+	return e.TSDBStore.SetCompactionsEnabled(stmt.ShardID, stmt.Resume)
+}
+
+func (e *StatementExecutor) executeAlterShardTierStatement(stmt *influxql.AlterShardTierStatement) error {
+	return e.TSDBStore.MoveShard(stmt.ID, stmt.Cold)
+}
+
 func (e *StatementExecutor) executeCreateContinuousQueryStatement(q *influxql.CreateContinuousQueryStatement) error {
 	return e.MetaClient.CreateContinuousQuery(q.Database, q.Name, q.String())
 }
@@ -256,18 +325,36 @@ func (e *StatementExecutor) executeCreateUserStatement(q *influxql.CreateUserSta
 	return err
 }
 
-func (e *StatementExecutor) executeDeleteSeriesStatement(stmt *influxql.DeleteSeriesStatement, database string) error {
+func (e *StatementExecutor) executeDeleteSeriesStatement(stmt *influxql.DeleteSeriesStatement, ctx *influxql.ExecutionContext) error {
+	database := ctx.Database
 	if dbi := e.MetaClient.Database(database); dbi == nil {
 		return influxql.ErrDatabaseNotFound(database)
 	}
 
 	// Convert "now()" to current time.
-	stmt.Condition = influxql.Reduce(stmt.Condition, &influxql.NowValuer{Now: time.Now().UTC()})
+	stmt.Condition = influxql.Reduce(stmt.Condition, &influxql.NowValuer{Now: statementNow(ctx)})
 
 	// Locally delete the series.
 	return e.TSDBStore.DeleteSeries(database, stmt.Sources, stmt.Condition)
 }
 
+func (e *StatementExecutor) executeCreateSchemaStatement(stmt *influxql.CreateSchemaStatement) error {
+	msi := &meta.MeasurementSchemaInfo{
+		Name:    stmt.Name,
+		TagKeys: stmt.TagKeys,
+		Fields:  make(map[string]influxql.DataType, len(stmt.Fields)),
+		Strict:  stmt.Strict,
+	}
+	for _, f := range stmt.Fields {
+		msi.Fields[f.Name] = f.Type
+	}
+	return e.MetaClient.CreateMeasurementSchema(stmt.Database, msi)
+}
+
+func (e *StatementExecutor) executeDropSchemaStatement(stmt *influxql.DropSchemaStatement) error {
+	return e.MetaClient.DropMeasurementSchema(stmt.Database, stmt.Name)
+}
+
 func (e *StatementExecutor) executeDropContinuousQueryStatement(q *influxql.DropContinuousQueryStatement) error {
 	return e.MetaClient.DropContinuousQuery(q.Database, q.Name)
 }
@@ -367,75 +454,56 @@ func (e *StatementExecutor) executeSetPasswordUserStatement(q *influxql.SetPassw
 	return e.MetaClient.UpdateUser(q.Name, q.Password)
 }
 
-func (e *StatementExecutor) executeSelectStatement(stmt *influxql.SelectStatement, ctx *influxql.ExecutionContext) error {
-	// It is important to "stamp" this time so that everywhere we evaluate `now()` in the statement is EXACTLY the same `now`
-	now := time.Now().UTC()
-	opt := influxql.SelectOptions{InterruptCh: ctx.InterruptCh}
-
-	// Replace instances of "now()" with the current time, and check the resultant times.
-	nowValuer := influxql.NowValuer{Now: now}
-	stmt.Condition = influxql.Reduce(stmt.Condition, &nowValuer)
-	// Replace instances of "now()" with the current time in the dimensions.
-	for _, d := range stmt.Dimensions {
-		d.Expr = influxql.Reduce(d.Expr, &nowValuer)
-	}
-
-	var err error
-	opt.MinTime, opt.MaxTime, err = influxql.TimeRange(stmt.Condition)
-	if err != nil {
-		return err
+// statementNow returns the value of now() for the statement executing in ctx.
+func statementNow(ctx *influxql.ExecutionContext) time.Time {
+	if !ctx.Now.IsZero() {
+		return ctx.Now.UTC()
 	}
+	return time.Now().UTC()
+}
 
-	if opt.MaxTime.IsZero() {
-		opt.MaxTime = now
-	}
-	if opt.MinTime.IsZero() {
-		opt.MinTime = time.Unix(0, 0)
+func (e *StatementExecutor) executeSelectStatement(stmt *influxql.SelectStatement, ctx *influxql.ExecutionContext) error {
+	// It is important to "stamp" this time so that everywhere we evaluate `now()` in the statement is EXACTLY the same `now`
+	now := statementNow(ctx)
+	opt := influxql.SelectOptions{
+		InterruptCh:  ctx.InterruptCh,
+		MemoryBudget: e.newSelectMemoryBudget(),
 	}
+	defer opt.MemoryBudget.Close()
 
-	// Convert DISTINCT into a call.
-	stmt.RewriteDistinct()
-
-	// Remove "time" from fields list.
-	stmt.RewriteTimeFields()
-
-	// Create an iterator creator based on the shards in the cluster.
-	ic, err := e.iteratorCreator(stmt, &opt)
+	stmt, ic, err := e.prepareSelectStatement(stmt, now, &opt)
 	if err != nil {
 		return err
 	}
 
-	// Expand regex sources to their actual source names.
-	if stmt.Sources.HasRegex() {
-		sources, err := ic.ExpandSources(stmt.Sources)
-		if err != nil {
+	// Points of an INTO statement are written in batches. Rows are emitted
+	// in chunks of the batch size so a series is never buffered whole.
+	chunkSize := ctx.ChunkSize
+	var w *intoWriter
+	if stmt.Target != nil {
+		if w, err = e.newIntoWriter(stmt.Target, ctx.InterruptCh); err != nil {
 			return err
 		}
-		stmt.Sources = sources
+		chunkSize = w.batchSize
 	}
 
-	// Rewrite wildcards, if any exist.
-	tmp, err := stmt.RewriteWildcards(ic)
+	// Resolve the HAVING clause to the columns of the rewritten statement.
+	having, err := stmt.HavingCondition()
 	if err != nil {
 		return err
 	}
-	stmt = tmp
 
-	if e.MaxSelectBucketsN > 0 && !stmt.IsRawQuery {
-		interval, err := stmt.GroupByInterval()
-		if err != nil {
+	// Reuse the result of an identical statement if the shards it was read
+	// from have not changed since.  The generations of the shards are read
+	// before the iterators are created so a write made while the statement
+	// runs invalidates its result.
+	var cache *queryCacheRequest
+	if e.QueryCache != nil && w == nil {
+		if cache, err = e.newQueryCacheRequest(stmt, opt, chunkSize); err != nil {
 			return err
-		}
-
-		if interval > 0 {
-			// Determine the start and end time matched to the interval (may not match the actual times).
-			min := opt.MinTime.Truncate(interval)
-			max := opt.MaxTime.Truncate(interval).Add(interval)
-
-			// Determine the number of buckets by finding the time span and dividing by the interval.
-			buckets := int64(max.Sub(min)) / int64(interval)
-			if int(buckets) > e.MaxSelectBucketsN {
-				return fmt.Errorf("max select bucket count exceeded: %d buckets", buckets)
+		} else if cache != nil {
+			if rows, ok := e.QueryCache.get(cache.key, cache.shards, cache.generations); ok {
+				return emitCachedRows(rows, ctx)
 			}
 		}
 	}
@@ -446,26 +514,35 @@ func (e *StatementExecutor) executeSelectStatement(stmt *influxql.SelectStatemen
 		return err
 	}
 
+	// Add the series and points read to the query once the iterators have
+	// been closed by the emitter.
+	defer func() {
+		ctx.Query.AddStats(influxql.Iterators(itrs).Stats())
+	}()
+
 	if e.MaxSelectPointN > 0 {
 		monitor := influxql.PointLimitMonitor(itrs, influxql.DefaultStatsInterval, e.MaxSelectPointN)
 		ctx.Query.Monitor(monitor)
 	}
 
 	// Generate a row emitter from the iterator set.
-	em := influxql.NewEmitter(itrs, stmt.TimeAscending(), ctx.ChunkSize)
+	em := influxql.NewEmitter(itrs, stmt.TimeAscending(), chunkSize)
 	em.Columns = stmt.ColumnNames()
 	em.OmitTime = stmt.OmitTime
+	em.Location = stmt.Location
+	em.Condition = having
 	defer em.Close()
 
 	// Calculate initial stats across all iterators.
 	stats := influxql.Iterators(itrs).Stats()
 	if e.MaxSelectSeriesN > 0 && stats.SeriesN > e.MaxSelectSeriesN {
-		return fmt.Errorf("max select series count exceeded: %d series", stats.SeriesN)
+		return fmt.Errorf("max-select-series limit exceeded: (%d/%d)", stats.SeriesN, e.MaxSelectSeriesN)
 	}
 
-	// Emit rows to the results channel.
-	var writeN int64
+	// Emit rows to the results channel. Each result is held back until the
+	// next row is read so it can be marked as partial if more rows follow.
 	var emitted bool
+	var result *influxql.Result
 	for {
 		row, err := em.Emit()
 		if err != nil {
@@ -481,31 +558,54 @@ func (e *StatementExecutor) executeSelectStatement(stmt *influxql.SelectStatemen
 		}
 
 		// Write points back into system for INTO statements.
-		if stmt.Target != nil {
-			if err := e.writeInto(stmt, row); err != nil {
+		if w != nil {
+			if err := w.WriteRow(row); err != nil {
 				return err
 			}
-			writeN += int64(len(row.Values))
 			continue
 		}
 
-		result := &influxql.Result{
+		// Keep a copy of the row to cache as the results are modified by
+		// their readers.  Results larger than the cache are not kept.
+		if cache != nil {
+			cache.rows = append(cache.rows, copyRow(row))
+			if cache.size += len(row.Values); cache.size > e.QueryCache.maxSize {
+				cache = nil
+			}
+		}
+
+		// Send the previous result or exit if closing.
+		if result != nil {
+			result.Partial = true
+			select {
+			case <-ctx.InterruptCh:
+				return influxql.ErrQueryInterrupted
+			case ctx.Results <- result:
+			}
+		}
+
+		result = &influxql.Result{
 			StatementID: ctx.StatementID,
 			Series:      []*models.Row{row},
 		}
+		emitted = true
+	}
 
-		// Send results or exit if closing.
+	// Send the last result.
+	if result != nil {
 		select {
 		case <-ctx.InterruptCh:
 			return influxql.ErrQueryInterrupted
 		case ctx.Results <- result:
 		}
-
-		emitted = true
 	}
 
 	// Emit write count if an INTO statement.
-	if stmt.Target != nil {
+	if w != nil {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
 		var messages []*influxql.Message
 		if ctx.ReadOnly {
 			messages = append(messages, influxql.ReadOnlyWarning(stmt.String()))
@@ -517,12 +617,16 @@ func (e *StatementExecutor) executeSelectStatement(stmt *influxql.SelectStatemen
 			Series: []*models.Row{{
 				Name:    "result",
 				Columns: []string{"time", "written"},
-				Values:  [][]interface{}{{time.Unix(0, 0).UTC(), writeN}},
+				Values:  [][]interface{}{{time.Unix(0, 0).UTC(), w.writeN}},
 			}},
 		}
 		return nil
 	}
 
+	if cache != nil {
+		e.QueryCache.add(cache.key, cache.shards, cache.generations, cache.rows)
+	}
+
 	// Always emit at least one result.
 	if !emitted {
 		ctx.Results <- &influxql.Result{
@@ -534,14 +638,427 @@ func (e *StatementExecutor) executeSelectStatement(stmt *influxql.SelectStatemen
 	return nil
 }
 
+// queryCacheRequest holds the key of the cached result of a select statement,
+// the shards it is read from and the rows read so far.
+type queryCacheRequest struct {
+	key         string
+	shards      []uint64
+	generations []uint64
+	rows        models.Rows
+	size        int // size of the rows in the cache
+}
+
+// newQueryCacheRequest returns the request to cache the result of stmt, or nil
+// if its result cannot be cached.  Only statements reading measurements are
+// cached since the shards of subqueries and joins are not known here, and only
+// if every shard read is held by the local store since the generations of
+// shards on other nodes are not known.
+func (e *StatementExecutor) newQueryCacheRequest(stmt *influxql.SelectStatement, opt influxql.SelectOptions, chunkSize int) (*queryCacheRequest, error) {
+	for _, src := range stmt.Sources {
+		if _, ok := src.(*influxql.Measurement); !ok {
+			return nil, nil
+		}
+	}
+
+	shards, err := e.MetaClient.ShardsByTimeRange(stmt.Sources, opt.MinTime, opt.MaxTime)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, len(shards))
+	for i, sh := range shards {
+		ids[i] = sh.ID
+	}
+
+	generations, ok := e.TSDBStore.ShardGenerations(ids)
+	if !ok {
+		return nil, nil
+	}
+
+	// The statement has been rewritten with "now()" replaced, so the key holds
+	// the time bounds of the condition as well as the implied ones.
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%d\x00%t\x00%t", stmt.String(), opt.MinTime.UnixNano(), opt.MaxTime.UnixNano(), chunkSize, stmt.OmitTime, stmt.Dedupe)
+	return &queryCacheRequest{
+		key:         key,
+		shards:      ids,
+		generations: generations,
+		size:        rowsSize(nil),
+	}, nil
+}
+
+// emitCachedRows sends cached rows to the results channel the same way the
+// rows of an executed select statement are sent.
+func emitCachedRows(rows models.Rows, ctx *influxql.ExecutionContext) error {
+	if len(rows) == 0 {
+		ctx.Results <- &influxql.Result{
+			StatementID: ctx.StatementID,
+			Series:      make([]*models.Row, 0),
+		}
+		return nil
+	}
+
+	for i, row := range rows {
+		select {
+		case <-ctx.InterruptCh:
+			return influxql.ErrQueryInterrupted
+		case ctx.Results <- &influxql.Result{
+			StatementID: ctx.StatementID,
+			Series:      []*models.Row{row},
+			Partial:     i < len(rows)-1,
+		}:
+		}
+	}
+	return nil
+}
+
+func (e *StatementExecutor) executeExplainStatement(q *influxql.ExplainStatement, ctx *influxql.ExecutionContext) (models.Rows, error) {
+	if q.Analyze {
+		return e.executeExplainAnalyzeStatement(q, ctx)
+	}
+
+	opt := influxql.SelectOptions{InterruptCh: ctx.InterruptCh}
+	stmt, ic, err := e.prepareSelectStatement(q.Statement, statementNow(ctx), &opt)
+	if err != nil {
+		return nil, err
+	}
+
+	plans, err := influxql.Explain(stmt, ic, &opt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(models.Rows, 0, len(plans))
+	for _, plan := range plans {
+		row := &models.Row{Columns: []string{"QUERY PLAN"}}
+		if plan.Expr != nil {
+			row.Values = append(row.Values, []interface{}{fmt.Sprintf("EXPRESSION: %s", plan.Expr)})
+		} else {
+			row.Values = append(row.Values, []interface{}{"EXPRESSION: <nil>"})
+		}
+		if len(plan.Aux) > 0 {
+			row.Values = append(row.Values, []interface{}{fmt.Sprintf("AUXILIARY FIELDS: %s", strings.Join(influxql.VarRefs(plan.Aux).Strings(), ", "))})
+		}
+		row.Values = append(row.Values,
+			[]interface{}{fmt.Sprintf("NUMBER OF SHARDS: %d", plan.Cost.NumShards)},
+			[]interface{}{fmt.Sprintf("NUMBER OF SERIES: %d", plan.Cost.NumSeries)},
+			[]interface{}{fmt.Sprintf("CACHED VALUES: %d", plan.Cost.CachedValues)},
+			[]interface{}{fmt.Sprintf("NUMBER OF FILES: %d", plan.Cost.NumFiles)},
+			[]interface{}{fmt.Sprintf("NUMBER OF BLOCKS: %d", plan.Cost.BlocksRead)},
+			[]interface{}{fmt.Sprintf("SIZE OF BLOCKS: %d", plan.Cost.BlockSize)},
+		)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// executeExplainAnalyzeStatement runs the SELECT of q and reports the time
+// spent in each stage and the work done by the iterators. The selected rows
+// are discarded and nothing is written for an INTO clause.
+func (e *StatementExecutor) executeExplainAnalyzeStatement(q *influxql.ExplainStatement, ctx *influxql.ExecutionContext) (models.Rows, error) {
+	start := time.Now()
+	opt := influxql.SelectOptions{
+		InterruptCh:  ctx.InterruptCh,
+		MemoryBudget: e.newSelectMemoryBudget(),
+	}
+	defer opt.MemoryBudget.Close()
+	stmt, ic, err := e.prepareSelectStatement(q.Statement, statementNow(ctx), &opt)
+	if err != nil {
+		return nil, err
+	}
+	having, err := stmt.HavingCondition()
+	if err != nil {
+		return nil, err
+	}
+	planned := time.Now()
+
+	// Keep the iterators created from the shards since the statistics of
+	// the iterators returned by Select do not always include their inputs.
+	aic := &analyzeIteratorCreator{IteratorCreator: ic}
+	itrs, err := influxql.Select(stmt, aic, &opt)
+	if err != nil {
+		return nil, err
+	}
+	created := time.Now()
+
+	em := influxql.NewEmitter(itrs, stmt.TimeAscending(), 0)
+	em.Columns = stmt.ColumnNames()
+	em.OmitTime = stmt.OmitTime
+	em.Condition = having
+	defer em.Close()
+
+	var seriesN, pointN int
+	for {
+		row, err := em.Emit()
+		if err != nil {
+			return nil, err
+		} else if row == nil {
+			select {
+			case <-ctx.InterruptCh:
+				return nil, influxql.ErrQueryInterrupted
+			default:
+			}
+			break
+		}
+		seriesN++
+		pointN += len(row.Values)
+	}
+	stats := aic.itrs.Stats()
+	finished := time.Now()
+
+	return models.Rows{{
+		Columns: []string{"QUERY PLAN"},
+		Values: [][]interface{}{
+			{fmt.Sprintf("PLANNING TIME: %s", planned.Sub(start))},
+			{fmt.Sprintf("ITERATOR CREATION TIME: %s", created.Sub(planned))},
+			{fmt.Sprintf("EXECUTION TIME: %s", finished.Sub(created))},
+			{fmt.Sprintf("TOTAL TIME: %s", finished.Sub(start))},
+			{fmt.Sprintf("SERIES SCANNED: %d", stats.SeriesN)},
+			{fmt.Sprintf("POINTS SCANNED: %d", stats.PointN)},
+			{fmt.Sprintf("BLOCKS DECODED: %d", stats.BlockN)},
+			{fmt.Sprintf("SERIES RETURNED: %d", seriesN)},
+			{fmt.Sprintf("POINTS RETURNED: %d", pointN)},
+		},
+	}}, nil
+}
+
+// analyzeIteratorCreator keeps the iterators created by an IteratorCreator so
+// their statistics can be reported by EXPLAIN ANALYZE.
+type analyzeIteratorCreator struct {
+	influxql.IteratorCreator
+	itrs influxql.Iterators
+}
+
+func (ic *analyzeIteratorCreator) CreateIterator(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+	itr, err := ic.IteratorCreator.CreateIterator(opt)
+	if err != nil {
+		return nil, err
+	} else if itr != nil {
+		ic.itrs = append(ic.itrs, itr)
+	}
+	return itr, nil
+}
+
+// prepareSelectStatement rewrites stmt for execution and returns the rewritten
+// statement with the iterator creator to select it from.  The time range of
+// the statement is set on opt.  If opt already has a time range, as for a
+// subquery, the statement's time range is limited to it.
+func (e *StatementExecutor) prepareSelectStatement(stmt *influxql.SelectStatement, now time.Time, opt *influxql.SelectOptions) (*influxql.SelectStatement, influxql.IteratorCreator, error) {
+	// Replace instances of "now()" with the current time, and check the resultant times.
+	nowValuer := influxql.NowValuer{Now: now}
+	stmt.Condition = influxql.Reduce(stmt.Condition, &nowValuer)
+	// Replace instances of "now()" with the current time in the dimensions.
+	for _, d := range stmt.Dimensions {
+		d.Expr = influxql.Reduce(d.Expr, &nowValuer)
+	}
+
+	min, max, err := influxql.TimeRange(stmt.Condition)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if max.IsZero() {
+		max = now
+	}
+	if min.IsZero() {
+		min = time.Unix(0, 0)
+	}
+	if opt.MinTime.IsZero() || min.After(opt.MinTime) {
+		opt.MinTime = min
+	}
+	if opt.MaxTime.IsZero() || max.Before(opt.MaxTime) {
+		opt.MaxTime = max
+	}
+
+	// Convert DISTINCT into a call.
+	stmt.RewriteDistinct()
+
+	// Remove "time" from fields list.
+	stmt.RewriteTimeFields()
+
+	// Split histograms into a field per bucket.
+	stmt.RewriteHistograms()
+
+	// Filter the series of tag dimensions restricted by a regex.
+	stmt.RewriteDimensionFilters()
+
+	// Create an iterator creator based on the shards in the cluster, or on
+	// the results of a subquery or a join.
+	var ic influxql.IteratorCreator
+	if len(stmt.Sources) == 1 {
+		switch src := stmt.Sources[0].(type) {
+		case *influxql.SubQuery:
+			subOpt := influxql.SelectOptions{MinTime: opt.MinTime, MaxTime: opt.MaxTime, InterruptCh: opt.InterruptCh, MemoryBudget: opt.MemoryBudget}
+			sub, subIC, err := e.prepareSelectStatement(src.Statement, now, &subOpt)
+			if err != nil {
+				return nil, nil, err
+			}
+			src.Statement = sub
+			ic = influxql.NewSubQueryIteratorCreator(sub, subIC, subOpt)
+		case *influxql.Join:
+			if ic, err = e.joinIteratorCreator(stmt, src, now, opt); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if ic == nil {
+		if ic, err = e.iteratorCreator(stmt, opt); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Expand regex sources to their actual source names.
+	if stmt.Sources.HasRegex() {
+		sources, err := ic.ExpandSources(stmt.Sources)
+		if err != nil {
+			return nil, nil, err
+		}
+		stmt.Sources = sources
+	}
+
+	// Rewrite wildcards, if any exist.
+	tmp, err := stmt.RewriteWildcards(ic)
+	if err != nil {
+		return nil, nil, err
+	}
+	stmt = tmp
+
+	if e.MaxSelectBucketsN > 0 && !stmt.IsRawQuery {
+		interval, err := stmt.GroupByInterval()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if interval > 0 {
+			offset, err := stmt.GroupByOffset(nil)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// Determine the start and end time matched to the windows the
+			// query will use (may not match the actual times).
+			itrOpt := influxql.IteratorOptions{
+				Interval: influxql.Interval{Duration: interval, Offset: offset},
+				Location: stmt.Location,
+			}
+			min, _ := itrOpt.Window(opt.MinTime.UnixNano())
+			_, max := itrOpt.Window(opt.MaxTime.UnixNano())
+
+			// Determine the number of buckets by finding the time span and dividing by the interval.
+			buckets := int64(time.Unix(0, max).Sub(time.Unix(0, min))) / int64(interval)
+			if int(buckets) > e.MaxSelectBucketsN {
+				return nil, nil, fmt.Errorf("max-select-buckets limit exceeded: (%d/%d)", buckets, e.MaxSelectBucketsN)
+			}
+		}
+	}
+
+	return stmt, ic, nil
+}
+
+// joinIteratorCreator returns an IteratorCreator reading the points of the
+// measurements of j, the source of stmt, joined on their time and the tags
+// stmt is grouped by.  All fields of each measurement are selected, grouped
+// by all of its tags, within the time range of opt.
+func (e *StatementExecutor) joinIteratorCreator(stmt *influxql.SelectStatement, j *influxql.Join, now time.Time, opt *influxql.SelectOptions) (influxql.IteratorCreator, error) {
+	subOpt := influxql.SelectOptions{MinTime: opt.MinTime, MaxTime: opt.MaxTime, InterruptCh: opt.InterruptCh, MemoryBudget: opt.MemoryBudget}
+
+	stmts := make([]*influxql.SelectStatement, len(j.Measurements))
+	ics := make([]influxql.IteratorCreator, len(j.Measurements))
+	for i, m := range j.Measurements {
+		sub := &influxql.SelectStatement{
+			Fields:     influxql.Fields{{Expr: &influxql.Wildcard{}}},
+			Sources:    influxql.Sources{m},
+			Dimensions: influxql.Dimensions{{Expr: &influxql.Wildcard{}}},
+			IsRawQuery: true,
+		}
+
+		sub, ic, err := e.prepareSelectStatement(sub, now, &subOpt)
+		if err != nil {
+			return nil, err
+		}
+		stmts[i], ics[i] = sub, ic
+	}
+	return influxql.NewJoinIteratorCreator(stmt, j, stmts, ics, subOpt), nil
+}
+
 // iteratorCreator returns a new instance of IteratorCreator based on stmt.
 func (e *StatementExecutor) iteratorCreator(stmt *influxql.SelectStatement, opt *influxql.SelectOptions) (influxql.IteratorCreator, error) {
+	// Read the shard groups of a raw query with a limit one after another so
+	// the groups furthest from the start of the query are only read if the
+	// limit has not been reached.
+	groups, err := e.concatShardGroups(stmt, opt)
+	if err != nil {
+		return nil, err
+	} else if groups != nil {
+		ics := make(influxql.ConcatIteratorCreators, 0, len(groups))
+		for _, g := range groups {
+			ic, err := e.TSDBStore.IteratorCreator(g.Shards)
+			if err != nil {
+				ics.Close()
+				return nil, err
+			}
+			ics = append(ics, ic)
+		}
+		return ics, nil
+	}
+
 	// Retrieve a list of shard IDs.
 	shards, err := e.MetaClient.ShardsByTimeRange(stmt.Sources, opt.MinTime, opt.MaxTime)
 	if err != nil {
 		return nil, err
 	}
-	return e.TSDBStore.IteratorCreator(shards)
+	ic, err := e.TSDBStore.IteratorCreator(shards)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the shards concurrently and merge their points.
+	n := e.MaxConcurrentShardQueries
+	if n == 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if ics, ok := ic.(influxql.IteratorCreators); ok && len(ics) > 1 && n > 1 {
+		return influxql.NewParallelIteratorCreators(ics, n), nil
+	}
+	return ic, nil
+}
+
+// newSelectMemoryBudget returns the budget of the memory buffered by a single
+// select statement.
+func (e *StatementExecutor) newSelectMemoryBudget() *influxql.MemoryBudget {
+	return e.SelectMemoryBudget.NewChild("max-select-memory", e.MaxSelectMemory)
+}
+
+// concatShardGroups returns the shard groups of stmt in the order its points
+// are returned, or nil if the groups cannot be read one after another.
+func (e *StatementExecutor) concatShardGroups(stmt *influxql.SelectStatement, opt *influxql.SelectOptions) ([]meta.ShardGroupInfo, error) {
+	if !stmt.IsRawQuery || stmt.Limit == 0 || len(stmt.Dimensions) > 0 || len(stmt.Sources) != 1 {
+		return nil, nil
+	}
+	mm, ok := stmt.Sources[0].(*influxql.Measurement)
+	if !ok || mm.Regex != nil {
+		return nil, nil
+	}
+
+	groups, err := e.MetaClient.ShardGroupsByTimeRange(mm.Database, mm.RetentionPolicy, opt.MinTime, opt.MaxTime)
+	if err != nil {
+		return nil, err
+	} else if len(groups) < 2 {
+		return nil, nil
+	}
+
+	// Shard groups may overlap if the shard group duration was changed.
+	sort.Sort(meta.ShardGroupInfos(groups))
+	for i := 1; i < len(groups); i++ {
+		if groups[i].StartTime.Before(groups[i-1].EndTime) {
+			return nil, nil
+		}
+	}
+
+	if !stmt.TimeAscending() {
+		for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+			groups[i], groups[j] = groups[j], groups[i]
+		}
+	}
+	return groups, nil
 }
 
 func (e *StatementExecutor) executeShowContinuousQueriesStatement(stmt *influxql.ShowContinuousQueriesStatement) (models.Rows, error) {
@@ -558,6 +1075,30 @@ func (e *StatementExecutor) executeShowContinuousQueriesStatement(stmt *influxql
 	return rows, nil
 }
 
+func (e *StatementExecutor) executeShowSchemasStatement(stmt *influxql.ShowSchemasStatement) (models.Rows, error) {
+	dis := e.MetaClient.Databases()
+
+	rows := []*models.Row{}
+	for _, di := range dis {
+		row := &models.Row{Columns: []string{"name", "tags", "fields", "strict"}, Name: di.Name}
+		for _, msi := range di.MeasurementSchemas {
+			names := make([]string, 0, len(msi.Fields))
+			for name := range msi.Fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			fields := make([]string, len(names))
+			for i, name := range names {
+				fields[i] = name + " " + msi.Fields[name].String()
+			}
+			row.Values = append(row.Values, []interface{}{msi.Name, strings.Join(msi.TagKeys, ", "), strings.Join(fields, ", "), msi.Strict})
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
 func (e *StatementExecutor) executeShowDatabasesStatement(q *influxql.ShowDatabasesStatement) (models.Rows, error) {
 	dis := e.MetaClient.Databases()
 
@@ -660,6 +1201,75 @@ func (e *StatementExecutor) executeShowShardsStatement(stmt *influxql.ShowShards
 	return rows, nil
 }
 
+// cardinalityEstimationColumn is the column of the database wide totals
+// returned by the cardinality statements without EXACT.
+const cardinalityEstimationColumn = "cardinality estimation"
+
+func (e *StatementExecutor) executeShowMeasurementCardinalityStatement(stmt *influxql.ShowMeasurementCardinalityStatement, database string) (models.Rows, error) {
+	if database == "" {
+		return nil, meta.ErrDatabaseNameRequired
+	}
+
+	n, err := e.TSDBStore.MeasurementCardinality(database, stmt.Sources, stmt.Condition)
+	if err != nil {
+		return nil, err
+	}
+
+	column := cardinalityEstimationColumn
+	if stmt.Exact {
+		column = "count"
+	}
+	return []*models.Row{{Columns: []string{column}, Values: [][]interface{}{{n}}}}, nil
+}
+
+func (e *StatementExecutor) executeShowSeriesCardinalityStatement(stmt *influxql.ShowSeriesCardinalityStatement, database string) (models.Rows, error) {
+	if database == "" {
+		return nil, meta.ErrDatabaseNameRequired
+	}
+
+	counts, err := e.TSDBStore.SeriesCardinality(database, stmt.Sources, stmt.Condition)
+	if err != nil {
+		return nil, err
+	}
+	return cardinalityRows(counts, stmt.Exact), nil
+}
+
+func (e *StatementExecutor) executeShowTagValuesCardinalityStatement(stmt *influxql.ShowTagValuesCardinalityStatement, database string) (models.Rows, error) {
+	if database == "" {
+		return nil, meta.ErrDatabaseNameRequired
+	}
+
+	counts, err := e.TSDBStore.TagValuesCardinality(database, stmt.Sources, stmt.TagKeys, stmt.Condition)
+	if err != nil {
+		return nil, err
+	}
+	return cardinalityRows(counts, stmt.Exact), nil
+}
+
+// cardinalityRows returns a row with the count of each measurement in counts
+// if exact is set, otherwise a single row with the total of all counts.
+func cardinalityRows(counts map[string]int64, exact bool) models.Rows {
+	if !exact {
+		var total int64
+		for _, n := range counts {
+			total += n
+		}
+		return []*models.Row{{Columns: []string{cardinalityEstimationColumn}, Values: [][]interface{}{{total}}}}
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([]*models.Row, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, &models.Row{Name: name, Columns: []string{"count"}, Values: [][]interface{}{{counts[name]}}})
+	}
+	return rows
+}
+
 func (e *StatementExecutor) executeShowShardGroupsStatement(stmt *influxql.ShowShardGroupsStatement) (models.Rows, error) {
 	dis := e.MetaClient.Databases()
 
@@ -738,19 +1348,50 @@ func (e *StatementExecutor) executeShowUsersStatement(q *influxql.ShowUsersState
 	return []*models.Row{row}, nil
 }
 
-func (e *StatementExecutor) writeInto(stmt *influxql.SelectStatement, row *models.Row) error {
-	if stmt.Target.Measurement.Database == "" {
-		return errNoDatabaseInTarget
+// intoWriter writes the rows of a SELECT ... INTO statement back into the
+// database through the points writer in batches of at most batchSize points.
+type intoWriter struct {
+	pointsWriter interface {
+		WritePointsInto(*IntoWriteRequest) error
+	}
+	interruptCh <-chan struct{}
+
+	database        string
+	retentionPolicy string
+	name            string // blank to use the name of each row (:MEASUREMENT)
+	batchSize       int
+
+	points []models.Point
+	writeN int64 // number of points written
+}
+
+func (e *StatementExecutor) newIntoWriter(target *influxql.Target, interruptCh <-chan struct{}) (*intoWriter, error) {
+	if target.Measurement.Database == "" {
+		return nil, errNoDatabaseInTarget
+	}
+
+	batchSize := e.IntoBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultIntoBatchSize
 	}
 
-	// It might seem a bit weird that this is where we do this, since we will have to
-	// convert rows back to points. The Executors (both aggregate and raw) are complex
-	// enough that changing them to write back to the DB is going to be clumsy
-	//
-	// it might seem weird to have the write be in the QueryExecutor, but the interweaving of
-	// limitedRowWriter and ExecuteAggregate/Raw makes it ridiculously hard to make sure that the
-	// results will be the same as when queried normally.
-	name := stmt.Target.Measurement.Name
+	return &intoWriter{
+		pointsWriter:    e.PointsWriter,
+		interruptCh:     interruptCh,
+		database:        target.Measurement.Database,
+		retentionPolicy: target.Measurement.RetentionPolicy,
+		name:            target.Measurement.Name,
+		batchSize:       batchSize,
+	}, nil
+}
+
+// WriteRow converts row to points and writes every full batch.
+//
+// It might seem a bit weird that this is where we do this, since we will have to
+// convert rows back to points. The Executors (both aggregate and raw) are complex
+// enough that changing them to write back to the DB is going to be clumsy.
+func (w *intoWriter) WriteRow(row *models.Row) error {
+	name := w.name
 	if name == "" {
 		name = row.Name
 	}
@@ -760,17 +1401,81 @@ func (e *StatementExecutor) writeInto(stmt *influxql.SelectStatement, row *model
 		return err
 	}
 
-	if err := e.PointsWriter.WritePointsInto(&IntoWriteRequest{
-		Database:        stmt.Target.Measurement.Database,
-		RetentionPolicy: stmt.Target.Measurement.RetentionPolicy,
-		Points:          points,
-	}); err != nil {
+	for len(points) > 0 {
+		n := w.batchSize - len(w.points)
+		if n > len(points) {
+			n = len(points)
+		}
+		w.points = append(w.points, points[:n]...)
+		points = points[n:]
+
+		if len(w.points) >= w.batchSize {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered points.
+func (w *intoWriter) Flush() error {
+	if len(w.points) == 0 {
+		return nil
+	}
+
+	if err := w.write(w.points); err != nil {
 		return err
 	}
+	w.writeN += int64(len(w.points))
 
+	// The points writer may hand the batch to subscribers so it is not reused.
+	w.points = nil
 	return nil
 }
 
+// write writes a batch of points. A write rejected because the cache of a
+// shard is full is retried with a backoff until the cache has room again so
+// a large backfill does not fail while the cache is being snapshotted.
+func (w *intoWriter) write(points []models.Point) error {
+	req := &IntoWriteRequest{
+		Database:        w.database,
+		RetentionPolicy: w.retentionPolicy,
+		Points:          points,
+	}
+
+	interval := intoRetryInterval
+	timeout := time.After(intoRetryTimeout)
+	for {
+		err := w.pointsWriter.WritePointsInto(req)
+		if !influxdb.IsCacheFullError(err) {
+			return err
+		}
+
+		select {
+		case <-w.interruptCh:
+			return influxql.ErrQueryInterrupted
+		case <-timeout:
+			return err
+		case <-time.After(interval):
+		}
+
+		if interval *= 2; interval > intoMaxRetryInterval {
+			interval = intoMaxRetryInterval
+		}
+	}
+}
+
+const (
+	// intoRetryInterval is the initial wait before retrying a write into a
+	// full cache. It doubles up to intoMaxRetryInterval.
+	intoRetryInterval    = 100 * time.Millisecond
+	intoMaxRetryInterval = 5 * time.Second
+
+	// intoRetryTimeout is how long a write into a full cache is retried.
+	intoRetryTimeout = time.Minute
+)
+
 var errNoDatabaseInTarget = errors.New("no database in target")
 
 // convertRowToPoints will convert a query result Row into Points that can be written back in.
@@ -878,6 +1583,15 @@ type TSDBStore interface {
 	DeleteSeries(database string, sources []influxql.Source, condition influxql.Expr) error
 	DeleteShard(id uint64) error
 	IteratorCreator(shards []meta.ShardInfo) (influxql.IteratorCreator, error)
+
+	ScheduleFullCompaction(shardID uint64) error
+	SetCompactionsEnabled(shardID uint64, enabled bool) error
+	MoveShard(shardID uint64, cold bool) error
+	ShardGenerations(ids []uint64) ([]uint64, bool)
+
+	MeasurementCardinality(database string, sources influxql.Sources, condition influxql.Expr) (int64, error)
+	SeriesCardinality(database string, sources influxql.Sources, condition influxql.Expr) (map[string]int64, error)
+	TagValuesCardinality(database string, sources influxql.Sources, tagKeys []string, condition influxql.Expr) (map[string]int64, error)
 }
 
 type LocalTSDBStore struct {
@@ -7,10 +7,12 @@ import (
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/cluster"
 	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/influxdb/models"
@@ -77,6 +79,241 @@ func TestQueryExecutor_ExecuteQuery_SelectStatement(t *testing.T) {
 	}
 }
 
+// Ensure query executor reuses the result of a repeated SELECT statement until
+// the shards it was read from change.
+func TestQueryExecutor_ExecuteQuery_SelectStatement_QueryCache(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.StatementExecutor.QueryCache = cluster.NewQueryCache(100)
+
+	e.MetaClient.ShardsByTimeRangeFn = func(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error) {
+		return []meta.ShardInfo{{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}}}, nil
+	}
+
+	var generation uint64
+	e.TSDBStore.ShardGenerationsFn = func(ids []uint64) ([]uint64, bool) {
+		if !reflect.DeepEqual(ids, []uint64{100}) {
+			t.Fatalf("unexpected shard ids: %v", ids)
+		}
+		return []uint64{generation}, true
+	}
+
+	var createN int
+	e.TSDBStore.ShardIteratorCreatorFn = func(id uint64) influxql.IteratorCreator {
+		var ic IteratorCreator
+		ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+			createN++
+			return &FloatIterator{Points: []influxql.FloatPoint{
+				{Name: "cpu", Time: int64(0 * time.Second), Aux: []interface{}{float64(100)}},
+				{Name: "cpu", Time: int64(1 * time.Second), Aux: []interface{}{float64(200)}},
+			}}, nil
+		}
+		ic.FieldDimensionsFn = func(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+			return map[string]struct{}{"value": struct{}{}}, nil, nil
+		}
+		ic.SeriesKeysFn = func(opt influxql.IteratorOptions) (influxql.SeriesList, error) {
+			return influxql.SeriesList{
+				{Name: "cpu", Aux: []influxql.DataType{influxql.Float}},
+			}, nil
+		}
+		return &ic
+	}
+
+	exp := []*influxql.Result{
+		{
+			StatementID: 0,
+			Series: []*models.Row{{
+				Name:    "cpu",
+				Columns: []string{"time", "value"},
+				Values: [][]interface{}{
+					{time.Unix(0, 0).UTC(), float64(100)},
+					{time.Unix(1, 0).UTC(), float64(200)},
+				},
+			}},
+		},
+	}
+
+	const query = `SELECT * FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:00Z'`
+	for i, tt := range []struct {
+		generation uint64
+		createN    int
+	}{
+		{generation: 0, createN: 1},
+		{generation: 0, createN: 1},
+		{generation: 1, createN: 2},
+		{generation: 1, createN: 2},
+	} {
+		generation = tt.generation
+		a := ReadAllResults(e.ExecuteQuery(query, "db0", 0))
+		if !reflect.DeepEqual(a, exp) {
+			t.Fatalf("%d. unexpected results: %s", i, spew.Sdump(a))
+		} else if createN != tt.createN {
+			t.Fatalf("%d. unexpected iterators created: %d", i, createN)
+		}
+
+		// Modifying the results must not change the cached result.
+		a[0].Series[0].Values[0][0] = int64(0)
+	}
+}
+
+// Ensure query executor does not cache the result of a SELECT statement that
+// reads a shard not held by the local store.
+func TestQueryExecutor_ExecuteQuery_SelectStatement_QueryCache_NonLocalShard(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.StatementExecutor.QueryCache = cluster.NewQueryCache(100)
+
+	e.MetaClient.ShardsByTimeRangeFn = func(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error) {
+		return []meta.ShardInfo{{ID: 100, Owners: []meta.ShardOwner{{NodeID: 1}}}}, nil
+	}
+	e.TSDBStore.ShardGenerationsFn = func(ids []uint64) ([]uint64, bool) {
+		return nil, false
+	}
+
+	var createN int
+	e.TSDBStore.ShardIteratorCreatorFn = func(id uint64) influxql.IteratorCreator {
+		var ic IteratorCreator
+		ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+			createN++
+			return &FloatIterator{Points: []influxql.FloatPoint{
+				{Name: "cpu", Time: int64(0 * time.Second), Aux: []interface{}{float64(100)}},
+			}}, nil
+		}
+		ic.FieldDimensionsFn = func(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+			return map[string]struct{}{"value": struct{}{}}, nil, nil
+		}
+		ic.SeriesKeysFn = func(opt influxql.IteratorOptions) (influxql.SeriesList, error) {
+			return influxql.SeriesList{
+				{Name: "cpu", Aux: []influxql.DataType{influxql.Float}},
+			}, nil
+		}
+		return &ic
+	}
+
+	const query = `SELECT * FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:00Z'`
+	for i := 1; i <= 2; i++ {
+		if a := ReadAllResults(e.ExecuteQuery(query, "db0", 0)); len(a) != 1 || a[0].Err != nil {
+			t.Fatalf("%d. unexpected results: %s", i, spew.Sdump(a))
+		} else if createN != i {
+			t.Fatalf("%d. unexpected iterators created: %d", i, createN)
+		}
+	}
+}
+
+// Ensure query executor writes the points of a SELECT ... INTO statement in
+// batches and retries a batch rejected by a full cache.
+func TestQueryExecutor_ExecuteQuery_SelectInto(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.StatementExecutor.IntoBatchSize = 2
+
+	e.MetaClient.ShardsByTimeRangeFn = func(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error) {
+		return []meta.ShardInfo{{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}}}, nil
+	}
+	e.TSDBStore.ShardIteratorCreatorFn = func(id uint64) influxql.IteratorCreator {
+		var ic IteratorCreator
+		ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+			return &FloatIterator{Points: []influxql.FloatPoint{
+				{Name: "cpu", Time: int64(0 * time.Second), Aux: []interface{}{float64(100)}},
+				{Name: "cpu", Time: int64(1 * time.Second), Aux: []interface{}{float64(200)}},
+				{Name: "cpu", Time: int64(2 * time.Second), Aux: []interface{}{float64(300)}},
+				{Name: "cpu", Time: int64(3 * time.Second), Aux: []interface{}{float64(400)}},
+				{Name: "cpu", Time: int64(4 * time.Second), Aux: []interface{}{float64(500)}},
+			}}, nil
+		}
+		ic.FieldDimensionsFn = func(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+			return map[string]struct{}{"value": struct{}{}}, nil, nil
+		}
+		ic.SeriesKeysFn = func(opt influxql.IteratorOptions) (influxql.SeriesList, error) {
+			return influxql.SeriesList{
+				{Name: "cpu", Aux: []influxql.DataType{influxql.Float}},
+			}, nil
+		}
+		return &ic
+	}
+
+	// The second write is rejected once because the cache is full.
+	var batches []int
+	var writeN int
+	e.PointsWriter.WritePointsIntoFn = func(req *cluster.IntoWriteRequest) error {
+		if writeN++; writeN == 2 {
+			return influxdb.ErrCacheMemoryExceeded
+		} else if req.Database != "db0" || req.RetentionPolicy != "rp0" {
+			t.Fatalf("unexpected target: %s.%s", req.Database, req.RetentionPolicy)
+		}
+		for _, p := range req.Points {
+			if p.Name() != "cpu_copy" {
+				t.Fatalf("unexpected measurement: %s", p.Name())
+			}
+		}
+		batches = append(batches, len(req.Points))
+		return nil
+	}
+
+	if a := ReadAllResults(e.ExecuteQuery(`SELECT * INTO db0.rp0.cpu_copy FROM cpu`, "db0", 0)); !reflect.DeepEqual(a, []*influxql.Result{
+		{
+			StatementID: 0,
+			Series: []*models.Row{{
+				Name:    "result",
+				Columns: []string{"time", "written"},
+				Values:  [][]interface{}{{time.Unix(0, 0).UTC(), int64(5)}},
+			}},
+		},
+	}) {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	} else if !reflect.DeepEqual(batches, []int{2, 2, 1}) {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+}
+
+// Ensure query executor removes a dropped shard from the meta store and disk.
+func TestQueryExecutor_ExecuteQuery_DropShard(t *testing.T) {
+	e := DefaultQueryExecutor()
+
+	var dropped, deleted uint64
+	e.MetaClient.DropShardFn = func(id uint64) error {
+		dropped = id
+		return nil
+	}
+	e.TSDBStore.DeleteShardFn = func(id uint64) error {
+		if dropped != id {
+			t.Fatal("expected shard to be dropped from the meta store first")
+		}
+		deleted = id
+		return nil
+	}
+
+	if a := ReadAllResults(e.ExecuteQuery(`DROP SHARD 1`, "db0", 0)); !reflect.DeepEqual(a, []*influxql.Result{{StatementID: 0}}) {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	} else if dropped != 1 || deleted != 1 {
+		t.Fatalf("unexpected shard ids: dropped=%d deleted=%d", dropped, deleted)
+	}
+}
+
+// Ensure query executor returns the estimated and exact series cardinality.
+func TestQueryExecutor_ExecuteQuery_ShowSeriesCardinality(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.TSDBStore.SeriesCardinalityFn = func(database string, sources influxql.Sources, condition influxql.Expr) (map[string]int64, error) {
+		if database != "db0" {
+			t.Fatalf("unexpected database: %s", database)
+		}
+		return map[string]int64{"mem": 2, "cpu": 3}, nil
+	}
+
+	if a := ReadAllResults(e.ExecuteQuery(`SHOW SERIES CARDINALITY; SHOW SERIES EXACT CARDINALITY`, "db0", 0)); !reflect.DeepEqual(a, []*influxql.Result{
+		{
+			StatementID: 0,
+			Series:      []*models.Row{{Columns: []string{"cardinality estimation"}, Values: [][]interface{}{{int64(5)}}}},
+		},
+		{
+			StatementID: 1,
+			Series: []*models.Row{
+				{Name: "cpu", Columns: []string{"count"}, Values: [][]interface{}{{int64(3)}}},
+				{Name: "mem", Columns: []string{"count"}, Values: [][]interface{}{{int64(2)}}},
+			},
+		},
+	}) {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
 // Ensure query executor can enforce a maximum series selection count.
 func TestQueryExecutor_ExecuteQuery_MaxSelectSeriesN(t *testing.T) {
 	e := DefaultQueryExecutor()
@@ -113,13 +350,68 @@ func TestQueryExecutor_ExecuteQuery_MaxSelectSeriesN(t *testing.T) {
 	if a := ReadAllResults(e.ExecuteQuery(`SELECT count(value) FROM cpu`, "db0", 0)); !reflect.DeepEqual(a, []*influxql.Result{
 		{
 			StatementID: 0,
-			Err:         errors.New("max select series count exceeded: 4 series"),
+			Err:         errors.New("max-select-series limit exceeded: (4/3)"),
 		},
 	}) {
 		t.Fatalf("unexpected results: %s", spew.Sdump(a))
 	}
 }
 
+// Ensure query executor can enforce a maximum memory per select statement
+// and across all select statements.
+func TestQueryExecutor_ExecuteQuery_MaxSelectMemory(t *testing.T) {
+	e := DefaultQueryExecutor()
+
+	e.MetaClient.ShardsByTimeRangeFn = func(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error) {
+		return []meta.ShardInfo{
+			{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}},
+		}, nil
+	}
+
+	// The percentile reducer holds all 1000 points of the series.
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		points := make([]influxql.FloatPoint, 1000)
+		for i := range points {
+			points[i] = influxql.FloatPoint{Name: "cpu", Time: int64(i), Value: float64(i)}
+		}
+		return &FloatIterator{Points: points}, nil
+	}
+	ic.FieldDimensionsFn = func(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+		return map[string]struct{}{"value": struct{}{}}, nil, nil
+	}
+	ic.SeriesKeysFn = func(opt influxql.IteratorOptions) (influxql.SeriesList, error) {
+		return influxql.SeriesList{{Name: "cpu", Aux: []influxql.DataType{influxql.Float}}}, nil
+	}
+	e.TSDBStore.ShardIteratorCreatorFn = func(id uint64) influxql.IteratorCreator { return &ic }
+
+	for _, tt := range []struct {
+		maxSelect, maxTotal int64
+		err                 string
+	}{
+		{maxSelect: 10000, err: "max-select-memory limit exceeded"},
+		{maxTotal: 10000, err: "max-total-select-memory limit exceeded"},
+		{},
+	} {
+		e.StatementExecutor.MaxSelectMemory = tt.maxSelect
+		e.StatementExecutor.SelectMemoryBudget = influxql.NewMemoryBudget("max-total-select-memory", tt.maxTotal)
+
+		a := ReadAllResults(e.ExecuteQuery(`SELECT percentile(value, 50) FROM cpu`, "db0", 0))
+		if len(a) != 1 {
+			t.Fatalf("unexpected results: %s", spew.Sdump(a))
+		} else if tt.err == "" && a[0].Err != nil {
+			t.Fatalf("unexpected error: %s", a[0].Err)
+		} else if tt.err != "" && (a[0].Err == nil || !strings.HasPrefix(a[0].Err.Error(), tt.err)) {
+			t.Fatalf("unexpected error: %v", a[0].Err)
+		}
+
+		// All memory is returned once the statement is done.
+		if n := e.StatementExecutor.SelectMemoryBudget.Used(); n != 0 {
+			t.Fatalf("unexpected memory still in use: %d", n)
+		}
+	}
+}
+
 // Ensure query executor can enforce a maximum bucket selection count.
 func TestQueryExecutor_ExecuteQuery_MaxSelectBucketsN(t *testing.T) {
 	e := DefaultQueryExecutor()
@@ -152,11 +444,113 @@ func TestQueryExecutor_ExecuteQuery_MaxSelectBucketsN(t *testing.T) {
 	if a := ReadAllResults(e.ExecuteQuery(`SELECT count(value) FROM cpu WHERE time >= '2000-01-01T00:00:05Z' AND time < '2000-01-01T00:00:35Z' GROUP BY time(10s)`, "db0", 0)); !reflect.DeepEqual(a, []*influxql.Result{
 		{
 			StatementID: 0,
-			Err:         errors.New("max select bucket count exceeded: 4 buckets"),
+			Err:         errors.New("max-select-buckets limit exceeded: (4/3)"),
 		},
 	}) {
 		t.Fatalf("unexpected results: %s", spew.Sdump(a))
 	}
+
+	// Offsetting the intervals shifts the buckets so the same range spans 3 buckets.
+	if a := ReadAllResults(e.ExecuteQuery(`SELECT count(value) FROM cpu WHERE time >= '2000-01-01T00:00:05Z' AND time < '2000-01-01T00:00:35Z' GROUP BY time(10s, 5s)`, "db0", 0)); len(a) != 1 || a[0].Err != nil {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure query executor can explain the cost of a query across shards.
+func TestQueryExecutor_ExecuteQuery_Explain(t *testing.T) {
+	e := DefaultQueryExecutor()
+
+	// The meta client should return two shards on the local node.
+	e.MetaClient.ShardsByTimeRangeFn = func(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error) {
+		return []meta.ShardInfo{
+			{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}},
+			{ID: 101, Owners: []meta.ShardOwner{{NodeID: 0}}},
+		}, nil
+	}
+
+	var ic IteratorCreator
+	ic.FieldDimensionsFn = func(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+		return map[string]struct{}{"value": struct{}{}}, nil, nil
+	}
+	ic.IteratorCostFn = func(opt influxql.IteratorOptions) (influxql.IteratorCost, error) {
+		if expr := opt.Expr.String(); expr != `count(value)` {
+			t.Fatalf("unexpected expr: %s", expr)
+		}
+		return influxql.IteratorCost{NumShards: 1, NumSeries: 2, CachedValues: 3, NumFiles: 1, BlocksRead: 4, BlockSize: 500}, nil
+	}
+	e.TSDBStore.ShardIteratorCreatorFn = func(id uint64) influxql.IteratorCreator { return &ic }
+
+	// Verify the costs of both shards are combined.
+	if a := ReadAllResults(e.ExecuteQuery(`EXPLAIN SELECT count(value) FROM cpu`, "db0", 0)); !reflect.DeepEqual(a, []*influxql.Result{
+		{
+			StatementID: 0,
+			Series: []*models.Row{{
+				Columns: []string{"QUERY PLAN"},
+				Values: [][]interface{}{
+					{"EXPRESSION: count(value)"},
+					{"NUMBER OF SHARDS: 2"},
+					{"NUMBER OF SERIES: 4"},
+					{"CACHED VALUES: 6"},
+					{"NUMBER OF FILES: 2"},
+					{"NUMBER OF BLOCKS: 8"},
+					{"SIZE OF BLOCKS: 1000"},
+				},
+			}},
+		},
+	}) {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure query executor can analyze a query by executing it.
+func TestQueryExecutor_ExecuteQuery_ExplainAnalyze(t *testing.T) {
+	e := DefaultQueryExecutor()
+
+	e.MetaClient.ShardsByTimeRangeFn = func(sources influxql.Sources, tmin, tmax time.Time) (a []meta.ShardInfo, err error) {
+		return []meta.ShardInfo{
+			{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}},
+		}, nil
+	}
+
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{
+			Points: []influxql.FloatPoint{
+				{Name: "cpu", Time: int64(0 * time.Second), Value: 1},
+				{Name: "cpu", Time: int64(1 * time.Second), Value: 2},
+			},
+			stats: influxql.IteratorStats{SeriesN: 1, PointN: 2, BlockN: 1},
+		}, nil
+	}
+	ic.FieldDimensionsFn = func(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+		return map[string]struct{}{"value": struct{}{}}, nil, nil
+	}
+	ic.SeriesKeysFn = func(opt influxql.IteratorOptions) (influxql.SeriesList, error) {
+		return influxql.SeriesList{
+			{Name: "cpu", Aux: []influxql.DataType{influxql.Float}},
+		}, nil
+	}
+	e.TSDBStore.ShardIteratorCreatorFn = func(id uint64) influxql.IteratorCreator { return &ic }
+
+	a := ReadAllResults(e.ExecuteQuery(`EXPLAIN ANALYZE SELECT count(value) FROM cpu`, "db0", 0))
+	if len(a) != 1 || a[0].Err != nil || len(a[0].Series) != 1 {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+
+	// Timings vary so only check the statistics.
+	var lines []string
+	for _, v := range a[0].Series[0].Values[4:] {
+		lines = append(lines, v[0].(string))
+	}
+	if exp := []string{
+		"SERIES SCANNED: 1",
+		"POINTS SCANNED: 2",
+		"BLOCKS DECODED: 1",
+		"SERIES RETURNED: 1",
+		"POINTS RETURNED: 1",
+	}; !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("unexpected statistics: %s", spew.Sdump(lines))
+	}
 }
 
 // QueryExecutor is a test wrapper for cluster.QueryExecutor.
@@ -165,6 +559,7 @@ type QueryExecutor struct {
 
 	MetaClient        MetaClient
 	TSDBStore         TSDBStore
+	PointsWriter      PointsWriter
 	StatementExecutor *cluster.StatementExecutor
 	LogOutput         bytes.Buffer
 }
@@ -176,8 +571,9 @@ func NewQueryExecutor() *QueryExecutor {
 		QueryExecutor: influxql.NewQueryExecutor(),
 	}
 	e.StatementExecutor = &cluster.StatementExecutor{
-		MetaClient: &e.MetaClient,
-		TSDBStore:  &e.TSDBStore,
+		MetaClient:   &e.MetaClient,
+		TSDBStore:    &e.TSDBStore,
+		PointsWriter: &e.PointsWriter,
 	}
 	e.QueryExecutor.StatementExecutor = e.StatementExecutor
 
@@ -202,6 +598,16 @@ func (e *QueryExecutor) ExecuteQuery(query, database string, chunkSize int) <-ch
 	return e.QueryExecutor.ExecuteQuery(MustParseQuery(query), database, chunkSize, false, make(chan struct{}))
 }
 
+// PointsWriter is a mockable implementation of the points writer of
+// cluster.StatementExecutor.
+type PointsWriter struct {
+	WritePointsIntoFn func(req *cluster.IntoWriteRequest) error
+}
+
+func (w *PointsWriter) WritePointsInto(req *cluster.IntoWriteRequest) error {
+	return w.WritePointsIntoFn(req)
+}
+
 // TSDBStore is a mockable implementation of cluster.TSDBStore.
 type TSDBStore struct {
 	CreateShardFn  func(database, policy string, shardID uint64) error
@@ -213,6 +619,15 @@ type TSDBStore struct {
 	DeleteShardFn           func(id uint64) error
 	DeleteSeriesFn          func(database string, sources []influxql.Source, condition influxql.Expr) error
 	ShardIteratorCreatorFn  func(id uint64) influxql.IteratorCreator
+
+	ScheduleFullCompactionFn func(shardID uint64) error
+	SetCompactionsEnabledFn  func(shardID uint64, enabled bool) error
+	MoveShardFn              func(shardID uint64, cold bool) error
+	ShardGenerationsFn       func(ids []uint64) ([]uint64, bool)
+
+	MeasurementCardinalityFn func(database string, sources influxql.Sources, condition influxql.Expr) (int64, error)
+	SeriesCardinalityFn      func(database string, sources influxql.Sources, condition influxql.Expr) (map[string]int64, error)
+	TagValuesCardinalityFn   func(database string, sources influxql.Sources, tagKeys []string, condition influxql.Expr) (map[string]int64, error)
 }
 
 func (s *TSDBStore) CreateShard(database, policy string, shardID uint64) error {
@@ -271,6 +686,37 @@ func (s *TSDBStore) ShardIteratorCreator(id uint64) influxql.IteratorCreator {
 	return s.ShardIteratorCreatorFn(id)
 }
 
+func (s *TSDBStore) ScheduleFullCompaction(shardID uint64) error {
+	return s.ScheduleFullCompactionFn(shardID)
+}
+
+func (s *TSDBStore) SetCompactionsEnabled(shardID uint64, enabled bool) error {
+	return s.SetCompactionsEnabledFn(shardID, enabled)
+}
+
+func (s *TSDBStore) MoveShard(shardID uint64, cold bool) error {
+	return s.MoveShardFn(shardID, cold)
+}
+
+func (s *TSDBStore) ShardGenerations(ids []uint64) ([]uint64, bool) {
+	if s.ShardGenerationsFn == nil {
+		return make([]uint64, len(ids)), true
+	}
+	return s.ShardGenerationsFn(ids)
+}
+
+func (s *TSDBStore) MeasurementCardinality(database string, sources influxql.Sources, condition influxql.Expr) (int64, error) {
+	return s.MeasurementCardinalityFn(database, sources, condition)
+}
+
+func (s *TSDBStore) SeriesCardinality(database string, sources influxql.Sources, condition influxql.Expr) (map[string]int64, error) {
+	return s.SeriesCardinalityFn(database, sources, condition)
+}
+
+func (s *TSDBStore) TagValuesCardinality(database string, sources influxql.Sources, tagKeys []string, condition influxql.Expr) (map[string]int64, error) {
+	return s.TagValuesCardinalityFn(database, sources, tagKeys, condition)
+}
+
 // MustParseQuery parses s into a query. Panic on error.
 func MustParseQuery(s string) *influxql.Query {
 	q, err := influxql.ParseQuery(s)
@@ -295,6 +741,7 @@ type IteratorCreator struct {
 	FieldDimensionsFn func(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error)
 	SeriesKeysFn      func(opt influxql.IteratorOptions) (influxql.SeriesList, error)
 	ExpandSourcesFn   func(sources influxql.Sources) (influxql.Sources, error)
+	IteratorCostFn    func(opt influxql.IteratorOptions) (influxql.IteratorCost, error)
 }
 
 func (ic *IteratorCreator) CreateIterator(opt influxql.IteratorOptions) (influxql.Iterator, error) {
@@ -313,6 +760,10 @@ func (ic *IteratorCreator) ExpandSources(sources influxql.Sources) (influxql.Sou
 	return ic.ExpandSourcesFn(sources)
 }
 
+func (ic *IteratorCreator) IteratorCost(opt influxql.IteratorOptions) (influxql.IteratorCost, error) {
+	return ic.IteratorCostFn(opt)
+}
+
 // FloatIterator is a represents an iterator that reads from a slice.
 type FloatIterator struct {
 	Points []influxql.FloatPoint
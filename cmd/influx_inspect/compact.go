@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/influxdata/influxdb/cmd/influxd/run"
+	"github.com/influxdata/influxdb/tsdb"
+	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
+)
+
+type compactOpts struct {
+	path        string
+	configPath  string
+	maxFileSize uint64
+	dryRun      bool
+	keyFile     string
+}
+
+// cmdCompact fully compacts the TSM files in a single shard directory.  The
+// shard must not be open by a running server.
+func cmdCompact(opts *compactOpts) {
+	start := time.Now()
+
+	if err := compactShard(opts); err != nil {
+		fmt.Printf("compact: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Completed in %s\n", time.Since(start))
+}
+
+func compactShard(opts *compactOpts) error {
+	if fi, err := os.Stat(opts.path); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s is not a shard directory", opts.path)
+	}
+
+	// The files are written with the same settings the server would use.
+	config := run.NewConfig()
+	if opts.configPath != "" {
+		if err := config.FromTomlFile(opts.configPath); err != nil {
+			return err
+		}
+	}
+	if opts.maxFileSize != 0 {
+		config.Data.CompactMaxFileSize = opts.maxFileSize
+	}
+	if opts.keyFile != "" {
+		config.Data.EncryptionKeyFile = opts.keyFile
+	}
+
+	if config.Data.CompactMaxFileSize > tsdb.DefaultCompactMaxFileSize {
+		return fmt.Errorf("max file size must not exceed %d", tsdb.DefaultCompactMaxFileSize)
+	}
+
+	// Remove any temp files left behind by an interrupted compaction.  The
+	// files they were compacting from are still in place.
+	tmpFiles, err := filepath.Glob(filepath.Join(opts.path, fmt.Sprintf("*.%s", tsm1.CompactionTempExtension)))
+	if err != nil {
+		return err
+	}
+	for _, f := range tmpFiles {
+		fmt.Printf("Removing incomplete compaction file %s\n", f)
+		if opts.dryRun {
+			continue
+		}
+		if err := os.Remove(f); err != nil {
+			return err
+		}
+	}
+
+	aead, err := loadCipher(config.Data.EncryptionKeyFile)
+	if err != nil {
+		return err
+	}
+
+	fs := tsm1.NewFileStore(opts.path)
+	fs.ReaderOptions.Cipher = aead
+	fs.SetLogOutput(ioutil.Discard)
+	if err := fs.Open(); err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	var files []string
+	var size int64
+	var hasTombstones bool
+	for _, st := range fs.Stats() {
+		files = append(files, st.Path)
+		size += int64(st.Size)
+		hasTombstones = hasTombstones || st.HasTombstone
+	}
+
+	fmt.Printf("Shard %s: %d TSM files, %d bytes\n", opts.path, len(files), size)
+	if len(files) == 0 || (len(files) == 1 && !hasTombstones) {
+		fmt.Println("Nothing to compact")
+		return nil
+	}
+
+	if opts.dryRun {
+		for _, f := range files {
+			fmt.Printf("Would compact %s\n", f)
+		}
+		return nil
+	}
+
+	c := tsm1.NewCompactor(opts.path, fs, config.Data)
+	c.Cipher = aead
+
+	newFiles, err := c.CompactFull(files)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.Replace(files, newFiles); err != nil {
+		return err
+	}
+
+	size = 0
+	for _, st := range fs.Stats() {
+		size += int64(st.Size)
+	}
+	fmt.Printf("Compacted into %d TSM files, %d bytes\n", len(fs.Stats()), size)
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"crypto/cipher"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,7 +12,7 @@ import (
 	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
 )
 
-func cmdExport(path string) {
+func cmdExport(path string, aead cipher.AEAD) {
 	dataPath := filepath.Join(path, "data")
 
 	// No need to do this in a loop
@@ -47,7 +48,7 @@ func cmdExport(path string) {
 			os.Exit(1)
 		}
 
-		reader, err := tsm1.NewTSMReader(file)
+		reader, err := tsm1.NewTSMReaderWithOptions(file, tsm1.TSMReaderOptions{Cipher: aead})
 
 		if err != nil {
 			fmt.Printf("%v", err)
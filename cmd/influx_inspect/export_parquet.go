@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/parquet"
+	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
+)
+
+// cmdExportParquet writes the data of every shard under path to Parquet files
+// in out.  Each measurement of each shard is written to
+// <out>/<database>/<retention policy>/<measurement>/<shard id>.parquet, so
+// files are partitioned by measurement and by the time range of the shard.
+func cmdExportParquet(path, out string, aead cipher.AEAD) {
+	dataPath := filepath.Join(path, "data")
+	ext := fmt.Sprintf(".%s", tsm1.TSMFileExtension)
+
+	// Group the TSM files by shard directory
+	shards := make(map[string][]string)
+	err := filepath.Walk(dataPath, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filepath.Ext(path) == ext {
+			dir := filepath.Dir(path)
+			shards[dir] = append(shards[dir], path)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	dirs := make([]string, 0, len(shards))
+	for dir := range shards {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		relPath, _ := filepath.Rel(dataPath, dir)
+		parts := strings.Split(relPath, string(os.PathSeparator))
+		if len(parts) != 3 {
+			fmt.Printf("Skipping %s: not a shard directory\n", dir)
+			continue
+		}
+		database, rp, shardID := parts[0], parts[1], parts[2]
+
+		fmt.Println("Exporting shard", shardID, "of", database+"."+rp)
+		if err := exportShardParquet(shards[dir], filepath.Join(out, database, rp), shardID, aead); err != nil {
+			fmt.Printf("%s: %v\n", dir, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// parquetMeasurement holds the columns and series of a measurement being
+// exported.
+type parquetMeasurement struct {
+	tagKeys    map[string]struct{}
+	fieldTypes map[string]byte
+
+	// series maps each series key to its tags and the fields it has.
+	series map[string]*parquetSeries
+}
+
+// parquetSeries holds the tags and field names of a series being exported.
+type parquetSeries struct {
+	tags   models.Tags
+	fields []string
+}
+
+// exportShardParquet writes one Parquet file per measurement in the TSM files
+// of a shard.  The columns are found from the indexes of the files, then the
+// values are read and written one series at a time.
+func exportShardParquet(files []string, dir, shardID string, aead cipher.AEAD) error {
+	sort.Strings(files)
+
+	var readers []*tsm1.TSMReader
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+
+	// Files are read oldest first so that the type of a field is the one
+	// first written, and newer values replace older ones when deduplicating.
+	measurements := make(map[string]*parquetMeasurement)
+	for _, f := range files {
+		file, err := os.Open(f)
+		if err != nil {
+			return err
+		}
+		r, err := tsm1.NewTSMReaderWithOptions(file, tsm1.TSMReaderOptions{Cipher: aead})
+		if err != nil {
+			file.Close()
+			return err
+		}
+		readers = append(readers, r)
+
+		for i := 0; i < r.KeyCount(); i++ {
+			key, typ := r.KeyAt(i)
+			j := strings.Index(key, "#!~#")
+			if j == -1 {
+				continue
+			}
+			seriesKey, field := key[:j], key[j+4:]
+
+			name, tags, err := models.ParseKey(seriesKey)
+			if err != nil {
+				return err
+			}
+			m := measurements[name]
+			if m == nil {
+				m = &parquetMeasurement{
+					tagKeys:    make(map[string]struct{}),
+					fieldTypes: make(map[string]byte),
+					series:     make(map[string]*parquetSeries),
+				}
+				measurements[name] = m
+			}
+
+			s := m.series[seriesKey]
+			if s == nil {
+				s = &parquetSeries{tags: tags}
+				m.series[seriesKey] = s
+				for k := range tags {
+					m.tagKeys[k] = struct{}{}
+				}
+			}
+			if !containsString(s.fields, field) {
+				s.fields = append(s.fields, field)
+			}
+			if _, ok := m.fieldTypes[field]; !ok {
+				m.fieldTypes[field] = typ
+			}
+		}
+	}
+
+	names := make([]string, 0, len(measurements))
+	for name := range measurements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := writeMeasurementParquet(filepath.Join(dir, name, shardID+".parquet"), measurements[name], readers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMeasurementParquet writes a row for each time of each series of m with
+// a column for time, each tag key and each field.
+func writeMeasurementParquet(path string, m *parquetMeasurement, readers []*tsm1.TSMReader) error {
+	tags := make([]string, 0, len(m.tagKeys))
+	for k := range m.tagKeys {
+		tags = append(tags, k)
+	}
+	sort.Strings(tags)
+
+	fields := make([]string, 0, len(m.fieldTypes))
+	for f := range m.fieldTypes {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	// Fields may share a name with a tag, or with the time column, in which
+	// case they are suffixed until the name is unique.
+	used := map[string]struct{}{"time": struct{}{}}
+	for _, k := range tags {
+		used[k] = struct{}{}
+	}
+	for _, f := range fields {
+		used[f] = struct{}{}
+	}
+
+	columns := []parquet.Column{{Name: "time", Type: parquet.Int64, TimestampNanos: true}}
+	for _, k := range tags {
+		columns = append(columns, parquet.Column{Name: k, Type: parquet.ByteArray, Optional: true, UTF8: true})
+	}
+	for _, f := range fields {
+		c := parquet.Column{Name: f, Optional: true}
+		if _, ok := m.tagKeys[f]; ok || f == "time" {
+			for c.Name = f + "_field"; ; c.Name += "_field" {
+				if _, ok := used[c.Name]; !ok {
+					break
+				}
+			}
+			used[c.Name] = struct{}{}
+		}
+
+		switch m.fieldTypes[f] {
+		case tsm1.BlockFloat64:
+			c.Type = parquet.Double
+		case tsm1.BlockInteger:
+			c.Type = parquet.Int64
+		case tsm1.BlockBoolean:
+			c.Type = parquet.Boolean
+		case tsm1.BlockString:
+			c.Type, c.UTF8 = parquet.ByteArray, true
+		}
+		columns = append(columns, c)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w := parquet.NewWriter(f, columns)
+	row := make([]interface{}, len(columns))
+	for _, k := range keys {
+		s := m.series[k]
+
+		// Only the values of the current series are held in memory.
+		values := make([]tsm1.Values, len(fields))
+		for i, field := range fields {
+			if !containsString(s.fields, field) {
+				continue
+			}
+			key := k + "#!~#" + field
+			for _, r := range readers {
+				// Skip values that conflict with the type of the column.
+				if typ, err := r.Type(key); err != nil || typ != m.fieldTypes[field] {
+					continue
+				}
+				v, err := r.ReadAll(key)
+				if err != nil {
+					return err
+				}
+				values[i] = append(values[i], v...)
+			}
+			values[i] = values[i].Deduplicate()
+		}
+
+		// Merge the values of each field by time.
+		pos := make([]int, len(fields))
+		for {
+			min := int64(0)
+			found := false
+			for i := range fields {
+				if pos[i] < len(values[i]) {
+					if t := values[i][pos[i]].UnixNano(); !found || t < min {
+						min, found = t, true
+					}
+				}
+			}
+			if !found {
+				break
+			}
+
+			row[0] = min
+			for i, tk := range tags {
+				if v, ok := s.tags[tk]; ok {
+					row[1+i] = v
+				} else {
+					row[1+i] = nil
+				}
+			}
+			for i := range fields {
+				row[1+len(tags)+i] = nil
+				if pos[i] < len(values[i]) && values[i][pos[i]].UnixNano() == min {
+					row[1+len(tags)+i] = values[i][pos[i]].Value()
+					pos[i]++
+				}
+			}
+
+			if err := w.WriteRow(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// containsString returns true if a contains s.
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
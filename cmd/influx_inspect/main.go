@@ -1,11 +1,14 @@
 package main
 
 import (
+	"crypto/cipher"
 	"flag"
 	"fmt"
 	"os"
 
+	"github.com/influxdata/influxdb/tsdb"
 	_ "github.com/influxdata/influxdb/tsdb/engine"
+	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
 )
 
 func usage() {
@@ -17,7 +20,9 @@ Displays detailed information about InfluxDB data files.
 	println(`Commands:
   info - displays series meta-data for all shards.  Default location [$HOME/.influxdb]
   dumptsm - dumps low-level details about tsm1 files.
-  dumptsmdev - dumps low-level details about tsm1dev files.`)
+  dumptsmdev - dumps low-level details about tsm1dev files.
+  verify - reports corrupt blocks in the TSM files of all shards.
+  compact - fully compacts the TSM files of a shard while the server is stopped.`)
 	println()
 }
 
@@ -114,12 +119,13 @@ func main() {
 		opts.dumpIndex = opts.dumpIndex || dumpAll || opts.filterKey != ""
 		cmdDumpTsm1dev(opts)
 	case "verify":
-		var path string
+		var path, keyFile string
 		fs := flag.NewFlagSet("verify", flag.ExitOnError)
 		fs.StringVar(&path, "dir", os.Getenv("HOME")+"/.influxdb", "Root storage path. [$HOME/.influxdb]")
+		fs.StringVar(&keyFile, "encryption-key-file", "", "File holding the hex encoded key of encrypted TSM files")
 
 		fs.Usage = func() {
-			println("Usage: influx_inspect verify [options]\n\n   verifies the the checksum of shards")
+			println("Usage: influx_inspect verify [options]\n\n   verifies the checksum and encoding of every block in every TSM file and\n   reports corrupt blocks by series key and time range")
 			println()
 			println("Options:")
 			fs.PrintDefaults()
@@ -129,14 +135,50 @@ func main() {
 			fmt.Printf("%v", err)
 			os.Exit(1)
 		}
-		cmdVerify(path)
+		cmdVerify(path, mustLoadCipher(keyFile))
 	case "export":
-		var path string
+		var path, format, out, keyFile string
 		fs := flag.NewFlagSet("export", flag.ExitOnError)
 		fs.StringVar(&path, "dir", os.Getenv("HOME")+"/.influxdb", "Root storage path. [$HOME/.influxdb]")
+		fs.StringVar(&format, "format", "line", "Output format: line or parquet")
+		fs.StringVar(&out, "out", "export", "Output directory for the parquet format")
+		fs.StringVar(&keyFile, "encryption-key-file", "", "File holding the hex encoded key of encrypted TSM files")
+
+		fs.Usage = func() {
+			println("Usage: influx_inspect export [options]\n\n   exports TSM files into InfluxDB line protocol format, or into Parquet")
+			println("   files partitioned by database, retention policy, measurement and shard")
+			println()
+			println("Options:")
+			fs.PrintDefaults()
+		}
+
+		if err := fs.Parse(flag.Args()[1:]); err != nil {
+			fmt.Printf("%v", err)
+			os.Exit(1)
+		}
+
+		switch format {
+		case "line":
+			cmdExport(path, mustLoadCipher(keyFile))
+		case "parquet":
+			cmdExportParquet(path, out, mustLoadCipher(keyFile))
+		default:
+			fmt.Printf("unknown format %q\n\n", format)
+			fs.Usage()
+			os.Exit(1)
+		}
+	case "compact":
+		opts := &compactOpts{}
+		fs := flag.NewFlagSet("compact", flag.ExitOnError)
+		fs.StringVar(&opts.configPath, "config", "", "influxd config file whose [data] settings the compacted files are written with")
+		fs.Uint64Var(&opts.maxFileSize, "max-file-size", 0, "Maximum size of each compacted TSM file in bytes (default from the config)")
+		fs.BoolVar(&opts.dryRun, "dry-run", false, "Display the files that would be compacted without compacting them")
+		fs.StringVar(&opts.keyFile, "encryption-key-file", "", "File holding the hex encoded key of encrypted TSM files (default from the config)")
 
 		fs.Usage = func() {
-			println("Usage: influx_inspect export [options]\n\n   exports TSM files into InfluxDB line protocol format")
+			println("Usage: influx_inspect compact [options] <shard path>\n\n   fully compacts the TSM files of a shard.  The server must be stopped")
+			println("   or the shard otherwise not in use.  Incomplete files left behind by")
+			println("   an interrupted compaction are removed.")
 			println()
 			println("Options:")
 			fs.PrintDefaults()
@@ -146,9 +188,37 @@ func main() {
 			fmt.Printf("%v", err)
 			os.Exit(1)
 		}
-		cmdExport(path)
+
+		if len(fs.Args()) == 0 || fs.Args()[0] == "" {
+			fmt.Printf("Shard path not specified\n\n")
+			fs.Usage()
+			os.Exit(1)
+		}
+		opts.path = fs.Args()[0]
+		cmdCompact(opts)
 	default:
 		flag.Usage()
 		os.Exit(1)
 	}
 }
+
+// loadCipher returns the cipher for the hex encoded key in the file at path,
+// or nil if path is empty.
+func loadCipher(path string) (cipher.AEAD, error) {
+	c := tsdb.Config{EncryptionKeyFile: path}
+	key, err := c.EncryptionKey()
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return tsm1.NewCipher(key)
+}
+
+// mustLoadCipher is like loadCipher but exits if the key cannot be loaded.
+func mustLoadCipher(path string) cipher.AEAD {
+	aead, err := loadCipher(path)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	return aead
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
+	"crypto/cipher"
 	"fmt"
-	"hash/crc32"
 	"os"
 	"path/filepath"
 	"text/tabwriter"
@@ -11,7 +11,7 @@ import (
 	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
 )
 
-func cmdVerify(path string) {
+func cmdVerify(path string, aead cipher.AEAD) {
 	start := time.Now()
 	dataPath := filepath.Join(path, "data")
 
@@ -38,7 +38,7 @@ func cmdVerify(path string) {
 
 	tw := tabwriter.NewWriter(os.Stdout, 16, 8, 0, '\t', 0)
 
-	// Verify the checksums of every block in every file
+	// Verify the checksum and encoding of every block in every file
 	for _, f := range files {
 		file, err := os.OpenFile(f, os.O_RDONLY, 0600)
 		if err != nil {
@@ -46,33 +46,35 @@ func cmdVerify(path string) {
 			os.Exit(1)
 		}
 
-		reader, err := tsm1.NewTSMReader(file)
+		reader, err := tsm1.NewTSMReaderWithOptions(file, tsm1.TSMReaderOptions{Cipher: aead})
 		if err != nil {
 			fmt.Printf("%v", err)
 			os.Exit(1)
 		}
 
-		blockItr := reader.BlockIterator()
-		brokenFileBlocks := 0
-		count := 0
-		for blockItr.Next() {
-			totalBlocks++
-			key, _, _, checksum, buf, err := blockItr.Read()
-			if err != nil {
-				brokenBlocks++
-				fmt.Fprintf(tw, "%s: could not get checksum for key %v block %d due to error: %q\n", f, key, count, err)
-			} else if expected := crc32.ChecksumIEEE(buf); checksum != expected {
-				brokenBlocks++
-				fmt.Fprintf(tw, "%s: got %d but expected %d for key %v, block %d\n", f, checksum, expected, key, count)
-			}
-			count++
+		for i := 0; i < reader.KeyCount(); i++ {
+			_, entries := reader.Key(i)
+			totalBlocks += len(entries)
 		}
-		if brokenFileBlocks == 0 {
+
+		errs := reader.VerifyBlocks()
+		for _, e := range errs {
+			fmt.Fprintf(tw, "%s: corrupt block for key %v, %s - %s: %v\n", f, e.Key,
+				time.Unix(0, e.MinTime).UTC().Format(time.RFC3339Nano),
+				time.Unix(0, e.MaxTime).UTC().Format(time.RFC3339Nano),
+				e.Err)
+		}
+		if len(errs) == 0 {
 			fmt.Fprintf(tw, "%s: healthy\n", f)
 		}
+		brokenBlocks += len(errs)
 		reader.Close()
 	}
 
 	fmt.Fprintf(tw, "Broken Blocks: %d / %d, in %vs\n", brokenBlocks, totalBlocks, time.Since(start).Seconds())
 	tw.Flush()
+
+	if brokenBlocks > 0 {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,129 @@
+package exportshard
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/influxdata/influxdb/services/snapshotter"
+)
+
+// Suffix is a suffix added to the export while it's in-process.
+const Suffix = ".pending"
+
+// Command represents the program execution for "influxd export-shard".
+type Command struct {
+	// The logger passed to the ticker during execution.
+	Logger *log.Logger
+
+	// Standard input/output, overridden for testing.
+	Stderr io.Writer
+	Stdout io.Writer
+
+	host    string
+	shardID uint64
+	path    string
+}
+
+// NewCommand returns a new instance of Command with default settings.
+func NewCommand() *Command {
+	return &Command{
+		Stderr: os.Stderr,
+		Stdout: os.Stdout,
+	}
+}
+
+// Run executes the program.
+func (cmd *Command) Run(args ...string) error {
+	// Set up logger.
+	cmd.Logger = log.New(cmd.Stderr, "", log.LstdFlags)
+
+	// Parse command line arguments.
+	if err := cmd.parseFlags(args); err != nil {
+		return err
+	}
+
+	if err := cmd.export(); err != nil {
+		cmd.Logger.Printf("export failed: %v", err)
+		return err
+	}
+
+	cmd.Logger.Println("export complete")
+	return nil
+}
+
+// parseFlags parses and validates the command line arguments.
+func (cmd *Command) parseFlags(args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.StringVar(&cmd.host, "host", "localhost:8088", "")
+	fs.Uint64Var(&cmd.shardID, "shard", 0, "")
+	fs.SetOutput(cmd.Stderr)
+	fs.Usage = cmd.printUsage
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cmd.shardID == 0 {
+		return errors.New("-shard is required")
+	}
+
+	// Ensure that only one arg is specified.
+	if fs.NArg() == 0 {
+		return errors.New("export file path required")
+	} else if fs.NArg() != 1 {
+		return errors.New("only one export file path allowed")
+	}
+	cmd.path = fs.Arg(0)
+
+	return nil
+}
+
+// export downloads the shard export to a temporary file and renames it to
+// the export path once complete.
+func (cmd *Command) export() error {
+	tmppath := cmd.path + Suffix
+	f, err := os.Create(tmppath)
+	if err != nil {
+		return fmt.Errorf("open temp file: %s", err)
+	}
+
+	h, err := snapshotter.NewClient(cmd.host).ExportShard(cmd.shardID, f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmppath)
+		return err
+	}
+
+	if err := os.Rename(tmppath, cmd.path); err != nil {
+		return fmt.Errorf("rename: %s", err)
+	}
+
+	cmd.Logger.Printf("exported shard %d of db=%s rp=%s for %s to %s", h.ShardID, h.Database, h.RetentionPolicy,
+		h.StartTime.Format("2006-01-02T15:04:05Z07:00"), cmd.path)
+	return nil
+}
+
+// printUsage prints the usage message to STDERR.
+func (cmd *Command) printUsage() {
+	fmt.Fprintf(cmd.Stdout, `usage: influxd export-shard [flags] PATH
+
+Export-shard downloads the data files of a shard, together with the database,
+retention policy and time range of the shard, to the file at PATH. The file
+can be imported into another instance with "influxd import-shard".
+
+Options:
+  -host <host:port>
+        The host to connect to. Defaults to localhost:8088.
+  -shard <id>
+        The id of the shard to export.
+
+`)
+}
@@ -36,6 +36,8 @@ The commands are:
 
     backup               downloads a snapshot of a data node and saves it to disk
     config               display the default configuration
+    export-shard         downloads the data files of a shard for import elsewhere
+    import-shard         adds an exported shard to a running node
     restore              uses a snapshot of a data node to rebuild a cluster
     run                  run node with existing configuration
     version              displays the InfluxDB version
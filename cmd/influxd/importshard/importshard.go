@@ -0,0 +1,106 @@
+package importshard
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/influxdata/influxdb/services/snapshotter"
+)
+
+// Command represents the program execution for "influxd import-shard".
+type Command struct {
+	// The logger passed to the ticker during execution.
+	Logger *log.Logger
+
+	// Standard input/output, overridden for testing.
+	Stderr io.Writer
+	Stdout io.Writer
+
+	host      string
+	database  string
+	retention string
+	path      string
+}
+
+// NewCommand returns a new instance of Command with default settings.
+func NewCommand() *Command {
+	return &Command{
+		Stderr: os.Stderr,
+		Stdout: os.Stdout,
+	}
+}
+
+// Run executes the program.
+func (cmd *Command) Run(args ...string) error {
+	// Set up logger.
+	cmd.Logger = log.New(cmd.Stderr, "", log.LstdFlags)
+
+	// Parse command line arguments.
+	if err := cmd.parseFlags(args); err != nil {
+		return err
+	}
+
+	f, err := os.Open(cmd.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	id, err := snapshotter.NewClient(cmd.host).ImportShard(cmd.database, cmd.retention, f)
+	if err != nil {
+		cmd.Logger.Printf("import failed: %v", err)
+		return err
+	}
+
+	cmd.Logger.Printf("imported %s into shard %d", cmd.path, id)
+	return nil
+}
+
+// parseFlags parses and validates the command line arguments.
+func (cmd *Command) parseFlags(args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.StringVar(&cmd.host, "host", "localhost:8088", "")
+	fs.StringVar(&cmd.database, "database", "", "")
+	fs.StringVar(&cmd.retention, "retention", "", "")
+	fs.SetOutput(cmd.Stderr)
+	fs.Usage = cmd.printUsage
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Ensure that only one arg is specified.
+	if fs.NArg() == 0 {
+		return errors.New("export file path required")
+	} else if fs.NArg() != 1 {
+		return errors.New("only one export file path allowed")
+	}
+	cmd.path = fs.Arg(0)
+
+	return nil
+}
+
+// printUsage prints the usage message to STDERR.
+func (cmd *Command) printUsage() {
+	fmt.Fprintf(cmd.Stdout, `usage: influxd import-shard [flags] PATH
+
+Import-shard sends a file written by "influxd export-shard" to a running
+instance, which adds its data to the shard covering the exported time range,
+creating the shard group if needed. The retention policy must use a shard
+group duration that covers the exported time range.
+
+Options:
+  -host <host:port>
+        The host to connect to. Defaults to localhost:8088.
+  -database <name>
+        Optional. The existing database to import into. Defaults to the
+        database the shard was exported from.
+  -retention <name>
+        Optional. The existing retention policy to import into. Defaults to
+        the retention policy the shard was exported from.
+
+`)
+}
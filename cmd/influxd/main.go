@@ -13,7 +13,9 @@ import (
 	"time"
 
 	"github.com/influxdata/influxdb/cmd/influxd/backup"
+	"github.com/influxdata/influxdb/cmd/influxd/exportshard"
 	"github.com/influxdata/influxdb/cmd/influxd/help"
+	"github.com/influxdata/influxdb/cmd/influxd/importshard"
 	"github.com/influxdata/influxdb/cmd/influxd/restore"
 	"github.com/influxdata/influxdb/cmd/influxd/run"
 )
@@ -122,6 +124,14 @@ func (m *Main) Run(args ...string) error {
 		if err := name.Run(args...); err != nil {
 			return fmt.Errorf("restore: %s", err)
 		}
+	case "export-shard":
+		if err := exportshard.NewCommand().Run(args...); err != nil {
+			return fmt.Errorf("export-shard: %s", err)
+		}
+	case "import-shard":
+		if err := importshard.NewCommand().Run(args...); err != nil {
+			return fmt.Errorf("import-shard: %s", err)
+		}
 	case "config":
 		if err := run.NewPrintConfigCommand().Run(args...); err != nil {
 			return fmt.Errorf("config: %s", err)
@@ -1,6 +1,7 @@
 package run_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"github.com/influxdata/influxdb/cmd/influxd/backup"
+	"github.com/influxdata/influxdb/cmd/influxd/exportshard"
+	"github.com/influxdata/influxdb/cmd/influxd/importshard"
 	"github.com/influxdata/influxdb/cmd/influxd/restore"
 	"github.com/influxdata/influxdb/cmd/influxd/run"
 )
@@ -98,6 +101,70 @@ func TestServer_BackupAndRestore(t *testing.T) {
 	}
 }
 
+func TestServer_ExportAndImportShard(t *testing.T) {
+	config := NewConfig()
+	config.Data.Engine = "tsm1"
+	config.Data.Dir, _ = ioutil.TempDir("", "data_export")
+	config.Meta.Dir, _ = ioutil.TempDir("", "meta_export")
+	config.BindAddress = freePort()
+
+	exportDir, _ := ioutil.TempDir("", "export")
+	defer os.RemoveAll(exportDir)
+	exportPath := filepath.Join(exportDir, "shard.export")
+
+	// set the cache snapshot size low so that a single point will cause TSM file creation
+	config.Data.CacheSnapshotMemorySize = 1
+
+	s := OpenServer(config)
+	defer s.Close()
+
+	for _, db := range []string{"mydb", "otherdb"} {
+		if err := s.CreateDatabaseAndRetentionPolicy(db, newRetentionPolicyInfo("forever", 1, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.Write("mydb", "forever", "myseries,host=A value=23 1000000", nil); err != nil {
+		t.Fatalf("failed to write: %s", err)
+	}
+
+	// wait for the snapshot to write
+	time.Sleep(time.Second)
+
+	groups := s.MetaClient.Database("mydb").RetentionPolicy("forever").ShardGroups
+	if len(groups) != 1 || len(groups[0].Shards) != 1 {
+		t.Fatalf("unexpected shard groups: %v", groups)
+	}
+	shardID := groups[0].Shards[0].ID
+
+	hostAddress, _ := run.DefaultHost(run.DefaultHostname, config.BindAddress)
+	if err := exportshard.NewCommand().Run("-host", hostAddress, "-shard", fmt.Sprint(shardID), exportPath); err != nil {
+		t.Fatalf("error exporting: %s", err)
+	}
+
+	// import twice into the other database to make sure the generations of
+	// the imported files don't collide with existing files.
+	for i := 0; i < 2; i++ {
+		if err := importshard.NewCommand().Run("-host", hostAddress, "-database", "otherdb", "-retention", "forever", exportPath); err != nil {
+			t.Fatalf("error importing: %s", err)
+		}
+	}
+
+	expected := `{"results":[{"series":[{"name":"myseries","columns":["time","host","value"],"values":[["1970-01-01T00:00:00.001Z","A",23]]}]}]}`
+	res, err := s.Query(`select * from "otherdb"."forever"."myseries"`)
+	if err != nil {
+		t.Fatalf("error querying: %s", err.Error())
+	}
+	if res != expected {
+		t.Fatalf("query results wrong:\n\texp: %s\n\tgot: %s", expected, res)
+	}
+
+	// importing into a missing database fails.
+	if err := importshard.NewCommand().Run("-host", hostAddress, "-database", "nodb", exportPath); err == nil {
+		t.Fatal("expected error importing into a missing database")
+	}
+}
+
 func freePort() string {
 	l, _ := net.Listen("tcp", "")
 	defer l.Close()
@@ -16,6 +16,7 @@ import (
 	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/monitor"
+	"github.com/influxdata/influxdb/monitor/diagnostics"
 	"github.com/influxdata/influxdb/services/copier"
 	"github.com/influxdata/influxdb/services/meta"
 	"github.com/influxdata/influxdb/services/snapshotter"
@@ -92,6 +93,9 @@ type Server struct {
 	// logOutput is the writer to which all services should be configured to
 	// write logs to after appension.
 	logOutput io.Writer
+
+	// slowQueryLog is the file finished slow queries are logged to.
+	slowQueryLog *os.File
 }
 
 // NewServer returns a new instance of Server built from a config.
@@ -175,17 +179,26 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 	// Initialize query executor.
 	s.QueryExecutor = influxql.NewQueryExecutor()
 	s.QueryExecutor.StatementExecutor = &cluster.StatementExecutor{
-		MetaClient:        s.MetaClient,
-		TSDBStore:         cluster.LocalTSDBStore{Store: s.TSDBStore},
-		Monitor:           s.Monitor,
-		PointsWriter:      s.PointsWriter,
-		MaxSelectPointN:   c.Cluster.MaxSelectPointN,
-		MaxSelectSeriesN:  c.Cluster.MaxSelectSeriesN,
-		MaxSelectBucketsN: c.Cluster.MaxSelectBucketsN,
+		MetaClient:                s.MetaClient,
+		TSDBStore:                 cluster.LocalTSDBStore{Store: s.TSDBStore},
+		Monitor:                   s.Monitor,
+		PointsWriter:              s.PointsWriter,
+		MaxConcurrentShardQueries: c.Cluster.MaxConcurrentShardQueries,
+		MaxSelectPointN:           c.Cluster.MaxSelectPointN,
+		MaxSelectSeriesN:          c.Cluster.MaxSelectSeriesN,
+		MaxSelectBucketsN:         c.Cluster.MaxSelectBucketsN,
+		MaxSelectMemory:           c.Cluster.MaxSelectMemory,
+		SelectMemoryBudget:        influxql.NewMemoryBudget("max-total-select-memory", c.Cluster.MaxTotalSelectMemory),
+		IntoBatchSize:             c.Cluster.IntoBatchSize,
+		QueryCache:                cluster.NewQueryCache(c.Cluster.QueryCacheSize),
 	}
 	s.QueryExecutor.QueryTimeout = time.Duration(c.Cluster.QueryTimeout)
 	s.QueryExecutor.LogQueriesAfter = time.Duration(c.Cluster.LogQueriesAfter)
+	s.QueryExecutor.SlowQueryDuration = time.Duration(c.Cluster.SlowQueryDuration)
 	s.QueryExecutor.MaxConcurrentQueries = c.Cluster.MaxConcurrentQueries
+	s.QueryExecutor.MaxQueuedQueries = c.Cluster.MaxQueuedQueries
+	s.QueryExecutor.QueueTimeout = time.Duration(c.Cluster.QueueTimeout)
+	s.QueryExecutor.MaxConcurrentStatements = c.Cluster.MaxConcurrentStatements
 	if c.Data.QueryLogEnabled {
 		s.QueryExecutor.Logger = log.New(os.Stderr, "[query] ", log.LstdFlags)
 	}
@@ -297,6 +310,16 @@ func (s *Server) Open() error {
 	s.CopierService.SetLogOutput(w)
 	s.Monitor.SetLogOutput(w)
 
+	// Open the slow query log.
+	if path := s.config.Cluster.SlowQueryLogPath; path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return fmt.Errorf("open slow query log: %s", err)
+		}
+		s.slowQueryLog = f
+		s.QueryExecutor.SetSlowQueryLogOutput(f)
+	}
+
 	// Open TSDB store.
 	if err := s.TSDBStore.Open(); err != nil {
 		return fmt.Errorf("open tsdb store: %s", err)
@@ -316,6 +339,7 @@ func (s *Server) Open() error {
 	if err := s.Monitor.Open(); err != nil {
 		return fmt.Errorf("open monitor: %v", err)
 	}
+	s.Monitor.RegisterDiagnosticsClient("compactions", diagnostics.ClientFunc(s.TSDBStore.CompactionDiagnostics))
 
 	for _, service := range s.Services {
 		if err := service.Open(); err != nil {
@@ -358,6 +382,10 @@ func (s *Server) Close() error {
 		s.QueryExecutor.Close()
 	}
 
+	if s.slowQueryLog != nil {
+		s.slowQueryLog.Close()
+	}
+
 	// Close the TSDBStore, no more reads or writes at this point
 	if s.TSDBStore != nil {
 		s.TSDBStore.Close()
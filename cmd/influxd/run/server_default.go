@@ -48,6 +48,7 @@ func (s *Server) appendHTTPDService(c httpd.Config) {
 	srv.Handler.WriteAuthorizer = meta.NewWriteAuthorizer(s.MetaClient)
 	srv.Handler.QueryExecutor = s.QueryExecutor
 	srv.Handler.PointsWriter = s.PointsWriter
+	srv.Handler.TSDBStore = s.TSDBStore
 	srv.Handler.Version = s.buildInfo.Version
 
 	// If a ContinuousQuerier service has been started, attach it.
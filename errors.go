@@ -13,6 +13,30 @@ var (
 	// ErrFieldTypeConflict is returned when a new field already exists with a different type.
 	ErrFieldTypeConflict = errors.New("field type conflict")
 
+	// ErrMaxSeriesPerDatabaseExceeded is returned when a point would create a
+	// series beyond the configured maximum for its database.
+	ErrMaxSeriesPerDatabaseExceeded = errors.New("max series per database exceeded")
+
+	// ErrMaxValuesPerTagExceeded is returned when a point would add a value
+	// to a tag key beyond the configured maximum for its measurement.
+	ErrMaxValuesPerTagExceeded = errors.New("max values per tag exceeded")
+
+	// ErrMaxSeriesPerShardExceeded is returned when a point would add a
+	// series to a shard beyond the configured maximum.
+	ErrMaxSeriesPerShardExceeded = errors.New("max series per shard exceeded")
+
+	// ErrMaxShardDiskSizeExceeded is returned when writing to a shard whose
+	// data files have reached the configured maximum size.
+	ErrMaxShardDiskSizeExceeded = errors.New("max shard disk size exceeded")
+
+	// ErrSchemaViolation is returned when a point does not conform to the
+	// declared schema of its measurement.
+	ErrSchemaViolation = errors.New("schema violation")
+
+	// ErrCacheMemoryExceeded is returned when a write would grow the cache of
+	// a shard beyond its configured maximum size.
+	ErrCacheMemoryExceeded = errors.New("cache maximum memory size exceeded")
+
 	// ErrUpgradeEngine will be returned when it's determined that
 	// the server has encountered shards that are not in the `tsm1`
 	// format.
@@ -46,9 +70,38 @@ func IsClientError(err error) bool {
 		return true
 	}
 
+	if strings.Contains(err.Error(), ErrMaxSeriesPerDatabaseExceeded.Error()) {
+		return true
+	}
+
+	if strings.Contains(err.Error(), ErrMaxValuesPerTagExceeded.Error()) {
+		return true
+	}
+
+	if strings.Contains(err.Error(), ErrMaxSeriesPerShardExceeded.Error()) {
+		return true
+	}
+
+	if strings.Contains(err.Error(), ErrMaxShardDiskSizeExceeded.Error()) {
+		return true
+	}
+
+	if strings.Contains(err.Error(), ErrSchemaViolation.Error()) {
+		return true
+	}
+
 	return false
 }
 
+// IsCacheFullError indicates whether a write failed because the cache of a
+// shard was full. The write may succeed once the cache has been snapshotted.
+func IsCacheFullError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return err == ErrCacheMemoryExceeded || strings.Contains(err.Error(), ErrCacheMemoryExceeded.Error())
+}
+
 const upgradeMessage = `*******************************************************************
                  UNSUPPORTED SHARD FORMAT DETECTED
 
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gogo/protobuf/proto"
 	internal "github.com/influxdata/influxdb/influxql/internal"
@@ -32,6 +33,12 @@ const (
 	Time = 5
 	// Duration means the data type is a duration of time.
 	Duration = 6
+	// Tag means the data type is a tag.  It is only used to qualify a
+	// reference in a query.
+	Tag = 7
+	// AnyField means the data type is any field.  It is only used to qualify
+	// a reference in a query.
+	AnyField = 8
 )
 
 // InspectDataType returns the data type of a given value.
@@ -76,6 +83,10 @@ func (d DataType) String() string {
 		return "time"
 	case Duration:
 		return "duration"
+	case Tag:
+		return "tag"
+	case AnyField:
+		return "field"
 	}
 	return "unknown"
 }
@@ -89,56 +100,69 @@ type Node interface {
 func (*Query) node()     {}
 func (Statements) node() {}
 
-func (*AlterRetentionPolicyStatement) node()  {}
-func (*CreateContinuousQueryStatement) node() {}
-func (*CreateDatabaseStatement) node()        {}
-func (*CreateRetentionPolicyStatement) node() {}
-func (*CreateSubscriptionStatement) node()    {}
-func (*CreateUserStatement) node()            {}
-func (*Distinct) node()                       {}
-func (*DeleteSeriesStatement) node()          {}
-func (*DeleteStatement) node()                {}
-func (*DropContinuousQueryStatement) node()   {}
-func (*DropDatabaseStatement) node()          {}
-func (*DropMeasurementStatement) node()       {}
-func (*DropRetentionPolicyStatement) node()   {}
-func (*DropSeriesStatement) node()            {}
-func (*DropShardStatement) node()             {}
-func (*DropSubscriptionStatement) node()      {}
-func (*DropUserStatement) node()              {}
-func (*GrantStatement) node()                 {}
-func (*GrantAdminStatement) node()            {}
-func (*KillQueryStatement) node()             {}
-func (*RevokeStatement) node()                {}
-func (*RevokeAdminStatement) node()           {}
-func (*SelectStatement) node()                {}
-func (*SetPasswordUserStatement) node()       {}
-func (*ShowContinuousQueriesStatement) node() {}
-func (*ShowGrantsForUserStatement) node()     {}
-func (*ShowDatabasesStatement) node()         {}
-func (*ShowFieldKeysStatement) node()         {}
-func (*ShowRetentionPoliciesStatement) node() {}
-func (*ShowMeasurementsStatement) node()      {}
-func (*ShowQueriesStatement) node()           {}
-func (*ShowSeriesStatement) node()            {}
-func (*ShowShardGroupsStatement) node()       {}
-func (*ShowShardsStatement) node()            {}
-func (*ShowStatsStatement) node()             {}
-func (*ShowSubscriptionsStatement) node()     {}
-func (*ShowDiagnosticsStatement) node()       {}
-func (*ShowTagKeysStatement) node()           {}
-func (*ShowTagValuesStatement) node()         {}
-func (*ShowUsersStatement) node()             {}
+func (*AlterRetentionPolicyStatement) node()       {}
+func (*AlterShardTierStatement) node()             {}
+func (*CompactShardStatement) node()               {}
+func (*PauseCompactionsStatement) node()           {}
+func (*CreateContinuousQueryStatement) node()      {}
+func (*CreateDatabaseStatement) node()             {}
+func (*CreateRetentionPolicyStatement) node()      {}
+func (*CreateSchemaStatement) node()               {}
+func (*CreateSubscriptionStatement) node()         {}
+func (*CreateUserStatement) node()                 {}
+func (*Distinct) node()                            {}
+func (*DeleteSeriesStatement) node()               {}
+func (*DeleteStatement) node()                     {}
+func (*DropContinuousQueryStatement) node()        {}
+func (*DropDatabaseStatement) node()               {}
+func (*DropMeasurementStatement) node()            {}
+func (*DropRetentionPolicyStatement) node()        {}
+func (*DropSchemaStatement) node()                 {}
+func (*DropSeriesStatement) node()                 {}
+func (*DropShardStatement) node()                  {}
+func (*DropSubscriptionStatement) node()           {}
+func (*DropUserStatement) node()                   {}
+func (*ExplainStatement) node()                    {}
+func (*GrantStatement) node()                      {}
+func (*GrantAdminStatement) node()                 {}
+func (*KillQueryStatement) node()                  {}
+func (*RevokeStatement) node()                     {}
+func (*RevokeAdminStatement) node()                {}
+func (*SelectStatement) node()                     {}
+func (*SetNowStatement) node()                     {}
+func (*SetPasswordUserStatement) node()            {}
+func (*ShowContinuousQueriesStatement) node()      {}
+func (*ShowGrantsForUserStatement) node()          {}
+func (*ShowDatabasesStatement) node()              {}
+func (*ShowFieldKeysStatement) node()              {}
+func (*ShowRetentionPoliciesStatement) node()      {}
+func (*ShowSchemasStatement) node()                {}
+func (*ShowMeasurementsStatement) node()           {}
+func (*ShowMeasurementCardinalityStatement) node() {}
+func (*ShowQueriesStatement) node()                {}
+func (*ShowSeriesStatement) node()                 {}
+func (*ShowSeriesCardinalityStatement) node()      {}
+func (*ShowShardGroupsStatement) node()            {}
+func (*ShowShardsStatement) node()                 {}
+func (*ShowStatsStatement) node()                  {}
+func (*ShowSubscriptionsStatement) node()          {}
+func (*ShowDiagnosticsStatement) node()            {}
+func (*ShowTagKeysStatement) node()                {}
+func (*ShowTagValuesStatement) node()              {}
+func (*ShowTagValuesCardinalityStatement) node()   {}
+func (*ShowUsersStatement) node()                  {}
 
 func (*BinaryExpr) node()      {}
 func (*BooleanLiteral) node()  {}
 func (*Call) node()            {}
+func (*CaseExpr) node()        {}
 func (*Dimension) node()       {}
 func (Dimensions) node()       {}
 func (*DurationLiteral) node() {}
 func (*IntegerLiteral) node()  {}
 func (*Field) node()           {}
 func (Fields) node()           {}
+func (*Join) node()            {}
 func (*Measurement) node()     {}
 func (Measurements) node()     {}
 func (*nilLiteral) node()      {}
@@ -148,6 +172,7 @@ func (*RegexLiteral) node()    {}
 func (*SortField) node()       {}
 func (SortFields) node()       {}
 func (Sources) node()          {}
+func (*SubQuery) node()        {}
 func (*StringLiteral) node()   {}
 func (*Target) node()          {}
 func (*TimeLiteral) node()     {}
@@ -204,45 +229,56 @@ type ExecutionPrivilege struct {
 // ExecutionPrivileges is a list of privileges required to execute a statement.
 type ExecutionPrivileges []ExecutionPrivilege
 
-func (*AlterRetentionPolicyStatement) stmt()  {}
-func (*CreateContinuousQueryStatement) stmt() {}
-func (*CreateDatabaseStatement) stmt()        {}
-func (*CreateRetentionPolicyStatement) stmt() {}
-func (*CreateSubscriptionStatement) stmt()    {}
-func (*CreateUserStatement) stmt()            {}
-func (*DeleteSeriesStatement) stmt()          {}
-func (*DeleteStatement) stmt()                {}
-func (*DropContinuousQueryStatement) stmt()   {}
-func (*DropDatabaseStatement) stmt()          {}
-func (*DropMeasurementStatement) stmt()       {}
-func (*DropRetentionPolicyStatement) stmt()   {}
-func (*DropSeriesStatement) stmt()            {}
-func (*DropSubscriptionStatement) stmt()      {}
-func (*DropUserStatement) stmt()              {}
-func (*GrantStatement) stmt()                 {}
-func (*GrantAdminStatement) stmt()            {}
-func (*KillQueryStatement) stmt()             {}
-func (*ShowContinuousQueriesStatement) stmt() {}
-func (*ShowGrantsForUserStatement) stmt()     {}
-func (*ShowDatabasesStatement) stmt()         {}
-func (*ShowFieldKeysStatement) stmt()         {}
-func (*ShowMeasurementsStatement) stmt()      {}
-func (*ShowQueriesStatement) stmt()           {}
-func (*ShowRetentionPoliciesStatement) stmt() {}
-func (*ShowSeriesStatement) stmt()            {}
-func (*ShowShardGroupsStatement) stmt()       {}
-func (*ShowShardsStatement) stmt()            {}
-func (*ShowStatsStatement) stmt()             {}
-func (*DropShardStatement) stmt()             {}
-func (*ShowSubscriptionsStatement) stmt()     {}
-func (*ShowDiagnosticsStatement) stmt()       {}
-func (*ShowTagKeysStatement) stmt()           {}
-func (*ShowTagValuesStatement) stmt()         {}
-func (*ShowUsersStatement) stmt()             {}
-func (*RevokeStatement) stmt()                {}
-func (*RevokeAdminStatement) stmt()           {}
-func (*SelectStatement) stmt()                {}
-func (*SetPasswordUserStatement) stmt()       {}
+func (*AlterRetentionPolicyStatement) stmt()       {}
+func (*AlterShardTierStatement) stmt()             {}
+func (*CompactShardStatement) stmt()               {}
+func (*PauseCompactionsStatement) stmt()           {}
+func (*CreateContinuousQueryStatement) stmt()      {}
+func (*CreateDatabaseStatement) stmt()             {}
+func (*CreateRetentionPolicyStatement) stmt()      {}
+func (*CreateSchemaStatement) stmt()               {}
+func (*CreateSubscriptionStatement) stmt()         {}
+func (*CreateUserStatement) stmt()                 {}
+func (*DeleteSeriesStatement) stmt()               {}
+func (*DeleteStatement) stmt()                     {}
+func (*DropContinuousQueryStatement) stmt()        {}
+func (*DropDatabaseStatement) stmt()               {}
+func (*DropMeasurementStatement) stmt()            {}
+func (*DropRetentionPolicyStatement) stmt()        {}
+func (*DropSchemaStatement) stmt()                 {}
+func (*DropSeriesStatement) stmt()                 {}
+func (*DropSubscriptionStatement) stmt()           {}
+func (*DropUserStatement) stmt()                   {}
+func (*ExplainStatement) stmt()                    {}
+func (*GrantStatement) stmt()                      {}
+func (*GrantAdminStatement) stmt()                 {}
+func (*KillQueryStatement) stmt()                  {}
+func (*ShowContinuousQueriesStatement) stmt()      {}
+func (*ShowGrantsForUserStatement) stmt()          {}
+func (*ShowDatabasesStatement) stmt()              {}
+func (*ShowFieldKeysStatement) stmt()              {}
+func (*ShowMeasurementsStatement) stmt()           {}
+func (*ShowMeasurementCardinalityStatement) stmt() {}
+func (*ShowQueriesStatement) stmt()                {}
+func (*ShowRetentionPoliciesStatement) stmt()      {}
+func (*ShowSchemasStatement) stmt()                {}
+func (*ShowSeriesStatement) stmt()                 {}
+func (*ShowSeriesCardinalityStatement) stmt()      {}
+func (*ShowShardGroupsStatement) stmt()            {}
+func (*ShowShardsStatement) stmt()                 {}
+func (*ShowStatsStatement) stmt()                  {}
+func (*DropShardStatement) stmt()                  {}
+func (*ShowSubscriptionsStatement) stmt()          {}
+func (*ShowDiagnosticsStatement) stmt()            {}
+func (*ShowTagKeysStatement) stmt()                {}
+func (*ShowTagValuesStatement) stmt()              {}
+func (*ShowTagValuesCardinalityStatement) stmt()   {}
+func (*ShowUsersStatement) stmt()                  {}
+func (*RevokeStatement) stmt()                     {}
+func (*RevokeAdminStatement) stmt()                {}
+func (*SelectStatement) stmt()                     {}
+func (*SetNowStatement) stmt()                     {}
+func (*SetPasswordUserStatement) stmt()            {}
 
 // Expr represents an expression that can be evaluated to a value.
 type Expr interface {
@@ -253,6 +289,7 @@ type Expr interface {
 func (*BinaryExpr) expr()      {}
 func (*BooleanLiteral) expr()  {}
 func (*Call) expr()            {}
+func (*CaseExpr) expr()        {}
 func (*Distinct) expr()        {}
 func (*DurationLiteral) expr() {}
 func (*IntegerLiteral) expr()  {}
@@ -286,7 +323,9 @@ type Source interface {
 	source()
 }
 
+func (*Join) source()        {}
 func (*Measurement) source() {}
+func (*SubQuery) source()    {}
 
 // Sources represents a list of sources.
 type Sources []Source
@@ -655,6 +694,31 @@ func (s *GrantAdminStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
 }
 
+// ExplainStatement represents a command for explaining how a SELECT statement
+// will be executed. If Analyze is set, the statement is also executed and the
+// actual cost of each stage is reported.
+type ExplainStatement struct {
+	Statement *SelectStatement
+	Analyze   bool
+}
+
+// String returns a string representation of the explain statement.
+func (s *ExplainStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("EXPLAIN ")
+	if s.Analyze {
+		_, _ = buf.WriteString("ANALYZE ")
+	}
+	_, _ = buf.WriteString(s.Statement.String())
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privileges required to execute the
+// underlying SELECT statement.
+func (s *ExplainStatement) RequiredPrivileges() ExecutionPrivileges {
+	return s.Statement.RequiredPrivileges()
+}
+
 type KillQueryStatement struct {
 	// The query to kill.
 	QueryID uint64
@@ -668,6 +732,23 @@ func (s *KillQueryStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
 }
 
+// SetNowStatement represents a command for fixing the value of now() for
+// the statements following it in the same query.
+type SetNowStatement struct {
+	Now time.Time
+}
+
+// String returns a string representation of the set now statement.
+func (s *SetNowStatement) String() string {
+	return "SET NOW = " + QuoteString(s.Now.UTC().Format(time.RFC3339Nano))
+}
+
+// RequiredPrivileges returns the privilege required to execute a SetNowStatement.
+// It only changes how the query is evaluated so no privileges are needed.
+func (s *SetNowStatement) RequiredPrivileges() ExecutionPrivileges {
+	return nil
+}
+
 // SetPasswordUserStatement represents a command for changing user password.
 type SetPasswordUserStatement struct {
 	// Plain Password
@@ -806,6 +887,11 @@ type AlterRetentionPolicyStatement struct {
 
 	// Duration of the Shard
 	ShardGroupDuration *time.Duration
+
+	// Measurement whose data is kept for MeasurementDuration instead of the
+	// duration of the policy.  A zero duration removes the override.
+	Measurement         string
+	MeasurementDuration *time.Duration
 }
 
 // String returns a string representation of the alter retention policy statement.
@@ -831,6 +917,13 @@ func (s *AlterRetentionPolicyStatement) String() string {
 		_, _ = buf.WriteString(FormatDuration(*s.ShardGroupDuration))
 	}
 
+	if s.MeasurementDuration != nil {
+		_, _ = buf.WriteString(" MEASUREMENT ")
+		_, _ = buf.WriteString(QuoteIdent(s.Measurement))
+		_, _ = buf.WriteString(" DURATION ")
+		_, _ = buf.WriteString(FormatDuration(*s.MeasurementDuration))
+	}
+
 	if s.Default {
 		_, _ = buf.WriteString(" DEFAULT")
 	}
@@ -855,6 +948,8 @@ const (
 	NumberFill
 	// PreviousFill means that empty aggregate windows will be filled with whatever the previous aggregate window had
 	PreviousFill
+	// LinearFill means that empty aggregate windows will be filled with the value interpolated between the surrounding windows
+	LinearFill
 )
 
 // SelectStatement represents a command for extracting data from the database.
@@ -874,6 +969,9 @@ type SelectStatement struct {
 	// An expression evaluated on data point.
 	Condition Expr
 
+	// An expression evaluated on each row of an aggregate query.
+	Having Expr
+
 	// Fields to sort results by
 	SortFields SortFields
 
@@ -898,9 +996,14 @@ type SelectStatement struct {
 	// What fill option the select statement uses, if any
 	Fill FillOption
 
-	// The value to fill empty aggregate buckets with, if any
+	// The value to fill empty aggregate buckets with, if any.
+	// For fill(previous) this is how far to look back for a previous value.
 	FillValue interface{}
 
+	// The time zone that GROUP BY time() intervals are aligned to and that
+	// times are returned in. Defaults to UTC if not set.
+	Location *time.Location
+
 	// Renames the implicit time field name.
 	TimeAlias string
 
@@ -957,12 +1060,14 @@ func (s *SelectStatement) Clone() *SelectStatement {
 		Sources:    cloneSources(s.Sources),
 		SortFields: make(SortFields, 0, len(s.SortFields)),
 		Condition:  CloneExpr(s.Condition),
+		Having:     CloneExpr(s.Having),
 		Limit:      s.Limit,
 		Offset:     s.Offset,
 		SLimit:     s.SLimit,
 		SOffset:    s.SOffset,
 		Fill:       s.Fill,
 		FillValue:  s.FillValue,
+		Location:   s.Location,
 		IsRawQuery: s.IsRawQuery,
 	}
 	if s.Target != nil {
@@ -1007,6 +1112,14 @@ func cloneSource(s Source) Source {
 			m.Regex = &RegexLiteral{Val: regexp.MustCompile(s.Regex.Val.String())}
 		}
 		return m
+	case *SubQuery:
+		return &SubQuery{Statement: s.Statement.Clone()}
+	case *Join:
+		other := &Join{Measurements: make(Measurements, len(s.Measurements))}
+		for i, m := range s.Measurements {
+			other.Measurements[i] = cloneSource(m).(*Measurement)
+		}
+		return other
 	default:
 		panic("unreachable")
 	}
@@ -1029,7 +1142,9 @@ func (s *SelectStatement) RewriteWildcards(ic IteratorCreator) (*SelectStatement
 		return s, err
 	}
 
-	// If there are no dimension wildcards then merge dimensions to fields.
+	// If there are no dimension wildcards then the tags not in the group by
+	// are selected as fields.
+	var tags []string
 	if !hasDimensionWildcard {
 		// Remove the dimensions present in the group by so they don't get added as fields.
 		for _, d := range s.Dimensions {
@@ -1040,10 +1155,7 @@ func (s *SelectStatement) RewriteWildcards(ic IteratorCreator) (*SelectStatement
 				}
 			}
 		}
-
-		for k := range dimensionSet {
-			fieldSet[k] = struct{}{}
-		}
+		tags = stringSetSlice(dimensionSet)
 		dimensionSet = nil
 	}
 	fields := stringSetSlice(fieldSet)
@@ -1054,12 +1166,16 @@ func (s *SelectStatement) RewriteWildcards(ic IteratorCreator) (*SelectStatement
 	// Rewrite all wildcard query fields
 	if hasFieldWildcard {
 		// Allocate a slice assuming there is exactly one wildcard for efficiency.
-		rwFields := make(Fields, 0, len(s.Fields)+len(fields)-1)
+		rwFields := make(Fields, 0, len(s.Fields)+len(fields)+len(tags)-1)
 		for _, f := range s.Fields {
-			switch f.Expr.(type) {
+			switch expr := f.Expr.(type) {
 			case *Wildcard:
-				for _, name := range fields {
-					rwFields = append(rwFields, &Field{Expr: &VarRef{Val: name}})
+				refs, err := wildcardRefs(expr, fields, tags, s.Sources, ic)
+				if err != nil {
+					return s, err
+				}
+				for _, ref := range refs {
+					rwFields = append(rwFields, &Field{Expr: ref})
 				}
 			default:
 				rwFields = append(rwFields, f)
@@ -1088,6 +1204,58 @@ func (s *SelectStatement) RewriteWildcards(ic IteratorCreator) (*SelectStatement
 	return other, nil
 }
 
+// wildcardRefs returns the references a wildcard field expands to.  A
+// wildcard qualified with a type only expands to the tags, to the fields or
+// to the fields with values of the type in the series of the sources.
+func wildcardRefs(w *Wildcard, fields, tags []string, sources Sources, ic IteratorCreator) ([]*VarRef, error) {
+	var refs []*VarRef
+	switch w.Type {
+	case Unknown:
+		set := make(map[string]struct{}, len(fields)+len(tags))
+		for _, name := range fields {
+			set[name] = struct{}{}
+		}
+		for _, name := range tags {
+			set[name] = struct{}{}
+		}
+		for _, name := range stringSetSlice(set) {
+			refs = append(refs, &VarRef{Val: name})
+		}
+		return refs, nil
+	case Tag:
+		for _, name := range tags {
+			refs = append(refs, &VarRef{Val: name, Type: Tag})
+		}
+		return refs, nil
+	case AnyField:
+		for _, name := range fields {
+			refs = append(refs, &VarRef{Val: name, Type: AnyField})
+		}
+		return refs, nil
+	}
+
+	// The series report an unknown type for the fields without values of
+	// the type.
+	opt := IteratorOptions{Sources: sources, Aux: make([]VarRef, len(fields))}
+	for i, name := range fields {
+		opt.Aux[i] = VarRef{Val: name, Type: w.Type}
+	}
+	series, err := ic.SeriesKeys(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range fields {
+		for _, ser := range series {
+			if ser.Aux[i] != Unknown {
+				refs = append(refs, &VarRef{Val: name, Type: w.Type})
+				break
+			}
+		}
+	}
+	return refs, nil
+}
+
 // RewriteDistinct rewrites the expression to be a call for map/reduce to work correctly
 // This method assumes all validation has passed
 func (s *SelectStatement) RewriteDistinct() {
@@ -1121,6 +1289,56 @@ func (s *SelectStatement) RewriteTimeFields() {
 	}
 }
 
+// RewriteHistograms splits each histogram() field into a field per bucket.
+// Each field counts the values between two consecutive boundaries and is
+// named after the lower boundary of its bucket.
+// This method assumes all validation has passed
+func (s *SelectStatement) RewriteHistograms() {
+	fields := make(Fields, 0, len(s.Fields))
+	for _, f := range s.Fields {
+		call, ok := f.Expr.(*Call)
+		if !ok || call.Name != "histogram" {
+			fields = append(fields, f)
+			continue
+		}
+
+		prefix := f.Name()
+		for i := 1; i < len(call.Args)-1; i++ {
+			lo, _ := histogramBound(call.Args[i])
+			fields = append(fields, &Field{
+				Expr:  &Call{Name: call.Name, Args: []Expr{call.Args[0], call.Args[i], call.Args[i+1]}},
+				Alias: prefix + "_" + strconv.FormatFloat(lo, 'f', -1, 64),
+			})
+		}
+	}
+	s.Fields = fields
+}
+
+// RewriteDimensionFilters replaces each tag dimension restricted by a regex,
+// such as "GROUP BY host =~ /^server0[1-3]$/", with the tag and adds the regex
+// to the condition.  The series of the other values of the tag are then
+// excluded through the index before any cursor is created for them.
+// This method assumes all validation has passed
+func (s *SelectStatement) RewriteDimensionFilters() {
+	for _, d := range s.Dimensions {
+		expr, ok := d.Expr.(*BinaryExpr)
+		if !ok {
+			continue
+		}
+
+		d.Expr = &VarRef{Val: expr.LHS.(*VarRef).Val}
+		if s.Condition == nil {
+			s.Condition = expr
+		} else {
+			s.Condition = &BinaryExpr{
+				Op:  AND,
+				LHS: &ParenExpr{Expr: s.Condition},
+				RHS: expr,
+			}
+		}
+	}
+}
+
 // ColumnNames will walk all fields and functions and return the appropriate field names for the select statement
 // while maintaining order of the field names
 func (s *SelectStatement) ColumnNames() []string {
@@ -1230,7 +1448,17 @@ func (s *SelectStatement) String() string {
 	case NumberFill:
 		_, _ = buf.WriteString(fmt.Sprintf(" fill(%v)", s.FillValue))
 	case PreviousFill:
-		_, _ = buf.WriteString(" fill(previous)")
+		if lookback, ok := s.FillValue.(time.Duration); ok {
+			_, _ = buf.WriteString(fmt.Sprintf(" fill(previous, %s)", FormatDuration(lookback)))
+		} else {
+			_, _ = buf.WriteString(" fill(previous)")
+		}
+	case LinearFill:
+		_, _ = buf.WriteString(" fill(linear)")
+	}
+	if s.Having != nil {
+		_, _ = buf.WriteString(" HAVING ")
+		_, _ = buf.WriteString(s.Having.String())
 	}
 	if len(s.SortFields) > 0 {
 		_, _ = buf.WriteString(" ORDER BY ")
@@ -1249,6 +1477,9 @@ func (s *SelectStatement) String() string {
 	if s.SOffset > 0 {
 		_, _ = fmt.Fprintf(&buf, " SOFFSET %d", s.SOffset)
 	}
+	if s.Location != nil {
+		_, _ = fmt.Fprintf(&buf, " tz(%s)", QuoteString(s.Location.String()))
+	}
 	return buf.String()
 }
 
@@ -1294,6 +1525,10 @@ func (s *SelectStatement) HasDimensionWildcard() bool {
 }
 
 func (s *SelectStatement) validate(tr targetRequirement) error {
+	if err := s.validateSources(); err != nil {
+		return err
+	}
+
 	if err := s.validateFields(); err != nil {
 		return err
 	}
@@ -1310,6 +1545,38 @@ func (s *SelectStatement) validate(tr targetRequirement) error {
 		return err
 	}
 
+	if err := s.validateHaving(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SelectStatement) validateSources() error {
+	for _, src := range s.Sources {
+		switch src := src.(type) {
+		case *SubQuery:
+			if len(s.Sources) > 1 {
+				return errors.New("a subquery cannot be combined with other sources")
+			} else if src.Statement.Target != nil {
+				return errors.New("a subquery cannot have an INTO clause")
+			}
+		case *Join:
+			if len(s.Sources) > 1 {
+				return errors.New("a join cannot be combined with other sources")
+			}
+
+			names := make(map[string]struct{}, len(src.Measurements))
+			for _, m := range src.Measurements {
+				if m.Regex != nil {
+					return errors.New("a regex measurement cannot be joined")
+				} else if _, ok := names[m.Name]; ok {
+					return fmt.Errorf("measurement joined more than once: %s", m.Name)
+				}
+				names[m.Name] = struct{}{}
+			}
+		}
+	}
 	return nil
 }
 
@@ -1325,8 +1592,146 @@ func (s *SelectStatement) validateFields() error {
 			if err := expr.validate(); err != nil {
 				return err
 			}
+		case *CaseExpr:
+			if err := expr.validate(); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		WalkFunc(f.Expr, func(n Node) {
+			if call, ok := n.(*Call); ok && err == nil {
+				if isMathFunction(call.Name) {
+					err = validMathCall(call)
+				} else if isStringFunction(call.Name) {
+					err = validStringCall(call)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMathFunction returns true if name is a math function that transforms
+// each value of its argument rather than aggregating them.
+func isMathFunction(name string) bool {
+	switch name {
+	case "abs", "ceil", "floor", "round", "ln", "log", "pow", "sqrt", "sin", "cos", "tan":
+		return true
+	}
+	return false
+}
+
+// isStringFunction returns true if name is a string function that transforms
+// each value of its arguments rather than aggregating them.
+func isStringFunction(name string) bool {
+	switch name {
+	case "concat", "length", "substr", "lower", "upper":
+		return true
+	}
+	return false
+}
+
+// isScalarFunction returns true if name is a math or string function, which
+// are applied to each value of their arguments.
+func isScalarFunction(name string) bool {
+	return isMathFunction(name) || isStringFunction(name)
+}
+
+// validMathCall validates the arguments of a math function. The first argument
+// is a field, an aggregate or an expression of those. pow() and log() take a
+// number as their second argument for the exponent and the base.
+func validMathCall(expr *Call) error {
+	exp := 1
+	if expr.Name == "pow" || expr.Name == "log" {
+		exp = 2
+	}
+	if got := len(expr.Args); got != exp {
+		return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", expr.Name, exp, got)
+	}
+
+	switch arg := expr.Args[0].(type) {
+	case *VarRef, *BinaryExpr, *ParenExpr:
+	case *Call:
+		if arg.Name == "top" || arg.Name == "bottom" || arg.Name == "histogram" {
+			return fmt.Errorf("cannot use %s() inside of %s()", arg.Name, expr.Name)
+		}
+	default:
+		return fmt.Errorf("expected field argument in %s()", expr.Name)
+	}
+
+	if exp == 2 {
+		switch expr.Args[1].(type) {
+		case *NumberLiteral, *IntegerLiteral:
+		default:
+			return fmt.Errorf("expected number argument as second arg in %s", expr.Name)
+		}
+	}
+	return nil
+}
+
+// validStringCall validates the arguments of a string function. concat() takes
+// two or more fields, tags or strings. The other functions take a field or tag,
+// and substr() also takes a start position counted from 1 and an optional
+// length.
+func validStringCall(expr *Call) error {
+	min, max := 1, 1
+	switch expr.Name {
+	case "concat":
+		min, max = 2, -1
+	case "substr":
+		min, max = 2, 3
+	}
+	if got := len(expr.Args); max == -1 && got < min {
+		return fmt.Errorf("invalid number of arguments for %s, expected at least %d, got %d", expr.Name, min, got)
+	} else if max != -1 && (got < min || got > max) {
+		if min == max {
+			return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", expr.Name, min, got)
+		}
+		return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
+	}
+
+	args := expr.Args
+	if expr.Name != "concat" {
+		args = args[:1]
+	}
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case *VarRef, *CaseExpr:
+		case *StringLiteral:
+			if expr.Name != "concat" {
+				return fmt.Errorf("expected field argument in %s()", expr.Name)
+			}
+		case *Call:
+			if arg.Name == "top" || arg.Name == "bottom" || arg.Name == "histogram" {
+				return fmt.Errorf("cannot use %s() inside of %s()", arg.Name, expr.Name)
+			}
+		default:
+			return fmt.Errorf("expected field argument in %s()", expr.Name)
+		}
+	}
+
+	if expr.Name == "substr" {
+		if lit, ok := expr.Args[1].(*IntegerLiteral); !ok || lit.Val <= 0 {
+			return fmt.Errorf("expected positive integer argument as second arg in %s", expr.Name)
+		}
+		if len(expr.Args) == 3 {
+			if lit, ok := expr.Args[2].(*IntegerLiteral); !ok || lit.Val < 0 {
+				return fmt.Errorf("expected non-negative integer argument as third arg in %s", expr.Name)
+			}
 		}
 	}
+
+	v := binaryExprValidator{}
+	Walk(&v, expr)
+	if v.err != nil {
+		return v.err
+	} else if v.calls && v.refs {
+		return errors.New("string functions cannot mix aggregates and raw fields")
+	}
 	return nil
 }
 
@@ -1366,6 +1771,13 @@ func (s *SelectStatement) validateDimensions() error {
 			if strings.ToLower(expr.Val) == "time" {
 				return errors.New("time() is a function and expects at least one argument")
 			}
+		case *BinaryExpr:
+			// A tag dimension may be restricted to the values matching a regex.
+			if ref, ok := expr.LHS.(*VarRef); !ok || strings.ToLower(ref.Val) == "time" {
+				return errors.New("only time and tag dimensions allowed")
+			} else if expr.Op != EQREGEX && expr.Op != NEQREGEX {
+				return fmt.Errorf("invalid operator %s in tag dimension, expected =~ or !~", expr.Op)
+			}
 		case *Wildcard:
 		default:
 			return errors.New("only time and tag dimensions allowed")
@@ -1378,7 +1790,8 @@ func (s *SelectStatement) validateDimensions() error {
 // combination of aggregate functions combined with selected fields and tags
 // Currently we don't have support for all aggregates, but aggregates that
 // can be combined with fields/tags are:
-//  TOP, BOTTOM, MAX, MIN, FIRST, LAST
+//
+//	TOP, BOTTOM, MAX, MIN, FIRST, LAST
 func (s *SelectStatement) validSelectWithAggregate() error {
 	calls := map[string]struct{}{}
 	numAggregates := 0
@@ -1391,12 +1804,12 @@ func (s *SelectStatement) validSelectWithAggregate() error {
 			numAggregates++
 		}
 	}
-	// For TOP, BOTTOM, MAX, MIN, FIRST, LAST, PERCENTILE (selector functions) it is ok to ask for fields and tags
+	// For TOP, BOTTOM, MAX, MIN, FIRST, LAST, PERCENTILE, SAMPLE (selector functions) it is ok to ask for fields and tags
 	// but only if one function is specified.  Combining multiple functions and fields and tags is not currently supported
 	onlySelectors := true
 	for k := range calls {
 		switch k {
-		case "top", "bottom", "max", "min", "first", "last", "percentile":
+		case "top", "bottom", "max", "min", "first", "last", "percentile", "sample":
 		default:
 			onlySelectors = false
 			break
@@ -1456,111 +1869,285 @@ func (s *SelectStatement) validPercentileAggr(expr *Call) error {
 	case *VarRef:
 		// do nothing
 	default:
-		return fmt.Errorf("expected field argument in percentile()")
+		return fmt.Errorf("expected field argument in %s()", expr.Name)
 	}
 
 	switch expr.Args[1].(type) {
 	case *IntegerLiteral, *NumberLiteral:
 		return nil
 	default:
-		return fmt.Errorf("expected float argument in percentile()")
+		return fmt.Errorf("expected float argument in %s()", expr.Name)
+	}
+}
+
+// validSampleAggr determines if sample() has valid arguments.
+func (s *SelectStatement) validSampleAggr(expr *Call) error {
+	if err := s.validSelectWithAggregate(); err != nil {
+		return err
+	}
+	if exp, got := 2, len(expr.Args); got != exp {
+		return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", expr.Name, exp, got)
+	}
+
+	if _, ok := expr.Args[0].(*VarRef); !ok {
+		return fmt.Errorf("expected field argument in sample()")
+	}
+
+	if lit, ok := expr.Args[1].(*IntegerLiteral); !ok {
+		return fmt.Errorf("expected integer argument in sample()")
+	} else if lit.Val <= 0 {
+		return fmt.Errorf("sample window must be greater than 0, got %d", lit.Val)
+	} else if int64(int(lit.Val)) != lit.Val {
+		return fmt.Errorf("sample window too large, got %d", lit.Val)
+	}
+	return nil
+}
+
+// validUnitAggr determines if an aggregate that takes a field and an optional
+// time unit, such as integral() or rate(), has valid arguments.
+func (s *SelectStatement) validUnitAggr(expr *Call) error {
+	if err := s.validSelectWithAggregate(); err != nil {
+		return err
+	}
+	if min, max, got := 1, 2, len(expr.Args); got > max || got < min {
+		return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
+	}
+
+	if _, ok := expr.Args[0].(*VarRef); !ok {
+		return fmt.Errorf("expected field argument in %s()", expr.Name)
+	}
+
+	// If a duration arg is passed, make sure it's a positive duration.
+	if len(expr.Args) == 2 {
+		if lit, ok := expr.Args[1].(*DurationLiteral); !ok {
+			return fmt.Errorf("%s requires a duration argument", expr.Name)
+		} else if lit.Val <= 0 {
+			return fmt.Errorf("%s duration must be positive, got %s", expr.Name, FormatDuration(lit.Val))
+		}
+	}
+	return nil
+}
+
+// validHistogramAggr determines if histogram() has valid arguments.
+func (s *SelectStatement) validHistogramAggr(expr *Call) error {
+	if err := s.validSelectWithAggregate(); err != nil {
+		return err
+	}
+	if exp, got := 3, len(expr.Args); got < exp {
+		return fmt.Errorf("invalid number of arguments for %s, expected at least %d, got %d", expr.Name, exp, got)
+	}
+
+	if _, ok := expr.Args[0].(*VarRef); !ok {
+		return fmt.Errorf("expected field argument in histogram()")
+	}
+
+	// The bucket boundaries must be numbers in ascending order.
+	for i, arg := range expr.Args[1:] {
+		bound, ok := histogramBound(arg)
+		if !ok {
+			return fmt.Errorf("expected number argument as bucket boundary in histogram(), got %s", arg)
+		} else if i > 0 {
+			if prev, _ := histogramBound(expr.Args[i]); bound <= prev {
+				return fmt.Errorf("histogram() bucket boundaries must be in ascending order")
+			}
+		}
+	}
+	return nil
+}
+
+// histogramBound returns the value of a histogram bucket boundary.
+func histogramBound(expr Expr) (float64, bool) {
+	switch expr := expr.(type) {
+	case *IntegerLiteral:
+		return float64(expr.Val), true
+	case *NumberLiteral:
+		return expr.Val, true
+	default:
+		return 0, false
 	}
 }
 
+// validTransformAggr validates a call that transforms the points or the
+// aggregates of a series, such as derivative() or moving_average().
+func (s *SelectStatement) validTransformAggr(expr *Call) error {
+	switch expr.Name {
+	case "derivative", "non_negative_derivative":
+		if min, max, got := 1, 3, len(expr.Args); got > max || got < min {
+			return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
+		}
+		if len(expr.Args) >= 2 {
+			if _, ok := expr.Args[1].(*DurationLiteral); !ok {
+				return fmt.Errorf("second argument for %s must be a duration, got %T", expr.Name, expr.Args[1])
+			}
+		}
+		if len(expr.Args) == 3 {
+			if lit, ok := expr.Args[2].(*StringLiteral); !ok {
+				return fmt.Errorf("third argument for %s must be a string, got %T", expr.Name, expr.Args[2])
+			} else if _, ok := derivativeGapPolicies[lit.Val]; !ok {
+				return fmt.Errorf("invalid gap policy for %s: %s, expected extrapolate, null or skip", expr.Name, lit.Val)
+			}
+		}
+	case "elapsed":
+		if min, max, got := 1, 2, len(expr.Args); got > max || got < min {
+			return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
+		}
+		// If a duration arg is passed, make sure it's a duration
+		if len(expr.Args) == 2 {
+			// Second must be a duration .e.g (1h)
+			if lit, ok := expr.Args[1].(*DurationLiteral); !ok {
+				return errors.New("elapsed requires a duration argument")
+			} else if lit.Val <= 0 {
+				return fmt.Errorf("elapsed duration must be positive, got %s", FormatDuration(lit.Val))
+			}
+		}
+	case "difference", "non_negative_difference", "cumulative_sum":
+		if got := len(expr.Args); got != 1 {
+			return fmt.Errorf("invalid number of arguments for %s, expected 1, got %d", expr.Name, got)
+		}
+	case "moving_average", "exponential_moving_average", "double_exponential_moving_average", "kaufmans_adaptive_moving_average":
+		if got := len(expr.Args); got != 2 {
+			return fmt.Errorf("invalid number of arguments for %s, expected 2, got %d", expr.Name, got)
+		}
+
+		if lit, ok := expr.Args[1].(*IntegerLiteral); !ok {
+			return fmt.Errorf("second argument for %s must be an integer, got %T", expr.Name, expr.Args[1])
+		} else if lit.Val <= 1 {
+			return fmt.Errorf("%s window must be greater than 1, got %d", expr.Name, lit.Val)
+		} else if int64(int(lit.Val)) != lit.Val {
+			return fmt.Errorf("%s window too large, got %d", expr.Name, lit.Val)
+		}
+	}
+	// Validate that if they have grouping by time, they need a sub-call like min/max, etc.
+	groupByInterval, err := s.GroupByInterval()
+	if err != nil {
+		return fmt.Errorf("invalid group interval: %v", err)
+	}
+
+	if c, ok := expr.Args[0].(*Call); ok && groupByInterval == 0 {
+		return fmt.Errorf("%s aggregate requires a GROUP BY interval", expr.Name)
+	} else if !ok && groupByInterval > 0 {
+		return fmt.Errorf("aggregate function required inside the call to %s", expr.Name)
+	} else if ok {
+		return s.validNestedAggr(c)
+	}
+	return nil
+}
+
+// validNestedAggr determines if an aggregate called within another function
+// has valid arguments.
+func (s *SelectStatement) validNestedAggr(c *Call) error {
+	switch c.Name {
+	case "top", "bottom":
+		return s.validTopBottomAggr(c)
+	case "percentile", "interpolated_percentile":
+		return s.validPercentileAggr(c)
+	case "sample":
+		return s.validSampleAggr(c)
+	case "integral", "rate":
+		return s.validUnitAggr(c)
+	case "histogram":
+		return fmt.Errorf("histogram() cannot be used in an expression or another function")
+	case "derivative", "non_negative_derivative", "difference", "non_negative_difference", "cumulative_sum", "moving_average", "elapsed",
+		"exponential_moving_average", "double_exponential_moving_average", "kaufmans_adaptive_moving_average":
+		return s.validTransformAggr(c)
+	}
+
+	if exp, got := 1, len(c.Args); got != exp {
+		return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", c.Name, exp, got)
+	}
+
+	switch fc := c.Args[0].(type) {
+	case *VarRef:
+		// do nothing
+	case *Call:
+		if fc.Name != "distinct" || c.Name != "count" {
+			return fmt.Errorf("expected field argument in %s()", c.Name)
+		} else if exp, got := 1, len(fc.Args); got != exp {
+			return fmt.Errorf("count(distinct <field>) can only have one argument")
+		} else if _, ok := fc.Args[0].(*VarRef); !ok {
+			return fmt.Errorf("expected field argument in distinct()")
+		}
+	case *Distinct:
+		if c.Name != "count" {
+			return fmt.Errorf("expected field argument in %s()", c.Name)
+		}
+	default:
+		return fmt.Errorf("expected field argument in %s()", c.Name)
+	}
+	return nil
+}
+
 func (s *SelectStatement) validateAggregates(tr targetRequirement) error {
 	for _, f := range s.Fields {
 		for _, expr := range walkFunctionCalls(f.Expr) {
 			switch expr.Name {
-			case "derivative", "non_negative_derivative", "difference", "moving_average", "elapsed":
+			case "derivative", "non_negative_derivative", "difference", "non_negative_difference", "cumulative_sum", "moving_average", "elapsed",
+				"exponential_moving_average", "double_exponential_moving_average", "kaufmans_adaptive_moving_average":
 				if err := s.validSelectWithAggregate(); err != nil {
 					return err
 				}
-				switch expr.Name {
-				case "derivative", "non_negative_derivative":
-					if min, max, got := 1, 2, len(expr.Args); got > max || got < min {
-						return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
-					}
-				case "elapsed":
-					if min, max, got := 1, 2, len(expr.Args); got > max || got < min {
-						return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", expr.Name, min, max, got)
-					}
-					// If a duration arg is passed, make sure it's a duration
-					if len(expr.Args) == 2 {
-						// Second must be a duration .e.g (1h)
-						if _, ok := expr.Args[1].(*DurationLiteral); !ok {
-							return errors.New("elapsed requires a duration argument")
-						}
-					}
-				case "difference":
-					if got := len(expr.Args); got != 1 {
-						return fmt.Errorf("invalid number of arguments for difference, expected 1, got %d", got)
-					}
-				case "moving_average":
-					if got := len(expr.Args); got != 2 {
-						return fmt.Errorf("invalid number of arguments for moving_average, expected 2, got %d", got)
-					}
-
-					if lit, ok := expr.Args[1].(*IntegerLiteral); !ok {
-						return fmt.Errorf("second argument for moving_average must be an integer, got %T", expr.Args[1])
-					} else if lit.Val <= 1 {
-						return fmt.Errorf("moving_average window must be greater than 1, got %d", lit.Val)
-					} else if int64(int(lit.Val)) != lit.Val {
-						return fmt.Errorf("moving_average window too large, got %d", lit.Val)
-					}
+				if err := s.validTransformAggr(expr); err != nil {
+					return err
 				}
-				// Validate that if they have grouping by time, they need a sub-call like min/max, etc.
+			case "holt_winters", "holt_winters_with_fit":
+				if err := s.validSelectWithAggregate(); err != nil {
+					return err
+				}
+				if exp, got := 3, len(expr.Args); got != exp {
+					return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", expr.Name, exp, got)
+				}
+
 				groupByInterval, err := s.GroupByInterval()
 				if err != nil {
 					return fmt.Errorf("invalid group interval: %v", err)
 				}
 
-				if c, ok := expr.Args[0].(*Call); ok && groupByInterval == 0 {
+				c, ok := expr.Args[0].(*Call)
+				if !ok {
+					return fmt.Errorf("must use aggregate function with %s", expr.Name)
+				} else if groupByInterval == 0 {
 					return fmt.Errorf("%s aggregate requires a GROUP BY interval", expr.Name)
-				} else if !ok && groupByInterval > 0 {
-					return fmt.Errorf("aggregate function required inside the call to %s", expr.Name)
-				} else if ok {
-					switch c.Name {
-					case "top", "bottom":
-						if err := s.validTopBottomAggr(c); err != nil {
-							return err
-						}
-					case "percentile":
-						if err := s.validPercentileAggr(c); err != nil {
-							return err
-						}
-					default:
-						if exp, got := 1, len(c.Args); got != exp {
-							return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", c.Name, exp, got)
-						}
+				} else if err := s.validNestedAggr(c); err != nil {
+					return err
+				}
 
-						switch fc := c.Args[0].(type) {
-						case *VarRef:
-							// do nothing
-						case *Call:
-							if fc.Name != "distinct" || expr.Name != "count" {
-								return fmt.Errorf("expected field argument in %s()", c.Name)
-							} else if exp, got := 1, len(fc.Args); got != exp {
-								return fmt.Errorf("count(distinct <field>) can only have one argument", fc.Name, exp, got)
-							} else if _, ok := fc.Args[0].(*VarRef); !ok {
-								return fmt.Errorf("expected field argument in distinct()")
-							}
-						case *Distinct:
-							if expr.Name != "count" {
-								return fmt.Errorf("expected field argument in %s()", c.Name)
-							}
-						default:
-							return fmt.Errorf("expected field argument in %s()", c.Name)
-						}
-					}
+				if lit, ok := expr.Args[1].(*IntegerLiteral); !ok {
+					return fmt.Errorf("expected integer argument as second arg in %s", expr.Name)
+				} else if lit.Val <= 0 {
+					return fmt.Errorf("second arg to %s must be greater than 0, got %d", expr.Name, lit.Val)
+				} else if int64(int(lit.Val)) != lit.Val {
+					return fmt.Errorf("second arg to %s is too large, got %d", expr.Name, lit.Val)
+				}
+
+				if lit, ok := expr.Args[2].(*IntegerLiteral); !ok {
+					return fmt.Errorf("expected integer argument as third arg in %s", expr.Name)
+				} else if lit.Val < 0 {
+					return fmt.Errorf("third arg to %s cannot be negative, got %d", expr.Name, lit.Val)
+				} else if int64(int(lit.Val)) != lit.Val {
+					return fmt.Errorf("third arg to %s is too large, got %d", expr.Name, lit.Val)
 				}
 			case "top", "bottom":
 				if err := s.validTopBottomAggr(expr); err != nil {
 					return err
 				}
-			case "percentile":
+			case "percentile", "interpolated_percentile":
 				if err := s.validPercentileAggr(expr); err != nil {
 					return err
 				}
+			case "sample":
+				if err := s.validSampleAggr(expr); err != nil {
+					return err
+				}
+			case "integral", "rate":
+				if err := s.validUnitAggr(expr); err != nil {
+					return err
+				}
+			case "histogram":
+				if f.Expr != Expr(expr) {
+					return fmt.Errorf("histogram() cannot be used in an expression or another function")
+				} else if err := s.validHistogramAggr(expr); err != nil {
+					return err
+				}
 			default:
 				if err := s.validSelectWithAggregate(); err != nil {
 					return err
@@ -1608,9 +2195,49 @@ func (s *SelectStatement) validateAggregates(tr targetRequirement) error {
 
 	// If we have an aggregate function with a group by time without a where clause, it's an invalid statement
 	if tr == targetNotRequired { // ignore create continuous query statements
-		if !s.IsRawQuery && groupByDuration > 0 && !HasTimeExpr(s.Condition) {
+		if !s.IsRawQuery && groupByDuration > 0 && !s.hasTimeRange() {
+			return fmt.Errorf("aggregate functions with GROUP BY time require a WHERE time clause")
+		}
+		if err := s.validateSubQueryTimeRange(HasTimeExpr(s.Condition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasTimeRange returns true if the statement, or the subquery it selects
+// from, has a time condition.
+func (s *SelectStatement) hasTimeRange() bool {
+	if HasTimeExpr(s.Condition) {
+		return true
+	}
+	for _, src := range s.Sources {
+		if sq, ok := src.(*SubQuery); ok && sq.Statement.hasTimeRange() {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSubQueryTimeRange checks that the subqueries aggregating by time
+// are limited by a time condition of their own, of one of their subqueries
+// or of a statement selecting from them.  bounded is set if a statement
+// selecting from s has a time condition.
+func (s *SelectStatement) validateSubQueryTimeRange(bounded bool) error {
+	bounded = bounded || HasTimeExpr(s.Condition)
+	for _, src := range s.Sources {
+		sq, ok := src.(*SubQuery)
+		if !ok {
+			continue
+		}
+
+		groupByDuration, _ := sq.Statement.GroupByInterval()
+		if !sq.Statement.IsRawQuery && groupByDuration > 0 && !bounded && !sq.Statement.hasTimeRange() {
 			return fmt.Errorf("aggregate functions with GROUP BY time require a WHERE time clause")
 		}
+		if err := sq.Statement.validateSubQueryTimeRange(bounded); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -1650,7 +2277,98 @@ func (s *SelectStatement) validateDistinct() error {
 			return fmt.Errorf("distinct function can only have one argument")
 		}
 	}
-	return nil
+	return nil
+}
+
+func (s *SelectStatement) validateHaving() error {
+	if s.Having == nil {
+		return nil
+	} else if s.IsRawQuery {
+		return errors.New("HAVING clause requires an aggregate function")
+	}
+
+	// Columns selected by wildcards are only known once they are rewritten.
+	if s.HasFieldWildcard() {
+		return nil
+	}
+	_, err := s.HavingCondition()
+	return err
+}
+
+// HavingCondition returns the HAVING clause with every aggregate replaced by a
+// reference to the column it is selected as, so that it can be evaluated
+// against the columns of each row. Returns nil if there is no HAVING clause.
+func (s *SelectStatement) HavingCondition() (Expr, error) {
+	if s.Having == nil {
+		return nil, nil
+	}
+
+	columns := s.ColumnNames()
+	names := make(map[string]struct{}, len(columns))
+	for _, name := range columns {
+		names[name] = struct{}{}
+	}
+
+	// Map each selected expression to the name of its column.
+	i := 0
+	if !s.OmitTime {
+		i++
+	}
+	exprs := make(map[string]string, len(s.Fields))
+	for _, f := range s.Fields {
+		if _, ok := exprs[f.Expr.String()]; !ok {
+			exprs[f.Expr.String()] = columns[i]
+		}
+		i++
+
+		// top() and bottom() add a column for each of their tags and fields.
+		if call, ok := f.Expr.(*Call); ok && (call.Name == "top" || call.Name == "bottom") {
+			for _, arg := range call.Args[1:] {
+				if _, ok := arg.(*VarRef); ok {
+					i++
+				}
+			}
+		}
+	}
+
+	return rewriteHavingExpr(CloneExpr(s.Having), exprs, names)
+}
+
+// rewriteHavingExpr replaces the selected expressions within expr with
+// references to their columns. Returns an error if expr refers to anything
+// that is not a column of the statement.
+func rewriteHavingExpr(expr Expr, exprs map[string]string, names map[string]struct{}) (Expr, error) {
+	if name, ok := exprs[expr.String()]; ok {
+		return &VarRef{Val: name}, nil
+	}
+
+	switch expr := expr.(type) {
+	case *BinaryExpr:
+		lhs, err := rewriteHavingExpr(expr.LHS, exprs, names)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := rewriteHavingExpr(expr.RHS, exprs, names)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpr{Op: expr.Op, LHS: lhs, RHS: rhs}, nil
+	case *ParenExpr:
+		inner, err := rewriteHavingExpr(expr.Expr, exprs, names)
+		if err != nil {
+			return nil, err
+		}
+		return &ParenExpr{Expr: inner}, nil
+	case *Call:
+		return nil, fmt.Errorf("%s in HAVING clause must also be selected", expr)
+	case *VarRef:
+		if _, ok := names[expr.Val]; !ok {
+			return nil, fmt.Errorf("unknown column in HAVING clause: %s", expr.Val)
+		}
+	case *Wildcard:
+		return nil, errors.New("wildcards are not allowed in the HAVING clause")
+	}
+	return expr, nil
 }
 
 // GroupByInterval extracts the time interval, if specified.
@@ -1888,6 +2606,14 @@ func walkNames(exp Expr) []string {
 		ret = append(ret, walkNames(expr.LHS)...)
 		ret = append(ret, walkNames(expr.RHS)...)
 		return ret
+	case *CaseExpr:
+		var ret []string
+		for _, w := range expr.WhenClauses {
+			ret = append(ret, walkNames(w.Condition)...)
+			ret = append(ret, walkNames(w.Result)...)
+		}
+		ret = append(ret, walkNames(expr.Else)...)
+		return ret
 	case *ParenExpr:
 		return walkNames(expr.Expr)
 	}
@@ -1938,12 +2664,29 @@ func walkFunctionCalls(exp Expr) []*Call {
 	case *VarRef:
 		return nil
 	case *Call:
+		// Math and string functions are applied to the result of their
+		// arguments so only the calls inside of them are aggregates.
+		if isScalarFunction(expr.Name) {
+			var ret []*Call
+			for _, arg := range expr.Args {
+				ret = append(ret, walkFunctionCalls(arg)...)
+			}
+			return ret
+		}
 		return []*Call{expr}
 	case *BinaryExpr:
 		var ret []*Call
 		ret = append(ret, walkFunctionCalls(expr.LHS)...)
 		ret = append(ret, walkFunctionCalls(expr.RHS)...)
 		return ret
+	case *CaseExpr:
+		var ret []*Call
+		for _, w := range expr.WhenClauses {
+			ret = append(ret, walkFunctionCalls(w.Condition)...)
+			ret = append(ret, walkFunctionCalls(w.Result)...)
+		}
+		ret = append(ret, walkFunctionCalls(expr.Else)...)
+		return ret
 	case *ParenExpr:
 		return walkFunctionCalls(expr.Expr)
 	}
@@ -2105,6 +2848,60 @@ func (s *ShowSeriesStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
 }
 
+// ShowSeriesCardinalityStatement represents a command for counting the series
+// in the database.
+type ShowSeriesCardinalityStatement struct {
+	// Exact is true if the series of each measurement should be counted.
+	// Otherwise a single total is returned for the database.
+	Exact bool
+
+	// Measurement(s) the series are counted for.
+	Sources Sources
+
+	// An expression evaluated on a series name or tag.
+	Condition Expr
+}
+
+// String returns a string representation of the statement.
+func (s *ShowSeriesCardinalityStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("SHOW SERIES ")
+	writeCardinality(&buf, s.Exact, s.Sources, nil, s.Condition)
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute a ShowSeriesCardinalityStatement.
+func (s *ShowSeriesCardinalityStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
+}
+
+// writeCardinality writes the common parts of cardinality statements to buf.
+func writeCardinality(buf *bytes.Buffer, exact bool, sources Sources, tagKeys []string, condition Expr) {
+	if exact {
+		_, _ = buf.WriteString("EXACT ")
+	}
+	_, _ = buf.WriteString("CARDINALITY")
+
+	if sources != nil {
+		_, _ = buf.WriteString(" FROM ")
+		_, _ = buf.WriteString(sources.String())
+	}
+	if tagKeys != nil {
+		_, _ = buf.WriteString(" WITH KEY IN (")
+		for idx, tagKey := range tagKeys {
+			if idx != 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			_, _ = buf.WriteString(QuoteIdent(tagKey))
+		}
+		_, _ = buf.WriteString(")")
+	}
+	if condition != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString(condition.String())
+	}
+}
+
 // DropSeriesStatement represents a command for removing a series from the database.
 type DropSeriesStatement struct {
 	// Data source that fields are extracted from (optional)
@@ -2188,6 +2985,185 @@ func (s *DropShardStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
 }
 
+// CompactShardStatement represents a command for running a full
+// compaction of a shard on the node.
+type CompactShardStatement struct {
+	// ID of the shard to be compacted.
+	ID uint64
+}
+
+// String returns a string representation of the compact shard statement.
+func (s *CompactShardStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("COMPACT SHARD ")
+	buf.WriteString(strconv.FormatUint(s.ID, 10))
+	buf.WriteString(" FULL")
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute a
+// CompactShardStatement.
+func (s *CompactShardStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// PauseCompactionsStatement represents a command for pausing or
+// resuming compactions on the node.
+type PauseCompactionsStatement struct {
+	// ID of the shard to pause or resume. Zero applies to all shards.
+	ShardID uint64
+
+	// Resume is true if compactions should be resumed instead of paused.
+	Resume bool
+}
+
+// String returns a string representation of the pause compactions statement.
+func (s *PauseCompactionsStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("COMPACT ")
+	if s.ShardID != 0 {
+		buf.WriteString("SHARD ")
+		buf.WriteString(strconv.FormatUint(s.ShardID, 10))
+		buf.WriteString(" ")
+	}
+	if s.Resume {
+		buf.WriteString("RESUME")
+	} else {
+		buf.WriteString("PAUSE")
+	}
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute a
+// PauseCompactionsStatement.
+func (s *PauseCompactionsStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// AlterShardTierStatement represents a command for moving a shard on the
+// node between the data and cold data directories.
+type AlterShardTierStatement struct {
+	// ID of the shard to be moved.
+	ID uint64
+
+	// Cold is true if the shard should be moved to the cold data directory.
+	Cold bool
+}
+
+// String returns a string representation of the alter shard tier statement.
+func (s *AlterShardTierStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("ALTER SHARD ")
+	buf.WriteString(strconv.FormatUint(s.ID, 10))
+	if s.Cold {
+		buf.WriteString(" TIER COLD")
+	} else {
+		buf.WriteString(" TIER HOT")
+	}
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute an
+// AlterShardTierStatement.
+func (s *AlterShardTierStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// CreateSchemaStatement represents a command for declaring the tag keys and
+// field types of a measurement.
+type CreateSchemaStatement struct {
+	// Name of the measurement.
+	Name string
+
+	// Name of the database the measurement is in.
+	Database string
+
+	// Declared tag keys.
+	TagKeys []string
+
+	// Declared fields and their types.
+	Fields []SchemaField
+
+	// Strict rejects points with tags or fields that are not declared.
+	Strict bool
+}
+
+// SchemaField is a field declared by a CreateSchemaStatement.
+type SchemaField struct {
+	Name string
+	Type DataType
+}
+
+// String returns a string representation of the create schema statement.
+func (s *CreateSchemaStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("CREATE SCHEMA ")
+	buf.WriteString(QuoteIdent(s.Name))
+	buf.WriteString(" ON ")
+	buf.WriteString(QuoteIdent(s.Database))
+	buf.WriteString(" (")
+	for i, k := range s.TagKeys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(QuoteIdent(k))
+		buf.WriteString(" TAG")
+	}
+	for i, f := range s.Fields {
+		if i > 0 || len(s.TagKeys) > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(QuoteIdent(f.Name))
+		buf.WriteString(" ")
+		buf.WriteString(strings.ToUpper(f.Type.String()))
+	}
+	buf.WriteString(")")
+	if s.Strict {
+		buf.WriteString(" STRICT")
+	}
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute a
+// CreateSchemaStatement.
+func (s *CreateSchemaStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// DropSchemaStatement represents a command for removing the declared schema
+// of a measurement.
+type DropSchemaStatement struct {
+	// Name of the measurement.
+	Name string
+
+	// Name of the database the measurement is in.
+	Database string
+}
+
+// String returns a string representation of the drop schema statement.
+func (s *DropSchemaStatement) String() string {
+	return fmt.Sprintf("DROP SCHEMA %s ON %s", QuoteIdent(s.Name), QuoteIdent(s.Database))
+}
+
+// RequiredPrivileges returns the privilege required to execute a
+// DropSchemaStatement.
+func (s *DropSchemaStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: true, Name: "", Privilege: AllPrivileges}}
+}
+
+// ShowSchemasStatement represents a command for listing the declared
+// measurement schemas.
+type ShowSchemasStatement struct{}
+
+// String returns a string representation of the show schemas statement.
+func (s *ShowSchemasStatement) String() string { return "SHOW SCHEMAS" }
+
+// RequiredPrivileges returns the privilege required to execute a
+// ShowSchemasStatement.
+func (s *ShowSchemasStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
+}
+
 // ShowContinuousQueriesStatement represents a command for listing continuous queries.
 type ShowContinuousQueriesStatement struct{}
 
@@ -2382,6 +3358,33 @@ func (s *ShowMeasurementsStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
 }
 
+// ShowMeasurementCardinalityStatement represents a command for counting the
+// measurements in the database.
+type ShowMeasurementCardinalityStatement struct {
+	// Exact is true if the measurements should be counted by walking the
+	// index rather than read from its counters.
+	Exact bool
+
+	// Measurement(s) to count.
+	Sources Sources
+
+	// An expression evaluated on a series name or tag.
+	Condition Expr
+}
+
+// String returns a string representation of the statement.
+func (s *ShowMeasurementCardinalityStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("SHOW MEASUREMENT ")
+	writeCardinality(&buf, s.Exact, s.Sources, nil, s.Condition)
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute a ShowMeasurementCardinalityStatement.
+func (s *ShowMeasurementCardinalityStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
+}
+
 // DropMeasurementStatement represents a command to drop a measurement.
 type DropMeasurementStatement struct {
 	// Name of the measurement to be dropped.
@@ -2694,6 +3697,36 @@ func (s *ShowTagValuesStatement) RequiredPrivileges() ExecutionPrivileges {
 	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
 }
 
+// ShowTagValuesCardinalityStatement represents a command for counting the
+// values of tag keys in the database.
+type ShowTagValuesCardinalityStatement struct {
+	// Exact is true if the values should be counted for each measurement.
+	// Otherwise a single total is returned for the database.
+	Exact bool
+
+	// Data source that tag values are counted for.
+	Sources Sources
+
+	// Tag key(s) to count values of.
+	TagKeys []string
+
+	// An expression evaluated on a series name or tag.
+	Condition Expr
+}
+
+// String returns a string representation of the statement.
+func (s *ShowTagValuesCardinalityStatement) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("SHOW TAG VALUES ")
+	writeCardinality(&buf, s.Exact, s.Sources, s.TagKeys, s.Condition)
+	return buf.String()
+}
+
+// RequiredPrivileges returns the privilege required to execute a ShowTagValuesCardinalityStatement.
+func (s *ShowTagValuesCardinalityStatement) RequiredPrivileges() ExecutionPrivileges {
+	return ExecutionPrivileges{{Admin: false, Name: "", Privilege: ReadPrivilege}}
+}
+
 // ShowUsersStatement represents a command for listing users.
 type ShowUsersStatement struct{}
 
@@ -2712,6 +3745,9 @@ type ShowFieldKeysStatement struct {
 	// Data sources that fields are extracted from.
 	Sources Sources
 
+	// A time range limiting the shards the fields are read from.
+	Condition Expr
+
 	// Fields to sort results by
 	SortFields SortFields
 
@@ -2732,6 +3768,10 @@ func (s *ShowFieldKeysStatement) String() string {
 		_, _ = buf.WriteString(" FROM ")
 		_, _ = buf.WriteString(s.Sources.String())
 	}
+	if s.Condition != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString(s.Condition.String())
+	}
 	if len(s.SortFields) > 0 {
 		_, _ = buf.WriteString(" ORDER BY ")
 		_, _ = buf.WriteString(s.SortFields.String())
@@ -2778,6 +3818,8 @@ func (a Fields) Names() []string {
 			names = append(names, walkNames(expr)...)
 		case *ParenExpr:
 			names = append(names, walkNames(expr)...)
+		case *CaseExpr:
+			names = append(names, walkNames(expr)...)
 		}
 	}
 	return names
@@ -2812,6 +3854,8 @@ func (f *Field) Name() string {
 		return expr.Name
 	case *BinaryExpr:
 		return BinaryExprName(expr)
+	case *CaseExpr:
+		return "case"
 	case *ParenExpr:
 		f := Field{Expr: expr.Expr}
 		return f.Name()
@@ -2889,6 +3933,42 @@ func (a Measurements) String() string {
 	return strings.Join(str, ", ")
 }
 
+// SubQuery is a source with the results of another select statement.
+type SubQuery struct {
+	Statement *SelectStatement
+}
+
+// String returns a string representation of the subquery.
+func (s *SubQuery) String() string {
+	return fmt.Sprintf("(%s)", s.Statement.String())
+}
+
+// Join is a source with the points of several measurements joined on their
+// time and tags.  The fields of each measurement are prefixed with its name,
+// as in "errors.value", and are null in the points a measurement has no point
+// for.
+type Join struct {
+	Measurements Measurements
+}
+
+// Name returns the name of the measurement of the joined points.
+func (j *Join) Name() string {
+	names := make([]string, len(j.Measurements))
+	for i, m := range j.Measurements {
+		names[i] = m.Name
+	}
+	return strings.Join(names, "_")
+}
+
+// String returns a string representation of the join.
+func (j *Join) String() string {
+	var str []string
+	for _, m := range j.Measurements {
+		str = append(str, m.String())
+	}
+	return strings.Join(str, " JOIN ")
+}
+
 // Measurement represents a single measurement used as a datasource.
 type Measurement struct {
 	Database        string
@@ -2958,10 +4038,39 @@ func decodeMeasurement(pb *internal.Measurement) (*Measurement, error) {
 // VarRef represents a reference to a variable.
 type VarRef struct {
 	Val string
+
+	// The type the reference is qualified with, if any.  Tag and AnyField
+	// select a tag or a field when both have the name, the other types
+	// select a field and cast its values to the type.
+	Type DataType
+}
+
+// VarRefs represents a slice of VarRef types.
+type VarRefs []VarRef
+
+func (a VarRefs) Len() int { return len(a) }
+func (a VarRefs) Less(i, j int) bool {
+	if a[i].Val != a[j].Val {
+		return a[i].Val < a[j].Val
+	}
+	return a[i].Type < a[j].Type
+}
+func (a VarRefs) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// Strings returns a slice of the variable references as strings.
+func (a VarRefs) Strings() []string {
+	s := make([]string, len(a))
+	for i, ref := range a {
+		s[i] = ref.String()
+	}
+	return s
 }
 
 // String returns a string representation of the variable reference.
 func (r *VarRef) String() string {
+	if r.Type != Unknown {
+		return QuoteIdent(r.Val) + "::" + r.Type.String()
+	}
 	return QuoteIdent(r.Val)
 }
 
@@ -3146,6 +4255,17 @@ func (e *BinaryExpr) validate() error {
 	return nil
 }
 
+func (e *CaseExpr) validate() error {
+	v := binaryExprValidator{}
+	Walk(&v, e)
+	if v.err != nil {
+		return v.err
+	} else if v.calls && v.refs {
+		return errors.New("case expressions cannot mix aggregates and raw fields")
+	}
+	return nil
+}
+
 type binaryExprValidator struct {
 	calls bool
 	refs  bool
@@ -3159,6 +4279,9 @@ func (v *binaryExprValidator) Visit(n Node) Visitor {
 
 	switch n := n.(type) {
 	case *Call:
+		if isScalarFunction(n.Name) {
+			return v
+		}
 		v.calls = true
 
 		if n.Name == "top" || n.Name == "bottom" {
@@ -3202,6 +4325,38 @@ func (v *binaryExprNameVisitor) Visit(n Node) Visitor {
 	return v
 }
 
+// CaseExpr represents a CASE expression.  It returns the result of the first
+// WHEN clause whose condition is true, or the ELSE result if none is.  The
+// result is null if no condition is true and there is no ELSE result.
+type CaseExpr struct {
+	WhenClauses []*WhenClause
+	Else        Expr
+}
+
+// WhenClause represents a condition of a CASE expression with its result.
+type WhenClause struct {
+	Condition Expr
+	Result    Expr
+}
+
+// String returns a string representation of the case expression.
+func (e *CaseExpr) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("CASE")
+	for _, w := range e.WhenClauses {
+		_, _ = buf.WriteString(" WHEN ")
+		_, _ = buf.WriteString(w.Condition.String())
+		_, _ = buf.WriteString(" THEN ")
+		_, _ = buf.WriteString(w.Result.String())
+	}
+	if e.Else != nil {
+		_, _ = buf.WriteString(" ELSE ")
+		_, _ = buf.WriteString(e.Else.String())
+	}
+	_, _ = buf.WriteString(" END")
+	return buf.String()
+}
+
 // ParenExpr represents a parenthesized expression.
 type ParenExpr struct {
 	Expr Expr
@@ -3238,10 +4393,18 @@ func CloneRegexLiteral(r *RegexLiteral) *RegexLiteral {
 }
 
 // Wildcard represents a wild card expression.
-type Wildcard struct{}
+type Wildcard struct {
+	// The type the wildcard is restricted to, if any.
+	Type DataType
+}
 
 // String returns a string representation of the wildcard.
-func (e *Wildcard) String() string { return "*" }
+func (e *Wildcard) String() string {
+	if e.Type != Unknown {
+		return "*::" + e.Type.String()
+	}
+	return "*"
+}
 
 // CloneExpr returns a deep copy of the expression.
 func CloneExpr(expr Expr) Expr {
@@ -3259,6 +4422,12 @@ func CloneExpr(expr Expr) Expr {
 			args[i] = CloneExpr(arg)
 		}
 		return &Call{Name: expr.Name, Args: args}
+	case *CaseExpr:
+		other := &CaseExpr{WhenClauses: make([]*WhenClause, len(expr.WhenClauses)), Else: CloneExpr(expr.Else)}
+		for i, w := range expr.WhenClauses {
+			other.WhenClauses[i] = &WhenClause{Condition: CloneExpr(w.Condition), Result: CloneExpr(w.Result)}
+		}
+		return other
 	case *Distinct:
 		return &Distinct{Val: expr.Val}
 	case *DurationLiteral:
@@ -3276,9 +4445,9 @@ func CloneExpr(expr Expr) Expr {
 	case *TimeLiteral:
 		return &TimeLiteral{Val: expr.Val}
 	case *VarRef:
-		return &VarRef{Val: expr.Val}
+		return &VarRef{Val: expr.Val, Type: expr.Type}
 	case *Wildcard:
-		return &Wildcard{}
+		return &Wildcard{Type: expr.Type}
 	}
 	panic("unreachable")
 }
@@ -3460,6 +4629,13 @@ func Walk(v Visitor, node Node) {
 			Walk(v, expr)
 		}
 
+	case *CaseExpr:
+		for _, w := range n.WhenClauses {
+			Walk(v, w.Condition)
+			Walk(v, w.Result)
+		}
+		Walk(v, n.Else)
+
 	case *CreateContinuousQueryStatement:
 		Walk(v, n.Source)
 
@@ -3479,6 +4655,9 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Sources)
 		Walk(v, n.Condition)
 
+	case *ExplainStatement:
+		Walk(v, n.Statement)
+
 	case *Field:
 		Walk(v, n.Expr)
 
@@ -3499,12 +4678,25 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Dimensions)
 		Walk(v, n.Sources)
 		Walk(v, n.Condition)
+		Walk(v, n.Having)
 		Walk(v, n.SortFields)
 
 	case *ShowSeriesStatement:
 		Walk(v, n.Sources)
 		Walk(v, n.Condition)
 
+	case *ShowSeriesCardinalityStatement:
+		Walk(v, n.Sources)
+		Walk(v, n.Condition)
+
+	case *ShowMeasurementCardinalityStatement:
+		Walk(v, n.Sources)
+		Walk(v, n.Condition)
+
+	case *ShowTagValuesCardinalityStatement:
+		Walk(v, n.Sources)
+		Walk(v, n.Condition)
+
 	case *ShowTagKeysStatement:
 		Walk(v, n.Sources)
 		Walk(v, n.Condition)
@@ -3529,6 +4721,14 @@ func Walk(v Visitor, node Node) {
 			Walk(v, s)
 		}
 
+	case *SubQuery:
+		Walk(v, n.Statement)
+
+	case *Join:
+		for _, m := range n.Measurements {
+			Walk(v, m)
+		}
+
 	case Statements:
 		for _, s := range n {
 			Walk(v, s)
@@ -3573,6 +4773,9 @@ func Rewrite(r Rewriter, node Node) Node {
 		n.Dimensions = Rewrite(r, n.Dimensions).(Dimensions)
 		n.Sources = Rewrite(r, n.Sources).(Sources)
 		n.Condition = Rewrite(r, n.Condition).(Expr)
+		if n.Having != nil {
+			n.Having = Rewrite(r, n.Having).(Expr)
+		}
 
 	case Fields:
 		for i, f := range n {
@@ -3601,6 +4804,15 @@ func Rewrite(r Rewriter, node Node) Node {
 		for i, expr := range n.Args {
 			n.Args[i] = Rewrite(r, expr).(Expr)
 		}
+
+	case *CaseExpr:
+		for _, w := range n.WhenClauses {
+			w.Condition = Rewrite(r, w.Condition).(Expr)
+			w.Result = Rewrite(r, w.Result).(Expr)
+		}
+		if n.Else != nil {
+			n.Else = Rewrite(r, n.Else).(Expr)
+		}
 	}
 
 	return r.Rewrite(node)
@@ -3640,6 +4852,15 @@ func RewriteExpr(expr Expr, fn func(Expr) Expr) Expr {
 		for i, expr := range e.Args {
 			e.Args[i] = RewriteExpr(expr, fn)
 		}
+
+	case *CaseExpr:
+		for _, w := range e.WhenClauses {
+			w.Condition = RewriteExpr(w.Condition, fn)
+			w.Result = RewriteExpr(w.Result, fn)
+		}
+		if e.Else != nil {
+			e.Else = RewriteExpr(e.Else, fn)
+		}
 	}
 
 	return fn(expr)
@@ -3656,6 +4877,15 @@ func Eval(expr Expr, m map[string]interface{}) interface{} {
 		return evalBinaryExpr(expr, m)
 	case *BooleanLiteral:
 		return expr.Val
+	case *Call:
+		return evalCall(expr, m)
+	case *CaseExpr:
+		for _, w := range expr.WhenClauses {
+			if EvalBool(w.Condition, m) {
+				return Eval(w.Result, m)
+			}
+		}
+		return Eval(expr.Else, m)
 	case *IntegerLiteral:
 		return expr.Val
 	case *NumberLiteral:
@@ -3673,6 +4903,68 @@ func Eval(expr Expr, m map[string]interface{}) interface{} {
 	}
 }
 
+// evalCall evaluates a string function.  Returns nil for any other call or if
+// the argument is not a string.  The nil values of the arguments of concat()
+// are skipped.
+func evalCall(expr *Call, m map[string]interface{}) interface{} {
+	if !isStringFunction(expr.Name) || len(expr.Args) == 0 {
+		return nil
+	}
+
+	if expr.Name == "concat" {
+		var buf bytes.Buffer
+		found := false
+		for _, arg := range expr.Args {
+			switch v := Eval(arg, m).(type) {
+			case nil:
+				continue
+			case string:
+				buf.WriteString(v)
+			default:
+				fmt.Fprint(&buf, v)
+			}
+			found = true
+		}
+		if !found {
+			return nil
+		}
+		return buf.String()
+	}
+
+	s, ok := Eval(expr.Args[0], m).(string)
+	if !ok {
+		return nil
+	}
+
+	switch expr.Name {
+	case "length":
+		return int64(utf8.RuneCountInString(s))
+	case "lower":
+		return strings.ToLower(s)
+	case "upper":
+		return strings.ToUpper(s)
+	case "substr":
+		start, ok := Eval(expr.Args[1], m).(int64)
+		if !ok || start <= 0 {
+			return nil
+		}
+		runes := []rune(s)
+		if start > int64(len(runes)) {
+			return ""
+		}
+		runes = runes[start-1:]
+		if len(expr.Args) > 2 {
+			if n, ok := Eval(expr.Args[2], m).(int64); !ok || n < 0 {
+				return nil
+			} else if n < int64(len(runes)) {
+				runes = runes[:n]
+			}
+		}
+		return string(runes)
+	}
+	return nil
+}
+
 func evalBinaryExpr(expr *BinaryExpr, m map[string]interface{}) interface{} {
 	lhs := Eval(expr.LHS, m)
 	rhs := Eval(expr.RHS, m)
@@ -4180,14 +5472,14 @@ func reduceParenExpr(expr *ParenExpr, valuer Valuer) Expr {
 func reduceVarRef(expr *VarRef, valuer Valuer) Expr {
 	// Ignore if there is no valuer.
 	if valuer == nil {
-		return &VarRef{Val: expr.Val}
+		return &VarRef{Val: expr.Val, Type: expr.Type}
 	}
 
 	// Retrieve the value of the ref.
 	// Ignore if the value doesn't exist.
 	v, ok := valuer.Value(expr.Val)
 	if !ok {
-		return &VarRef{Val: expr.Val}
+		return &VarRef{Val: expr.Val, Type: expr.Type}
 	}
 
 	// Return the value as a literal.
@@ -4239,8 +5531,11 @@ type containsVarRefVisitor struct {
 }
 
 func (v *containsVarRefVisitor) Visit(n Node) Visitor {
-	switch n.(type) {
+	switch n := n.(type) {
 	case *Call:
+		if isScalarFunction(n.Name) {
+			return v
+		}
 		return nil
 	case *VarRef:
 		v.contains = true
@@ -402,6 +402,12 @@ func TestSelectStatement_RewriteWildcards(t *testing.T) {
 			rewrite: `SELECT mean(value) FROM cpu WHERE time < now() GROUP BY host, region, time(1m) fill(0)`,
 		},
 
+		// GROUP BY wildcard with a time zone
+		{
+			stmt:    `SELECT mean(value) FROM cpu where time < now() GROUP BY *,time(1d) tz('America/New_York')`,
+			rewrite: `SELECT mean(value) FROM cpu WHERE time < now() GROUP BY host, region, time(1d) tz('America/New_York')`,
+		},
+
 		// GROUP BY wildcard with explicit
 		{
 			stmt:    `SELECT value FROM cpu GROUP BY *,host`,
@@ -419,6 +425,28 @@ func TestSelectStatement_RewriteWildcards(t *testing.T) {
 			stmt:    `SELECT * FROM cpu GROUP BY *`,
 			rewrite: `SELECT value1, value2 FROM cpu GROUP BY host, region`,
 		},
+
+		// Query wildcard restricted to tags
+		{
+			stmt:    `SELECT *::tag FROM cpu GROUP BY host`,
+			rewrite: `SELECT region::tag FROM cpu GROUP BY host`,
+		},
+
+		// Query wildcard restricted to fields
+		{
+			stmt:    `SELECT *::field FROM cpu`,
+			rewrite: `SELECT value1::field, value2::field FROM cpu`,
+		},
+
+		// Query wildcard restricted to a field type
+		{
+			stmt:    `SELECT *::float FROM cpu`,
+			rewrite: `SELECT value1::float FROM cpu`,
+		},
+		{
+			stmt:    `SELECT *::string FROM cpu`,
+			rewrite: `SELECT value2::string FROM cpu`,
+		},
 	}
 
 	for i, tt := range tests {
@@ -434,6 +462,16 @@ func TestSelectStatement_RewriteWildcards(t *testing.T) {
 			dimensions = map[string]struct{}{"host": struct{}{}, "region": struct{}{}}
 			return
 		}
+		ic.SeriesKeysFn = func(opt influxql.IteratorOptions) (influxql.SeriesList, error) {
+			// value1 is a float and value2 a string.
+			aux := make([]influxql.DataType, len(opt.Aux))
+			for i, ref := range opt.Aux {
+				if (ref.Val == "value1" && ref.Type == influxql.Float) || (ref.Val == "value2" && ref.Type == influxql.String) {
+					aux[i] = ref.Type
+				}
+			}
+			return influxql.SeriesList{{Name: "cpu", Aux: aux}}, nil
+		}
 
 		// Rewrite statement.
 		rw, err := stmt.(*influxql.SelectStatement).RewriteWildcards(&ic)
@@ -497,6 +535,120 @@ func TestSelectStatement_RewriteTimeFields(t *testing.T) {
 	}
 }
 
+// Test SELECT statement histogram rewrite.
+func TestSelectStatement_RewriteHistograms(t *testing.T) {
+	var tests = []struct {
+		stmt    string
+		rewrite string
+	}{
+		{
+			stmt:    `SELECT count(value) FROM cpu`,
+			rewrite: `SELECT count(value) FROM cpu`,
+		},
+		{
+			stmt:    `SELECT histogram(value, 0, 10, 20) FROM cpu`,
+			rewrite: `SELECT histogram(value, 0, 10) AS histogram_0, histogram(value, 10, 20) AS histogram_10 FROM cpu`,
+		},
+		{
+			stmt:    `SELECT histogram(value, 0.5, 1, 1.5) AS h FROM cpu`,
+			rewrite: `SELECT histogram(value, 0.500, 1) AS "h_0.5", histogram(value, 1, 1.500) AS h_1 FROM cpu`,
+		},
+	}
+
+	for i, tt := range tests {
+		// Parse statement.
+		stmt, err := influxql.NewParser(strings.NewReader(tt.stmt)).ParseStatement()
+		if err != nil {
+			t.Fatalf("invalid statement: %q: %s", tt.stmt, err)
+		}
+
+		// Rewrite statement.
+		stmt.(*influxql.SelectStatement).RewriteHistograms()
+		if rw := stmt.String(); tt.rewrite != rw {
+			t.Errorf("%d. %q: unexpected rewrite:\n\nexp=%s\n\ngot=%s\n\n", i, tt.stmt, tt.rewrite, rw)
+		}
+	}
+}
+
+func TestSelectStatement_RewriteDimensionFilters(t *testing.T) {
+	var tests = []struct {
+		stmt    string
+		rewrite string
+	}{
+		{
+			stmt:    `SELECT count(value) FROM cpu GROUP BY host`,
+			rewrite: `SELECT count(value) FROM cpu GROUP BY host`,
+		},
+		{
+			stmt:    `SELECT count(value) FROM cpu GROUP BY host =~ /^server0[12]$/`,
+			rewrite: `SELECT count(value) FROM cpu WHERE host =~ /^server0[12]$/ GROUP BY host`,
+		},
+		{
+			stmt:    `SELECT count(value) FROM cpu WHERE region = 'uswest' OR region = 'useast' GROUP BY host !~ /^test/, region =~ /^us/`,
+			rewrite: `SELECT count(value) FROM cpu WHERE ((region = 'uswest' OR region = 'useast') AND host !~ /^test/) AND region =~ /^us/ GROUP BY host, region`,
+		},
+	}
+
+	for i, tt := range tests {
+		// Parse statement.
+		stmt, err := influxql.NewParser(strings.NewReader(tt.stmt)).ParseStatement()
+		if err != nil {
+			t.Fatalf("invalid statement: %q: %s", tt.stmt, err)
+		}
+
+		// Rewrite statement.
+		stmt.(*influxql.SelectStatement).RewriteDimensionFilters()
+		if rw := stmt.String(); tt.rewrite != rw {
+			t.Errorf("%d. %q: unexpected rewrite:\n\nexp=%s\n\ngot=%s\n\n", i, tt.stmt, tt.rewrite, rw)
+		}
+	}
+}
+
+// Ensure the HAVING clause is resolved to the columns of a statement.
+func TestSelectStatement_HavingCondition(t *testing.T) {
+	var tests = []struct {
+		stmt string
+		cond string
+	}{
+		{
+			stmt: `SELECT mean(value) FROM cpu GROUP BY host`,
+			cond: ``,
+		},
+		{
+			stmt: `SELECT mean(value) FROM cpu GROUP BY host HAVING mean(value) > 80`,
+			cond: `mean > 80`,
+		},
+		{
+			stmt: `SELECT mean(value) AS m, mean(value) * 2 FROM cpu HAVING (mean(value) * 2 > 80 OR m < 10)`,
+			cond: `(mean > 80 OR m < 10)`,
+		},
+		{
+			stmt: `SELECT top(value, host, 2), max(value) FROM cpu HAVING max(value) > 80 AND host = 'a'`,
+			cond: `max > 80 AND host = 'a'`,
+		},
+		{
+			stmt: `SELECT mean(value), mean(load) FROM cpu HAVING mean(load) > mean(value)`,
+			cond: `mean_1 > mean`,
+		},
+	}
+
+	for i, tt := range tests {
+		stmt, err := influxql.NewParser(strings.NewReader(tt.stmt)).ParseStatement()
+		if err != nil {
+			t.Fatalf("invalid statement: %q: %s", tt.stmt, err)
+		}
+
+		cond, err := stmt.(*influxql.SelectStatement).HavingCondition()
+		if err != nil {
+			t.Errorf("%d. %q: unexpected error: %s", i, tt.stmt, err)
+		} else if cond == nil && tt.cond != "" {
+			t.Errorf("%d. %q: expected condition %s", i, tt.stmt, tt.cond)
+		} else if cond != nil && cond.String() != tt.cond {
+			t.Errorf("%d. %q: unexpected condition:\n\nexp=%s\n\ngot=%s\n\n", i, tt.stmt, tt.cond, cond)
+		}
+	}
+}
+
 // Ensure that the IsRawQuery flag gets set properly
 func TestSelectStatement_IsRawQuerySet(t *testing.T) {
 	var tests = []struct {
@@ -986,6 +1138,21 @@ func TestEval(t *testing.T) {
 		{in: `foo <> 'bar'`, out: true, data: map[string]interface{}{"foo": "xxx"}},
 		{in: `foo =~ /b.*/`, out: true, data: map[string]interface{}{"foo": "bar"}},
 		{in: `foo !~ /b.*/`, out: false, data: map[string]interface{}{"foo": "bar"}},
+
+		// String functions.
+		{in: `concat(host, '-', region)`, out: "a-b", data: map[string]interface{}{"host": "a", "region": "b"}},
+		{in: `concat(host, ':', value)`, out: "a:1.5", data: map[string]interface{}{"host": "a", "value": float64(1.5)}},
+		{in: `concat(host, region)`, out: "a", data: map[string]interface{}{"host": "a"}},
+		{in: `concat(host, region)`, out: nil},
+		{in: `length(host)`, out: int64(5), data: map[string]interface{}{"host": "héllo"}},
+		{in: `length(value)`, out: nil, data: map[string]interface{}{"value": int64(1)}},
+		{in: `lower(host)`, out: "server", data: map[string]interface{}{"host": "SerVer"}},
+		{in: `upper(host)`, out: "SERVER", data: map[string]interface{}{"host": "SerVer"}},
+		{in: `substr(host, 2)`, out: "erver01", data: map[string]interface{}{"host": "server01"}},
+		{in: `substr(host, 1, 6)`, out: "server", data: map[string]interface{}{"host": "server01"}},
+		{in: `substr(host, 7, 10)`, out: "01", data: map[string]interface{}{"host": "server01"}},
+		{in: `substr(host, 10)`, out: "", data: map[string]interface{}{"host": "server01"}},
+		{in: `upper(substr(host, 1, 1))`, out: "S", data: map[string]interface{}{"host": "server01"}},
 	} {
 		// Evaluate expression.
 		out := influxql.Eval(MustParseExpr(tt.in), tt.data)
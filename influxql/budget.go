@@ -0,0 +1,73 @@
+package influxql
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// MemoryBudget tracks the memory used by the iterators of queries so a query
+// can be aborted before it exhausts the memory of the server.  A budget may
+// have a parent, such as a budget shared by all queries, and memory reserved
+// from a budget is also reserved from its parents.
+//
+// The memory used is an estimate.  It covers the points buffered by the
+// iterators but not the memory used by the storage engine to read them.
+//
+// A nil budget does not limit or track anything.
+type MemoryBudget struct {
+	used   int64 // accessed atomically
+	limit  int64
+	name   string
+	parent *MemoryBudget
+}
+
+// NewMemoryBudget returns a budget of limit bytes.  A limit of zero only
+// tracks the memory used.  The name is used in the error returned when the
+// limit is exceeded.
+func NewMemoryBudget(name string, limit int64) *MemoryBudget {
+	return &MemoryBudget{name: name, limit: limit}
+}
+
+// NewChild returns a budget of limit bytes whose reservations are also
+// reserved from b.
+func (b *MemoryBudget) NewChild(name string, limit int64) *MemoryBudget {
+	return &MemoryBudget{name: name, limit: limit, parent: b}
+}
+
+// Reserve reserves n bytes from b and its parents.  It returns an error and
+// reserves nothing if that would exceed the limit of any of them.
+func (b *MemoryBudget) Reserve(n int64) error {
+	for m := b; m != nil; m = m.parent {
+		if used := atomic.AddInt64(&m.used, n); m.limit > 0 && used > m.limit {
+			for u := b; u != m.parent; u = u.parent {
+				atomic.AddInt64(&u.used, -n)
+			}
+			return fmt.Errorf("%s limit exceeded: (%d/%d bytes)", m.name, used, m.limit)
+		}
+	}
+	return nil
+}
+
+// Release returns n reserved bytes to b and its parents.
+func (b *MemoryBudget) Release(n int64) {
+	for m := b; m != nil; m = m.parent {
+		atomic.AddInt64(&m.used, -n)
+	}
+}
+
+// Used returns the number of bytes reserved from b.
+func (b *MemoryBudget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.used)
+}
+
+// Close returns everything still reserved from b to its parents.  It is
+// called once the iterators using b have been closed.
+func (b *MemoryBudget) Close() {
+	if b == nil {
+		return
+	}
+	b.parent.Release(atomic.SwapInt64(&b.used, 0))
+}
@@ -0,0 +1,55 @@
+package influxql_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/influxdb/influxql"
+)
+
+// Ensure a budget reserves memory from its parent and rejects reservations
+// that exceed either limit.
+func TestMemoryBudget_Reserve(t *testing.T) {
+	global := influxql.NewMemoryBudget("global", 100)
+	a := global.NewChild("a", 60)
+	b := global.NewChild("b", 0)
+
+	if err := a.Reserve(50); err != nil {
+		t.Fatal(err)
+	} else if err := a.Reserve(20); err == nil || err.Error() != "a limit exceeded: (70/60 bytes)" {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := b.Reserve(60); err == nil || err.Error() != "global limit exceeded: (110/100 bytes)" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Failed reservations reserve nothing.
+	if a.Used() != 50 || b.Used() != 0 || global.Used() != 50 {
+		t.Fatalf("unexpected usage: a=%d b=%d global=%d", a.Used(), b.Used(), global.Used())
+	}
+
+	if err := b.Reserve(40); err != nil {
+		t.Fatal(err)
+	}
+	b.Release(10)
+	if b.Used() != 30 || global.Used() != 80 {
+		t.Fatalf("unexpected usage: b=%d global=%d", b.Used(), global.Used())
+	}
+
+	// Closing a budget returns what it still holds to its parent.
+	a.Close()
+	if a.Used() != 0 || global.Used() != 30 {
+		t.Fatalf("unexpected usage: a=%d global=%d", a.Used(), global.Used())
+	}
+}
+
+// Ensure a nil budget does not limit anything.
+func TestMemoryBudget_Nil(t *testing.T) {
+	var b *influxql.MemoryBudget
+	if err := b.Reserve(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+	b.Release(1 << 40)
+	b.Close()
+	if n := b.Used(); n != 0 {
+		t.Fatalf("unexpected usage: %d", n)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"time"
 )
 
 /*
@@ -458,6 +459,87 @@ func newMedianIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
 	}
 }
 
+// newHistogramIterator returns an iterator for operating on a histogram() call
+// that has been rewritten to a single bucket.
+func newHistogramIterator(input Iterator, opt IteratorOptions, lo, hi float64) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, IntegerPointEmitter) {
+			fn := NewFloatFuncIntegerReducer(NewFloatHistogramReduceFunc(lo, hi))
+			return fn, fn
+		}
+		return &floatReduceIntegerIterator{input: newBufFloatIterator(input), opt: opt, create: createFn}, nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, IntegerPointEmitter) {
+			fn := NewIntegerFuncReducer(NewIntegerHistogramReduceFunc(lo, hi))
+			return fn, fn
+		}
+		return &integerReduceIntegerIterator{input: newBufIntegerIterator(input), opt: opt, create: createFn}, nil
+	default:
+		return nil, fmt.Errorf("unsupported histogram iterator type: %T", input)
+	}
+}
+
+// NewFloatHistogramReduceFunc returns the count of points within [lo, hi).
+func NewFloatHistogramReduceFunc(lo, hi float64) FloatReduceIntegerFunc {
+	return func(prev *IntegerPoint, curr *FloatPoint) (int64, int64, []interface{}) {
+		var n int64
+		if prev != nil {
+			n = prev.Value
+		}
+		if curr.Value >= lo && curr.Value < hi {
+			n++
+		}
+		return ZeroTime, n, nil
+	}
+}
+
+// NewIntegerHistogramReduceFunc returns the count of points within [lo, hi).
+func NewIntegerHistogramReduceFunc(lo, hi float64) IntegerReduceFunc {
+	return func(prev, curr *IntegerPoint) (int64, int64, []interface{}) {
+		var n int64
+		if prev != nil {
+			n = prev.Value
+		}
+		if v := float64(curr.Value); v >= lo && v < hi {
+			n++
+		}
+		return ZeroTime, n, nil
+	}
+}
+
+// newModeIterator returns an iterator for operating on a mode() call.
+func newModeIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatModeReducer()
+			return fn, fn
+		}
+		return &floatReduceFloatIterator{input: newBufFloatIterator(input), opt: opt, create: createFn}, nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, IntegerPointEmitter) {
+			fn := NewIntegerModeReducer()
+			return fn, fn
+		}
+		return &integerReduceIntegerIterator{input: newBufIntegerIterator(input), opt: opt, create: createFn}, nil
+	case StringIterator:
+		createFn := func() (StringPointAggregator, StringPointEmitter) {
+			fn := NewStringModeReducer()
+			return fn, fn
+		}
+		return &stringReduceStringIterator{input: newBufStringIterator(input), opt: opt, create: createFn}, nil
+	case BooleanIterator:
+		createFn := func() (BooleanPointAggregator, BooleanPointEmitter) {
+			fn := NewBooleanModeReducer()
+			return fn, fn
+		}
+		return &booleanReduceBooleanIterator{input: newBufBooleanIterator(input), opt: opt, create: createFn}, nil
+	default:
+		return nil, fmt.Errorf("unsupported mode iterator type: %T", input)
+	}
+}
+
 // FloatMedianReduceSlice returns the median value within a window.
 func FloatMedianReduceSlice(a []FloatPoint) []FloatPoint {
 	if len(a) == 1 {
@@ -937,6 +1019,66 @@ func newPercentileIterator(input Iterator, opt IteratorOptions, percentile float
 	}
 }
 
+// newInterpolatedPercentileIterator returns an iterator for operating on an interpolated_percentile() call.
+func newInterpolatedPercentileIterator(input Iterator, opt IteratorOptions, percentile float64) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		floatInterpolatedPercentileReduceSlice := NewFloatInterpolatedPercentileReduceSliceFunc(percentile)
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatSliceFuncReducer(floatInterpolatedPercentileReduceSlice)
+			return fn, fn
+		}
+		return &floatReduceFloatIterator{input: newBufFloatIterator(input), opt: opt, create: createFn}, nil
+	case IntegerIterator:
+		integerInterpolatedPercentileReduceSlice := NewIntegerInterpolatedPercentileReduceSliceFunc(percentile)
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := NewIntegerSliceFuncFloatReducer(integerInterpolatedPercentileReduceSlice)
+			return fn, fn
+		}
+		return &integerReduceFloatIterator{input: newBufIntegerIterator(input), opt: opt, create: createFn}, nil
+	default:
+		return nil, fmt.Errorf("unsupported interpolated percentile iterator type: %T", input)
+	}
+}
+
+// NewFloatInterpolatedPercentileReduceSliceFunc returns the percentile value
+// within a window, interpolating linearly between the two closest ranks.
+func NewFloatInterpolatedPercentileReduceSliceFunc(percentile float64) FloatReduceSliceFunc {
+	return func(a []FloatPoint) []FloatPoint {
+		if len(a) == 0 || percentile < 0 || percentile > 100 {
+			return nil
+		}
+
+		sort.Sort(floatPointsByValue(a))
+		rank := percentile / 100 * float64(len(a)-1)
+		i := int(math.Floor(rank))
+		if i == len(a)-1 {
+			return []FloatPoint{{Time: ZeroTime, Value: a[i].Value}}
+		}
+		value := a[i].Value + (rank-float64(i))*(a[i+1].Value-a[i].Value)
+		return []FloatPoint{{Time: ZeroTime, Value: value}}
+	}
+}
+
+// NewIntegerInterpolatedPercentileReduceSliceFunc returns the percentile value
+// within a window, interpolating linearly between the two closest ranks.
+func NewIntegerInterpolatedPercentileReduceSliceFunc(percentile float64) IntegerReduceFloatSliceFunc {
+	return func(a []IntegerPoint) []FloatPoint {
+		if len(a) == 0 || percentile < 0 || percentile > 100 {
+			return nil
+		}
+
+		sort.Sort(integerPointsByValue(a))
+		rank := percentile / 100 * float64(len(a)-1)
+		i := int(math.Floor(rank))
+		if i == len(a)-1 {
+			return []FloatPoint{{Time: ZeroTime, Value: float64(a[i].Value)}}
+		}
+		value := float64(a[i].Value) + (rank-float64(i))*float64(a[i+1].Value-a[i].Value)
+		return []FloatPoint{{Time: ZeroTime, Value: value}}
+	}
+}
+
 // NewFloatPercentileReduceSliceFunc returns the percentile value within a window.
 func NewFloatPercentileReduceSliceFunc(percentile float64) FloatReduceSliceFunc {
 	return func(a []FloatPoint) []FloatPoint {
@@ -967,18 +1109,97 @@ func NewIntegerPercentileReduceSliceFunc(percentile float64) IntegerReduceSliceF
 	}
 }
 
+// newSampleIterator returns an iterator for operating on a sample() call.
+func newSampleIterator(input Iterator, opt IteratorOptions, size int) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatSampleReducer(size)
+			return fn, fn
+		}
+		return &floatReduceFloatIterator{input: newBufFloatIterator(input), opt: opt, create: createFn}, nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, IntegerPointEmitter) {
+			fn := NewIntegerSampleReducer(size)
+			return fn, fn
+		}
+		return &integerReduceIntegerIterator{input: newBufIntegerIterator(input), opt: opt, create: createFn}, nil
+	case StringIterator:
+		createFn := func() (StringPointAggregator, StringPointEmitter) {
+			fn := NewStringSampleReducer(size)
+			return fn, fn
+		}
+		return &stringReduceStringIterator{input: newBufStringIterator(input), opt: opt, create: createFn}, nil
+	case BooleanIterator:
+		createFn := func() (BooleanPointAggregator, BooleanPointEmitter) {
+			fn := NewBooleanSampleReducer(size)
+			return fn, fn
+		}
+		return &booleanReduceBooleanIterator{input: newBufBooleanIterator(input), opt: opt, create: createFn}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sample iterator type: %T", input)
+	}
+}
+
+// newIntegralIterator returns an iterator for operating on an integral() call.
+func newIntegralIterator(input Iterator, opt IteratorOptions, interval Interval) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatIntegralReducer(interval)
+			return fn, fn
+		}
+		return &floatReduceFloatIterator{input: newBufFloatIterator(input), opt: opt, create: createFn}, nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := NewIntegerIntegralReducer(interval)
+			return fn, fn
+		}
+		return &integerReduceFloatIterator{input: newBufIntegerIterator(input), opt: opt, create: createFn}, nil
+	default:
+		return nil, fmt.Errorf("unsupported integral iterator type: %T", input)
+	}
+}
+
+// newRateIterator returns an iterator for operating on a rate() call.
+func newRateIterator(input Iterator, opt IteratorOptions, interval Interval) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatRateReducer(interval)
+			return fn, fn
+		}
+		return &floatReduceFloatIterator{input: newBufFloatIterator(input), opt: opt, create: createFn}, nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := NewIntegerRateReducer(interval)
+			return fn, fn
+		}
+		return &integerReduceFloatIterator{input: newBufIntegerIterator(input), opt: opt, create: createFn}, nil
+	default:
+		return nil, fmt.Errorf("unsupported rate iterator type: %T", input)
+	}
+}
+
 // newDerivativeIterator returns an iterator for operating on a derivative() call.
-func newDerivativeIterator(input Iterator, opt IteratorOptions, interval Interval, isNonNegative bool) (Iterator, error) {
+// A gap is a time between points longer than the unit of the derivative, or
+// than the GROUP BY interval if it is longer.
+func newDerivativeIterator(input Iterator, opt IteratorOptions, interval Interval, isNonNegative bool, gapPolicy DerivativeGapPolicy) (Iterator, error) {
+	maxGap := interval.Duration
+	if opt.Interval.Duration > maxGap {
+		maxGap = opt.Interval.Duration
+	}
+
 	switch input := input.(type) {
 	case FloatIterator:
 		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
-			fn := NewFloatDerivativeReducer(interval, isNonNegative, opt.Ascending)
+			fn := NewFloatDerivativeReducer(interval, isNonNegative, opt.Ascending, gapPolicy, maxGap)
 			return fn, fn
 		}
 		return newFloatStreamFloatIterator(input, createFn, opt), nil
 	case IntegerIterator:
 		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
-			fn := NewIntegerDerivativeReducer(interval, isNonNegative, opt.Ascending)
+			fn := NewIntegerDerivativeReducer(interval, isNonNegative, opt.Ascending, gapPolicy, maxGap)
 			return fn, fn
 		}
 		return newIntegerStreamFloatIterator(input, createFn, opt), nil
@@ -988,17 +1209,17 @@ func newDerivativeIterator(input Iterator, opt IteratorOptions, interval Interva
 }
 
 // newDifferenceIterator returns an iterator for operating on a difference() call.
-func newDifferenceIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
+func newDifferenceIterator(input Iterator, opt IteratorOptions, isNonNegative bool) (Iterator, error) {
 	switch input := input.(type) {
 	case FloatIterator:
 		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
-			fn := NewFloatDifferenceReducer()
+			fn := NewFloatDifferenceReducer(isNonNegative)
 			return fn, fn
 		}
 		return newFloatStreamFloatIterator(input, createFn, opt), nil
 	case IntegerIterator:
 		createFn := func() (IntegerPointAggregator, IntegerPointEmitter) {
-			fn := NewIntegerDifferenceReducer()
+			fn := NewIntegerDifferenceReducer(isNonNegative)
 			return fn, fn
 		}
 		return newIntegerStreamIntegerIterator(input, createFn, opt), nil
@@ -1007,6 +1228,26 @@ func newDifferenceIterator(input Iterator, opt IteratorOptions) (Iterator, error
 	}
 }
 
+// newCumulativeSumIterator returns an iterator for operating on a cumulative_sum() call.
+func newCumulativeSumIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatCumulativeSumReducer()
+			return fn, fn
+		}
+		return newFloatStreamFloatIterator(input, createFn, opt), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, IntegerPointEmitter) {
+			fn := NewIntegerCumulativeSumReducer()
+			return fn, fn
+		}
+		return newIntegerStreamIntegerIterator(input, createFn, opt), nil
+	default:
+		return nil, fmt.Errorf("unsupported cumulative sum iterator type: %T", input)
+	}
+}
+
 // newElapsedIterator returns an iterator for operating on a elapsed() call.
 func newElapsedIterator(input Iterator, opt IteratorOptions, interval Interval) (Iterator, error) {
 	switch input := input.(type) {
@@ -1039,6 +1280,66 @@ func newElapsedIterator(input Iterator, opt IteratorOptions, interval Interval)
 	}
 }
 
+// newHoltWintersIterator returns an iterator for operating on a holt_winters() call.
+func newHoltWintersIterator(input Iterator, opt IteratorOptions, h, m int, includeFitData bool, interval time.Duration) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatHoltWintersReducer(h, m, includeFitData, interval, opt.Ascending)
+			return fn, fn
+		}
+		return &floatReduceFloatIterator{input: newBufFloatIterator(input), opt: opt, create: createFn}, nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := NewFloatHoltWintersReducer(h, m, includeFitData, interval, opt.Ascending)
+			return fn, fn
+		}
+		return &integerReduceFloatIterator{input: newBufIntegerIterator(input), opt: opt, create: createFn}, nil
+	default:
+		return nil, fmt.Errorf("unsupported holt winters iterator type: %T", input)
+	}
+}
+
+// newExponentialMovingAverageIterator returns an iterator for operating on an
+// exponential_moving_average(), double_exponential_moving_average() or
+// kaufmans_adaptive_moving_average() call.
+func newExponentialMovingAverageIterator(input Iterator, name string, n int, opt IteratorOptions) (Iterator, error) {
+	type reducer interface {
+		FloatPointAggregator
+		IntegerPointAggregator
+		FloatPointEmitter
+	}
+
+	var newReducer func() reducer
+	switch name {
+	case "exponential_moving_average":
+		newReducer = func() reducer { return NewFloatExponentialMovingAverageReducer(n) }
+	case "double_exponential_moving_average":
+		newReducer = func() reducer { return NewFloatDoubleExponentialMovingAverageReducer(n) }
+	case "kaufmans_adaptive_moving_average":
+		newReducer = func() reducer { return NewFloatKaufmansAdaptiveMovingAverageReducer(n) }
+	default:
+		return nil, fmt.Errorf("unsupported moving average function: %s", name)
+	}
+
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := newReducer()
+			return fn, fn
+		}
+		return newFloatStreamFloatIterator(input, createFn, opt), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := newReducer()
+			return fn, fn
+		}
+		return newIntegerStreamFloatIterator(input, createFn, opt), nil
+	default:
+		return nil, fmt.Errorf("unsupported %s iterator type: %T", name, input)
+	}
+}
+
 // newMovingAverageIterator returns an iterator for operating on a moving_average() call.
 func newMovingAverageIterator(input Iterator, n int, opt IteratorOptions) (Iterator, error) {
 	switch input := input.(type) {
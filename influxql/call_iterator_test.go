@@ -672,6 +672,26 @@ func TestNewCallIterator_UnsupportedExprName(t *testing.T) {
 	}
 }
 
+// Ensure the sample reducer selects a subset of the points sorted by time.
+func TestFloatSampleReducer(t *testing.T) {
+	r := influxql.NewFloatSampleReducer(3)
+	for i := 0; i < 100; i++ {
+		r.AggregateFloat(&influxql.FloatPoint{Time: int64(i), Value: float64(i)})
+	}
+
+	points := r.Emit()
+	if len(points) != 3 {
+		t.Fatalf("unexpected number of points: %d", len(points))
+	}
+	for i, p := range points {
+		if p.Value != float64(p.Time) {
+			t.Fatalf("unexpected point: %v", p)
+		} else if i > 0 && p.Time <= points[i-1].Time {
+			t.Fatalf("points not sorted by time: %v", points)
+		}
+	}
+}
+
 func BenchmarkCountIterator_1K(b *testing.B)   { benchmarkCountIterator(b, 1000) }
 func BenchmarkCountIterator_100K(b *testing.B) { benchmarkCountIterator(b, 100000) }
 func BenchmarkCountIterator_1M(b *testing.B)   { benchmarkCountIterator(b, 1000000) }
@@ -23,6 +23,13 @@ type Emitter struct {
 	// Removes the "time" column from output.
 	// Used for meta queries where time does not apply.
 	OmitTime bool
+
+	// The time zone to return times in. Defaults to UTC if not set.
+	Location *time.Location
+
+	// An optional condition that rows must match to be emitted. It is
+	// evaluated against the values of each row by column name.
+	Condition Expr
 }
 
 // NewEmitter returns a new instance of Emitter that pulls from itrs.
@@ -68,6 +75,11 @@ func (e *Emitter) Emit() (*models.Row, error) {
 			return row, nil
 		}
 
+		// Drop values that do not match the condition.
+		if e.Condition != nil && !e.match(values) {
+			continue
+		}
+
 		// If there's no row yet then create one.
 		// If the name and tags match the existing row, append to that row if
 		// the number of values doesn't exceed the chunk size.
@@ -78,6 +90,7 @@ func (e *Emitter) Emit() (*models.Row, error) {
 			e.row.Values = append(e.row.Values, values)
 		} else {
 			row := e.row
+			row.Partial = row.Name == name && e.tags.Equals(&tags)
 			e.createRow(name, tags, values)
 			return row, nil
 		}
@@ -139,6 +152,15 @@ func (e *Emitter) createRow(name string, tags Tags, values []interface{}) {
 	}
 }
 
+// match returns true if the column values match the condition.
+func (e *Emitter) match(values []interface{}) bool {
+	m := make(map[string]interface{}, len(e.Columns))
+	for i, name := range e.Columns {
+		m[name] = values[i]
+	}
+	return EvalBool(e.Condition, m)
+}
+
 // readAt returns the next slice of values from the iterators at time/name/tags.
 // Returns nil values once the iterators are exhausted.
 func (e *Emitter) readAt(t int64, name string, tags Tags) []interface{} {
@@ -150,7 +172,11 @@ func (e *Emitter) readAt(t int64, name string, tags Tags) []interface{} {
 
 	values := make([]interface{}, len(e.itrs)+offset)
 	if !e.OmitTime {
-		values[0] = time.Unix(0, t).UTC()
+		if e.Location != nil {
+			values[0] = time.Unix(0, t).In(e.Location)
+		} else {
+			values[0] = time.Unix(0, t).UTC()
+		}
 	}
 
 	for i, p := range e.buf {
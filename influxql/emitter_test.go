@@ -97,11 +97,12 @@ func TestEmitter_ChunkSize(t *testing.T) {
 		Values: [][]interface{}{
 			{time.Unix(0, 0).UTC(), float64(1)},
 		},
+		Partial: true,
 	}) {
 		t.Fatalf("unexpected row(0): %s", spew.Sdump(row))
 	}
 
-	// Verify the cpu region=north is emitted next.
+	// Verify the rest of cpu region=west is emitted next.
 	if row, err := e.Emit(); err != nil {
 		t.Fatalf("unexpected error(1): %s", err)
 	} else if !deep.Equal(row, &models.Row{
@@ -122,3 +123,37 @@ func TestEmitter_ChunkSize(t *testing.T) {
 		t.Fatalf("unexpected eof: %s", spew.Sdump(row))
 	}
 }
+
+// Ensure the emitter only emits the rows that match its condition.
+func TestEmitter_Condition(t *testing.T) {
+	e := influxql.NewEmitter([]influxql.Iterator{
+		&FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 0, Value: 90},
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 10, Value: 70},
+			{Name: "cpu", Tags: ParseTags("host=B"), Time: 0, Value: 60},
+		}},
+	}, true, 0)
+	e.Columns = []string{"time", "mean"}
+	e.Condition = MustParseExpr(`mean > 80`)
+
+	// Verify only the first row of host=A is emitted.
+	if row, err := e.Emit(); err != nil {
+		t.Fatalf("unexpected error(0): %s", err)
+	} else if !deep.Equal(row, &models.Row{
+		Name:    "cpu",
+		Tags:    map[string]string{"host": "A"},
+		Columns: []string{"time", "mean"},
+		Values: [][]interface{}{
+			{time.Unix(0, 0).UTC(), float64(90)},
+		},
+	}) {
+		t.Fatalf("unexpected row(0): %s", spew.Sdump(row))
+	}
+
+	// Verify host=B is skipped entirely.
+	if row, err := e.Emit(); err != nil {
+		t.Fatalf("unexpected error(eof): %s", err)
+	} else if row != nil {
+		t.Fatalf("unexpected eof: %s", spew.Sdump(row))
+	}
+}
@@ -0,0 +1,350 @@
+package influxql
+
+import (
+	"fmt"
+)
+
+// buildEvalIterator creates an iterator that evaluates expr for each point,
+// such as a CASE expression or a string function.
+//
+// Every field and call referenced by the expression, other than the string
+// functions, is read from its own iterator.  The points of those iterators
+// with the same series and time are combined and the expression is evaluated
+// against their values.
+func buildEvalIterator(expr Expr, ic IteratorCreator, opt IteratorOptions) (Iterator, error) {
+	// Collect the operands and refer to each of them by its string in a
+	// copy of the expression.
+	expr = CloneExpr(expr)
+	v := evalOperandVisitor{refs: make(map[Expr]string), seen: make(map[string]struct{})}
+	Walk(&v, expr)
+	if len(v.operands) == 0 {
+		return nil, fmt.Errorf("%s must reference a field or an aggregate", evalExprName(expr))
+	}
+
+	itr := &evalIterator{
+		names:  make([]string, len(v.operands)),
+		inputs: make([]Iterator, 0, len(v.operands)),
+		buf:    make([]Point, len(v.operands)),
+		opt:    opt,
+	}
+	types := make(map[string]DataType, len(v.operands))
+	for i, operand := range v.operands {
+		input, err := buildExprIterator(operand, ic, opt, false)
+		if err != nil {
+			Iterators(itr.inputs).Close()
+			return nil, err
+		}
+		itr.names[i] = v.refs[operand]
+		itr.inputs = append(itr.inputs, input)
+		types[itr.names[i]] = iteratorDataType(input)
+	}
+
+	itr.expr = RewriteExpr(expr, func(e Expr) Expr {
+		if name, ok := v.refs[e]; ok {
+			return &VarRef{Val: name}
+		}
+		return e
+	})
+
+	typ, err := evalExprType(itr.expr, types)
+	if err != nil {
+		itr.Close()
+		return nil, err
+	}
+
+	switch typ {
+	case Integer:
+		return &integerEvalIterator{itr}, nil
+	case String:
+		return &stringEvalIterator{itr}, nil
+	case Boolean:
+		return &booleanEvalIterator{itr}, nil
+	default:
+		return &floatEvalIterator{itr}, nil
+	}
+}
+
+// evalExprName returns the name of an evaluated expression for errors.
+func evalExprName(expr Expr) string {
+	if call, ok := expr.(*Call); ok {
+		return call.Name + "()"
+	}
+	return "case expression"
+}
+
+// evalOperandVisitor collects the fields and calls of an expression without
+// descending into the arguments of the calls other than string functions.
+// Operands with the same string are read once.
+type evalOperandVisitor struct {
+	operands []Expr
+	refs     map[Expr]string
+	seen     map[string]struct{}
+}
+
+func (v *evalOperandVisitor) Visit(n Node) Visitor {
+	switch n := n.(type) {
+	case *Call:
+		if isStringFunction(n.Name) {
+			return v
+		}
+		v.add(n)
+		return nil
+	case *VarRef:
+		v.add(n)
+		return nil
+	}
+	return v
+}
+
+func (v *evalOperandVisitor) add(expr Expr) {
+	name := expr.String()
+	v.refs[expr] = name
+	if _, ok := v.seen[name]; !ok {
+		v.seen[name] = struct{}{}
+		v.operands = append(v.operands, expr)
+	}
+}
+
+// caseResultType returns the type of the values returned by expr.  Integer
+// and float results are returned as floats.  The results of any other
+// different types cannot be combined.
+func caseResultType(expr *CaseExpr, types map[string]DataType) (DataType, error) {
+	results := make([]Expr, 0, len(expr.WhenClauses)+1)
+	for _, w := range expr.WhenClauses {
+		results = append(results, w.Result)
+	}
+	if expr.Else != nil {
+		results = append(results, expr.Else)
+	}
+
+	typ := Unknown
+	for _, result := range results {
+		t, err := evalExprType(result, types)
+		if err != nil {
+			return Unknown, err
+		}
+
+		switch {
+		case t == Unknown || t == typ:
+		case typ == Unknown:
+			typ = t
+		case (typ == Float && t == Integer) || (typ == Integer && t == Float):
+			typ = Float
+		default:
+			return Unknown, fmt.Errorf("case expression results have mismatched types: %s and %s", typ, t)
+		}
+	}
+	if typ == Unknown {
+		typ = Float
+	}
+	return typ, nil
+}
+
+// evalExprType returns the type of the value of an evaluated expression.
+func evalExprType(expr Expr, types map[string]DataType) (DataType, error) {
+	switch expr := expr.(type) {
+	case *VarRef:
+		return types[expr.Val], nil
+	case Literal:
+		return literalDataType(expr), nil
+	case *ParenExpr:
+		return evalExprType(expr.Expr, types)
+	case *CaseExpr:
+		return caseResultType(expr, types)
+	case *Call:
+		if expr.Name == "length" {
+			return Integer, nil
+		}
+		return String, nil
+	case *BinaryExpr:
+		switch expr.Op {
+		case ADD, SUB, MUL, DIV:
+			lhs, err := evalExprType(expr.LHS, types)
+			if err != nil {
+				return Unknown, err
+			}
+			rhs, err := evalExprType(expr.RHS, types)
+			if err != nil {
+				return Unknown, err
+			}
+			if lhs == Integer && rhs == Integer {
+				return Integer, nil
+			}
+			return Float, nil
+		default:
+			return Boolean, nil
+		}
+	default:
+		return Unknown, nil
+	}
+}
+
+// evalIterator evaluates an expression against the points of its operands
+// with the same series and time.
+type evalIterator struct {
+	expr   Expr
+	names  []string
+	inputs []Iterator
+	buf    []Point
+	opt    IteratorOptions
+}
+
+// Stats returns the aggregated stats of the operand iterators.
+func (itr *evalIterator) Stats() IteratorStats {
+	var stats IteratorStats
+	for _, input := range itr.inputs {
+		stats.Add(input.Stats())
+	}
+	return stats
+}
+
+// Close closes the operand iterators.
+func (itr *evalIterator) Close() error {
+	return Iterators(itr.inputs).Close()
+}
+
+// next returns the earliest point of the operands along with the result of
+// the expression for it.  Returns a nil point once the operands are done.
+func (itr *evalIterator) next() (Point, interface{}, error) {
+	for i, input := range itr.inputs {
+		if itr.buf[i] != nil {
+			continue
+		}
+		p, err := readPoint(input)
+		if err != nil {
+			return nil, nil, err
+		}
+		itr.buf[i] = p
+	}
+
+	var head Point
+	for _, p := range itr.buf {
+		if p == nil {
+			continue
+		} else if head == nil || comparePoints(p.name(), p.tags(), p.time(), head.name(), head.tags(), head.time(), itr.opt.Ascending) < 0 {
+			head = p
+		}
+	}
+	if head == nil {
+		return nil, nil, nil
+	}
+
+	// Operands without a point for the series and time are missing from the
+	// values and evaluate as nil.
+	m := make(map[string]interface{}, len(itr.names))
+	for i, p := range itr.buf {
+		if p == nil || comparePoints(p.name(), p.tags(), p.time(), head.name(), head.tags(), head.time(), itr.opt.Ascending) != 0 {
+			continue
+		}
+		m[itr.names[i]] = p.value()
+		itr.buf[i] = nil
+	}
+	return head, Eval(itr.expr, m), nil
+}
+
+// readPoint reads the next point from itr.  Returns nil once itr is done.
+func readPoint(itr Iterator) (Point, error) {
+	switch itr := itr.(type) {
+	case FloatIterator:
+		p, err := itr.Next()
+		if p == nil || err != nil {
+			return nil, err
+		}
+		return p, nil
+	case IntegerIterator:
+		p, err := itr.Next()
+		if p == nil || err != nil {
+			return nil, err
+		}
+		return p, nil
+	case StringIterator:
+		p, err := itr.Next()
+		if p == nil || err != nil {
+			return nil, err
+		}
+		return p, nil
+	case BooleanIterator:
+		p, err := itr.Next()
+		if p == nil || err != nil {
+			return nil, err
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unsupported iterator: %T", itr)
+	}
+}
+
+// floatEvalIterator returns the float results of an evaluated expression.
+type floatEvalIterator struct{ *evalIterator }
+
+func (itr *floatEvalIterator) Next() (*FloatPoint, error) {
+	p, v, err := itr.next()
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	out := &FloatPoint{Name: p.name(), Tags: p.tags(), Time: p.time()}
+	switch v := v.(type) {
+	case float64:
+		out.Value = v
+	case int64:
+		out.Value = float64(v)
+	default:
+		out.Nil = true
+	}
+	return out, nil
+}
+
+// integerEvalIterator returns the integer results of an evaluated expression.
+type integerEvalIterator struct{ *evalIterator }
+
+func (itr *integerEvalIterator) Next() (*IntegerPoint, error) {
+	p, v, err := itr.next()
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	out := &IntegerPoint{Name: p.name(), Tags: p.tags(), Time: p.time()}
+	if v, ok := v.(int64); ok {
+		out.Value = v
+	} else {
+		out.Nil = true
+	}
+	return out, nil
+}
+
+// stringEvalIterator returns the string results of an evaluated expression.
+type stringEvalIterator struct{ *evalIterator }
+
+func (itr *stringEvalIterator) Next() (*StringPoint, error) {
+	p, v, err := itr.next()
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	out := &StringPoint{Name: p.name(), Tags: p.tags(), Time: p.time()}
+	if v, ok := v.(string); ok {
+		out.Value = v
+	} else {
+		out.Nil = true
+	}
+	return out, nil
+}
+
+// booleanEvalIterator returns the boolean results of an evaluated expression.
+type booleanEvalIterator struct{ *evalIterator }
+
+func (itr *booleanEvalIterator) Next() (*BooleanPoint, error) {
+	p, v, err := itr.next()
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	out := &BooleanPoint{Name: p.name(), Tags: p.tags(), Time: p.time()}
+	if v, ok := v.(bool); ok {
+		out.Value = v
+	} else {
+		out.Nil = true
+	}
+	return out, nil
+}
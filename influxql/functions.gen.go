@@ -6,7 +6,11 @@
 
 package influxql
 
-import "sort"
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
 
 // FloatPointAggregator aggregates points to produce a single point.
 type FloatPointAggregator interface {
@@ -88,6 +92,9 @@ func (r *FloatSliceFuncReducer) AggregateFloatBulk(points []FloatPoint) {
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatSliceFuncReducer) bufferedN() int { return len(r.points) }
+
 func (r *FloatSliceFuncReducer) Emit() []FloatPoint {
 	return r.fn(r.points)
 }
@@ -143,6 +150,9 @@ func (r *FloatSliceFuncIntegerReducer) AggregateFloatBulk(points []FloatPoint) {
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatSliceFuncIntegerReducer) bufferedN() int { return len(r.points) }
+
 func (r *FloatSliceFuncIntegerReducer) Emit() []IntegerPoint {
 	return r.fn(r.points)
 }
@@ -198,6 +208,9 @@ func (r *FloatSliceFuncStringReducer) AggregateFloatBulk(points []FloatPoint) {
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatSliceFuncStringReducer) bufferedN() int { return len(r.points) }
+
 func (r *FloatSliceFuncStringReducer) Emit() []StringPoint {
 	return r.fn(r.points)
 }
@@ -253,6 +266,9 @@ func (r *FloatSliceFuncBooleanReducer) AggregateFloatBulk(points []FloatPoint) {
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatSliceFuncBooleanReducer) bufferedN() int { return len(r.points) }
+
 func (r *FloatSliceFuncBooleanReducer) Emit() []BooleanPoint {
 	return r.fn(r.points)
 }
@@ -274,6 +290,9 @@ func (r *FloatDistinctReducer) AggregateFloat(p *FloatPoint) {
 	}
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatDistinctReducer) bufferedN() int { return len(r.m) }
+
 // Emit emits the distinct points that have been aggregated into the reducer.
 func (r *FloatDistinctReducer) Emit() []FloatPoint {
 	points := make([]FloatPoint, 0, len(r.m))
@@ -284,6 +303,51 @@ func (r *FloatDistinctReducer) Emit() []FloatPoint {
 	return points
 }
 
+// FloatModeReducer returns the most frequent value of the aggregated points.
+type FloatModeReducer struct {
+	m map[float64]*modeCount
+}
+
+// NewFloatModeReducer creates a new FloatModeReducer.
+func NewFloatModeReducer() *FloatModeReducer {
+	return &FloatModeReducer{m: make(map[float64]*modeCount)}
+}
+
+// AggregateFloat aggregates a point into the reducer.
+func (r *FloatModeReducer) AggregateFloat(p *FloatPoint) {
+	c := r.m[p.Value]
+	if c == nil {
+		c = &modeCount{time: p.Time}
+		r.m[p.Value] = c
+	} else if p.Time < c.time {
+		c.time = p.Time
+	}
+	c.n++
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatModeReducer) bufferedN() int { return len(r.m) }
+
+// Emit emits the most frequent value as a single point. Ties are broken by
+// the value that was seen first.
+func (r *FloatModeReducer) Emit() []FloatPoint {
+	var (
+		mode float64
+		best *modeCount
+	)
+	for v, c := range r.m {
+		if best == nil || c.n > best.n || (c.n == best.n && c.time < best.time) {
+			mode, best = v, c
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return []FloatPoint{
+		{Time: ZeroTime, Value: mode},
+	}
+}
+
 // FloatElapsedReducer calculates the elapsed of the aggregated points.
 type FloatElapsedReducer struct {
 	unitConversion int64
@@ -317,6 +381,49 @@ func (r *FloatElapsedReducer) Emit() []IntegerPoint {
 	return nil
 }
 
+// FloatSampleReducer selects a random sample of the aggregated points
+// using reservoir sampling, so only the sample is held in memory.
+type FloatSampleReducer struct {
+	count  int
+	rng    *rand.Rand
+	points []FloatPoint
+}
+
+// NewFloatSampleReducer creates a new FloatSampleReducer that
+// selects at most size points.
+func NewFloatSampleReducer(size int) *FloatSampleReducer {
+	return &FloatSampleReducer{
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		points: make([]FloatPoint, 0, size),
+	}
+}
+
+// AggregateFloat aggregates a point into the reducer.
+func (r *FloatSampleReducer) AggregateFloat(p *FloatPoint) {
+	r.count++
+
+	// Fill the reservoir with the first points.
+	if len(r.points) < cap(r.points) {
+		r.points = append(r.points, *p)
+		return
+	}
+
+	// Replace a random point in the reservoir so every point aggregated so
+	// far has the same chance of being in the sample.
+	if i := r.rng.Intn(r.count); i < len(r.points) {
+		r.points[i] = *p
+	}
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatSampleReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the sampled points sorted by time.
+func (r *FloatSampleReducer) Emit() []FloatPoint {
+	sort.Stable(floatPointsByTime(r.points))
+	return r.points
+}
+
 // IntegerPointAggregator aggregates points to produce a single point.
 type IntegerPointAggregator interface {
 	AggregateInteger(p *IntegerPoint)
@@ -397,6 +504,9 @@ func (r *IntegerSliceFuncFloatReducer) AggregateIntegerBulk(points []IntegerPoin
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerSliceFuncFloatReducer) bufferedN() int { return len(r.points) }
+
 func (r *IntegerSliceFuncFloatReducer) Emit() []FloatPoint {
 	return r.fn(r.points)
 }
@@ -452,6 +562,9 @@ func (r *IntegerSliceFuncReducer) AggregateIntegerBulk(points []IntegerPoint) {
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerSliceFuncReducer) bufferedN() int { return len(r.points) }
+
 func (r *IntegerSliceFuncReducer) Emit() []IntegerPoint {
 	return r.fn(r.points)
 }
@@ -507,6 +620,9 @@ func (r *IntegerSliceFuncStringReducer) AggregateIntegerBulk(points []IntegerPoi
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerSliceFuncStringReducer) bufferedN() int { return len(r.points) }
+
 func (r *IntegerSliceFuncStringReducer) Emit() []StringPoint {
 	return r.fn(r.points)
 }
@@ -562,6 +678,9 @@ func (r *IntegerSliceFuncBooleanReducer) AggregateIntegerBulk(points []IntegerPo
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerSliceFuncBooleanReducer) bufferedN() int { return len(r.points) }
+
 func (r *IntegerSliceFuncBooleanReducer) Emit() []BooleanPoint {
 	return r.fn(r.points)
 }
@@ -583,6 +702,9 @@ func (r *IntegerDistinctReducer) AggregateInteger(p *IntegerPoint) {
 	}
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerDistinctReducer) bufferedN() int { return len(r.m) }
+
 // Emit emits the distinct points that have been aggregated into the reducer.
 func (r *IntegerDistinctReducer) Emit() []IntegerPoint {
 	points := make([]IntegerPoint, 0, len(r.m))
@@ -593,6 +715,51 @@ func (r *IntegerDistinctReducer) Emit() []IntegerPoint {
 	return points
 }
 
+// IntegerModeReducer returns the most frequent value of the aggregated points.
+type IntegerModeReducer struct {
+	m map[int64]*modeCount
+}
+
+// NewIntegerModeReducer creates a new IntegerModeReducer.
+func NewIntegerModeReducer() *IntegerModeReducer {
+	return &IntegerModeReducer{m: make(map[int64]*modeCount)}
+}
+
+// AggregateInteger aggregates a point into the reducer.
+func (r *IntegerModeReducer) AggregateInteger(p *IntegerPoint) {
+	c := r.m[p.Value]
+	if c == nil {
+		c = &modeCount{time: p.Time}
+		r.m[p.Value] = c
+	} else if p.Time < c.time {
+		c.time = p.Time
+	}
+	c.n++
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerModeReducer) bufferedN() int { return len(r.m) }
+
+// Emit emits the most frequent value as a single point. Ties are broken by
+// the value that was seen first.
+func (r *IntegerModeReducer) Emit() []IntegerPoint {
+	var (
+		mode int64
+		best *modeCount
+	)
+	for v, c := range r.m {
+		if best == nil || c.n > best.n || (c.n == best.n && c.time < best.time) {
+			mode, best = v, c
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return []IntegerPoint{
+		{Time: ZeroTime, Value: mode},
+	}
+}
+
 // IntegerElapsedReducer calculates the elapsed of the aggregated points.
 type IntegerElapsedReducer struct {
 	unitConversion int64
@@ -626,6 +793,49 @@ func (r *IntegerElapsedReducer) Emit() []IntegerPoint {
 	return nil
 }
 
+// IntegerSampleReducer selects a random sample of the aggregated points
+// using reservoir sampling, so only the sample is held in memory.
+type IntegerSampleReducer struct {
+	count  int
+	rng    *rand.Rand
+	points []IntegerPoint
+}
+
+// NewIntegerSampleReducer creates a new IntegerSampleReducer that
+// selects at most size points.
+func NewIntegerSampleReducer(size int) *IntegerSampleReducer {
+	return &IntegerSampleReducer{
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		points: make([]IntegerPoint, 0, size),
+	}
+}
+
+// AggregateInteger aggregates a point into the reducer.
+func (r *IntegerSampleReducer) AggregateInteger(p *IntegerPoint) {
+	r.count++
+
+	// Fill the reservoir with the first points.
+	if len(r.points) < cap(r.points) {
+		r.points = append(r.points, *p)
+		return
+	}
+
+	// Replace a random point in the reservoir so every point aggregated so
+	// far has the same chance of being in the sample.
+	if i := r.rng.Intn(r.count); i < len(r.points) {
+		r.points[i] = *p
+	}
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerSampleReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the sampled points sorted by time.
+func (r *IntegerSampleReducer) Emit() []IntegerPoint {
+	sort.Stable(integerPointsByTime(r.points))
+	return r.points
+}
+
 // StringPointAggregator aggregates points to produce a single point.
 type StringPointAggregator interface {
 	AggregateString(p *StringPoint)
@@ -706,6 +916,9 @@ func (r *StringSliceFuncFloatReducer) AggregateStringBulk(points []StringPoint)
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *StringSliceFuncFloatReducer) bufferedN() int { return len(r.points) }
+
 func (r *StringSliceFuncFloatReducer) Emit() []FloatPoint {
 	return r.fn(r.points)
 }
@@ -761,6 +974,9 @@ func (r *StringSliceFuncIntegerReducer) AggregateStringBulk(points []StringPoint
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *StringSliceFuncIntegerReducer) bufferedN() int { return len(r.points) }
+
 func (r *StringSliceFuncIntegerReducer) Emit() []IntegerPoint {
 	return r.fn(r.points)
 }
@@ -816,6 +1032,9 @@ func (r *StringSliceFuncReducer) AggregateStringBulk(points []StringPoint) {
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *StringSliceFuncReducer) bufferedN() int { return len(r.points) }
+
 func (r *StringSliceFuncReducer) Emit() []StringPoint {
 	return r.fn(r.points)
 }
@@ -871,6 +1090,9 @@ func (r *StringSliceFuncBooleanReducer) AggregateStringBulk(points []StringPoint
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *StringSliceFuncBooleanReducer) bufferedN() int { return len(r.points) }
+
 func (r *StringSliceFuncBooleanReducer) Emit() []BooleanPoint {
 	return r.fn(r.points)
 }
@@ -892,6 +1114,9 @@ func (r *StringDistinctReducer) AggregateString(p *StringPoint) {
 	}
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *StringDistinctReducer) bufferedN() int { return len(r.m) }
+
 // Emit emits the distinct points that have been aggregated into the reducer.
 func (r *StringDistinctReducer) Emit() []StringPoint {
 	points := make([]StringPoint, 0, len(r.m))
@@ -902,6 +1127,51 @@ func (r *StringDistinctReducer) Emit() []StringPoint {
 	return points
 }
 
+// StringModeReducer returns the most frequent value of the aggregated points.
+type StringModeReducer struct {
+	m map[string]*modeCount
+}
+
+// NewStringModeReducer creates a new StringModeReducer.
+func NewStringModeReducer() *StringModeReducer {
+	return &StringModeReducer{m: make(map[string]*modeCount)}
+}
+
+// AggregateString aggregates a point into the reducer.
+func (r *StringModeReducer) AggregateString(p *StringPoint) {
+	c := r.m[p.Value]
+	if c == nil {
+		c = &modeCount{time: p.Time}
+		r.m[p.Value] = c
+	} else if p.Time < c.time {
+		c.time = p.Time
+	}
+	c.n++
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *StringModeReducer) bufferedN() int { return len(r.m) }
+
+// Emit emits the most frequent value as a single point. Ties are broken by
+// the value that was seen first.
+func (r *StringModeReducer) Emit() []StringPoint {
+	var (
+		mode string
+		best *modeCount
+	)
+	for v, c := range r.m {
+		if best == nil || c.n > best.n || (c.n == best.n && c.time < best.time) {
+			mode, best = v, c
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return []StringPoint{
+		{Time: ZeroTime, Value: mode},
+	}
+}
+
 // StringElapsedReducer calculates the elapsed of the aggregated points.
 type StringElapsedReducer struct {
 	unitConversion int64
@@ -935,6 +1205,49 @@ func (r *StringElapsedReducer) Emit() []IntegerPoint {
 	return nil
 }
 
+// StringSampleReducer selects a random sample of the aggregated points
+// using reservoir sampling, so only the sample is held in memory.
+type StringSampleReducer struct {
+	count  int
+	rng    *rand.Rand
+	points []StringPoint
+}
+
+// NewStringSampleReducer creates a new StringSampleReducer that
+// selects at most size points.
+func NewStringSampleReducer(size int) *StringSampleReducer {
+	return &StringSampleReducer{
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		points: make([]StringPoint, 0, size),
+	}
+}
+
+// AggregateString aggregates a point into the reducer.
+func (r *StringSampleReducer) AggregateString(p *StringPoint) {
+	r.count++
+
+	// Fill the reservoir with the first points.
+	if len(r.points) < cap(r.points) {
+		r.points = append(r.points, *p)
+		return
+	}
+
+	// Replace a random point in the reservoir so every point aggregated so
+	// far has the same chance of being in the sample.
+	if i := r.rng.Intn(r.count); i < len(r.points) {
+		r.points[i] = *p
+	}
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *StringSampleReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the sampled points sorted by time.
+func (r *StringSampleReducer) Emit() []StringPoint {
+	sort.Stable(stringPointsByTime(r.points))
+	return r.points
+}
+
 // BooleanPointAggregator aggregates points to produce a single point.
 type BooleanPointAggregator interface {
 	AggregateBoolean(p *BooleanPoint)
@@ -1015,6 +1328,9 @@ func (r *BooleanSliceFuncFloatReducer) AggregateBooleanBulk(points []BooleanPoin
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *BooleanSliceFuncFloatReducer) bufferedN() int { return len(r.points) }
+
 func (r *BooleanSliceFuncFloatReducer) Emit() []FloatPoint {
 	return r.fn(r.points)
 }
@@ -1070,6 +1386,9 @@ func (r *BooleanSliceFuncIntegerReducer) AggregateBooleanBulk(points []BooleanPo
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *BooleanSliceFuncIntegerReducer) bufferedN() int { return len(r.points) }
+
 func (r *BooleanSliceFuncIntegerReducer) Emit() []IntegerPoint {
 	return r.fn(r.points)
 }
@@ -1125,6 +1444,9 @@ func (r *BooleanSliceFuncStringReducer) AggregateBooleanBulk(points []BooleanPoi
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *BooleanSliceFuncStringReducer) bufferedN() int { return len(r.points) }
+
 func (r *BooleanSliceFuncStringReducer) Emit() []StringPoint {
 	return r.fn(r.points)
 }
@@ -1180,6 +1502,9 @@ func (r *BooleanSliceFuncReducer) AggregateBooleanBulk(points []BooleanPoint) {
 	r.points = append(r.points, points...)
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *BooleanSliceFuncReducer) bufferedN() int { return len(r.points) }
+
 func (r *BooleanSliceFuncReducer) Emit() []BooleanPoint {
 	return r.fn(r.points)
 }
@@ -1201,6 +1526,9 @@ func (r *BooleanDistinctReducer) AggregateBoolean(p *BooleanPoint) {
 	}
 }
 
+// bufferedN returns the number of points held by the reducer.
+func (r *BooleanDistinctReducer) bufferedN() int { return len(r.m) }
+
 // Emit emits the distinct points that have been aggregated into the reducer.
 func (r *BooleanDistinctReducer) Emit() []BooleanPoint {
 	points := make([]BooleanPoint, 0, len(r.m))
@@ -1211,6 +1539,51 @@ func (r *BooleanDistinctReducer) Emit() []BooleanPoint {
 	return points
 }
 
+// BooleanModeReducer returns the most frequent value of the aggregated points.
+type BooleanModeReducer struct {
+	m map[bool]*modeCount
+}
+
+// NewBooleanModeReducer creates a new BooleanModeReducer.
+func NewBooleanModeReducer() *BooleanModeReducer {
+	return &BooleanModeReducer{m: make(map[bool]*modeCount)}
+}
+
+// AggregateBoolean aggregates a point into the reducer.
+func (r *BooleanModeReducer) AggregateBoolean(p *BooleanPoint) {
+	c := r.m[p.Value]
+	if c == nil {
+		c = &modeCount{time: p.Time}
+		r.m[p.Value] = c
+	} else if p.Time < c.time {
+		c.time = p.Time
+	}
+	c.n++
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *BooleanModeReducer) bufferedN() int { return len(r.m) }
+
+// Emit emits the most frequent value as a single point. Ties are broken by
+// the value that was seen first.
+func (r *BooleanModeReducer) Emit() []BooleanPoint {
+	var (
+		mode bool
+		best *modeCount
+	)
+	for v, c := range r.m {
+		if best == nil || c.n > best.n || (c.n == best.n && c.time < best.time) {
+			mode, best = v, c
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return []BooleanPoint{
+		{Time: ZeroTime, Value: mode},
+	}
+}
+
 // BooleanElapsedReducer calculates the elapsed of the aggregated points.
 type BooleanElapsedReducer struct {
 	unitConversion int64
@@ -1243,3 +1616,46 @@ func (r *BooleanElapsedReducer) Emit() []IntegerPoint {
 	}
 	return nil
 }
+
+// BooleanSampleReducer selects a random sample of the aggregated points
+// using reservoir sampling, so only the sample is held in memory.
+type BooleanSampleReducer struct {
+	count  int
+	rng    *rand.Rand
+	points []BooleanPoint
+}
+
+// NewBooleanSampleReducer creates a new BooleanSampleReducer that
+// selects at most size points.
+func NewBooleanSampleReducer(size int) *BooleanSampleReducer {
+	return &BooleanSampleReducer{
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		points: make([]BooleanPoint, 0, size),
+	}
+}
+
+// AggregateBoolean aggregates a point into the reducer.
+func (r *BooleanSampleReducer) AggregateBoolean(p *BooleanPoint) {
+	r.count++
+
+	// Fill the reservoir with the first points.
+	if len(r.points) < cap(r.points) {
+		r.points = append(r.points, *p)
+		return
+	}
+
+	// Replace a random point in the reservoir so every point aggregated so
+	// far has the same chance of being in the sample.
+	if i := r.rng.Intn(r.count); i < len(r.points) {
+		r.points[i] = *p
+	}
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *BooleanSampleReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the sampled points sorted by time.
+func (r *BooleanSampleReducer) Emit() []BooleanPoint {
+	sort.Stable(booleanPointsByTime(r.points))
+	return r.points
+}
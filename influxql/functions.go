@@ -1,5 +1,27 @@
 package influxql
 
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/influxdata/influxdb/influxql/neldermead"
+)
+
+// pointBuffer is implemented by reducers that hold on to the points they
+// aggregate until they emit, so the memory they use grows with the points read.
+type pointBuffer interface {
+	// bufferedN returns the number of points held.
+	bufferedN() int
+}
+
+// modeCount tracks the occurrences of a value and the earliest time it was
+// seen for the mode reducers.
+type modeCount struct {
+	n    int
+	time int64
+}
+
 // FloatMeanReducer calculates the mean of the aggregated points.
 type FloatMeanReducer struct {
 	sum   float64
@@ -62,6 +84,188 @@ func (r *IntegerMeanReducer) Emit() []FloatPoint {
 	}}
 }
 
+// FloatIntegralReducer calculates the area under the curve of the aggregated
+// points using the trapezoidal rule.
+type FloatIntegralReducer struct {
+	interval Interval
+	points   []FloatPoint
+}
+
+// NewFloatIntegralReducer creates a new FloatIntegralReducer.
+func NewFloatIntegralReducer(interval Interval) *FloatIntegralReducer {
+	return &FloatIntegralReducer{interval: interval}
+}
+
+// AggregateFloat aggregates a point into the reducer.
+func (r *FloatIntegralReducer) AggregateFloat(p *FloatPoint) {
+	r.points = append(r.points, FloatPoint{Time: p.Time, Value: p.Value})
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatIntegralReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the integral of the aggregated points as a single point.
+func (r *FloatIntegralReducer) Emit() []FloatPoint {
+	sort.Stable(floatPointsByTime(r.points))
+
+	var area float64
+	for i := 1; i < len(r.points); i++ {
+		prev, curr := &r.points[i-1], &r.points[i]
+		elapsed := float64(curr.Time-prev.Time) / float64(r.interval.Duration)
+		area += (prev.Value + curr.Value) / 2 * elapsed
+	}
+	return []FloatPoint{{Time: ZeroTime, Value: area}}
+}
+
+// IntegerIntegralReducer calculates the area under the curve of the
+// aggregated points using the trapezoidal rule.
+type IntegerIntegralReducer struct {
+	interval Interval
+	points   []IntegerPoint
+}
+
+// NewIntegerIntegralReducer creates a new IntegerIntegralReducer.
+func NewIntegerIntegralReducer(interval Interval) *IntegerIntegralReducer {
+	return &IntegerIntegralReducer{interval: interval}
+}
+
+// AggregateInteger aggregates a point into the reducer.
+func (r *IntegerIntegralReducer) AggregateInteger(p *IntegerPoint) {
+	r.points = append(r.points, IntegerPoint{Time: p.Time, Value: p.Value})
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerIntegralReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the integral of the aggregated points as a single point.
+func (r *IntegerIntegralReducer) Emit() []FloatPoint {
+	sort.Stable(integerPointsByTime(r.points))
+
+	var area float64
+	for i := 1; i < len(r.points); i++ {
+		prev, curr := &r.points[i-1], &r.points[i]
+		elapsed := float64(curr.Time-prev.Time) / float64(r.interval.Duration)
+		area += float64(prev.Value+curr.Value) / 2 * elapsed
+	}
+	return []FloatPoint{{Time: ZeroTime, Value: area}}
+}
+
+// FloatRateReducer calculates the rate of increase of a counter over the
+// aggregated points. A decrease is treated as a counter reset, so the value
+// after the reset is counted as the increase since the reset.
+type FloatRateReducer struct {
+	interval Interval
+	points   []FloatPoint
+}
+
+// NewFloatRateReducer creates a new FloatRateReducer.
+func NewFloatRateReducer(interval Interval) *FloatRateReducer {
+	return &FloatRateReducer{interval: interval}
+}
+
+// AggregateFloat aggregates a point into the reducer.
+func (r *FloatRateReducer) AggregateFloat(p *FloatPoint) {
+	r.points = append(r.points, FloatPoint{Time: p.Time, Value: p.Value})
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatRateReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the rate of the aggregated points as a single point. It
+// produces zero points unless the points span some time.
+func (r *FloatRateReducer) Emit() []FloatPoint {
+	if len(r.points) < 2 {
+		return nil
+	}
+	sort.Stable(floatPointsByTime(r.points))
+
+	first, last := r.points[0], r.points[len(r.points)-1]
+	if last.Time == first.Time {
+		return nil
+	}
+
+	var increase float64
+	for i := 1; i < len(r.points); i++ {
+		if diff := r.points[i].Value - r.points[i-1].Value; diff >= 0 {
+			increase += diff
+		} else {
+			increase += r.points[i].Value
+		}
+	}
+	elapsed := float64(last.Time-first.Time) / float64(r.interval.Duration)
+	return []FloatPoint{{Time: ZeroTime, Value: increase / elapsed}}
+}
+
+// IntegerRateReducer calculates the rate of increase of a counter over the
+// aggregated points. A decrease is treated as a counter reset, so the value
+// after the reset is counted as the increase since the reset.
+type IntegerRateReducer struct {
+	interval Interval
+	points   []IntegerPoint
+}
+
+// NewIntegerRateReducer creates a new IntegerRateReducer.
+func NewIntegerRateReducer(interval Interval) *IntegerRateReducer {
+	return &IntegerRateReducer{interval: interval}
+}
+
+// AggregateInteger aggregates a point into the reducer.
+func (r *IntegerRateReducer) AggregateInteger(p *IntegerPoint) {
+	r.points = append(r.points, IntegerPoint{Time: p.Time, Value: p.Value})
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *IntegerRateReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the rate of the aggregated points as a single point. It
+// produces zero points unless the points span some time.
+func (r *IntegerRateReducer) Emit() []FloatPoint {
+	if len(r.points) < 2 {
+		return nil
+	}
+	sort.Stable(integerPointsByTime(r.points))
+
+	first, last := r.points[0], r.points[len(r.points)-1]
+	if last.Time == first.Time {
+		return nil
+	}
+
+	var increase int64
+	for i := 1; i < len(r.points); i++ {
+		if diff := r.points[i].Value - r.points[i-1].Value; diff >= 0 {
+			increase += diff
+		} else {
+			increase += r.points[i].Value
+		}
+	}
+	elapsed := float64(last.Time-first.Time) / float64(r.interval.Duration)
+	return []FloatPoint{{Time: ZeroTime, Value: float64(increase) / elapsed}}
+}
+
+// DerivativeGapPolicy controls how a derivative treats a gap between two
+// successive points that is longer than the maximum gap of the derivative.
+type DerivativeGapPolicy int
+
+const (
+	// DerivativeGapExtrapolate spreads the change across the gap.  It is the
+	// default policy.
+	DerivativeGapExtrapolate DerivativeGapPolicy = iota
+
+	// DerivativeGapNull emits a null value for the point after a gap.
+	DerivativeGapNull
+
+	// DerivativeGapSkip emits no value for the point after a gap.
+	DerivativeGapSkip
+)
+
+// derivativeGapPolicies maps the names of the gap policies accepted by
+// derivative() to the policies.
+var derivativeGapPolicies = map[string]DerivativeGapPolicy{
+	"extrapolate": DerivativeGapExtrapolate,
+	"null":        DerivativeGapNull,
+	"skip":        DerivativeGapSkip,
+}
+
 // FloatDerivativeReducer calculates the derivative of the aggregated points.
 type FloatDerivativeReducer struct {
 	interval      Interval
@@ -69,14 +273,19 @@ type FloatDerivativeReducer struct {
 	curr          FloatPoint
 	isNonNegative bool
 	ascending     bool
+	gapPolicy     DerivativeGapPolicy
+	maxGap        time.Duration
 }
 
-// NewFloatDerivativeReducer creates a new FloatDerivativeReducer.
-func NewFloatDerivativeReducer(interval Interval, isNonNegative, ascending bool) *FloatDerivativeReducer {
+// NewFloatDerivativeReducer creates a new FloatDerivativeReducer.  Gaps
+// between points longer than maxGap are handled according to gapPolicy.
+func NewFloatDerivativeReducer(interval Interval, isNonNegative, ascending bool, gapPolicy DerivativeGapPolicy, maxGap time.Duration) *FloatDerivativeReducer {
 	return &FloatDerivativeReducer{
 		interval:      interval,
 		isNonNegative: isNonNegative,
 		ascending:     ascending,
+		gapPolicy:     gapPolicy,
+		maxGap:        maxGap,
 		prev:          FloatPoint{Nil: true},
 		curr:          FloatPoint{Nil: true},
 	}
@@ -98,6 +307,14 @@ func (r *FloatDerivativeReducer) Emit() []FloatPoint {
 		if !r.ascending {
 			elapsed = -elapsed
 		}
+		if elapsed > int64(r.maxGap) {
+			switch r.gapPolicy {
+			case DerivativeGapNull:
+				return []FloatPoint{{Time: r.curr.Time, Nil: true}}
+			case DerivativeGapSkip:
+				return nil
+			}
+		}
 		value := diff / (float64(elapsed) / float64(r.interval.Duration))
 
 		// Drop negative values for non-negative derivatives.
@@ -116,14 +333,19 @@ type IntegerDerivativeReducer struct {
 	curr          IntegerPoint
 	isNonNegative bool
 	ascending     bool
+	gapPolicy     DerivativeGapPolicy
+	maxGap        time.Duration
 }
 
-// NewIntegerDerivativeReducer creates a new IntegerDerivativeReducer.
-func NewIntegerDerivativeReducer(interval Interval, isNonNegative, ascending bool) *IntegerDerivativeReducer {
+// NewIntegerDerivativeReducer creates a new IntegerDerivativeReducer.  Gaps
+// between points longer than maxGap are handled according to gapPolicy.
+func NewIntegerDerivativeReducer(interval Interval, isNonNegative, ascending bool, gapPolicy DerivativeGapPolicy, maxGap time.Duration) *IntegerDerivativeReducer {
 	return &IntegerDerivativeReducer{
 		interval:      interval,
 		isNonNegative: isNonNegative,
 		ascending:     ascending,
+		gapPolicy:     gapPolicy,
+		maxGap:        maxGap,
 		prev:          IntegerPoint{Nil: true},
 		curr:          IntegerPoint{Nil: true},
 	}
@@ -145,6 +367,14 @@ func (r *IntegerDerivativeReducer) Emit() []FloatPoint {
 		if !r.ascending {
 			elapsed = -elapsed
 		}
+		if elapsed > int64(r.maxGap) {
+			switch r.gapPolicy {
+			case DerivativeGapNull:
+				return []FloatPoint{{Time: r.curr.Time, Nil: true}}
+			case DerivativeGapSkip:
+				return nil
+			}
+		}
 		value := diff / (float64(elapsed) / float64(r.interval.Duration))
 
 		// Drop negative values for non-negative derivatives.
@@ -158,15 +388,17 @@ func (r *IntegerDerivativeReducer) Emit() []FloatPoint {
 
 // FloatDifferenceReducer calculates the derivative of the aggregated points.
 type FloatDifferenceReducer struct {
-	prev FloatPoint
-	curr FloatPoint
+	prev          FloatPoint
+	curr          FloatPoint
+	isNonNegative bool
 }
 
 // NewFloatDifferenceReducer creates a new FloatDifferenceReducer.
-func NewFloatDifferenceReducer() *FloatDifferenceReducer {
+func NewFloatDifferenceReducer(isNonNegative bool) *FloatDifferenceReducer {
 	return &FloatDifferenceReducer{
-		prev: FloatPoint{Nil: true},
-		curr: FloatPoint{Nil: true},
+		prev:          FloatPoint{Nil: true},
+		curr:          FloatPoint{Nil: true},
+		isNonNegative: isNonNegative,
 	}
 }
 
@@ -181,6 +413,11 @@ func (r *FloatDifferenceReducer) Emit() []FloatPoint {
 	if !r.prev.Nil {
 		// Calculate the difference of successive points.
 		value := r.curr.Value - r.prev.Value
+
+		// Drop negative values for non-negative differences.
+		if r.isNonNegative && value < 0 {
+			return nil
+		}
 		return []FloatPoint{{Time: r.curr.Time, Value: value}}
 	}
 	return nil
@@ -188,15 +425,17 @@ func (r *FloatDifferenceReducer) Emit() []FloatPoint {
 
 // IntegerDifferenceReducer calculates the derivative of the aggregated points.
 type IntegerDifferenceReducer struct {
-	prev IntegerPoint
-	curr IntegerPoint
+	prev          IntegerPoint
+	curr          IntegerPoint
+	isNonNegative bool
 }
 
 // NewIntegerDifferenceReducer creates a new IntegerDifferenceReducer.
-func NewIntegerDifferenceReducer() *IntegerDifferenceReducer {
+func NewIntegerDifferenceReducer(isNonNegative bool) *IntegerDifferenceReducer {
 	return &IntegerDifferenceReducer{
-		prev: IntegerPoint{Nil: true},
-		curr: IntegerPoint{Nil: true},
+		prev:          IntegerPoint{Nil: true},
+		curr:          IntegerPoint{Nil: true},
+		isNonNegative: isNonNegative,
 	}
 }
 
@@ -211,11 +450,76 @@ func (r *IntegerDifferenceReducer) Emit() []IntegerPoint {
 	if !r.prev.Nil {
 		// Calculate the difference of successive points.
 		value := r.curr.Value - r.prev.Value
+
+		// Drop negative values for non-negative differences.
+		if r.isNonNegative && value < 0 {
+			return nil
+		}
 		return []IntegerPoint{{Time: r.curr.Time, Value: value}}
 	}
 	return nil
 }
 
+// FloatCumulativeSumReducer calculates the running total of the aggregated points.
+type FloatCumulativeSumReducer struct {
+	curr FloatPoint
+}
+
+// NewFloatCumulativeSumReducer creates a new FloatCumulativeSumReducer.
+func NewFloatCumulativeSumReducer() *FloatCumulativeSumReducer {
+	return &FloatCumulativeSumReducer{
+		curr: FloatPoint{Nil: true},
+	}
+}
+
+// AggregateFloat aggregates a point into the reducer and adds it to the running total.
+func (r *FloatCumulativeSumReducer) AggregateFloat(p *FloatPoint) {
+	if r.curr.Nil {
+		r.curr = *p
+	} else {
+		r.curr.Value += p.Value
+		r.curr.Time = p.Time
+	}
+}
+
+// Emit emits the running total at the current point.
+func (r *FloatCumulativeSumReducer) Emit() []FloatPoint {
+	if !r.curr.Nil {
+		return []FloatPoint{{Time: r.curr.Time, Value: r.curr.Value}}
+	}
+	return nil
+}
+
+// IntegerCumulativeSumReducer calculates the running total of the aggregated points.
+type IntegerCumulativeSumReducer struct {
+	curr IntegerPoint
+}
+
+// NewIntegerCumulativeSumReducer creates a new IntegerCumulativeSumReducer.
+func NewIntegerCumulativeSumReducer() *IntegerCumulativeSumReducer {
+	return &IntegerCumulativeSumReducer{
+		curr: IntegerPoint{Nil: true},
+	}
+}
+
+// AggregateInteger aggregates a point into the reducer and adds it to the running total.
+func (r *IntegerCumulativeSumReducer) AggregateInteger(p *IntegerPoint) {
+	if r.curr.Nil {
+		r.curr = *p
+	} else {
+		r.curr.Value += p.Value
+		r.curr.Time = p.Time
+	}
+}
+
+// Emit emits the running total at the current point.
+func (r *IntegerCumulativeSumReducer) Emit() []IntegerPoint {
+	if !r.curr.Nil {
+		return []IntegerPoint{{Time: r.curr.Time, Value: r.curr.Value}}
+	}
+	return nil
+}
+
 // FloatMovingAverageReducer calculates the moving average of the aggregated points.
 type FloatMovingAverageReducer struct {
 	pos  int
@@ -309,3 +613,445 @@ func (r *IntegerMovingAverageReducer) Emit() []FloatPoint {
 		},
 	}
 }
+
+// exponentialMovingAverage calculates an exponential moving average over a
+// period of n values.  The average is seeded with the simple average of the
+// first n values.
+type exponentialMovingAverage struct {
+	n     int
+	alpha float64
+	count int
+	value float64
+}
+
+func newExponentialMovingAverage(n int) exponentialMovingAverage {
+	return exponentialMovingAverage{n: n, alpha: 2 / float64(n+1)}
+}
+
+// update adds v to the average and returns the new average. It returns false
+// until the first n values have been added.
+func (a *exponentialMovingAverage) update(v float64) (float64, bool) {
+	if a.count < a.n {
+		a.count++
+		a.value += (v - a.value) / float64(a.count)
+		return a.value, a.count == a.n
+	}
+	a.value += a.alpha * (v - a.value)
+	return a.value, true
+}
+
+// FloatExponentialMovingAverageReducer calculates the exponential moving
+// average of the aggregated points.
+type FloatExponentialMovingAverageReducer struct {
+	ema   exponentialMovingAverage
+	value float64
+	time  int64
+	ok    bool
+}
+
+// NewFloatExponentialMovingAverageReducer creates a new FloatExponentialMovingAverageReducer.
+func NewFloatExponentialMovingAverageReducer(n int) *FloatExponentialMovingAverageReducer {
+	return &FloatExponentialMovingAverageReducer{ema: newExponentialMovingAverage(n)}
+}
+
+// AggregateFloat aggregates a point into the reducer and updates the average.
+func (r *FloatExponentialMovingAverageReducer) AggregateFloat(p *FloatPoint) {
+	r.value, r.ok = r.ema.update(p.Value)
+	r.time = p.Time
+}
+
+// AggregateInteger aggregates a point into the reducer and updates the average.
+func (r *FloatExponentialMovingAverageReducer) AggregateInteger(p *IntegerPoint) {
+	r.value, r.ok = r.ema.update(float64(p.Value))
+	r.time = p.Time
+}
+
+// Emit emits the exponential moving average of the last point. It produces
+// zero points until enough points have been aggregated to seed the average.
+func (r *FloatExponentialMovingAverageReducer) Emit() []FloatPoint {
+	if !r.ok {
+		return nil
+	}
+	return []FloatPoint{{Time: r.time, Value: r.value}}
+}
+
+// FloatDoubleExponentialMovingAverageReducer calculates the double
+// exponential moving average of the aggregated points, which is twice the
+// exponential moving average minus the exponential moving average of the
+// exponential moving average.
+type FloatDoubleExponentialMovingAverageReducer struct {
+	ema    exponentialMovingAverage
+	emaEMA exponentialMovingAverage
+	value  float64
+	time   int64
+	ok     bool
+}
+
+// NewFloatDoubleExponentialMovingAverageReducer creates a new FloatDoubleExponentialMovingAverageReducer.
+func NewFloatDoubleExponentialMovingAverageReducer(n int) *FloatDoubleExponentialMovingAverageReducer {
+	return &FloatDoubleExponentialMovingAverageReducer{
+		ema:    newExponentialMovingAverage(n),
+		emaEMA: newExponentialMovingAverage(n),
+	}
+}
+
+// AggregateFloat aggregates a point into the reducer and updates the average.
+func (r *FloatDoubleExponentialMovingAverageReducer) AggregateFloat(p *FloatPoint) {
+	r.aggregate(p.Time, p.Value)
+}
+
+// AggregateInteger aggregates a point into the reducer and updates the average.
+func (r *FloatDoubleExponentialMovingAverageReducer) AggregateInteger(p *IntegerPoint) {
+	r.aggregate(p.Time, float64(p.Value))
+}
+
+func (r *FloatDoubleExponentialMovingAverageReducer) aggregate(t int64, v float64) {
+	r.time, r.ok = t, false
+	ema, ok := r.ema.update(v)
+	if !ok {
+		return
+	}
+	if emaEMA, ok := r.emaEMA.update(ema); ok {
+		r.value, r.ok = 2*ema-emaEMA, true
+	}
+}
+
+// Emit emits the double exponential moving average of the last point. It
+// produces zero points until enough points have been aggregated to seed both
+// averages.
+func (r *FloatDoubleExponentialMovingAverageReducer) Emit() []FloatPoint {
+	if !r.ok {
+		return nil
+	}
+	return []FloatPoint{{Time: r.time, Value: r.value}}
+}
+
+const (
+	// kaufmansFastConstant and kaufmansSlowConstant are the smoothing
+	// constants of the 2 and 30 period exponential moving averages that bound
+	// Kaufman's adaptive moving average.
+	kaufmansFastConstant = 2.0 / (2 + 1)
+	kaufmansSlowConstant = 2.0 / (30 + 1)
+)
+
+// FloatKaufmansAdaptiveMovingAverageReducer calculates Kaufman's adaptive
+// moving average of the aggregated points.  The average follows the points
+// closely when they trend and smooths them when they are noisy, as measured
+// by the efficiency ratio over the last n points.
+type FloatKaufmansAdaptiveMovingAverageReducer struct {
+	n     int
+	buf   []float64
+	value float64
+	time  int64
+	ok    bool
+}
+
+// NewFloatKaufmansAdaptiveMovingAverageReducer creates a new FloatKaufmansAdaptiveMovingAverageReducer.
+func NewFloatKaufmansAdaptiveMovingAverageReducer(n int) *FloatKaufmansAdaptiveMovingAverageReducer {
+	return &FloatKaufmansAdaptiveMovingAverageReducer{
+		n:   n,
+		buf: make([]float64, 0, n+1),
+	}
+}
+
+// AggregateFloat aggregates a point into the reducer and updates the average.
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) AggregateFloat(p *FloatPoint) {
+	r.aggregate(p.Time, p.Value)
+}
+
+// AggregateInteger aggregates a point into the reducer and updates the average.
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) AggregateInteger(p *IntegerPoint) {
+	r.aggregate(p.Time, float64(p.Value))
+}
+
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) aggregate(t int64, v float64) {
+	r.time = t
+
+	// Keep the last n+1 values so the change over n periods can be measured.
+	if len(r.buf) == cap(r.buf) {
+		copy(r.buf, r.buf[1:])
+		r.buf = r.buf[:len(r.buf)-1]
+	}
+	r.buf = append(r.buf, v)
+	if len(r.buf) != cap(r.buf) {
+		return
+	}
+
+	// Seed the average with the first value that has a full window.
+	if !r.ok {
+		r.value, r.ok = v, true
+		return
+	}
+
+	// The efficiency ratio is the net change divided by the sum of the
+	// absolute changes over the window.
+	var er, volatility float64
+	for i := 1; i < len(r.buf); i++ {
+		volatility += math.Abs(r.buf[i] - r.buf[i-1])
+	}
+	if volatility != 0 {
+		er = math.Abs(v-r.buf[0]) / volatility
+	}
+
+	sc := er*kaufmansFastConstant + (1-er)*kaufmansSlowConstant
+	r.value += sc * sc * (v - r.value)
+}
+
+// Emit emits Kaufman's adaptive moving average of the last point. It
+// produces zero points until n+1 points have been aggregated.
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) Emit() []FloatPoint {
+	if !r.ok {
+		return nil
+	}
+	return []FloatPoint{{Time: r.time, Value: r.value}}
+}
+
+// FloatHoltWintersReducer forecasts the aggregated points using the
+// Holt-Winters method with a damped trend and, when the season is longer than
+// one interval, additive seasonality.  The points are expected at a regular
+// interval, as produced by an aggregate grouped by time.  Missing intervals
+// are predicted by the model instead of being used to fit it.
+type FloatHoltWintersReducer struct {
+	h              int
+	m              int
+	includeFitData bool
+	interval       int64
+	ascending      bool
+
+	points []FloatPoint
+}
+
+// NewFloatHoltWintersReducer creates a new FloatHoltWintersReducer forecasting
+// h intervals with a season of m intervals.  If includeFitData is set, the
+// values fitted to the aggregated points are emitted before the forecast.
+func NewFloatHoltWintersReducer(h, m int, includeFitData bool, interval time.Duration, ascending bool) *FloatHoltWintersReducer {
+	return &FloatHoltWintersReducer{
+		h:              h,
+		m:              m,
+		includeFitData: includeFitData,
+		interval:       int64(interval),
+		ascending:      ascending,
+	}
+}
+
+// AggregateFloat aggregates a point into the reducer.
+func (r *FloatHoltWintersReducer) AggregateFloat(p *FloatPoint) {
+	r.points = append(r.points, FloatPoint{Time: p.Time, Value: p.Value})
+}
+
+// AggregateInteger aggregates a point into the reducer.
+func (r *FloatHoltWintersReducer) AggregateInteger(p *IntegerPoint) {
+	r.points = append(r.points, FloatPoint{Time: p.Time, Value: float64(p.Value)})
+}
+
+// bufferedN returns the number of points held by the reducer.
+func (r *FloatHoltWintersReducer) bufferedN() int { return len(r.points) }
+
+// Emit emits the forecast of the aggregated points.  No points are emitted if
+// there are not enough points to fit the model.
+func (r *FloatHoltWintersReducer) Emit() []FloatPoint {
+	if len(r.points) == 0 || r.interval <= 0 {
+		return nil
+	}
+	sort.Sort(floatPointsByTime(r.points))
+
+	// Place the values at their interval, using NaN for missing intervals.
+	start := r.points[0].Time
+	y := make([]float64, (r.points[len(r.points)-1].Time-start)/r.interval+1)
+	for i := range y {
+		y[i] = math.NaN()
+	}
+	for _, p := range r.points {
+		y[(p.Time-start)/r.interval] = p.Value
+	}
+	r.points = r.points[:0]
+
+	model := newHoltWinters(y, r.m)
+	if model == nil {
+		return nil
+	}
+	fitted, forecast := model.forecast(r.h, r.includeFitData)
+
+	points := make([]FloatPoint, 0, len(fitted)+len(forecast))
+	for i, v := range fitted {
+		points = append(points, FloatPoint{Time: start + int64(model.start+i)*r.interval, Value: v})
+	}
+	for i, v := range forecast {
+		points = append(points, FloatPoint{Time: start + int64(len(y)+i)*r.interval, Value: v})
+	}
+
+	if !r.ascending {
+		for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
+		}
+	}
+	return points
+}
+
+const (
+	// holtWintersEpsilon is the precision, relative to the sum of the squared
+	// values, to which the squared errors of the model are minimized.
+	holtWintersEpsilon = 1e-8
+
+	// holtWintersScale is the size of the initial simplex used to fit the
+	// smoothing parameters.
+	holtWintersScale = 0.2
+)
+
+// holtWinters is a Holt-Winters model of values at a regular interval.
+type holtWinters struct {
+	y []float64 // values, NaN if missing
+	m int       // season length, 1 if not seasonal
+
+	// Initial level, trend and seasonal components.  The model is fitted
+	// from the value at start.
+	level  float64
+	trend  float64
+	season []float64
+	start  int
+}
+
+// newHoltWinters returns a model of y with a season of m values.  Returns nil
+// if y is too short to initialize the model, which takes two values, or two
+// seasons if m is greater than one.
+func newHoltWinters(y []float64, m int) *holtWinters {
+	if m < 2 {
+		if len(y) < 2 {
+			return nil
+		}
+		hw := &holtWinters{y: y, m: 1, level: y[0], season: []float64{0}, start: 1}
+		if !math.IsNaN(y[1]) {
+			hw.trend = y[1] - y[0]
+		}
+		return hw
+	}
+
+	if len(y) < 2*m {
+		return nil
+	}
+	hw := &holtWinters{y: y, m: m, season: make([]float64, m), start: m}
+
+	// Start from the mean of the first season, trending toward the mean of
+	// the second one, with the seasonal components being the deviations of
+	// the first season from that trend.
+	first, second := meanOf(y[:m]), meanOf(y[m:2*m])
+	if !math.IsNaN(second) {
+		hw.trend = (second - first) / float64(m)
+	}
+	hw.level = first + hw.trend*float64(m-1)/2
+	for i := range hw.season {
+		if v := y[i]; !math.IsNaN(v) {
+			hw.season[i] = v - (first + hw.trend*(float64(i)-float64(m-1)/2))
+		}
+	}
+	return hw
+}
+
+// forecast fits the smoothing parameters of the model to its values and
+// returns the forecast of the next h values.  The values fitted from start are
+// also returned if fit is set.
+func (hw *holtWinters) forecast(h int, fit bool) (fitted, forecast []float64) {
+	// The errors are minimized from a few starting points of the smoothing
+	// parameters, as the squared errors may have several local minima.
+	var sum float64
+	for _, v := range hw.y {
+		if !math.IsNaN(v) {
+			sum += v * v
+		}
+	}
+	epsilon := holtWintersEpsilon * math.Max(sum, 1)
+
+	gammas := []float64{0.2, 0.6}
+	if hw.m == 1 {
+		gammas = []float64{0}
+	}
+
+	optim := neldermead.New()
+	best, params := math.Inf(1), []float64{0.5, 0.5, 0, 0.9}
+	for _, alpha := range []float64{0.2, 0.6} {
+		for _, beta := range []float64{0.2, 0.6} {
+			for _, gamma := range gammas {
+				sse, p := optim.Optimize(func(p []float64) float64 {
+					sse, _, _ := hw.run(p, 0, false)
+					return sse
+				}, []float64{alpha, beta, gamma, 0.9}, epsilon, holtWintersScale)
+				if sse < best {
+					best, params = sse, p
+				}
+			}
+		}
+	}
+
+	_, fitted, forecast = hw.run(params, h, fit)
+	return fitted, forecast
+}
+
+// run runs the model with the smoothing parameters alpha, beta, gamma and
+// phi, and returns the sum of the squared errors of the values predicted one
+// interval ahead.  It also returns the forecast of the next h values and, if
+// fit is set, the predicted values.  The parameters are limited to [0, 1].
+func (hw *holtWinters) run(params []float64, h int, fit bool) (sse float64, fitted, forecast []float64) {
+	alpha, beta, gamma, phi := clamp(params[0]), clamp(params[1]), clamp(params[2]), clamp(params[3])
+	if hw.m == 1 {
+		gamma = 0
+	}
+
+	level, trend := hw.level, hw.trend
+	season := make([]float64, hw.m)
+	copy(season, hw.season)
+
+	if fit {
+		fitted = make([]float64, 0, len(hw.y)-hw.start)
+	}
+	for t := hw.start; t < len(hw.y); t++ {
+		s := season[t%hw.m]
+		predicted := level + phi*trend + s
+		if fit {
+			fitted = append(fitted, predicted)
+		}
+
+		y := hw.y[t]
+		if math.IsNaN(y) {
+			y = predicted
+		} else {
+			sse += (y - predicted) * (y - predicted)
+		}
+
+		l := alpha*(y-s) + (1-alpha)*(level+phi*trend)
+		season[t%hw.m] = gamma*(y-level-phi*trend) + (1-gamma)*s
+		trend = beta*(l-level) + (1-beta)*phi*trend
+		level = l
+	}
+
+	if h > 0 {
+		forecast = make([]float64, h)
+		damping := 0.0
+		for i := range forecast {
+			damping += math.Pow(phi, float64(i+1))
+			forecast[i] = level + damping*trend + season[(len(hw.y)+i)%hw.m]
+		}
+	}
+	return sse, fitted, forecast
+}
+
+// meanOf returns the mean of the values of a that are not NaN, or NaN if there
+// are none.
+func meanOf(a []float64) float64 {
+	var sum float64
+	var n int
+	for _, v := range a {
+		if !math.IsNaN(v) {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
+
+// clamp limits v to [0, 1].
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
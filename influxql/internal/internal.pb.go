@@ -18,6 +18,7 @@ It has these top-level messages:
 	IteratorStats
 	Series
 	SeriesList
+	VarRef
 */
 package influxql
 
@@ -197,6 +198,8 @@ type IteratorOptions struct {
 	SLimit           *int64         `protobuf:"varint,14,opt,name=SLimit" json:"SLimit,omitempty"`
 	SOffset          *int64         `protobuf:"varint,15,opt,name=SOffset" json:"SOffset,omitempty"`
 	Dedupe           *bool          `protobuf:"varint,16,opt,name=Dedupe" json:"Dedupe,omitempty"`
+	Location         *string        `protobuf:"bytes,17,opt,name=Location" json:"Location,omitempty"`
+	Fields           []*VarRef      `protobuf:"bytes,18,rep,name=Fields" json:"Fields,omitempty"`
 	XXX_unrecognized []byte         `json:"-"`
 }
 
@@ -317,6 +320,20 @@ func (m *IteratorOptions) GetDedupe() bool {
 	return false
 }
 
+func (m *IteratorOptions) GetLocation() string {
+	if m != nil && m.Location != nil {
+		return *m.Location
+	}
+	return ""
+}
+
+func (m *IteratorOptions) GetFields() []*VarRef {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
 type Measurements struct {
 	Items            []*Measurement `protobuf:"bytes,1,rep,name=Items" json:"Items,omitempty"`
 	XXX_unrecognized []byte         `json:"-"`
@@ -411,6 +428,7 @@ func (m *Interval) GetOffset() int64 {
 type IteratorStats struct {
 	SeriesN          *int64 `protobuf:"varint,1,opt,name=SeriesN" json:"SeriesN,omitempty"`
 	PointN           *int64 `protobuf:"varint,2,opt,name=PointN" json:"PointN,omitempty"`
+	BlockN           *int64 `protobuf:"varint,3,opt,name=BlockN" json:"BlockN,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
 }
 
@@ -433,6 +451,13 @@ func (m *IteratorStats) GetPointN() int64 {
 	return 0
 }
 
+func (m *IteratorStats) GetBlockN() int64 {
+	if m != nil && m.BlockN != nil {
+		return *m.BlockN
+	}
+	return 0
+}
+
 type Series struct {
 	Name             *string  `protobuf:"bytes,1,opt,name=Name" json:"Name,omitempty"`
 	Tags             []byte   `protobuf:"bytes,2,opt,name=Tags" json:"Tags,omitempty"`
@@ -483,6 +508,31 @@ func (m *SeriesList) GetItems() []*Series {
 	return nil
 }
 
+type VarRef struct {
+	Val              *string `protobuf:"bytes,1,req,name=Val" json:"Val,omitempty"`
+	Type             *int32  `protobuf:"varint,2,opt,name=Type" json:"Type,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *VarRef) Reset()                    { *m = VarRef{} }
+func (m *VarRef) String() string            { return proto.CompactTextString(m) }
+func (*VarRef) ProtoMessage()               {}
+func (*VarRef) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{9} }
+
+func (m *VarRef) GetVal() string {
+	if m != nil && m.Val != nil {
+		return *m.Val
+	}
+	return ""
+}
+
+func (m *VarRef) GetType() int32 {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Point)(nil), "influxql.Point")
 	proto.RegisterType((*Aux)(nil), "influxql.Aux")
@@ -493,44 +543,49 @@ func init() {
 	proto.RegisterType((*IteratorStats)(nil), "influxql.IteratorStats")
 	proto.RegisterType((*Series)(nil), "influxql.Series")
 	proto.RegisterType((*SeriesList)(nil), "influxql.SeriesList")
+	proto.RegisterType((*VarRef)(nil), "influxql.VarRef")
 }
 
 var fileDescriptorInternal = []byte{
-	// 569 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x74, 0x53, 0xdf, 0x6e, 0xda, 0x3c,
-	0x14, 0x57, 0x48, 0x53, 0x92, 0x13, 0x52, 0xf8, 0xfc, 0x6d, 0x22, 0xda, 0xcd, 0x50, 0x34, 0x4d,
-	0x5c, 0x6c, 0x6c, 0x43, 0x7b, 0x01, 0x36, 0x5a, 0x09, 0xa9, 0xa3, 0x55, 0x41, 0xbb, 0xf7, 0xc0,
-	0x44, 0x96, 0x4c, 0xcc, 0x6c, 0x67, 0xa2, 0x8f, 0xb8, 0x67, 0xd9, 0x4b, 0xec, 0xd8, 0x49, 0x0a,
-	0x54, 0xec, 0x2e, 0xe7, 0x77, 0x8e, 0x73, 0x7e, 0x7f, 0x6c, 0xe8, 0xf3, 0xc2, 0x30, 0x55, 0x50,
-	0xf1, 0xa1, 0xf9, 0x18, 0xed, 0x94, 0x34, 0x92, 0x84, 0xbc, 0xd8, 0x88, 0x72, 0xff, 0x53, 0x64,
-	0x7f, 0x3c, 0x08, 0xee, 0x25, 0xb6, 0x49, 0x07, 0x2e, 0xe6, 0x74, 0xcb, 0x52, 0x6f, 0xd0, 0x1a,
-	0x46, 0xb6, 0x5a, 0xd2, 0x5c, 0xa7, 0xad, 0xa7, 0x8a, 0x63, 0xcf, 0xc7, 0xca, 0x27, 0x31, 0xf8,
-	0x73, 0x2e, 0xd2, 0x0b, 0x2c, 0x42, 0xf2, 0x0a, 0xfc, 0x49, 0xb9, 0x4f, 0x83, 0x81, 0x3f, 0x8c,
-	0xc7, 0xc9, 0xa8, 0xf9, 0xf1, 0x08, 0x41, 0x42, 0x00, 0x26, 0x79, 0xae, 0x58, 0x4e, 0x0d, 0x5b,
-	0xa7, 0x97, 0x03, 0x6f, 0x98, 0x58, 0xec, 0x46, 0x48, 0x6a, 0xbe, 0x53, 0x51, 0xb2, 0xb4, 0x8d,
-	0x98, 0x47, 0x5e, 0x40, 0x67, 0x86, 0x04, 0x73, 0xa6, 0x2a, 0x34, 0x44, 0xd4, 0x27, 0xff, 0x43,
-	0xbc, 0x30, 0x8a, 0x17, 0x79, 0x05, 0x46, 0x08, 0x46, 0x76, 0xf4, 0x8b, 0x94, 0x82, 0xd1, 0xa2,
-	0x42, 0x01, 0xd1, 0x90, 0xbc, 0x85, 0x60, 0x61, 0xa8, 0xd1, 0x69, 0x8c, 0x65, 0x3c, 0xee, 0x1f,
-	0x68, 0xcc, 0x50, 0x37, 0x35, 0x52, 0xb9, 0x76, 0x26, 0x1c, 0x59, 0xd2, 0x83, 0x70, 0x4a, 0x0d,
-	0x5d, 0x3e, 0xee, 0x2a, 0xb9, 0xc1, 0x33, 0x56, 0xad, 0xb3, 0xac, 0xfc, 0x73, 0xac, 0x2e, 0xce,
-	0xb2, 0x0a, 0x2c, 0xab, 0xec, 0x77, 0x0b, 0xba, 0xcd, 0xfe, 0xbb, 0x9d, 0xe1, 0xb2, 0xd0, 0xd6,
-	0xc9, 0xeb, 0xfd, 0x4e, 0xe1, 0x5a, 0x7b, 0x2e, 0xae, 0xcc, 0x6b, 0xa1, 0x79, 0x11, 0x8a, 0x68,
-	0x2f, 0x64, 0xa9, 0x56, 0x4c, 0xe3, 0x2a, 0xeb, 0xe6, 0xcb, 0x83, 0x8c, 0x6f, 0x8c, 0xea, 0x52,
-	0xb1, 0x2d, 0xc3, 0xa0, 0xde, 0x40, 0x68, 0x79, 0xa9, 0x5f, 0x54, 0xb8, 0xf5, 0xf1, 0x98, 0x1c,
-	0xe9, 0xad, 0x3b, 0x56, 0xd1, 0x14, 0x23, 0x2b, 0xb4, 0x5d, 0xeb, 0xe2, 0x71, 0x31, 0xde, 0x70,
-	0x21, 0x5c, 0x12, 0x01, 0xf9, 0x0f, 0x22, 0x5b, 0x1d, 0x07, 0x81, 0xd0, 0x57, 0x59, 0xac, 0xb9,
-	0xe5, 0xea, 0x52, 0x88, 0x2c, 0x84, 0xde, 0x29, 0xe3, 0xf2, 0x8f, 0x9c, 0x05, 0x5d, 0x68, 0x5f,
-	0x17, 0x6b, 0x07, 0x80, 0x03, 0x70, 0x66, 0xa2, 0x57, 0x0c, 0x0f, 0x16, 0xb9, 0x8b, 0x20, 0x24,
-	0x09, 0x04, 0xb7, 0x7c, 0xcb, 0x4d, 0xda, 0x71, 0x13, 0x57, 0x70, 0x79, 0xb7, 0xd9, 0x68, 0x66,
-	0xd2, 0xa4, 0xa9, 0x17, 0x55, 0xff, 0xaa, 0xf9, 0xe5, 0xa2, 0x1e, 0xe8, 0x36, 0x03, 0x53, 0xb6,
-	0x2e, 0x31, 0xa0, 0x9e, 0xf3, 0xf2, 0x33, 0x74, 0x8e, 0x3c, 0xd0, 0x68, 0x42, 0x80, 0xd6, 0x6e,
-	0x35, 0x1a, 0xf9, 0x6f, 0xab, 0xb2, 0x1c, 0xe2, 0x63, 0xe7, 0xea, 0xdc, 0x7f, 0x50, 0xcd, 0xea,
-	0x00, 0xfa, 0xd0, 0x7d, 0x60, 0x06, 0x7b, 0x28, 0xf8, 0x5e, 0x0a, 0xbe, 0x7a, 0x74, 0xe1, 0x47,
-	0x4f, 0xaf, 0xc1, 0x77, 0x15, 0xaa, 0x79, 0xc0, 0x8b, 0xb0, 0xaf, 0xe3, 0xc6, 0xff, 0xcc, 0xf4,
-	0x92, 0xaa, 0x1c, 0xe9, 0x56, 0x51, 0xbf, 0x3b, 0x64, 0xe2, 0xb6, 0x94, 0x18, 0xba, 0xf5, 0xd0,
-	0x7b, 0xa6, 0xde, 0xfe, 0xdc, 0xcf, 0x3e, 0x42, 0x72, 0x72, 0x2f, 0x9d, 0x7c, 0xa6, 0x38, 0xd3,
-	0xf3, 0xc3, 0x09, 0xf7, 0x2a, 0xe7, 0xf5, 0x89, 0x4f, 0xe8, 0x97, 0x1b, 0x38, 0x7a, 0xa6, 0xde,
-	0xc9, 0x33, 0xf5, 0x86, 0x9d, 0xe6, 0x3a, 0xd9, 0xdb, 0x93, 0x64, 0xef, 0x01, 0xaa, 0x23, 0xb7,
-	0x5c, 0x1b, 0xf2, 0xfa, 0xd4, 0xaf, 0xde, 0xc1, 0xaf, 0x6a, 0xe8, 0x6f, 0x00, 0x00, 0x00, 0xff,
-	0xff, 0xe3, 0x58, 0x08, 0xa6, 0x2c, 0x04, 0x00, 0x00,
+	// 628 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x54, 0x5f, 0x4f, 0xdb, 0x30,
+	0x10, 0x97, 0x13, 0x52, 0x92, 0x4b, 0x4b, 0x5b, 0x6f, 0x13, 0xd6, 0x5e, 0x16, 0x65, 0x08, 0xe5,
+	0x61, 0x63, 0x1a, 0xda, 0x07, 0x58, 0x19, 0x20, 0x55, 0x62, 0x05, 0x51, 0xc4, 0xbb, 0xd7, 0x5e,
+	0x23, 0x6b, 0xae, 0xd3, 0xd9, 0xce, 0x54, 0x3e, 0xf3, 0x3e, 0xc3, 0xa4, 0xc9, 0x4e, 0x43, 0x0b,
+	0x42, 0x7b, 0xcb, 0x9d, 0xef, 0xcf, 0xef, 0x7e, 0xbf, 0xbb, 0xc0, 0xa1, 0x50, 0x16, 0xb5, 0xe2,
+	0xf2, 0x53, 0xfb, 0x71, 0xb2, 0xd2, 0x95, 0xad, 0x68, 0x2c, 0xd4, 0x42, 0xd6, 0xeb, 0x5f, 0x32,
+	0xff, 0x43, 0x20, 0xba, 0xa9, 0x84, 0xb2, 0xb4, 0x0b, 0x7b, 0x13, 0xbe, 0x44, 0x46, 0xb2, 0xa0,
+	0x48, 0x9c, 0x75, 0xc7, 0x4b, 0xc3, 0x82, 0x47, 0x4b, 0x2c, 0x91, 0x85, 0x59, 0x50, 0x84, 0x34,
+	0x85, 0x70, 0x22, 0x24, 0xdb, 0xcb, 0x82, 0x22, 0xa6, 0x6f, 0x21, 0x1c, 0xd5, 0x6b, 0x16, 0x65,
+	0x61, 0x91, 0x9e, 0xf6, 0x4e, 0xda, 0xc2, 0x27, 0xa3, 0x7a, 0x4d, 0x29, 0xc0, 0xa8, 0x2c, 0x35,
+	0x96, 0xdc, 0xe2, 0x9c, 0x75, 0x32, 0x52, 0xf4, 0x9c, 0xef, 0x52, 0x56, 0xdc, 0xde, 0x73, 0x59,
+	0x23, 0xdb, 0xcf, 0x48, 0x41, 0xe8, 0x6b, 0xe8, 0x8e, 0x95, 0xc5, 0x12, 0x75, 0xe3, 0x8d, 0x33,
+	0x52, 0x84, 0xf4, 0x15, 0xa4, 0x53, 0xab, 0x85, 0x2a, 0x1b, 0x67, 0x92, 0x91, 0x22, 0x71, 0xa1,
+	0x67, 0x55, 0x25, 0x91, 0xab, 0xc6, 0x0b, 0x19, 0x29, 0x62, 0x7a, 0x0c, 0xd1, 0xd4, 0x72, 0x6b,
+	0x58, 0x9a, 0x91, 0x22, 0x3d, 0x3d, 0xdc, 0xc2, 0x18, 0x5b, 0xd4, 0xdc, 0x56, 0xda, 0x3f, 0xe7,
+	0xd2, 0x83, 0xa5, 0x03, 0x88, 0xcf, 0xb9, 0xe5, 0x77, 0x0f, 0xab, 0x66, 0xdc, 0xe8, 0x19, 0xaa,
+	0xe0, 0x45, 0x54, 0xe1, 0x4b, 0xa8, 0xf6, 0x5e, 0x44, 0x15, 0x39, 0x54, 0xf9, 0xdf, 0x00, 0xfa,
+	0x6d, 0xff, 0xeb, 0x95, 0x15, 0x95, 0x32, 0x8e, 0xc9, 0x8b, 0xf5, 0x4a, 0x33, 0xe2, 0xf3, 0xd2,
+	0x86, 0xbc, 0x20, 0x0b, 0x8b, 0x84, 0x1e, 0xc3, 0xfe, 0xb4, 0xaa, 0xf5, 0x0c, 0x0d, 0x0b, 0x3d,
+	0x9b, 0x6f, 0xb6, 0x63, 0x7c, 0x47, 0x6e, 0x6a, 0x8d, 0x4b, 0x54, 0x96, 0x1e, 0x41, 0xec, 0x70,
+	0xe9, 0xdf, 0x5c, 0xfa, 0xf6, 0xe9, 0x29, 0xdd, 0x99, 0x77, 0xf3, 0xe2, 0x26, 0x3a, 0x17, 0x4b,
+	0x54, 0xc6, 0xb5, 0xf5, 0xf2, 0x78, 0x19, 0x2f, 0x85, 0x94, 0x5e, 0x89, 0x88, 0x0e, 0x21, 0x71,
+	0xd6, 0xae, 0x10, 0x43, 0x48, 0xbe, 0x55, 0x6a, 0x2e, 0x1c, 0x56, 0xaf, 0x42, 0xe2, 0x5c, 0x53,
+	0xcb, 0xb5, 0xf5, 0xfa, 0x27, 0x9e, 0x82, 0x3e, 0xec, 0x5f, 0xa8, 0xb9, 0x77, 0x80, 0x77, 0x0c,
+	0x21, 0x19, 0x99, 0x19, 0xaa, 0xb9, 0x50, 0xa5, 0x97, 0x20, 0xa6, 0x3d, 0x88, 0xae, 0xc4, 0x52,
+	0x58, 0xd6, 0xf5, 0x11, 0x07, 0xd0, 0xb9, 0x5e, 0x2c, 0x0c, 0x5a, 0xd6, 0x6b, 0xed, 0x69, 0xf3,
+	0x7e, 0xd0, 0x96, 0x9c, 0x6e, 0x02, 0xfa, 0x6d, 0xc0, 0x39, 0xce, 0xeb, 0x15, 0xb2, 0x81, 0xaf,
+	0x37, 0x80, 0xf8, 0xaa, 0x9a, 0x71, 0x0f, 0x6c, 0xe8, 0x81, 0x65, 0xd0, 0xb9, 0x14, 0x28, 0xe7,
+	0x86, 0x51, 0xcf, 0xd6, 0x60, 0x4b, 0xc2, 0x3d, 0xd7, 0xb7, 0xb8, 0xc8, 0xbf, 0x40, 0x77, 0x87,
+	0x37, 0x43, 0x8f, 0x20, 0x1a, 0x5b, 0x5c, 0x1a, 0x46, 0xfe, 0x43, 0x6f, 0x5e, 0x42, 0xba, 0x63,
+	0xb6, 0xbb, 0xf2, 0x83, 0x1b, 0xdc, 0x88, 0x76, 0x08, 0xfd, 0x5b, 0xb4, 0xa8, 0x1c, 0x96, 0x9b,
+	0x4a, 0x8a, 0xd9, 0x83, 0x5f, 0x98, 0xe4, 0xf1, 0x82, 0x42, 0x6f, 0xf5, 0x20, 0xba, 0xc5, 0x12,
+	0xd7, 0x9b, 0x15, 0x19, 0x40, 0x3c, 0x36, 0x77, 0x5c, 0x97, 0x68, 0x37, 0xeb, 0xf1, 0x61, 0xab,
+	0xa3, 0xef, 0x52, 0xeb, 0x66, 0x3c, 0xf2, 0x8c, 0x31, 0x57, 0x3c, 0xcc, 0xbf, 0x42, 0xef, 0xc9,
+	0x2e, 0x7b, 0xca, 0x50, 0x0b, 0x34, 0x93, 0x6d, 0x86, 0xbf, 0xe4, 0x09, 0x0b, 0x5a, 0xfb, 0x4c,
+	0x56, 0xb3, 0x9f, 0x93, 0x66, 0x73, 0xf3, 0xcf, 0xd0, 0x69, 0x12, 0x76, 0x4e, 0x9d, 0x3c, 0x39,
+	0x75, 0x52, 0x74, 0xdb, 0x95, 0x74, 0x1b, 0xd8, 0xcb, 0x3f, 0x02, 0x34, 0x29, 0x57, 0xc2, 0x58,
+	0xfa, 0xee, 0x29, 0x7f, 0x3b, 0x84, 0x37, 0x41, 0xf9, 0x7b, 0xe8, 0x34, 0xd4, 0xbb, 0x2a, 0xf7,
+	0x5c, 0xee, 0xfc, 0x4b, 0xdc, 0xa9, 0xb9, 0x06, 0xd1, 0xbf, 0x01, 0x00, 0x9c, 0xae, 0x32, 0xd8,
+	0x95, 0x04, 0x00, 0x00,
 }
@@ -464,6 +464,12 @@ func (itr *floatLimitIterator) Close() error { return itr.input.Close() }
 // Next returns the next point from the iterator.
 func (itr *floatLimitIterator) Next() (*FloatPoint, error) {
 	for {
+		// Stop before reading another point once the limit has been reached
+		// if no other group can follow so the inputs stop reading early.
+		if itr.opt.Limit > 0 && (itr.n-itr.opt.Offset) >= itr.opt.Limit && itr.opt.singleGroup() {
+			return nil, nil
+		}
+
 		p, err := itr.input.Next()
 		if p == nil || err != nil {
 			return nil, err
@@ -486,10 +492,6 @@ func (itr *floatLimitIterator) Next() (*FloatPoint, error) {
 
 		// Read next point if we're beyond the limit.
 		if itr.opt.Limit > 0 && (itr.n-itr.opt.Offset) > itr.opt.Limit {
-			// If there's no interval, no groups, and a single source then simply exit.
-			if itr.opt.Interval.IsZero() && len(itr.opt.Dimensions) == 0 && len(itr.opt.Sources) == 1 {
-				return nil, nil
-			}
 			continue
 		}
 
@@ -515,7 +517,7 @@ type floatFillIterator struct {
 
 func newFloatFillIterator(input FloatIterator, expr Expr, opt IteratorOptions) *floatFillIterator {
 	if opt.Fill == NullFill {
-		if expr, ok := expr.(*Call); ok && expr.Name == "count" {
+		if expr, ok := expr.(*Call); ok && (expr.Name == "count" || expr.Name == "histogram") {
 			opt.Fill = NumberFill
 			opt.FillValue = float64(0)
 		}
@@ -558,44 +560,56 @@ func (itr *floatFillIterator) Next() (*FloatPoint, error) {
 		itr.init = true
 	}
 
-	p, err := itr.input.Next()
-	if err != nil {
-		return nil, err
-	}
+	var p *FloatPoint
+	for {
+		var err error
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
 
-	// Check if the next point is outside of our window or is nil.
-	for p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
-		// If we are inside of an interval, unread the point and continue below to
-		// constructing a new point.
-		if itr.opt.Ascending {
-			if itr.window.time <= itr.endTime {
-				itr.input.unread(p)
-				p = nil
-				break
+		// Check if the next point is outside of our window or is nil.
+		for p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// If we are inside of an interval, unread the point and continue below to
+			// constructing a new point.
+			if itr.opt.Ascending {
+				if itr.window.time <= itr.endTime {
+					itr.input.unread(p)
+					p = nil
+					break
+				}
+			} else {
+				if itr.window.time >= itr.endTime {
+					itr.input.unread(p)
+					p = nil
+					break
+				}
 			}
-		} else {
-			if itr.window.time >= itr.endTime {
-				itr.input.unread(p)
-				p = nil
-				break
+
+			// We are *not* in a current interval. If there is no next point,
+			// we are at the end of all intervals.
+			if p == nil {
+				return nil, nil
 			}
-		}
 
-		// We are *not* in a current interval. If there is no next point,
-		// we are at the end of all intervals.
-		if p == nil {
-			return nil, nil
+			// Set the new interval.
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+			itr.prev = nil
+			break
 		}
 
-		// Set the new interval.
-		itr.window.name, itr.window.tags = p.Name, p.Tags
-		itr.window.time = itr.startTime
-		itr.prev = nil
+		// Points before the start of the range are only read when fill(previous)
+		// looks back for a previous value so they are not returned.
+		if p != nil && itr.opt.Ascending && p.Time < itr.startTime {
+			itr.prev = p
+			continue
+		}
 		break
 	}
 
 	// Check if the point is our next expected point.
 	if p == nil || (itr.opt.Ascending && p.Time > itr.window.time) || (!itr.opt.Ascending && p.Time < itr.window.time) {
+		next := p
 		if p != nil {
 			itr.input.unread(p)
 		}
@@ -619,6 +633,13 @@ func (itr *floatFillIterator) Next() (*FloatPoint, error) {
 			} else {
 				p.Nil = true
 			}
+		case LinearFill:
+			// Interpolate between the previous and next points of the series.
+			if itr.prev != nil && !itr.prev.Nil && next != nil && !next.Nil {
+				p.Value = linearFloat(itr.window.time, itr.prev.Time, next.Time, itr.prev.Value, next.Value)
+			} else {
+				p.Nil = true
+			}
 		}
 	} else {
 		itr.prev = p
@@ -626,11 +647,12 @@ func (itr *floatFillIterator) Next() (*FloatPoint, error) {
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. The interval is looked up so it follows the time zone when a
+	// daylight saving time change makes it shorter or longer.
 	if itr.opt.Ascending {
-		itr.window.time = p.Time + int64(itr.opt.Interval.Duration)
+		_, itr.window.time = itr.opt.Window(p.Time)
 	} else {
-		itr.window.time = p.Time - int64(itr.opt.Interval.Duration)
+		itr.window.time, _ = itr.opt.Window(p.Time - 1)
 	}
 	return p, nil
 }
@@ -691,6 +713,150 @@ func (itr *floatInterruptIterator) Next() (*FloatPoint, error) {
 	return itr.input.Next()
 }
 
+// floatParallelIterator reads its input in batches on a separate goroutine.
+type floatParallelIterator struct {
+	input   FloatIterator
+	budget  *MemoryBudget
+	ch      chan floatParallelBatch
+	closing chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	batch     []FloatPoint
+	batchSize int64 // bytes reserved for batch
+	err       error
+
+	mu    sync.Mutex
+	stats IteratorStats
+}
+
+// floatParallelBatch is a batch of points read by a floatParallelIterator
+// and the error that ended it, if any.
+type floatParallelBatch struct {
+	points []FloatPoint
+	size   int64
+	err    error
+}
+
+func newFloatParallelIterator(input FloatIterator, limiter chan struct{}, budget *MemoryBudget) *floatParallelIterator {
+	itr := &floatParallelIterator{
+		input:   input,
+		budget:  budget,
+		ch:      make(chan floatParallelBatch, 1),
+		closing: make(chan struct{}),
+	}
+	itr.wg.Add(1)
+	go itr.monitor(limiter)
+	return itr
+}
+
+// Stats returns the stats of the input as of the last batch read.
+func (itr *floatParallelIterator) Stats() IteratorStats {
+	itr.mu.Lock()
+	defer itr.mu.Unlock()
+	return itr.stats
+}
+
+// Close stops reading the input and closes it.
+func (itr *floatParallelIterator) Close() error {
+	itr.once.Do(func() { close(itr.closing) })
+	itr.wg.Wait()
+
+	// Release the memory of the batches that were not read.
+	itr.budget.Release(itr.batchSize)
+	itr.batch, itr.batchSize = nil, 0
+	for b := range itr.ch {
+		itr.budget.Release(b.size)
+	}
+	return itr.input.Close()
+}
+
+// Next returns the next point read from the input.
+func (itr *floatParallelIterator) Next() (*FloatPoint, error) {
+	for len(itr.batch) == 0 {
+		if itr.err != nil {
+			err := itr.err
+			itr.err = nil
+			return nil, err
+		}
+
+		itr.budget.Release(itr.batchSize)
+		itr.batchSize = 0
+
+		b, ok := <-itr.ch
+		if !ok {
+			return nil, nil
+		}
+		itr.batch, itr.batchSize, itr.err = b.points, b.size, b.err
+	}
+
+	p := &itr.batch[0]
+	itr.batch = itr.batch[1:]
+	return p, nil
+}
+
+// monitor reads batches from the input while holding a slot in limiter and
+// sends them to Next until the input is exhausted or the iterator is closed.
+func (itr *floatParallelIterator) monitor(limiter chan struct{}) {
+	defer itr.wg.Done()
+	defer close(itr.ch)
+
+	for {
+		select {
+		case limiter <- struct{}{}:
+		case <-itr.closing:
+			return
+		}
+		points, size, err := itr.read()
+		<-limiter
+
+		if len(points) > 0 || err != nil {
+			select {
+			case itr.ch <- floatParallelBatch{points: points, size: size, err: err}:
+			case <-itr.closing:
+				itr.budget.Release(size)
+				return
+			}
+		}
+
+		// A short batch means the input is exhausted.
+		if err != nil || len(points) < parallelBatchSize {
+			return
+		}
+	}
+}
+
+// read reads up to parallelBatchSize points from the input and reserves
+// their memory from the budget.  The points are copied since the input may
+// reuse them.
+func (itr *floatParallelIterator) read() ([]FloatPoint, int64, error) {
+	points := make([]FloatPoint, 0, parallelBatchSize)
+	defer func() {
+		stats := itr.input.Stats()
+		itr.mu.Lock()
+		itr.stats = stats
+		itr.mu.Unlock()
+	}()
+
+	var size int64
+	for len(points) < parallelBatchSize {
+		p, err := itr.input.Next()
+		if err != nil {
+			return points, size, err
+		} else if p == nil {
+			break
+		}
+
+		n := p.size()
+		if err := itr.budget.Reserve(n); err != nil {
+			return points, size, err
+		}
+		size += n
+		points = append(points, *p.Clone())
+	}
+	return points, size, nil
+}
+
 // auxFloatPoint represents a combination of a point and an error for the AuxIterator.
 type auxFloatPoint struct {
 	point *FloatPoint
@@ -726,7 +892,9 @@ func (itr *floatAuxIterator) Next() (*FloatPoint, error) {
 	p := <-itr.output
 	return p.point, p.err
 }
-func (itr *floatAuxIterator) Iterator(name string) Iterator { return itr.fields.iterator(name) }
+func (itr *floatAuxIterator) Iterator(name string, typ DataType) Iterator {
+	return itr.fields.iterator(name, typ)
+}
 
 func (itr *floatAuxIterator) CreateIterator(opt IteratorOptions) (Iterator, error) {
 	expr := opt.Expr
@@ -736,7 +904,7 @@ func (itr *floatAuxIterator) CreateIterator(opt IteratorOptions) (Iterator, erro
 
 	switch expr := expr.(type) {
 	case *VarRef:
-		return itr.Iterator(expr.Val), nil
+		return itr.Iterator(expr.Val, expr.Type), nil
 	default:
 		panic(fmt.Sprintf("invalid expression type for an aux iterator: %T", expr))
 	}
@@ -876,17 +1044,22 @@ func (itr *floatChanIterator) Next() (*FloatPoint, error) {
 
 // floatReduceFloatIterator executes a reducer for every interval and buffers the result.
 type floatReduceFloatIterator struct {
-	input  *bufFloatIterator
-	create func() (FloatPointAggregator, FloatPointEmitter)
-	opt    IteratorOptions
-	points []FloatPoint
+	input    *bufFloatIterator
+	create   func() (FloatPointAggregator, FloatPointEmitter)
+	opt      IteratorOptions
+	points   []FloatPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *floatReduceFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *floatReduceFloatIterator) Close() error { return itr.input.Close() }
+func (itr *floatReduceFloatIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *floatReduceFloatIterator) Next() (*FloatPoint, error) {
@@ -911,6 +1084,23 @@ type floatReduceFloatPoint struct {
 	Tags       Tags
 	Aggregator FloatPointAggregator
 	Emitter    FloatPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *floatReduceFloatPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -923,6 +1113,10 @@ func (itr *floatReduceFloatIterator) reduce() ([]FloatPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*floatReduceFloatPoint)
 	for {
@@ -945,6 +1139,9 @@ func (itr *floatReduceFloatIterator) reduce() ([]FloatPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &floatReduceFloatPoint{
 				Name:       curr.Name,
@@ -952,9 +1149,15 @@ func (itr *floatReduceFloatIterator) reduce() ([]FloatPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateFloat(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -984,6 +1187,15 @@ func (itr *floatReduceFloatIterator) reduce() ([]FloatPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *floatReduceFloatIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // floatStreamFloatIterator streams inputs into the iterator and emits points gradually.
 type floatStreamFloatIterator struct {
 	input  *bufFloatIterator
@@ -991,6 +1203,8 @@ type floatStreamFloatIterator struct {
 	opt    IteratorOptions
 	m      map[string]*floatReduceFloatPoint
 	points []FloatPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newFloatStreamFloatIterator returns a new instance of floatStreamFloatIterator.
@@ -1007,7 +1221,11 @@ func newFloatStreamFloatIterator(input FloatIterator, createFn func() (FloatPoin
 func (itr *floatStreamFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *floatStreamFloatIterator) Close() error { return itr.input.Close() }
+func (itr *floatStreamFloatIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *floatStreamFloatIterator) Next() (*FloatPoint, error) {
@@ -1047,6 +1265,9 @@ func (itr *floatStreamFloatIterator) reduce() ([]FloatPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &floatReduceFloatPoint{
 				Name:       curr.Name,
@@ -1054,9 +1275,15 @@ func (itr *floatStreamFloatIterator) reduce() ([]FloatPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateFloat(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -1072,12 +1299,22 @@ func (itr *floatStreamFloatIterator) reduce() ([]FloatPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *floatStreamFloatIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // floatExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type floatExprIterator struct {
 	left  *bufFloatIterator
 	right *bufFloatIterator
 	fn    floatExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *floatExprIterator) Stats() IteratorStats {
@@ -1103,6 +1340,19 @@ func (itr *floatExprIterator) Next() (*FloatPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -1114,17 +1364,22 @@ type floatExprFunc func(a *FloatPoint, b *FloatPoint) *FloatPoint
 
 // floatReduceIntegerIterator executes a reducer for every interval and buffers the result.
 type floatReduceIntegerIterator struct {
-	input  *bufFloatIterator
-	create func() (FloatPointAggregator, IntegerPointEmitter)
-	opt    IteratorOptions
-	points []IntegerPoint
+	input    *bufFloatIterator
+	create   func() (FloatPointAggregator, IntegerPointEmitter)
+	opt      IteratorOptions
+	points   []IntegerPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *floatReduceIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *floatReduceIntegerIterator) Close() error { return itr.input.Close() }
+func (itr *floatReduceIntegerIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *floatReduceIntegerIterator) Next() (*IntegerPoint, error) {
@@ -1149,6 +1404,23 @@ type floatReduceIntegerPoint struct {
 	Tags       Tags
 	Aggregator FloatPointAggregator
 	Emitter    IntegerPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *floatReduceIntegerPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -1161,6 +1433,10 @@ func (itr *floatReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*floatReduceIntegerPoint)
 	for {
@@ -1183,6 +1459,9 @@ func (itr *floatReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &floatReduceIntegerPoint{
 				Name:       curr.Name,
@@ -1190,9 +1469,15 @@ func (itr *floatReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateFloat(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -1222,6 +1507,15 @@ func (itr *floatReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *floatReduceIntegerIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // floatStreamIntegerIterator streams inputs into the iterator and emits points gradually.
 type floatStreamIntegerIterator struct {
 	input  *bufFloatIterator
@@ -1229,6 +1523,8 @@ type floatStreamIntegerIterator struct {
 	opt    IteratorOptions
 	m      map[string]*floatReduceIntegerPoint
 	points []IntegerPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newFloatStreamIntegerIterator returns a new instance of floatStreamIntegerIterator.
@@ -1245,7 +1541,11 @@ func newFloatStreamIntegerIterator(input FloatIterator, createFn func() (FloatPo
 func (itr *floatStreamIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *floatStreamIntegerIterator) Close() error { return itr.input.Close() }
+func (itr *floatStreamIntegerIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *floatStreamIntegerIterator) Next() (*IntegerPoint, error) {
@@ -1285,6 +1585,9 @@ func (itr *floatStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &floatReduceIntegerPoint{
 				Name:       curr.Name,
@@ -1292,9 +1595,15 @@ func (itr *floatStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateFloat(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -1310,12 +1619,22 @@ func (itr *floatStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *floatStreamIntegerIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // floatIntegerExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type floatIntegerExprIterator struct {
 	left  *bufFloatIterator
 	right *bufFloatIterator
 	fn    floatIntegerExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *floatIntegerExprIterator) Stats() IteratorStats {
@@ -1341,6 +1660,19 @@ func (itr *floatIntegerExprIterator) Next() (*IntegerPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -1352,17 +1684,22 @@ type floatIntegerExprFunc func(a *FloatPoint, b *FloatPoint) *IntegerPoint
 
 // floatReduceStringIterator executes a reducer for every interval and buffers the result.
 type floatReduceStringIterator struct {
-	input  *bufFloatIterator
-	create func() (FloatPointAggregator, StringPointEmitter)
-	opt    IteratorOptions
-	points []StringPoint
+	input    *bufFloatIterator
+	create   func() (FloatPointAggregator, StringPointEmitter)
+	opt      IteratorOptions
+	points   []StringPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *floatReduceStringIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *floatReduceStringIterator) Close() error { return itr.input.Close() }
+func (itr *floatReduceStringIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *floatReduceStringIterator) Next() (*StringPoint, error) {
@@ -1387,6 +1724,23 @@ type floatReduceStringPoint struct {
 	Tags       Tags
 	Aggregator FloatPointAggregator
 	Emitter    StringPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *floatReduceStringPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -1399,6 +1753,10 @@ func (itr *floatReduceStringIterator) reduce() ([]StringPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*floatReduceStringPoint)
 	for {
@@ -1421,6 +1779,9 @@ func (itr *floatReduceStringIterator) reduce() ([]StringPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &floatReduceStringPoint{
 				Name:       curr.Name,
@@ -1428,9 +1789,15 @@ func (itr *floatReduceStringIterator) reduce() ([]StringPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateFloat(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -1460,6 +1827,15 @@ func (itr *floatReduceStringIterator) reduce() ([]StringPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *floatReduceStringIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // floatStreamStringIterator streams inputs into the iterator and emits points gradually.
 type floatStreamStringIterator struct {
 	input  *bufFloatIterator
@@ -1467,6 +1843,8 @@ type floatStreamStringIterator struct {
 	opt    IteratorOptions
 	m      map[string]*floatReduceStringPoint
 	points []StringPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newFloatStreamStringIterator returns a new instance of floatStreamStringIterator.
@@ -1483,7 +1861,11 @@ func newFloatStreamStringIterator(input FloatIterator, createFn func() (FloatPoi
 func (itr *floatStreamStringIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *floatStreamStringIterator) Close() error { return itr.input.Close() }
+func (itr *floatStreamStringIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *floatStreamStringIterator) Next() (*StringPoint, error) {
@@ -1523,6 +1905,9 @@ func (itr *floatStreamStringIterator) reduce() ([]StringPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &floatReduceStringPoint{
 				Name:       curr.Name,
@@ -1530,9 +1915,15 @@ func (itr *floatStreamStringIterator) reduce() ([]StringPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateFloat(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -1548,12 +1939,22 @@ func (itr *floatStreamStringIterator) reduce() ([]StringPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *floatStreamStringIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // floatStringExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type floatStringExprIterator struct {
 	left  *bufFloatIterator
 	right *bufFloatIterator
 	fn    floatStringExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *floatStringExprIterator) Stats() IteratorStats {
@@ -1579,6 +1980,19 @@ func (itr *floatStringExprIterator) Next() (*StringPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -1590,17 +2004,22 @@ type floatStringExprFunc func(a *FloatPoint, b *FloatPoint) *StringPoint
 
 // floatReduceBooleanIterator executes a reducer for every interval and buffers the result.
 type floatReduceBooleanIterator struct {
-	input  *bufFloatIterator
-	create func() (FloatPointAggregator, BooleanPointEmitter)
-	opt    IteratorOptions
-	points []BooleanPoint
+	input    *bufFloatIterator
+	create   func() (FloatPointAggregator, BooleanPointEmitter)
+	opt      IteratorOptions
+	points   []BooleanPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *floatReduceBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *floatReduceBooleanIterator) Close() error { return itr.input.Close() }
+func (itr *floatReduceBooleanIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *floatReduceBooleanIterator) Next() (*BooleanPoint, error) {
@@ -1625,6 +2044,23 @@ type floatReduceBooleanPoint struct {
 	Tags       Tags
 	Aggregator FloatPointAggregator
 	Emitter    BooleanPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *floatReduceBooleanPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -1637,6 +2073,10 @@ func (itr *floatReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*floatReduceBooleanPoint)
 	for {
@@ -1659,6 +2099,9 @@ func (itr *floatReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &floatReduceBooleanPoint{
 				Name:       curr.Name,
@@ -1666,9 +2109,15 @@ func (itr *floatReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateFloat(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -1698,6 +2147,15 @@ func (itr *floatReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *floatReduceBooleanIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // floatStreamBooleanIterator streams inputs into the iterator and emits points gradually.
 type floatStreamBooleanIterator struct {
 	input  *bufFloatIterator
@@ -1705,6 +2163,8 @@ type floatStreamBooleanIterator struct {
 	opt    IteratorOptions
 	m      map[string]*floatReduceBooleanPoint
 	points []BooleanPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newFloatStreamBooleanIterator returns a new instance of floatStreamBooleanIterator.
@@ -1721,7 +2181,11 @@ func newFloatStreamBooleanIterator(input FloatIterator, createFn func() (FloatPo
 func (itr *floatStreamBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *floatStreamBooleanIterator) Close() error { return itr.input.Close() }
+func (itr *floatStreamBooleanIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *floatStreamBooleanIterator) Next() (*BooleanPoint, error) {
@@ -1761,6 +2225,9 @@ func (itr *floatStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &floatReduceBooleanPoint{
 				Name:       curr.Name,
@@ -1768,9 +2235,15 @@ func (itr *floatStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateFloat(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -1786,12 +2259,22 @@ func (itr *floatStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *floatStreamBooleanIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // floatBooleanExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type floatBooleanExprIterator struct {
 	left  *bufFloatIterator
 	right *bufFloatIterator
 	fn    floatBooleanExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *floatBooleanExprIterator) Stats() IteratorStats {
@@ -1817,6 +2300,19 @@ func (itr *floatBooleanExprIterator) Next() (*BooleanPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -2413,6 +2909,12 @@ func (itr *integerLimitIterator) Close() error { return itr.input.Close() }
 // Next returns the next point from the iterator.
 func (itr *integerLimitIterator) Next() (*IntegerPoint, error) {
 	for {
+		// Stop before reading another point once the limit has been reached
+		// if no other group can follow so the inputs stop reading early.
+		if itr.opt.Limit > 0 && (itr.n-itr.opt.Offset) >= itr.opt.Limit && itr.opt.singleGroup() {
+			return nil, nil
+		}
+
 		p, err := itr.input.Next()
 		if p == nil || err != nil {
 			return nil, err
@@ -2435,10 +2937,6 @@ func (itr *integerLimitIterator) Next() (*IntegerPoint, error) {
 
 		// Read next point if we're beyond the limit.
 		if itr.opt.Limit > 0 && (itr.n-itr.opt.Offset) > itr.opt.Limit {
-			// If there's no interval, no groups, and a single source then simply exit.
-			if itr.opt.Interval.IsZero() && len(itr.opt.Dimensions) == 0 && len(itr.opt.Sources) == 1 {
-				return nil, nil
-			}
 			continue
 		}
 
@@ -2464,7 +2962,7 @@ type integerFillIterator struct {
 
 func newIntegerFillIterator(input IntegerIterator, expr Expr, opt IteratorOptions) *integerFillIterator {
 	if opt.Fill == NullFill {
-		if expr, ok := expr.(*Call); ok && expr.Name == "count" {
+		if expr, ok := expr.(*Call); ok && (expr.Name == "count" || expr.Name == "histogram") {
 			opt.Fill = NumberFill
 			opt.FillValue = int64(0)
 		}
@@ -2507,44 +3005,56 @@ func (itr *integerFillIterator) Next() (*IntegerPoint, error) {
 		itr.init = true
 	}
 
-	p, err := itr.input.Next()
-	if err != nil {
-		return nil, err
-	}
+	var p *IntegerPoint
+	for {
+		var err error
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
 
-	// Check if the next point is outside of our window or is nil.
-	for p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
-		// If we are inside of an interval, unread the point and continue below to
-		// constructing a new point.
-		if itr.opt.Ascending {
-			if itr.window.time <= itr.endTime {
-				itr.input.unread(p)
-				p = nil
-				break
+		// Check if the next point is outside of our window or is nil.
+		for p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// If we are inside of an interval, unread the point and continue below to
+			// constructing a new point.
+			if itr.opt.Ascending {
+				if itr.window.time <= itr.endTime {
+					itr.input.unread(p)
+					p = nil
+					break
+				}
+			} else {
+				if itr.window.time >= itr.endTime {
+					itr.input.unread(p)
+					p = nil
+					break
+				}
 			}
-		} else {
-			if itr.window.time >= itr.endTime {
-				itr.input.unread(p)
-				p = nil
-				break
+
+			// We are *not* in a current interval. If there is no next point,
+			// we are at the end of all intervals.
+			if p == nil {
+				return nil, nil
 			}
-		}
 
-		// We are *not* in a current interval. If there is no next point,
-		// we are at the end of all intervals.
-		if p == nil {
-			return nil, nil
+			// Set the new interval.
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+			itr.prev = nil
+			break
 		}
 
-		// Set the new interval.
-		itr.window.name, itr.window.tags = p.Name, p.Tags
-		itr.window.time = itr.startTime
-		itr.prev = nil
+		// Points before the start of the range are only read when fill(previous)
+		// looks back for a previous value so they are not returned.
+		if p != nil && itr.opt.Ascending && p.Time < itr.startTime {
+			itr.prev = p
+			continue
+		}
 		break
 	}
 
 	// Check if the point is our next expected point.
 	if p == nil || (itr.opt.Ascending && p.Time > itr.window.time) || (!itr.opt.Ascending && p.Time < itr.window.time) {
+		next := p
 		if p != nil {
 			itr.input.unread(p)
 		}
@@ -2568,6 +3078,13 @@ func (itr *integerFillIterator) Next() (*IntegerPoint, error) {
 			} else {
 				p.Nil = true
 			}
+		case LinearFill:
+			// Interpolate between the previous and next points of the series.
+			if itr.prev != nil && !itr.prev.Nil && next != nil && !next.Nil {
+				p.Value = linearInteger(itr.window.time, itr.prev.Time, next.Time, itr.prev.Value, next.Value)
+			} else {
+				p.Nil = true
+			}
 		}
 	} else {
 		itr.prev = p
@@ -2575,11 +3092,12 @@ func (itr *integerFillIterator) Next() (*IntegerPoint, error) {
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. The interval is looked up so it follows the time zone when a
+	// daylight saving time change makes it shorter or longer.
 	if itr.opt.Ascending {
-		itr.window.time = p.Time + int64(itr.opt.Interval.Duration)
+		_, itr.window.time = itr.opt.Window(p.Time)
 	} else {
-		itr.window.time = p.Time - int64(itr.opt.Interval.Duration)
+		itr.window.time, _ = itr.opt.Window(p.Time - 1)
 	}
 	return p, nil
 }
@@ -2640,6 +3158,150 @@ func (itr *integerInterruptIterator) Next() (*IntegerPoint, error) {
 	return itr.input.Next()
 }
 
+// integerParallelIterator reads its input in batches on a separate goroutine.
+type integerParallelIterator struct {
+	input   IntegerIterator
+	budget  *MemoryBudget
+	ch      chan integerParallelBatch
+	closing chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	batch     []IntegerPoint
+	batchSize int64 // bytes reserved for batch
+	err       error
+
+	mu    sync.Mutex
+	stats IteratorStats
+}
+
+// integerParallelBatch is a batch of points read by a integerParallelIterator
+// and the error that ended it, if any.
+type integerParallelBatch struct {
+	points []IntegerPoint
+	size   int64
+	err    error
+}
+
+func newIntegerParallelIterator(input IntegerIterator, limiter chan struct{}, budget *MemoryBudget) *integerParallelIterator {
+	itr := &integerParallelIterator{
+		input:   input,
+		budget:  budget,
+		ch:      make(chan integerParallelBatch, 1),
+		closing: make(chan struct{}),
+	}
+	itr.wg.Add(1)
+	go itr.monitor(limiter)
+	return itr
+}
+
+// Stats returns the stats of the input as of the last batch read.
+func (itr *integerParallelIterator) Stats() IteratorStats {
+	itr.mu.Lock()
+	defer itr.mu.Unlock()
+	return itr.stats
+}
+
+// Close stops reading the input and closes it.
+func (itr *integerParallelIterator) Close() error {
+	itr.once.Do(func() { close(itr.closing) })
+	itr.wg.Wait()
+
+	// Release the memory of the batches that were not read.
+	itr.budget.Release(itr.batchSize)
+	itr.batch, itr.batchSize = nil, 0
+	for b := range itr.ch {
+		itr.budget.Release(b.size)
+	}
+	return itr.input.Close()
+}
+
+// Next returns the next point read from the input.
+func (itr *integerParallelIterator) Next() (*IntegerPoint, error) {
+	for len(itr.batch) == 0 {
+		if itr.err != nil {
+			err := itr.err
+			itr.err = nil
+			return nil, err
+		}
+
+		itr.budget.Release(itr.batchSize)
+		itr.batchSize = 0
+
+		b, ok := <-itr.ch
+		if !ok {
+			return nil, nil
+		}
+		itr.batch, itr.batchSize, itr.err = b.points, b.size, b.err
+	}
+
+	p := &itr.batch[0]
+	itr.batch = itr.batch[1:]
+	return p, nil
+}
+
+// monitor reads batches from the input while holding a slot in limiter and
+// sends them to Next until the input is exhausted or the iterator is closed.
+func (itr *integerParallelIterator) monitor(limiter chan struct{}) {
+	defer itr.wg.Done()
+	defer close(itr.ch)
+
+	for {
+		select {
+		case limiter <- struct{}{}:
+		case <-itr.closing:
+			return
+		}
+		points, size, err := itr.read()
+		<-limiter
+
+		if len(points) > 0 || err != nil {
+			select {
+			case itr.ch <- integerParallelBatch{points: points, size: size, err: err}:
+			case <-itr.closing:
+				itr.budget.Release(size)
+				return
+			}
+		}
+
+		// A short batch means the input is exhausted.
+		if err != nil || len(points) < parallelBatchSize {
+			return
+		}
+	}
+}
+
+// read reads up to parallelBatchSize points from the input and reserves
+// their memory from the budget.  The points are copied since the input may
+// reuse them.
+func (itr *integerParallelIterator) read() ([]IntegerPoint, int64, error) {
+	points := make([]IntegerPoint, 0, parallelBatchSize)
+	defer func() {
+		stats := itr.input.Stats()
+		itr.mu.Lock()
+		itr.stats = stats
+		itr.mu.Unlock()
+	}()
+
+	var size int64
+	for len(points) < parallelBatchSize {
+		p, err := itr.input.Next()
+		if err != nil {
+			return points, size, err
+		} else if p == nil {
+			break
+		}
+
+		n := p.size()
+		if err := itr.budget.Reserve(n); err != nil {
+			return points, size, err
+		}
+		size += n
+		points = append(points, *p.Clone())
+	}
+	return points, size, nil
+}
+
 // auxIntegerPoint represents a combination of a point and an error for the AuxIterator.
 type auxIntegerPoint struct {
 	point *IntegerPoint
@@ -2675,7 +3337,9 @@ func (itr *integerAuxIterator) Next() (*IntegerPoint, error) {
 	p := <-itr.output
 	return p.point, p.err
 }
-func (itr *integerAuxIterator) Iterator(name string) Iterator { return itr.fields.iterator(name) }
+func (itr *integerAuxIterator) Iterator(name string, typ DataType) Iterator {
+	return itr.fields.iterator(name, typ)
+}
 
 func (itr *integerAuxIterator) CreateIterator(opt IteratorOptions) (Iterator, error) {
 	expr := opt.Expr
@@ -2685,7 +3349,7 @@ func (itr *integerAuxIterator) CreateIterator(opt IteratorOptions) (Iterator, er
 
 	switch expr := expr.(type) {
 	case *VarRef:
-		return itr.Iterator(expr.Val), nil
+		return itr.Iterator(expr.Val, expr.Type), nil
 	default:
 		panic(fmt.Sprintf("invalid expression type for an aux iterator: %T", expr))
 	}
@@ -2822,17 +3486,22 @@ func (itr *integerChanIterator) Next() (*IntegerPoint, error) {
 
 // integerReduceFloatIterator executes a reducer for every interval and buffers the result.
 type integerReduceFloatIterator struct {
-	input  *bufIntegerIterator
-	create func() (IntegerPointAggregator, FloatPointEmitter)
-	opt    IteratorOptions
-	points []FloatPoint
+	input    *bufIntegerIterator
+	create   func() (IntegerPointAggregator, FloatPointEmitter)
+	opt      IteratorOptions
+	points   []FloatPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *integerReduceFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *integerReduceFloatIterator) Close() error { return itr.input.Close() }
+func (itr *integerReduceFloatIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *integerReduceFloatIterator) Next() (*FloatPoint, error) {
@@ -2857,6 +3526,23 @@ type integerReduceFloatPoint struct {
 	Tags       Tags
 	Aggregator IntegerPointAggregator
 	Emitter    FloatPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *integerReduceFloatPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -2869,6 +3555,10 @@ func (itr *integerReduceFloatIterator) reduce() ([]FloatPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*integerReduceFloatPoint)
 	for {
@@ -2891,6 +3581,9 @@ func (itr *integerReduceFloatIterator) reduce() ([]FloatPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &integerReduceFloatPoint{
 				Name:       curr.Name,
@@ -2898,9 +3591,15 @@ func (itr *integerReduceFloatIterator) reduce() ([]FloatPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateInteger(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -2930,6 +3629,15 @@ func (itr *integerReduceFloatIterator) reduce() ([]FloatPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *integerReduceFloatIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // integerStreamFloatIterator streams inputs into the iterator and emits points gradually.
 type integerStreamFloatIterator struct {
 	input  *bufIntegerIterator
@@ -2937,6 +3645,8 @@ type integerStreamFloatIterator struct {
 	opt    IteratorOptions
 	m      map[string]*integerReduceFloatPoint
 	points []FloatPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newIntegerStreamFloatIterator returns a new instance of integerStreamFloatIterator.
@@ -2953,7 +3663,11 @@ func newIntegerStreamFloatIterator(input IntegerIterator, createFn func() (Integ
 func (itr *integerStreamFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *integerStreamFloatIterator) Close() error { return itr.input.Close() }
+func (itr *integerStreamFloatIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *integerStreamFloatIterator) Next() (*FloatPoint, error) {
@@ -2993,6 +3707,9 @@ func (itr *integerStreamFloatIterator) reduce() ([]FloatPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &integerReduceFloatPoint{
 				Name:       curr.Name,
@@ -3000,9 +3717,15 @@ func (itr *integerStreamFloatIterator) reduce() ([]FloatPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateInteger(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -3018,12 +3741,22 @@ func (itr *integerStreamFloatIterator) reduce() ([]FloatPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *integerStreamFloatIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // integerFloatExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type integerFloatExprIterator struct {
 	left  *bufIntegerIterator
 	right *bufIntegerIterator
 	fn    integerFloatExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *integerFloatExprIterator) Stats() IteratorStats {
@@ -3049,6 +3782,19 @@ func (itr *integerFloatExprIterator) Next() (*FloatPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -3060,17 +3806,22 @@ type integerFloatExprFunc func(a *IntegerPoint, b *IntegerPoint) *FloatPoint
 
 // integerReduceIntegerIterator executes a reducer for every interval and buffers the result.
 type integerReduceIntegerIterator struct {
-	input  *bufIntegerIterator
-	create func() (IntegerPointAggregator, IntegerPointEmitter)
-	opt    IteratorOptions
-	points []IntegerPoint
+	input    *bufIntegerIterator
+	create   func() (IntegerPointAggregator, IntegerPointEmitter)
+	opt      IteratorOptions
+	points   []IntegerPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *integerReduceIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *integerReduceIntegerIterator) Close() error { return itr.input.Close() }
+func (itr *integerReduceIntegerIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *integerReduceIntegerIterator) Next() (*IntegerPoint, error) {
@@ -3095,6 +3846,23 @@ type integerReduceIntegerPoint struct {
 	Tags       Tags
 	Aggregator IntegerPointAggregator
 	Emitter    IntegerPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *integerReduceIntegerPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -3107,6 +3875,10 @@ func (itr *integerReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*integerReduceIntegerPoint)
 	for {
@@ -3129,6 +3901,9 @@ func (itr *integerReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &integerReduceIntegerPoint{
 				Name:       curr.Name,
@@ -3136,9 +3911,15 @@ func (itr *integerReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateInteger(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -3168,6 +3949,15 @@ func (itr *integerReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *integerReduceIntegerIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // integerStreamIntegerIterator streams inputs into the iterator and emits points gradually.
 type integerStreamIntegerIterator struct {
 	input  *bufIntegerIterator
@@ -3175,6 +3965,8 @@ type integerStreamIntegerIterator struct {
 	opt    IteratorOptions
 	m      map[string]*integerReduceIntegerPoint
 	points []IntegerPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newIntegerStreamIntegerIterator returns a new instance of integerStreamIntegerIterator.
@@ -3191,7 +3983,11 @@ func newIntegerStreamIntegerIterator(input IntegerIterator, createFn func() (Int
 func (itr *integerStreamIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *integerStreamIntegerIterator) Close() error { return itr.input.Close() }
+func (itr *integerStreamIntegerIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *integerStreamIntegerIterator) Next() (*IntegerPoint, error) {
@@ -3231,6 +4027,9 @@ func (itr *integerStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &integerReduceIntegerPoint{
 				Name:       curr.Name,
@@ -3238,9 +4037,15 @@ func (itr *integerStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateInteger(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -3256,12 +4061,22 @@ func (itr *integerStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *integerStreamIntegerIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // integerExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type integerExprIterator struct {
 	left  *bufIntegerIterator
 	right *bufIntegerIterator
 	fn    integerExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *integerExprIterator) Stats() IteratorStats {
@@ -3287,6 +4102,19 @@ func (itr *integerExprIterator) Next() (*IntegerPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -3298,17 +4126,22 @@ type integerExprFunc func(a *IntegerPoint, b *IntegerPoint) *IntegerPoint
 
 // integerReduceStringIterator executes a reducer for every interval and buffers the result.
 type integerReduceStringIterator struct {
-	input  *bufIntegerIterator
-	create func() (IntegerPointAggregator, StringPointEmitter)
-	opt    IteratorOptions
-	points []StringPoint
+	input    *bufIntegerIterator
+	create   func() (IntegerPointAggregator, StringPointEmitter)
+	opt      IteratorOptions
+	points   []StringPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *integerReduceStringIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *integerReduceStringIterator) Close() error { return itr.input.Close() }
+func (itr *integerReduceStringIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *integerReduceStringIterator) Next() (*StringPoint, error) {
@@ -3333,6 +4166,23 @@ type integerReduceStringPoint struct {
 	Tags       Tags
 	Aggregator IntegerPointAggregator
 	Emitter    StringPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *integerReduceStringPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -3345,6 +4195,10 @@ func (itr *integerReduceStringIterator) reduce() ([]StringPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*integerReduceStringPoint)
 	for {
@@ -3367,6 +4221,9 @@ func (itr *integerReduceStringIterator) reduce() ([]StringPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &integerReduceStringPoint{
 				Name:       curr.Name,
@@ -3374,9 +4231,15 @@ func (itr *integerReduceStringIterator) reduce() ([]StringPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateInteger(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -3406,6 +4269,15 @@ func (itr *integerReduceStringIterator) reduce() ([]StringPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *integerReduceStringIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // integerStreamStringIterator streams inputs into the iterator and emits points gradually.
 type integerStreamStringIterator struct {
 	input  *bufIntegerIterator
@@ -3413,6 +4285,8 @@ type integerStreamStringIterator struct {
 	opt    IteratorOptions
 	m      map[string]*integerReduceStringPoint
 	points []StringPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newIntegerStreamStringIterator returns a new instance of integerStreamStringIterator.
@@ -3429,7 +4303,11 @@ func newIntegerStreamStringIterator(input IntegerIterator, createFn func() (Inte
 func (itr *integerStreamStringIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *integerStreamStringIterator) Close() error { return itr.input.Close() }
+func (itr *integerStreamStringIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *integerStreamStringIterator) Next() (*StringPoint, error) {
@@ -3469,6 +4347,9 @@ func (itr *integerStreamStringIterator) reduce() ([]StringPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &integerReduceStringPoint{
 				Name:       curr.Name,
@@ -3476,9 +4357,15 @@ func (itr *integerStreamStringIterator) reduce() ([]StringPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateInteger(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -3494,12 +4381,22 @@ func (itr *integerStreamStringIterator) reduce() ([]StringPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *integerStreamStringIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // integerStringExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type integerStringExprIterator struct {
 	left  *bufIntegerIterator
 	right *bufIntegerIterator
 	fn    integerStringExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *integerStringExprIterator) Stats() IteratorStats {
@@ -3525,6 +4422,19 @@ func (itr *integerStringExprIterator) Next() (*StringPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -3536,17 +4446,22 @@ type integerStringExprFunc func(a *IntegerPoint, b *IntegerPoint) *StringPoint
 
 // integerReduceBooleanIterator executes a reducer for every interval and buffers the result.
 type integerReduceBooleanIterator struct {
-	input  *bufIntegerIterator
-	create func() (IntegerPointAggregator, BooleanPointEmitter)
-	opt    IteratorOptions
-	points []BooleanPoint
+	input    *bufIntegerIterator
+	create   func() (IntegerPointAggregator, BooleanPointEmitter)
+	opt      IteratorOptions
+	points   []BooleanPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *integerReduceBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *integerReduceBooleanIterator) Close() error { return itr.input.Close() }
+func (itr *integerReduceBooleanIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *integerReduceBooleanIterator) Next() (*BooleanPoint, error) {
@@ -3571,6 +4486,23 @@ type integerReduceBooleanPoint struct {
 	Tags       Tags
 	Aggregator IntegerPointAggregator
 	Emitter    BooleanPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *integerReduceBooleanPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -3583,6 +4515,10 @@ func (itr *integerReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*integerReduceBooleanPoint)
 	for {
@@ -3605,6 +4541,9 @@ func (itr *integerReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &integerReduceBooleanPoint{
 				Name:       curr.Name,
@@ -3612,9 +4551,15 @@ func (itr *integerReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateInteger(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -3644,6 +4589,15 @@ func (itr *integerReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *integerReduceBooleanIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // integerStreamBooleanIterator streams inputs into the iterator and emits points gradually.
 type integerStreamBooleanIterator struct {
 	input  *bufIntegerIterator
@@ -3651,6 +4605,8 @@ type integerStreamBooleanIterator struct {
 	opt    IteratorOptions
 	m      map[string]*integerReduceBooleanPoint
 	points []BooleanPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newIntegerStreamBooleanIterator returns a new instance of integerStreamBooleanIterator.
@@ -3667,7 +4623,11 @@ func newIntegerStreamBooleanIterator(input IntegerIterator, createFn func() (Int
 func (itr *integerStreamBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *integerStreamBooleanIterator) Close() error { return itr.input.Close() }
+func (itr *integerStreamBooleanIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *integerStreamBooleanIterator) Next() (*BooleanPoint, error) {
@@ -3707,6 +4667,9 @@ func (itr *integerStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &integerReduceBooleanPoint{
 				Name:       curr.Name,
@@ -3714,9 +4677,15 @@ func (itr *integerStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateInteger(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -3732,12 +4701,22 @@ func (itr *integerStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *integerStreamBooleanIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // integerBooleanExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type integerBooleanExprIterator struct {
 	left  *bufIntegerIterator
 	right *bufIntegerIterator
 	fn    integerBooleanExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *integerBooleanExprIterator) Stats() IteratorStats {
@@ -3763,6 +4742,19 @@ func (itr *integerBooleanExprIterator) Next() (*BooleanPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -4359,6 +5351,12 @@ func (itr *stringLimitIterator) Close() error { return itr.input.Close() }
 // Next returns the next point from the iterator.
 func (itr *stringLimitIterator) Next() (*StringPoint, error) {
 	for {
+		// Stop before reading another point once the limit has been reached
+		// if no other group can follow so the inputs stop reading early.
+		if itr.opt.Limit > 0 && (itr.n-itr.opt.Offset) >= itr.opt.Limit && itr.opt.singleGroup() {
+			return nil, nil
+		}
+
 		p, err := itr.input.Next()
 		if p == nil || err != nil {
 			return nil, err
@@ -4381,10 +5379,6 @@ func (itr *stringLimitIterator) Next() (*StringPoint, error) {
 
 		// Read next point if we're beyond the limit.
 		if itr.opt.Limit > 0 && (itr.n-itr.opt.Offset) > itr.opt.Limit {
-			// If there's no interval, no groups, and a single source then simply exit.
-			if itr.opt.Interval.IsZero() && len(itr.opt.Dimensions) == 0 && len(itr.opt.Sources) == 1 {
-				return nil, nil
-			}
 			continue
 		}
 
@@ -4410,7 +5404,7 @@ type stringFillIterator struct {
 
 func newStringFillIterator(input StringIterator, expr Expr, opt IteratorOptions) *stringFillIterator {
 	if opt.Fill == NullFill {
-		if expr, ok := expr.(*Call); ok && expr.Name == "count" {
+		if expr, ok := expr.(*Call); ok && (expr.Name == "count" || expr.Name == "histogram") {
 			opt.Fill = NumberFill
 			opt.FillValue = ""
 		}
@@ -4453,39 +5447,50 @@ func (itr *stringFillIterator) Next() (*StringPoint, error) {
 		itr.init = true
 	}
 
-	p, err := itr.input.Next()
-	if err != nil {
-		return nil, err
-	}
+	var p *StringPoint
+	for {
+		var err error
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
 
-	// Check if the next point is outside of our window or is nil.
-	for p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
-		// If we are inside of an interval, unread the point and continue below to
-		// constructing a new point.
-		if itr.opt.Ascending {
-			if itr.window.time <= itr.endTime {
-				itr.input.unread(p)
-				p = nil
-				break
+		// Check if the next point is outside of our window or is nil.
+		for p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// If we are inside of an interval, unread the point and continue below to
+			// constructing a new point.
+			if itr.opt.Ascending {
+				if itr.window.time <= itr.endTime {
+					itr.input.unread(p)
+					p = nil
+					break
+				}
+			} else {
+				if itr.window.time >= itr.endTime {
+					itr.input.unread(p)
+					p = nil
+					break
+				}
 			}
-		} else {
-			if itr.window.time >= itr.endTime {
-				itr.input.unread(p)
-				p = nil
-				break
+
+			// We are *not* in a current interval. If there is no next point,
+			// we are at the end of all intervals.
+			if p == nil {
+				return nil, nil
 			}
-		}
 
-		// We are *not* in a current interval. If there is no next point,
-		// we are at the end of all intervals.
-		if p == nil {
-			return nil, nil
+			// Set the new interval.
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+			itr.prev = nil
+			break
 		}
 
-		// Set the new interval.
-		itr.window.name, itr.window.tags = p.Name, p.Tags
-		itr.window.time = itr.startTime
-		itr.prev = nil
+		// Points before the start of the range are only read when fill(previous)
+		// looks back for a previous value so they are not returned.
+		if p != nil && itr.opt.Ascending && p.Time < itr.startTime {
+			itr.prev = p
+			continue
+		}
 		break
 	}
 
@@ -4514,6 +5519,8 @@ func (itr *stringFillIterator) Next() (*StringPoint, error) {
 			} else {
 				p.Nil = true
 			}
+		case LinearFill:
+			p.Nil = true
 		}
 	} else {
 		itr.prev = p
@@ -4521,11 +5528,12 @@ func (itr *stringFillIterator) Next() (*StringPoint, error) {
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. The interval is looked up so it follows the time zone when a
+	// daylight saving time change makes it shorter or longer.
 	if itr.opt.Ascending {
-		itr.window.time = p.Time + int64(itr.opt.Interval.Duration)
+		_, itr.window.time = itr.opt.Window(p.Time)
 	} else {
-		itr.window.time = p.Time - int64(itr.opt.Interval.Duration)
+		itr.window.time, _ = itr.opt.Window(p.Time - 1)
 	}
 	return p, nil
 }
@@ -4586,6 +5594,150 @@ func (itr *stringInterruptIterator) Next() (*StringPoint, error) {
 	return itr.input.Next()
 }
 
+// stringParallelIterator reads its input in batches on a separate goroutine.
+type stringParallelIterator struct {
+	input   StringIterator
+	budget  *MemoryBudget
+	ch      chan stringParallelBatch
+	closing chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	batch     []StringPoint
+	batchSize int64 // bytes reserved for batch
+	err       error
+
+	mu    sync.Mutex
+	stats IteratorStats
+}
+
+// stringParallelBatch is a batch of points read by a stringParallelIterator
+// and the error that ended it, if any.
+type stringParallelBatch struct {
+	points []StringPoint
+	size   int64
+	err    error
+}
+
+func newStringParallelIterator(input StringIterator, limiter chan struct{}, budget *MemoryBudget) *stringParallelIterator {
+	itr := &stringParallelIterator{
+		input:   input,
+		budget:  budget,
+		ch:      make(chan stringParallelBatch, 1),
+		closing: make(chan struct{}),
+	}
+	itr.wg.Add(1)
+	go itr.monitor(limiter)
+	return itr
+}
+
+// Stats returns the stats of the input as of the last batch read.
+func (itr *stringParallelIterator) Stats() IteratorStats {
+	itr.mu.Lock()
+	defer itr.mu.Unlock()
+	return itr.stats
+}
+
+// Close stops reading the input and closes it.
+func (itr *stringParallelIterator) Close() error {
+	itr.once.Do(func() { close(itr.closing) })
+	itr.wg.Wait()
+
+	// Release the memory of the batches that were not read.
+	itr.budget.Release(itr.batchSize)
+	itr.batch, itr.batchSize = nil, 0
+	for b := range itr.ch {
+		itr.budget.Release(b.size)
+	}
+	return itr.input.Close()
+}
+
+// Next returns the next point read from the input.
+func (itr *stringParallelIterator) Next() (*StringPoint, error) {
+	for len(itr.batch) == 0 {
+		if itr.err != nil {
+			err := itr.err
+			itr.err = nil
+			return nil, err
+		}
+
+		itr.budget.Release(itr.batchSize)
+		itr.batchSize = 0
+
+		b, ok := <-itr.ch
+		if !ok {
+			return nil, nil
+		}
+		itr.batch, itr.batchSize, itr.err = b.points, b.size, b.err
+	}
+
+	p := &itr.batch[0]
+	itr.batch = itr.batch[1:]
+	return p, nil
+}
+
+// monitor reads batches from the input while holding a slot in limiter and
+// sends them to Next until the input is exhausted or the iterator is closed.
+func (itr *stringParallelIterator) monitor(limiter chan struct{}) {
+	defer itr.wg.Done()
+	defer close(itr.ch)
+
+	for {
+		select {
+		case limiter <- struct{}{}:
+		case <-itr.closing:
+			return
+		}
+		points, size, err := itr.read()
+		<-limiter
+
+		if len(points) > 0 || err != nil {
+			select {
+			case itr.ch <- stringParallelBatch{points: points, size: size, err: err}:
+			case <-itr.closing:
+				itr.budget.Release(size)
+				return
+			}
+		}
+
+		// A short batch means the input is exhausted.
+		if err != nil || len(points) < parallelBatchSize {
+			return
+		}
+	}
+}
+
+// read reads up to parallelBatchSize points from the input and reserves
+// their memory from the budget.  The points are copied since the input may
+// reuse them.
+func (itr *stringParallelIterator) read() ([]StringPoint, int64, error) {
+	points := make([]StringPoint, 0, parallelBatchSize)
+	defer func() {
+		stats := itr.input.Stats()
+		itr.mu.Lock()
+		itr.stats = stats
+		itr.mu.Unlock()
+	}()
+
+	var size int64
+	for len(points) < parallelBatchSize {
+		p, err := itr.input.Next()
+		if err != nil {
+			return points, size, err
+		} else if p == nil {
+			break
+		}
+
+		n := p.size()
+		if err := itr.budget.Reserve(n); err != nil {
+			return points, size, err
+		}
+		size += n
+		points = append(points, *p.Clone())
+	}
+	return points, size, nil
+}
+
 // auxStringPoint represents a combination of a point and an error for the AuxIterator.
 type auxStringPoint struct {
 	point *StringPoint
@@ -4621,7 +5773,9 @@ func (itr *stringAuxIterator) Next() (*StringPoint, error) {
 	p := <-itr.output
 	return p.point, p.err
 }
-func (itr *stringAuxIterator) Iterator(name string) Iterator { return itr.fields.iterator(name) }
+func (itr *stringAuxIterator) Iterator(name string, typ DataType) Iterator {
+	return itr.fields.iterator(name, typ)
+}
 
 func (itr *stringAuxIterator) CreateIterator(opt IteratorOptions) (Iterator, error) {
 	expr := opt.Expr
@@ -4631,7 +5785,7 @@ func (itr *stringAuxIterator) CreateIterator(opt IteratorOptions) (Iterator, err
 
 	switch expr := expr.(type) {
 	case *VarRef:
-		return itr.Iterator(expr.Val), nil
+		return itr.Iterator(expr.Val, expr.Type), nil
 	default:
 		panic(fmt.Sprintf("invalid expression type for an aux iterator: %T", expr))
 	}
@@ -4768,17 +5922,22 @@ func (itr *stringChanIterator) Next() (*StringPoint, error) {
 
 // stringReduceFloatIterator executes a reducer for every interval and buffers the result.
 type stringReduceFloatIterator struct {
-	input  *bufStringIterator
-	create func() (StringPointAggregator, FloatPointEmitter)
-	opt    IteratorOptions
-	points []FloatPoint
+	input    *bufStringIterator
+	create   func() (StringPointAggregator, FloatPointEmitter)
+	opt      IteratorOptions
+	points   []FloatPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *stringReduceFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *stringReduceFloatIterator) Close() error { return itr.input.Close() }
+func (itr *stringReduceFloatIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *stringReduceFloatIterator) Next() (*FloatPoint, error) {
@@ -4803,6 +5962,23 @@ type stringReduceFloatPoint struct {
 	Tags       Tags
 	Aggregator StringPointAggregator
 	Emitter    FloatPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *stringReduceFloatPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -4815,6 +5991,10 @@ func (itr *stringReduceFloatIterator) reduce() ([]FloatPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*stringReduceFloatPoint)
 	for {
@@ -4837,6 +6017,9 @@ func (itr *stringReduceFloatIterator) reduce() ([]FloatPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &stringReduceFloatPoint{
 				Name:       curr.Name,
@@ -4844,9 +6027,15 @@ func (itr *stringReduceFloatIterator) reduce() ([]FloatPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateString(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -4876,6 +6065,15 @@ func (itr *stringReduceFloatIterator) reduce() ([]FloatPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *stringReduceFloatIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // stringStreamFloatIterator streams inputs into the iterator and emits points gradually.
 type stringStreamFloatIterator struct {
 	input  *bufStringIterator
@@ -4883,6 +6081,8 @@ type stringStreamFloatIterator struct {
 	opt    IteratorOptions
 	m      map[string]*stringReduceFloatPoint
 	points []FloatPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newStringStreamFloatIterator returns a new instance of stringStreamFloatIterator.
@@ -4899,7 +6099,11 @@ func newStringStreamFloatIterator(input StringIterator, createFn func() (StringP
 func (itr *stringStreamFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *stringStreamFloatIterator) Close() error { return itr.input.Close() }
+func (itr *stringStreamFloatIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *stringStreamFloatIterator) Next() (*FloatPoint, error) {
@@ -4939,6 +6143,9 @@ func (itr *stringStreamFloatIterator) reduce() ([]FloatPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &stringReduceFloatPoint{
 				Name:       curr.Name,
@@ -4946,9 +6153,15 @@ func (itr *stringStreamFloatIterator) reduce() ([]FloatPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateString(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -4964,12 +6177,22 @@ func (itr *stringStreamFloatIterator) reduce() ([]FloatPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *stringStreamFloatIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // stringFloatExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type stringFloatExprIterator struct {
 	left  *bufStringIterator
 	right *bufStringIterator
 	fn    stringFloatExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *stringFloatExprIterator) Stats() IteratorStats {
@@ -4995,6 +6218,19 @@ func (itr *stringFloatExprIterator) Next() (*FloatPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -5006,17 +6242,22 @@ type stringFloatExprFunc func(a *StringPoint, b *StringPoint) *FloatPoint
 
 // stringReduceIntegerIterator executes a reducer for every interval and buffers the result.
 type stringReduceIntegerIterator struct {
-	input  *bufStringIterator
-	create func() (StringPointAggregator, IntegerPointEmitter)
-	opt    IteratorOptions
-	points []IntegerPoint
+	input    *bufStringIterator
+	create   func() (StringPointAggregator, IntegerPointEmitter)
+	opt      IteratorOptions
+	points   []IntegerPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *stringReduceIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *stringReduceIntegerIterator) Close() error { return itr.input.Close() }
+func (itr *stringReduceIntegerIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *stringReduceIntegerIterator) Next() (*IntegerPoint, error) {
@@ -5041,6 +6282,23 @@ type stringReduceIntegerPoint struct {
 	Tags       Tags
 	Aggregator StringPointAggregator
 	Emitter    IntegerPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *stringReduceIntegerPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -5053,6 +6311,10 @@ func (itr *stringReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*stringReduceIntegerPoint)
 	for {
@@ -5075,6 +6337,9 @@ func (itr *stringReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &stringReduceIntegerPoint{
 				Name:       curr.Name,
@@ -5082,9 +6347,15 @@ func (itr *stringReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateString(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -5114,6 +6385,15 @@ func (itr *stringReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *stringReduceIntegerIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // stringStreamIntegerIterator streams inputs into the iterator and emits points gradually.
 type stringStreamIntegerIterator struct {
 	input  *bufStringIterator
@@ -5121,6 +6401,8 @@ type stringStreamIntegerIterator struct {
 	opt    IteratorOptions
 	m      map[string]*stringReduceIntegerPoint
 	points []IntegerPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newStringStreamIntegerIterator returns a new instance of stringStreamIntegerIterator.
@@ -5137,7 +6419,11 @@ func newStringStreamIntegerIterator(input StringIterator, createFn func() (Strin
 func (itr *stringStreamIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *stringStreamIntegerIterator) Close() error { return itr.input.Close() }
+func (itr *stringStreamIntegerIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *stringStreamIntegerIterator) Next() (*IntegerPoint, error) {
@@ -5177,6 +6463,9 @@ func (itr *stringStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &stringReduceIntegerPoint{
 				Name:       curr.Name,
@@ -5184,9 +6473,15 @@ func (itr *stringStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateString(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -5202,12 +6497,22 @@ func (itr *stringStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *stringStreamIntegerIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // stringIntegerExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type stringIntegerExprIterator struct {
 	left  *bufStringIterator
 	right *bufStringIterator
 	fn    stringIntegerExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *stringIntegerExprIterator) Stats() IteratorStats {
@@ -5233,6 +6538,19 @@ func (itr *stringIntegerExprIterator) Next() (*IntegerPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -5244,17 +6562,22 @@ type stringIntegerExprFunc func(a *StringPoint, b *StringPoint) *IntegerPoint
 
 // stringReduceStringIterator executes a reducer for every interval and buffers the result.
 type stringReduceStringIterator struct {
-	input  *bufStringIterator
-	create func() (StringPointAggregator, StringPointEmitter)
-	opt    IteratorOptions
-	points []StringPoint
+	input    *bufStringIterator
+	create   func() (StringPointAggregator, StringPointEmitter)
+	opt      IteratorOptions
+	points   []StringPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *stringReduceStringIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *stringReduceStringIterator) Close() error { return itr.input.Close() }
+func (itr *stringReduceStringIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *stringReduceStringIterator) Next() (*StringPoint, error) {
@@ -5279,6 +6602,23 @@ type stringReduceStringPoint struct {
 	Tags       Tags
 	Aggregator StringPointAggregator
 	Emitter    StringPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *stringReduceStringPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -5291,6 +6631,10 @@ func (itr *stringReduceStringIterator) reduce() ([]StringPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*stringReduceStringPoint)
 	for {
@@ -5313,6 +6657,9 @@ func (itr *stringReduceStringIterator) reduce() ([]StringPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &stringReduceStringPoint{
 				Name:       curr.Name,
@@ -5320,9 +6667,15 @@ func (itr *stringReduceStringIterator) reduce() ([]StringPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateString(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -5352,6 +6705,15 @@ func (itr *stringReduceStringIterator) reduce() ([]StringPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *stringReduceStringIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // stringStreamStringIterator streams inputs into the iterator and emits points gradually.
 type stringStreamStringIterator struct {
 	input  *bufStringIterator
@@ -5359,6 +6721,8 @@ type stringStreamStringIterator struct {
 	opt    IteratorOptions
 	m      map[string]*stringReduceStringPoint
 	points []StringPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newStringStreamStringIterator returns a new instance of stringStreamStringIterator.
@@ -5375,7 +6739,11 @@ func newStringStreamStringIterator(input StringIterator, createFn func() (String
 func (itr *stringStreamStringIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *stringStreamStringIterator) Close() error { return itr.input.Close() }
+func (itr *stringStreamStringIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *stringStreamStringIterator) Next() (*StringPoint, error) {
@@ -5415,6 +6783,9 @@ func (itr *stringStreamStringIterator) reduce() ([]StringPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &stringReduceStringPoint{
 				Name:       curr.Name,
@@ -5422,9 +6793,15 @@ func (itr *stringStreamStringIterator) reduce() ([]StringPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateString(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -5440,12 +6817,22 @@ func (itr *stringStreamStringIterator) reduce() ([]StringPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *stringStreamStringIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // stringExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type stringExprIterator struct {
 	left  *bufStringIterator
 	right *bufStringIterator
 	fn    stringExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *stringExprIterator) Stats() IteratorStats {
@@ -5471,6 +6858,19 @@ func (itr *stringExprIterator) Next() (*StringPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -5481,18 +6881,23 @@ func (itr *stringExprIterator) Next() (*StringPoint, error) {
 type stringExprFunc func(a *StringPoint, b *StringPoint) *StringPoint
 
 // stringReduceBooleanIterator executes a reducer for every interval and buffers the result.
-type stringReduceBooleanIterator struct {
-	input  *bufStringIterator
-	create func() (StringPointAggregator, BooleanPointEmitter)
-	opt    IteratorOptions
-	points []BooleanPoint
+type stringReduceBooleanIterator struct {
+	input    *bufStringIterator
+	create   func() (StringPointAggregator, BooleanPointEmitter)
+	opt      IteratorOptions
+	points   []BooleanPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *stringReduceBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *stringReduceBooleanIterator) Close() error { return itr.input.Close() }
+func (itr *stringReduceBooleanIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *stringReduceBooleanIterator) Next() (*BooleanPoint, error) {
@@ -5517,6 +6922,23 @@ type stringReduceBooleanPoint struct {
 	Tags       Tags
 	Aggregator StringPointAggregator
 	Emitter    BooleanPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *stringReduceBooleanPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -5529,6 +6951,10 @@ func (itr *stringReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*stringReduceBooleanPoint)
 	for {
@@ -5551,6 +6977,9 @@ func (itr *stringReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &stringReduceBooleanPoint{
 				Name:       curr.Name,
@@ -5558,9 +6987,15 @@ func (itr *stringReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateString(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -5590,6 +7025,15 @@ func (itr *stringReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *stringReduceBooleanIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // stringStreamBooleanIterator streams inputs into the iterator and emits points gradually.
 type stringStreamBooleanIterator struct {
 	input  *bufStringIterator
@@ -5597,6 +7041,8 @@ type stringStreamBooleanIterator struct {
 	opt    IteratorOptions
 	m      map[string]*stringReduceBooleanPoint
 	points []BooleanPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newStringStreamBooleanIterator returns a new instance of stringStreamBooleanIterator.
@@ -5613,7 +7059,11 @@ func newStringStreamBooleanIterator(input StringIterator, createFn func() (Strin
 func (itr *stringStreamBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *stringStreamBooleanIterator) Close() error { return itr.input.Close() }
+func (itr *stringStreamBooleanIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *stringStreamBooleanIterator) Next() (*BooleanPoint, error) {
@@ -5653,6 +7103,9 @@ func (itr *stringStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &stringReduceBooleanPoint{
 				Name:       curr.Name,
@@ -5660,9 +7113,15 @@ func (itr *stringStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateString(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -5678,12 +7137,22 @@ func (itr *stringStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *stringStreamBooleanIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // stringBooleanExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type stringBooleanExprIterator struct {
 	left  *bufStringIterator
 	right *bufStringIterator
 	fn    stringBooleanExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *stringBooleanExprIterator) Stats() IteratorStats {
@@ -5709,6 +7178,19 @@ func (itr *stringBooleanExprIterator) Next() (*BooleanPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -6305,6 +7787,12 @@ func (itr *booleanLimitIterator) Close() error { return itr.input.Close() }
 // Next returns the next point from the iterator.
 func (itr *booleanLimitIterator) Next() (*BooleanPoint, error) {
 	for {
+		// Stop before reading another point once the limit has been reached
+		// if no other group can follow so the inputs stop reading early.
+		if itr.opt.Limit > 0 && (itr.n-itr.opt.Offset) >= itr.opt.Limit && itr.opt.singleGroup() {
+			return nil, nil
+		}
+
 		p, err := itr.input.Next()
 		if p == nil || err != nil {
 			return nil, err
@@ -6327,10 +7815,6 @@ func (itr *booleanLimitIterator) Next() (*BooleanPoint, error) {
 
 		// Read next point if we're beyond the limit.
 		if itr.opt.Limit > 0 && (itr.n-itr.opt.Offset) > itr.opt.Limit {
-			// If there's no interval, no groups, and a single source then simply exit.
-			if itr.opt.Interval.IsZero() && len(itr.opt.Dimensions) == 0 && len(itr.opt.Sources) == 1 {
-				return nil, nil
-			}
 			continue
 		}
 
@@ -6356,7 +7840,7 @@ type booleanFillIterator struct {
 
 func newBooleanFillIterator(input BooleanIterator, expr Expr, opt IteratorOptions) *booleanFillIterator {
 	if opt.Fill == NullFill {
-		if expr, ok := expr.(*Call); ok && expr.Name == "count" {
+		if expr, ok := expr.(*Call); ok && (expr.Name == "count" || expr.Name == "histogram") {
 			opt.Fill = NumberFill
 			opt.FillValue = false
 		}
@@ -6399,39 +7883,50 @@ func (itr *booleanFillIterator) Next() (*BooleanPoint, error) {
 		itr.init = true
 	}
 
-	p, err := itr.input.Next()
-	if err != nil {
-		return nil, err
-	}
+	var p *BooleanPoint
+	for {
+		var err error
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
 
-	// Check if the next point is outside of our window or is nil.
-	for p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
-		// If we are inside of an interval, unread the point and continue below to
-		// constructing a new point.
-		if itr.opt.Ascending {
-			if itr.window.time <= itr.endTime {
-				itr.input.unread(p)
-				p = nil
-				break
+		// Check if the next point is outside of our window or is nil.
+		for p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// If we are inside of an interval, unread the point and continue below to
+			// constructing a new point.
+			if itr.opt.Ascending {
+				if itr.window.time <= itr.endTime {
+					itr.input.unread(p)
+					p = nil
+					break
+				}
+			} else {
+				if itr.window.time >= itr.endTime {
+					itr.input.unread(p)
+					p = nil
+					break
+				}
 			}
-		} else {
-			if itr.window.time >= itr.endTime {
-				itr.input.unread(p)
-				p = nil
-				break
+
+			// We are *not* in a current interval. If there is no next point,
+			// we are at the end of all intervals.
+			if p == nil {
+				return nil, nil
 			}
-		}
 
-		// We are *not* in a current interval. If there is no next point,
-		// we are at the end of all intervals.
-		if p == nil {
-			return nil, nil
+			// Set the new interval.
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+			itr.prev = nil
+			break
 		}
 
-		// Set the new interval.
-		itr.window.name, itr.window.tags = p.Name, p.Tags
-		itr.window.time = itr.startTime
-		itr.prev = nil
+		// Points before the start of the range are only read when fill(previous)
+		// looks back for a previous value so they are not returned.
+		if p != nil && itr.opt.Ascending && p.Time < itr.startTime {
+			itr.prev = p
+			continue
+		}
 		break
 	}
 
@@ -6460,6 +7955,8 @@ func (itr *booleanFillIterator) Next() (*BooleanPoint, error) {
 			} else {
 				p.Nil = true
 			}
+		case LinearFill:
+			p.Nil = true
 		}
 	} else {
 		itr.prev = p
@@ -6467,11 +7964,12 @@ func (itr *booleanFillIterator) Next() (*BooleanPoint, error) {
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. The interval is looked up so it follows the time zone when a
+	// daylight saving time change makes it shorter or longer.
 	if itr.opt.Ascending {
-		itr.window.time = p.Time + int64(itr.opt.Interval.Duration)
+		_, itr.window.time = itr.opt.Window(p.Time)
 	} else {
-		itr.window.time = p.Time - int64(itr.opt.Interval.Duration)
+		itr.window.time, _ = itr.opt.Window(p.Time - 1)
 	}
 	return p, nil
 }
@@ -6532,6 +8030,150 @@ func (itr *booleanInterruptIterator) Next() (*BooleanPoint, error) {
 	return itr.input.Next()
 }
 
+// booleanParallelIterator reads its input in batches on a separate goroutine.
+type booleanParallelIterator struct {
+	input   BooleanIterator
+	budget  *MemoryBudget
+	ch      chan booleanParallelBatch
+	closing chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	batch     []BooleanPoint
+	batchSize int64 // bytes reserved for batch
+	err       error
+
+	mu    sync.Mutex
+	stats IteratorStats
+}
+
+// booleanParallelBatch is a batch of points read by a booleanParallelIterator
+// and the error that ended it, if any.
+type booleanParallelBatch struct {
+	points []BooleanPoint
+	size   int64
+	err    error
+}
+
+func newBooleanParallelIterator(input BooleanIterator, limiter chan struct{}, budget *MemoryBudget) *booleanParallelIterator {
+	itr := &booleanParallelIterator{
+		input:   input,
+		budget:  budget,
+		ch:      make(chan booleanParallelBatch, 1),
+		closing: make(chan struct{}),
+	}
+	itr.wg.Add(1)
+	go itr.monitor(limiter)
+	return itr
+}
+
+// Stats returns the stats of the input as of the last batch read.
+func (itr *booleanParallelIterator) Stats() IteratorStats {
+	itr.mu.Lock()
+	defer itr.mu.Unlock()
+	return itr.stats
+}
+
+// Close stops reading the input and closes it.
+func (itr *booleanParallelIterator) Close() error {
+	itr.once.Do(func() { close(itr.closing) })
+	itr.wg.Wait()
+
+	// Release the memory of the batches that were not read.
+	itr.budget.Release(itr.batchSize)
+	itr.batch, itr.batchSize = nil, 0
+	for b := range itr.ch {
+		itr.budget.Release(b.size)
+	}
+	return itr.input.Close()
+}
+
+// Next returns the next point read from the input.
+func (itr *booleanParallelIterator) Next() (*BooleanPoint, error) {
+	for len(itr.batch) == 0 {
+		if itr.err != nil {
+			err := itr.err
+			itr.err = nil
+			return nil, err
+		}
+
+		itr.budget.Release(itr.batchSize)
+		itr.batchSize = 0
+
+		b, ok := <-itr.ch
+		if !ok {
+			return nil, nil
+		}
+		itr.batch, itr.batchSize, itr.err = b.points, b.size, b.err
+	}
+
+	p := &itr.batch[0]
+	itr.batch = itr.batch[1:]
+	return p, nil
+}
+
+// monitor reads batches from the input while holding a slot in limiter and
+// sends them to Next until the input is exhausted or the iterator is closed.
+func (itr *booleanParallelIterator) monitor(limiter chan struct{}) {
+	defer itr.wg.Done()
+	defer close(itr.ch)
+
+	for {
+		select {
+		case limiter <- struct{}{}:
+		case <-itr.closing:
+			return
+		}
+		points, size, err := itr.read()
+		<-limiter
+
+		if len(points) > 0 || err != nil {
+			select {
+			case itr.ch <- booleanParallelBatch{points: points, size: size, err: err}:
+			case <-itr.closing:
+				itr.budget.Release(size)
+				return
+			}
+		}
+
+		// A short batch means the input is exhausted.
+		if err != nil || len(points) < parallelBatchSize {
+			return
+		}
+	}
+}
+
+// read reads up to parallelBatchSize points from the input and reserves
+// their memory from the budget.  The points are copied since the input may
+// reuse them.
+func (itr *booleanParallelIterator) read() ([]BooleanPoint, int64, error) {
+	points := make([]BooleanPoint, 0, parallelBatchSize)
+	defer func() {
+		stats := itr.input.Stats()
+		itr.mu.Lock()
+		itr.stats = stats
+		itr.mu.Unlock()
+	}()
+
+	var size int64
+	for len(points) < parallelBatchSize {
+		p, err := itr.input.Next()
+		if err != nil {
+			return points, size, err
+		} else if p == nil {
+			break
+		}
+
+		n := p.size()
+		if err := itr.budget.Reserve(n); err != nil {
+			return points, size, err
+		}
+		size += n
+		points = append(points, *p.Clone())
+	}
+	return points, size, nil
+}
+
 // auxBooleanPoint represents a combination of a point and an error for the AuxIterator.
 type auxBooleanPoint struct {
 	point *BooleanPoint
@@ -6567,7 +8209,9 @@ func (itr *booleanAuxIterator) Next() (*BooleanPoint, error) {
 	p := <-itr.output
 	return p.point, p.err
 }
-func (itr *booleanAuxIterator) Iterator(name string) Iterator { return itr.fields.iterator(name) }
+func (itr *booleanAuxIterator) Iterator(name string, typ DataType) Iterator {
+	return itr.fields.iterator(name, typ)
+}
 
 func (itr *booleanAuxIterator) CreateIterator(opt IteratorOptions) (Iterator, error) {
 	expr := opt.Expr
@@ -6577,7 +8221,7 @@ func (itr *booleanAuxIterator) CreateIterator(opt IteratorOptions) (Iterator, er
 
 	switch expr := expr.(type) {
 	case *VarRef:
-		return itr.Iterator(expr.Val), nil
+		return itr.Iterator(expr.Val, expr.Type), nil
 	default:
 		panic(fmt.Sprintf("invalid expression type for an aux iterator: %T", expr))
 	}
@@ -6714,17 +8358,22 @@ func (itr *booleanChanIterator) Next() (*BooleanPoint, error) {
 
 // booleanReduceFloatIterator executes a reducer for every interval and buffers the result.
 type booleanReduceFloatIterator struct {
-	input  *bufBooleanIterator
-	create func() (BooleanPointAggregator, FloatPointEmitter)
-	opt    IteratorOptions
-	points []FloatPoint
+	input    *bufBooleanIterator
+	create   func() (BooleanPointAggregator, FloatPointEmitter)
+	opt      IteratorOptions
+	points   []FloatPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *booleanReduceFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *booleanReduceFloatIterator) Close() error { return itr.input.Close() }
+func (itr *booleanReduceFloatIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *booleanReduceFloatIterator) Next() (*FloatPoint, error) {
@@ -6749,6 +8398,23 @@ type booleanReduceFloatPoint struct {
 	Tags       Tags
 	Aggregator BooleanPointAggregator
 	Emitter    FloatPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *booleanReduceFloatPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -6761,6 +8427,10 @@ func (itr *booleanReduceFloatIterator) reduce() ([]FloatPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*booleanReduceFloatPoint)
 	for {
@@ -6783,6 +8453,9 @@ func (itr *booleanReduceFloatIterator) reduce() ([]FloatPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &booleanReduceFloatPoint{
 				Name:       curr.Name,
@@ -6790,9 +8463,15 @@ func (itr *booleanReduceFloatIterator) reduce() ([]FloatPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateBoolean(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -6822,6 +8501,15 @@ func (itr *booleanReduceFloatIterator) reduce() ([]FloatPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *booleanReduceFloatIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // booleanStreamFloatIterator streams inputs into the iterator and emits points gradually.
 type booleanStreamFloatIterator struct {
 	input  *bufBooleanIterator
@@ -6829,6 +8517,8 @@ type booleanStreamFloatIterator struct {
 	opt    IteratorOptions
 	m      map[string]*booleanReduceFloatPoint
 	points []FloatPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newBooleanStreamFloatIterator returns a new instance of booleanStreamFloatIterator.
@@ -6845,7 +8535,11 @@ func newBooleanStreamFloatIterator(input BooleanIterator, createFn func() (Boole
 func (itr *booleanStreamFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *booleanStreamFloatIterator) Close() error { return itr.input.Close() }
+func (itr *booleanStreamFloatIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *booleanStreamFloatIterator) Next() (*FloatPoint, error) {
@@ -6885,6 +8579,9 @@ func (itr *booleanStreamFloatIterator) reduce() ([]FloatPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &booleanReduceFloatPoint{
 				Name:       curr.Name,
@@ -6892,9 +8589,15 @@ func (itr *booleanStreamFloatIterator) reduce() ([]FloatPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateBoolean(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -6910,12 +8613,22 @@ func (itr *booleanStreamFloatIterator) reduce() ([]FloatPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *booleanStreamFloatIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // booleanFloatExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type booleanFloatExprIterator struct {
 	left  *bufBooleanIterator
 	right *bufBooleanIterator
 	fn    booleanFloatExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *booleanFloatExprIterator) Stats() IteratorStats {
@@ -6941,6 +8654,19 @@ func (itr *booleanFloatExprIterator) Next() (*FloatPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -6952,17 +8678,22 @@ type booleanFloatExprFunc func(a *BooleanPoint, b *BooleanPoint) *FloatPoint
 
 // booleanReduceIntegerIterator executes a reducer for every interval and buffers the result.
 type booleanReduceIntegerIterator struct {
-	input  *bufBooleanIterator
-	create func() (BooleanPointAggregator, IntegerPointEmitter)
-	opt    IteratorOptions
-	points []IntegerPoint
+	input    *bufBooleanIterator
+	create   func() (BooleanPointAggregator, IntegerPointEmitter)
+	opt      IteratorOptions
+	points   []IntegerPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *booleanReduceIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *booleanReduceIntegerIterator) Close() error { return itr.input.Close() }
+func (itr *booleanReduceIntegerIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *booleanReduceIntegerIterator) Next() (*IntegerPoint, error) {
@@ -6987,6 +8718,23 @@ type booleanReduceIntegerPoint struct {
 	Tags       Tags
 	Aggregator BooleanPointAggregator
 	Emitter    IntegerPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *booleanReduceIntegerPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -6999,6 +8747,10 @@ func (itr *booleanReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*booleanReduceIntegerPoint)
 	for {
@@ -7021,6 +8773,9 @@ func (itr *booleanReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &booleanReduceIntegerPoint{
 				Name:       curr.Name,
@@ -7028,9 +8783,15 @@ func (itr *booleanReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateBoolean(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -7060,6 +8821,15 @@ func (itr *booleanReduceIntegerIterator) reduce() ([]IntegerPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *booleanReduceIntegerIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // booleanStreamIntegerIterator streams inputs into the iterator and emits points gradually.
 type booleanStreamIntegerIterator struct {
 	input  *bufBooleanIterator
@@ -7067,6 +8837,8 @@ type booleanStreamIntegerIterator struct {
 	opt    IteratorOptions
 	m      map[string]*booleanReduceIntegerPoint
 	points []IntegerPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newBooleanStreamIntegerIterator returns a new instance of booleanStreamIntegerIterator.
@@ -7083,7 +8855,11 @@ func newBooleanStreamIntegerIterator(input BooleanIterator, createFn func() (Boo
 func (itr *booleanStreamIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *booleanStreamIntegerIterator) Close() error { return itr.input.Close() }
+func (itr *booleanStreamIntegerIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *booleanStreamIntegerIterator) Next() (*IntegerPoint, error) {
@@ -7123,6 +8899,9 @@ func (itr *booleanStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &booleanReduceIntegerPoint{
 				Name:       curr.Name,
@@ -7130,9 +8909,15 @@ func (itr *booleanStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateBoolean(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -7148,12 +8933,22 @@ func (itr *booleanStreamIntegerIterator) reduce() ([]IntegerPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *booleanStreamIntegerIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // booleanIntegerExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type booleanIntegerExprIterator struct {
 	left  *bufBooleanIterator
 	right *bufBooleanIterator
 	fn    booleanIntegerExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *booleanIntegerExprIterator) Stats() IteratorStats {
@@ -7179,6 +8974,19 @@ func (itr *booleanIntegerExprIterator) Next() (*IntegerPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -7190,17 +8998,22 @@ type booleanIntegerExprFunc func(a *BooleanPoint, b *BooleanPoint) *IntegerPoint
 
 // booleanReduceStringIterator executes a reducer for every interval and buffers the result.
 type booleanReduceStringIterator struct {
-	input  *bufBooleanIterator
-	create func() (BooleanPointAggregator, StringPointEmitter)
-	opt    IteratorOptions
-	points []StringPoint
+	input    *bufBooleanIterator
+	create   func() (BooleanPointAggregator, StringPointEmitter)
+	opt      IteratorOptions
+	points   []StringPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *booleanReduceStringIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *booleanReduceStringIterator) Close() error { return itr.input.Close() }
+func (itr *booleanReduceStringIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *booleanReduceStringIterator) Next() (*StringPoint, error) {
@@ -7225,6 +9038,23 @@ type booleanReduceStringPoint struct {
 	Tags       Tags
 	Aggregator BooleanPointAggregator
 	Emitter    StringPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *booleanReduceStringPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -7237,6 +9067,10 @@ func (itr *booleanReduceStringIterator) reduce() ([]StringPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*booleanReduceStringPoint)
 	for {
@@ -7259,6 +9093,9 @@ func (itr *booleanReduceStringIterator) reduce() ([]StringPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &booleanReduceStringPoint{
 				Name:       curr.Name,
@@ -7266,9 +9103,15 @@ func (itr *booleanReduceStringIterator) reduce() ([]StringPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateBoolean(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -7298,6 +9141,15 @@ func (itr *booleanReduceStringIterator) reduce() ([]StringPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *booleanReduceStringIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // booleanStreamStringIterator streams inputs into the iterator and emits points gradually.
 type booleanStreamStringIterator struct {
 	input  *bufBooleanIterator
@@ -7305,6 +9157,8 @@ type booleanStreamStringIterator struct {
 	opt    IteratorOptions
 	m      map[string]*booleanReduceStringPoint
 	points []StringPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newBooleanStreamStringIterator returns a new instance of booleanStreamStringIterator.
@@ -7321,7 +9175,11 @@ func newBooleanStreamStringIterator(input BooleanIterator, createFn func() (Bool
 func (itr *booleanStreamStringIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *booleanStreamStringIterator) Close() error { return itr.input.Close() }
+func (itr *booleanStreamStringIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *booleanStreamStringIterator) Next() (*StringPoint, error) {
@@ -7361,6 +9219,9 @@ func (itr *booleanStreamStringIterator) reduce() ([]StringPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &booleanReduceStringPoint{
 				Name:       curr.Name,
@@ -7368,9 +9229,15 @@ func (itr *booleanStreamStringIterator) reduce() ([]StringPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateBoolean(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -7386,12 +9253,22 @@ func (itr *booleanStreamStringIterator) reduce() ([]StringPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *booleanStreamStringIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // booleanStringExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type booleanStringExprIterator struct {
 	left  *bufBooleanIterator
 	right *bufBooleanIterator
 	fn    booleanStringExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *booleanStringExprIterator) Stats() IteratorStats {
@@ -7417,6 +9294,19 @@ func (itr *booleanStringExprIterator) Next() (*StringPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
@@ -7428,17 +9318,22 @@ type booleanStringExprFunc func(a *BooleanPoint, b *BooleanPoint) *StringPoint
 
 // booleanReduceBooleanIterator executes a reducer for every interval and buffers the result.
 type booleanReduceBooleanIterator struct {
-	input  *bufBooleanIterator
-	create func() (BooleanPointAggregator, BooleanPointEmitter)
-	opt    IteratorOptions
-	points []BooleanPoint
+	input    *bufBooleanIterator
+	create   func() (BooleanPointAggregator, BooleanPointEmitter)
+	opt      IteratorOptions
+	points   []BooleanPoint
+	reserved int64 // bytes reserved from the memory budget for the window
 }
 
 // Stats returns stats from the input iterator.
 func (itr *booleanReduceBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *booleanReduceBooleanIterator) Close() error { return itr.input.Close() }
+func (itr *booleanReduceBooleanIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the minimum value for the next available interval.
 func (itr *booleanReduceBooleanIterator) Next() (*BooleanPoint, error) {
@@ -7463,6 +9358,23 @@ type booleanReduceBooleanPoint struct {
 	Tags       Tags
 	Aggregator BooleanPointAggregator
 	Emitter    BooleanPointEmitter
+
+	// The aggregator if it holds the points it aggregates, and the number
+	// of points held when grow was last called.
+	buffer    pointBuffer
+	bufferedN int
+}
+
+// grow returns the number of points the aggregator started to hold since
+// grow was last called.
+func (rp *booleanReduceBooleanPoint) grow() int {
+	if rp.buffer == nil {
+		return 0
+	}
+	n := rp.buffer.bufferedN()
+	grown := n - rp.bufferedN
+	rp.bufferedN = n
+	return grown
 }
 
 // reduce executes fn once for every point in the next window.
@@ -7475,6 +9387,10 @@ func (itr *booleanReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 	}
 	startTime, endTime := itr.opt.Window(t)
 
+	// The memory of the previous window is no longer used.
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+
 	// Create points by tags.
 	m := make(map[string]*booleanReduceBooleanPoint)
 	for {
@@ -7497,6 +9413,9 @@ func (itr *booleanReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &booleanReduceBooleanPoint{
 				Name:       curr.Name,
@@ -7504,9 +9423,15 @@ func (itr *booleanReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			m[id] = rp
 		}
 		rp.Aggregator.AggregateBoolean(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Reverse sort points by name & tag.
@@ -7536,6 +9461,15 @@ func (itr *booleanReduceBooleanIterator) reduce() ([]BooleanPoint, error) {
 	return a, nil
 }
 
+// reserve reserves n bytes from the memory budget for the current window.
+func (itr *booleanReduceBooleanIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // booleanStreamBooleanIterator streams inputs into the iterator and emits points gradually.
 type booleanStreamBooleanIterator struct {
 	input  *bufBooleanIterator
@@ -7543,6 +9477,8 @@ type booleanStreamBooleanIterator struct {
 	opt    IteratorOptions
 	m      map[string]*booleanReduceBooleanPoint
 	points []BooleanPoint
+
+	reserved int64 // bytes reserved from the memory budget
 }
 
 // newBooleanStreamBooleanIterator returns a new instance of booleanStreamBooleanIterator.
@@ -7559,7 +9495,11 @@ func newBooleanStreamBooleanIterator(input BooleanIterator, createFn func() (Boo
 func (itr *booleanStreamBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
 
 // Close closes the iterator and all child iterators.
-func (itr *booleanStreamBooleanIterator) Close() error { return itr.input.Close() }
+func (itr *booleanStreamBooleanIterator) Close() error {
+	itr.opt.MemoryBudget.Release(itr.reserved)
+	itr.reserved = 0
+	return itr.input.Close()
+}
 
 // Next returns the next value for the stream iterator.
 func (itr *booleanStreamBooleanIterator) Next() (*BooleanPoint, error) {
@@ -7599,6 +9539,9 @@ func (itr *booleanStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 		// Retrieve the aggregator for this name/tag combination or create one.
 		rp := itr.m[id]
 		if rp == nil {
+			if err := itr.reserve(aggregatorSize + int64(len(id))); err != nil {
+				return nil, err
+			}
 			aggregator, emitter := itr.create()
 			rp = &booleanReduceBooleanPoint{
 				Name:       curr.Name,
@@ -7606,9 +9549,15 @@ func (itr *booleanStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 				Aggregator: aggregator,
 				Emitter:    emitter,
 			}
+			rp.buffer, _ = aggregator.(pointBuffer)
 			itr.m[id] = rp
 		}
 		rp.Aggregator.AggregateBoolean(curr)
+		if n := rp.grow(); n > 0 {
+			if err := itr.reserve(int64(n) * curr.size()); err != nil {
+				return nil, err
+			}
+		}
 
 		// Attempt to emit points from the aggregator.
 		points := rp.Emitter.Emit()
@@ -7624,12 +9573,22 @@ func (itr *booleanStreamBooleanIterator) reduce() ([]BooleanPoint, error) {
 	}
 }
 
+// reserve reserves n bytes from the memory budget until the iterator is closed.
+func (itr *booleanStreamBooleanIterator) reserve(n int64) error {
+	if err := itr.opt.MemoryBudget.Reserve(n); err != nil {
+		return err
+	}
+	itr.reserved += n
+	return nil
+}
+
 // booleanExprIterator executes a function to modify an existing point
 // for every output of the input iterator.
 type booleanExprIterator struct {
 	left  *bufBooleanIterator
 	right *bufBooleanIterator
 	fn    booleanExprFunc
+	opt   IteratorOptions
 }
 
 func (itr *booleanExprIterator) Stats() IteratorStats {
@@ -7655,6 +9614,19 @@ func (itr *booleanExprIterator) Next() (*BooleanPoint, error) {
 	} else if a == nil && b == nil {
 		return nil, nil
 	}
+
+	// If one side has no point for the series and time of the other, combine
+	// the earlier point with a missing value and keep the later one for the
+	// next call.
+	if a != nil && b != nil {
+		if cmp := comparePoints(a.Name, a.Tags, a.Time, b.Name, b.Tags, b.Time, itr.opt.Ascending); cmp < 0 {
+			itr.right.unread(b)
+			b = nil
+		} else if cmp > 0 {
+			itr.left.unread(a)
+			a = nil
+		}
+	}
 	return itr.fn(a, b), nil
 }
 
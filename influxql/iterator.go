@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -249,13 +250,41 @@ func NewInterruptIterator(input Iterator, closing <-chan struct{}) Iterator {
 	}
 }
 
+const (
+	// parallelBatchSize is the number of points a parallel iterator reads
+	// from its input at a time.
+	parallelBatchSize = 1000
+
+	// aggregatorSize is an estimate of the memory used by an aggregator
+	// and its entry in a reduce iterator, excluding any points it holds.
+	aggregatorSize = 256
+)
+
+// newParallelIterator returns an iterator that reads input in batches on a
+// separate goroutine while holding a slot in limiter.  The memory of the
+// batches is reserved from budget until they are read.
+func newParallelIterator(input Iterator, limiter chan struct{}, budget *MemoryBudget) Iterator {
+	switch input := input.(type) {
+	case FloatIterator:
+		return newFloatParallelIterator(input, limiter, budget)
+	case IntegerIterator:
+		return newIntegerParallelIterator(input, limiter, budget)
+	case StringIterator:
+		return newStringParallelIterator(input, limiter, budget)
+	case BooleanIterator:
+		return newBooleanParallelIterator(input, limiter, budget)
+	default:
+		panic(fmt.Sprintf("unsupported parallel iterator type: %T", input))
+	}
+}
+
 // AuxIterator represents an iterator that can split off separate auxilary iterators.
 type AuxIterator interface {
 	Iterator
 	IteratorCreator
 
 	// Auxilary iterator
-	Iterator(name string) Iterator
+	Iterator(name string, typ DataType) Iterator
 
 	// Start starts writing to the created iterators.
 	Start()
@@ -283,7 +312,7 @@ func NewAuxIterator(input Iterator, seriesKeys SeriesList, opt IteratorOptions)
 
 // auxIteratorField represents an auxilary field within an AuxIterator.
 type auxIteratorField struct {
-	name string     // field name
+	ref  VarRef     // field reference
 	typ  DataType   // detected data type
 	itrs []Iterator // auxillary iterators
 	mu   sync.Mutex
@@ -309,8 +338,8 @@ type auxIteratorFields []*auxIteratorField
 // newAuxIteratorFields returns a new instance of auxIteratorFields from a list of field names.
 func newAuxIteratorFields(seriesKeys SeriesList, opt IteratorOptions) auxIteratorFields {
 	fields := make(auxIteratorFields, len(opt.Aux))
-	for i, name := range opt.Aux {
-		fields[i] = &auxIteratorField{name: name, opt: opt}
+	for i, ref := range opt.Aux {
+		fields[i] = &auxIteratorField{ref: ref, opt: opt}
 		for _, s := range seriesKeys {
 			aux := s.Aux[i]
 			if aux == Unknown {
@@ -332,11 +361,11 @@ func (a auxIteratorFields) close() {
 }
 
 // iterator creates a new iterator for a named auxilary field.
-func (a auxIteratorFields) iterator(name string) Iterator {
+func (a auxIteratorFields) iterator(name string, typ DataType) Iterator {
 	for _, f := range a {
 		// Skip field if it's name doesn't match.
 		// Exit if no points were received by the iterator.
-		if f.name != name {
+		if f.ref.Val != name || f.ref.Type != typ {
 			continue
 		}
 
@@ -531,8 +560,12 @@ func (a IteratorCreators) CreateIterator(opt IteratorOptions) (Iterator, error)
 		Iterators(itrs).Close()
 		return nil, err
 	}
+	return mergeIterators(itrs, opt)
+}
 
-	// Merge into a single iterator.
+// mergeIterators merges the iterators of multiple iterator creators into a
+// single iterator.
+func mergeIterators(itrs []Iterator, opt IteratorOptions) (Iterator, error) {
 	if opt.MergeSorted() {
 		itr := NewSortedMergeIterator(itrs, opt)
 		if itr != nil && opt.InterruptCh != nil {
@@ -609,6 +642,25 @@ func (a IteratorCreators) SeriesKeys(opt IteratorOptions) (SeriesList, error) {
 	return SeriesList(seriesList), nil
 }
 
+// IteratorCost returns the combined cost of the iterator creators in a that
+// can estimate it.
+func (a IteratorCreators) IteratorCost(opt IteratorOptions) (IteratorCost, error) {
+	var cost IteratorCost
+	for _, ic := range a {
+		coster, ok := ic.(IteratorCoster)
+		if !ok {
+			continue
+		}
+
+		c, err := coster.IteratorCost(opt)
+		if err != nil {
+			return IteratorCost{}, err
+		}
+		cost.Add(c)
+	}
+	return cost, nil
+}
+
 // ExpandSources expands sources across all iterator creators and returns a unique result.
 func (a IteratorCreators) ExpandSources(sources Sources) (Sources, error) {
 	m := make(map[string]Source)
@@ -645,6 +697,167 @@ func (a IteratorCreators) ExpandSources(sources Sources) (Sources, error) {
 	return sorted, nil
 }
 
+// ParallelIteratorCreators represents a list of iterator creators whose
+// iterators are created and read concurrently.  Each iterator is read in
+// batches on its own goroutine but at most a fixed number of creators work at
+// once.  The points are merged like IteratorCreators.
+type ParallelIteratorCreators struct {
+	IteratorCreators
+	limiter chan struct{}
+}
+
+// NewParallelIteratorCreators returns a ParallelIteratorCreators with up to
+// n creators in a working at once.
+func NewParallelIteratorCreators(a IteratorCreators, n int) *ParallelIteratorCreators {
+	if n <= 0 {
+		n = 1
+	}
+	return &ParallelIteratorCreators{
+		IteratorCreators: a,
+		limiter:          make(chan struct{}, n),
+	}
+}
+
+// CreateIterator returns a single combined iterator from multiple iterator creators.
+func (a *ParallelIteratorCreators) CreateIterator(opt IteratorOptions) (Iterator, error) {
+	itrs := make([]Iterator, len(a.IteratorCreators))
+	errs := make([]error, len(a.IteratorCreators))
+
+	var wg sync.WaitGroup
+	for i, ic := range a.IteratorCreators {
+		wg.Add(1)
+		go func(i int, ic IteratorCreator) {
+			defer wg.Done()
+			a.limiter <- struct{}{}
+			defer func() { <-a.limiter }()
+			itrs[i], errs[i] = ic.CreateIterator(opt)
+		}(i, ic)
+	}
+	wg.Wait()
+
+	itrs = Iterators(itrs).filterNonNil()
+	for _, err := range errs {
+		if err != nil {
+			Iterators(itrs).Close()
+			return nil, err
+		}
+	}
+
+	for i, itr := range itrs {
+		itrs[i] = newParallelIterator(itr, a.limiter, opt.MemoryBudget)
+	}
+	return mergeIterators(itrs, opt)
+}
+
+// ConcatIteratorCreators represents a list of iterator creators whose points
+// do not overlap in time, in the order their points are read.
+//
+// The points of a raw query without dimensions are read from each creator in
+// turn and a creator's iterator is only created once the points of the
+// creators before it have been read. A query with a limit stops reading
+// before the remaining creators are used. Any other iterator is merged from
+// all creators like IteratorCreators.
+type ConcatIteratorCreators []IteratorCreator
+
+// Close closes all iterator creators that implement io.Closer.
+func (a ConcatIteratorCreators) Close() error {
+	return IteratorCreators(a).Close()
+}
+
+// CreateIterator returns an iterator reading the points of each creator in turn.
+func (a ConcatIteratorCreators) CreateIterator(opt IteratorOptions) (Iterator, error) {
+	if opt.Expr != nil || len(opt.Dimensions) > 0 {
+		return IteratorCreators(a).CreateIterator(opt)
+	}
+
+	var itr Iterator = &concatIterator{ics: a, opt: opt}
+	if opt.InterruptCh != nil {
+		itr = NewInterruptIterator(itr, opt.InterruptCh)
+	}
+	return itr, nil
+}
+
+// FieldDimensions returns unique fields and dimensions from multiple iterator creators.
+func (a ConcatIteratorCreators) FieldDimensions(sources Sources) (fields, dimensions map[string]struct{}, err error) {
+	return IteratorCreators(a).FieldDimensions(sources)
+}
+
+// SeriesKeys returns a list of series in all iterator creators in a.
+func (a ConcatIteratorCreators) SeriesKeys(opt IteratorOptions) (SeriesList, error) {
+	return IteratorCreators(a).SeriesKeys(opt)
+}
+
+// IteratorCost returns the combined cost of the iterator creators in a that
+// can estimate it.
+func (a ConcatIteratorCreators) IteratorCost(opt IteratorOptions) (IteratorCost, error) {
+	return IteratorCreators(a).IteratorCost(opt)
+}
+
+// ExpandSources expands sources across all iterator creators and returns a unique result.
+func (a ConcatIteratorCreators) ExpandSources(sources Sources) (Sources, error) {
+	return IteratorCreators(a).ExpandSources(sources)
+}
+
+// concatIterator reads the auxiliary points of each iterator creator in turn.
+// The iterator of a creator is created when the previous one is exhausted.
+type concatIterator struct {
+	ics   []IteratorCreator
+	opt   IteratorOptions
+	input FloatIterator
+	stats IteratorStats
+}
+
+// Stats returns the stats of the iterators read so far.
+func (itr *concatIterator) Stats() IteratorStats {
+	stats := itr.stats
+	if itr.input != nil {
+		stats.Add(itr.input.Stats())
+	}
+	return stats
+}
+
+// Close closes the current input and does not create any further ones.
+func (itr *concatIterator) Close() error {
+	itr.ics = nil
+	if itr.input != nil {
+		return itr.input.Close()
+	}
+	return nil
+}
+
+// Next returns the next point from the current input, moving to the next
+// iterator creator when the input is exhausted.
+func (itr *concatIterator) Next() (*FloatPoint, error) {
+	for {
+		if itr.input != nil {
+			if p, err := itr.input.Next(); p != nil || err != nil {
+				return p, err
+			}
+			itr.stats.Add(itr.input.Stats())
+			itr.input.Close()
+			itr.input = nil
+		}
+
+		if len(itr.ics) == 0 {
+			return nil, nil
+		}
+		input, err := itr.ics[0].CreateIterator(itr.opt)
+		itr.ics = itr.ics[1:]
+		if err != nil {
+			return nil, err
+		} else if input == nil {
+			continue
+		}
+
+		fitr, ok := input.(FloatIterator)
+		if !ok {
+			input.Close()
+			return nil, fmt.Errorf("unsupported concat iterator input type: %T", input)
+		}
+		itr.input = fitr
+	}
+}
+
 // IteratorOptions is an object passed to CreateIterator to specify creation options.
 type IteratorOptions struct {
 	// Expression to iterate for.
@@ -652,7 +865,7 @@ type IteratorOptions struct {
 	Expr Expr
 
 	// Auxilary tags or values to also retrieve for the point.
-	Aux []string
+	Aux []VarRef
 
 	// Data sources from which to retrieve data.
 	Sources []Source
@@ -661,6 +874,9 @@ type IteratorOptions struct {
 	Interval   Interval
 	Dimensions []string
 
+	// Time zone the intervals are aligned to. Intervals are aligned to UTC if nil.
+	Location *time.Location
+
 	// Fill options.
 	Fill      FillOption
 	FillValue interface{}
@@ -687,6 +903,10 @@ type IteratorOptions struct {
 	// If this channel is set and is closed, the iterator should try to exit
 	// and close as soon as possible.
 	InterruptCh <-chan struct{}
+
+	// Budget of the memory buffered by the iterators. Iterators return an
+	// error when it is exceeded.
+	MemoryBudget *MemoryBudget
 }
 
 // newIteratorOptionsStmt creates the iterator options from stmt.
@@ -743,6 +963,7 @@ func newIteratorOptionsStmt(stmt *SelectStatement, sopt *SelectOptions) (opt Ite
 	opt.Condition = stmt.Condition
 	opt.Ascending = stmt.TimeAscending()
 	opt.Dedupe = stmt.Dedupe
+	opt.Location = stmt.Location
 
 	opt.Fill, opt.FillValue = stmt.Fill, stmt.FillValue
 	if opt.Fill == NullFill && stmt.Target != nil {
@@ -755,6 +976,7 @@ func newIteratorOptionsStmt(stmt *SelectStatement, sopt *SelectOptions) (opt Ite
 	opt.SLimit, opt.SOffset = stmt.SLimit, stmt.SOffset
 	if sopt != nil {
 		opt.InterruptCh = sopt.InterruptCh
+		opt.MemoryBudget = sopt.MemoryBudget
 	}
 
 	return opt, nil
@@ -770,6 +992,12 @@ func (opt IteratorOptions) MergeSorted() bool {
 	return ok
 }
 
+// singleGroup returns true if the points read with the options cannot be
+// grouped by tags or by source, so a limit applies to all of them at once.
+func (opt IteratorOptions) singleGroup() bool {
+	return len(opt.Dimensions) == 0 && len(opt.Sources) == 1
+}
+
 // SeekTime returns the time the iterator should start from.
 // For ascending iterators this is the start time, for descending iterators it's the end time.
 func (opt IteratorOptions) SeekTime() int64 {
@@ -788,19 +1016,78 @@ func (opt IteratorOptions) Window(t int64) (start, end int64) {
 	// Subtract the offset to the time so we calculate the correct base interval.
 	t -= int64(opt.Interval.Offset)
 
+	// Retrieve the zone offset so the interval is aligned to the local time.
+	_, zone := opt.Zone(t)
+
 	// Truncate time by duration.
-	t -= t % int64(opt.Interval.Duration)
+	dt := (t + zone) % int64(opt.Interval.Duration)
+	if dt < 0 {
+		// A negative remainder rounds up instead of down.
+		dt += int64(opt.Interval.Duration)
+	}
+	start = t - dt
+	end = start + int64(opt.Interval.Duration)
+
+	// The zone offset may be different at the start and end of the interval
+	// if they are on the other side of a daylight saving time change. Adjust
+	// them so they stay on the same local time, unless the change is larger
+	// than the interval itself.
+	if opt.Location != nil {
+		if _, offset := opt.Zone(start); offset != zone && abs(zone-offset) < int64(opt.Interval.Duration) {
+			start += zone - offset
+		}
+		if _, offset := opt.Zone(end); offset != zone && abs(zone-offset) < int64(opt.Interval.Duration) {
+			end += zone - offset
+		}
+	}
 
 	// Apply the offset.
-	start = t + int64(opt.Interval.Offset)
-	end = start + int64(opt.Interval.Duration)
+	start += int64(opt.Interval.Offset)
+	end += int64(opt.Interval.Offset)
 	return
 }
 
+// Zone returns the name and the offset in nanoseconds of the time zone at
+// the given time. Returns UTC if there is no location.
+func (opt IteratorOptions) Zone(ns int64) (string, int64) {
+	if opt.Location == nil {
+		return "UTC", 0
+	}
+	name, offset := time.Unix(0, ns).In(opt.Location).Zone()
+	return name, int64(offset) * int64(time.Second)
+}
+
+// QualifiedType returns the type of the values read from a field of type typ
+// by a reference qualified with qualifier.  Integers are cast to floats.
+// Returns Unknown if the field is not read by the reference.
+func QualifiedType(typ, qualifier DataType) DataType {
+	switch qualifier {
+	case Unknown, AnyField:
+		return typ
+	case Tag:
+		return Unknown
+	case Float:
+		if typ == Integer {
+			return Float
+		}
+	}
+	if typ != qualifier {
+		return Unknown
+	}
+	return typ
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
 // DerivativeInterval returns the time interval for the derivative function.
 func (opt IteratorOptions) DerivativeInterval() Interval {
 	// Use the interval on the derivative() call, if specified.
-	if expr, ok := opt.Expr.(*Call); ok && len(expr.Args) == 2 {
+	if expr, ok := opt.Expr.(*Call); ok && len(expr.Args) >= 2 {
 		return Interval{Duration: expr.Args[1].(*DurationLiteral).Val}
 	}
 
@@ -812,6 +1099,34 @@ func (opt IteratorOptions) DerivativeInterval() Interval {
 	return Interval{Duration: time.Second}
 }
 
+// DerivativeGapPolicy returns the gap policy of the derivative function.
+func (opt IteratorOptions) DerivativeGapPolicy() DerivativeGapPolicy {
+	if expr, ok := opt.Expr.(*Call); ok && len(expr.Args) == 3 {
+		return derivativeGapPolicies[expr.Args[2].(*StringLiteral).Val]
+	}
+	return DerivativeGapExtrapolate
+}
+
+// IntegralInterval returns the time interval for the integral function.
+func (opt IteratorOptions) IntegralInterval() Interval {
+	// Use the interval on the integral() call, if specified.
+	if expr, ok := opt.Expr.(*Call); ok && len(expr.Args) == 2 {
+		return Interval{Duration: expr.Args[1].(*DurationLiteral).Val}
+	}
+
+	return Interval{Duration: time.Second}
+}
+
+// RateInterval returns the time interval for the rate function.
+func (opt IteratorOptions) RateInterval() Interval {
+	// Use the interval on the rate() call, if specified.
+	if expr, ok := opt.Expr.(*Call); ok && len(expr.Args) == 2 {
+		return Interval{Duration: expr.Args[1].(*DurationLiteral).Val}
+	}
+
+	return Interval{Duration: time.Second}
+}
+
 // ElapsedInterval returns the time interval for the elapsed function.
 func (opt IteratorOptions) ElapsedInterval() Interval {
 	// Use the interval on the elapsed() call, if specified.
@@ -822,6 +1137,16 @@ func (opt IteratorOptions) ElapsedInterval() Interval {
 	return Interval{Duration: time.Nanosecond}
 }
 
+// FillLookback returns how far before the start of the range fill(previous)
+// looks for a previous value. Returns zero if it does not look back.
+func (opt IteratorOptions) FillLookback() time.Duration {
+	if opt.Fill != PreviousFill {
+		return 0
+	}
+	lookback, _ := opt.FillValue.(time.Duration)
+	return lookback
+}
+
 // MarshalBinary encodes opt into a binary format.
 func (opt *IteratorOptions) MarshalBinary() ([]byte, error) {
 	return proto.Marshal(encodeIteratorOptions(opt))
@@ -845,7 +1170,6 @@ func (opt *IteratorOptions) UnmarshalBinary(buf []byte) error {
 
 func encodeIteratorOptions(opt *IteratorOptions) *internal.IteratorOptions {
 	pb := &internal.IteratorOptions{
-		Aux:        opt.Aux,
 		Interval:   encodeInterval(opt.Interval),
 		Dimensions: opt.Dimensions,
 		Fill:       proto.Int32(int32(opt.Fill)),
@@ -864,6 +1188,15 @@ func encodeIteratorOptions(opt *IteratorOptions) *internal.IteratorOptions {
 		pb.Expr = proto.String(opt.Expr.String())
 	}
 
+	// Set the names of the auxiliary fields for nodes that do not read the
+	// references along with their types.
+	pb.Aux = make([]string, len(opt.Aux))
+	pb.Fields = make([]*internal.VarRef, len(opt.Aux))
+	for i, ref := range opt.Aux {
+		pb.Aux[i] = ref.Val
+		pb.Fields[i] = &internal.VarRef{Val: proto.String(ref.Val), Type: proto.Int32(int32(ref.Type))}
+	}
+
 	// Convert and encode sources to measurements.
 	sources := make([]*internal.Measurement, len(opt.Sources))
 	for i, source := range opt.Sources {
@@ -882,12 +1215,16 @@ func encodeIteratorOptions(opt *IteratorOptions) *internal.IteratorOptions {
 		pb.Condition = proto.String(opt.Condition.String())
 	}
 
+	// Set the time zone, if set.
+	if opt.Location != nil {
+		pb.Location = proto.String(opt.Location.String())
+	}
+
 	return pb
 }
 
 func decodeIteratorOptions(pb *internal.IteratorOptions) (*IteratorOptions, error) {
 	opt := &IteratorOptions{
-		Aux:        pb.GetAux(),
 		Interval:   decodeInterval(pb.GetInterval()),
 		Dimensions: pb.GetDimensions(),
 		Fill:       FillOption(pb.GetFill()),
@@ -911,6 +1248,20 @@ func decodeIteratorOptions(pb *internal.IteratorOptions) (*IteratorOptions, erro
 		opt.Expr = expr
 	}
 
+	// Read the auxiliary fields from their names if the references were not
+	// sent.
+	if fields := pb.GetFields(); len(fields) > 0 {
+		opt.Aux = make([]VarRef, len(fields))
+		for i, ref := range fields {
+			opt.Aux[i] = VarRef{Val: ref.GetVal(), Type: DataType(ref.GetType())}
+		}
+	} else if aux := pb.GetAux(); len(aux) > 0 {
+		opt.Aux = make([]VarRef, len(aux))
+		for i, name := range aux {
+			opt.Aux[i] = VarRef{Val: name}
+		}
+	}
+
 	// Convert and encode sources to measurements.
 	sources := make([]Source, len(pb.GetSources()))
 	for i, source := range pb.GetSources() {
@@ -931,6 +1282,15 @@ func decodeIteratorOptions(pb *internal.IteratorOptions) (*IteratorOptions, erro
 		opt.Condition = expr
 	}
 
+	// Load the time zone, if set.
+	if pb.Location != nil {
+		loc, err := time.LoadLocation(pb.GetLocation())
+		if err != nil {
+			return nil, err
+		}
+		opt.Location = loc
+	}
+
 	return opt, nil
 }
 
@@ -953,6 +1313,9 @@ func newSelectInfo(stmt *SelectStatement) *selectInfo {
 func (v *selectInfo) Visit(n Node) Visitor {
 	switch n := n.(type) {
 	case *Call:
+		if isScalarFunction(n.Name) {
+			return v
+		}
 		v.calls[n] = struct{}{}
 		return nil
 	case *VarRef:
@@ -1095,6 +1458,31 @@ func (*nilFloatIterator) Stats() IteratorStats       { return IteratorStats{} }
 func (*nilFloatIterator) Close() error               { return nil }
 func (*nilFloatIterator) Next() (*FloatPoint, error) { return nil, nil }
 
+// tagValueIterator returns the tag value read as the first auxiliary field of
+// each point of its input. Points without the tag are skipped.
+type tagValueIterator struct {
+	input FloatIterator
+	point StringPoint
+}
+
+func (itr *tagValueIterator) Stats() IteratorStats { return itr.input.Stats() }
+func (itr *tagValueIterator) Close() error         { return itr.input.Close() }
+func (itr *tagValueIterator) Next() (*StringPoint, error) {
+	for {
+		p, err := itr.input.Next()
+		if p == nil || err != nil {
+			return nil, err
+		}
+
+		v, ok := p.Aux[0].(string)
+		if !ok || v == "" {
+			continue
+		}
+		itr.point = StringPoint{Name: p.Name, Tags: p.Tags, Time: p.Time, Value: v}
+		return &itr.point, nil
+	}
+}
+
 // integerFloatTransformIterator executes a function to modify an existing point for every
 // output of the input iterator.
 type integerFloatTransformIterator struct {
@@ -1146,24 +1534,67 @@ func (itr *integerFloatCastIterator) Next() (*FloatPoint, error) {
 	}, nil
 }
 
+// linearFloat computes the value at windowTime on the line between the
+// previous and the next point.
+func linearFloat(windowTime, previousTime, nextTime int64, previousValue, nextValue float64) float64 {
+	m := (nextValue - previousValue) / float64(nextTime-previousTime)
+	return previousValue + m*float64(windowTime-previousTime)
+}
+
+// linearInteger computes the value at windowTime on the line between the
+// previous and the next point, truncated to an integer.
+func linearInteger(windowTime, previousTime, nextTime int64, previousValue, nextValue int64) int64 {
+	return int64(linearFloat(windowTime, previousTime, nextTime, float64(previousValue), float64(nextValue)))
+}
+
 // IteratorStats represents statistics about an iterator.
 // Some statistics are available immediately upon iterator creation while
 // some are derived as the iterator processes data.
 type IteratorStats struct {
 	SeriesN int // series represented
 	PointN  int // points returned
+	BlockN  int // blocks decoded
 }
 
 // Add aggregates fields from s and other together. Overwrites s.
 func (s *IteratorStats) Add(other IteratorStats) {
 	s.SeriesN += other.SeriesN
 	s.PointN += other.PointN
+	s.BlockN += other.BlockN
+}
+
+// IteratorCost represents the estimated cost of creating and reading an
+// iterator. It is calculated from indexes only, without reading any data.
+type IteratorCost struct {
+	NumShards    int // shards queried
+	NumSeries    int // series read
+	CachedValues int // values read from the cache
+	NumFiles     int // file reads across all series
+	BlocksRead   int // blocks decoded
+	BlockSize    int // bytes of the blocks decoded
+}
+
+// Add aggregates fields from c and other together. Overwrites c.
+func (c *IteratorCost) Add(other IteratorCost) {
+	c.NumShards += other.NumShards
+	c.NumSeries += other.NumSeries
+	c.CachedValues += other.CachedValues
+	c.NumFiles += other.NumFiles
+	c.BlocksRead += other.BlocksRead
+	c.BlockSize += other.BlockSize
+}
+
+// IteratorCoster is implemented by iterator creators that can estimate the
+// cost of an iterator before it is created.
+type IteratorCoster interface {
+	IteratorCost(opt IteratorOptions) (IteratorCost, error)
 }
 
 func encodeIteratorStats(stats *IteratorStats) *internal.IteratorStats {
 	return &internal.IteratorStats{
 		SeriesN: proto.Int64(int64(stats.SeriesN)),
 		PointN:  proto.Int64(int64(stats.PointN)),
+		BlockN:  proto.Int64(int64(stats.BlockN)),
 	}
 }
 
@@ -1171,6 +1602,7 @@ func decodeIteratorStats(pb *internal.IteratorStats) IteratorStats {
 	return IteratorStats{
 		SeriesN: int(pb.GetSeriesN()),
 		PointN:  int(pb.GetPointN()),
+		BlockN:  int(pb.GetBlockN()),
 	}
 }
 
@@ -1223,6 +1655,28 @@ type fastDedupeKey struct {
 	value interface{}
 }
 
+// comparePoints returns -1, 0 or 1 depending on whether the point with the
+// first name, tags and time comes before, at the same position as or after the
+// point with the second ones in the output of an iterator.  Iterators are
+// ordered by name, tags and time, in reverse when not ascending.
+func comparePoints(aName string, aTags Tags, aTime int64, bName string, bTags Tags, bTime int64, ascending bool) int {
+	cmp := 0
+	if aName != bName {
+		cmp = strings.Compare(aName, bName)
+	} else if aID, bID := aTags.ID(), bTags.ID(); aID != bID {
+		cmp = strings.Compare(aID, bID)
+	} else if aTime < bTime {
+		cmp = -1
+	} else if aTime > bTime {
+		cmp = 1
+	}
+
+	if !ascending {
+		return -cmp
+	}
+	return cmp
+}
+
 type reverseStringSlice []string
 
 func (p reverseStringSlice) Len() int           { return len(p) }
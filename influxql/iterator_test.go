@@ -2,6 +2,7 @@ package influxql_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -642,14 +643,14 @@ func TestFloatAuxIterator(t *testing.T) {
 		[]influxql.Series{
 			{Aux: []influxql.DataType{influxql.Float, influxql.Float}},
 		},
-		influxql.IteratorOptions{Aux: []string{"f0", "f1"}},
+		influxql.IteratorOptions{Aux: []influxql.VarRef{{Val: "f0"}, {Val: "f1"}}},
 	)
 
 	itrs := []influxql.Iterator{
 		itr,
-		itr.Iterator("f0"),
-		itr.Iterator("f1"),
-		itr.Iterator("f0"),
+		itr.Iterator("f0", influxql.Unknown),
+		itr.Iterator("f1", influxql.Unknown),
+		itr.Iterator("f0", influxql.Unknown),
 	}
 	itr.Start()
 
@@ -700,6 +701,68 @@ func TestLimitIterator(t *testing.T) {
 	}
 }
 
+// Ensure limit iterator stops reading its input once the limit is reached.
+func TestLimitIterator_EarlyTermination(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		opt    influxql.IteratorOptions
+		unread int
+	}{
+		{
+			name: "raw",
+			opt: influxql.IteratorOptions{
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Limit:   2,
+				Offset:  1,
+			},
+			unread: 2,
+		},
+		{
+			name: "group by time",
+			opt: influxql.IteratorOptions{
+				Sources:  []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Interval: influxql.Interval{Duration: 10},
+				Limit:    2,
+			},
+			unread: 3,
+		},
+		{
+			name: "group by tag",
+			opt: influxql.IteratorOptions{
+				Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Dimensions: []string{"host"},
+				Limit:      2,
+			},
+			unread: 0,
+		},
+		{
+			name: "multiple sources",
+			opt: influxql.IteratorOptions{
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}, &influxql.Measurement{Name: "mem"}},
+				Limit:   2,
+			},
+			unread: 0,
+		},
+	} {
+		input := &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0, Value: 0},
+			{Name: "cpu", Time: 10, Value: 1},
+			{Name: "cpu", Time: 20, Value: 2},
+			{Name: "cpu", Time: 30, Value: 3},
+			{Name: "cpu", Time: 40, Value: 4},
+		}}
+
+		itr := influxql.NewLimitIterator(input, tt.opt)
+		if a, err := (Iterators{itr}).ReadAll(); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		} else if len(a) != 2 {
+			t.Fatalf("%s: unexpected points: %s", tt.name, spew.Sdump(a))
+		} else if len(input.Points) != tt.unread {
+			t.Errorf("%s: unexpected unread points: %d", tt.name, len(input.Points))
+		}
+	}
+}
+
 // Iterators is a test wrapper for iterators.
 type Iterators []influxql.Iterator
 
@@ -794,6 +857,38 @@ func TestIteratorOptions_Window_Offset(t *testing.T) {
 	}
 }
 
+func TestIteratorOptions_Window_Location(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		now, start, end string
+	}{
+		{now: "2016-03-12T15:00:00-05:00", start: "2016-03-12T00:00:00-05:00", end: "2016-03-13T00:00:00-05:00"},
+		{now: "2016-03-13T15:00:00-04:00", start: "2016-03-13T00:00:00-05:00", end: "2016-03-14T00:00:00-04:00"},
+		{now: "2016-03-13T01:00:00-05:00", start: "2016-03-13T00:00:00-05:00", end: "2016-03-14T00:00:00-04:00"},
+		{now: "2016-11-06T15:00:00-05:00", start: "2016-11-06T00:00:00-04:00", end: "2016-11-07T00:00:00-05:00"},
+		{now: "2016-11-06T01:30:00-05:00", start: "2016-11-06T00:00:00-04:00", end: "2016-11-07T00:00:00-05:00"},
+	} {
+		opt := influxql.IteratorOptions{
+			Interval: influxql.Interval{
+				Duration: 24 * time.Hour,
+			},
+			Location: loc,
+		}
+
+		start, end := opt.Window(mustParseTime(tt.now).UnixNano())
+		if exp := mustParseTime(tt.start).UnixNano(); start != exp {
+			t.Errorf("%s: expected start to be %s, got %s", tt.now, tt.start, time.Unix(0, start).In(loc).Format(time.RFC3339))
+		}
+		if exp := mustParseTime(tt.end).UnixNano(); end != exp {
+			t.Errorf("%s: expected end to be %s, got %s", tt.now, tt.end, time.Unix(0, end).In(loc).Format(time.RFC3339))
+		}
+	}
+}
+
 func TestIteratorOptions_Window_Default(t *testing.T) {
 	opt := influxql.IteratorOptions{
 		StartTime: 0,
@@ -835,6 +930,121 @@ func TestIteratorOptions_SeekTime_Descending(t *testing.T) {
 	}
 }
 
+// Ensure the iterator creators are read in turn and only used once the
+// points of the previous creators have been read.
+func TestConcatIteratorCreators_CreateIterator(t *testing.T) {
+	var ic0, ic1, ic2 IteratorCreator
+	ic0.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 30, Aux: []interface{}{float64(3)}},
+			{Name: "cpu", Time: 20, Aux: []interface{}{float64(2)}},
+		}}, nil
+	}
+	ic1.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 10, Aux: []interface{}{float64(1)}},
+			{Name: "cpu", Time: 5, Aux: []interface{}{float64(0)}},
+		}}, nil
+	}
+	ic2.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		t.Fatal("unexpected iterator creation")
+		return nil, nil
+	}
+
+	opt := influxql.IteratorOptions{
+		Aux:     []influxql.VarRef{{Val: "value"}},
+		Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+		Limit:   3,
+	}
+	itr, err := influxql.ConcatIteratorCreators{&ic0, &ic1, &ic2}.CreateIterator(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itr = influxql.NewLimitIterator(itr, opt)
+	defer itr.Close()
+
+	var times []int64
+	for {
+		p, err := itr.(influxql.FloatIterator).Next()
+		if err != nil {
+			t.Fatal(err)
+		} else if p == nil {
+			break
+		}
+		times = append(times, p.Time)
+	}
+	if !reflect.DeepEqual(times, []int64{30, 20, 10}) {
+		t.Fatalf("unexpected times: %v", times)
+	}
+}
+
+// Ensure iterators read concurrently are merged in time order.
+func TestParallelIteratorCreators_CreateIterator(t *testing.T) {
+	// Enough points to be read in several batches.
+	var even, odd []influxql.FloatPoint
+	for i := 0; i < 5000; i += 2 {
+		even = append(even, influxql.FloatPoint{Name: "cpu", Time: int64(i), Value: float64(i)})
+		odd = append(odd, influxql.FloatPoint{Name: "cpu", Time: int64(i + 1), Value: float64(i + 1)})
+	}
+
+	var ic0, ic1, ic2 IteratorCreator
+	ic0.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: even}, nil
+	}
+	ic1.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: odd}, nil
+	}
+	ic2.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return nil, nil
+	}
+
+	ics := influxql.NewParallelIteratorCreators(influxql.IteratorCreators{&ic0, &ic1, &ic2}, 2)
+	itr, err := ics.CreateIterator(influxql.IteratorOptions{
+		Expr:      MustParseExpr(`value`),
+		Ascending: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer itr.Close()
+
+	var n int
+	for {
+		p, err := itr.(influxql.FloatIterator).Next()
+		if err != nil {
+			t.Fatal(err)
+		} else if p == nil {
+			break
+		} else if p.Time != int64(n) || p.Value != float64(n) {
+			t.Fatalf("unexpected point %d: %v", n, p)
+		}
+		n++
+	}
+	if n != 5000 {
+		t.Fatalf("unexpected point count: %d", n)
+	}
+}
+
+// Ensure iterators already created are closed if another creator fails.
+func TestParallelIteratorCreators_CreateIterator_Err(t *testing.T) {
+	input := &FloatIterator{Points: []influxql.FloatPoint{{Name: "cpu", Time: 0}}}
+
+	var ic0, ic1 IteratorCreator
+	ic0.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return input, nil
+	}
+	ic1.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return nil, errors.New("marker")
+	}
+
+	ics := influxql.NewParallelIteratorCreators(influxql.IteratorCreators{&ic0, &ic1}, 2)
+	if _, err := ics.CreateIterator(influxql.IteratorOptions{Expr: MustParseExpr(`value`)}); err == nil || err.Error() != "marker" {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !input.Closed {
+		t.Fatal("expected input to be closed")
+	}
+}
+
 func TestIteratorOptions_MergeSorted(t *testing.T) {
 	opt := influxql.IteratorOptions{}
 	sorted := opt.MergeSorted()
@@ -947,7 +1157,7 @@ func TestIteratorOptions_ElapsedInterval_Call(t *testing.T) {
 func TestIteratorOptions_MarshalBinary(t *testing.T) {
 	opt := &influxql.IteratorOptions{
 		Expr: MustParseExpr("count(value)"),
-		Aux:  []string{"a", "b", "c"},
+		Aux:  []influxql.VarRef{{Val: "a"}, {Val: "b", Type: influxql.Float}, {Val: "c", Type: influxql.Tag}},
 		Sources: []influxql.Source{
 			&influxql.Measurement{Database: "db0", RetentionPolicy: "rp0", Name: "mm0"},
 		},
@@ -984,6 +1194,27 @@ func TestIteratorOptions_MarshalBinary(t *testing.T) {
 	}
 }
 
+// Ensure iterator options with a time zone can be marshaled.
+func TestIteratorOptions_MarshalBinary_Location(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := &influxql.IteratorOptions{Location: loc}
+
+	buf, err := opt.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var other influxql.IteratorOptions
+	if err := other.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	} else if other.Location == nil || other.Location.String() != "America/New_York" {
+		t.Fatalf("unexpected location: %v", other.Location)
+	}
+}
+
 // Ensure iterator options with a regex measurement can be marshaled.
 func TestIteratorOptions_MarshalBinary_Measurement_Regex(t *testing.T) {
 	opt := &influxql.IteratorOptions{
@@ -1097,6 +1328,9 @@ func (ic *IteratorCreator) CreateIterator(opt influxql.IteratorOptions) (influxq
 }
 
 func (ic *IteratorCreator) FieldDimensions(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+	if ic.FieldDimensionsFn == nil {
+		return nil, nil, nil
+	}
 	return ic.FieldDimensionsFn(sources)
 }
 
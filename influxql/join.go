@@ -0,0 +1,128 @@
+package influxql
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// NewJoinIteratorCreator returns an IteratorCreator that reads the points of
+// the measurements of j, the source of stmt, joined on their time and the
+// tags stmt is grouped by.  stmts holds, for each measurement, a statement
+// selecting all of its fields grouped by all of its tags from the matching
+// iterator creator of ics.  The statements must be rewritten for execution,
+// as for Select.
+//
+// The joined points are buffered like the rows of a subquery and read the
+// same way.
+func NewJoinIteratorCreator(stmt *SelectStatement, j *Join, stmts []*SelectStatement, ics []IteratorCreator, opt SelectOptions) IteratorCreator {
+	s := &subQueryIteratorCreator{opt: opt}
+	on := joinKeys(stmt)
+	s.exec = func() error { return s.join(j, on, stmts, ics) }
+	return s
+}
+
+// joinKeys returns the tags the points of the measurements joined by stmt
+// are matched on: the tags stmt is grouped by and the names its condition
+// filters on.  It returns nil if stmt is grouped by all tags.
+func joinKeys(stmt *SelectStatement) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, d := range stmt.Dimensions {
+		switch expr := d.Expr.(type) {
+		case *VarRef:
+			keys[expr.Val] = struct{}{}
+		case *Wildcard, *RegexLiteral:
+			return nil
+		}
+	}
+	for _, name := range stmt.NamesInWhere() {
+		keys[name] = struct{}{}
+	}
+	return keys
+}
+
+// join executes the statement of each joined measurement and merges the rows
+// with the same time and the same values of the tags in on, or of all tags if
+// on is nil.  Each column is prefixed with the name of its measurement and is nil
+// in the rows the measurement has no value for.
+func (s *subQueryIteratorCreator) join(j *Join, on map[string]struct{}, stmts []*SelectStatement, ics []IteratorCreator) error {
+	s.index = make(map[string]int)
+	s.types = make(map[string]DataType)
+	s.dims = make(map[string]struct{})
+
+	columns := make([][]string, len(stmts))
+	offsets := make([]int, len(stmts))
+	for i, stmt := range stmts {
+		offsets[i] = len(s.index)
+		for _, c := range stmt.ColumnNames()[1:] {
+			name := j.Measurements[i].Name + "." + c
+			columns[i] = append(columns[i], name)
+			s.index[name] = len(s.index)
+			s.types[name] = Unknown
+		}
+		for _, d := range stmt.Dimensions {
+			if ref, ok := d.Expr.(*VarRef); ok {
+				if _, ok := on[ref.Val]; ok || on == nil {
+					s.dims[ref.Val] = struct{}{}
+				}
+			}
+		}
+	}
+	width := len(s.index)
+
+	name := j.Name()
+	keys := make(map[string]int) // time and tags to index in the rows
+	for i, stmt := range stmts {
+		if err := s.readRows(stmt, ics[i], func(row *models.Row) error {
+			m := row.Tags
+			if on != nil {
+				m = make(map[string]string, len(on))
+				for k, v := range row.Tags {
+					if _, ok := on[k]; ok {
+						m[k] = v
+					}
+				}
+			}
+			tags := NewTags(m)
+
+			for _, values := range row.Values {
+				t := values[0].(time.Time).UnixNano()
+				key := strconv.FormatInt(t, 10) + "\x00" + tags.ID()
+
+				n, ok := keys[key]
+				if !ok {
+					r := subQueryRow{
+						name:   name,
+						tags:   tags,
+						time:   t,
+						values: make([]interface{}, width),
+					}
+
+					// The rows are held until the statement is done so their
+					// memory is released with the budget of the statement.
+					if err := s.opt.MemoryBudget.Reserve(r.size()); err != nil {
+						return err
+					}
+					n = len(s.rows)
+					keys[key] = n
+					s.rows = append(s.rows, r)
+				}
+
+				for k, v := range values[1:] {
+					if str, ok := v.(string); ok {
+						if err := s.opt.MemoryBudget.Reserve(int64(len(str))); err != nil {
+							return err
+						}
+					}
+					s.rows[n].values[offsets[i]+k] = v
+					s.setType(columns[i][k], v)
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Package neldermead implements the Nelder-Mead simplex method to find a local
+// minimum of a function of several variables.
+package neldermead
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	defaultReflection  = 1.0
+	defaultExpansion   = 2.0
+	defaultContraction = 0.5
+	defaultShrink      = 0.5
+
+	// DefaultMaxIterations is the default maximum number of iterations of
+	// the method.
+	DefaultMaxIterations = 1000
+)
+
+// Optimizer minimizes functions using the Nelder-Mead method.
+type Optimizer struct {
+	// Coefficients used to transform the simplex.
+	Reflection  float64
+	Expansion   float64
+	Contraction float64
+	Shrink      float64
+
+	// Maximum number of iterations before returning the best point found.
+	MaxIterations int
+}
+
+// New returns an Optimizer with the standard coefficients.
+func New() *Optimizer {
+	return &Optimizer{
+		Reflection:    defaultReflection,
+		Expansion:     defaultExpansion,
+		Contraction:   defaultContraction,
+		Shrink:        defaultShrink,
+		MaxIterations: DefaultMaxIterations,
+	}
+}
+
+// vertex is a point of the simplex and the value of the function at it.
+type vertex struct {
+	x []float64
+	f float64
+}
+
+// Optimize returns the minimum value of fn found starting from start, and the
+// point at which it was found.  The initial simplex has start as a vertex and
+// extends by scale along every axis.  The search stops once the values of fn
+// at the vertices of the simplex differ by less than epsilon.
+func (o *Optimizer) Optimize(fn func([]float64) float64, start []float64, epsilon, scale float64) (float64, []float64) {
+	n := len(start)
+
+	// Build the initial simplex.
+	simplex := make([]vertex, n+1)
+	for i := range simplex {
+		x := make([]float64, n)
+		copy(x, start)
+		if i > 0 {
+			x[i-1] += scale
+		}
+		simplex[i] = vertex{x: x, f: fn(x)}
+	}
+
+	centroid := make([]float64, n)
+	point := func(from []float64, coef float64) vertex {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = centroid[i] + coef*(from[i]-centroid[i])
+		}
+		return vertex{x: x, f: fn(x)}
+	}
+
+	for iter := 0; iter < o.MaxIterations; iter++ {
+		sort.Sort(vertices(simplex))
+		best, worst := simplex[0], simplex[n]
+		if math.Abs(worst.f-best.f) < epsilon {
+			break
+		}
+
+		// Compute the centroid of all vertices but the worst.
+		for i := range centroid {
+			centroid[i] = 0
+			for _, v := range simplex[:n] {
+				centroid[i] += v.x[i]
+			}
+			centroid[i] /= float64(n)
+		}
+
+		// Reflect the worst vertex through the centroid.
+		r := point(worst.x, -o.Reflection)
+		if r.f < best.f {
+			// Expand further in the same direction if it is even better.
+			if e := point(r.x, o.Expansion); e.f < r.f {
+				simplex[n] = e
+			} else {
+				simplex[n] = r
+			}
+			continue
+		} else if r.f < simplex[n-1].f {
+			simplex[n] = r
+			continue
+		}
+
+		// Contract the worst vertex toward the centroid.
+		if c := point(worst.x, o.Contraction); c.f < worst.f {
+			simplex[n] = c
+			continue
+		}
+
+		// Shrink the simplex toward the best vertex.
+		for i := 1; i <= n; i++ {
+			for j := range simplex[i].x {
+				simplex[i].x[j] = best.x[j] + o.Shrink*(simplex[i].x[j]-best.x[j])
+			}
+			simplex[i].f = fn(simplex[i].x)
+		}
+	}
+
+	sort.Sort(vertices(simplex))
+	return simplex[0].f, simplex[0].x
+}
+
+// vertices sorts vertices by the value of the function at them.
+type vertices []vertex
+
+func (a vertices) Len() int           { return len(a) }
+func (a vertices) Less(i, j int) bool { return a[i].f < a[j].f }
+func (a vertices) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
@@ -0,0 +1,55 @@
+package neldermead_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/influxdata/influxdb/influxql/neldermead"
+)
+
+// Ensure the optimizer finds the minimum of a quadratic function.
+func TestOptimizer_Optimize_Quadratic(t *testing.T) {
+	fn := func(x []float64) float64 {
+		return (x[0]-3)*(x[0]-3) + (x[1]+1)*(x[1]+1) + 2
+	}
+
+	min, x := neldermead.New().Optimize(fn, []float64{0, 0}, 1e-12, 1)
+	if math.Abs(min-2) > 1e-6 {
+		t.Fatalf("unexpected minimum: %v", min)
+	} else if math.Abs(x[0]-3) > 1e-3 || math.Abs(x[1]+1) > 1e-3 {
+		t.Fatalf("unexpected point: %v", x)
+	}
+}
+
+// Ensure the optimizer finds the minimum of the Rosenbrock function.
+func TestOptimizer_Optimize_Rosenbrock(t *testing.T) {
+	fn := func(x []float64) float64 {
+		return (1-x[0])*(1-x[0]) + 100*(x[1]-x[0]*x[0])*(x[1]-x[0]*x[0])
+	}
+
+	min, x := neldermead.New().Optimize(fn, []float64{-1, 2}, 1e-15, 0.5)
+	if min > 1e-6 {
+		t.Fatalf("unexpected minimum: %v", min)
+	} else if math.Abs(x[0]-1) > 1e-2 || math.Abs(x[1]-1) > 1e-2 {
+		t.Fatalf("unexpected point: %v", x)
+	}
+}
+
+// Ensure the optimizer stops after the maximum number of iterations.
+func TestOptimizer_Optimize_MaxIterations(t *testing.T) {
+	var n int
+	fn := func(x []float64) float64 {
+		n++
+		return x[0] * x[0]
+	}
+
+	o := neldermead.New()
+	o.MaxIterations = 5
+	o.Optimize(fn, []float64{10}, 0, 1)
+
+	// The initial simplex has 2 vertices and each iteration evaluates the
+	// function at most 3 times.
+	if n > 2+5*3 {
+		t.Fatalf("unexpected number of evaluations: %d", n)
+	}
+}
@@ -2,6 +2,7 @@ package influxql
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -23,7 +24,8 @@ const (
 
 // Parser represents an InfluxQL parser.
 type Parser struct {
-	s *bufScanner
+	s      *bufScanner
+	params map[string]interface{}
 }
 
 // NewParser returns a new instance of Parser.
@@ -31,6 +33,14 @@ func NewParser(r io.Reader) *Parser {
 	return &Parser{s: newBufScanner(r)}
 }
 
+// SetParams sets the values of the bound parameters, such as $host, used in
+// the expressions of the query.  Strings, numbers and booleans are bound as
+// literals of their type.  A map with a single "duration" or "regex" key binds
+// a duration or a regular expression parsed from its string value.
+func (p *Parser) SetParams(params map[string]interface{}) {
+	p.params = params
+}
+
 // ParseQuery parses a query string and returns its AST representation.
 func ParseQuery(s string) (*Query, error) { return NewParser(strings.NewReader(s)).ParseQuery() }
 
@@ -107,12 +117,18 @@ func (p *Parser) ParseStatement() (Statement, error) {
 	case ALTER:
 		return p.parseAlterStatement()
 	case SET:
-		return p.parseSetPasswordUserStatement()
+		return p.parseSetStatement()
 	case KILL:
 		return p.parseKillQueryStatement()
-	default:
-		return nil, newParseError(tokstr(tok, lit), []string{"SELECT", "DELETE", "SHOW", "CREATE", "DROP", "GRANT", "REVOKE", "ALTER", "SET", "KILL"}, pos)
+	case EXPLAIN:
+		return p.parseExplainStatement()
+	case IDENT:
+		// COMPACT is not a reserved keyword so it is matched against the identifier.
+		if strings.ToUpper(lit) == "COMPACT" {
+			return p.parseCompactStatement()
+		}
 	}
+	return nil, newParseError(tokstr(tok, lit), []string{"SELECT", "DELETE", "SHOW", "CREATE", "DROP", "GRANT", "REVOKE", "ALTER", "SET", "KILL", "COMPACT", "EXPLAIN"}, pos)
 }
 
 // parseShowStatement parses a string and returns a list statement.
@@ -132,6 +148,15 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 			return p.parseShowFieldKeysStatement()
 		}
 		return nil, newParseError(tokstr(tok, lit), []string{"KEYS"}, pos)
+	case MEASUREMENT:
+		exact, ok, err := p.parseCardinality()
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			tok, pos, lit := p.scanIgnoreWhitespace()
+			return nil, newParseError(tokstr(tok, lit), []string{"EXACT", "CARDINALITY"}, pos)
+		}
+		return p.parseShowMeasurementCardinalityStatement(exact)
 	case MEASUREMENTS:
 		return p.parseShowMeasurementsStatement()
 	case QUERIES:
@@ -143,6 +168,11 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		}
 		return nil, newParseError(tokstr(tok, lit), []string{"POLICIES"}, pos)
 	case SERIES:
+		if exact, ok, err := p.parseCardinality(); err != nil {
+			return nil, err
+		} else if ok {
+			return p.parseShowSeriesCardinalityStatement(exact)
+		}
 		return p.parseShowSeriesStatement()
 	case SHARD:
 		tok, pos, lit := p.scanIgnoreWhitespace()
@@ -161,6 +191,11 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		if tok == KEYS {
 			return p.parseShowTagKeysStatement()
 		} else if tok == VALUES {
+			if exact, ok, err := p.parseCardinality(); err != nil {
+				return nil, err
+			} else if ok {
+				return p.parseShowTagValuesCardinalityStatement(exact)
+			}
 			return p.parseShowTagValuesStatement()
 		}
 		return nil, newParseError(tokstr(tok, lit), []string{"KEYS", "VALUES"}, pos)
@@ -168,6 +203,12 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		return p.parseShowUsersStatement()
 	case SUBSCRIPTIONS:
 		return p.parseShowSubscriptionsStatement()
+	case IDENT:
+		// SCHEMAS is not a reserved keyword so it is matched against the
+		// identifier instead.
+		if strings.ToUpper(lit) == "SCHEMAS" {
+			return &ShowSchemasStatement{}, nil
+		}
 	}
 
 	showQueryKeywords := []string{
@@ -175,9 +216,11 @@ func (p *Parser) parseShowStatement() (Statement, error) {
 		"DATABASES",
 		"FIELD",
 		"GRANTS",
+		"MEASUREMENT",
 		"MEASUREMENTS",
 		"QUERIES",
 		"RETENTION",
+		"SCHEMAS",
 		"SERIES",
 		"TAG",
 		"USERS",
@@ -210,9 +253,11 @@ func (p *Parser) parseCreateStatement() (Statement, error) {
 		return p.parseCreateRetentionPolicyStatement()
 	} else if tok == SUBSCRIPTION {
 		return p.parseCreateSubscriptionStatement()
+	} else if tok == IDENT && strings.ToUpper(lit) == "SCHEMA" {
+		return p.parseCreateSchemaStatement()
 	}
 
-	return nil, newParseError(tokstr(tok, lit), []string{"CONTINUOUS", "DATABASE", "USER", "RETENTION", "SUBSCRIPTION"}, pos)
+	return nil, newParseError(tokstr(tok, lit), []string{"CONTINUOUS", "DATABASE", "USER", "RETENTION", "SUBSCRIPTION", "SCHEMA"}, pos)
 }
 
 // parseDropStatement parses a string and returns a drop statement.
@@ -239,9 +284,14 @@ func (p *Parser) parseDropStatement() (Statement, error) {
 		return p.parseDropSubscriptionStatement()
 	case USER:
 		return p.parseDropUserStatement()
-	default:
-		return nil, newParseError(tokstr(tok, lit), []string{"CONTINUOUS", "MEASUREMENT", "RETENTION", "SERIES", "SHARD", "SUBSCRIPTION", "USER"}, pos)
+	case IDENT:
+		// SCHEMA is not a reserved keyword so it is matched against the
+		// identifier instead.
+		if strings.ToUpper(lit) == "SCHEMA" {
+			return p.parseDropSchemaStatement()
+		}
 	}
+	return nil, newParseError(tokstr(tok, lit), []string{"CONTINUOUS", "MEASUREMENT", "RETENTION", "SCHEMA", "SERIES", "SHARD", "SUBSCRIPTION", "USER"}, pos)
 }
 
 // parseAlterStatement parses a string and returns an alter statement.
@@ -253,9 +303,180 @@ func (p *Parser) parseAlterStatement() (Statement, error) {
 			return nil, newParseError(tokstr(tok, lit), []string{"POLICY"}, pos)
 		}
 		return p.parseAlterRetentionPolicyStatement()
+	} else if tok == SHARD {
+		return p.parseAlterShardTierStatement()
+	}
+
+	return nil, newParseError(tokstr(tok, lit), []string{"RETENTION", "SHARD"}, pos)
+}
+
+// parseAlterShardTierStatement parses a string and returns an
+// AlterShardTierStatement. This function assumes the ALTER SHARD tokens have
+// already been consumed.
+func (p *Parser) parseAlterShardTierStatement() (*AlterShardTierStatement, error) {
+	var err error
+	stmt := &AlterShardTierStatement{}
+
+	// Parse the ID of the shard to be moved.
+	if stmt.ID, err = p.parseUInt64(); err != nil {
+		return nil, err
+	}
+
+	// TIER, HOT and COLD are not reserved keywords so they are matched
+	// against the identifier instead.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToUpper(lit) != "TIER" {
+		return nil, newParseError(tokstr(tok, lit), []string{"TIER"}, pos)
+	}
+
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok == IDENT && strings.ToUpper(lit) == "COLD" {
+		stmt.Cold = true
+	} else if tok != IDENT || strings.ToUpper(lit) != "HOT" {
+		return nil, newParseError(tokstr(tok, lit), []string{"HOT", "COLD"}, pos)
+	}
+	return stmt, nil
+}
+
+// parseCreateSchemaStatement parses a string and returns a
+// CreateSchemaStatement. This function assumes the CREATE SCHEMA tokens have
+// already been consumed.
+func (p *Parser) parseCreateSchemaStatement() (*CreateSchemaStatement, error) {
+	stmt := &CreateSchemaStatement{}
+
+	// Read the name of the measurement and its database.
+	var err error
+	if stmt.Name, err = p.parseIdent(); err != nil {
+		return nil, err
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != ON {
+		return nil, newParseError(tokstr(tok, lit), []string{"ON"}, pos)
+	}
+	if stmt.Database, err = p.parseIdent(); err != nil {
+		return nil, err
+	}
+
+	// Read the parenthesized list of columns.  Each column is a tag key
+	// followed by TAG or a field name followed by its type.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != LPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+	}
+	seen := make(map[string]struct{})
+	for {
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate column in schema: %s", name)
+		}
+		seen[name] = struct{}{}
+
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok == TAG {
+			stmt.TagKeys = append(stmt.TagKeys, name)
+		} else if typ := schemaFieldType(tok, lit); typ != Unknown {
+			stmt.Fields = append(stmt.Fields, SchemaField{Name: name, Type: typ})
+		} else {
+			return nil, newParseError(tokstr(tok, lit), []string{"TAG", "FLOAT", "INTEGER", "STRING", "BOOLEAN"}, pos)
+		}
+
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok == RPAREN {
+			break
+		} else if tok != COMMA {
+			return nil, newParseError(tokstr(tok, lit), []string{",", ")"}, pos)
+		}
+	}
+
+	// STRICT is not a reserved keyword so it is matched against the
+	// identifier instead.
+	if tok, _, lit := p.scanIgnoreWhitespace(); tok == IDENT && strings.ToUpper(lit) == "STRICT" {
+		stmt.Strict = true
+	} else {
+		p.unscan()
+	}
+	return stmt, nil
+}
+
+// schemaFieldType returns the data type named by a field type in a schema,
+// or Unknown if it does not name one.
+func schemaFieldType(tok Token, lit string) DataType {
+	if tok != IDENT {
+		return Unknown
+	}
+	switch strings.ToUpper(lit) {
+	case "FLOAT":
+		return Float
+	case "INTEGER":
+		return Integer
+	case "STRING":
+		return String
+	case "BOOLEAN":
+		return Boolean
+	}
+	return Unknown
+}
+
+// parseDropSchemaStatement parses a string and returns a DropSchemaStatement.
+// This function assumes the DROP SCHEMA tokens have already been consumed.
+func (p *Parser) parseDropSchemaStatement() (*DropSchemaStatement, error) {
+	stmt := &DropSchemaStatement{}
+
+	var err error
+	if stmt.Name, err = p.parseIdent(); err != nil {
+		return nil, err
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != ON {
+		return nil, newParseError(tokstr(tok, lit), []string{"ON"}, pos)
+	}
+	if stmt.Database, err = p.parseIdent(); err != nil {
+		return nil, err
 	}
+	return stmt, nil
+}
 
-	return nil, newParseError(tokstr(tok, lit), []string{"RETENTION"}, pos)
+// parseSetStatement parses a string and returns a set statement.
+// This function assumes the SET token has already been consumed.
+func (p *Parser) parseSetStatement() (Statement, error) {
+	// NOW is not a reserved keyword so it is matched against the identifier.
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok == IDENT && strings.ToUpper(lit) == "NOW" {
+		return p.parseSetNowStatement()
+	} else if tok != PASSWORD {
+		return nil, newParseError(tokstr(tok, lit), []string{"PASSWORD", "NOW"}, pos)
+	}
+	p.unscan()
+	return p.parseSetPasswordUserStatement()
+}
+
+// parseSetNowStatement parses a string and returns a set now statement.
+// This function assumes the SET NOW tokens have already been consumed.
+func (p *Parser) parseSetNowStatement() (*SetNowStatement, error) {
+	// Consume the required = token.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != EQ {
+		return nil, newParseError(tokstr(tok, lit), []string{"="}, pos)
+	}
+
+	// The time is either a time string or an integer in nanoseconds.
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case STRING:
+		expr, err := parseStringLiteral(lit, pos)
+		if err != nil {
+			return nil, err
+		}
+		t, ok := expr.(*TimeLiteral)
+		if !ok {
+			return nil, &ParseError{Message: "unable to parse datetime", Pos: pos}
+		}
+		return &SetNowStatement{Now: t.Val}, nil
+	case INTEGER:
+		n, err := strconv.ParseInt(lit, 10, 64)
+		if err != nil {
+			return nil, &ParseError{Message: "unable to parse integer", Pos: pos}
+		}
+		return &SetNowStatement{Now: time.Unix(0, n).UTC()}, nil
+	}
+	return nil, newParseError(tokstr(tok, lit), []string{"string", "integer"}, pos)
 }
 
 // parseSetPasswordUserStatement parses a string and returns a set statement.
@@ -304,6 +525,30 @@ func (p *Parser) parseKillQueryStatement() (*KillQueryStatement, error) {
 	return &KillQueryStatement{QueryID: qid}, nil
 }
 
+// parseExplainStatement parses a string and returns an ExplainStatement.
+// This function assumes the EXPLAIN token has already been consumed.
+func (p *Parser) parseExplainStatement() (*ExplainStatement, error) {
+	stmt := &ExplainStatement{}
+
+	// ANALYZE is not a reserved keyword so it is matched against the identifier.
+	if tok, _, lit := p.scanIgnoreWhitespace(); tok == IDENT && strings.ToUpper(lit) == "ANALYZE" {
+		stmt.Analyze = true
+	} else {
+		p.unscan()
+	}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != SELECT {
+		return nil, newParseError(tokstr(tok, lit), []string{"SELECT"}, pos)
+	}
+
+	s, err := p.parseSelectStatement(targetNotRequired)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Statement = s
+	return stmt, nil
+}
+
 // parseCreateSubscriptionStatement parses a string and returns a CreatesubScriptionStatement.
 // This function assumes the "CREATE SUBSCRIPTION" tokens have already been consumed.
 func (p *Parser) parseCreateSubscriptionStatement() (*CreateSubscriptionStatement, error) {
@@ -459,7 +704,7 @@ func (p *Parser) parseAlterRetentionPolicyStatement() (*AlterRetentionPolicyStat
 	stmt.Database = ident
 
 	// Loop through option tokens (DURATION, REPLICATION, DEFAULT, etc.).
-	maxNumOptions := 3
+	maxNumOptions := 5
 Loop:
 	for i := 0; i < maxNumOptions; i++ {
 		tok, pos, lit := p.scanIgnoreWhitespace()
@@ -487,11 +732,24 @@ Loop:
 			} else {
 				return nil, newParseError(tokstr(tok, lit), []string{"DURATION"}, pos)
 			}
+		case MEASUREMENT:
+			ident, err := p.parseIdent()
+			if err != nil {
+				return nil, err
+			}
+			if tok, pos, lit := p.scanIgnoreWhitespace(); tok != DURATION {
+				return nil, newParseError(tokstr(tok, lit), []string{"DURATION"}, pos)
+			}
+			d, err := p.parseDuration()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Measurement, stmt.MeasurementDuration = ident, &d
 		case DEFAULT:
 			stmt.Default = true
 		default:
 			if i < 1 {
-				return nil, newParseError(tokstr(tok, lit), []string{"DURATION", "RETENTION", "SHARD", "DEFAULT"}, pos)
+				return nil, newParseError(tokstr(tok, lit), []string{"DURATION", "RETENTION", "SHARD", "MEASUREMENT", "DEFAULT"}, pos)
 			}
 			p.unscan()
 			break Loop
@@ -882,7 +1140,7 @@ func (p *Parser) parseSelectStatement(tr targetRequirement) (*SelectStatement, e
 	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
 		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
 	}
-	if stmt.Sources, err = p.parseSources(); err != nil {
+	if stmt.Sources, err = p.parseSources(true); err != nil {
 		return nil, err
 	}
 
@@ -901,6 +1159,11 @@ func (p *Parser) parseSelectStatement(tr targetRequirement) (*SelectStatement, e
 		return nil, err
 	}
 
+	// Parse aggregate condition: "HAVING EXPR".
+	if stmt.Having, err = p.parseHaving(); err != nil {
+		return nil, err
+	}
+
 	// Parse sort: "ORDER BY FIELD+".
 	if stmt.SortFields, err = p.parseOrderBy(); err != nil {
 		return nil, err
@@ -926,10 +1189,15 @@ func (p *Parser) parseSelectStatement(tr targetRequirement) (*SelectStatement, e
 		return nil, err
 	}
 
+	// Parse time zone: "tz(<string>)".
+	if stmt.Location, err = p.parseLocation(); err != nil {
+		return nil, err
+	}
+
 	// Set if the query is a raw data query or one with an aggregate
 	stmt.IsRawQuery = true
 	WalkFunc(stmt.Fields, func(n Node) {
-		if _, ok := n.(*Call); ok {
+		if call, ok := n.(*Call); ok && !isScalarFunction(call.Name) {
 			stmt.IsRawQuery = false
 		}
 	})
@@ -947,6 +1215,10 @@ type targetRequirement int
 const (
 	targetRequired targetRequirement = iota
 	targetNotRequired
+
+	// targetSubQuery is used for subqueries, which are validated as part of
+	// the statement selecting from them.
+	targetSubQuery
 )
 
 // parseTarget parses a string and returns a Target.
@@ -1003,7 +1275,7 @@ func (p *Parser) parseDeleteStatement() (Statement, error) {
 
 	if tok == FROM {
 		// Parse source.
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1031,7 +1303,7 @@ func (p *Parser) parseShowSeriesStatement() (*ShowSeriesStatement, error) {
 
 	// Parse optional FROM.
 	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1061,6 +1333,72 @@ func (p *Parser) parseShowSeriesStatement() (*ShowSeriesStatement, error) {
 	return stmt, nil
 }
 
+// parseCardinality parses the optional "[EXACT] CARDINALITY" tokens of a
+// cardinality statement.  If the next token is neither, ok is false and no
+// tokens are consumed.
+func (p *Parser) parseCardinality() (exact, ok bool, err error) {
+	// EXACT and CARDINALITY are not reserved keywords so they are matched
+	// against the identifier instead.
+	tok, _, lit := p.scanIgnoreWhitespace()
+	if tok == IDENT && strings.ToUpper(lit) == "CARDINALITY" {
+		return false, true, nil
+	} else if tok != IDENT || strings.ToUpper(lit) != "EXACT" {
+		p.unscan()
+		return false, false, nil
+	}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToUpper(lit) != "CARDINALITY" {
+		return false, false, newParseError(tokstr(tok, lit), []string{"CARDINALITY"}, pos)
+	}
+	return true, true, nil
+}
+
+// parseShowSeriesCardinalityStatement parses a string and returns a
+// ShowSeriesCardinalityStatement. This function assumes the
+// "SHOW SERIES [EXACT] CARDINALITY" tokens have already been consumed.
+func (p *Parser) parseShowSeriesCardinalityStatement(exact bool) (*ShowSeriesCardinalityStatement, error) {
+	stmt := &ShowSeriesCardinalityStatement{Exact: exact}
+	var err error
+
+	// Parse optional FROM.
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	// Parse condition: "WHERE EXPR".
+	if stmt.Condition, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseShowMeasurementCardinalityStatement parses a string and returns a
+// ShowMeasurementCardinalityStatement. This function assumes the
+// "SHOW MEASUREMENT [EXACT] CARDINALITY" tokens have already been consumed.
+func (p *Parser) parseShowMeasurementCardinalityStatement(exact bool) (*ShowMeasurementCardinalityStatement, error) {
+	stmt := &ShowMeasurementCardinalityStatement{Exact: exact}
+	var err error
+
+	// Parse optional FROM.
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	// Parse condition: "WHERE EXPR".
+	if stmt.Condition, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
 // parseShowMeasurementsStatement parses a string and returns a ShowSeriesStatement.
 // This function assumes the "SHOW MEASUREMENTS" tokens have already been consumed.
 func (p *Parser) parseShowMeasurementsStatement() (*ShowMeasurementsStatement, error) {
@@ -1079,7 +1417,7 @@ func (p *Parser) parseShowMeasurementsStatement() (*ShowMeasurementsStatement, e
 		switch tok {
 		case EQ, EQREGEX:
 			// Parse required source (measurement name or regex).
-			if stmt.Source, err = p.parseSource(); err != nil {
+			if stmt.Source, err = p.parseSource(false); err != nil {
 				return nil, err
 			}
 		default:
@@ -1147,7 +1485,7 @@ func (p *Parser) parseShowTagKeysStatement() (*ShowTagKeysStatement, error) {
 
 	// Parse optional source.
 	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1195,7 +1533,7 @@ func (p *Parser) parseShowTagValuesStatement() (*ShowTagValuesStatement, error)
 
 	// Parse optional source.
 	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1230,6 +1568,34 @@ func (p *Parser) parseShowTagValuesStatement() (*ShowTagValuesStatement, error)
 	return stmt, nil
 }
 
+// parseShowTagValuesCardinalityStatement parses a string and returns a
+// ShowTagValuesCardinalityStatement. This function assumes the
+// "SHOW TAG VALUES [EXACT] CARDINALITY" tokens have already been consumed.
+func (p *Parser) parseShowTagValuesCardinalityStatement(exact bool) (*ShowTagValuesCardinalityStatement, error) {
+	stmt := &ShowTagValuesCardinalityStatement{Exact: exact}
+	var err error
+
+	// Parse optional source.
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	// Parse required WITH KEY.
+	if stmt.TagKeys, err = p.parseTagKeys(); err != nil {
+		return nil, err
+	}
+
+	// Parse condition: "WHERE EXPR".
+	if stmt.Condition, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
 // parseTagKeys parses a string and returns a list of tag keys.
 func (p *Parser) parseTagKeys() ([]string, error) {
 	var err error
@@ -1292,13 +1658,18 @@ func (p *Parser) parseShowFieldKeysStatement() (*ShowFieldKeysStatement, error)
 
 	// Parse optional source.
 	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
 		p.unscan()
 	}
 
+	// Parse condition: "WHERE EXPR".
+	if stmt.Condition, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+
 	// Parse sort: "ORDER BY FIELD+".
 	if stmt.SortFields, err = p.parseOrderBy(); err != nil {
 		return nil, err
@@ -1342,7 +1713,7 @@ func (p *Parser) parseDropSeriesStatement() (*DropSeriesStatement, error) {
 
 	if tok == FROM {
 		// Parse source.
-		if stmt.Sources, err = p.parseSources(); err != nil {
+		if stmt.Sources, err = p.parseSources(false); err != nil {
 			return nil, err
 		}
 	} else {
@@ -1376,6 +1747,47 @@ func (p *Parser) parseDropShardStatement() (*DropShardStatement, error) {
 	return stmt, nil
 }
 
+// parseCompactStatement parses a string and returns either a
+// CompactShardStatement or a PauseCompactionsStatement. This function
+// assumes the COMPACT token has already been consumed.
+func (p *Parser) parseCompactStatement() (Statement, error) {
+	var shardID uint64
+
+	// FULL, PAUSE and RESUME are not reserved keywords so they are
+	// matched against the identifier instead.
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok == SHARD {
+		// Parse the ID of the shard.
+		var err error
+		if shardID, err = p.parseUInt64(); err != nil {
+			return nil, err
+		}
+
+		tok, pos, lit = p.scanIgnoreWhitespace()
+		if tok == IDENT && strings.ToUpper(lit) == "FULL" {
+			return &CompactShardStatement{ID: shardID}, nil
+		} else if tok != IDENT || !isCompactionsAction(lit) {
+			return nil, newParseError(tokstr(tok, lit), []string{"FULL", "PAUSE", "RESUME"}, pos)
+		}
+	} else if tok != IDENT || !isCompactionsAction(lit) {
+		return nil, newParseError(tokstr(tok, lit), []string{"SHARD", "PAUSE", "RESUME"}, pos)
+	}
+
+	return &PauseCompactionsStatement{
+		ShardID: shardID,
+		Resume:  strings.ToUpper(lit) == "RESUME",
+	}, nil
+}
+
+// isCompactionsAction returns true if lit is PAUSE or RESUME.
+func isCompactionsAction(lit string) bool {
+	switch strings.ToUpper(lit) {
+	case "PAUSE", "RESUME":
+		return true
+	}
+	return false
+}
+
 // parseShowContinuousQueriesStatement parses a string and returns a ShowContinuousQueriesStatement.
 // This function assumes the "SHOW CONTINUOUS" tokens have already been consumed.
 func (p *Parser) parseShowContinuousQueriesStatement() (*ShowContinuousQueriesStatement, error) {
@@ -1882,13 +2294,21 @@ func (p *Parser) parseField() (*Field, error) {
 }
 
 // validateField checks if the Expr is a valid field. We disallow all binary expression
-// that return a boolean
+// that return a boolean, except for the conditions of a CASE expression.
 type validateField struct {
 	foundInvalid bool
 	badToken     Token
 }
 
 func (c *validateField) Visit(n Node) Visitor {
+	if e, ok := n.(*CaseExpr); ok {
+		for _, w := range e.WhenClauses {
+			Walk(c, w.Result)
+		}
+		Walk(c, e.Else)
+		return nil
+	}
+
 	e, ok := n.(*BinaryExpr)
 	if !ok {
 		return c
@@ -1921,15 +2341,23 @@ func (p *Parser) parseAlias() (string, error) {
 	return lit, nil
 }
 
-// parseSources parses a comma delimited list of sources.
-func (p *Parser) parseSources() (Sources, error) {
+// parseSources parses a comma delimited list of sources.  Subqueries are
+// only parsed if subqueries is set.
+func (p *Parser) parseSources(subqueries bool) (Sources, error) {
 	var sources Sources
 
 	for {
-		s, err := p.parseSource()
+		s, err := p.parseSource(subqueries)
 		if err != nil {
 			return nil, err
 		}
+
+		// Measurements can only be joined where subqueries are allowed.
+		if subqueries {
+			if s, err = p.parseJoin(s); err != nil {
+				return nil, err
+			}
+		}
 		sources = append(sources, s)
 
 		if tok, _, _ := p.scanIgnoreWhitespace(); tok != COMMA {
@@ -1941,6 +2369,55 @@ func (p *Parser) parseSources() (Sources, error) {
 	return sources, nil
 }
 
+// parseJoin parses the measurements joined to src with "JOIN".  src is
+// returned unchanged if it is not followed by "JOIN".
+//
+// JOIN is not a reserved keyword so it is matched against the identifier.
+func (p *Parser) parseJoin(src Source) (Source, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != IDENT || strings.ToUpper(lit) != "JOIN" {
+		p.unscan()
+		return src, nil
+	}
+
+	m, ok := src.(*Measurement)
+	if !ok {
+		return nil, &ParseError{Message: "a subquery cannot be joined", Pos: pos}
+	}
+	join := &Join{Measurements: Measurements{m}}
+
+	for {
+		src, err := p.parseSource(false)
+		if err != nil {
+			return nil, err
+		}
+		join.Measurements = append(join.Measurements, src.(*Measurement))
+
+		if tok, _, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToUpper(lit) != "JOIN" {
+			p.unscan()
+			return join, nil
+		}
+	}
+}
+
+// parseSubQuery parses a select statement in parentheses.  The opening
+// parenthesis has already been read.
+func (p *Parser) parseSubQuery() (*SubQuery, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != SELECT {
+		return nil, newParseError(tokstr(tok, lit), []string{"SELECT"}, pos)
+	}
+
+	stmt, err := p.parseSelectStatement(targetSubQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != RPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+	}
+	return &SubQuery{Statement: stmt}, nil
+}
+
 // peekRune returns the next rune that would be read by the scanner.
 func (p *Parser) peekRune() rune {
 	r, _, _ := p.s.s.r.ReadRune()
@@ -1951,7 +2428,18 @@ func (p *Parser) peekRune() rune {
 	return r
 }
 
-func (p *Parser) parseSource() (Source, error) {
+func (p *Parser) parseSource(subqueries bool) (Source, error) {
+	// Peek instead of scanning so a regex can still be read from the reader.
+	if subqueries {
+		if isWhitespace(p.peekRune()) {
+			p.consumeWhitespace()
+		}
+		if p.peekRune() == '(' {
+			p.scan()
+			return p.parseSubQuery()
+		}
+	}
+
 	m := &Measurement{}
 
 	// Attempt to parse a regex.
@@ -2019,6 +2507,17 @@ func (p *Parser) parseCondition() (Expr, error) {
 	return expr, nil
 }
 
+// parseHaving parses the "HAVING" clause of the query, if it exists.
+func (p *Parser) parseHaving() (Expr, error) {
+	// Check if the HAVING token exists.
+	// HAVING is not a reserved keyword so it is matched against the identifier.
+	if tok, _, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToUpper(lit) != "HAVING" {
+		p.unscan()
+		return nil, nil
+	}
+	return p.ParseExpr()
+}
+
 // parseDimensions parses the "GROUP BY" clause of the query, if it exists.
 func (p *Parser) parseDimensions() (Dimensions, error) {
 	// If the next token is not GROUP then exit.
@@ -2066,8 +2565,46 @@ func (p *Parser) parseDimension() (*Dimension, error) {
 	return &Dimension{Expr: expr}, nil
 }
 
+// parseLocation parses the optional tz call and loads its time zone.
+func (p *Parser) parseLocation() (*time.Location, error) {
+	// Check if the tz call exists.
+	if tok, _, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToLower(lit) != "tz" {
+		p.unscan()
+		return nil, nil
+	}
+	p.unscan()
+
+	expr, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	call, ok := expr.(*Call)
+	if !ok || call.Name != "tz" {
+		return nil, fmt.Errorf("expected tz() call, got %s", expr)
+	} else if len(call.Args) != 1 {
+		return nil, fmt.Errorf("tz requires exactly one argument, got %d", len(call.Args))
+	}
+
+	name, ok := call.Args[0].(*StringLiteral)
+	if !ok {
+		return nil, errors.New("expected string argument in tz()")
+	}
+	loc, err := time.LoadLocation(name.Val)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find time zone %s", name.Val)
+	}
+	return loc, nil
+}
+
 // parseFill parses the fill call and its options.
 func (p *Parser) parseFill() (FillOption, interface{}, error) {
+	// Check if the fill call exists so a following clause is not consumed.
+	if tok, _, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToLower(lit) != "fill" {
+		p.unscan()
+		return NullFill, nil, nil
+	}
+	p.unscan()
+
 	// Parse the expression first.
 	expr, err := p.ParseExpr()
 	if err != nil {
@@ -2083,8 +2620,19 @@ func (p *Parser) parseFill() (FillOption, interface{}, error) {
 		p.unscan()
 		return NullFill, nil, nil
 	}
+	// fill(previous) can look back before the start of the range for a
+	// previous value, up to the given duration.
+	if len(lit.Args) == 2 && lit.Args[0].String() == "previous" {
+		lookback, ok := lit.Args[1].(*DurationLiteral)
+		if !ok {
+			return NullFill, nil, errors.New("expected duration argument as lookback in fill(previous)")
+		} else if lookback.Val <= 0 {
+			return NullFill, nil, fmt.Errorf("fill(previous) lookback must be positive, got %s", FormatDuration(lookback.Val))
+		}
+		return PreviousFill, lookback.Val, nil
+	}
 	if len(lit.Args) != 1 {
-		return NullFill, nil, errors.New("fill requires an argument, e.g.: 0, null, none, previous")
+		return NullFill, nil, errors.New("fill requires an argument, e.g.: 0, null, none, previous, linear")
 	}
 	switch lit.Args[0].String() {
 	case "null":
@@ -2093,6 +2641,8 @@ func (p *Parser) parseFill() (FillOption, interface{}, error) {
 		return NoFill, nil, nil
 	case "previous":
 		return PreviousFill, nil, nil
+	case "linear":
+		return LinearFill, nil, nil
 	default:
 		switch num := lit.Args[0].(type) {
 		case *IntegerLiteral:
@@ -2236,9 +2786,46 @@ func (p *Parser) parseVarRef() (*VarRef, error) {
 
 	vr := &VarRef{Val: strings.Join(segments, ".")}
 
+	// Parse the optional type qualifier: "::type".
+	if vr.Type, err = p.parseOptionalDataType(); err != nil {
+		return nil, err
+	}
+
 	return vr, nil
 }
 
+// parseOptionalDataType parses the "::type" qualifier of a reference or a
+// wildcard, if it immediately follows it.
+func (p *Parser) parseOptionalDataType() (DataType, error) {
+	if tok, _, _ := p.scan(); tok != COLON {
+		p.unscan()
+		return Unknown, nil
+	}
+	if tok, pos, lit := p.scan(); tok != COLON {
+		return Unknown, newParseError(tokstr(tok, lit), []string{":"}, pos)
+	}
+
+	tok, pos, lit := p.scan()
+	switch tok {
+	case TAG:
+		return Tag, nil
+	case FIELD:
+		return AnyField, nil
+	case IDENT:
+		switch strings.ToLower(lit) {
+		case "float":
+			return Float, nil
+		case "integer":
+			return Integer, nil
+		case "string":
+			return String, nil
+		case "boolean":
+			return Boolean, nil
+		}
+	}
+	return Unknown, newParseError(tokstr(tok, lit), []string{"tag", "field", "float", "integer", "string", "boolean"}, pos)
+}
+
 // ParseExpr parses an expression.
 func (p *Parser) ParseExpr() (Expr, error) {
 	var err error
@@ -2272,7 +2859,16 @@ func (p *Parser) ParseExpr() (Expr, error) {
 			// parseRegex can return an empty type, but we need it to be present
 			if rhs.(*RegexLiteral) == nil {
 				tok, pos, lit := p.scanIgnoreWhitespace()
-				return nil, newParseError(tokstr(tok, lit), []string{"regex"}, pos)
+				if tok != BOUNDPARAM {
+					return nil, newParseError(tokstr(tok, lit), []string{"regex"}, pos)
+				}
+
+				// A bound parameter must be bound to a regex.
+				if rhs, err = p.parseBoundParam(lit, pos); err != nil {
+					return nil, err
+				} else if _, ok := rhs.(*RegexLiteral); !ok {
+					return nil, newParseError(tokstr(tok, lit), []string{"regex"}, pos)
+				}
 			}
 		} else {
 			if rhs, err = p.parseUnaryExpr(); err != nil {
@@ -2296,6 +2892,78 @@ func (p *Parser) ParseExpr() (Expr, error) {
 	}
 }
 
+// parseStringLiteral returns a time literal if lit looks like a date or a
+// date time and a string literal otherwise.
+func parseStringLiteral(lit string, pos Pos) (Expr, error) {
+	if isDateTimeString(lit) {
+		t, err := time.Parse(DateTimeFormat, lit)
+		if err != nil {
+			// try to parse it as an RFCNano time
+			t, err := time.Parse(time.RFC3339Nano, lit)
+			if err != nil {
+				return nil, &ParseError{Message: "unable to parse datetime", Pos: pos}
+			}
+			return &TimeLiteral{Val: t}, nil
+		}
+		return &TimeLiteral{Val: t}, nil
+	} else if isDateString(lit) {
+		t, err := time.Parse(DateFormat, lit)
+		if err != nil {
+			return nil, &ParseError{Message: "unable to parse date", Pos: pos}
+		}
+		return &TimeLiteral{Val: t}, nil
+	}
+	return &StringLiteral{Val: lit}, nil
+}
+
+// parseBoundParam returns the literal bound to the parameter lit, including
+// its leading "$".
+func (p *Parser) parseBoundParam(lit string, pos Pos) (Expr, error) {
+	v, ok := p.params[lit[1:]]
+	if !ok {
+		return nil, &ParseError{Message: fmt.Sprintf("missing parameter: %s", lit[1:]), Pos: pos}
+	}
+
+	switch v := v.(type) {
+	case string:
+		return parseStringLiteral(v, pos)
+	case bool:
+		return &BooleanLiteral{Val: v}, nil
+	case float64:
+		return &NumberLiteral{Val: v}, nil
+	case int64:
+		return &IntegerLiteral{Val: v}, nil
+	case int:
+		return &IntegerLiteral{Val: int64(v)}, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return &IntegerLiteral{Val: i}, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, &ParseError{Message: fmt.Sprintf("unable to parse number for parameter %s", lit[1:]), Pos: pos}
+		}
+		return &NumberLiteral{Val: f}, nil
+	case map[string]interface{}:
+		if len(v) == 1 {
+			if d, ok := v["duration"].(string); ok {
+				dur, err := ParseDuration(d)
+				if err != nil {
+					return nil, &ParseError{Message: fmt.Sprintf("unable to parse duration for parameter %s", lit[1:]), Pos: pos}
+				}
+				return &DurationLiteral{Val: dur}, nil
+			} else if r, ok := v["regex"].(string); ok {
+				re, err := regexp.Compile(r)
+				if err != nil {
+					return nil, &ParseError{Message: err.Error(), Pos: pos}
+				}
+				return &RegexLiteral{Val: re}, nil
+			}
+		}
+	}
+	return nil, &ParseError{Message: fmt.Sprintf("unable to bind parameter %s with type %T", lit[1:], v), Pos: pos}
+}
+
 // parseUnaryExpr parses an non-binary expression.
 func (p *Parser) parseUnaryExpr() (Expr, error) {
 	// If the first token is a LPAREN then parse it as its own grouped expression.
@@ -2320,10 +2988,24 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 	case IDENT:
 		// If the next immediate token is a left parentheses, parse as function call.
 		// Otherwise parse as a variable reference.
-		if tok0, _, _ := p.scan(); tok0 == LPAREN {
+		tok0, _, _ := p.scan()
+		if tok0 == LPAREN {
 			return p.parseCall(lit)
 		}
 
+		// CASE is not a reserved keyword so a CASE expression is matched by
+		// the identifier followed by WHEN.  Otherwise the identifier, followed
+		// by whitespace, is a variable reference of a single segment.
+		if tok0 == WS && strings.ToUpper(lit) == "CASE" {
+			tok1, _, lit1 := p.scan()
+			p.unscan()
+			if tok1 == IDENT && strings.ToUpper(lit1) == "WHEN" {
+				return p.parseCaseExpr()
+			}
+			p.unscan() // unscan the whitespace
+			return &VarRef{Val: lit}, nil
+		}
+
 		p.unscan() // unscan the last token (wasn't an LPAREN)
 		p.unscan() // unscan the IDENT token
 
@@ -2345,26 +3027,9 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 
 		return nil, newParseError(tokstr(tok0, lit), []string{"(", "identifier"}, pos)
 	case STRING:
-		// If literal looks like a date time then parse it as a time literal.
-		if isDateTimeString(lit) {
-			t, err := time.Parse(DateTimeFormat, lit)
-			if err != nil {
-				// try to parse it as an RFCNano time
-				t, err := time.Parse(time.RFC3339Nano, lit)
-				if err != nil {
-					return nil, &ParseError{Message: "unable to parse datetime", Pos: pos}
-				}
-				return &TimeLiteral{Val: t}, nil
-			}
-			return &TimeLiteral{Val: t}, nil
-		} else if isDateString(lit) {
-			t, err := time.Parse(DateFormat, lit)
-			if err != nil {
-				return nil, &ParseError{Message: "unable to parse date", Pos: pos}
-			}
-			return &TimeLiteral{Val: t}, nil
-		}
-		return &StringLiteral{Val: lit}, nil
+		return parseStringLiteral(lit, pos)
+	case BOUNDPARAM:
+		return p.parseBoundParam(lit, pos)
 	case NUMBER:
 		v, err := strconv.ParseFloat(lit, 64)
 		if err != nil {
@@ -2383,7 +3048,11 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 		v, _ := ParseDuration(lit)
 		return &DurationLiteral{Val: v}, nil
 	case MUL:
-		return &Wildcard{}, nil
+		typ, err := p.parseOptionalDataType()
+		if err != nil {
+			return nil, err
+		}
+		return &Wildcard{Type: typ}, nil
 	case REGEX:
 		re, err := regexp.Compile(lit)
 		if err != nil {
@@ -2395,6 +3064,56 @@ func (p *Parser) parseUnaryExpr() (Expr, error) {
 	}
 }
 
+// parseCaseExpr parses a CASE expression.
+// This function assumes the CASE token has already been consumed.
+//
+// CASE, WHEN, THEN and ELSE are not reserved keywords so they are matched
+// against the identifier.
+func (p *Parser) parseCaseExpr() (*CaseExpr, error) {
+	expr := &CaseExpr{}
+
+	// Parse the WHEN clauses.  There must be at least one.
+	for {
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok != IDENT || strings.ToUpper(lit) != "WHEN" {
+			if len(expr.WhenClauses) == 0 {
+				return nil, newParseError(tokstr(tok, lit), []string{"WHEN"}, pos)
+			}
+			p.unscan()
+			break
+		}
+
+		cond, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok != IDENT || strings.ToUpper(lit) != "THEN" {
+			return nil, newParseError(tokstr(tok, lit), []string{"THEN"}, pos)
+		}
+		result, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		expr.WhenClauses = append(expr.WhenClauses, &WhenClause{Condition: cond, Result: result})
+	}
+
+	// Parse the optional ELSE result.
+	if tok, _, lit := p.scanIgnoreWhitespace(); tok == IDENT && strings.ToUpper(lit) == "ELSE" {
+		result, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		expr.Else = result
+	} else {
+		p.unscan()
+	}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != END {
+		return nil, newParseError(tokstr(tok, lit), []string{"WHEN", "ELSE", "END"}, pos)
+	}
+	return expr, nil
+}
+
 // parseRegex parses a regular expression.
 func (p *Parser) parseRegex() (*RegexLiteral, error) {
 	nextRune := p.peekRune()
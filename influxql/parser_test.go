@@ -181,6 +181,18 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// derivative with a gap policy
+		{
+			s: `SELECT derivative(field1, 1h, 'skip') FROM myseries`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: false,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.Call{Name: "derivative", Args: []influxql.Expr{&influxql.VarRef{Val: "field1"}, &influxql.DurationLiteral{Val: time.Hour}, &influxql.StringLiteral{Val: "skip"}}}},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "myseries"}},
+			},
+		},
+
 		{
 			s: fmt.Sprintf(`SELECT derivative(field1, 1h) FROM myseries WHERE time > '%s'`, now.UTC().Format(time.RFC3339Nano)),
 			stmt: &influxql.SelectStatement{
@@ -637,6 +649,117 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// SELECT ... FROM (SELECT ...)
+		{
+			s: `SELECT max(mean) FROM (SELECT mean(value) FROM cpu GROUP BY time(1m), host) WHERE time > now() - 1d GROUP BY time(1h)`,
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{Expr: &influxql.Call{Name: "max", Args: []influxql.Expr{&influxql.VarRef{Val: "mean"}}}}},
+				Sources: []influxql.Source{&influxql.SubQuery{
+					Statement: &influxql.SelectStatement{
+						Fields:  []*influxql.Field{{Expr: &influxql.Call{Name: "mean", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+						Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+						Dimensions: []*influxql.Dimension{
+							{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{&influxql.DurationLiteral{Val: time.Minute}}}},
+							{Expr: &influxql.VarRef{Val: "host"}},
+						},
+					},
+				}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.GT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.BinaryExpr{
+						Op:  influxql.SUB,
+						LHS: &influxql.Call{Name: "now"},
+						RHS: &influxql.DurationLiteral{Val: 24 * time.Hour},
+					},
+				},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{&influxql.DurationLiteral{Val: time.Hour}}}}},
+			},
+		},
+
+		// SELECT ... FROM ... JOIN ...
+		{
+			s: `SELECT count(errors.value) / count(requests.value) FROM errors JOIN db0.rp0.requests WHERE time > now() - 1h GROUP BY time(1m), host`,
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{Expr: &influxql.BinaryExpr{
+					Op:  influxql.DIV,
+					LHS: &influxql.Call{Name: "count", Args: []influxql.Expr{&influxql.VarRef{Val: "errors.value"}}},
+					RHS: &influxql.Call{Name: "count", Args: []influxql.Expr{&influxql.VarRef{Val: "requests.value"}}},
+				}}},
+				Sources: []influxql.Source{&influxql.Join{Measurements: influxql.Measurements{
+					{Name: "errors"},
+					{Database: "db0", RetentionPolicy: "rp0", Name: "requests"},
+				}}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.GT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.BinaryExpr{
+						Op:  influxql.SUB,
+						LHS: &influxql.Call{Name: "now"},
+						RHS: &influxql.DurationLiteral{Val: time.Hour},
+					},
+				},
+				Dimensions: []*influxql.Dimension{
+					{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{&influxql.DurationLiteral{Val: time.Minute}}}},
+					{Expr: &influxql.VarRef{Val: "host"}},
+				},
+			},
+		},
+
+		// SELECT ... FROM ... JOIN ... with JOIN used as an identifier
+		{
+			s: `SELECT join FROM join JOIN mem`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: true,
+				Fields:     []*influxql.Field{{Expr: &influxql.VarRef{Val: "join"}}},
+				Sources: []influxql.Source{&influxql.Join{Measurements: influxql.Measurements{
+					{Name: "join"},
+					{Name: "mem"},
+				}}},
+			},
+		},
+
+		// SELECT statement with unreserved keywords used as identifiers
+		{
+			s: `SELECT case, else FROM compact WHERE having = 'a'`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: true,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.VarRef{Val: "case"}},
+					{Expr: &influxql.VarRef{Val: "else"}},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "compact"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.EQ,
+					LHS: &influxql.VarRef{Val: "having"},
+					RHS: &influxql.StringLiteral{Val: "a"},
+				},
+			},
+		},
+
+		// SELECT CASE ... END AS alias
+		{
+			s: `SELECT CASE WHEN value > 5 THEN 'high' ELSE 'low' END AS level FROM cpu`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: true,
+				Fields: []*influxql.Field{{
+					Expr: &influxql.CaseExpr{
+						WhenClauses: []*influxql.WhenClause{{
+							Condition: &influxql.BinaryExpr{
+								Op:  influxql.GT,
+								LHS: &influxql.VarRef{Val: "value"},
+								RHS: &influxql.IntegerLiteral{Val: 5},
+							},
+							Result: &influxql.StringLiteral{Val: "high"},
+						}},
+						Else: &influxql.StringLiteral{Val: "low"},
+					},
+					Alias: "level",
+				}},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+			},
+		},
+
 		// SELECT * FROM "db"."rp"./<regex>/
 		{
 			s: `SELECT * FROM "db"."rp"./cpu.*/`,
@@ -773,6 +896,95 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// SELECT statement with linear fill
+		{
+			s: fmt.Sprintf(`SELECT mean(value) FROM cpu where time < '%s' GROUP BY time(5m) FILL(linear)`, now.UTC().Format(time.RFC3339Nano)),
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{
+					Expr: &influxql.Call{
+						Name: "mean",
+						Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.LT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.TimeLiteral{Val: now.UTC()},
+				},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{&influxql.DurationLiteral{Val: 5 * time.Minute}}}}},
+				Fill:       influxql.LinearFill,
+			},
+		},
+
+		// SELECT statement with previous fill looking back before the range
+		{
+			s: fmt.Sprintf(`SELECT mean(value) FROM cpu where time < '%s' GROUP BY time(5m) fill(previous, 1h)`, now.UTC().Format(time.RFC3339Nano)),
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{
+					Expr: &influxql.Call{
+						Name: "mean",
+						Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.LT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.TimeLiteral{Val: now.UTC()},
+				},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{&influxql.DurationLiteral{Val: 5 * time.Minute}}}}},
+				Fill:       influxql.PreviousFill,
+				FillValue:  time.Hour,
+			},
+		},
+
+		// SELECT statement with a time zone
+		{
+			s: `SELECT mean(value) FROM cpu WHERE time >= now() - 7d GROUP BY time(1d) tz('America/New_York')`,
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{{
+					Expr: &influxql.Call{
+						Name: "mean",
+						Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}}},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.GTE,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.BinaryExpr{
+						Op:  influxql.SUB,
+						LHS: &influxql.Call{Name: "now"},
+						RHS: &influxql.DurationLiteral{Val: 7 * 24 * time.Hour},
+					},
+				},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.Call{Name: "time", Args: []influxql.Expr{&influxql.DurationLiteral{Val: 24 * time.Hour}}}}},
+				Location:   mustLoadLocation("America/New_York"),
+			},
+		},
+
+		// SELECT statement with a HAVING clause
+		{
+			s: `SELECT mean(value) AS m, max(value) FROM cpu GROUP BY host fill(none) HAVING mean(value) > 80 AND max > 90`,
+			stmt: &influxql.SelectStatement{
+				Fields: []*influxql.Field{
+					{Expr: &influxql.Call{Name: "mean", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}, Alias: "m"},
+					{Expr: &influxql.Call{Name: "max", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}}},
+				},
+				Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Dimensions: []*influxql.Dimension{{Expr: &influxql.VarRef{Val: "host"}}},
+				Fill:       influxql.NoFill,
+				Having: &influxql.BinaryExpr{
+					Op: influxql.AND,
+					LHS: &influxql.BinaryExpr{
+						Op:  influxql.GT,
+						LHS: &influxql.Call{Name: "mean", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}},
+						RHS: &influxql.IntegerLiteral{Val: 80},
+					},
+					RHS: &influxql.BinaryExpr{
+						Op:  influxql.GT,
+						LHS: &influxql.VarRef{Val: "max"},
+						RHS: &influxql.IntegerLiteral{Val: 90},
+					},
+				},
+			},
+		},
+
 		// See issues https://github.com/influxdata/influxdb/issues/1647
 		// and https://github.com/influxdata/influxdb/issues/4404
 		// DELETE statement
@@ -806,6 +1018,63 @@ func TestParser_ParseStatement(t *testing.T) {
 			stmt: &influxql.ShowSeriesStatement{},
 		},
 
+		// SHOW SERIES CARDINALITY
+		{
+			s:    `SHOW SERIES CARDINALITY`,
+			stmt: &influxql.ShowSeriesCardinalityStatement{},
+		},
+
+		// SHOW SERIES EXACT CARDINALITY FROM ... WHERE
+		{
+			s: `SHOW SERIES EXACT CARDINALITY FROM cpu WHERE region = 'uswest'`,
+			stmt: &influxql.ShowSeriesCardinalityStatement{
+				Exact:   true,
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.EQ,
+					LHS: &influxql.VarRef{Val: "region"},
+					RHS: &influxql.StringLiteral{Val: "uswest"},
+				},
+			},
+		},
+
+		// SHOW MEASUREMENT CARDINALITY
+		{
+			s:    `SHOW MEASUREMENT CARDINALITY`,
+			stmt: &influxql.ShowMeasurementCardinalityStatement{},
+		},
+
+		// SHOW MEASUREMENT EXACT CARDINALITY FROM /<regex>/
+		{
+			s: `SHOW MEASUREMENT EXACT CARDINALITY FROM /[cg]pu/`,
+			stmt: &influxql.ShowMeasurementCardinalityStatement{
+				Exact: true,
+				Sources: []influxql.Source{
+					&influxql.Measurement{
+						Regex: &influxql.RegexLiteral{Val: regexp.MustCompile(`[cg]pu`)},
+					},
+				},
+			},
+		},
+
+		// SHOW TAG VALUES CARDINALITY WITH KEY = ...
+		{
+			s: `SHOW TAG VALUES CARDINALITY WITH KEY = host`,
+			stmt: &influxql.ShowTagValuesCardinalityStatement{
+				TagKeys: []string{"host"},
+			},
+		},
+
+		// SHOW TAG VALUES EXACT CARDINALITY FROM ... WITH KEY IN ...
+		{
+			s: `SHOW TAG VALUES EXACT CARDINALITY FROM cpu WITH KEY IN (region, host)`,
+			stmt: &influxql.ShowTagValuesCardinalityStatement{
+				Exact:   true,
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				TagKeys: []string{"region", "host"},
+			},
+		},
+
 		// SHOW SERIES FROM
 		{
 			s: `SHOW SERIES FROM cpu`,
@@ -908,6 +1177,23 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// EXPLAIN SELECT
+		{
+			s: `EXPLAIN SELECT count(value) FROM cpu`,
+			stmt: &influxql.ExplainStatement{
+				Statement: MustParseSelectStatement(`SELECT count(value) FROM cpu`),
+			},
+		},
+
+		// EXPLAIN ANALYZE SELECT
+		{
+			s: `EXPLAIN ANALYZE SELECT value FROM cpu WHERE host = 'serverA'`,
+			stmt: &influxql.ExplainStatement{
+				Statement: MustParseSelectStatement(`SELECT value FROM cpu WHERE host = 'serverA'`),
+				Analyze:   true,
+			},
+		},
+
 		// SHOW RETENTION POLICIES
 		{
 			s: `SHOW RETENTION POLICIES ON mydb`,
@@ -1112,6 +1398,30 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// SELECT with type qualifiers
+		{
+			s: `SELECT host::tag, value::field, "load"::float FROM cpu`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: true,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.VarRef{Val: "host", Type: influxql.Tag}},
+					{Expr: &influxql.VarRef{Val: "value", Type: influxql.AnyField}},
+					{Expr: &influxql.VarRef{Val: "load", Type: influxql.Float}},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+			},
+		},
+		{
+			s: `SELECT *::integer FROM cpu`,
+			stmt: &influxql.SelectStatement{
+				IsRawQuery: true,
+				Fields: []*influxql.Field{
+					{Expr: &influxql.Wildcard{Type: influxql.Integer}},
+				},
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+			},
+		},
+
 		// SHOW USERS
 		{
 			s:    `SHOW USERS`,
@@ -1132,6 +1442,17 @@ func TestParser_ParseStatement(t *testing.T) {
 				Limit: 10,
 			},
 		},
+		{
+			s: `SHOW FIELD KEYS FROM cpu WHERE time > '2000-01-01T00:00:00Z'`,
+			stmt: &influxql.ShowFieldKeysStatement{
+				Sources: []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+				Condition: &influxql.BinaryExpr{
+					Op:  influxql.GT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.TimeLiteral{Val: mustParseTime("2000-01-01T00:00:00Z")},
+				},
+			},
+		},
 		{
 			s: `SHOW FIELD KEYS FROM /[cg]pu/`,
 			stmt: &influxql.ShowFieldKeysStatement{
@@ -1548,6 +1869,16 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		// SET NOW
+		{
+			s:    `SET NOW = '2000-01-01T00:00:00Z'`,
+			stmt: &influxql.SetNowStatement{Now: mustParseTime("2000-01-01T00:00:00Z")},
+		},
+		{
+			s:    `set now = 946684800000000000`,
+			stmt: &influxql.SetNowStatement{Now: mustParseTime("2000-01-01T00:00:00Z")},
+		},
+
 		// DROP CONTINUOUS QUERY statement
 		{
 			s:    `DROP CONTINUOUS QUERY myquery ON foo`,
@@ -1793,6 +2124,57 @@ func TestParser_ParseStatement(t *testing.T) {
 			s:    `ALTER RETENTION POLICY policy1 ON testdb REPLICATION 4 SHARD DURATION 10m`,
 			stmt: newAlterRetentionPolicyStatement("policy1", "testdb", -1, 10*time.Minute, 4, false),
 		},
+		// ALTER RETENTION POLICY with a measurement duration
+		{
+			s:    `ALTER RETENTION POLICY policy1 ON testdb MEASUREMENT cpu DURATION 1d`,
+			stmt: newAlterMeasurementDurationStatement("policy1", "testdb", "cpu", 24*time.Hour),
+		},
+		{
+			s:    `ALTER RETENTION POLICY policy1 ON testdb MEASUREMENT "cpu load" DURATION INF`,
+			stmt: newAlterMeasurementDurationStatement("policy1", "testdb", "cpu load", 0),
+		},
+
+		// ALTER SHARD TIER
+		{
+			s:    `ALTER SHARD 1 TIER COLD`,
+			stmt: &influxql.AlterShardTierStatement{ID: 1, Cold: true},
+		},
+		{
+			s:    `alter shard 2 tier hot`,
+			stmt: &influxql.AlterShardTierStatement{ID: 2},
+		},
+
+		// CREATE SCHEMA
+		{
+			s: `CREATE SCHEMA cpu ON db0 (host TAG, region TAG, value FLOAT, n integer) STRICT`,
+			stmt: &influxql.CreateSchemaStatement{
+				Name:     "cpu",
+				Database: "db0",
+				TagKeys:  []string{"host", "region"},
+				Fields:   []influxql.SchemaField{{Name: "value", Type: influxql.Float}, {Name: "n", Type: influxql.Integer}},
+				Strict:   true,
+			},
+		},
+		{
+			s: `create schema "disk io" on db0 (path string, "read" boolean)`,
+			stmt: &influxql.CreateSchemaStatement{
+				Name:     "disk io",
+				Database: "db0",
+				Fields:   []influxql.SchemaField{{Name: "path", Type: influxql.String}, {Name: "read", Type: influxql.Boolean}},
+			},
+		},
+
+		// DROP SCHEMA
+		{
+			s:    `DROP SCHEMA cpu ON db0`,
+			stmt: &influxql.DropSchemaStatement{Name: "cpu", Database: "db0"},
+		},
+
+		// SHOW SCHEMAS
+		{
+			s:    `SHOW SCHEMAS`,
+			stmt: &influxql.ShowSchemasStatement{},
+		},
 
 		// SHOW STATS
 		{
@@ -1860,12 +2242,66 @@ func TestParser_ParseStatement(t *testing.T) {
 			stmt: &influxql.ShowSubscriptionsStatement{},
 		},
 
+		// DROP SHARD
+		{
+			s:    `DROP SHARD 1`,
+			stmt: &influxql.DropShardStatement{ID: 1},
+		},
+
+		// COMPACT SHARD
+		{
+			s:    `COMPACT SHARD 1 FULL`,
+			stmt: &influxql.CompactShardStatement{ID: 1},
+		},
+
+		// COMPACT PAUSE / RESUME
+		{
+			s:    `COMPACT PAUSE`,
+			stmt: &influxql.PauseCompactionsStatement{},
+		},
+		{
+			s:    `compact resume`,
+			stmt: &influxql.PauseCompactionsStatement{Resume: true},
+		},
+		{
+			s:    `COMPACT SHARD 2 PAUSE`,
+			stmt: &influxql.PauseCompactionsStatement{ShardID: 2},
+		},
+		{
+			s:    `COMPACT SHARD 2 RESUME`,
+			stmt: &influxql.PauseCompactionsStatement{ShardID: 2, Resume: true},
+		},
+
 		// Errors
-		{s: ``, err: `found EOF, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET, KILL at line 1, char 1`},
+		{s: ``, err: `found EOF, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET, KILL, COMPACT, EXPLAIN at line 1, char 1`},
 		{s: `SELECT`, err: `found EOF, expected identifier, string, number, bool at line 1, char 8`},
 		{s: `SELECT time FROM myseries`, err: `at least 1 non-time field must be queried`},
-		{s: `blah blah`, err: `found blah, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET, KILL at line 1, char 1`},
+		{s: `blah blah`, err: `found blah, expected SELECT, DELETE, SHOW, CREATE, DROP, GRANT, REVOKE, ALTER, SET, KILL, COMPACT, EXPLAIN at line 1, char 1`},
 		{s: `SELECT field1 X`, err: `found X, expected FROM at line 1, char 15`},
+		{s: `SELECT value FROM (SELECT value FROM cpu`, err: `found EOF, expected ) at line 1, char 42`},
+		{s: `SELECT max(mean) FROM (SELECT mean(value) FROM cpu GROUP BY time(1m))`, err: `aggregate functions with GROUP BY time require a WHERE time clause`},
+		{s: `SELECT value FROM (SHOW MEASUREMENTS)`, err: `found SHOW, expected SELECT at line 1, char 20`},
+		{s: `SELECT value FROM (SELECT value INTO cpu2 FROM cpu)`, err: `a subquery cannot have an INTO clause`},
+		{s: `SELECT value FROM (SELECT value FROM cpu), mem`, err: `a subquery cannot be combined with other sources`},
+		{s: `SELECT value FROM cpu JOIN mem, disk`, err: `a join cannot be combined with other sources`},
+		{s: `SELECT value FROM cpu JOIN /m.*/`, err: `a regex measurement cannot be joined`},
+		{s: `SELECT value FROM cpu JOIN cpu`, err: `measurement joined more than once: cpu`},
+		{s: `SELECT value FROM (SELECT value FROM cpu) JOIN mem`, err: `a subquery cannot be joined at line 1, char 43`},
+		{s: `SELECT value FROM cpu JOIN`, err: `found EOF, expected identifier at line 1, char 28`},
+		{s: `SELECT value FROM cpu HAVING value > 1`, err: `HAVING clause requires an aggregate function`},
+		{s: `SELECT mean(value) FROM cpu HAVING max(value) > 1`, err: `max(value) in HAVING clause must also be selected`},
+		{s: `SELECT mean(value) FROM cpu HAVING value > 1`, err: `unknown column in HAVING clause: value`},
+		{s: `SELECT mean(value) FROM cpu HAVING`, err: `found EOF, expected identifier, string, number, bool at line 1, char 36`},
+		{s: `SHOW SERIES FROM (SELECT value FROM cpu)`, err: `found (, expected identifier at line 1, char 18`},
+		{s: `DROP SHARD`, err: `found EOF, expected integer at line 1, char 12`},
+		{s: `DROP SHARD cpu`, err: `found cpu, expected integer at line 1, char 12`},
+		{s: `COMPACT SHARD`, err: `found EOF, expected integer at line 1, char 15`},
+		{s: `COMPACT SHARD 1`, err: `found EOF, expected FULL, PAUSE, RESUME at line 1, char 16`},
+		{s: `COMPACT SHARD 1 LEVEL`, err: `found LEVEL, expected FULL, PAUSE, RESUME at line 1, char 17`},
+		{s: `COMPACT`, err: `found EOF, expected SHARD, PAUSE, RESUME at line 1, char 9`},
+		{s: `COMPACT FULL`, err: `found FULL, expected SHARD, PAUSE, RESUME at line 1, char 9`},
+		{s: `EXPLAIN`, err: `found EOF, expected SELECT at line 1, char 9`},
+		{s: `EXPLAIN ANALYZE SHOW DATABASES`, err: `found SHOW, expected SELECT at line 1, char 17`},
 		{s: `SELECT field1 FROM "series" WHERE X +;`, err: `found ;, expected identifier, string, number, bool at line 1, char 38`},
 		{s: `SELECT field1 FROM myseries GROUP`, err: `found EOF, expected BY at line 1, char 35`},
 		{s: `SELECT field1 FROM myseries LIMIT`, err: `found EOF, expected integer at line 1, char 35`},
@@ -1903,6 +2339,53 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SELECT percentile(field1) FROM myseries`, err: `invalid number of arguments for percentile, expected 2, got 1`},
 		{s: `SELECT percentile(field1, foo) FROM myseries`, err: `expected float argument in percentile()`},
 		{s: `SELECT percentile(max(field1), 75) FROM myseries`, err: `expected field argument in percentile()`},
+		{s: `SELECT rate() FROM myseries`, err: `invalid number of arguments for rate, expected at least 1 but no more than 2, got 0`},
+		{s: `SELECT rate(max(value)) FROM myseries`, err: `expected field argument in rate()`},
+		{s: `SELECT rate(value, 10) FROM myseries`, err: `rate requires a duration argument`},
+		{s: `SELECT rate(value, 0s) FROM myseries`, err: `rate duration must be positive, got 0s`},
+		{s: `SELECT abs() FROM myseries`, err: `invalid number of arguments for abs, expected 1, got 0`},
+		{s: `SELECT sqrt(value, 2) FROM myseries`, err: `invalid number of arguments for sqrt, expected 1, got 2`},
+		{s: `SELECT pow(value) FROM myseries`, err: `invalid number of arguments for pow, expected 2, got 1`},
+		{s: `SELECT log(value, 'a') FROM myseries`, err: `expected number argument as second arg in log`},
+		{s: `SELECT floor(1.5) FROM myseries`, err: `expected field argument in floor()`},
+		{s: `SELECT abs(top(value, 1)) FROM myseries`, err: `cannot use top() inside of abs()`},
+		{s: `SELECT abs(value) FROM myseries WHERE time > now() - 1m GROUP BY time(1m)`, err: `GROUP BY requires at least one aggregate function`},
+		{s: `SELECT abs(value) + mean(value) FROM myseries`, err: `binary expressions cannot mix aggregates and raw fields`},
+		{s: `SELECT concat(host) FROM myseries`, err: `invalid number of arguments for concat, expected at least 2, got 1`},
+		{s: `SELECT lower(host, region) FROM myseries`, err: `invalid number of arguments for lower, expected 1, got 2`},
+		{s: `SELECT substr(host) FROM myseries`, err: `invalid number of arguments for substr, expected at least 2 but no more than 3, got 1`},
+		{s: `SELECT substr(host, 0) FROM myseries`, err: `expected positive integer argument as second arg in substr`},
+		{s: `SELECT substr(host, 1, -1) FROM myseries`, err: `expected non-negative integer argument as third arg in substr`},
+		{s: `SELECT upper('a') FROM myseries`, err: `expected field argument in upper()`},
+		{s: `SELECT concat(host, 1) FROM myseries`, err: `expected field argument in concat()`},
+		{s: `SELECT length(top(value, 1)) FROM myseries`, err: `cannot use top() inside of length()`},
+		{s: `SELECT concat(host, last(value)) FROM myseries`, err: `string functions cannot mix aggregates and raw fields`},
+		{s: `SELECT mean(value) FROM myseries WHERE time > now() - 1h GROUP BY time(1m) fill(previous, 10)`, err: `expected duration argument as lookback in fill(previous)`},
+		{s: `SELECT mean(value) FROM myseries WHERE time > now() - 1h GROUP BY time(1m) fill(previous, 0s)`, err: `fill(previous) lookback must be positive, got 0s`},
+		{s: `SELECT mean(value) FROM myseries WHERE time > now() - 1h GROUP BY time(1m) fill(none, 1h)`, err: `fill requires an argument, e.g.: 0, null, none, previous, linear`},
+		{s: `SELECT value FROM myseries tz()`, err: `tz requires exactly one argument, got 0`},
+		{s: `SELECT value FROM myseries tz(1)`, err: `expected string argument in tz()`},
+		{s: `SELECT value FROM myseries tz('Nowhere/Zone')`, err: `unable to find time zone Nowhere/Zone`},
+		{s: `SELECT histogram(value, 10) FROM myseries`, err: `invalid number of arguments for histogram, expected at least 3, got 2`},
+		{s: `SELECT histogram(max(value), 0, 10) FROM myseries`, err: `expected field argument in histogram()`},
+		{s: `SELECT histogram(value, 0, 'a') FROM myseries`, err: `expected number argument as bucket boundary in histogram(), got 'a'`},
+		{s: `SELECT histogram(value, 0, 10, 10) FROM myseries`, err: `histogram() bucket boundaries must be in ascending order`},
+		{s: `SELECT histogram(value, 0, 10) * 2 FROM myseries`, err: `histogram() cannot be used in an expression or another function`},
+		{s: `SELECT derivative(histogram(value, 0, 10)) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `histogram() cannot be used in an expression or another function`},
+		{s: `SELECT interpolated_percentile(field1) FROM myseries`, err: `invalid number of arguments for interpolated_percentile, expected 2, got 1`},
+		{s: `SELECT interpolated_percentile(field1, foo) FROM myseries`, err: `expected float argument in interpolated_percentile()`},
+		{s: `SELECT interpolated_percentile(max(field1), 75) FROM myseries`, err: `expected field argument in interpolated_percentile()`},
+		{s: `SELECT mode() FROM myseries`, err: `invalid number of arguments for mode, expected 1, got 0`},
+		{s: `SELECT mode(value, 2) FROM myseries`, err: `invalid number of arguments for mode, expected 1, got 2`},
+		{s: `SELECT integral() FROM myseries`, err: `invalid number of arguments for integral, expected at least 1 but no more than 2, got 0`},
+		{s: `SELECT integral(mean(value)) FROM myseries`, err: `expected field argument in integral()`},
+		{s: `SELECT integral(value, 10) FROM myseries`, err: `integral requires a duration argument`},
+		{s: `SELECT integral(value, 0s) FROM myseries`, err: `integral duration must be positive, got 0s`},
+		{s: `SELECT sample(value) FROM myseries`, err: `invalid number of arguments for sample, expected 2, got 1`},
+		{s: `SELECT sample(max(value), 2) FROM myseries`, err: `expected field argument in sample()`},
+		{s: `SELECT sample(value, 2.5) FROM myseries`, err: `expected integer argument in sample()`},
+		{s: `SELECT sample(value, 0) FROM myseries`, err: `sample window must be greater than 0, got 0`},
+		{s: `SELECT sample(value, 2), max(value), host FROM myseries`, err: `mixing multiple selector functions with tags or fields is not supported`},
 		{s: `SELECT field1 FROM myseries OFFSET`, err: `found EOF, expected integer at line 1, char 36`},
 		{s: `SELECT field1 FROM myseries OFFSET 10.5`, err: `found 10.5, expected integer at line 1, char 36`},
 		{s: `SELECT field1 FROM myseries ORDER`, err: `found EOF, expected BY at line 1, char 35`},
@@ -1919,6 +2402,9 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SELECT count(value) FROM foo group by time(1s) where host = 'hosta.influxdb.org'`, err: `aggregate functions with GROUP BY time require a WHERE time clause`},
 		{s: `SELECT count(value) FROM foo group by time`, err: `time() is a function and expects at least one argument`},
 		{s: `SELECT count(value) FROM foo group by 'time'`, err: `only time and tag dimensions allowed`},
+		{s: `SELECT count(value) FROM foo group by host = 'a'`, err: `invalid operator = in tag dimension, expected =~ or !~`},
+		{s: `SELECT count(value) FROM foo group by host =~ 'a'`, err: `found a, expected regex at line 1, char 46`},
+		{s: `SELECT count(value) FROM foo group by time =~ /a/`, err: `only time and tag dimensions allowed`},
 		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time()`, err: `time dimension expected 1 or 2 arguments`},
 		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time(b)`, err: `time dimension must have duration argument`},
 		{s: `SELECT count(value) FROM foo where time > now() and time < now() group by time(1s), time(2s)`, err: `multiple time dimensions not allowed`},
@@ -1937,17 +2423,23 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `select count(distinct(too, many, arguments)) from myseries`, err: `count(distinct <field>) can only have one argument`},
 		{s: `select count() from myseries`, err: `invalid number of arguments for count, expected 1, got 0`},
 		{s: `SELECT derivative(), field1 FROM myseries`, err: `mixing aggregate and non-aggregate queries is not supported`},
-		{s: `select derivative() from myseries`, err: `invalid number of arguments for derivative, expected at least 1 but no more than 2, got 0`},
-		{s: `select derivative(mean(value), 1h, 3) from myseries`, err: `invalid number of arguments for derivative, expected at least 1 but no more than 2, got 3`},
+		{s: `select derivative() from myseries`, err: `invalid number of arguments for derivative, expected at least 1 but no more than 3, got 0`},
+		{s: `select derivative(mean(value), 1h, 3) from myseries`, err: `third argument for derivative must be a string, got *influxql.IntegerLiteral`},
+		{s: `select derivative(mean(value), 1h, 'skip', 3) from myseries`, err: `invalid number of arguments for derivative, expected at least 1 but no more than 3, got 4`},
+		{s: `select derivative(mean(value), 'skip') from myseries`, err: `second argument for derivative must be a duration, got *influxql.StringLiteral`},
+		{s: `select derivative(mean(value), 1h, 'zero') from myseries`, err: `invalid gap policy for derivative: zero, expected extrapolate, null or skip`},
 		{s: `SELECT derivative(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to derivative`},
+		{s: `SELECT moving_average(derivative(value), 2) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `aggregate function required inside the call to derivative`},
+		{s: `SELECT moving_average(derivative(mean(value), 1h, 2), 2) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `third argument for derivative must be a string, got *influxql.IntegerLiteral`},
+		{s: `SELECT cumulative_sum(moving_average(max(value), 1)) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `moving_average window must be greater than 1, got 1`},
 		{s: `SELECT derivative(top(value)) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for top, expected at least 2, got 1`},
 		{s: `SELECT derivative(bottom(value)) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for bottom, expected at least 2, got 1`},
 		{s: `SELECT derivative(max()) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for max, expected 1, got 0`},
 		{s: `SELECT derivative(percentile(value)) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for percentile, expected 2, got 1`},
 		{s: `SELECT derivative(mean(value), 1h) FROM myseries where time < now() and time > now() - 1d`, err: `derivative aggregate requires a GROUP BY interval`},
 		{s: `SELECT non_negative_derivative(), field1 FROM myseries`, err: `mixing aggregate and non-aggregate queries is not supported`},
-		{s: `select non_negative_derivative() from myseries`, err: `invalid number of arguments for non_negative_derivative, expected at least 1 but no more than 2, got 0`},
-		{s: `select non_negative_derivative(mean(value), 1h, 3) from myseries`, err: `invalid number of arguments for non_negative_derivative, expected at least 1 but no more than 2, got 3`},
+		{s: `select non_negative_derivative() from myseries`, err: `invalid number of arguments for non_negative_derivative, expected at least 1 but no more than 3, got 0`},
+		{s: `select non_negative_derivative(mean(value), 1h, 3) from myseries`, err: `third argument for non_negative_derivative must be a string, got *influxql.IntegerLiteral`},
 		{s: `SELECT non_negative_derivative(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to non_negative_derivative`},
 		{s: `SELECT non_negative_derivative(top(value)) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for top, expected at least 2, got 1`},
 		{s: `SELECT non_negative_derivative(bottom(value)) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for bottom, expected at least 2, got 1`},
@@ -1962,6 +2454,17 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SELECT difference(max()) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for max, expected 1, got 0`},
 		{s: `SELECT difference(percentile(value)) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for percentile, expected 2, got 1`},
 		{s: `SELECT difference(mean(value)) FROM myseries where time < now() and time > now() - 1d`, err: `difference aggregate requires a GROUP BY interval`},
+		{s: `SELECT elapsed() FROM myseries`, err: `invalid number of arguments for elapsed, expected at least 1 but no more than 2, got 0`},
+		{s: `SELECT elapsed(value, 1s, 1m) FROM myseries`, err: `invalid number of arguments for elapsed, expected at least 1 but no more than 2, got 3`},
+		{s: `SELECT elapsed(value, 10) FROM myseries`, err: `elapsed requires a duration argument`},
+		{s: `SELECT elapsed(value, 0s) FROM myseries`, err: `elapsed duration must be positive, got 0s`},
+		{s: `SELECT elapsed(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to elapsed`},
+		{s: `SELECT non_negative_difference() from myseries`, err: `invalid number of arguments for non_negative_difference, expected 1, got 0`},
+		{s: `SELECT non_negative_difference(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to non_negative_difference`},
+		{s: `SELECT non_negative_difference(mean(value)) FROM myseries where time < now() and time > now() - 1d`, err: `non_negative_difference aggregate requires a GROUP BY interval`},
+		{s: `SELECT cumulative_sum() from myseries`, err: `invalid number of arguments for cumulative_sum, expected 1, got 0`},
+		{s: `SELECT cumulative_sum(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to cumulative_sum`},
+		{s: `SELECT cumulative_sum(mean(value)) FROM myseries where time < now() and time > now() - 1d`, err: `cumulative_sum aggregate requires a GROUP BY interval`},
 		{s: `SELECT moving_average(), field1 FROM myseries`, err: `mixing aggregate and non-aggregate queries is not supported`},
 		{s: `SELECT moving_average() from myseries`, err: `invalid number of arguments for moving_average, expected 2, got 0`},
 		{s: `SELECT moving_average(value) FROM myseries`, err: `invalid number of arguments for moving_average, expected 2, got 1`},
@@ -1971,6 +2474,22 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SELECT moving_average(max(), 2) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for max, expected 1, got 0`},
 		{s: `SELECT moving_average(percentile(value), 2) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for percentile, expected 2, got 1`},
 		{s: `SELECT moving_average(mean(value), 2) FROM myseries where time < now() and time > now() - 1d`, err: `moving_average aggregate requires a GROUP BY interval`},
+		{s: `SELECT moving_average(sum(distinct(value)), 2) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `expected field argument in sum()`},
+		{s: `SELECT moving_average(count(distinct(value, field1)), 2) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `count(distinct <field>) can only have one argument`},
+		{s: `SELECT exponential_moving_average(value) FROM myseries`, err: `invalid number of arguments for exponential_moving_average, expected 2, got 1`},
+		{s: `SELECT exponential_moving_average(value, 1) FROM myseries`, err: `exponential_moving_average window must be greater than 1, got 1`},
+		{s: `SELECT double_exponential_moving_average(value, 2.5) FROM myseries`, err: `second argument for double_exponential_moving_average must be an integer, got *influxql.NumberLiteral`},
+		{s: `SELECT kaufmans_adaptive_moving_average(value, 2) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to kaufmans_adaptive_moving_average`},
+		{s: `SELECT kaufmans_adaptive_moving_average(mean(value), 2) FROM myseries where time < now() and time > now() - 1d`, err: `kaufmans_adaptive_moving_average aggregate requires a GROUP BY interval`},
+		{s: `SELECT holt_winters(value) FROM myseries where time < now() and time > now() - 1d`, err: `invalid number of arguments for holt_winters, expected 3, got 1`},
+		{s: `SELECT holt_winters(value, 10, 2) FROM myseries where time < now() and time > now() - 1d group by time(1d)`, err: `must use aggregate function with holt_winters`},
+		{s: `SELECT holt_winters(min(value), 10, 2) FROM myseries where time < now() and time > now() - 1d`, err: `holt_winters aggregate requires a GROUP BY interval`},
+		{s: `SELECT holt_winters(max(), 10, 2) FROM myseries where time < now() and time > now() - 1d group by time(1d)`, err: `invalid number of arguments for max, expected 1, got 0`},
+		{s: `SELECT holt_winters(min(value), 0, 2) FROM myseries where time < now() and time > now() - 1d group by time(1d)`, err: `second arg to holt_winters must be greater than 0, got 0`},
+		{s: `SELECT holt_winters(min(value), false, 2) FROM myseries where time < now() and time > now() - 1d group by time(1d)`, err: `expected integer argument as second arg in holt_winters`},
+		{s: `SELECT holt_winters(min(value), 10, 'string') FROM myseries where time < now() and time > now() - 1d group by time(1d)`, err: `expected integer argument as third arg in holt_winters`},
+		{s: `SELECT holt_winters(min(value), 10, -1) FROM myseries where time < now() and time > now() - 1d group by time(1d)`, err: `third arg to holt_winters cannot be negative, got -1`},
+		{s: `SELECT holt_winters_with_fit(min(value), 10, 2), value FROM myseries where time < now() and time > now() - 1d group by time(1d)`, err: `mixing aggregate and non-aggregate queries is not supported`},
 		{s: `SELECT field1 from myseries WHERE host =~ 'asd' LIMIT 1`, err: `found asd, expected regex at line 1, char 42`},
 		{s: `SELECT value > 2 FROM cpu`, err: `invalid operator > in SELECT clause at line 1, char 8; operator is intended for WHERE clause`},
 		{s: `SELECT value = 2 FROM cpu`, err: `invalid operator = in SELECT clause at line 1, char 8; operator is intended for WHERE clause`},
@@ -1983,6 +2502,7 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SELECT count(foo + sum(bar)) FROM cpu`, err: `expected field argument in count()`},
 		{s: `SELECT (count(foo + sum(bar))) FROM cpu`, err: `expected field argument in count()`},
 		{s: `SELECT sum(value) + count(foo + sum(bar)) FROM cpu`, err: `binary expressions cannot mix aggregates and raw fields`},
+		{s: `SELECT CASE WHEN mean(value) > 1 THEN value END FROM cpu`, err: `case expressions cannot mix aggregates and raw fields`},
 		// See issues https://github.com/influxdata/influxdb/issues/1647
 		// and https://github.com/influxdata/influxdb/issues/4404
 		//{s: `DELETE`, err: `found EOF, expected FROM at line 1, char 8`},
@@ -2002,7 +2522,10 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `SHOW RETENTION POLICIES mydb`, err: `found mydb, expected ON at line 1, char 25`},
 		{s: `SHOW RETENTION POLICIES ON`, err: `found EOF, expected identifier at line 1, char 28`},
 		{s: `SHOW SHARD`, err: `found EOF, expected GROUPS at line 1, char 12`},
-		{s: `SHOW FOO`, err: `found FOO, expected CONTINUOUS, DATABASES, DIAGNOSTICS, FIELD, GRANTS, MEASUREMENTS, QUERIES, RETENTION, SERIES, SHARD, SHARDS, STATS, SUBSCRIPTIONS, TAG, USERS at line 1, char 6`},
+		{s: `SHOW SERIES EXACT`, err: `found EOF, expected CARDINALITY at line 1, char 19`},
+		{s: `SHOW MEASUREMENT`, err: `found EOF, expected EXACT, CARDINALITY at line 1, char 18`},
+		{s: `SHOW TAG VALUES CARDINALITY`, err: `found EOF, expected WITH at line 1, char 29`},
+		{s: `SHOW FOO`, err: `found FOO, expected CONTINUOUS, DATABASES, DIAGNOSTICS, FIELD, GRANTS, MEASUREMENT, MEASUREMENTS, QUERIES, RETENTION, SCHEMAS, SERIES, SHARD, SHARDS, STATS, SUBSCRIPTIONS, TAG, USERS at line 1, char 6`},
 		{s: `SHOW STATS FOR`, err: `found EOF, expected string at line 1, char 16`},
 		{s: `SHOW DIAGNOSTICS FOR`, err: `found EOF, expected string at line 1, char 22`},
 		{s: `SHOW GRANTS`, err: `found EOF, expected FOR at line 1, char 13`},
@@ -2015,8 +2538,8 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `CREATE CONTINUOUS QUERY`, err: `found EOF, expected identifier at line 1, char 25`},
 		{s: `CREATE CONTINUOUS QUERY cq ON db RESAMPLE FOR 5s BEGIN SELECT mean(value) INTO cpu_mean FROM cpu GROUP BY time(10s) END`, err: `FOR duration must be >= GROUP BY time duration: must be a minimum of 10s, got 5s`},
 		{s: `CREATE CONTINUOUS QUERY cq ON db RESAMPLE EVERY 10s FOR 5s BEGIN SELECT mean(value) INTO cpu_mean FROM cpu GROUP BY time(5s) END`, err: `FOR duration must be >= GROUP BY time duration: must be a minimum of 10s, got 5s`},
-		{s: `DROP FOO`, err: `found FOO, expected CONTINUOUS, MEASUREMENT, RETENTION, SERIES, SHARD, SUBSCRIPTION, USER at line 1, char 6`},
-		{s: `CREATE FOO`, err: `found FOO, expected CONTINUOUS, DATABASE, USER, RETENTION, SUBSCRIPTION at line 1, char 8`},
+		{s: `DROP FOO`, err: `found FOO, expected CONTINUOUS, MEASUREMENT, RETENTION, SCHEMA, SERIES, SHARD, SUBSCRIPTION, USER at line 1, char 6`},
+		{s: `CREATE FOO`, err: `found FOO, expected CONTINUOUS, DATABASE, USER, RETENTION, SUBSCRIPTION, SCHEMA at line 1, char 8`},
 		{s: `CREATE DATABASE`, err: `found EOF, expected identifier at line 1, char 17`},
 		{s: `CREATE DATABASE "testdb" WITH`, err: `found EOF, expected DURATION, NAME, REPLICATION, SHARD at line 1, char 31`},
 		{s: `CREATE DATABASE "testdb" WITH DURATION`, err: `found EOF, expected duration at line 1, char 40`},
@@ -2134,12 +2657,28 @@ func TestParser_ParseStatement(t *testing.T) {
 		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 3.14`, err: `found 3.14, expected integer at line 1, char 67`},
 		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION 0`, err: `invalid value 0: must be 1 <= n <= 2147483647 at line 1, char 67`},
 		{s: `CREATE RETENTION POLICY policy1 ON testdb DURATION 1h REPLICATION bad`, err: `found bad, expected integer at line 1, char 67`},
-		{s: `ALTER`, err: `found EOF, expected RETENTION at line 1, char 7`},
+		{s: `ALTER`, err: `found EOF, expected RETENTION, SHARD at line 1, char 7`},
+		{s: `ALTER SHARD`, err: `found EOF, expected integer at line 1, char 13`},
+		{s: `ALTER SHARD 1`, err: `found EOF, expected TIER at line 1, char 14`},
+		{s: `ALTER SHARD 1 TIER`, err: `found EOF, expected HOT, COLD at line 1, char 20`},
+		{s: `ALTER SHARD 1 TIER WARM`, err: `found WARM, expected HOT, COLD at line 1, char 20`},
+		{s: `CREATE SCHEMA cpu`, err: `found EOF, expected ON at line 1, char 19`},
+		{s: `CREATE SCHEMA cpu ON db0`, err: `found EOF, expected ( at line 1, char 26`},
+		{s: `CREATE SCHEMA cpu ON db0 (host)`, err: `found ), expected TAG, FLOAT, INTEGER, STRING, BOOLEAN at line 1, char 31`},
+		{s: `CREATE SCHEMA cpu ON db0 (host TAG value FLOAT)`, err: `found value, expected ,, ) at line 1, char 36`},
+		{s: `CREATE SCHEMA cpu ON db0 (host TAG, host FLOAT)`, err: `duplicate column in schema: host`},
+		{s: `DROP SCHEMA cpu`, err: `found EOF, expected ON at line 1, char 17`},
 		{s: `ALTER RETENTION`, err: `found EOF, expected POLICY at line 1, char 17`},
 		{s: `ALTER RETENTION POLICY`, err: `found EOF, expected identifier at line 1, char 24`},
 		{s: `ALTER RETENTION POLICY policy1`, err: `found EOF, expected ON at line 1, char 32`}, {s: `ALTER RETENTION POLICY policy1 ON`, err: `found EOF, expected identifier at line 1, char 35`},
-		{s: `ALTER RETENTION POLICY policy1 ON testdb`, err: `found EOF, expected DURATION, RETENTION, SHARD, DEFAULT at line 1, char 42`},
-		{s: `SET`, err: `found EOF, expected PASSWORD at line 1, char 5`},
+		{s: `ALTER RETENTION POLICY policy1 ON testdb`, err: `found EOF, expected DURATION, RETENTION, SHARD, MEASUREMENT, DEFAULT at line 1, char 42`},
+		{s: `ALTER RETENTION POLICY policy1 ON testdb MEASUREMENT cpu`, err: `found EOF, expected DURATION at line 1, char 58`},
+		{s: `SELECT value::unknown FROM cpu`, err: `found unknown, expected tag, field, float, integer, string, boolean at line 1, char 15`},
+		{s: `SELECT value: FROM cpu`, err: `found  , expected : at line 1, char 14`},
+		{s: `SET`, err: `found EOF, expected PASSWORD, NOW at line 1, char 5`},
+		{s: `SET NOW`, err: `found EOF, expected = at line 1, char 9`},
+		{s: `SET NOW = 'foo'`, err: `unable to parse datetime at line 1, char 10`},
+		{s: `SET NOW = now()`, err: `found now, expected string, integer at line 1, char 11`},
 		{s: `SET PASSWORD`, err: `found EOF, expected FOR at line 1, char 14`},
 		{s: `SET PASSWORD something`, err: `found something, expected FOR at line 1, char 14`},
 		{s: `SET PASSWORD FOR`, err: `found EOF, expected identifier at line 1, char 18`},
@@ -2157,6 +2696,11 @@ func TestParser_ParseStatement(t *testing.T) {
 		// We are memoizing a field so for testing we need to...
 		if s, ok := tt.stmt.(*influxql.SelectStatement); ok {
 			s.GroupByInterval()
+			for _, src := range s.Sources {
+				if sq, ok := src.(*influxql.SubQuery); ok {
+					sq.Statement.GroupByInterval()
+				}
+			}
 		} else if st, ok := stmt.(*influxql.CreateContinuousQueryStatement); ok { // if it's a CQ, there is a non-exported field that gets memoized during parsing that needs to be set
 			if st != nil && st.Source != nil {
 				tt.stmt.(*influxql.CreateContinuousQueryStatement).Source.GroupByInterval()
@@ -2173,6 +2717,53 @@ func TestParser_ParseStatement(t *testing.T) {
 	}
 }
 
+// Ensure the parser binds parameters to literals.
+func TestParser_ParseStatement_BoundParams(t *testing.T) {
+	params := map[string]interface{}{
+		"host":  "serverA",
+		"start": "2000-01-01T00:00:00Z",
+		"value": json.Number("10"),
+		"ratio": json.Number("0.5"),
+		"ok":    true,
+		"ago":   map[string]interface{}{"duration": "1h"},
+		"re":    map[string]interface{}{"regex": "^server"},
+		"limit": "x",
+	}
+
+	var tests = []struct {
+		s   string
+		exp string
+		err string
+	}{
+		{
+			s:   `SELECT value FROM cpu WHERE host = $host AND time >= $start AND value > $value`,
+			exp: `SELECT value FROM cpu WHERE host = 'serverA' AND time >= '2000-01-01T00:00:00Z' AND value > 10`,
+		},
+		{
+			s:   `SELECT value FROM cpu WHERE ratio < $ratio AND ok = $ok AND $limit = 'x'`,
+			exp: `SELECT value FROM cpu WHERE ratio < 0.500 AND ok = true AND 'x' = 'x'`,
+		},
+		{
+			s:   `SELECT value FROM cpu WHERE host =~ $re AND time > now() - $ago`,
+			exp: `SELECT value FROM cpu WHERE host =~ /^server/ AND time > now() - 1h`,
+		},
+		{s: `SELECT value FROM cpu WHERE host = $missing`, err: `missing parameter: missing at line 1, char 36`},
+		{s: `SELECT value FROM cpu WHERE host = $"my host"`, err: `missing parameter: my host at line 1, char 36`},
+		{s: `SELECT value FROM cpu WHERE host =~ $host`, err: `found $host, expected regex at line 1, char 37`},
+	}
+
+	for i, tt := range tests {
+		p := influxql.NewParser(strings.NewReader(tt.s))
+		p.SetParams(params)
+		stmt, err := p.ParseStatement()
+		if errstring(err) != tt.err {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s", i, tt.s, tt.err, err)
+		} else if err == nil && stmt.String() != tt.exp {
+			t.Errorf("%d. %q: statement mismatch:\n  exp=%s\n  got=%s", i, tt.s, tt.exp, stmt.String())
+		}
+	}
+}
+
 // Ensure the parser can parse expressions into an AST.
 func TestParser_ParseExpr(t *testing.T) {
 	var tests = []struct {
@@ -2356,6 +2947,56 @@ func TestParser_ParseExpr(t *testing.T) {
 				},
 			},
 		},
+
+		// Case expression
+		{
+			s: `CASE WHEN value > 10 THEN 'high' WHEN value > 5 THEN 'medium' ELSE 'low' END`,
+			expr: &influxql.CaseExpr{
+				WhenClauses: []*influxql.WhenClause{
+					{
+						Condition: &influxql.BinaryExpr{
+							Op:  influxql.GT,
+							LHS: &influxql.VarRef{Val: "value"},
+							RHS: &influxql.IntegerLiteral{Val: 10},
+						},
+						Result: &influxql.StringLiteral{Val: "high"},
+					},
+					{
+						Condition: &influxql.BinaryExpr{
+							Op:  influxql.GT,
+							LHS: &influxql.VarRef{Val: "value"},
+							RHS: &influxql.IntegerLiteral{Val: 5},
+						},
+						Result: &influxql.StringLiteral{Val: "medium"},
+					},
+				},
+				Else: &influxql.StringLiteral{Val: "low"},
+			},
+		},
+
+		// Case expression without an else result
+		{
+			s: `CASE WHEN mean(value) > 5 THEN mean(value) * 2 END`,
+			expr: &influxql.CaseExpr{
+				WhenClauses: []*influxql.WhenClause{
+					{
+						Condition: &influxql.BinaryExpr{
+							Op:  influxql.GT,
+							LHS: &influxql.Call{Name: "mean", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}},
+							RHS: &influxql.IntegerLiteral{Val: 5},
+						},
+						Result: &influxql.BinaryExpr{
+							Op:  influxql.MUL,
+							LHS: &influxql.Call{Name: "mean", Args: []influxql.Expr{&influxql.VarRef{Val: "value"}}},
+							RHS: &influxql.IntegerLiteral{Val: 2},
+						},
+					},
+				},
+			},
+		},
+		{s: `CASE WHEN`, err: `found EOF, expected identifier, string, number, bool at line 1, char 11`},
+		{s: `CASE WHEN value > 1 'a' END`, err: `found a, expected THEN at line 1, char 20`},
+		{s: `CASE WHEN value > 1 THEN 'a'`, err: `found EOF, expected WHEN, ELSE, END at line 1, char 29`},
 	}
 
 	for i, tt := range tests {
@@ -2633,6 +3274,17 @@ func newAlterRetentionPolicyStatement(name string, DB string, d, sd time.Duratio
 	return stmt
 }
 
+// newAlterMeasurementDurationStatement creates an AlterRetentionPolicyStatement
+// that sets the duration of a measurement.
+func newAlterMeasurementDurationStatement(name, DB, measurement string, d time.Duration) *influxql.AlterRetentionPolicyStatement {
+	return &influxql.AlterRetentionPolicyStatement{
+		Name:                name,
+		Database:            DB,
+		Measurement:         measurement,
+		MeasurementDuration: &d,
+	}
+}
+
 // mustMarshalJSON encodes a value to JSON.
 func mustMarshalJSON(v interface{}) []byte {
 	b, err := json.Marshal(v)
@@ -2649,3 +3301,11 @@ func mustParseDuration(s string) time.Duration {
 	}
 	return d
 }
+
+func mustLoadLocation(s string) *time.Location {
+	l, err := time.LoadLocation(s)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
@@ -9,6 +9,7 @@ package influxql
 import (
 	"encoding/binary"
 	"io"
+	"unsafe"
 
 	"github.com/gogo/protobuf/proto"
 	internal "github.com/influxdata/influxdb/influxql/internal"
@@ -58,6 +59,19 @@ func (v *FloatPoint) Clone() *FloatPoint {
 	return &other
 }
 
+// size returns an estimate of the memory used by v.  The name and tags are
+// not counted since they are shared by the points of a series.
+func (v *FloatPoint) size() int64 {
+	n := int64(unsafe.Sizeof(*v))
+	for _, a := range v.Aux {
+		n += 16
+		if s, ok := a.(string); ok {
+			n += int64(len(s))
+		}
+	}
+	return n
+}
+
 func encodeFloatPoint(p *FloatPoint) *internal.Point {
 	return &internal.Point{
 		Name:       proto.String(p.Name),
@@ -260,6 +274,19 @@ func (v *IntegerPoint) Clone() *IntegerPoint {
 	return &other
 }
 
+// size returns an estimate of the memory used by v.  The name and tags are
+// not counted since they are shared by the points of a series.
+func (v *IntegerPoint) size() int64 {
+	n := int64(unsafe.Sizeof(*v))
+	for _, a := range v.Aux {
+		n += 16
+		if s, ok := a.(string); ok {
+			n += int64(len(s))
+		}
+	}
+	return n
+}
+
 func encodeIntegerPoint(p *IntegerPoint) *internal.Point {
 	return &internal.Point{
 		Name:       proto.String(p.Name),
@@ -462,6 +489,19 @@ func (v *StringPoint) Clone() *StringPoint {
 	return &other
 }
 
+// size returns an estimate of the memory used by v.  The name and tags are
+// not counted since they are shared by the points of a series.
+func (v *StringPoint) size() int64 {
+	n := int64(unsafe.Sizeof(*v)) + int64(len(v.Value))
+	for _, a := range v.Aux {
+		n += 16
+		if s, ok := a.(string); ok {
+			n += int64(len(s))
+		}
+	}
+	return n
+}
+
 func encodeStringPoint(p *StringPoint) *internal.Point {
 	return &internal.Point{
 		Name:       proto.String(p.Name),
@@ -664,6 +704,19 @@ func (v *BooleanPoint) Clone() *BooleanPoint {
 	return &other
 }
 
+// size returns an estimate of the memory used by v.  The name and tags are
+// not counted since they are shared by the points of a series.
+func (v *BooleanPoint) size() int64 {
+	n := int64(unsafe.Sizeof(*v))
+	for _, a := range v.Aux {
+		n += 16
+		if s, ok := a.(string); ok {
+			n += int64(len(s))
+		}
+	}
+	return n
+}
+
 func encodeBooleanPoint(p *BooleanPoint) *internal.Point {
 	return &internal.Point{
 		Name:       proto.String(p.Name),
@@ -25,9 +25,15 @@ var (
 	// ErrQueryInterrupted is an error returned when the query is interrupted.
 	ErrQueryInterrupted = errors.New("query interrupted")
 
+	// ErrServerBusy is an error when a query cannot be run because the
+	// maximum number of queries are running and the query could not wait
+	// for one of them to finish, either because the wait queue is full or
+	// because it waited for longer than the queue timeout.
+	ErrServerBusy = errors.New("server busy: max concurrent queries reached")
+
 	// ErrMaxConcurrentQueriesReached is an error when a query cannot be run
 	// because the maximum number of queries has been reached.
-	ErrMaxConcurrentQueriesReached = errors.New("max concurrent queries reached")
+	ErrMaxConcurrentQueriesReached = ErrServerBusy
 
 	// ErrQueryEngineShutdown is an error sent when the query cannot be
 	// created because the query engine was shutdown.
@@ -45,11 +51,18 @@ const (
 	// DefaultQueryTimeout is the default timeout for executing a query.
 	// A value of zero will have no query timeout.
 	DefaultQueryTimeout = time.Duration(0)
+
+	// MaxBufferedResults is the number of results a statement executed
+	// concurrently buffers while the statements before it are being sent.
+	// Once reached, the statement blocks until its results can be sent.
+	MaxBufferedResults = 10
 )
 
 // Statistics for the QueryExecutor
 const (
 	statQueriesActive          = "queriesActive"   // Number of queries currently being executed
+	statQueriesQueued          = "queriesQueued"   // Number of queries currently waiting to be executed
+	statQueriesRejected        = "queriesRejected" // Number of queries rejected because the server was busy
 	statQueryExecutionDuration = "queryDurationNs" // Total (wall) time spent executing queries
 )
 
@@ -82,6 +95,10 @@ type ExecutionContext struct {
 	// If this query is being executed in a read-only context.
 	ReadOnly bool
 
+	// The value of now() for the statement. If it is zero, the current
+	// time is used.
+	Now time.Time
+
 	// Hold the query executor's logger.
 	Log *log.Logger
 
@@ -115,16 +132,47 @@ type QueryExecutor struct {
 	// Maximum number of concurrent queries.
 	MaxConcurrentQueries int
 
+	// Maximum number of queries waiting for a running query to finish when
+	// MaxConcurrentQueries are running. Queries are run in the order they
+	// arrived. If zero, queries are rejected as soon as the limit is reached.
+	MaxQueuedQueries int
+
+	// Maximum time a query waits for a running query to finish before it is
+	// rejected. If zero, a query waits until it can run or is interrupted.
+	QueueTimeout time.Duration
+
+	// Maximum number of statements of a query executed at the same time.
+	// Statements are only executed concurrently if none of them changes any
+	// data. If zero or one, statements are executed one after another.
+	MaxConcurrentStatements int
+
 	// Logger to use for all logging.
 	// Defaults to discarding all log output.
 	Logger *log.Logger
 
+	// Logger for the queries which took longer than SlowQueryDuration once
+	// they finish, along with the number of series and points they read.
+	// If nil or SlowQueryDuration is zero, finished queries are not logged.
+	SlowQueryLogger   *log.Logger
+	SlowQueryDuration time.Duration
+
 	// Used for managing and tracking running queries.
 	queries  map[uint64]*QueryTask
 	nextID   uint64
 	mu       sync.RWMutex
 	shutdown bool
 
+	// Queries waiting for a running query to finish. The channel of the
+	// first one is closed when a query finishes and its slot is reserved
+	// until the waiting query is attached.
+	waiting  []chan struct{}
+	reserved int
+
+	// Number of running queries that only manage other queries. They are
+	// not limited by MaxConcurrentQueries so they can stop queries while
+	// the server is busy.
+	unlimited int
+
 	// expvar-based stats.
 	statMap *expvar.Map
 }
@@ -151,6 +199,10 @@ func (e *QueryExecutor) Close() error {
 		close(query.closing)
 	}
 	e.queries = nil
+	for _, ready := range e.waiting {
+		close(ready)
+	}
+	e.waiting = nil
 	return nil
 }
 
@@ -160,14 +212,57 @@ func (e *QueryExecutor) SetLogOutput(w io.Writer) {
 	e.Logger = log.New(w, "[query] ", log.LstdFlags)
 }
 
+// SetSlowQueryLogOutput sets the writer to which finished slow queries are
+// logged.
+func (e *QueryExecutor) SetSlowQueryLogOutput(w io.Writer) {
+	e.SlowQueryLogger = log.New(w, "", log.LstdFlags)
+}
+
 // ExecuteQuery executes each statement within a query.
 func (e *QueryExecutor) ExecuteQuery(query *Query, database string, chunkSize int, readonly bool, closing chan struct{}) <-chan *Result {
+	return e.ExecuteQueryWithSource(query, "", database, chunkSize, readonly, closing)
+}
+
+// ExecuteQueryWithSource executes each statement within a query and records
+// where the query came from. The source is reported by SHOW QUERIES.
+func (e *QueryExecutor) ExecuteQueryWithSource(query *Query, source, database string, chunkSize int, readonly bool, closing chan struct{}) <-chan *Result {
+	return e.ExecuteQueryWithOptions(query, ExecutionOptions{
+		Source:    source,
+		Database:  database,
+		ChunkSize: chunkSize,
+		ReadOnly:  readonly,
+	}, closing)
+}
+
+// ExecutionOptions contains the options for executing a query.
+type ExecutionOptions struct {
+	// Where the query came from. It is reported by SHOW QUERIES.
+	Source string
+
+	// The database the query is running against.
+	Database string
+
+	// The requested maximum number of points to return in each result.
+	ChunkSize int
+
+	// If this query is being executed in a read-only context.
+	ReadOnly bool
+
+	// The value of now() for every statement of the query. If it is zero,
+	// the current time is used. A SET NOW statement overrides it for the
+	// statements following it.
+	Now time.Time
+}
+
+// ExecuteQueryWithOptions executes each statement within a query using opt.
+func (e *QueryExecutor) ExecuteQueryWithOptions(query *Query, opt ExecutionOptions, closing chan struct{}) <-chan *Result {
 	results := make(chan *Result)
-	go e.executeQuery(query, database, chunkSize, readonly, closing, results)
+	go e.executeQuery(query, opt, closing, results)
 	return results
 }
 
-func (e *QueryExecutor) executeQuery(query *Query, database string, chunkSize int, readonly bool, closing <-chan struct{}, results chan *Result) {
+func (e *QueryExecutor) executeQuery(query *Query, opt ExecutionOptions, closing <-chan struct{}, results chan *Result) {
+	database := opt.Database
 	defer close(results)
 	defer e.recover(query, results)
 
@@ -177,12 +272,13 @@ func (e *QueryExecutor) executeQuery(query *Query, database string, chunkSize in
 		e.statMap.Add(statQueryExecutionDuration, time.Since(start).Nanoseconds())
 	}(time.Now())
 
-	qid, task, err := e.attachQuery(query, database, closing)
+	qid, task, err := e.attachQuery(query, opt.Source, database, closing)
 	if err != nil {
 		results <- &Result{Err: err}
 		return
 	}
 	defer e.killQuery(qid)
+	defer e.logSlowQuery(qid, task)
 
 	// Setup the execution context that will be used when executing statements.
 	ctx := ExecutionContext{
@@ -190,43 +286,29 @@ func (e *QueryExecutor) executeQuery(query *Query, database string, chunkSize in
 		Query:       task,
 		Results:     results,
 		Database:    database,
-		ChunkSize:   chunkSize,
-		ReadOnly:    readonly,
+		ChunkSize:   opt.ChunkSize,
+		ReadOnly:    opt.ReadOnly,
+		Now:         opt.Now,
 		Log:         e.Logger,
 		InterruptCh: task.closing,
 	}
 
+	// Statements which only read data can be executed at the same time.
+	if e.MaxConcurrentStatements > 1 && len(query.Statements) > 1 && concurrentStatements(query.Statements) {
+		e.executeConcurrentStatements(query, database, ctx, results)
+		return
+	}
+
 	var i int
 loop:
 	for ; i < len(query.Statements); i++ {
 		ctx.StatementID = i
-		stmt := query.Statements[i]
 
-		// If a default database wasn't passed in by the caller, check the statement.
-		defaultDB := database
-		if defaultDB == "" {
-			if s, ok := stmt.(HasDefaultDatabase); ok {
-				defaultDB = s.DefaultDatabase()
-			}
-		}
-
-		// Rewrite statements, if necessary.
-		// This can occur on meta read statements which convert to SELECT statements.
-		newStmt, err := RewriteStatement(stmt)
+		stmt, err := e.prepareStatement(query.Statements[i], database)
 		if err != nil {
 			results <- &Result{Err: err}
 			break
 		}
-		stmt = newStmt
-
-		// Normalize each statement.
-		if err := e.StatementExecutor.NormalizeStatement(stmt, defaultDB); err != nil {
-			results <- &Result{Err: err}
-			break
-		}
-
-		// Log each normalized statement.
-		e.Logger.Println(stmt.String())
 
 		// Handle a query management queries specially so they don't go
 		// to the underlying statement executor.
@@ -243,6 +325,10 @@ loop:
 				break loop
 			}
 			continue loop
+		case *SetNowStatement:
+			ctx.Now = stmt.Now
+			results <- &Result{StatementID: i}
+			continue loop
 		case *KillQueryStatement:
 			var messages []*Message
 			if ctx.ReadOnly {
@@ -263,17 +349,8 @@ loop:
 		}
 
 		// Send any other statements to the underlying statement executor.
-		err = e.StatementExecutor.ExecuteStatement(stmt, &ctx)
-		if err == ErrQueryInterrupted {
-			// Query was interrupted so retrieve the real interrupt error from
-			// the query task if there is one.
-			if qerr := task.Error(); qerr != nil {
-				err = qerr
-			}
-		}
-
 		// Send an error for this result if it failed for some reason.
-		if err != nil {
+		if err := e.executeStatement(stmt, &ctx); err != nil {
 			results <- &Result{
 				StatementID: i,
 				Err:         err,
@@ -292,6 +369,161 @@ loop:
 	}
 }
 
+// prepareStatement rewrites and normalizes a statement before it is executed.
+func (e *QueryExecutor) prepareStatement(stmt Statement, database string) (Statement, error) {
+	// If a default database wasn't passed in by the caller, check the statement.
+	defaultDB := database
+	if defaultDB == "" {
+		if s, ok := stmt.(HasDefaultDatabase); ok {
+			defaultDB = s.DefaultDatabase()
+		}
+	}
+
+	// Rewrite statements, if necessary.
+	// This can occur on meta read statements which convert to SELECT statements.
+	stmt, err := RewriteStatement(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalize each statement.
+	if err := e.StatementExecutor.NormalizeStatement(stmt, defaultDB); err != nil {
+		return nil, err
+	}
+
+	// Log each normalized statement.
+	e.Logger.Println(stmt.String())
+	return stmt, nil
+}
+
+// executeStatement sends a statement to the underlying statement executor.
+func (e *QueryExecutor) executeStatement(stmt Statement, ctx *ExecutionContext) error {
+	err := e.StatementExecutor.ExecuteStatement(stmt, ctx)
+	if err == ErrQueryInterrupted {
+		// Query was interrupted so retrieve the real interrupt error from
+		// the query task if there is one.
+		if qerr := ctx.Query.Error(); qerr != nil {
+			err = qerr
+		}
+	}
+	return err
+}
+
+// executeConcurrentStatements executes up to MaxConcurrentStatements
+// statements of a query at the same time. The results are still sent in the
+// order of the statements: the first unfinished statement streams its results
+// while the following ones buffer up to MaxBufferedResults of theirs until
+// every statement before them has been sent. An error only fails the
+// statement which returned it.
+func (e *QueryExecutor) executeConcurrentStatements(query *Query, database string, ctx ExecutionContext, results chan *Result) {
+	sem := make(chan struct{}, e.MaxConcurrentStatements)
+
+	// The first statement can send its results immediately.
+	prev := make(chan struct{})
+	close(prev)
+
+	var wg sync.WaitGroup
+	for i, stmt := range query.Statements {
+		ch, done := make(chan *Result), make(chan struct{})
+		wg.Add(1)
+		go func(prev <-chan struct{}) {
+			defer wg.Done()
+			relayResults(ch, results, prev, done)
+		}(prev)
+		prev = done
+
+		// SET NOW applies to the statements following it.
+		if stmt, ok := stmt.(*SetNowStatement); ok {
+			ctx.Now = stmt.Now
+		}
+
+		sem <- struct{}{}
+		ctx.StatementID, ctx.Results = i, ch
+		wg.Add(1)
+		go func(stmt Statement, ctx ExecutionContext) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer close(ctx.Results)
+			defer e.recover(query, ctx.Results)
+
+			if _, ok := stmt.(*SetNowStatement); ok {
+				ctx.Results <- &Result{StatementID: ctx.StatementID}
+				return
+			}
+
+			stmt, err := e.prepareStatement(stmt, database)
+			if err == nil {
+				err = e.executeStatement(stmt, &ctx)
+			}
+			if err != nil {
+				ctx.Results <- &Result{
+					StatementID: ctx.StatementID,
+					Err:         err,
+				}
+			}
+		}(stmt, ctx)
+	}
+	wg.Wait()
+}
+
+// concurrentStatements returns true if none of the statements changes any
+// data, so they can be executed in any order.
+func concurrentStatements(stmts Statements) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *SelectStatement:
+			if stmt.Target != nil {
+				return false
+			}
+		case *ShowDatabasesStatement, *ShowRetentionPoliciesStatement,
+			*ShowMeasurementsStatement, *ShowSeriesStatement,
+			*ShowTagKeysStatement, *ShowTagValuesStatement, *ShowFieldKeysStatement,
+			*SetNowStatement:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// relayResults sends the results of a statement from in to out once prev is
+// closed and closes done when all of them have been sent. Up to
+// MaxBufferedResults received before then are buffered so the statement is
+// not blocked.
+func relayResults(in <-chan *Result, out chan<- *Result, prev <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	var buf []*Result
+wait:
+	for {
+		// Stop receiving once the buffer is full so the statement blocks.
+		recv := in
+		if len(buf) >= MaxBufferedResults {
+			recv = nil
+		}
+
+		select {
+		case r, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			buf = append(buf, r)
+		case <-prev:
+			break wait
+		}
+	}
+
+	for _, r := range buf {
+		out <- r
+	}
+	if in != nil {
+		for r := range in {
+			out <- r
+		}
+	}
+}
+
 func (e *QueryExecutor) recover(query *Query, results chan *Result) {
 	if err := recover(); err != nil {
 		results <- &Result{
@@ -301,6 +533,23 @@ func (e *QueryExecutor) recover(query *Query, results chan *Result) {
 	}
 }
 
+// logSlowQuery logs a finished query to the slow query log if it took longer
+// than SlowQueryDuration.
+func (e *QueryExecutor) logSlowQuery(qid uint64, query *QueryTask) {
+	if e.SlowQueryLogger == nil || e.SlowQueryDuration == 0 {
+		return
+	}
+
+	d := time.Since(query.startTime)
+	if d < e.SlowQueryDuration {
+		return
+	}
+
+	stats := query.Stats()
+	e.SlowQueryLogger.Printf("qid=%d database=%q source=%q duration=%s series=%d points=%d blocks=%d query=%q",
+		qid, query.database, query.source, d, stats.SeriesN, stats.PointN, stats.BlockN, query.query)
+}
+
 func (e *QueryExecutor) executeKillQueryStatement(stmt *KillQueryStatement) error {
 	return e.killQuery(stmt.QueryID)
 }
@@ -323,11 +572,11 @@ func (e *QueryExecutor) executeShowQueriesStatement(q *ShowQueriesStatement) (mo
 		} else {
 			ds = (d - (d % time.Second)).String()
 		}
-		values = append(values, []interface{}{id, qi.query, qi.database, ds})
+		values = append(values, []interface{}{id, qi.query, qi.database, qi.source, ds})
 	}
 
 	return []*models.Row{{
-		Columns: []string{"qid", "query", "database", "duration"},
+		Columns: []string{"qid", "query", "database", "source", "duration"},
 		Values:  values,
 	}}, nil
 }
@@ -346,7 +595,7 @@ func (e *QueryExecutor) query(qid uint64) (*QueryTask, bool) {
 // query finishes running.
 //
 // After a query finishes running, the system is free to reuse a query id.
-func (e *QueryExecutor) attachQuery(q *Query, database string, interrupt <-chan struct{}) (uint64, *QueryTask, error) {
+func (e *QueryExecutor) attachQuery(q *Query, source, database string, interrupt <-chan struct{}) (uint64, *QueryTask, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -354,19 +603,30 @@ func (e *QueryExecutor) attachQuery(q *Query, database string, interrupt <-chan
 		return 0, nil, ErrQueryEngineShutdown
 	}
 
-	if e.MaxConcurrentQueries > 0 && len(e.queries) >= e.MaxConcurrentQueries {
-		return 0, nil, ErrMaxConcurrentQueriesReached
+	unlimited := isQueryManagement(q)
+	if !unlimited && e.MaxConcurrentQueries > 0 && (len(e.queries)-e.unlimited+e.reserved >= e.MaxConcurrentQueries || len(e.waiting) > 0) {
+		if err := e.waitForSlot(interrupt); err != nil {
+			if err == ErrServerBusy {
+				e.statMap.Add(statQueriesRejected, 1)
+			}
+			return 0, nil, err
+		}
 	}
 
 	qid := e.nextID
 	query := &QueryTask{
 		query:     q.String(),
 		database:  database,
+		source:    source,
 		startTime: time.Now(),
 		closing:   make(chan struct{}),
 		monitorCh: make(chan error),
+		unlimited: unlimited,
 	}
 	e.queries[qid] = query
+	if unlimited {
+		e.unlimited++
+	}
 
 	go e.waitForQuery(qid, query.closing, interrupt, query.monitorCh)
 	if e.LogQueriesAfter != 0 {
@@ -387,6 +647,69 @@ func (e *QueryExecutor) attachQuery(q *Query, database string, interrupt <-chan
 	return qid, query, nil
 }
 
+// waitForSlot queues a query until one of the running queries finishes and
+// hands its slot over. It must be called with the lock held, which is
+// released while the query waits.
+func (e *QueryExecutor) waitForSlot(interrupt <-chan struct{}) error {
+	if len(e.waiting) >= e.MaxQueuedQueries {
+		return ErrServerBusy
+	}
+
+	ready := make(chan struct{})
+	e.waiting = append(e.waiting, ready)
+	e.statMap.Add(statQueriesQueued, 1)
+	defer e.statMap.Add(statQueriesQueued, -1)
+	e.mu.Unlock()
+
+	var timer <-chan time.Time
+	if e.QueueTimeout != 0 {
+		t := time.NewTimer(e.QueueTimeout)
+		timer = t.C
+		defer t.Stop()
+	}
+
+	var err error
+	select {
+	case <-ready:
+	case <-timer:
+		err = ErrServerBusy
+	case <-interrupt:
+		err = ErrQueryInterrupted
+	}
+
+	e.mu.Lock()
+	if e.shutdown {
+		return ErrQueryEngineShutdown
+	}
+
+	if err != nil {
+		// If the slot was handed over while the query gave up, pass it on
+		// to the next query in the queue.
+		for i, ch := range e.waiting {
+			if ch == ready {
+				e.waiting = append(e.waiting[:i], e.waiting[i+1:]...)
+				return err
+			}
+		}
+		e.reserved--
+		e.releaseSlot()
+		return err
+	}
+	e.reserved--
+	return nil
+}
+
+// releaseSlot hands the slot of a finished query to the first query in the
+// queue, if any. It must be called with the lock held.
+func (e *QueryExecutor) releaseSlot() {
+	if len(e.waiting) == 0 {
+		return
+	}
+	close(e.waiting[0])
+	e.waiting = e.waiting[1:]
+	e.reserved++
+}
+
 // killQuery stops and removes a query from the QueryExecutor.
 // This method can be used to forcefully terminate a running query.
 func (e *QueryExecutor) killQuery(qid uint64) error {
@@ -400,9 +723,27 @@ func (e *QueryExecutor) killQuery(qid uint64) error {
 
 	close(query.closing)
 	delete(e.queries, qid)
+	if query.unlimited {
+		e.unlimited--
+		return nil
+	}
+	e.releaseSlot()
 	return nil
 }
 
+// isQueryManagement returns true if every statement of q is handled by the
+// QueryExecutor itself to show or kill queries.
+func isQueryManagement(q *Query) bool {
+	for _, stmt := range q.Statements {
+		switch stmt.(type) {
+		case *ShowQueriesStatement, *KillQueryStatement, *SetNowStatement:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (e *QueryExecutor) waitForQuery(qid uint64, interrupt <-chan struct{}, closing <-chan struct{}, monitorCh <-chan error) {
 	var timer <-chan time.Time
 	if e.QueryTimeout != 0 {
@@ -451,10 +792,13 @@ type QueryMonitorFunc func(<-chan struct{}) error
 type QueryTask struct {
 	query     string
 	database  string
+	source    string
 	startTime time.Time
 	closing   chan struct{}
 	monitorCh chan error
+	unlimited bool
 	err       error
+	stats     IteratorStats
 	mu        sync.Mutex
 }
 
@@ -474,6 +818,22 @@ func (q *QueryTask) Error() error {
 	return q.err
 }
 
+// AddStats adds the number of series, points and blocks read by a statement
+// to the query.
+func (q *QueryTask) AddStats(stats IteratorStats) {
+	q.mu.Lock()
+	q.stats.Add(stats)
+	q.mu.Unlock()
+}
+
+// Stats returns the number of series, points and blocks read by the
+// statements of the query which have finished.
+func (q *QueryTask) Stats() IteratorStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats
+}
+
 func (q *QueryTask) setError(err error) {
 	q.mu.Lock()
 	q.err = err
@@ -1,12 +1,18 @@
 package influxql_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/influxdata/influxdb/influxql"
+	"github.com/influxdata/influxdb/models"
 )
 
 var errUnexpected = errors.New("unexpected error")
@@ -134,6 +140,54 @@ func TestQueryExecutor_ShowQueries(t *testing.T) {
 	}
 }
 
+func TestQueryExecutor_ShowQueries_Source(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qid := make(chan uint64)
+
+	e := influxql.NewQueryExecutor()
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			qid <- ctx.QueryID
+			<-ctx.InterruptCh
+			return influxql.ErrQueryInterrupted
+		},
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	go discardOutput(e.ExecuteQueryWithSource(q, "127.0.0.1:8086", "mydb", 100, false, closing))
+	id := <-qid
+
+	q, err = influxql.ParseQuery(`SHOW QUERIES`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := <-e.ExecuteQueryWithSource(q, "", "", 100, false, nil)
+	if result.Err != nil {
+		t.Fatalf("unexpected error: %s", result.Err)
+	} else if len(result.Series) != 1 {
+		t.Fatalf("expected %d rows, got %d", 1, len(result.Series))
+	}
+
+	var found bool
+	for _, v := range result.Series[0].Values {
+		if v[0] == id {
+			found = true
+			if v[3] != "127.0.0.1:8086" {
+				t.Errorf("unexpected source: exp=%s got=%v", "127.0.0.1:8086", v[3])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("query %d not found in SHOW QUERIES", id)
+	}
+}
+
 func TestQueryExecutor_Limit_Timeout(t *testing.T) {
 	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu`)
 	if err != nil {
@@ -200,6 +254,130 @@ func TestQueryExecutor_Limit_ConcurrentQueries(t *testing.T) {
 	}
 }
 
+func TestQueryExecutor_Limit_QueuedQueries(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qid := make(chan uint64)
+
+	e := influxql.NewQueryExecutor()
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			qid <- ctx.QueryID
+			<-ctx.InterruptCh
+			return influxql.ErrQueryInterrupted
+		},
+	}
+	e.MaxConcurrentQueries = 1
+	e.MaxQueuedQueries = 1
+	defer e.Close()
+
+	// Start first query and wait for it to be executing.
+	go discardOutput(e.ExecuteQuery(q, "mydb", 100, false, nil))
+	id := <-qid
+
+	// Start second query which waits for the first one to finish.
+	go discardOutput(e.ExecuteQuery(q, "mydb", 100, false, nil))
+	time.Sleep(10 * time.Millisecond)
+
+	// Start a third query and expect it to be rejected.
+	results := e.ExecuteQuery(q, "mydb", 100, false, nil)
+	select {
+	case result := <-results:
+		if result.Err != influxql.ErrServerBusy {
+			t.Errorf("unexpected error: %s", result.Err)
+		}
+	case <-qid:
+		t.Fatalf("unexpected statement execution for the third query")
+	}
+
+	// Kill the first query and expect the second one to run.
+	kill, err := influxql.ParseQuery(fmt.Sprintf("KILL QUERY %d", id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	discardOutput(e.ExecuteQuery(kill, "mydb", 100, false, nil))
+
+	select {
+	case <-qid:
+	case <-time.After(time.Second):
+		t.Errorf("queued query was not executed")
+	}
+}
+
+func TestQueryExecutor_Limit_QueueTimeout(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qid := make(chan uint64)
+
+	e := influxql.NewQueryExecutor()
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			qid <- ctx.QueryID
+			<-ctx.InterruptCh
+			return influxql.ErrQueryInterrupted
+		},
+	}
+	e.MaxConcurrentQueries = 1
+	e.MaxQueuedQueries = 1
+	e.QueueTimeout = time.Millisecond
+	defer e.Close()
+
+	// Start first query and wait for it to be executing.
+	go discardOutput(e.ExecuteQuery(q, "mydb", 100, false, nil))
+	<-qid
+
+	// Start second query and expect it to give up waiting.
+	results := e.ExecuteQuery(q, "mydb", 100, false, nil)
+	select {
+	case result := <-results:
+		if result.Err != influxql.ErrServerBusy {
+			t.Errorf("unexpected error: %s", result.Err)
+		}
+	case <-qid:
+		t.Errorf("unexpected statement execution for the second query")
+	}
+}
+
+func TestQueryExecutor_SlowQueryLog(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := influxql.NewQueryExecutor()
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			ctx.Query.AddStats(influxql.IteratorStats{SeriesN: 2, PointN: 10, BlockN: 1})
+			return nil
+		},
+	}
+	e.SlowQueryDuration = time.Nanosecond
+
+	var buf bytes.Buffer
+	e.SetSlowQueryLogOutput(&buf)
+
+	discardOutput(e.ExecuteQueryWithSource(q, "127.0.0.1:8086", "mydb", 100, false, nil))
+	if s := buf.String(); !strings.Contains(s, `database="mydb" source="127.0.0.1:8086"`) {
+		t.Errorf("unexpected slow query log: %s", s)
+	} else if !strings.Contains(s, `series=2 points=10 blocks=1 query="SELECT count(value) FROM cpu"`) {
+		t.Errorf("unexpected slow query log: %s", s)
+	}
+
+	// Queries faster than the threshold are not logged.
+	buf.Reset()
+	e.SlowQueryDuration = time.Hour
+	discardOutput(e.ExecuteQuery(q, "mydb", 100, false, nil))
+	if buf.Len() != 0 {
+		t.Errorf("unexpected slow query log: %s", buf.String())
+	}
+}
+
 func TestQueryExecutor_Close(t *testing.T) {
 	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu`)
 	if err != nil {
@@ -273,6 +451,202 @@ func TestQueryExecutor_Panic(t *testing.T) {
 	}
 }
 
+func TestQueryExecutor_Now(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu; SET NOW = '2000-01-01T00:00:00Z'; SELECT mean(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, 3} {
+		var mu sync.Mutex
+		now := make(map[int]time.Time)
+
+		e := influxql.NewQueryExecutor()
+		e.StatementExecutor = &StatementExecutor{
+			ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+				mu.Lock()
+				now[ctx.StatementID] = ctx.Now
+				mu.Unlock()
+				ctx.Results <- &influxql.Result{StatementID: ctx.StatementID}
+				return nil
+			},
+		}
+		e.MaxConcurrentStatements = n
+
+		var ids []int
+		opt := influxql.ExecutionOptions{Database: "mydb", Now: time.Unix(0, 10)}
+		for result := range e.ExecuteQueryWithOptions(q, opt, nil) {
+			if result.Err != nil {
+				t.Fatalf("unexpected error: %s", result.Err)
+			}
+			ids = append(ids, result.StatementID)
+		}
+		if exp := []int{0, 1, 2}; !reflect.DeepEqual(ids, exp) {
+			t.Errorf("%d: unexpected statement ids: exp=%v got=%v", n, exp, ids)
+		}
+
+		// The option applies until SET NOW overrides it.
+		exp := map[int]time.Time{
+			0: time.Unix(0, 10),
+			2: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if !reflect.DeepEqual(now, exp) {
+			t.Errorf("%d: unexpected now: exp=%v got=%v", n, exp, now)
+		}
+	}
+}
+
+func TestQueryExecutor_ConcurrentStatements(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu; SELECT mean(value) FROM cpu; SELECT max(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := make(chan struct{}, 2)
+
+	e := influxql.NewQueryExecutor()
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			// The first statement only finishes after the others.
+			if ctx.StatementID == 0 {
+				for i := 0; i < 2; i++ {
+					select {
+					case <-finished:
+					case <-time.After(time.Second):
+						t.Error("statements were not executed concurrently")
+						return errUnexpected
+					}
+				}
+			}
+
+			for i := 0; i < 2; i++ {
+				ctx.Results <- &influxql.Result{
+					StatementID: ctx.StatementID,
+					Series:      models.Rows{{Name: stmt.String(), Values: [][]interface{}{{i}}}},
+					Partial:     i == 0,
+				}
+			}
+			if ctx.StatementID != 0 {
+				finished <- struct{}{}
+			}
+			return nil
+		},
+	}
+	e.MaxConcurrentStatements = 3
+
+	// The results are returned in the order of the statements.
+	var ids []int
+	for result := range e.ExecuteQuery(q, "mydb", 100, false, nil) {
+		if result.Err != nil {
+			t.Fatalf("unexpected error: %s", result.Err)
+		}
+		ids = append(ids, result.StatementID)
+	}
+	if exp := []int{0, 0, 1, 1, 2, 2}; !reflect.DeepEqual(ids, exp) {
+		t.Errorf("unexpected statement ids: exp=%v got=%v", exp, ids)
+	}
+}
+
+func TestQueryExecutor_ConcurrentStatements_BufferedResults(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu; SELECT mean(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocked := make(chan struct{})
+
+	e := influxql.NewQueryExecutor()
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			// The first statement only finishes once the second is blocked.
+			if ctx.StatementID == 0 {
+				<-blocked
+				ctx.Results <- &influxql.Result{StatementID: ctx.StatementID}
+				return nil
+			}
+
+			for i := 0; i < influxql.MaxBufferedResults; i++ {
+				ctx.Results <- &influxql.Result{StatementID: ctx.StatementID, Partial: true}
+			}
+
+			// The buffer is full so the next result cannot be sent yet.
+			r := &influxql.Result{StatementID: ctx.StatementID}
+			select {
+			case ctx.Results <- r:
+				t.Error("results were buffered beyond the limit")
+				close(blocked)
+			case <-time.After(50 * time.Millisecond):
+				close(blocked)
+				ctx.Results <- r
+			}
+			return nil
+		},
+	}
+	e.MaxConcurrentStatements = 2
+
+	var n int
+	for result := range e.ExecuteQuery(q, "mydb", 100, false, nil) {
+		if result.Err != nil {
+			t.Fatalf("unexpected error: %s", result.Err)
+		}
+		n++
+	}
+	if exp := influxql.MaxBufferedResults + 2; n != exp {
+		t.Errorf("unexpected result count: exp=%d got=%d", exp, n)
+	}
+}
+
+func TestQueryExecutor_ConcurrentStatements_Errors(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu; SELECT mean(value) FROM cpu; SELECT max(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := influxql.NewQueryExecutor()
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			if ctx.StatementID == 1 {
+				return errUnexpected
+			}
+			ctx.Results <- &influxql.Result{StatementID: ctx.StatementID}
+			return nil
+		},
+	}
+	e.MaxConcurrentStatements = 2
+
+	// An error only fails its own statement.
+	var errs []error
+	for result := range e.ExecuteQuery(q, "mydb", 100, false, nil) {
+		errs = append(errs, result.Err)
+	}
+	if exp := []error{nil, errUnexpected, nil}; !reflect.DeepEqual(errs, exp) {
+		t.Errorf("unexpected errors: exp=%v got=%v", exp, errs)
+	}
+}
+
+func TestQueryExecutor_ConcurrentStatements_Writes(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu; SELECT mean(value) INTO cpu_mean FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var running int32
+	e := influxql.NewQueryExecutor()
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			if n := atomic.AddInt32(&running, 1); n != 1 {
+				t.Errorf("statements executed concurrently with a write: %d", n)
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		},
+	}
+	e.MaxConcurrentStatements = 2
+
+	discardOutput(e.ExecuteQuery(q, "mydb", 100, false, nil))
+}
+
 func discardOutput(results <-chan *influxql.Result) {
 	for range results {
 		// Read all results and discard.
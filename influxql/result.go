@@ -62,6 +62,9 @@ type Result struct {
 	Series      models.Rows
 	Messages    []*Message
 	Err         error
+
+	// Partial is set if more results follow for the same statement.
+	Partial bool
 }
 
 // MarshalJSON encodes the result into JSON.
@@ -70,12 +73,14 @@ func (r *Result) MarshalJSON() ([]byte, error) {
 	var o struct {
 		Series   []*models.Row `json:"series,omitempty"`
 		Messages []*Message    `json:"messages,omitempty"`
+		Partial  bool          `json:"partial,omitempty"`
 		Err      string        `json:"error,omitempty"`
 	}
 
 	// Copy fields to output struct.
 	o.Series = r.Series
 	o.Messages = r.Messages
+	o.Partial = r.Partial
 	if r.Err != nil {
 		o.Err = r.Err.Error()
 	}
@@ -88,6 +93,7 @@ func (r *Result) UnmarshalJSON(b []byte) error {
 	var o struct {
 		Series   []*models.Row `json:"series,omitempty"`
 		Messages []*Message    `json:"messages,omitempty"`
+		Partial  bool          `json:"partial,omitempty"`
 		Err      string        `json:"error,omitempty"`
 	}
 
@@ -97,6 +103,7 @@ func (r *Result) UnmarshalJSON(b []byte) error {
 	}
 	r.Series = o.Series
 	r.Messages = o.Messages
+	r.Partial = o.Partial
 	if o.Err != "" {
 		r.Err = errors.New(o.Err)
 	}
@@ -32,7 +32,7 @@ func (s *Scanner) Scan() (tok Token, pos Pos, lit string) {
 		return s.scanWhitespace()
 	} else if isLetter(ch0) || ch0 == '_' {
 		s.r.unread()
-		return s.scanIdent()
+		return s.scanIdent(true)
 	} else if isDigit(ch0) {
 		return s.scanNumber()
 	}
@@ -43,7 +43,7 @@ func (s *Scanner) Scan() (tok Token, pos Pos, lit string) {
 		return EOF, pos, ""
 	case '"':
 		s.r.unread()
-		return s.scanIdent()
+		return s.scanIdent(true)
 	case '\'':
 		return s.scanString()
 	case '.':
@@ -96,6 +96,12 @@ func (s *Scanner) Scan() (tok Token, pos Pos, lit string) {
 		return SEMICOLON, pos, ""
 	case ':':
 		return COLON, pos, ""
+	case '$':
+		if tok, _, lit := s.scanIdent(false); tok == IDENT && lit != "" {
+			return BOUNDPARAM, pos, "$" + lit
+		} else if tok == BADSTRING || tok == BADESCAPE {
+			return tok, pos, lit
+		}
 	}
 
 	return ILLEGAL, pos, string(ch0)
@@ -125,7 +131,8 @@ func (s *Scanner) scanWhitespace() (tok Token, pos Pos, lit string) {
 	return WS, pos, buf.String()
 }
 
-func (s *Scanner) scanIdent() (tok Token, pos Pos, lit string) {
+// scanIdent consumes an identifier or, if lookup is true, a keyword.
+func (s *Scanner) scanIdent(lookup bool) (tok Token, pos Pos, lit string) {
 	// Save the starting position of the identifier.
 	_, pos = s.r.read()
 	s.r.unread()
@@ -151,8 +158,10 @@ func (s *Scanner) scanIdent() (tok Token, pos Pos, lit string) {
 	lit = buf.String()
 
 	// If the literal matches a keyword then return that keyword.
-	if tok = Lookup(lit); tok != IDENT {
-		return tok, pos, ""
+	if lookup {
+		if tok = Lookup(lit); tok != IDENT {
+			return tok, pos, ""
+		}
 	}
 
 	return IDENT, pos, lit
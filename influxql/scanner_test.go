@@ -69,6 +69,12 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `test"`, tok: influxql.BADSTRING, lit: "", pos: influxql.Pos{Line: 0, Char: 3}},
 		{s: `"test`, tok: influxql.BADSTRING, lit: `test`},
 
+		// Bound parameters
+		{s: `$host`, tok: influxql.BOUNDPARAM, lit: `$host`},
+		{s: `$"my param"`, tok: influxql.BOUNDPARAM, lit: `$my param`},
+		{s: `$limit`, tok: influxql.BOUNDPARAM, lit: `$limit`},
+		{s: `$`, tok: influxql.ILLEGAL, lit: `$`},
+
 		{s: `true`, tok: influxql.TRUE},
 		{s: `false`, tok: influxql.FALSE},
 
@@ -3,6 +3,7 @@ package influxql
 import (
 	"errors"
 	"fmt"
+	"math"
 	"sort"
 	"time"
 )
@@ -18,6 +19,9 @@ type SelectOptions struct {
 	// An optional channel that, if closed, signals that the select should be
 	// interrupted.
 	InterruptCh <-chan struct{}
+
+	// An optional budget of the memory used by the iterators of the select.
+	MemoryBudget *MemoryBudget
 }
 
 // Select executes stmt against ic and returns a list of iterators to stream from.
@@ -31,6 +35,11 @@ func Select(stmt *SelectStatement, ic IteratorCreator, sopt *SelectOptions) ([]I
 		return nil, err
 	}
 
+	// Select the series of SLIMIT and SOFFSET before creating any iterators.
+	if opt, err = limitSeries(ic, opt); err != nil {
+		return nil, err
+	}
+
 	// Retrieve refs for each call and var ref.
 	info := newSelectInfo(stmt)
 	if len(info.calls) > 1 && len(info.refs) > 0 {
@@ -38,11 +47,11 @@ func Select(stmt *SelectStatement, ic IteratorCreator, sopt *SelectOptions) ([]I
 	}
 
 	// Determine auxiliary fields to be selected.
-	opt.Aux = make([]string, 0, len(info.refs))
+	opt.Aux = make([]VarRef, 0, len(info.refs))
 	for ref := range info.refs {
-		opt.Aux = append(opt.Aux, ref.Val)
+		opt.Aux = append(opt.Aux, *ref)
 	}
-	sort.Strings(opt.Aux)
+	sort.Sort(VarRefs(opt.Aux))
 
 	// If there are multiple auxilary fields and no calls then construct an aux iterator.
 	if len(info.calls) == 0 && len(info.refs) > 0 {
@@ -55,7 +64,7 @@ func Select(stmt *SelectStatement, ic IteratorCreator, sopt *SelectOptions) ([]I
 		if call.Name == "top" || call.Name == "bottom" {
 			for i := 1; i < len(call.Args)-1; i++ {
 				ref := call.Args[i].(*VarRef)
-				opt.Aux = append(opt.Aux, ref.Val)
+				opt.Aux = append(opt.Aux, *ref)
 				extraFields++
 			}
 		}
@@ -92,6 +101,166 @@ func Select(stmt *SelectStatement, ic IteratorCreator, sopt *SelectOptions) ([]I
 	return buildFieldIterators(fields, ic, opt, selector)
 }
 
+// limitSeries applies SLIMIT and SOFFSET to the series of every iterator
+// creator in ic at once and returns opt with a condition matching only the
+// selected series, so cursors are only created for those series. The limit
+// and offset are applied to the series of each measurement separately.
+//
+// The series of a subquery or a system source are limited by the source.
+func limitSeries(ic IteratorCreator, opt IteratorOptions) (IteratorOptions, error) {
+	if opt.SLimit == 0 && opt.SOffset == 0 {
+		return opt, nil
+	} else if Sources(opt.Sources).HasSystemSource() {
+		return opt, nil
+	}
+	for _, src := range opt.Sources {
+		if _, ok := src.(*Measurement); !ok {
+			return opt, nil
+		}
+	}
+
+	seriesOpt := opt
+	seriesOpt.Aux = nil
+	seriesOpt.SLimit, seriesOpt.SOffset = 0, 0
+	series, err := ic.SeriesKeys(seriesOpt)
+	if err != nil {
+		return opt, err
+	}
+	sort.Sort(series)
+
+	// Match the selected series by measurement name and dimensions. The
+	// series are sorted by name so the series of each name are adjacent.
+	var cond Expr
+	for i := 0; i < len(series); {
+		j := i + 1
+		for j < len(series) && series[j].Name == series[i].Name {
+			j++
+		}
+
+		selected := series[i:j]
+		if opt.SOffset < len(selected) {
+			selected = selected[opt.SOffset:]
+		} else {
+			selected = nil
+		}
+		if opt.SLimit > 0 && opt.SLimit < len(selected) {
+			selected = selected[:opt.SLimit]
+		}
+
+		for _, s := range selected {
+			var expr Expr = &BinaryExpr{
+				Op:  EQ,
+				LHS: &VarRef{Val: "_name"},
+				RHS: &StringLiteral{Val: s.Name},
+			}
+			for _, d := range opt.Dimensions {
+				expr = &BinaryExpr{
+					Op:  AND,
+					LHS: expr,
+					RHS: &BinaryExpr{Op: EQ, LHS: &VarRef{Val: d}, RHS: &StringLiteral{Val: s.Tags.Value(d)}},
+				}
+			}
+
+			if cond == nil {
+				cond = expr
+			} else {
+				cond = &BinaryExpr{Op: OR, LHS: cond, RHS: expr}
+			}
+		}
+		i = j
+	}
+
+	// No measurement has a series left so match an empty name.
+	if cond == nil {
+		cond = &BinaryExpr{Op: EQ, LHS: &VarRef{Val: "_name"}, RHS: &StringLiteral{}}
+	}
+
+	if opt.Condition != nil {
+		cond = &BinaryExpr{Op: AND, LHS: &ParenExpr{Expr: opt.Condition}, RHS: &ParenExpr{Expr: cond}}
+	}
+	opt.Condition = cond
+	opt.SLimit, opt.SOffset = 0, 0
+	return opt, nil
+}
+
+// IteratorPlan describes an iterator that a SELECT statement creates from an
+// IteratorCreator along with its estimated cost.
+type IteratorPlan struct {
+	Expr Expr     // expression computed by the iterator, nil for raw fields
+	Aux  []VarRef // auxiliary fields read by the iterator
+	Cost IteratorCost
+}
+
+// Explain returns a plan for each iterator that Select creates from ic for
+// stmt. The IteratorCreator must implement IteratorCoster.
+func Explain(stmt *SelectStatement, ic IteratorCreator, sopt *SelectOptions) ([]IteratorPlan, error) {
+	coster, ok := ic.(IteratorCoster)
+	if !ok {
+		return nil, errors.New("unable to estimate the cost of the query sources")
+	}
+
+	// Determine base options for iterators.
+	opt, err := newIteratorOptionsStmt(stmt, sopt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Select the series of SLIMIT and SOFFSET.
+	if opt, err = limitSeries(ic, opt); err != nil {
+		return nil, err
+	}
+
+	// Determine auxiliary fields to be selected.
+	info := newSelectInfo(stmt)
+	opt.Aux = make([]VarRef, 0, len(info.refs))
+	for ref := range info.refs {
+		opt.Aux = append(opt.Aux, *ref)
+	}
+	sort.Sort(VarRefs(opt.Aux))
+
+	// Determine the expressions created by the iterator creator. Nested calls
+	// are computed from the innermost call so only that one is created.
+	var exprs []Expr
+	if len(info.calls) == 0 {
+		exprs = append(exprs, nil)
+	} else {
+		m := make(map[string]Expr, len(info.calls))
+		for call := range info.calls {
+			expr := call
+			for len(expr.Args) > 0 {
+				inner, ok := expr.Args[0].(*Call)
+				if !ok {
+					break
+				}
+				expr = inner
+			}
+			m[expr.String()] = expr
+		}
+
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			exprs = append(exprs, m[k])
+		}
+	}
+
+	plans := make([]IteratorPlan, 0, len(exprs))
+	for _, expr := range exprs {
+		itrOpt := opt
+		itrOpt.Expr = expr
+
+		cost, err := coster.IteratorCost(itrOpt)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, IteratorPlan{Expr: expr, Aux: opt.Aux, Cost: cost})
+	}
+	return plans, nil
+}
+
 // buildAuxIterators creates a set of iterators from a single combined auxilary iterator.
 func buildAuxIterators(fields Fields, ic IteratorCreator, opt IteratorOptions) ([]Iterator, error) {
 	// Create iterator to read auxilary fields.
@@ -133,8 +302,8 @@ func buildAuxIterators(fields Fields, ic IteratorCreator, opt IteratorOptions) (
 			expr := Reduce(f.Expr, nil)
 			switch expr := expr.(type) {
 			case *VarRef:
-				itrs[i] = aitr.Iterator(expr.Val)
-			case *BinaryExpr:
+				itrs[i] = aitr.Iterator(expr.Val, expr.Type)
+			case *BinaryExpr, *Call, *CaseExpr:
 				itr, err := buildExprIterator(expr, aitr, opt, false)
 				if err != nil {
 					return fmt.Errorf("error constructing iterator for field '%s': %s", f.String(), err)
@@ -239,27 +408,58 @@ func buildExprIterator(expr Expr, ic IteratorCreator, opt IteratorOptions, selec
 		} else if itr == nil {
 			itr = &nilFloatIterator{}
 		}
+
+		// Cast integers to floats if the reference is qualified with float.
+		if input, ok := itr.(IntegerIterator); ok && expr.Type == Float {
+			itr = &integerFloatCastIterator{input: input}
+		}
 		return itr, nil
 	case *Call:
 		// FIXME(benbjohnson): Validate that only calls with 1 arg are passed to IC.
 
 		switch expr.Name {
 		case "distinct":
-			input, err := buildExprIterator(expr.Args[0].(*VarRef), ic, opt, selector)
+			// Read the values of a tag if the argument is not a field.
+			input, err := buildTagValueIterator(expr.Args[0].(*VarRef), ic, opt)
 			if err != nil {
 				return nil, err
+			} else if input == nil {
+				input, err = buildExprIterator(expr.Args[0].(*VarRef), ic, opt, selector)
+				if err != nil {
+					return nil, err
+				}
 			}
 			input, err = NewDistinctIterator(input, opt)
 			if err != nil {
 				return nil, err
 			}
 			return NewIntervalIterator(input, opt), nil
-		case "derivative", "non_negative_derivative", "difference", "moving_average", "elapsed":
-			if !opt.Interval.IsZero() {
+		case "abs", "ceil", "floor", "round", "ln", "log", "pow", "sqrt", "sin", "cos", "tan":
+			input, err := buildExprIterator(expr.Args[0], ic, opt, selector)
+			if err != nil {
+				return nil, err
+			}
+			return buildMathIterator(input, expr)
+		case "concat", "length", "substr", "lower", "upper":
+			return buildEvalIterator(expr, ic, opt)
+		case "derivative", "non_negative_derivative", "difference", "non_negative_difference", "cumulative_sum", "moving_average", "elapsed",
+			"exponential_moving_average", "double_exponential_moving_average", "kaufmans_adaptive_moving_average":
+			// Read one more interval so the first interval has a previous
+			// point. A running total starts with the first interval instead
+			// and a moving average needs a full window of earlier intervals.
+			// The input may be transformed itself, in which case it widens
+			// the range further for its own input.
+			if !opt.Interval.IsZero() && expr.Name != "cumulative_sum" {
+				n := int64(1)
+				if expr.Name == "moving_average" {
+					if lit := expr.Args[1].(*IntegerLiteral); lit.Val > 1 {
+						n = lit.Val - 1
+					}
+				}
 				if opt.Ascending {
-					opt.StartTime -= int64(opt.Interval.Duration)
+					opt.StartTime -= int64(opt.Interval.Duration) * n
 				} else {
-					opt.EndTime += int64(opt.Interval.Duration)
+					opt.EndTime += int64(opt.Interval.Duration) * n
 				}
 			}
 
@@ -272,25 +472,50 @@ func buildExprIterator(expr Expr, ic IteratorCreator, opt IteratorOptions, selec
 			case "derivative", "non_negative_derivative":
 				interval := opt.DerivativeInterval()
 				isNonNegative := (expr.Name == "non_negative_derivative")
-				return newDerivativeIterator(input, opt, interval, isNonNegative)
+				return newDerivativeIterator(input, opt, interval, isNonNegative, opt.DerivativeGapPolicy())
 			case "elapsed":
 				interval := opt.ElapsedInterval()
 				return newElapsedIterator(input, opt, interval)
-			case "difference":
-				return newDifferenceIterator(input, opt)
+			case "difference", "non_negative_difference":
+				isNonNegative := (expr.Name == "non_negative_difference")
+				return newDifferenceIterator(input, opt, isNonNegative)
+			case "cumulative_sum":
+				return newCumulativeSumIterator(input, opt)
 			case "moving_average":
 				n := expr.Args[1].(*IntegerLiteral)
-				if n.Val > 1 && !opt.Interval.IsZero() {
-					if opt.Ascending {
-						opt.StartTime -= int64(opt.Interval.Duration) * (n.Val - 1)
-					} else {
-						opt.EndTime += int64(opt.Interval.Duration) * (n.Val - 1)
-					}
-				}
 				return newMovingAverageIterator(input, int(n.Val), opt)
+			case "exponential_moving_average", "double_exponential_moving_average", "kaufmans_adaptive_moving_average":
+				n := expr.Args[1].(*IntegerLiteral)
+				return newExponentialMovingAverageIterator(input, expr.Name, int(n.Val), opt)
 			}
 			panic(fmt.Sprintf("invalid series aggregate function: %s", expr.Name))
+		case "holt_winters", "holt_winters_with_fit":
+			input, err := buildExprIterator(expr.Args[0], ic, opt, selector)
+			if err != nil {
+				return nil, err
+			}
+
+			h := expr.Args[1].(*IntegerLiteral)
+			m := expr.Args[2].(*IntegerLiteral)
+			includeFitData := expr.Name == "holt_winters_with_fit"
+			interval := opt.Interval.Duration
+
+			// Reduce every series as a whole, so the model is fitted to all
+			// of the intervals of the series.
+			opt.StartTime, opt.EndTime = MinTime, MaxTime
+			opt.Interval = Interval{}
+			return newHoltWintersIterator(input, opt, int(h.Val), int(m.Val), includeFitData, interval)
 		default:
+			// When fill(previous) looks back across the start of the range,
+			// read the earlier intervals so the fill has a previous value.
+			fillOpt := opt
+			if lookback := opt.FillLookback(); lookback > 0 && opt.Ascending && !opt.Interval.IsZero() {
+				opt.StartTime -= int64(lookback)
+				if opt.StartTime < MinTime {
+					opt.StartTime = MinTime
+				}
+			}
+
 			itr, err := func() (Iterator, error) {
 				switch expr.Name {
 				case "count":
@@ -326,6 +551,20 @@ func buildExprIterator(expr Expr, ic IteratorCreator, opt IteratorOptions, selec
 						return nil, err
 					}
 					return newMedianIterator(input, opt)
+				case "histogram":
+					input, err := buildExprIterator(expr.Args[0].(*VarRef), ic, opt, false)
+					if err != nil {
+						return nil, err
+					}
+					lo, _ := histogramBound(expr.Args[1])
+					hi, _ := histogramBound(expr.Args[2])
+					return newHistogramIterator(input, opt, lo, hi)
+				case "mode":
+					input, err := buildExprIterator(expr.Args[0].(*VarRef), ic, opt, false)
+					if err != nil {
+						return nil, err
+					}
+					return newModeIterator(input, opt)
 				case "stddev":
 					input, err := buildExprIterator(expr.Args[0].(*VarRef), ic, opt, false)
 					if err != nil {
@@ -348,8 +587,8 @@ func buildExprIterator(expr Expr, ic IteratorCreator, opt IteratorOptions, selec
 						// This section is O(n^2), but for what should be a low value.
 						for i := 1; i < len(expr.Args)-1; i++ {
 							ref := expr.Args[i].(*VarRef)
-							for index, name := range opt.Aux {
-								if name == ref.Val {
+							for index, aux := range opt.Aux {
+								if aux == *ref {
 									tags = append(tags, index)
 									break
 								}
@@ -372,8 +611,8 @@ func buildExprIterator(expr Expr, ic IteratorCreator, opt IteratorOptions, selec
 						// This section is O(n^2), but for what should be a low value.
 						for i := 1; i < len(expr.Args)-1; i++ {
 							ref := expr.Args[i].(*VarRef)
-							for index, name := range opt.Aux {
-								if name == ref.Val {
+							for index, aux := range opt.Aux {
+								if aux == *ref {
 									tags = append(tags, index)
 									break
 								}
@@ -400,6 +639,38 @@ func buildExprIterator(expr Expr, ic IteratorCreator, opt IteratorOptions, selec
 						percentile = float64(arg.Val)
 					}
 					return newPercentileIterator(input, opt, percentile)
+				case "integral":
+					input, err := buildExprIterator(expr.Args[0].(*VarRef), ic, opt, false)
+					if err != nil {
+						return nil, err
+					}
+					return newIntegralIterator(input, opt, opt.IntegralInterval())
+				case "interpolated_percentile":
+					input, err := buildExprIterator(expr.Args[0].(*VarRef), ic, opt, false)
+					if err != nil {
+						return nil, err
+					}
+					var percentile float64
+					switch arg := expr.Args[1].(type) {
+					case *NumberLiteral:
+						percentile = arg.Val
+					case *IntegerLiteral:
+						percentile = float64(arg.Val)
+					}
+					return newInterpolatedPercentileIterator(input, opt, percentile)
+				case "rate":
+					input, err := buildExprIterator(expr.Args[0].(*VarRef), ic, opt, false)
+					if err != nil {
+						return nil, err
+					}
+					return newRateIterator(input, opt, opt.RateInterval())
+				case "sample":
+					input, err := buildExprIterator(expr.Args[0].(*VarRef), ic, opt, false)
+					if err != nil {
+						return nil, err
+					}
+					size := expr.Args[1].(*IntegerLiteral)
+					return newSampleIterator(input, opt, int(size.Val))
 				default:
 					return nil, fmt.Errorf("unsupported call: %s", expr.Name)
 				}
@@ -410,11 +681,11 @@ func buildExprIterator(expr Expr, ic IteratorCreator, opt IteratorOptions, selec
 			}
 
 			if !selector || !opt.Interval.IsZero() {
-				if expr.Name != "top" && expr.Name != "bottom" {
+				if expr.Name != "top" && expr.Name != "bottom" && expr.Name != "sample" {
 					itr = NewIntervalIterator(itr, opt)
 				}
 				if !opt.Interval.IsZero() && opt.Fill != NoFill {
-					itr = NewFillIterator(itr, expr, opt)
+					itr = NewFillIterator(itr, expr, fillOpt)
 				}
 			}
 			if opt.InterruptCh != nil {
@@ -456,11 +727,51 @@ func buildExprIterator(expr Expr, ic IteratorCreator, opt IteratorOptions, selec
 		}
 	case *ParenExpr:
 		return buildExprIterator(expr.Expr, ic, opt, selector)
+	case *CaseExpr:
+		return buildEvalIterator(expr, ic, opt)
 	default:
 		return nil, fmt.Errorf("invalid expression type: %T", expr)
 	}
 }
 
+// buildTagValueIterator returns an iterator of the value of the tag ref at
+// the time of every point of the series with the tag. Returns nil if ref is
+// a field or is not a tag of the sources.
+func buildTagValueIterator(ref *VarRef, ic IteratorCreator, opt IteratorOptions) (Iterator, error) {
+	fields, dimensions, err := ic.FieldDimensions(opt.Sources)
+	if err != nil {
+		return nil, err
+	} else if ref.Type != Unknown && ref.Type != Tag {
+		return nil, nil
+	} else if _, ok := fields[ref.Val]; ok && ref.Type != Tag {
+		return nil, nil
+	} else if _, ok := dimensions[ref.Val]; !ok {
+		return nil, nil
+	}
+
+	// Read every field along with the tag so a point is returned for each
+	// time any of the fields has a value.
+	opt.Expr = nil
+	opt.Aux = []VarRef{{Val: ref.Val, Type: Tag}}
+	for _, name := range stringSetSlice(fields) {
+		opt.Aux = append(opt.Aux, VarRef{Val: name})
+	}
+
+	input, err := ic.CreateIterator(opt)
+	if err != nil {
+		return nil, err
+	} else if input == nil {
+		return &nilFloatIterator{}, nil
+	}
+
+	itr, ok := input.(FloatIterator)
+	if !ok {
+		input.Close()
+		return nil, fmt.Errorf("unsupported tag value input iterator type: %T", input)
+	}
+	return &tagValueIterator{input: itr}, nil
+}
+
 func buildRHSTransformIterator(lhs Iterator, rhs Literal, op Token, ic IteratorCreator, opt IteratorOptions) (Iterator, error) {
 	fn := binaryExprFunc(iteratorDataType(lhs), literalDataType(rhs), op)
 	switch fn := fn.(type) {
@@ -865,6 +1176,7 @@ func buildTransformIterator(lhs Iterator, rhs Iterator, op Token, ic IteratorCre
 					return b
 				}
 			},
+			opt: opt,
 		}, nil
 	case func(int64, int64) float64:
 		left, ok := lhs.(IntegerIterator)
@@ -912,6 +1224,7 @@ func buildTransformIterator(lhs Iterator, rhs Iterator, op Token, ic IteratorCre
 				}
 				return p
 			},
+			opt: opt,
 		}, nil
 	case func(int64, int64) int64:
 		left, ok := lhs.(IntegerIterator)
@@ -945,6 +1258,7 @@ func buildTransformIterator(lhs Iterator, rhs Iterator, op Token, ic IteratorCre
 					return b
 				}
 			},
+			opt: opt,
 		}, nil
 	case func(float64, float64) bool:
 		var left FloatIterator
@@ -1003,6 +1317,7 @@ func buildTransformIterator(lhs Iterator, rhs Iterator, op Token, ic IteratorCre
 				}
 				return p
 			},
+			opt: opt,
 		}, nil
 	case func(int64, int64) bool:
 		left, ok := lhs.(IntegerIterator)
@@ -1050,11 +1365,120 @@ func buildTransformIterator(lhs Iterator, rhs Iterator, op Token, ic IteratorCre
 				}
 				return p
 			},
+			opt: opt,
 		}, nil
 	}
 	return nil, fmt.Errorf("unable to construct transform iterator from %T and %T", lhs, rhs)
 }
 
+// buildMathIterator applies the math function in expr to every point of input.
+// Null points are passed through and results that are not a finite number,
+// such as the square root of a negative value, are returned as null.
+func buildMathIterator(input Iterator, expr *Call) (Iterator, error) {
+	fn := mathFunc(expr)
+
+	switch input := input.(type) {
+	case FloatIterator:
+		return &floatTransformIterator{
+			input: input,
+			fn: func(p *FloatPoint) *FloatPoint {
+				if p == nil {
+					return nil
+				} else if p.Nil {
+					return p
+				}
+				p.Value = fn(p.Value)
+				if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+					p.Value, p.Nil = 0, true
+				}
+				return p
+			},
+		}, nil
+	case IntegerIterator:
+		// Rounding an integer is a no-op and the absolute value of an
+		// integer is still an integer so keep the input type for those.
+		switch expr.Name {
+		case "abs", "ceil", "floor", "round":
+			return &integerTransformIterator{
+				input: input,
+				fn: func(p *IntegerPoint) *IntegerPoint {
+					if p == nil {
+						return nil
+					} else if !p.Nil && expr.Name == "abs" && p.Value < 0 {
+						p.Value = -p.Value
+					}
+					return p
+				},
+			}, nil
+		}
+		return &integerFloatTransformIterator{
+			input: input,
+			fn: func(p *IntegerPoint) *FloatPoint {
+				if p == nil {
+					return nil
+				}
+
+				fp := &FloatPoint{
+					Name: p.Name,
+					Tags: p.Tags,
+					Time: p.Time,
+					Aux:  p.Aux,
+				}
+				if p.Nil {
+					fp.Nil = true
+				} else if fp.Value = fn(float64(p.Value)); math.IsNaN(fp.Value) || math.IsInf(fp.Value, 0) {
+					fp.Value, fp.Nil = 0, true
+				}
+				return fp
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported input type for %s(): %T", expr.Name, input)
+	}
+}
+
+// mathFunc returns the function used to compute a math call on a single value.
+func mathFunc(expr *Call) func(float64) float64 {
+	switch expr.Name {
+	case "abs":
+		return math.Abs
+	case "ceil":
+		return math.Ceil
+	case "floor":
+		return math.Floor
+	case "round":
+		return math.Round
+	case "ln":
+		return math.Log
+	case "log":
+		base := math.Log(numberLiteralValue(expr.Args[1]))
+		return func(v float64) float64 { return math.Log(v) / base }
+	case "pow":
+		y := numberLiteralValue(expr.Args[1])
+		return func(v float64) float64 { return math.Pow(v, y) }
+	case "sqrt":
+		return math.Sqrt
+	case "sin":
+		return math.Sin
+	case "cos":
+		return math.Cos
+	case "tan":
+		return math.Tan
+	}
+	panic(fmt.Sprintf("unsupported math function: %s", expr.Name))
+}
+
+// numberLiteralValue returns the value of a number or integer literal as a float.
+func numberLiteralValue(expr Expr) float64 {
+	switch lit := expr.(type) {
+	case *NumberLiteral:
+		return lit.Val
+	case *IntegerLiteral:
+		return float64(lit.Val)
+	}
+	return math.NaN()
+}
+
 func iteratorDataType(itr Iterator) DataType {
 	switch itr.(type) {
 	case FloatIterator:
@@ -140,6 +140,43 @@ func TestSelect_Distinct_String(t *testing.T) {
 	}
 }
 
+// Ensure a SELECT distinct() query can be executed on a tag.
+func TestSelect_Distinct_Tag(t *testing.T) {
+	var ic IteratorCreator
+	ic.FieldDimensionsFn = func(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+		return map[string]struct{}{"value": struct{}{}}, map[string]struct{}{"host": struct{}{}, "region": struct{}{}}, nil
+	}
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		if opt.Expr != nil {
+			t.Fatalf("unexpected expr: %s", opt.Expr)
+		} else if !reflect.DeepEqual(opt.Aux, []influxql.VarRef{{Val: "region", Type: influxql.Tag}, {Val: "value"}}) {
+			t.Fatalf("unexpected aux: %#v", opt.Aux)
+		}
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Aux: []interface{}{"west", float64(20)}},
+			{Name: "cpu", Time: 1 * Second, Aux: []interface{}{"west", float64(19)}},
+			{Name: "cpu", Time: 5 * Second, Aux: []interface{}{nil, float64(10)}},
+			{Name: "cpu", Time: 9 * Second, Aux: []interface{}{"east", float64(19)}},
+			{Name: "cpu", Time: 10 * Second, Aux: []interface{}{"east", float64(2)}},
+			{Name: "cpu", Time: 11 * Second, Aux: []interface{}{"east", float64(2)}},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT distinct(region) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s) fill(none)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected point: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.StringPoint{Name: "cpu", Time: 0 * Second, Value: "west"}},
+		{&influxql.StringPoint{Name: "cpu", Time: 0 * Second, Value: "east"}},
+		{&influxql.StringPoint{Name: "cpu", Time: 10 * Second, Value: "east"}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
 // Ensure a SELECT distinct() query can be executed.
 func TestSelect_Distinct_Boolean(t *testing.T) {
 	var ic IteratorCreator
@@ -993,6 +1030,118 @@ func TestSelect_Fill_Previous_Float(t *testing.T) {
 	}
 }
 
+// Ensure a SELECT query with fill(previous) can use a value from before the range.
+func TestSelect_Fill_Previous_Lookback_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		if opt.StartTime != 30*Second {
+			t.Fatalf("unexpected start time: %d", opt.StartTime)
+		}
+		return influxql.NewCallIterator(&FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 35 * Second, Value: 4},
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 45 * Second, Value: 6},
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 72 * Second, Value: 2},
+			{Name: "cpu", Tags: ParseTags("host=B"), Time: 40 * Second, Value: 1},
+		}}, opt)
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT mean(value) FROM cpu WHERE time >= '1970-01-01T00:01:00Z' AND time < '1970-01-01T00:01:30Z' GROUP BY host, time(10s) fill(previous, 30s)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 60 * Second, Value: 6}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 70 * Second, Value: 2, Aggregated: 1}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 80 * Second, Value: 2}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 60 * Second, Value: 1}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 70 * Second, Value: 1}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 80 * Second, Value: 1}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT query with a time zone aligns intervals to local days
+// across a daylight saving time change.
+func TestSelect_GroupByTime_Location(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return influxql.NewCallIterator(&FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: mustParseTime("2016-03-12T10:00:00-05:00").UnixNano(), Value: 1},
+			{Name: "cpu", Time: mustParseTime("2016-03-14T23:30:00-04:00").UnixNano(), Value: 2},
+		}}, opt)
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT count(value) FROM cpu WHERE time >= '2016-03-12T05:00:00Z' AND time < '2016-03-15T04:00:00Z' GROUP BY time(1d) tz('America/New_York')`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.IntegerPoint{Name: "cpu", Time: mustParseTime("2016-03-12T00:00:00-05:00").UnixNano(), Value: 1, Aggregated: 1}},
+		{&influxql.IntegerPoint{Name: "cpu", Time: mustParseTime("2016-03-13T00:00:00-05:00").UnixNano(), Value: 0}},
+		{&influxql.IntegerPoint{Name: "cpu", Time: mustParseTime("2016-03-14T00:00:00-04:00").UnixNano(), Value: 1, Aggregated: 1}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT query with a fill(linear) statement can be executed.
+func TestSelect_Fill_Linear_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return influxql.NewCallIterator(&FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 12 * Second, Value: 2},
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 42 * Second, Value: 8},
+		}}, opt)
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT mean(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:00Z' GROUP BY host, time(10s) fill(linear)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Nil: true}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 10 * Second, Value: 2, Aggregated: 1}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 20 * Second, Value: 4}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 30 * Second, Value: 6}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 40 * Second, Value: 8, Aggregated: 1}},
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 50 * Second, Nil: true}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT query with a fill(linear) statement truncates interpolated integers.
+func TestSelect_Fill_Linear_Integer(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return influxql.NewCallIterator(&IntegerIterator{Points: []influxql.IntegerPoint{
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 12 * Second, Value: 1},
+			{Name: "cpu", Tags: ParseTags("host=A"), Time: 32 * Second, Value: 4},
+		}}, opt)
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT max(value) FROM cpu WHERE time >= '1970-01-01T00:00:10Z' AND time < '1970-01-01T00:00:40Z' GROUP BY host, time(10s) fill(linear)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 10 * Second, Value: 1, Aggregated: 1}},
+		{&influxql.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 20 * Second, Value: 2}},
+		{&influxql.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 30 * Second, Value: 4, Aggregated: 1}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
 // Ensure a SELECT stddev() query can be executed.
 func TestSelect_Stddev_Float(t *testing.T) {
 	var ic IteratorCreator
@@ -1262,160 +1411,492 @@ func TestSelect_Percentile_Integer(t *testing.T) {
 	}
 }
 
-// Ensure a simple raw SELECT statement can be executed.
-func TestSelect_Raw(t *testing.T) {
-	// Mock two iterators -- one for each value in the query.
+// Ensure a SELECT rate() query can be executed across a counter reset.
+func TestSelect_Rate_Float(t *testing.T) {
 	var ic IteratorCreator
 	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
-		if !reflect.DeepEqual(opt.Aux, []string{"v1", "v2"}) {
-			t.Fatalf("unexpected options: %s", spew.Sdump(opt.Expr))
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 100},
+			{Name: "cpu", Time: 2 * Second, Value: 120},
+			{Name: "cpu", Time: 4 * Second, Value: 10},
+			{Name: "cpu", Time: 8 * Second, Value: 30},
+			{Name: "cpu", Time: 10 * Second, Value: 40},
+			{Name: "cpu", Time: 20 * Second, Value: 50},
+			{Name: "cpu", Time: 25 * Second, Value: 60},
+		}}, nil
+	}
 
-		}
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT rate(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z' GROUP BY time(10s)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 6.25}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 10 * Second, Nil: true}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 20 * Second, Value: 2}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT rate() query can be executed with a unit.
+func TestSelect_Rate_Integer(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &IntegerIterator{Points: []influxql.IntegerPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 100},
+			{Name: "cpu", Time: 30 * Second, Value: 20},
+			{Name: "cpu", Time: 60 * Second, Value: 80},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT rate(value, 1m) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:10:00Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 80}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT histogram() bucket query can be executed.
+func TestSelect_Histogram_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
 		return &FloatIterator{Points: []influxql.FloatPoint{
-			{Time: 0, Aux: []interface{}{float64(1), nil}},
-			{Time: 1, Aux: []interface{}{nil, float64(2)}},
-			{Time: 5, Aux: []interface{}{float64(3), float64(4)}},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: 4},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 1 * Second, Value: 10},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 2 * Second, Value: 9.5},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 3 * Second, Value: 5},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 10 * Second, Value: 1},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 30 * Second, Value: 7},
 		}}, nil
 	}
 
 	// Execute selection.
-	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT v1, v2 FROM cpu`), &ic, nil)
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT histogram(value, 5, 10) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:40Z' GROUP BY time(10s)`), &ic, nil)
 	if err != nil {
 		t.Fatal(err)
 	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
 		t.Fatalf("unexpected error: %s", err)
 	} else if !deep.Equal(a, [][]influxql.Point{
-		{
-			&influxql.FloatPoint{Time: 0, Value: 1},
-			&influxql.FloatPoint{Time: 0, Nil: true},
-		},
-		{
-			&influxql.FloatPoint{Time: 1, Nil: true},
-			&influxql.FloatPoint{Time: 1, Value: 2},
-		},
-		{
-			&influxql.FloatPoint{Time: 5, Value: 3},
-			&influxql.FloatPoint{Time: 5, Value: 4},
-		},
+		{&influxql.IntegerPoint{Name: "cpu", Time: 0 * Second, Value: 2, Aggregated: 4}},
+		{&influxql.IntegerPoint{Name: "cpu", Time: 10 * Second, Value: 0, Aggregated: 1}},
+		{&influxql.IntegerPoint{Name: "cpu", Time: 20 * Second, Value: 0}},
+		{&influxql.IntegerPoint{Name: "cpu", Time: 30 * Second, Value: 1, Aggregated: 1}},
 	}) {
 		t.Fatalf("unexpected points: %s", spew.Sdump(a))
 	}
 }
 
-// Ensure a SELECT binary expr queries can be executed as floats.
-func TestSelect_BinaryExpr_Float(t *testing.T) {
+// Ensure a SELECT interpolated_percentile() query can be executed.
+func TestSelect_InterpolatedPercentile_Float(t *testing.T) {
 	var ic IteratorCreator
 	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
-		makeAuxFields := func(value float64) []interface{} {
-			aux := make([]interface{}, len(opt.Aux))
-			for i := range aux {
-				aux[i] = value
-			}
-			return aux
-		}
 		return &FloatIterator{Points: []influxql.FloatPoint{
-			{Name: "cpu", Time: 0 * Second, Value: 20, Aux: makeAuxFields(20)},
-			{Name: "cpu", Time: 5 * Second, Value: 10, Aux: makeAuxFields(10)},
-			{Name: "cpu", Time: 9 * Second, Value: 19, Aux: makeAuxFields(19)},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: 4},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 1 * Second, Value: 1},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 2 * Second, Value: 3},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 3 * Second, Value: 2},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 10 * Second, Value: 7},
 		}}, nil
 	}
 
-	for _, test := range []struct {
-		Name      string
-		Statement string
-		Points    [][]influxql.Point
-	}{
-		{
-			Name:      "rhs binary add number",
-			Statement: `SELECT value + 2.0 FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 22}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 12}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 21}},
-			},
-		},
-		{
-			Name:      "rhs binary add integer",
-			Statement: `SELECT value + 2 FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 22}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 12}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 21}},
-			},
-		},
-		{
-			Name:      "lhs binary add number",
-			Statement: `SELECT 2.0 + value FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 22}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 12}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 21}},
-			},
-		},
-		{
-			Name:      "lhs binary add integer",
-			Statement: `SELECT 2 + value FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 22}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 12}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 21}},
-			},
-		},
-		{
-			Name:      "two variable binary add",
-			Statement: `SELECT value + value FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
-			},
-		},
-		{
-			Name:      "rhs binary multiply number",
-			Statement: `SELECT value * 2.0 FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
-			},
-		},
-		{
-			Name:      "rhs binary multiply integer",
-			Statement: `SELECT value * 2 FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
-			},
-		},
-		{
-			Name:      "lhs binary multiply number",
-			Statement: `SELECT 2.0 * value FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
-			},
-		},
-		{
-			Name:      "lhs binary multiply integer",
-			Statement: `SELECT 2 * value FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
-			},
-		},
-		{
-			Name:      "two variable binary multiply",
-			Statement: `SELECT value * value FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 400}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 100}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 361}},
-			},
-		},
-		{
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT interpolated_percentile(value, 75) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s) fill(none)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 3.25}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 10 * Second, Value: 7}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT interpolated_percentile() query can be executed on an integer field.
+func TestSelect_InterpolatedPercentile_Integer(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &IntegerIterator{Points: []influxql.IntegerPoint{
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: 40},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 1 * Second, Value: 10},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 2 * Second, Value: 30},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 3 * Second, Value: 20},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT interpolated_percentile(value, 50) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 25}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT mode() query can be executed.
+func TestSelect_Mode_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: 20},
+			{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 1 * Second, Value: 10},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 2 * Second, Value: 10},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 10 * Second, Value: 3},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 11 * Second, Value: 5},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT mode(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s) fill(none)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 10}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 10 * Second, Value: 3}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT mode() query can be executed on a string field.
+func TestSelect_Mode_String(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &StringIterator{Points: []influxql.StringPoint{
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: "ok"},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 1 * Second, Value: "warn"},
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 2 * Second, Value: "warn"},
+			{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 0 * Second, Value: "down"},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT mode(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY host`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.StringPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: "warn"}},
+		{&influxql.StringPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: "down"}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT integral() query can be executed.
+func TestSelect_Integral_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20},
+			{Name: "cpu", Time: 5 * Second, Value: 10},
+			{Name: "cpu", Time: 10 * Second, Value: 0},
+			{Name: "cpu", Time: 12 * Second, Value: 10},
+			{Name: "cpu", Time: 20 * Second, Value: 5},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT integral(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z' GROUP BY time(10s) fill(none)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 75}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 10 * Second, Value: 10}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 20 * Second, Value: 0}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT integral() query can be executed with a unit.
+func TestSelect_Integral_Integer(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &IntegerIterator{Points: []influxql.IntegerPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20},
+			{Name: "cpu", Time: 5 * Second, Value: 10},
+			{Name: "cpu", Time: 10 * Second, Value: 0},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT integral(value, 5s) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 20}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT sample() query can be executed.
+func TestSelect_Sample_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: 20},
+			{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 5 * Second, Value: 10},
+			{Name: "cpu", Tags: ParseTags("region=east,host=A"), Time: 10 * Second, Value: 19},
+			{Name: "cpu", Tags: ParseTags("region=east,host=B"), Time: 15 * Second, Value: 2},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT sample(value, 2) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s) fill(none)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 20}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 10}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 10 * Second, Value: 19}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 15 * Second, Value: 2}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT sample() query can be executed on a string field.
+func TestSelect_Sample_String(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &StringIterator{Points: []influxql.StringPoint{
+			{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: "a"},
+			{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 5 * Second, Value: "b"},
+			{Name: "cpu", Tags: ParseTags("region=east,host=A"), Time: 10 * Second, Value: "c"},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT sample(value, 2) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY host`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.StringPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: "a"}},
+		{&influxql.StringPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 10 * Second, Value: "c"}},
+		{&influxql.StringPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 5 * Second, Value: "b"}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a simple raw SELECT statement can be executed.
+func TestSelect_Raw(t *testing.T) {
+	// Mock two iterators -- one for each value in the query.
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		if !reflect.DeepEqual(opt.Aux, []influxql.VarRef{{Val: "v1"}, {Val: "v2"}}) {
+			t.Fatalf("unexpected options: %s", spew.Sdump(opt.Expr))
+
+		}
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Time: 0, Aux: []interface{}{float64(1), nil}},
+			{Time: 1, Aux: []interface{}{nil, float64(2)}},
+			{Time: 5, Aux: []interface{}{float64(3), float64(4)}},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT v1, v2 FROM cpu`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{
+			&influxql.FloatPoint{Time: 0, Value: 1},
+			&influxql.FloatPoint{Time: 0, Nil: true},
+		},
+		{
+			&influxql.FloatPoint{Time: 1, Nil: true},
+			&influxql.FloatPoint{Time: 1, Value: 2},
+		},
+		{
+			&influxql.FloatPoint{Time: 5, Value: 3},
+			&influxql.FloatPoint{Time: 5, Value: 4},
+		},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure SLIMIT and SOFFSET select the series before the iterators are created.
+func TestSelect_SLimit(t *testing.T) {
+	var ic IteratorCreator
+	ic.SeriesKeysFn = func(opt influxql.IteratorOptions) (influxql.SeriesList, error) {
+		if opt.SLimit != 0 || opt.SOffset != 0 {
+			t.Fatalf("unexpected series limit: %d, offset: %d", opt.SLimit, opt.SOffset)
+		}
+		return influxql.SeriesList{
+			{Name: "cpu", Tags: ParseTags("host=C")},
+			{Name: "cpu", Tags: ParseTags("host=A")},
+			{Name: "cpu", Tags: ParseTags("host=B")},
+		}, nil
+	}
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		if opt.SLimit != 0 || opt.SOffset != 0 {
+			t.Fatalf("unexpected series limit: %d, offset: %d", opt.SLimit, opt.SOffset)
+		} else if s := opt.Condition.String(); s != `(value > 1 AND time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z') AND (_name = 'cpu' AND host = 'B')` {
+			t.Fatalf("unexpected condition: %s", s)
+		}
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 2},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT sum(value) FROM cpu WHERE value > 1 AND time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY host SLIMIT 1 SOFFSET 1`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 2}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure a SELECT binary expr queries can be executed as floats.
+func TestSelect_BinaryExpr_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		makeAuxFields := func(value float64) []interface{} {
+			aux := make([]interface{}, len(opt.Aux))
+			for i := range aux {
+				aux[i] = value
+			}
+			return aux
+		}
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20, Aux: makeAuxFields(20)},
+			{Name: "cpu", Time: 5 * Second, Value: 10, Aux: makeAuxFields(10)},
+			{Name: "cpu", Time: 9 * Second, Value: 19, Aux: makeAuxFields(19)},
+		}}, nil
+	}
+
+	for _, test := range []struct {
+		Name      string
+		Statement string
+		Points    [][]influxql.Point
+	}{
+		{
+			Name:      "rhs binary add number",
+			Statement: `SELECT value + 2.0 FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 22}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 12}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 21}},
+			},
+		},
+		{
+			Name:      "rhs binary add integer",
+			Statement: `SELECT value + 2 FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 22}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 12}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 21}},
+			},
+		},
+		{
+			Name:      "lhs binary add number",
+			Statement: `SELECT 2.0 + value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 22}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 12}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 21}},
+			},
+		},
+		{
+			Name:      "lhs binary add integer",
+			Statement: `SELECT 2 + value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 22}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 12}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 21}},
+			},
+		},
+		{
+			Name:      "two variable binary add",
+			Statement: `SELECT value + value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
+			},
+		},
+		{
+			Name:      "rhs binary multiply number",
+			Statement: `SELECT value * 2.0 FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
+			},
+		},
+		{
+			Name:      "rhs binary multiply integer",
+			Statement: `SELECT value * 2 FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
+			},
+		},
+		{
+			Name:      "lhs binary multiply number",
+			Statement: `SELECT 2.0 * value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
+			},
+		},
+		{
+			Name:      "lhs binary multiply integer",
+			Statement: `SELECT 2 * value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 40}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 20}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 38}},
+			},
+		},
+		{
+			Name:      "two variable binary multiply",
+			Statement: `SELECT value * value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 400}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 100}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 361}},
+			},
+		},
+		{
 			Name:      "rhs binary subtract number",
 			Statement: `SELECT value - 2.0 FROM cpu`,
 			Points: [][]influxql.Point{
@@ -1796,16 +2277,201 @@ func TestSelect_BinaryExpr_Mixed(t *testing.T) {
 	}
 }
 
-// Ensure a SELECT binary expr with nil values can be executed.
-// Nil values may be present when a field is missing from one iterator,
-// but not the other.
-func TestSelect_BinaryExpr_NilValues(t *testing.T) {
+// Ensure a SELECT binary expr with nil values can be executed.
+// Nil values may be present when a field is missing from one iterator,
+// but not the other.
+func TestSelect_BinaryExpr_NilValues(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20, Aux: []interface{}{float64(20), nil}},
+			{Name: "cpu", Time: 5 * Second, Value: 10, Aux: []interface{}{float64(10), float64(15)}},
+			{Name: "cpu", Time: 9 * Second, Value: 19, Aux: []interface{}{nil, int64(5)}},
+		}}, nil
+	}
+
+	for _, test := range []struct {
+		Name      string
+		Statement string
+		Points    [][]influxql.Point
+	}{
+		{
+			Name:      "nil binary add",
+			Statement: `SELECT total + value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 25}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "nil binary subtract",
+			Statement: `SELECT total - value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: -5}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "nil binary multiply",
+			Statement: `SELECT total * value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 150}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "nil binary division",
+			Statement: `SELECT total / value FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: float64(10) / float64(15)}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+	} {
+		itrs, err := influxql.Select(MustParseSelectStatement(test.Statement), &ic, nil)
+		if err != nil {
+			t.Errorf("%s: parse error: %s", test.Name, err)
+		} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.Name, err)
+		} else if !deep.Equal(a, test.Points) {
+			t.Errorf("%s: unexpected points: %s", test.Name, spew.Sdump(a))
+		}
+	}
+}
+
+// Ensure a binary expression of aggregates combines the values by series and
+// time when one side has no value for a series or interval.
+func TestSelect_BinaryExpr_MissingPoints(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		var points []influxql.FloatPoint
+		switch opt.Expr.(*influxql.Call).Args[0].(*influxql.VarRef).Val {
+		case "used":
+			points = []influxql.FloatPoint{
+				{Name: "disk", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 20},
+				{Name: "disk", Tags: ParseTags("host=A"), Time: 20 * Second, Value: 30},
+				{Name: "disk", Tags: ParseTags("host=C"), Time: 10 * Second, Value: 5},
+			}
+		case "total":
+			points = []influxql.FloatPoint{
+				{Name: "disk", Tags: ParseTags("host=A"), Time: 10 * Second, Value: 80},
+				{Name: "disk", Tags: ParseTags("host=A"), Time: 20 * Second, Value: 60},
+				{Name: "disk", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 10},
+				{Name: "disk", Tags: ParseTags("host=C"), Time: 10 * Second, Value: 20},
+			}
+		}
+		return influxql.NewCallIterator(&FloatIterator{Points: points}, opt)
+	}
+
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT sum(used) / sum(total) FROM disk WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z' GROUP BY time(10s), host fill(none)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "disk", Tags: ParseTags("host=A"), Time: 0 * Second, Nil: true, Aggregated: 1}},
+		{&influxql.FloatPoint{Name: "disk", Tags: ParseTags("host=A"), Time: 10 * Second, Nil: true, Aggregated: 1}},
+		{&influxql.FloatPoint{Name: "disk", Tags: ParseTags("host=A"), Time: 20 * Second, Value: 0.5, Aggregated: 1}},
+		{&influxql.FloatPoint{Name: "disk", Tags: ParseTags("host=B"), Time: 0 * Second, Nil: true, Aggregated: 1}},
+		{&influxql.FloatPoint{Name: "disk", Tags: ParseTags("host=C"), Time: 10 * Second, Value: 0.25, Aggregated: 1}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure math functions can be applied to raw fields.
+func TestSelect_Math_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Aux: []interface{}{float64(-4.5)}},
+			{Name: "cpu", Time: 5 * Second, Aux: []interface{}{float64(16)}},
+			{Name: "cpu", Time: 9 * Second, Aux: []interface{}{nil}},
+		}}, nil
+	}
+
+	for _, test := range []struct {
+		Name      string
+		Statement string
+		Points    [][]influxql.Point
+	}{
+		{
+			Name:      "abs",
+			Statement: `SELECT abs(value) FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 4.5}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 16}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "ceil",
+			Statement: `SELECT ceil(value) FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: -4}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 16}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "round",
+			Statement: `SELECT round(value) FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: -5}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 16}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "sqrt of negative",
+			Statement: `SELECT sqrt(value) FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 4}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "log with base",
+			Statement: `SELECT log(value, 2) FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 4}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "pow in binary expression",
+			Statement: `SELECT pow(value, 0.5) * 2 FROM cpu`,
+			Points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 8}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+	} {
+		itrs, err := influxql.Select(MustParseSelectStatement(test.Statement), &ic, nil)
+		if err != nil {
+			t.Errorf("%s: parse error: %s", test.Name, err)
+		} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.Name, err)
+		} else if !deep.Equal(a, test.Points) {
+			t.Errorf("%s: unexpected points: %s", test.Name, spew.Sdump(a))
+		}
+	}
+}
+
+// Ensure math functions keep integers where the result is always an integer.
+func TestSelect_Math_Integer(t *testing.T) {
 	var ic IteratorCreator
 	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
-		return &FloatIterator{Points: []influxql.FloatPoint{
-			{Name: "cpu", Time: 0 * Second, Value: 20, Aux: []interface{}{float64(20), nil}},
-			{Name: "cpu", Time: 5 * Second, Value: 10, Aux: []interface{}{float64(10), float64(15)}},
-			{Name: "cpu", Time: 9 * Second, Value: 19, Aux: []interface{}{nil, int64(5)}},
+		return &IntegerIterator{Points: []influxql.IntegerPoint{
+			{Name: "cpu", Time: 0 * Second, Aux: []interface{}{int64(-9)}},
+			{Name: "cpu", Time: 5 * Second, Aux: []interface{}{int64(4)}},
 		}}, nil
 	}
 
@@ -1815,39 +2481,19 @@ func TestSelect_BinaryExpr_NilValues(t *testing.T) {
 		Points    [][]influxql.Point
 	}{
 		{
-			Name:      "nil binary add",
-			Statement: `SELECT total + value FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 25}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
-			},
-		},
-		{
-			Name:      "nil binary subtract",
-			Statement: `SELECT total - value FROM cpu`,
-			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: -5}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
-			},
-		},
-		{
-			Name:      "nil binary multiply",
-			Statement: `SELECT total * value FROM cpu`,
+			Name:      "abs",
+			Statement: `SELECT abs(value) FROM cpu`,
 			Points: [][]influxql.Point{
-				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 150}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+				{&influxql.IntegerPoint{Name: "cpu", Time: 0 * Second, Value: 9}},
+				{&influxql.IntegerPoint{Name: "cpu", Time: 5 * Second, Value: 4}},
 			},
 		},
 		{
-			Name:      "nil binary division",
-			Statement: `SELECT total / value FROM cpu`,
+			Name:      "sqrt",
+			Statement: `SELECT sqrt(value) FROM cpu`,
 			Points: [][]influxql.Point{
 				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Nil: true}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: float64(10) / float64(15)}},
-				{&influxql.FloatPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 2}},
 			},
 		},
 	} {
@@ -1862,6 +2508,32 @@ func TestSelect_BinaryExpr_NilValues(t *testing.T) {
 	}
 }
 
+// Ensure math functions can be applied to the result of an aggregate.
+func TestSelect_Math_Aggregate(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return influxql.NewCallIterator(&FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: -20},
+			{Name: "cpu", Time: 5 * Second, Value: -10},
+			{Name: "cpu", Time: 20 * Second, Value: 3},
+		}}, opt)
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT abs(mean(value)) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z' GROUP BY time(10s)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 15, Aggregated: 2}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 10 * Second, Nil: true}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 20 * Second, Value: 3, Aggregated: 1}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
 // Ensure a SELECT (...) query can be executed.
 func TestSelect_ParenExpr(t *testing.T) {
 	var ic IteratorCreator
@@ -2027,6 +2699,78 @@ func TestSelect_Derivative_Desc_Integer(t *testing.T) {
 	}
 }
 
+// Ensure a derivative handles gaps longer than its unit with its gap policy.
+func TestSelect_Derivative_GapPolicy(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		points := []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20},
+			{Name: "cpu", Time: 4 * Second, Value: 10},
+			{Name: "cpu", Time: 16 * Second, Value: 19},
+			{Name: "cpu", Time: 20 * Second, Value: 3},
+		}
+		if !opt.Ascending {
+			for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+				points[i], points[j] = points[j], points[i]
+			}
+		}
+		return &FloatIterator{Points: points}, nil
+	}
+
+	for _, tt := range []struct {
+		s      string
+		points [][]influxql.Point
+	}{
+		{
+			s: `SELECT derivative(value, 4s) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z'`,
+			points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 4 * Second, Value: -10}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 16 * Second, Value: 3}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 20 * Second, Value: -16}},
+			},
+		},
+		{
+			s: `SELECT derivative(value, 4s, 'extrapolate') FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z'`,
+			points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 4 * Second, Value: -10}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 16 * Second, Value: 3}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 20 * Second, Value: -16}},
+			},
+		},
+		{
+			s: `SELECT derivative(value, 4s, 'null') FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z'`,
+			points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 4 * Second, Value: -10}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 16 * Second, Nil: true}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 20 * Second, Value: -16}},
+			},
+		},
+		{
+			s: `SELECT derivative(value, 4s, 'skip') FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z'`,
+			points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 4 * Second, Value: -10}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 20 * Second, Value: -16}},
+			},
+		},
+		{
+			s: `SELECT derivative(value, 4s, 'skip') FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z' ORDER BY desc`,
+			points: [][]influxql.Point{
+				{&influxql.FloatPoint{Name: "cpu", Time: 16 * Second, Value: 16}},
+				{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 10}},
+			},
+		},
+	} {
+		itrs, err := influxql.Select(MustParseSelectStatement(tt.s), &ic, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.s, err)
+		} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.s, err)
+		} else if !deep.Equal(a, tt.points) {
+			t.Fatalf("%s: unexpected points: %s", tt.s, spew.Sdump(a))
+		}
+	}
+}
+
 func TestSelect_Difference_Float(t *testing.T) {
 	var ic IteratorCreator
 	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
@@ -2079,6 +2823,108 @@ func TestSelect_Difference_Integer(t *testing.T) {
 	}
 }
 
+func TestSelect_NonNegativeDifference_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20},
+			{Name: "cpu", Time: 4 * Second, Value: 10},
+			{Name: "cpu", Time: 8 * Second, Value: 19},
+			{Name: "cpu", Time: 12 * Second, Value: 3},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT non_negative_difference(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 9}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+func TestSelect_NonNegativeDifference_Integer(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &IntegerIterator{Points: []influxql.IntegerPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20},
+			{Name: "cpu", Time: 4 * Second, Value: 10},
+			{Name: "cpu", Time: 8 * Second, Value: 19},
+			{Name: "cpu", Time: 12 * Second, Value: 3},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT non_negative_difference(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.IntegerPoint{Name: "cpu", Time: 8 * Second, Value: 9}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+func TestSelect_CumulativeSum_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20},
+			{Name: "cpu", Time: 4 * Second, Value: 10},
+			{Name: "cpu", Time: 8 * Second, Value: 19},
+			{Name: "cpu", Time: 12 * Second, Value: 3},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT cumulative_sum(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 20}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 4 * Second, Value: 30}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 49}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 52}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+func TestSelect_CumulativeSum_Integer(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &IntegerIterator{Points: []influxql.IntegerPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 20},
+			{Name: "cpu", Time: 4 * Second, Value: 10},
+			{Name: "cpu", Time: 8 * Second, Value: 19},
+			{Name: "cpu", Time: 12 * Second, Value: 3},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT cumulative_sum(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.IntegerPoint{Name: "cpu", Time: 0 * Second, Value: 20}},
+		{&influxql.IntegerPoint{Name: "cpu", Time: 4 * Second, Value: 30}},
+		{&influxql.IntegerPoint{Name: "cpu", Time: 8 * Second, Value: 49}},
+		{&influxql.IntegerPoint{Name: "cpu", Time: 12 * Second, Value: 52}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
 func TestSelect_Elapsed_Float(t *testing.T) {
 	var ic IteratorCreator
 	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
@@ -2183,6 +3029,63 @@ func TestSelect_Elapsed_Boolean(t *testing.T) {
 	}
 }
 
+func TestSelect_HoltWinters(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		// A linear series with a missing interval.
+		return influxql.NewCallIterator(&FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 10},
+			{Name: "cpu", Time: 10 * Second, Value: 12},
+			{Name: "cpu", Time: 20 * Second, Value: 14},
+			{Name: "cpu", Time: 40 * Second, Value: 18},
+			{Name: "cpu", Time: 50 * Second, Value: 20},
+		}}, opt)
+	}
+
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT holt_winters(mean(value), 3, 0) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:00Z' GROUP BY time(10s)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 60 * Second, Value: 22}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 70 * Second, Value: 24}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 80 * Second, Value: 26}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+func TestSelect_HoltWintersWithFit(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		// A series with a season of 3 intervals.
+		var points []influxql.FloatPoint
+		for i, v := range []float64{4, 8, 6, 4, 8, 6, 4, 8, 6} {
+			points = append(points, influxql.FloatPoint{Name: "cpu", Time: int64(i) * 10 * Second, Value: v})
+		}
+		return influxql.NewCallIterator(&FloatIterator{Points: points}, opt)
+	}
+
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT holt_winters_with_fit(mean(value), 2, 3) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:30Z' GROUP BY time(10s)`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 30 * Second, Value: 4}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 40 * Second, Value: 8}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 50 * Second, Value: 6}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 60 * Second, Value: 4}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 70 * Second, Value: 8}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 80 * Second, Value: 6}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 90 * Second, Value: 4}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 100 * Second, Value: 8}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
 func TestSelect_MovingAverage_Float(t *testing.T) {
 	var ic IteratorCreator
 	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
@@ -2235,6 +3138,87 @@ func TestSelect_MovingAverage_Integer(t *testing.T) {
 	}
 }
 
+func TestSelect_ExponentialMovingAverage_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 2},
+			{Name: "cpu", Time: 4 * Second, Value: 4},
+			{Name: "cpu", Time: 8 * Second, Value: 6},
+			{Name: "cpu", Time: 12 * Second, Value: 8},
+			{Name: "cpu", Time: 16 * Second, Value: 12},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT exponential_moving_average(value, 3) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:20Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 4}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 6}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 16 * Second, Value: 9}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+func TestSelect_DoubleExponentialMovingAverage_Integer(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &IntegerIterator{Points: []influxql.IntegerPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 2},
+			{Name: "cpu", Time: 4 * Second, Value: 4},
+			{Name: "cpu", Time: 8 * Second, Value: 6},
+			{Name: "cpu", Time: 12 * Second, Value: 8},
+			{Name: "cpu", Time: 16 * Second, Value: 10},
+			{Name: "cpu", Time: 20 * Second, Value: 20},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT double_exponential_moving_average(value, 3) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:24Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 16 * Second, Value: 10}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 20 * Second, Value: 18}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
+func TestSelect_KaufmansAdaptiveMovingAverage_Float(t *testing.T) {
+	var ic IteratorCreator
+	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
+		return &FloatIterator{Points: []influxql.FloatPoint{
+			{Name: "cpu", Time: 0 * Second, Value: 1},
+			{Name: "cpu", Time: 4 * Second, Value: 2},
+			{Name: "cpu", Time: 8 * Second, Value: 3},
+			{Name: "cpu", Time: 12 * Second, Value: 12},
+			{Name: "cpu", Time: 16 * Second, Value: 7},
+		}}, nil
+	}
+
+	// Execute selection.
+	itrs, err := influxql.Select(MustParseSelectStatement(`SELECT kaufmans_adaptive_moving_average(value, 2) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:20Z'`), &ic, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !deep.Equal(a, [][]influxql.Point{
+		{&influxql.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 3}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 7}},
+		{&influxql.FloatPoint{Name: "cpu", Time: 16 * Second, Value: 7}},
+	}) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(a))
+	}
+}
+
 func TestSelect_UnsupportedCall(t *testing.T) {
 	var ic IteratorCreator
 	ic.CreateIteratorFn = func(opt influxql.IteratorOptions) (influxql.Iterator, error) {
@@ -2313,7 +3297,7 @@ func NewRawBenchmarkIteratorCreator(pointN int) *IteratorCreator {
 		}
 
 		for i := range opt.Aux {
-			switch opt.Aux[i] {
+			switch opt.Aux[i].Val {
 			case "fval":
 				p.Aux[i] = float64(100)
 			default:
@@ -20,13 +20,22 @@ func RewriteStatement(stmt Statement) (Statement, error) {
 	}
 }
 
+// rewriteShowFieldKeysStatement rewrites stmt into a SELECT of the _fieldKeys
+// system source.  A field with different types in the shards of the time
+// range is returned once for each type.
 func rewriteShowFieldKeysStatement(stmt *ShowFieldKeysStatement) (Statement, error) {
+	// Only time is supported in the WHERE clause.
+	if stmt.Condition != nil && !OnlyTimeExpr(stmt.Condition) {
+		return nil, errors.New("SHOW FIELD KEYS only supports time in WHERE clause")
+	}
+
 	return &SelectStatement{
 		Fields: Fields([]*Field{
 			{Expr: &VarRef{Val: "fieldKey"}},
+			{Expr: &VarRef{Val: "fieldType"}},
 		}),
 		Sources:    rewriteSources(stmt.Sources, "_fieldKeys"),
-		Condition:  rewriteSourcesCondition(stmt.Sources, nil),
+		Condition:  rewriteSourcesCondition(stmt.Sources, stmt.Condition),
 		Offset:     stmt.Offset,
 		Limit:      stmt.Limit,
 		SortFields: stmt.SortFields,
@@ -62,12 +71,10 @@ func rewriteShowMeasurementsStatement(stmt *ShowMeasurementsStatement) (Statemen
 	}, nil
 }
 
+// rewriteShowSeriesStatement rewrites stmt into a SELECT of the _series system
+// source.  A time condition is kept so only the series with values in the
+// time range are returned.
 func rewriteShowSeriesStatement(stmt *ShowSeriesStatement) (Statement, error) {
-	// Check for time in WHERE clause (not supported).
-	if HasTimeExpr(stmt.Condition) {
-		return nil, errors.New("SHOW SERIES doesn't support time in WHERE clause")
-	}
-
 	return &SelectStatement{
 		Fields: []*Field{
 			{Expr: &VarRef{Val: "key"}},
@@ -82,12 +89,10 @@ func rewriteShowSeriesStatement(stmt *ShowSeriesStatement) (Statement, error) {
 	}, nil
 }
 
+// rewriteShowTagValuesStatement rewrites stmt into a SELECT of the _tags
+// system source.  A time condition is kept so only the values of the series
+// with values in the time range are returned.
 func rewriteShowTagValuesStatement(stmt *ShowTagValuesStatement) (Statement, error) {
-	// Check for time in WHERE clause (not supported).
-	if HasTimeExpr(stmt.Condition) {
-		return nil, errors.New("SHOW TAG VALUES doesn't support time in WHERE clause")
-	}
-
 	condition := stmt.Condition
 	if len(stmt.TagKeys) > 0 {
 		var expr Expr
@@ -0,0 +1,560 @@
+package influxql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// subQueryIteratorCreator creates iterators for a statement selecting from a
+// subquery.  The subquery is executed once and its rows are buffered.  Each
+// column of the subquery is then read as a field and each tag the subquery is
+// grouped by as a tag of the measurements it returns.
+type subQueryIteratorCreator struct {
+	stmt *SelectStatement
+	ic   IteratorCreator
+	opt  SelectOptions
+	exec func() error // loads the rows
+
+	once  sync.Once
+	err   error
+	rows  []subQueryRow
+	index map[string]int      // column name to index in the row values
+	types map[string]DataType // column name to type
+	dims  map[string]struct{} // tags the rows are grouped by
+}
+
+// subQueryRow is a row returned by a subquery.
+type subQueryRow struct {
+	name   string
+	tags   Tags
+	time   int64
+	values []interface{}
+}
+
+// size returns an estimate of the memory used by r.  The name and tags are
+// not counted since they are shared by the rows of a series.
+func (r *subQueryRow) size() int64 {
+	n := int64(unsafe.Sizeof(*r))
+	for _, v := range r.values {
+		n += 16
+		if s, ok := v.(string); ok {
+			n += int64(len(s))
+		}
+	}
+	return n
+}
+
+// NewSubQueryIteratorCreator returns an IteratorCreator that reads the
+// results of stmt selected from ic.  The statement must be rewritten for
+// execution, as for Select.
+func NewSubQueryIteratorCreator(stmt *SelectStatement, ic IteratorCreator, opt SelectOptions) IteratorCreator {
+	s := &subQueryIteratorCreator{stmt: stmt, ic: ic, opt: opt}
+	s.exec = s.execute
+	return s
+}
+
+// load executes the subquery and buffers its rows.
+func (s *subQueryIteratorCreator) load() error {
+	s.once.Do(func() { s.err = s.exec() })
+	return s.err
+}
+
+func (s *subQueryIteratorCreator) execute() error {
+	// The first column holds the time of each row.
+	columns := s.stmt.ColumnNames()[1:]
+	s.index = make(map[string]int, len(columns))
+	s.types = make(map[string]DataType, len(columns))
+	for i, name := range columns {
+		s.index[name] = i
+		s.types[name] = Unknown
+	}
+	s.dims = make(map[string]struct{}, len(s.stmt.Dimensions))
+	for _, d := range s.stmt.Dimensions {
+		if ref, ok := d.Expr.(*VarRef); ok {
+			s.dims[ref.Val] = struct{}{}
+		}
+	}
+
+	return s.readRows(s.stmt, s.ic, func(row *models.Row) error {
+		tags := NewTags(row.Tags)
+		for _, values := range row.Values {
+			for i, v := range values[1:] {
+				s.setType(columns[i], v)
+			}
+
+			r := subQueryRow{
+				name:   row.Name,
+				tags:   tags,
+				time:   values[0].(time.Time).UnixNano(),
+				values: values[1:],
+			}
+
+			// The rows are held until the statement is done so their
+			// memory is released with the budget of the statement.
+			if err := s.opt.MemoryBudget.Reserve(r.size()); err != nil {
+				return err
+			}
+			s.rows = append(s.rows, r)
+		}
+		return nil
+	})
+}
+
+// readRows executes stmt against ic and calls fn with each row it returns.
+func (s *subQueryIteratorCreator) readRows(stmt *SelectStatement, ic IteratorCreator, fn func(row *models.Row) error) error {
+	having, err := stmt.HavingCondition()
+	if err != nil {
+		return err
+	}
+
+	itrs, err := Select(stmt, ic, &s.opt)
+	if err != nil {
+		return err
+	}
+
+	em := NewEmitter(itrs, stmt.TimeAscending(), 0)
+	em.Columns = stmt.ColumnNames()
+	em.Condition = having
+	defer em.Close()
+
+	for {
+		row, err := em.Emit()
+		if err != nil {
+			return err
+		} else if row == nil {
+			return nil
+		} else if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// setType widens the type of a column so it can hold v.  Integer columns
+// with float values are read as floats.
+func (s *subQueryIteratorCreator) setType(name string, v interface{}) {
+	if typ := s.types[name]; typ == Unknown || typ == Integer {
+		if t := valueDataType(v); t != Unknown && (typ == Unknown || t < typ) {
+			s.types[name] = t
+		}
+	}
+}
+
+// valueDataType returns the data type of a value returned by a query.
+func valueDataType(v interface{}) DataType {
+	switch v.(type) {
+	case float64:
+		return Float
+	case int64:
+		return Integer
+	case string:
+		return String
+	case bool:
+		return Boolean
+	default:
+		return Unknown
+	}
+}
+
+// value returns the value of a column or tag in row.
+func (s *subQueryIteratorCreator) value(row *subQueryRow, name string) interface{} {
+	if i, ok := s.index[name]; ok {
+		return row.values[i]
+	} else if v := row.tags.Value(name); v != "" {
+		return v
+	}
+	return nil
+}
+
+// dataType returns the type of a column or tag.
+func (s *subQueryIteratorCreator) dataType(name string) DataType {
+	if typ, ok := s.types[name]; ok {
+		return typ
+	} else if _, ok := s.dims[name]; ok {
+		return String
+	}
+	return Unknown
+}
+
+// CreateIterator returns an iterator reading the rows of the subquery.
+func (s *subQueryIteratorCreator) CreateIterator(opt IteratorOptions) (Iterator, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	var ref *VarRef
+	switch expr := opt.Expr.(type) {
+	case *VarRef:
+		ref = expr
+	case *Call:
+		arg, ok := expr.Args[0].(*VarRef)
+		if !ok {
+			return nil, fmt.Errorf("invalid expression type: %T", expr.Args[0])
+		}
+		ref = arg
+	case nil:
+	default:
+		return nil, fmt.Errorf("invalid expression type: %T", expr)
+	}
+
+	var typ DataType = Float
+	if ref != nil {
+		if typ = s.dataType(ref.Val); typ == Unknown {
+			return nil, nil
+		}
+	}
+
+	rows := s.selectRows(ref, opt)
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	var itr Iterator
+	switch typ {
+	case Float:
+		points := make([]FloatPoint, len(rows))
+		for i, row := range rows {
+			points[i] = FloatPoint{Name: row.name, Tags: row.tags.Subset(opt.Dimensions), Time: row.time, Aux: s.aux(row, opt)}
+			if ref == nil {
+				points[i].Nil = true
+			} else {
+				switch v := s.value(row, ref.Val).(type) {
+				case float64:
+					points[i].Value = v
+				case int64:
+					points[i].Value = float64(v)
+				}
+			}
+		}
+		itr = &floatSliceIterator{points: points}
+	case Integer:
+		points := make([]IntegerPoint, len(rows))
+		for i, row := range rows {
+			v, _ := s.value(row, ref.Val).(int64)
+			points[i] = IntegerPoint{Name: row.name, Tags: row.tags.Subset(opt.Dimensions), Time: row.time, Value: v, Aux: s.aux(row, opt)}
+		}
+		itr = &integerSliceIterator{points: points}
+	case String:
+		points := make([]StringPoint, len(rows))
+		for i, row := range rows {
+			v, _ := s.value(row, ref.Val).(string)
+			points[i] = StringPoint{Name: row.name, Tags: row.tags.Subset(opt.Dimensions), Time: row.time, Value: v, Aux: s.aux(row, opt)}
+		}
+		itr = &stringSliceIterator{points: points}
+	case Boolean:
+		points := make([]BooleanPoint, len(rows))
+		for i, row := range rows {
+			v, _ := s.value(row, ref.Val).(bool)
+			points[i] = BooleanPoint{Name: row.name, Tags: row.tags.Subset(opt.Dimensions), Time: row.time, Value: v, Aux: s.aux(row, opt)}
+		}
+		itr = &booleanSliceIterator{points: points}
+	}
+	setSliceIteratorSeriesN(itr, rows, opt)
+
+	if opt.InterruptCh != nil {
+		itr = NewInterruptIterator(itr, opt.InterruptCh)
+	}
+	if _, ok := opt.Expr.(*Call); ok {
+		return NewCallIterator(itr, opt)
+	}
+	return itr, nil
+}
+
+// selectRows returns the rows of the subquery matching opt, ordered as the
+// iterators of the shards are.  Rows without a value for ref are skipped, as
+// are rows without any of the auxiliary fields of opt when ref is nil.
+func (s *subQueryIteratorCreator) selectRows(ref *VarRef, opt IteratorOptions) []*subQueryRow {
+	// Times are compared against the time range of the options instead.
+	cond := RewriteExpr(CloneExpr(opt.Condition), func(expr Expr) Expr {
+		if expr, ok := expr.(*BinaryExpr); ok && (isTimeRef(expr.LHS) || isTimeRef(expr.RHS)) {
+			return nil
+		}
+		return expr
+	})
+
+	series := make(map[string]struct{})
+	var rows []*subQueryRow
+	for i := range s.rows {
+		row := &s.rows[i]
+		if row.time < opt.StartTime || row.time > opt.EndTime {
+			continue
+		}
+
+		if ref != nil {
+			if s.value(row, ref.Val) == nil {
+				continue
+			}
+		} else if len(opt.Aux) > 0 {
+			found := false
+			for _, ref := range opt.Aux {
+				if _, ok := s.index[ref.Val]; ok && s.value(row, ref.Val) != nil {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		if cond != nil {
+			m := make(map[string]interface{}, len(s.index)+len(row.tags.KeyValues()))
+			for k, v := range row.tags.KeyValues() {
+				m[k] = v
+			}
+			for name, i := range s.index {
+				m[name] = row.values[i]
+			}
+			if !EvalBool(cond, m) {
+				continue
+			}
+		}
+
+		series[subQuerySeriesID(row, opt)] = struct{}{}
+		rows = append(rows, row)
+	}
+
+	// Apply SLIMIT and SOFFSET to the series.
+	if opt.SLimit > 0 || opt.SOffset > 0 {
+		ids := make([]string, 0, len(series))
+		for id := range series {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		if opt.SOffset >= len(ids) {
+			ids = nil
+		} else {
+			ids = ids[opt.SOffset:]
+		}
+		if opt.SLimit > 0 && opt.SLimit < len(ids) {
+			ids = ids[:opt.SLimit]
+		}
+
+		keep := make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			keep[id] = struct{}{}
+		}
+
+		other := rows[:0]
+		for _, row := range rows {
+			if _, ok := keep[subQuerySeriesID(row, opt)]; ok {
+				other = append(other, row)
+			}
+		}
+		rows = other
+	}
+
+	sort.Stable(subQueryRowsByOrder{rows: rows, opt: opt})
+	return rows
+}
+
+// aux returns the auxiliary field values of row.
+func (s *subQueryIteratorCreator) aux(row *subQueryRow, opt IteratorOptions) []interface{} {
+	if len(opt.Aux) == 0 {
+		return nil
+	}
+	aux := make([]interface{}, len(opt.Aux))
+	for i, ref := range opt.Aux {
+		aux[i] = s.value(row, ref.Val)
+	}
+	return aux
+}
+
+// FieldDimensions returns the columns and the tags of the subquery.
+func (s *subQueryIteratorCreator) FieldDimensions(sources Sources) (fields, dimensions map[string]struct{}, err error) {
+	if err := s.load(); err != nil {
+		return nil, nil, err
+	}
+
+	fields = make(map[string]struct{}, len(s.index))
+	for name := range s.index {
+		fields[name] = struct{}{}
+	}
+
+	dimensions = make(map[string]struct{}, len(s.dims))
+	for name := range s.dims {
+		dimensions[name] = struct{}{}
+	}
+	return fields, dimensions, nil
+}
+
+// SeriesKeys returns the series of the subquery matching opt.
+func (s *subQueryIteratorCreator) SeriesKeys(opt IteratorOptions) (SeriesList, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	aux := make([]DataType, len(opt.Aux))
+	for i, ref := range opt.Aux {
+		aux[i] = s.dataType(ref.Val)
+	}
+
+	// Find the series from every row, not only those with a column in Aux, so
+	// tags read as auxiliary fields by top() and bottom() have a type.
+	seen := make(map[string]struct{})
+	var a SeriesList
+	for _, row := range s.selectRows(nil, IteratorOptions{
+		StartTime:  opt.StartTime,
+		EndTime:    opt.EndTime,
+		Dimensions: opt.Dimensions,
+		Condition:  opt.Condition,
+		SLimit:     opt.SLimit,
+		SOffset:    opt.SOffset,
+		Ascending:  opt.Ascending,
+	}) {
+		id := subQuerySeriesID(row, opt)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		a = append(a, Series{Name: row.name, Tags: row.tags.Subset(opt.Dimensions), Aux: aux})
+	}
+	sort.Sort(a)
+	return a, nil
+}
+
+// ExpandSources returns sources unchanged; a subquery has no regex sources.
+func (s *subQueryIteratorCreator) ExpandSources(sources Sources) (Sources, error) {
+	return sources, nil
+}
+
+// isTimeRef returns true if expr is a reference to the time.
+func isTimeRef(expr Expr) bool {
+	ref, ok := expr.(*VarRef)
+	return ok && strings.ToLower(ref.Val) == "time"
+}
+
+// subQuerySeriesID returns the id of the series of row grouped by the
+// dimensions of opt.
+func subQuerySeriesID(row *subQueryRow, opt IteratorOptions) string {
+	tags := row.tags.Subset(opt.Dimensions)
+	return row.name + "\x00" + tags.ID()
+}
+
+// setSliceIteratorSeriesN sets the number of series read by a slice iterator.
+func setSliceIteratorSeriesN(itr Iterator, rows []*subQueryRow, opt IteratorOptions) {
+	series := make(map[string]struct{})
+	for _, row := range rows {
+		series[subQuerySeriesID(row, opt)] = struct{}{}
+	}
+
+	switch itr := itr.(type) {
+	case *floatSliceIterator:
+		itr.stats.SeriesN = len(series)
+	case *integerSliceIterator:
+		itr.stats.SeriesN = len(series)
+	case *stringSliceIterator:
+		itr.stats.SeriesN = len(series)
+	case *booleanSliceIterator:
+		itr.stats.SeriesN = len(series)
+	}
+}
+
+// subQueryRowsByOrder sorts rows in the order the iterators of the shards
+// return points.  Raw points are ordered by series and then time, points to
+// be aggregated by time so the points of each window are read together.
+type subQueryRowsByOrder struct {
+	rows []*subQueryRow
+	opt  IteratorOptions
+}
+
+func (a subQueryRowsByOrder) Len() int      { return len(a.rows) }
+func (a subQueryRowsByOrder) Swap(i, j int) { a.rows[i], a.rows[j] = a.rows[j], a.rows[i] }
+func (a subQueryRowsByOrder) Less(i, j int) bool {
+	x, y := a.rows[i], a.rows[j]
+	if !a.opt.MergeSorted() {
+		xStart, _ := a.opt.Window(x.time)
+		yStart, _ := a.opt.Window(y.time)
+		if xStart != yStart {
+			if a.opt.Ascending {
+				return xStart < yStart
+			}
+			return xStart > yStart
+		}
+	}
+
+	if xID, yID := subQuerySeriesID(x, a.opt), subQuerySeriesID(y, a.opt); xID != yID {
+		return xID < yID
+	} else if a.opt.Ascending {
+		return x.time < y.time
+	}
+	return x.time > y.time
+}
+
+// floatSliceIterator returns float points from a slice.
+type floatSliceIterator struct {
+	points []FloatPoint
+	stats  IteratorStats
+}
+
+func (itr *floatSliceIterator) Stats() IteratorStats { return itr.stats }
+func (itr *floatSliceIterator) Close() error         { itr.points = nil; return nil }
+func (itr *floatSliceIterator) Next() (*FloatPoint, error) {
+	if len(itr.points) == 0 {
+		return nil, nil
+	}
+	p := &itr.points[0]
+	itr.points = itr.points[1:]
+	itr.stats.PointN++
+	return p, nil
+}
+
+// integerSliceIterator returns integer points from a slice.
+type integerSliceIterator struct {
+	points []IntegerPoint
+	stats  IteratorStats
+}
+
+func (itr *integerSliceIterator) Stats() IteratorStats { return itr.stats }
+func (itr *integerSliceIterator) Close() error         { itr.points = nil; return nil }
+func (itr *integerSliceIterator) Next() (*IntegerPoint, error) {
+	if len(itr.points) == 0 {
+		return nil, nil
+	}
+	p := &itr.points[0]
+	itr.points = itr.points[1:]
+	itr.stats.PointN++
+	return p, nil
+}
+
+// stringSliceIterator returns string points from a slice.
+type stringSliceIterator struct {
+	points []StringPoint
+	stats  IteratorStats
+}
+
+func (itr *stringSliceIterator) Stats() IteratorStats { return itr.stats }
+func (itr *stringSliceIterator) Close() error         { itr.points = nil; return nil }
+func (itr *stringSliceIterator) Next() (*StringPoint, error) {
+	if len(itr.points) == 0 {
+		return nil, nil
+	}
+	p := &itr.points[0]
+	itr.points = itr.points[1:]
+	itr.stats.PointN++
+	return p, nil
+}
+
+// booleanSliceIterator returns boolean points from a slice.
+type booleanSliceIterator struct {
+	points []BooleanPoint
+	stats  IteratorStats
+}
+
+func (itr *booleanSliceIterator) Stats() IteratorStats { return itr.stats }
+func (itr *booleanSliceIterator) Close() error         { itr.points = nil; return nil }
+func (itr *booleanSliceIterator) Next() (*BooleanPoint, error) {
+	if len(itr.points) == 0 {
+		return nil, nil
+	}
+	p := &itr.points[0]
+	itr.points = itr.points[1:]
+	itr.stats.PointN++
+	return p, nil
+}
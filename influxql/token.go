@@ -27,6 +27,7 @@ const (
 	FALSE       // false
 	REGEX       // Regular expressions
 	BADREGEX    // `.*
+	BOUNDPARAM  // $param
 	literalEnd
 
 	operatorBeg
@@ -153,6 +154,7 @@ var tokens = [...]string{
 	TRUE:        "TRUE",
 	FALSE:       "FALSE",
 	REGEX:       "REGEX",
+	BOUNDPARAM:  "BOUNDPARAM",
 
 	ADD: "+",
 	SUB: "-",
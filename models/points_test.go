@@ -1717,6 +1717,20 @@ func TestRoundedString(t *testing.T) {
 	}
 }
 
+func TestPoint_StringSize(t *testing.T) {
+	fields := map[string]interface{}{"value": float64(1), "host": "server01"}
+	for _, tm := range []time.Time{
+		time.Time{},
+		time.Unix(0, 946730096789012345),
+		time.Unix(0, -946730096789012345),
+	} {
+		pt := models.MustNewPoint("cpu", models.Tags{"region": "us-west"}, fields, tm)
+		if got, exp := pt.StringSize(), len(pt.String()); got != exp {
+			t.Errorf("%v: StringSize() mismatch: exp %d, got %d", tm, exp, got)
+		}
+	}
+}
+
 func TestParsePointsStringWithExtraBuffer(t *testing.T) {
 	b := make([]byte, 70*5000)
 	buf := bytes.NewBuffer(b)
@@ -12,6 +12,9 @@ type Row struct {
 	Columns []string          `json:"columns,omitempty"`
 	Values  [][]interface{}   `json:"values,omitempty"`
 	Err     error             `json:"err,omitempty"`
+
+	// Partial is set if the values of the series continue in the next row.
+	Partial bool `json:"partial,omitempty"`
 }
 
 // SameSeries returns true if r contains values for the same series as o.
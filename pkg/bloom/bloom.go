@@ -0,0 +1,82 @@
+// Package bloom implements a simple bloom filter of byte slices that can be
+// stored in and read back from a byte slice.
+package bloom // import "github.com/influxdata/influxdb/pkg/bloom"
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// Filter is a bloom filter.
+type Filter struct {
+	b []byte
+	k uint64
+}
+
+// NewFilter returns a new filter with m bits and k hash functions.  m is
+// rounded up to a multiple of 8.
+func NewFilter(m uint64, k uint64) *Filter {
+	return &Filter{b: make([]byte, (m+7)/8), k: k}
+}
+
+// NewFilterBuffer returns a filter using buf as its bits.  buf is not copied.
+func NewFilterBuffer(buf []byte, k uint64) *Filter {
+	return &Filter{b: buf, k: k}
+}
+
+// Estimate returns the number of bits and hash functions needed to store n
+// values with a false positive rate of p.
+func Estimate(n uint64, p float64) (m uint64, k uint64) {
+	if n == 0 {
+		n = 1
+	}
+	m = uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k = uint64(math.Ceil(math.Ln2 * float64(m) / float64(n)))
+	if k == 0 {
+		k = 1
+	}
+	return m, k
+}
+
+// Bytes returns the underlying bits of the filter.
+func (f *Filter) Bytes() []byte { return f.b }
+
+// K returns the number of hash functions used by the filter.
+func (f *Filter) K() uint64 { return f.k }
+
+// Insert adds v to the filter.
+func (f *Filter) Insert(v []byte) {
+	h1, h2 := hash(v)
+	m := uint64(len(f.b)) * 8
+	for i := uint64(0); i < f.k; i++ {
+		loc := (h1 + i*h2) % m
+		f.b[loc/8] |= 1 << (loc % 8)
+	}
+}
+
+// Contains returns true if v may be in the filter and false if it definitely
+// is not.  An empty filter contains everything.
+func (f *Filter) Contains(v []byte) bool {
+	if len(f.b) == 0 {
+		return true
+	}
+
+	h1, h2 := hash(v)
+	m := uint64(len(f.b)) * 8
+	for i := uint64(0); i < f.k; i++ {
+		loc := (h1 + i*h2) % m
+		if f.b[loc/8]&(1<<(loc%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hash returns two hashes of v used to derive the k locations.
+func hash(v []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write(v)
+	h1 := h.Sum64()
+	h2 := (h1 >> 33) | (h1 << 31) | 1
+	return h1, h2
+}
@@ -0,0 +1,45 @@
+package bloom_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/influxdata/influxdb/pkg/bloom"
+)
+
+func TestFilter_Contains(t *testing.T) {
+	m, k := bloom.Estimate(1000, 0.01)
+	f := bloom.NewFilter(m, k)
+	for i := 0; i < 1000; i++ {
+		f.Insert([]byte(fmt.Sprintf("cpu,host=server%d", i)))
+	}
+
+	for i := 0; i < 1000; i++ {
+		if !f.Contains([]byte(fmt.Sprintf("cpu,host=server%d", i))) {
+			t.Fatalf("expected filter to contain server%d", i)
+		}
+	}
+
+	// Allow for a false positive rate well above the requested one.
+	var n int
+	for i := 0; i < 1000; i++ {
+		if f.Contains([]byte(fmt.Sprintf("mem,host=server%d", i))) {
+			n++
+		}
+	}
+	if n > 50 {
+		t.Fatalf("too many false positives: %d", n)
+	}
+
+	// A filter read back from its bytes must answer the same way.
+	other := bloom.NewFilterBuffer(f.Bytes(), f.K())
+	if !other.Contains([]byte("cpu,host=server1")) {
+		t.Fatalf("expected filter buffer to contain server1")
+	}
+}
+
+func TestFilter_Empty(t *testing.T) {
+	if !bloom.NewFilterBuffer(nil, 0).Contains([]byte("cpu")) {
+		t.Fatalf("expected empty filter to contain everything")
+	}
+}
@@ -0,0 +1,16 @@
+// +build windows plan9 solaris
+
+package mmap // import "github.com/influxdata/influxdb/pkg/mmap"
+
+import "io/ioutil"
+
+// Map reads the file at path into memory on platforms where it is not memory
+// mapped.
+func Map(path string) ([]byte, error) {
+	return ioutil.ReadFile(path)
+}
+
+// Unmap releases a slice returned by Map.
+func Unmap(b []byte) error {
+	return nil
+}
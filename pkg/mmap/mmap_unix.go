@@ -0,0 +1,36 @@
+// +build !windows,!plan9,!solaris
+
+// Package mmap provides read-only memory maps of files.
+package mmap // import "github.com/influxdata/influxdb/pkg/mmap"
+
+import (
+	"os"
+	"syscall"
+)
+
+// Map memory maps the file at path for reading.  An empty file returns a nil
+// slice.
+func Map(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	} else if fi.Size() == 0 {
+		return nil, nil
+	}
+
+	return syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// Unmap unmaps a slice returned by Map.
+func Unmap(b []byte) error {
+	if b == nil {
+		return nil
+	}
+	return syscall.Munmap(b)
+}
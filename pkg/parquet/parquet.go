@@ -0,0 +1,380 @@
+// Package parquet implements a minimal writer of Apache Parquet files.  Files
+// have a flat schema of required or optional columns and are written with
+// plain encoding and no compression, which every Parquet reader supports.
+package parquet // import "github.com/influxdata/influxdb/pkg/parquet"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Magic begins and ends every Parquet file.
+const Magic = "PAR1"
+
+// DefaultRowGroupSize is the number of rows buffered before a row group is
+// written.
+const DefaultRowGroupSize = 64 * 1024
+
+// Type is the physical type of a column.
+type Type int32
+
+// Physical column types.
+const (
+	Boolean   Type = 0
+	Int64     Type = 2
+	Double    Type = 5
+	ByteArray Type = 6
+)
+
+// Parquet enum values used in the file metadata.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedUTF8 = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+
+	pageTypeData = 0
+)
+
+// ErrClosed is returned when writing to a closed Writer.
+var ErrClosed = errors.New("parquet: writer closed")
+
+// Column describes a column of a file.
+type Column struct {
+	Name string
+	Type Type
+
+	// Optional columns may hold null values.
+	Optional bool
+
+	// UTF8 annotates a ByteArray column as holding strings.
+	UTF8 bool
+
+	// TimestampNanos annotates an Int64 column as holding nanosecond
+	// timestamps in UTC.
+	TimestampNanos bool
+}
+
+// Writer writes rows to a Parquet file.
+type Writer struct {
+	w      io.Writer
+	n      int64
+	closed bool
+
+	columns []Column
+	buffers []columnBuffer
+
+	// RowGroupSize is the number of rows in each row group.
+	RowGroupSize int
+
+	rows      int
+	numRows   int64
+	rowGroups []rowGroup
+}
+
+type columnBuffer struct {
+	levels []byte
+	values []byte
+	bools  []bool
+}
+
+type rowGroup struct {
+	rows    int
+	size    int64
+	columns []columnChunk
+}
+
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// NewWriter returns a writer of rows with columns to w.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	return &Writer{
+		w:            w,
+		columns:      columns,
+		buffers:      make([]columnBuffer, len(columns)),
+		RowGroupSize: DefaultRowGroupSize,
+	}
+}
+
+// WriteRow writes a row with a value for each column.  Values must be nil for
+// nulls or a bool, int64, float64, string or []byte matching the column type.
+func (w *Writer) WriteRow(values []interface{}) error {
+	if w.closed {
+		return ErrClosed
+	} else if len(values) != len(w.columns) {
+		return fmt.Errorf("parquet: row has %d values, expected %d", len(values), len(w.columns))
+	}
+
+	for i, v := range values {
+		c, buf := &w.columns[i], &w.buffers[i]
+		if v == nil {
+			if !c.Optional {
+				return fmt.Errorf("parquet: null value for required column %s", c.Name)
+			}
+			buf.levels = append(buf.levels, 0)
+			continue
+		}
+
+		switch v := v.(type) {
+		case bool:
+			if c.Type != Boolean {
+				return w.typeError(c, v)
+			}
+			buf.bools = append(buf.bools, v)
+		case int64:
+			if c.Type != Int64 {
+				return w.typeError(c, v)
+			}
+			buf.values = appendUint64(buf.values, uint64(v))
+		case float64:
+			if c.Type != Double {
+				return w.typeError(c, v)
+			}
+			buf.values = appendUint64(buf.values, math.Float64bits(v))
+		case string:
+			if c.Type != ByteArray {
+				return w.typeError(c, v)
+			}
+			buf.values = appendUint32(buf.values, uint32(len(v)))
+			buf.values = append(buf.values, v...)
+		case []byte:
+			if c.Type != ByteArray {
+				return w.typeError(c, v)
+			}
+			buf.values = appendUint32(buf.values, uint32(len(v)))
+			buf.values = append(buf.values, v...)
+		default:
+			return w.typeError(c, v)
+		}
+		buf.levels = append(buf.levels, 1)
+	}
+
+	w.rows++
+	if w.rows >= w.RowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *Writer) typeError(c *Column, v interface{}) error {
+	return fmt.Errorf("parquet: invalid value %T for column %s", v, c.Name)
+}
+
+// Close writes any buffered rows and the file footer.  It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	footer := w.footer()
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	if err := w.write(appendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	return w.write([]byte(Magic))
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return err
+}
+
+// writeMagic writes the leading magic number if nothing has been written.
+func (w *Writer) writeMagic() error {
+	if w.n > 0 {
+		return nil
+	}
+	return w.write([]byte(Magic))
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+
+	rg := rowGroup{rows: w.rows}
+	for i := range w.columns {
+		c, buf := &w.columns[i], &w.buffers[i]
+
+		var page []byte
+		if c.Optional {
+			page = encodeLevels(buf.levels)
+		}
+		if c.Type == Boolean {
+			page = appendBools(page, buf.bools)
+		} else {
+			page = append(page, buf.values...)
+		}
+
+		h := newCompactWriter()
+		h.i32(1, pageTypeData)
+		h.i32(2, int32(len(page)))
+		h.i32(3, int32(len(page)))
+		h.structBegin(5)
+		h.i32(1, int32(w.rows))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.structEnd()
+		header := h.Bytes()
+
+		chunk := columnChunk{offset: w.n, size: int64(len(header) + len(page))}
+		if err := w.write(header); err != nil {
+			return err
+		}
+		if err := w.write(page); err != nil {
+			return err
+		}
+
+		rg.columns = append(rg.columns, chunk)
+		rg.size += chunk.size
+		*buf = columnBuffer{levels: buf.levels[:0], values: buf.values[:0], bools: buf.bools[:0]}
+	}
+
+	w.rowGroups = append(w.rowGroups, rg)
+	w.numRows += int64(w.rows)
+	w.rows = 0
+	return nil
+}
+
+// footer returns the encoded file metadata.
+func (w *Writer) footer() []byte {
+	m := newCompactWriter()
+	m.i32(1, 1)
+
+	m.listBegin(2, thriftStruct, len(w.columns)+1)
+	m.elemBegin()
+	m.binary(4, "schema")
+	m.i32(5, int32(len(w.columns)))
+	m.structEnd()
+	for _, c := range w.columns {
+		m.elemBegin()
+		m.i32(1, int32(c.Type))
+		if c.Optional {
+			m.i32(3, repetitionOptional)
+		} else {
+			m.i32(3, repetitionRequired)
+		}
+		m.binary(4, c.Name)
+		if c.UTF8 {
+			m.i32(6, convertedUTF8)
+		}
+		if c.TimestampNanos {
+			// LogicalType.TIMESTAMP with isAdjustedToUTC and a unit of NANOS.
+			m.structBegin(10)
+			m.structBegin(8)
+			m.bool(1, true)
+			m.structBegin(2)
+			m.structBegin(3)
+			m.structEnd()
+			m.structEnd()
+			m.structEnd()
+			m.structEnd()
+		}
+		m.structEnd()
+	}
+
+	m.i64(3, w.numRows)
+
+	m.listBegin(4, thriftStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		m.elemBegin()
+		m.listBegin(1, thriftStruct, len(rg.columns))
+		for i, chunk := range rg.columns {
+			c := w.columns[i]
+			m.elemBegin()
+			m.i64(2, chunk.offset)
+			m.structBegin(3)
+			m.i32(1, int32(c.Type))
+			m.listI32(2, []int32{encodingPlain, encodingRLE})
+			m.listBinary(3, []string{c.Name})
+			m.i32(4, codecUncompressed)
+			m.i64(5, int64(rg.rows))
+			m.i64(6, chunk.size)
+			m.i64(7, chunk.size)
+			m.i64(9, chunk.offset)
+			m.structEnd()
+			m.structEnd()
+		}
+		m.i64(2, rg.size)
+		m.i64(3, int64(rg.rows))
+		m.structEnd()
+	}
+
+	m.binary(6, "influxdb")
+	return m.Bytes()
+}
+
+// encodeLevels encodes definition levels of 0 or 1 with the run length
+// encoding, prefixed by their 4 byte little endian length.
+func encodeLevels(levels []byte) []byte {
+	b := make([]byte, 4)
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+
+		var buf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(buf[:], uint64(j-i)<<1)
+		b = append(b, buf[:n]...)
+		b = append(b, levels[i])
+		i = j
+	}
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)-4))
+	return b
+}
+
+// appendBools appends bools bit packed with the first value in the least
+// significant bit.
+func appendBools(b []byte, bools []bool) []byte {
+	for i := 0; i < len(bools); i += 8 {
+		var v byte
+		for j := 0; j < 8 && i+j < len(bools); j++ {
+			if bools[i+j] {
+				v |= 1 << uint(j)
+			}
+		}
+		b = append(b, v)
+	}
+	return b
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,157 @@
+package parquet_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb/pkg/parquet"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, []parquet.Column{
+		{Name: "time", Type: parquet.Int64, TimestampNanos: true},
+		{Name: "host", Type: parquet.ByteArray, Optional: true, UTF8: true},
+		{Name: "value", Type: parquet.Double, Optional: true},
+		{Name: "up", Type: parquet.Boolean},
+	})
+	w.RowGroupSize = 2
+
+	rows := [][]interface{}{
+		{int64(1), "serverA", 1.5, true},
+		{int64(2), nil, 2.5, false},
+		{int64(3), "serverB", nil, true},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("unexpected error writing row: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	b := buf.Bytes()
+	if string(b[:4]) != parquet.Magic || string(b[len(b)-4:]) != parquet.Magic {
+		t.Fatal("missing magic number")
+	}
+
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta, _ := decodeStruct(b[len(b)-8-n : len(b)-8])
+
+	if got, exp := meta[3], int64(3); got != exp {
+		t.Fatalf("row count mismatch: got %v, exp %v", got, exp)
+	}
+
+	var names []string
+	for _, e := range meta[2].([]interface{}) {
+		names = append(names, string(e.(map[int16]interface{})[4].([]byte)))
+	}
+	if exp := []string{"schema", "time", "host", "value", "up"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("schema mismatch: got %v, exp %v", names, exp)
+	}
+
+	rowGroups := meta[4].([]interface{})
+	if got, exp := len(rowGroups), 2; got != exp {
+		t.Fatalf("row group count mismatch: got %v, exp %v", got, exp)
+	}
+
+	// Read the value column of the first row group.
+	columns := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+	cmeta := columns[2].(map[int16]interface{})[3].(map[int16]interface{})
+	offset := cmeta[9].(int64)
+
+	header, n := decodeStruct(b[offset:])
+	if got, exp := header[5].(map[int16]interface{})[1], int64(2); got != exp {
+		t.Fatalf("page value count mismatch: got %v, exp %v", got, exp)
+	}
+
+	page := b[int(offset)+n:]
+	page = page[:header[3].(int64)]
+
+	// Both values are defined: one run of two levels of 1.
+	if got, exp := page[:6], []byte{2, 0, 0, 0, 4, 1}; !bytes.Equal(got, exp) {
+		t.Fatalf("definition levels mismatch: got %v, exp %v", got, exp)
+	}
+	for i, exp := range []float64{1.5, 2.5} {
+		if got := math.Float64frombits(binary.LittleEndian.Uint64(page[6+i*8:])); got != exp {
+			t.Fatalf("value mismatch(%d): got %v, exp %v", i, got, exp)
+		}
+	}
+}
+
+func TestWriter_WriteRow_Invalid(t *testing.T) {
+	w := parquet.NewWriter(&bytes.Buffer{}, []parquet.Column{
+		{Name: "time", Type: parquet.Int64},
+	})
+
+	if err := w.WriteRow([]interface{}{nil}); err == nil || err.Error() != "parquet: null value for required column time" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.WriteRow([]interface{}{"now"}); err == nil || err.Error() != "parquet: invalid value string for column time" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.WriteRow([]interface{}{int64(1), int64(2)}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// decodeStruct decodes a Thrift compact protocol struct into a map of field
+// IDs to values and returns the number of bytes read.
+func decodeStruct(b []byte) (map[int16]interface{}, int) {
+	m := make(map[int16]interface{})
+	var pos int
+	var last int16
+	for {
+		h := b[pos]
+		pos++
+		if h == 0 {
+			return m, pos
+		}
+
+		typ := h & 0x0f
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			v, n := binary.Varint(b[pos:])
+			id, pos = int16(v), pos+n
+		}
+		last = id
+
+		var n int
+		m[id], n = decodeValue(b[pos:], typ)
+		pos += n
+	}
+}
+
+func decodeValue(b []byte, typ byte) (interface{}, int) {
+	switch typ {
+	case 1:
+		return true, 0
+	case 2:
+		return false, 0
+	case 5, 6:
+		v, n := binary.Varint(b)
+		return v, n
+	case 8:
+		l, n := binary.Uvarint(b)
+		return b[n : n+int(l)], n + int(l)
+	case 9:
+		size, elem, pos := int(b[0]>>4), b[0]&0x0f, 1
+		if size == 15 {
+			v, n := binary.Uvarint(b[1:])
+			size, pos = int(v), pos+n
+		}
+		var a []interface{}
+		for i := 0; i < size; i++ {
+			v, n := decodeValue(b[pos:], elem)
+			a, pos = append(a, v), pos+n
+		}
+		return a, pos
+	case 12:
+		return decodeStruct(b)
+	}
+	panic("unsupported type")
+}
@@ -0,0 +1,121 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact protocol type IDs.
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftStruct    = 12
+)
+
+// compactWriter encodes structs with the Thrift compact protocol, which is
+// how Parquet stores its page headers and file metadata.
+type compactWriter struct {
+	b []byte
+
+	// last holds the last field ID written in each open struct.
+	last []int16
+}
+
+func newCompactWriter() *compactWriter {
+	return &compactWriter{last: []int16{0}}
+}
+
+// Bytes ends the top level struct and returns the encoded bytes.
+func (w *compactWriter) Bytes() []byte {
+	w.structEnd()
+	return w.b
+}
+
+func (w *compactWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		w.b = append(w.b, byte(d)<<4|typ)
+	} else {
+		w.b = append(w.b, typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (w *compactWriter) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	w.b = append(w.b, buf[:n]...)
+}
+
+func (w *compactWriter) zigzag(v int64) {
+	w.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *compactWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftBoolTrue)
+	} else {
+		w.field(id, thriftBoolFalse)
+	}
+}
+
+func (w *compactWriter) binary(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.uvarint(uint64(len(s)))
+	w.b = append(w.b, s...)
+}
+
+// structBegin begins a struct field.  It must be ended with structEnd.
+func (w *compactWriter) structBegin(id int16) {
+	w.field(id, thriftStruct)
+	w.last = append(w.last, 0)
+}
+
+// elemBegin begins a struct element of a list.  It must be ended with
+// structEnd.
+func (w *compactWriter) elemBegin() {
+	w.last = append(w.last, 0)
+}
+
+func (w *compactWriter) structEnd() {
+	w.b = append(w.b, 0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+// listBegin begins a list field of n elements of typ.
+func (w *compactWriter) listBegin(id int16, typ byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.b = append(w.b, byte(n)<<4|typ)
+	} else {
+		w.b = append(w.b, 0xF0|typ)
+		w.uvarint(uint64(n))
+	}
+}
+
+func (w *compactWriter) listI32(id int16, a []int32) {
+	w.listBegin(id, thriftI32, len(a))
+	for _, v := range a {
+		w.zigzag(int64(v))
+	}
+}
+
+func (w *compactWriter) listBinary(id int16, a []string) {
+	w.listBegin(id, thriftBinary, len(a))
+	for _, s := range a {
+		w.uvarint(uint64(len(s)))
+		w.b = append(w.b, s...)
+	}
+}
@@ -272,6 +272,12 @@ func (s *Service) ExecuteContinuousQuery(dbi *meta.DatabaseInfo, cqi *meta.Conti
 		return nil
 	}
 
+	// Get the group by offset so the time ranges line up with the intervals.
+	offset, err := cq.q.GroupByOffset(nil)
+	if err != nil {
+		return err
+	}
+
 	resampleEvery := interval
 	if cq.Resample.Every != 0 {
 		resampleEvery = cq.Resample.Every
@@ -279,7 +285,7 @@ func (s *Service) ExecuteContinuousQuery(dbi *meta.DatabaseInfo, cqi *meta.Conti
 
 	// We're about to run the query so store the current time closest to the nearest interval.
 	// If all is going well, this time should be the same as nextRun.
-	cq.LastRun = now.Truncate(resampleEvery)
+	cq.LastRun = now.Add(-offset).Truncate(resampleEvery).Add(offset)
 	s.lastRuns[id] = cq.LastRun
 
 	// Retrieve the oldest interval we should calculate based on the next time
@@ -303,7 +309,7 @@ func (s *Service) ExecuteContinuousQuery(dbi *meta.DatabaseInfo, cqi *meta.Conti
 	}
 
 	// Calculate and set the time range for the query. Go from most recent to least.
-	startTime := now.Add(-resampleEvery).Truncate(interval)
+	startTime := now.Add(-resampleEvery - offset).Truncate(interval).Add(offset)
 	for ; !startTime.Before(oldestTime); startTime = startTime.Add(-interval) {
 		endTime := startTime.Add(interval)
 		if err := cq.q.SetTimeRange(startTime, endTime); err != nil {
@@ -334,7 +340,7 @@ func (s *Service) runContinuousQueryAndWriteResult(cq *ContinuousQuery) error {
 	defer close(closing)
 
 	// Execute the SELECT.
-	ch := s.QueryExecutor.ExecuteQuery(q, cq.Database, NoChunkingSize, false, closing)
+	ch := s.QueryExecutor.ExecuteQueryWithSource(q, "continuous query "+cq.Info.Name, cq.Database, NoChunkingSize, false, closing)
 
 	// There is only one statement, so we will only ever receive one result
 	res, ok := <-ch
@@ -229,6 +229,51 @@ func TestContinuousQueryService_EveryHigherThanInterval(t *testing.T) {
 	}
 }
 
+// Test that the time ranges of a CQ line up with a GROUP BY time() offset.
+func TestContinuousQueryService_GroupByOffset(t *testing.T) {
+	s := NewTestService(t)
+	ms := NewMetaClient(t)
+	ms.CreateDatabase("db", "")
+	ms.CreateContinuousQuery("db", "cq", `CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT mean(value) INTO cpu_mean FROM cpu GROUP BY time(1m, 15s) END`)
+	s.MetaClient = ms
+
+	// Set RunInterval high so we can trigger using Run method.
+	s.RunInterval = 10 * time.Minute
+
+	done := make(chan struct{})
+	var min, max time.Time
+
+	// Set a callback for ExecuteStatement.
+	s.QueryExecutor.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+			var err error
+			min, max, err = influxql.TimeRange(stmt.(*influxql.SelectStatement).Condition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			done <- struct{}{}
+			ctx.Results <- &influxql.Result{}
+			return nil
+		},
+	}
+
+	s.Open()
+	defer s.Close()
+
+	// Trigger a run 15 seconds after a 10 minute interval, which is the start
+	// of an offset interval. The previous offset interval should be queried.
+	now := time.Now().Truncate(10 * time.Minute).Add(15 * time.Second)
+	s.RunCh <- &RunRequest{Now: now}
+
+	if err := wait(done, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	} else if exp := now.Add(-time.Minute); !min.Equal(exp) {
+		t.Errorf("unexpected start time: exp=%s got=%s", exp, min)
+	} else if exp := now.Add(-time.Nanosecond); !max.Equal(exp) {
+		t.Errorf("unexpected end time: exp=%s got=%s", exp, max)
+	}
+}
+
 // Test service when not the cluster leader (CQs shouldn't run).
 func TestContinuousQueryService_NotLeader(t *testing.T) {
 	s := NewTestService(t)
@@ -22,6 +22,7 @@ import (
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/services/continuous_querier"
 	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/tsdb"
 	"github.com/influxdata/influxdb/uuid"
 )
 
@@ -75,6 +76,10 @@ type Handler struct {
 
 	ContinuousQuerier continuous_querier.ContinuousQuerier
 
+	TSDBStore interface {
+		Compactions() []tsdb.CompactionStatus
+	}
+
 	Logger         *log.Logger
 	loggingEnabled bool // Log every HTTP access.
 	WriteTrace     bool // Detailed logging of write path
@@ -191,6 +196,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	} else if strings.HasPrefix(r.URL.Path, "/debug/vars") {
 		serveExpvar(w, r)
+	} else if strings.HasPrefix(r.URL.Path, "/debug/compactions") {
+		h.serveCompactions(w, r)
 	} else {
 		h.mux.ServeHTTP(w, r)
 	}
@@ -257,9 +264,37 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user *meta.
 
 	epoch := strings.TrimSpace(r.FormValue("epoch"))
 
+	// Parse the value of now() for the query, if one was given, as either
+	// an RFC3339 time or an int64 nanosecond timestamp.
+	var now time.Time
+	if s := strings.TrimSpace(r.FormValue("now")); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				httpError(w, fmt.Sprintf("invalid now parameter: %q", s), pretty, http.StatusBadRequest)
+				return
+			}
+			t = time.Unix(0, i)
+		}
+		now = t.UTC()
+	}
+
 	p := influxql.NewParser(strings.NewReader(qp))
 	db := r.FormValue("db")
 
+	// Parse the parameters bound to the query.
+	if rawParams := r.FormValue("params"); rawParams != "" {
+		var params map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(rawParams))
+		decoder.UseNumber()
+		if err := decoder.Decode(&params); err != nil {
+			httpError(w, "error parsing query parameters: "+err.Error(), pretty, http.StatusBadRequest)
+			return
+		}
+		p.SetParams(params)
+	}
+
 	// Sanitize the request query params so it doesn't show up in the response logger.
 	// Do this before anything else so a parsing error doesn't leak passwords.
 	sanitize(r)
@@ -318,22 +353,39 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user *meta.
 	w.Header().Add("Connection", "close")
 	w.Header().Add("content-type", "application/json")
 	readonly := r.Method == "GET" || r.Method == "HEAD"
-	results := h.QueryExecutor.ExecuteQuery(query, db, chunkSize, readonly, closing)
+	results := h.QueryExecutor.ExecuteQueryWithOptions(query, influxql.ExecutionOptions{
+		Source:    r.RemoteAddr,
+		Database:  db,
+		ChunkSize: chunkSize,
+		ReadOnly:  readonly,
+		Now:       now,
+	}, closing)
 
 	// if we're not chunking, this will be the in memory buffer for all results before sending to client
 	resp := Response{Results: make([]*influxql.Result, 0)}
 
-	// Status header is OK once this point is reached.
-	w.WriteHeader(http.StatusOK)
-
 	// pull all results from the channel
 	rows := 0
+	wroteHeader := false
 	for r := range results {
 		// Ignore nil results.
 		if r == nil {
 			continue
 		}
 
+		// Status header is OK once the first result is received, unless the
+		// query was rejected because too many queries are running.
+		if !wroteHeader {
+			if r.Err == influxql.ErrServerBusy {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write(MarshalJSON(Response{Err: r.Err}, pretty))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			wroteHeader = true
+		}
+
 		// if requested, convert result timestamps to epoch
 		if epoch != "" {
 			convertToEpoch(r, epoch)
@@ -386,6 +438,7 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user *meta.
 					}
 					// Values are for the same series, so append them.
 					lastSeries.Values = append(lastSeries.Values, row.Values...)
+					lastSeries.Partial = row.Partial
 					rowsMerged++
 				}
 			}
@@ -394,6 +447,7 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user *meta.
 			r.Series = r.Series[rowsMerged:]
 			cr.Series = append(cr.Series, r.Series...)
 			cr.Messages = append(cr.Messages, r.Messages...)
+			cr.Partial = r.Partial
 		} else {
 			resp.Results = append(resp.Results, r)
 		}
@@ -592,6 +646,34 @@ func serveExpvar(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "\n}\n")
 }
 
+// serveCompactions serves the status of the running compactions as JSON.
+func (h *Handler) serveCompactions(w http.ResponseWriter, r *http.Request) {
+	if h.TSDBStore == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	type compaction struct {
+		tsdb.CompactionStatus
+		ETA string `json:"eta"`
+	}
+
+	now := time.Now()
+	a := make([]compaction, 0)
+	for _, c := range h.TSDBStore.Compactions() {
+		a = append(a, compaction{CompactionStatus: c, ETA: c.ETA(now).String()})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	var b []byte
+	if r.FormValue("pretty") == "true" {
+		b, _ = json.MarshalIndent(a, "", "    ")
+	} else {
+		b, _ = json.Marshal(a)
+	}
+	w.Write(b)
+}
+
 // httpError writes an error to the client in a standard format.
 func httpError(w http.ResponseWriter, error string, pretty bool, code int) {
 	w.Header().Add("content-type", "application/json")
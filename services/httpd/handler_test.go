@@ -2,10 +2,12 @@ package httpd_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"regexp"
 	"testing"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/services/httpd"
 	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/tsdb"
 )
 
 // Ensure the handler returns results from a query (including nil results).
@@ -57,6 +60,90 @@ func TestHandler_QueryRegex(t *testing.T) {
 	h.ServeHTTP(w, MustNewRequest("GET", "/query?db=test&q=SELECT%20%2A%20FROM%20test%20WHERE%20url%20%3D~%20%2Fhttp%5C%3A%5C%2F%5C%2Fwww.akamai%5C.com%2F", nil))
 }
 
+// Ensure the handler binds the params of a query.
+func TestHandler_Query_BoundParams(t *testing.T) {
+	h := NewHandler(false)
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+		if stmt.String() != `SELECT value FROM cpu WHERE host = 'server01' AND value > 10` {
+			t.Fatalf("unexpected query: %s", stmt.String())
+		}
+		ctx.Results <- &influxql.Result{StatementID: 1, Series: models.Rows{{Name: "series0"}}}
+		return nil
+	}
+
+	params := url.Values{}
+	params.Set("q", `SELECT value FROM cpu WHERE host = $host AND value > $value`)
+	params.Set("params", `{"host": "server01", "value": 10}`)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?"+params.Encode(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.String() != `{"results":[{"series":[{"name":"series0"}]}]}` {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
+// Ensure the handler returns a status 400 if the params cannot be parsed.
+func TestHandler_Query_ErrInvalidParams(t *testing.T) {
+	h := NewHandler(false)
+
+	params := url.Values{}
+	params.Set("q", `SELECT value FROM cpu WHERE host = $host`)
+	params.Set("params", `{"host":`)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?"+params.Encode(), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.String() != `{"error":"error parsing query parameters: unexpected EOF"}` {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
+// Ensure the handler passes the now parameter to the statements.
+func TestHandler_Query_Now(t *testing.T) {
+	h := NewHandler(false)
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+		if exp := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC); !ctx.Now.Equal(exp) {
+			t.Fatalf("unexpected now: %s", ctx.Now)
+		}
+		ctx.Results <- &influxql.Result{StatementID: 1, Series: models.Rows{{Name: "series0"}}}
+		return nil
+	}
+
+	for _, now := range []string{"2000-01-01T00:00:00Z", "946684800000000000"} {
+		params := url.Values{}
+		params.Set("q", `SELECT value FROM cpu WHERE time > now() - 1h`)
+		params.Set("now", now)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?"+params.Encode(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status: %d", w.Code)
+		} else if w.Body.String() != `{"results":[{"series":[{"name":"series0"}]}]}` {
+			t.Fatalf("unexpected body: %s", w.Body.String())
+		}
+	}
+}
+
+// Ensure the handler returns a status 400 if the now parameter is invalid.
+func TestHandler_Query_ErrInvalidNow(t *testing.T) {
+	h := NewHandler(false)
+
+	params := url.Values{}
+	params.Set("q", `SELECT value FROM cpu`)
+	params.Set("now", `yesterday`)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?"+params.Encode(), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.String() != `{"error":"invalid now parameter: \"yesterday\""}` {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
 // Ensure the handler merges results from the same statement.
 func TestHandler_Query_MergeResults(t *testing.T) {
 	h := NewHandler(false)
@@ -116,6 +203,25 @@ func TestHandler_Query_Chunked(t *testing.T) {
 	}
 }
 
+// Ensure the handler combines partial results when the response is not chunked.
+func TestHandler_Query_MergePartial(t *testing.T) {
+	h := NewHandler(false)
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+		ctx.Results <- &influxql.Result{StatementID: 1, Series: models.Rows([]*models.Row{{Name: "series0", Values: [][]interface{}{{1}}, Partial: true}}), Partial: true}
+		ctx.Results <- &influxql.Result{StatementID: 1, Series: models.Rows([]*models.Row{{Name: "series0", Values: [][]interface{}{{2}}}}), Partial: true}
+		ctx.Results <- &influxql.Result{StatementID: 1, Series: models.Rows([]*models.Row{{Name: "series1", Values: [][]interface{}{{3}}}})}
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?db=foo&q=SELECT+*+FROM+bar", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.String() != `{"results":[{"series":[{"name":"series0","values":[[1],[2]]},{"name":"series1","values":[[3]]}]}]}` {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
 // Ensure the handler returns a status 400 if the query is not passed in.
 func TestHandler_Query_ErrQueryRequired(t *testing.T) {
 	h := NewHandler(false)
@@ -170,6 +276,36 @@ func TestHandler_Query_ErrResult(t *testing.T) {
 	}
 }
 
+// Ensure the handler returns a status 503 if the query is rejected because the server is busy.
+func TestHandler_Query_ErrServerBusy(t *testing.T) {
+	h := NewHandler(false)
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx *influxql.ExecutionContext) error {
+		<-ctx.InterruptCh
+		return influxql.ErrQueryInterrupted
+	}
+	h.QueryExecutor.MaxConcurrentQueries = 1
+
+	// Start a query which runs until the handler is closed.
+	q := &influxql.Query{Statements: influxql.Statements{influxql.MustParseStatement(`SELECT * FROM bin`)}}
+	results := h.QueryExecutor.ExecuteQuery(q, "foo", 0, false, nil)
+	go func() {
+		for range results {
+		}
+	}()
+	defer h.QueryExecutor.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?db=foo&q=SELECT+*+FROM+bin", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header")
+	} else if w.Body.String() != `{"error":"server busy: max concurrent queries reached"}` {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
 // Ensure the handler handles ping requests correctly.
 // TODO: This should be expanded to verify the MetaClient check in servePing is working correctly
 func TestHandler_Ping(t *testing.T) {
@@ -240,6 +376,36 @@ func TestHandler_Status(t *testing.T) {
 	}
 }
 
+// Ensure the handler reports running compactions.
+func TestHandler_Compactions(t *testing.T) {
+	h := NewHandler(false)
+	h.TSDBStore.CompactionsFn = func() []tsdb.CompactionStatus {
+		return []tsdb.CompactionStatus{{
+			ShardID:        1,
+			Level:          2,
+			Files:          []string{"000000001-000000001.tsm"},
+			Start:          time.Unix(0, 0).UTC(),
+			TotalBytes:     100,
+			BytesProcessed: 50,
+		}}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("GET", "/debug/compactions", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	var a []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected compaction count: %d", len(a))
+	} else if a[0]["shardID"] != float64(1) || a[0]["level"] != float64(2) || a[0]["bytesProcessed"] != float64(50) {
+		t.Fatalf("unexpected compaction: %v", a[0])
+	}
+}
+
 // Ensure write endpoint can handle bad requests
 func TestHandler_HandleBadRequestBody(t *testing.T) {
 	b := bytes.NewReader(make([]byte, 10))
@@ -282,6 +448,7 @@ type Handler struct {
 	*httpd.Handler
 	MetaClient        HandlerMetaStore
 	StatementExecutor HandlerStatementExecutor
+	TSDBStore         HandlerTSDBStore
 }
 
 // NewHandler returns a new instance of Handler.
@@ -293,6 +460,7 @@ func NewHandler(requireAuthentication bool) *Handler {
 	h.Handler.MetaClient = &h.MetaClient
 	h.Handler.QueryExecutor = influxql.NewQueryExecutor()
 	h.Handler.QueryExecutor.StatementExecutor = &h.StatementExecutor
+	h.Handler.TSDBStore = &h.TSDBStore
 	h.Handler.Version = "0.0.0"
 	return h
 }
@@ -338,6 +506,15 @@ func (e *HandlerStatementExecutor) NormalizeStatement(stmt influxql.Statement, d
 	return nil
 }
 
+// HandlerTSDBStore is a mock implementation of Handler.TSDBStore.
+type HandlerTSDBStore struct {
+	CompactionsFn func() []tsdb.CompactionStatus
+}
+
+func (s *HandlerTSDBStore) Compactions() []tsdb.CompactionStatus {
+	return s.CompactionsFn()
+}
+
 // MustNewRequest returns a new HTTP request. Panic on error.
 func MustNewRequest(method, urlStr string, body io.Reader) *http.Request {
 	r, err := http.NewRequest(method, urlStr, body)
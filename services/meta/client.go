@@ -864,6 +864,62 @@ func (c *Client) DropContinuousQuery(database, name string) error {
 	return nil
 }
 
+// CreateMeasurementSchema declares the schema of a measurement in a database.
+func (c *Client) CreateMeasurementSchema(database string, msi *MeasurementSchemaInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data := c.cacheData.Clone()
+
+	if err := data.CreateMeasurementSchema(database, msi); err != nil {
+		return err
+	}
+
+	if err := c.commit(data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DropMeasurementSchema removes the schema of a measurement.
+func (c *Client) DropMeasurementSchema(database, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data := c.cacheData.Clone()
+
+	if err := data.DropMeasurementSchema(database, name); err != nil {
+		return err
+	}
+
+	if err := c.commit(data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MeasurementSchemas returns the declared measurement schemas of a database,
+// keyed by measurement name.  It is called for every write so only the
+// schemas are copied, rather than the whole cache.
+func (c *Client) MeasurementSchemas(database string) map[string]*MeasurementSchemaInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	di := c.cacheData.Database(database)
+	if di == nil || len(di.MeasurementSchemas) == 0 {
+		return nil
+	}
+
+	schemas := make(map[string]*MeasurementSchemaInfo, len(di.MeasurementSchemas))
+	for i := range di.MeasurementSchemas {
+		msi := di.MeasurementSchemas[i].clone()
+		schemas[msi.Name] = &msi
+	}
+	return schemas
+}
+
 func (c *Client) CreateSubscription(database, rp, name, mode string, destinations []string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
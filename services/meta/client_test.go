@@ -367,6 +367,68 @@ func TestMetaClient_DropRetentionPolicy(t *testing.T) {
 	}
 }
 
+func TestMetaClient_UpdateRetentionPolicy_MeasurementDurations(t *testing.T) {
+	t.Parallel()
+
+	cfg := newConfig()
+	defer os.RemoveAll(cfg.Dir)
+
+	c := meta.NewClient(cfg)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.CreateDatabase("db0"); err != nil {
+		t.Fatal(err)
+	} else if _, err := c.CreateRetentionPolicy("db0", &meta.RetentionPolicyInfo{
+		Name:     "rp0",
+		Duration: 7 * 24 * time.Hour,
+		ReplicaN: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		d   time.Duration
+		err error
+	}{
+		{d: time.Minute, err: meta.ErrMeasurementDurationTooLow},
+		{d: 7 * 24 * time.Hour, err: meta.ErrMeasurementDurationTooHigh},
+		{d: 24 * time.Hour},
+	} {
+		rpu := &meta.RetentionPolicyUpdate{}
+		rpu.SetMeasurementDuration("cpu", tt.d)
+		if err := c.UpdateRetentionPolicy("db0", "rp0", rpu); err != tt.err {
+			t.Fatalf("%s: got %v, expected %v", tt.d, err, tt.err)
+		}
+	}
+	c.Close()
+
+	// Reopen the client and ensure the override was persisted.
+	c = meta.NewClient(cfg)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if rp, err := c.RetentionPolicy("db0", "rp0"); err != nil {
+		t.Fatal(err)
+	} else if exp := map[string]time.Duration{"cpu": 24 * time.Hour}; !reflect.DeepEqual(rp.MeasurementDurations, exp) {
+		t.Fatalf("unexpected measurement durations: %v", rp.MeasurementDurations)
+	}
+
+	// A zero duration removes the override.
+	rpu := &meta.RetentionPolicyUpdate{}
+	rpu.SetMeasurementDuration("cpu", 0)
+	if err := c.UpdateRetentionPolicy("db0", "rp0", rpu); err != nil {
+		t.Fatal(err)
+	} else if rp, err := c.RetentionPolicy("db0", "rp0"); err != nil {
+		t.Fatal(err)
+	} else if len(rp.MeasurementDurations) != 0 {
+		t.Fatalf("unexpected measurement durations: %v", rp.MeasurementDurations)
+	}
+}
+
 func TestMetaClient_CreateUser(t *testing.T) {
 	t.Parallel()
 
@@ -589,6 +651,97 @@ func TestMetaClient_ContinuousQueries(t *testing.T) {
 	}
 }
 
+func TestMetaClient_MeasurementSchemas(t *testing.T) {
+	t.Parallel()
+
+	cfg := newConfig()
+	defer os.RemoveAll(cfg.Dir)
+
+	c := meta.NewClient(cfg)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.CreateDatabase("db0"); err != nil {
+		t.Fatal(err)
+	}
+
+	msi := &meta.MeasurementSchemaInfo{
+		Name:    "cpu",
+		TagKeys: []string{"host", "region"},
+		Fields:  map[string]influxql.DataType{"value": influxql.Float, "n": influxql.Integer},
+		Strict:  true,
+	}
+	if err := c.CreateMeasurementSchema("db0", msi); err != nil {
+		t.Fatal(err)
+	}
+
+	// Declaring the same schema again should not return an error.
+	if err := c.CreateMeasurementSchema("db0", msi); err != nil {
+		t.Fatalf("got error %q, but didn't expect one", err)
+	}
+
+	// Declaring a different schema should return an error.
+	if err := c.CreateMeasurementSchema("db0", &meta.MeasurementSchemaInfo{Name: "cpu", TagKeys: []string{"host"}}); err != meta.ErrMeasurementSchemaExists {
+		t.Fatalf("got %v, expected %v", err, meta.ErrMeasurementSchemaExists)
+	}
+
+	if err := c.CreateMeasurementSchema("db1", msi); err == nil || err.Error() != influxdb.ErrDatabaseNotFound("db1").Error() {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Close()
+
+	// Reopen the client and ensure the schema was persisted.
+	c = meta.NewClient(cfg)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	schemas := c.MeasurementSchemas("db0")
+	if got := schemas["cpu"]; !reflect.DeepEqual(got, msi) {
+		t.Fatalf("unexpected schema: %#v", got)
+	} else if len(schemas) != 1 {
+		t.Fatalf("unexpected schemas: %#v", schemas)
+	}
+
+	for _, tt := range []struct {
+		tags   map[string]string
+		fields map[string]interface{}
+		err    string
+	}{
+		{tags: map[string]string{"host": "a"}, fields: map[string]interface{}{"value": 1.0}},
+		{tags: map[string]string{"dc": "a"}, fields: map[string]interface{}{"value": 1.0}, err: `tag "dc" is not declared`},
+		{fields: map[string]interface{}{"load": 1.0}, err: `field "load" is not declared`},
+		{fields: map[string]interface{}{"n": 1.0}, err: `field "n" is type float, declared as type integer`},
+	} {
+		if err := schemas["cpu"].CheckPoint(tt.tags, tt.fields); (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("%v %v: unexpected error: %v", tt.tags, tt.fields, err)
+		}
+	}
+
+	// A schema that is not strict only checks the types of declared fields.
+	schemas["cpu"].Strict = false
+	if err := schemas["cpu"].CheckPoint(map[string]string{"dc": "a"}, map[string]interface{}{"load": 1.0}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := schemas["cpu"].CheckPoint(nil, map[string]interface{}{"value": "x"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Changing the returned schema must not change the cached schema.
+	if !c.MeasurementSchemas("db0")["cpu"].Strict {
+		t.Fatal("cached schema was modified")
+	}
+
+	if err := c.DropMeasurementSchema("db0", "cpu"); err != nil {
+		t.Fatal(err)
+	} else if schemas := c.MeasurementSchemas("db0"); schemas != nil {
+		t.Fatalf("unexpected schemas: %#v", schemas)
+	} else if err := c.DropMeasurementSchema("db0", "cpu"); err != meta.ErrMeasurementSchemaNotFound {
+		t.Fatalf("got %v, expected %v", err, meta.ErrMeasurementSchemaNotFound)
+	}
+}
+
 func TestMetaClient_Subscriptions_Create(t *testing.T) {
 	t.Parallel()
 
@@ -192,6 +192,10 @@ type RetentionPolicyUpdate struct {
 	Duration           *time.Duration
 	ReplicaN           *int
 	ShardGroupDuration *time.Duration
+
+	// MeasurementDurations holds the duration overrides to set for individual
+	// measurements.  A duration of zero removes the override.
+	MeasurementDurations map[string]time.Duration
 }
 
 // SetName sets the RetentionPolicyUpdate.Name
@@ -206,6 +210,14 @@ func (rpu *RetentionPolicyUpdate) SetReplicaN(v int) { rpu.ReplicaN = &v }
 // SetShardGroupDuration sets the RetentionPolicyUpdate.ShardGroupDuration
 func (rpu *RetentionPolicyUpdate) SetShardGroupDuration(v time.Duration) { rpu.ShardGroupDuration = &v }
 
+// SetMeasurementDuration sets the duration override of a measurement.
+func (rpu *RetentionPolicyUpdate) SetMeasurementDuration(name string, v time.Duration) {
+	if rpu.MeasurementDurations == nil {
+		rpu.MeasurementDurations = make(map[string]time.Duration)
+	}
+	rpu.MeasurementDurations[name] = v
+}
+
 // UpdateRetentionPolicy updates an existing retention policy.
 func (data *Data) UpdateRetentionPolicy(database, name string, rpu *RetentionPolicyUpdate) error {
 	// Find database.
@@ -230,6 +242,21 @@ func (data *Data) UpdateRetentionPolicy(database, name string, rpu *RetentionPol
 		return ErrRetentionPolicyDurationTooLow
 	}
 
+	// Measurement overrides may only shorten the duration of the policy.
+	duration := rpi.Duration
+	if rpu.Duration != nil {
+		duration = *rpu.Duration
+	}
+	for _, d := range rpu.MeasurementDurations {
+		if d == 0 {
+			continue
+		} else if d < MinRetentionPolicyDuration {
+			return ErrMeasurementDurationTooLow
+		} else if duration != 0 && d >= duration {
+			return ErrMeasurementDurationTooHigh
+		}
+	}
+
 	// Update fields.
 	if rpu.Name != nil {
 		rpi.Name = *rpu.Name
@@ -247,6 +274,17 @@ func (data *Data) UpdateRetentionPolicy(database, name string, rpu *RetentionPol
 		rpi.ShardGroupDuration = shardGroupDuration(rpi.Duration)
 	}
 
+	for name, d := range rpu.MeasurementDurations {
+		if d == 0 {
+			delete(rpi.MeasurementDurations, name)
+			continue
+		}
+		if rpi.MeasurementDurations == nil {
+			rpi.MeasurementDurations = make(map[string]time.Duration)
+		}
+		rpi.MeasurementDurations[name] = d
+	}
+
 	return nil
 }
 
@@ -452,6 +490,42 @@ func (data *Data) DropContinuousQuery(database, name string) error {
 	return ErrContinuousQueryNotFound
 }
 
+// CreateMeasurementSchema declares the schema of a measurement in a database.
+func (data *Data) CreateMeasurementSchema(database string, msi *MeasurementSchemaInfo) error {
+	di := data.Database(database)
+	if di == nil {
+		return influxdb.ErrDatabaseNotFound(database)
+	}
+
+	// Declaring the same schema again is a no-op.  A different schema must
+	// be dropped first.
+	if other := di.MeasurementSchema(msi.Name); other != nil {
+		if other.equal(msi) {
+			return nil
+		}
+		return ErrMeasurementSchemaExists
+	}
+
+	di.MeasurementSchemas = append(di.MeasurementSchemas, msi.clone())
+	return nil
+}
+
+// DropMeasurementSchema removes the schema of a measurement.
+func (data *Data) DropMeasurementSchema(database, name string) error {
+	di := data.Database(database)
+	if di == nil {
+		return influxdb.ErrDatabaseNotFound(database)
+	}
+
+	for i := range di.MeasurementSchemas {
+		if di.MeasurementSchemas[i].Name == name {
+			di.MeasurementSchemas = append(di.MeasurementSchemas[:i], di.MeasurementSchemas[i+1:]...)
+			return nil
+		}
+	}
+	return ErrMeasurementSchemaNotFound
+}
+
 // CreateSubscription adds a named subscription to a database and retention policy.
 func (data *Data) CreateSubscription(database, rp, name, mode string, destinations []string) error {
 	rpi, err := data.RetentionPolicy(database, rp)
@@ -724,6 +798,7 @@ type DatabaseInfo struct {
 	DefaultRetentionPolicy string
 	RetentionPolicies      []RetentionPolicyInfo
 	ContinuousQueries      []ContinuousQueryInfo
+	MeasurementSchemas     []MeasurementSchemaInfo
 }
 
 // RetentionPolicy returns a retention policy by name.
@@ -736,6 +811,16 @@ func (di DatabaseInfo) RetentionPolicy(name string) *RetentionPolicyInfo {
 	return nil
 }
 
+// MeasurementSchema returns the declared schema of a measurement by name.
+func (di DatabaseInfo) MeasurementSchema(name string) *MeasurementSchemaInfo {
+	for i := range di.MeasurementSchemas {
+		if di.MeasurementSchemas[i].Name == name {
+			return &di.MeasurementSchemas[i]
+		}
+	}
+	return nil
+}
+
 // ShardInfos returns a list of all shards' info for the database.
 func (di DatabaseInfo) ShardInfos() []ShardInfo {
 	shards := map[uint64]*ShardInfo{}
@@ -780,6 +865,14 @@ func (di DatabaseInfo) clone() DatabaseInfo {
 		}
 	}
 
+	// Copy measurement schemas.
+	if di.MeasurementSchemas != nil {
+		other.MeasurementSchemas = make([]MeasurementSchemaInfo, len(di.MeasurementSchemas))
+		for i := range di.MeasurementSchemas {
+			other.MeasurementSchemas[i] = di.MeasurementSchemas[i].clone()
+		}
+	}
+
 	return other
 }
 
@@ -798,6 +891,11 @@ func (di DatabaseInfo) marshal() *internal.DatabaseInfo {
 	for i := range di.ContinuousQueries {
 		pb.ContinuousQueries[i] = di.ContinuousQueries[i].marshal()
 	}
+
+	pb.MeasurementSchemas = make([]*internal.MeasurementSchemaInfo, len(di.MeasurementSchemas))
+	for i := range di.MeasurementSchemas {
+		pb.MeasurementSchemas[i] = di.MeasurementSchemas[i].marshal()
+	}
 	return pb
 }
 
@@ -819,6 +917,13 @@ func (di *DatabaseInfo) unmarshal(pb *internal.DatabaseInfo) {
 			di.ContinuousQueries[i].unmarshal(x)
 		}
 	}
+
+	if len(pb.GetMeasurementSchemas()) > 0 {
+		di.MeasurementSchemas = make([]MeasurementSchemaInfo, len(pb.GetMeasurementSchemas()))
+		for i, x := range pb.GetMeasurementSchemas() {
+			di.MeasurementSchemas[i].unmarshal(x)
+		}
+	}
 }
 
 // RetentionPolicyInfo represents metadata about a retention policy.
@@ -829,6 +934,10 @@ type RetentionPolicyInfo struct {
 	ShardGroupDuration time.Duration
 	ShardGroups        []ShardGroupInfo
 	Subscriptions      []SubscriptionInfo
+
+	// MeasurementDurations holds shorter durations for the data of individual
+	// measurements, keyed by measurement name.
+	MeasurementDurations map[string]time.Duration
 }
 
 // NewRetentionPolicyInfo returns a new instance of RetentionPolicyInfo with defaults set.
@@ -894,6 +1003,18 @@ func (rpi *RetentionPolicyInfo) marshal() *internal.RetentionPolicyInfo {
 		pb.Subscriptions[i] = sub.marshal()
 	}
 
+	names := make([]string, 0, len(rpi.MeasurementDurations))
+	for name := range rpi.MeasurementDurations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pb.MeasurementDurations = append(pb.MeasurementDurations, &internal.MeasurementDurationInfo{
+			Name:     proto.String(name),
+			Duration: proto.Int64(int64(rpi.MeasurementDurations[name])),
+		})
+	}
+
 	return pb
 }
 
@@ -916,6 +1037,12 @@ func (rpi *RetentionPolicyInfo) unmarshal(pb *internal.RetentionPolicyInfo) {
 			rpi.Subscriptions[i].unmarshal(x)
 		}
 	}
+	if len(pb.GetMeasurementDurations()) > 0 {
+		rpi.MeasurementDurations = make(map[string]time.Duration, len(pb.GetMeasurementDurations()))
+		for _, x := range pb.GetMeasurementDurations() {
+			rpi.MeasurementDurations[x.GetName()] = time.Duration(x.GetDuration())
+		}
+	}
 }
 
 // clone returns a deep copy of rpi.
@@ -929,6 +1056,13 @@ func (rpi RetentionPolicyInfo) clone() RetentionPolicyInfo {
 		}
 	}
 
+	if rpi.MeasurementDurations != nil {
+		other.MeasurementDurations = make(map[string]time.Duration, len(rpi.MeasurementDurations))
+		for name, d := range rpi.MeasurementDurations {
+			other.MeasurementDurations[name] = d
+		}
+	}
+
 	return other
 }
 
@@ -1204,6 +1338,133 @@ func (cqi *ContinuousQueryInfo) unmarshal(pb *internal.ContinuousQueryInfo) {
 	cqi.Query = pb.GetQuery()
 }
 
+// MeasurementSchemaInfo represents the declared tag keys and field types of a
+// measurement.  Points must use the declared type of a field.  A strict
+// schema also rejects points with tags or fields that are not declared.
+type MeasurementSchemaInfo struct {
+	Name    string
+	TagKeys []string
+	Fields  map[string]influxql.DataType
+	Strict  bool
+}
+
+// CheckPoint returns an error if a point with tags and fields does not
+// conform to the schema.
+func (msi *MeasurementSchemaInfo) CheckPoint(tags map[string]string, fields map[string]interface{}) error {
+	for k, v := range fields {
+		typ, ok := msi.Fields[k]
+		if !ok {
+			if msi.Strict {
+				return fmt.Errorf("field %q is not declared", k)
+			}
+			continue
+		}
+
+		if got := influxql.InspectDataType(v); got != typ {
+			return fmt.Errorf("field %q is type %s, declared as type %s", k, got, typ)
+		}
+	}
+
+	if msi.Strict {
+		for k := range tags {
+			if !msi.hasTagKey(k) {
+				return fmt.Errorf("tag %q is not declared", k)
+			}
+		}
+	}
+	return nil
+}
+
+// hasTagKey returns true if key is a declared tag key.
+func (msi *MeasurementSchemaInfo) hasTagKey(key string) bool {
+	for _, k := range msi.TagKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// equal returns true if other declares the same schema as msi.
+func (msi *MeasurementSchemaInfo) equal(other *MeasurementSchemaInfo) bool {
+	if msi.Name != other.Name || msi.Strict != other.Strict ||
+		len(msi.TagKeys) != len(other.TagKeys) || len(msi.Fields) != len(other.Fields) {
+		return false
+	}
+	for _, k := range msi.TagKeys {
+		if !other.hasTagKey(k) {
+			return false
+		}
+	}
+	for k, typ := range msi.Fields {
+		if t, ok := other.Fields[k]; !ok || t != typ {
+			return false
+		}
+	}
+	return true
+}
+
+// clone returns a deep copy of msi.
+func (msi MeasurementSchemaInfo) clone() MeasurementSchemaInfo {
+	other := msi
+
+	if msi.TagKeys != nil {
+		other.TagKeys = make([]string, len(msi.TagKeys))
+		copy(other.TagKeys, msi.TagKeys)
+	}
+
+	if msi.Fields != nil {
+		other.Fields = make(map[string]influxql.DataType, len(msi.Fields))
+		for k, v := range msi.Fields {
+			other.Fields[k] = v
+		}
+	}
+
+	return other
+}
+
+// marshal serializes to a protobuf representation.
+func (msi MeasurementSchemaInfo) marshal() *internal.MeasurementSchemaInfo {
+	pb := &internal.MeasurementSchemaInfo{
+		Name:   proto.String(msi.Name),
+		Strict: proto.Bool(msi.Strict),
+	}
+
+	pb.TagKeys = make([]string, len(msi.TagKeys))
+	copy(pb.TagKeys, msi.TagKeys)
+
+	names := make([]string, 0, len(msi.Fields))
+	for k := range msi.Fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	pb.Fields = make([]*internal.MeasurementSchemaField, 0, len(names))
+	for _, k := range names {
+		pb.Fields = append(pb.Fields, &internal.MeasurementSchemaField{
+			Name: proto.String(k),
+			Type: proto.Int32(int32(msi.Fields[k])),
+		})
+	}
+	return pb
+}
+
+// unmarshal deserializes from a protobuf representation.
+func (msi *MeasurementSchemaInfo) unmarshal(pb *internal.MeasurementSchemaInfo) {
+	msi.Name = pb.GetName()
+	msi.Strict = pb.GetStrict()
+
+	if len(pb.GetTagKeys()) > 0 {
+		msi.TagKeys = make([]string, len(pb.GetTagKeys()))
+		copy(msi.TagKeys, pb.GetTagKeys())
+	}
+
+	msi.Fields = make(map[string]influxql.DataType, len(pb.GetFields()))
+	for _, f := range pb.GetFields() {
+		msi.Fields[f.GetName()] = influxql.DataType(f.GetType())
+	}
+}
+
 // UserInfo represents metadata about a user in the system.
 type UserInfo struct {
 	Name       string
@@ -73,6 +73,15 @@ var (
 	// ErrReplicationFactorTooLow is returned when the replication factor is not in an
 	// acceptable range.
 	ErrReplicationFactorTooLow = errors.New("replication factor must be greater than 0")
+
+	// ErrMeasurementDurationTooLow is returned when a measurement duration
+	// override is lower than the allowed minimum.
+	ErrMeasurementDurationTooLow = errors.New(fmt.Sprintf("measurement duration must be at least %s",
+		MinRetentionPolicyDuration))
+
+	// ErrMeasurementDurationTooHigh is returned when a measurement duration
+	// override is not shorter than the duration of its retention policy.
+	ErrMeasurementDurationTooHigh = errors.New("measurement duration must be shorter than the retention policy duration")
 )
 
 var (
@@ -95,6 +104,16 @@ var (
 	ErrContinuousQueryNotFound = errors.New("continuous query not found")
 )
 
+var (
+	// ErrMeasurementSchemaExists is returned when creating a schema for a
+	// measurement that already has a different schema.
+	ErrMeasurementSchemaExists = errors.New("measurement schema already exists")
+
+	// ErrMeasurementSchemaNotFound is returned when removing a measurement
+	// schema that doesn't exist.
+	ErrMeasurementSchemaNotFound = errors.New("measurement schema not found")
+)
+
 var (
 	// ErrSubscriptionExists is returned when creating an already existing subscription.
 	ErrSubscriptionExists = errors.New("subscription already exists")
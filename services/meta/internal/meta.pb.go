@@ -13,11 +13,14 @@ It has these top-level messages:
 	NodeInfo
 	DatabaseInfo
 	RetentionPolicyInfo
+	MeasurementDurationInfo
 	ShardGroupInfo
 	ShardInfo
 	SubscriptionInfo
 	ShardOwner
 	ContinuousQueryInfo
+	MeasurementSchemaInfo
+	MeasurementSchemaField
 	UserInfo
 	UserPrivilege
 	Command
@@ -307,11 +310,12 @@ func (m *NodeInfo) GetTCPHost() string {
 }
 
 type DatabaseInfo struct {
-	Name                   *string                `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
-	DefaultRetentionPolicy *string                `protobuf:"bytes,2,req,name=DefaultRetentionPolicy" json:"DefaultRetentionPolicy,omitempty"`
-	RetentionPolicies      []*RetentionPolicyInfo `protobuf:"bytes,3,rep,name=RetentionPolicies" json:"RetentionPolicies,omitempty"`
-	ContinuousQueries      []*ContinuousQueryInfo `protobuf:"bytes,4,rep,name=ContinuousQueries" json:"ContinuousQueries,omitempty"`
-	XXX_unrecognized       []byte                 `json:"-"`
+	Name                   *string                  `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
+	DefaultRetentionPolicy *string                  `protobuf:"bytes,2,req,name=DefaultRetentionPolicy" json:"DefaultRetentionPolicy,omitempty"`
+	RetentionPolicies      []*RetentionPolicyInfo   `protobuf:"bytes,3,rep,name=RetentionPolicies" json:"RetentionPolicies,omitempty"`
+	ContinuousQueries      []*ContinuousQueryInfo   `protobuf:"bytes,4,rep,name=ContinuousQueries" json:"ContinuousQueries,omitempty"`
+	MeasurementSchemas     []*MeasurementSchemaInfo `protobuf:"bytes,5,rep,name=MeasurementSchemas" json:"MeasurementSchemas,omitempty"`
+	XXX_unrecognized       []byte                   `json:"-"`
 }
 
 func (m *DatabaseInfo) Reset()         { *m = DatabaseInfo{} }
@@ -346,14 +350,22 @@ func (m *DatabaseInfo) GetContinuousQueries() []*ContinuousQueryInfo {
 	return nil
 }
 
+func (m *DatabaseInfo) GetMeasurementSchemas() []*MeasurementSchemaInfo {
+	if m != nil {
+		return m.MeasurementSchemas
+	}
+	return nil
+}
+
 type RetentionPolicyInfo struct {
-	Name               *string             `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
-	Duration           *int64              `protobuf:"varint,2,req,name=Duration" json:"Duration,omitempty"`
-	ShardGroupDuration *int64              `protobuf:"varint,3,req,name=ShardGroupDuration" json:"ShardGroupDuration,omitempty"`
-	ReplicaN           *uint32             `protobuf:"varint,4,req,name=ReplicaN" json:"ReplicaN,omitempty"`
-	ShardGroups        []*ShardGroupInfo   `protobuf:"bytes,5,rep,name=ShardGroups" json:"ShardGroups,omitempty"`
-	Subscriptions      []*SubscriptionInfo `protobuf:"bytes,6,rep,name=Subscriptions" json:"Subscriptions,omitempty"`
-	XXX_unrecognized   []byte              `json:"-"`
+	Name                 *string                    `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
+	Duration             *int64                     `protobuf:"varint,2,req,name=Duration" json:"Duration,omitempty"`
+	ShardGroupDuration   *int64                     `protobuf:"varint,3,req,name=ShardGroupDuration" json:"ShardGroupDuration,omitempty"`
+	ReplicaN             *uint32                    `protobuf:"varint,4,req,name=ReplicaN" json:"ReplicaN,omitempty"`
+	ShardGroups          []*ShardGroupInfo          `protobuf:"bytes,5,rep,name=ShardGroups" json:"ShardGroups,omitempty"`
+	Subscriptions        []*SubscriptionInfo        `protobuf:"bytes,6,rep,name=Subscriptions" json:"Subscriptions,omitempty"`
+	MeasurementDurations []*MeasurementDurationInfo `protobuf:"bytes,7,rep,name=MeasurementDurations" json:"MeasurementDurations,omitempty"`
+	XXX_unrecognized     []byte                     `json:"-"`
 }
 
 func (m *RetentionPolicyInfo) Reset()         { *m = RetentionPolicyInfo{} }
@@ -402,6 +414,37 @@ func (m *RetentionPolicyInfo) GetSubscriptions() []*SubscriptionInfo {
 	return nil
 }
 
+func (m *RetentionPolicyInfo) GetMeasurementDurations() []*MeasurementDurationInfo {
+	if m != nil {
+		return m.MeasurementDurations
+	}
+	return nil
+}
+
+type MeasurementDurationInfo struct {
+	Name             *string `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
+	Duration         *int64  `protobuf:"varint,2,req,name=Duration" json:"Duration,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *MeasurementDurationInfo) Reset()         { *m = MeasurementDurationInfo{} }
+func (m *MeasurementDurationInfo) String() string { return proto.CompactTextString(m) }
+func (*MeasurementDurationInfo) ProtoMessage()    {}
+
+func (m *MeasurementDurationInfo) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *MeasurementDurationInfo) GetDuration() int64 {
+	if m != nil && m.Duration != nil {
+		return *m.Duration
+	}
+	return 0
+}
+
 type ShardGroupInfo struct {
 	ID               *uint64      `protobuf:"varint,1,req,name=ID" json:"ID,omitempty"`
 	StartTime        *int64       `protobuf:"varint,2,req,name=StartTime" json:"StartTime,omitempty"`
@@ -554,6 +597,70 @@ func (m *ContinuousQueryInfo) GetQuery() string {
 	return ""
 }
 
+type MeasurementSchemaInfo struct {
+	Name             *string                   `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
+	TagKeys          []string                  `protobuf:"bytes,2,rep,name=TagKeys" json:"TagKeys,omitempty"`
+	Fields           []*MeasurementSchemaField `protobuf:"bytes,3,rep,name=Fields" json:"Fields,omitempty"`
+	Strict           *bool                     `protobuf:"varint,4,req,name=Strict" json:"Strict,omitempty"`
+	XXX_unrecognized []byte                    `json:"-"`
+}
+
+func (m *MeasurementSchemaInfo) Reset()         { *m = MeasurementSchemaInfo{} }
+func (m *MeasurementSchemaInfo) String() string { return proto.CompactTextString(m) }
+func (*MeasurementSchemaInfo) ProtoMessage()    {}
+
+func (m *MeasurementSchemaInfo) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *MeasurementSchemaInfo) GetTagKeys() []string {
+	if m != nil {
+		return m.TagKeys
+	}
+	return nil
+}
+
+func (m *MeasurementSchemaInfo) GetFields() []*MeasurementSchemaField {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+func (m *MeasurementSchemaInfo) GetStrict() bool {
+	if m != nil && m.Strict != nil {
+		return *m.Strict
+	}
+	return false
+}
+
+type MeasurementSchemaField struct {
+	Name             *string `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
+	Type             *int32  `protobuf:"varint,2,req,name=Type" json:"Type,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *MeasurementSchemaField) Reset()         { *m = MeasurementSchemaField{} }
+func (m *MeasurementSchemaField) String() string { return proto.CompactTextString(m) }
+func (*MeasurementSchemaField) ProtoMessage()    {}
+
+func (m *MeasurementSchemaField) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *MeasurementSchemaField) GetType() int32 {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return 0
+}
+
 type UserInfo struct {
 	Name             *string          `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
 	Hash             *string          `protobuf:"bytes,2,req,name=Hash" json:"Hash,omitempty"`
@@ -1690,11 +1797,14 @@ func init() {
 	proto.RegisterType((*NodeInfo)(nil), "meta.NodeInfo")
 	proto.RegisterType((*DatabaseInfo)(nil), "meta.DatabaseInfo")
 	proto.RegisterType((*RetentionPolicyInfo)(nil), "meta.RetentionPolicyInfo")
+	proto.RegisterType((*MeasurementDurationInfo)(nil), "meta.MeasurementDurationInfo")
 	proto.RegisterType((*ShardGroupInfo)(nil), "meta.ShardGroupInfo")
 	proto.RegisterType((*ShardInfo)(nil), "meta.ShardInfo")
 	proto.RegisterType((*SubscriptionInfo)(nil), "meta.SubscriptionInfo")
 	proto.RegisterType((*ShardOwner)(nil), "meta.ShardOwner")
 	proto.RegisterType((*ContinuousQueryInfo)(nil), "meta.ContinuousQueryInfo")
+	proto.RegisterType((*MeasurementSchemaInfo)(nil), "meta.MeasurementSchemaInfo")
+	proto.RegisterType((*MeasurementSchemaField)(nil), "meta.MeasurementSchemaField")
 	proto.RegisterType((*UserInfo)(nil), "meta.UserInfo")
 	proto.RegisterType((*UserPrivilege)(nil), "meta.UserPrivilege")
 	proto.RegisterType((*Command)(nil), "meta.Command")
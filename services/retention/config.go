@@ -10,6 +10,10 @@ import (
 type Config struct {
 	Enabled       bool          `toml:"enabled"`
 	CheckInterval toml.Duration `toml:"check-interval"`
+
+	// ReadOnlyShardGracePeriod is how long after the end of its time range a
+	// shard is made read-only.  Zero leaves shards writable.
+	ReadOnlyShardGracePeriod toml.Duration `toml:"read-only-shard-grace-period"`
 }
 
 // NewConfig returns an instance of Config with defaults.
@@ -14,6 +14,7 @@ func TestConfig_Parse(t *testing.T) {
 	if _, err := toml.Decode(`
 enabled = true
 check-interval = "1s"
+read-only-shard-grace-period = "2h"
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -23,5 +24,7 @@ check-interval = "1s"
 		t.Fatalf("unexpected enabled state: %v", c.Enabled)
 	} else if time.Duration(c.CheckInterval) != time.Second {
 		t.Fatalf("unexpected check interval: %v", c.CheckInterval)
+	} else if time.Duration(c.ReadOnlyShardGracePeriod) != 2*time.Hour {
+		t.Fatalf("unexpected read-only shard grace period: %v", c.ReadOnlyShardGracePeriod)
 	}
 }
@@ -3,6 +3,7 @@ package retention // import "github.com/influxdata/influxdb/services/retention"
 import (
 	"io"
 	"log"
+	"math"
 	"os"
 	"sync"
 	"time"
@@ -19,10 +20,13 @@ type Service struct {
 	TSDBStore interface {
 		ShardIDs() []uint64
 		DeleteShard(shardID uint64) error
+		SetShardReadOnly(shardID uint64, readOnly bool) error
+		DeleteMeasurementRange(database, name string, shardIDs []uint64, min, max int64) error
 	}
 
 	enabled       bool
 	checkInterval time.Duration
+	readOnlyGrace time.Duration
 	wg            sync.WaitGroup
 	done          chan struct{}
 
@@ -33,6 +37,7 @@ type Service struct {
 func NewService(c Config) *Service {
 	return &Service{
 		checkInterval: time.Duration(c.CheckInterval),
+		readOnlyGrace: time.Duration(c.ReadOnlyShardGracePeriod),
 		done:          make(chan struct{}),
 		logger:        log.New(os.Stderr, "[retention] ", log.LstdFlags),
 	}
@@ -41,9 +46,16 @@ func NewService(c Config) *Service {
 // Open starts retention policy enforcement.
 func (s *Service) Open() error {
 	s.logger.Println("Starting retention policy enforcement service with check interval of", s.checkInterval)
-	s.wg.Add(2)
+	s.wg.Add(3)
 	go s.deleteShardGroups()
 	go s.deleteShards()
+	go s.deleteExpiredMeasurements()
+
+	if s.readOnlyGrace > 0 {
+		s.logger.Println("Making shards read-only", s.readOnlyGrace, "after their time range ends")
+		s.wg.Add(1)
+		go s.markShardsReadOnly()
+	}
 	return nil
 }
 
@@ -133,3 +145,87 @@ func (s *Service) deleteShards() {
 		}
 	}
 }
+
+// deleteExpiredMeasurements deletes the data of measurements that is older
+// than the measurement's duration override in its retention policy.
+func (s *Service) deleteExpiredMeasurements() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case <-ticker.C:
+			now := time.Now().UTC()
+			for _, d := range s.MetaClient.Databases() {
+				for _, r := range d.RetentionPolicies {
+					for name, duration := range r.MeasurementDurations {
+						cutoff := now.Add(-duration)
+
+						// Only shards starting before the cutoff can hold expired data.
+						var shardIDs []uint64
+						for _, g := range r.ShardGroups {
+							if g.Deleted() || !g.StartTime.Before(cutoff) {
+								continue
+							}
+							for _, sh := range g.Shards {
+								shardIDs = append(shardIDs, sh.ID)
+							}
+						}
+						if len(shardIDs) == 0 {
+							continue
+						}
+
+						if err := s.TSDBStore.DeleteMeasurementRange(d.Name, name, shardIDs, math.MinInt64, cutoff.UnixNano()-1); err != nil {
+							s.logger.Printf("failed to delete expired data of measurement %s from database %s, retention policy %s: %s",
+								name, d.Name, r.Name, err.Error())
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// markShardsReadOnly makes shards read-only once their time range ended more
+// than the grace period ago.
+func (s *Service) markShardsReadOnly() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case <-ticker.C:
+			cutoff := time.Now().UTC().Add(-s.readOnlyGrace)
+			readOnlyShardIDs := make(map[uint64]struct{})
+			for _, d := range s.MetaClient.Databases() {
+				for _, r := range d.RetentionPolicies {
+					for _, g := range r.ShardGroups {
+						if g.Deleted() || !g.EndTime.Before(cutoff) {
+							continue
+						}
+						for _, sh := range g.Shards {
+							readOnlyShardIDs[sh.ID] = struct{}{}
+						}
+					}
+				}
+			}
+
+			for _, id := range s.TSDBStore.ShardIDs() {
+				if _, ok := readOnlyShardIDs[id]; !ok {
+					continue
+				}
+				if err := s.TSDBStore.SetShardReadOnly(id, true); err != nil {
+					s.logger.Printf("failed to make shard ID %d read-only: %s", id, err.Error())
+				}
+			}
+		}
+	}
+}
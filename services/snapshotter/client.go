@@ -55,6 +55,82 @@ func (c *Client) MetastoreBackup() (*meta.Data, error) {
 	return &data, nil
 }
 
+// ExportShard writes a shard export of the shard with the given id to w.  The
+// export holds the meta data of the shard followed by a tar archive of its
+// data files.
+func (c *Client) ExportShard(shardID uint64, w io.Writer) (*ShardExportHeader, error) {
+	conn, err := tcp.Dial("tcp", c.host, MuxHeader)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := &Request{
+		Type:    RequestShardExport,
+		ShardID: shardID,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("encode snapshot request: %s", err)
+	}
+
+	// The server closes the connection without a header if the export fails.
+	h, err := readShardExportHeader(conn)
+	if err != nil {
+		return nil, fmt.Errorf("read shard export header: %s", err)
+	}
+
+	if err := writeShardExportHeader(w, h); err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, conn); err != nil {
+		return nil, fmt.Errorf("copy shard export: %s", err)
+	}
+	return h, nil
+}
+
+// ImportShard sends the shard export read from r to the server, which adds
+// its data to the shard covering its time range in the given database and
+// retention policy.  Empty names import into the database and retention
+// policy the shard was exported from.  It returns the id of the shard the
+// data was imported into.
+func (c *Client) ImportShard(database, retentionPolicy string, r io.Reader) (uint64, error) {
+	conn, err := tcp.Dial("tcp", c.host, MuxHeader)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	req := &Request{
+		Type:            RequestShardImport,
+		Database:        database,
+		RetentionPolicy: retentionPolicy,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return 0, fmt.Errorf("encode snapshot request: %s", err)
+	}
+
+	// The server may reject the export before reading all of it, so report
+	// its response in preference to a failed copy.  Closing the write side
+	// tells the server the export is complete.
+	_, copyErr := io.Copy(conn, r)
+	if cw, ok := conn.(interface {
+		CloseWrite() error
+	}); ok && copyErr == nil {
+		copyErr = cw.CloseWrite()
+	}
+
+	var res Response
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		if copyErr != nil {
+			return 0, fmt.Errorf("copy shard export: %s", copyErr)
+		}
+		return 0, fmt.Errorf("decode import response: %s", err)
+	} else if res.Err != "" {
+		return 0, errors.New(res.Err)
+	}
+	return res.ShardID, nil
+}
+
 // doRequest sends a request to the snapshotter service and returns the result.
 func (c *Client) doRequest(req *Request) ([]byte, error) {
 	// Connect to snapshotter service.
@@ -1,10 +1,12 @@
 package snapshotter // import "github.com/influxdata/influxdb/services/snapshotter"
 
 import (
+	"bufio"
 	"bytes"
 	"encoding"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -26,6 +28,13 @@ const (
 	// BackupMagicHeader is the first 8 bytes used to identify and validate
 	// a metastore backup file
 	BackupMagicHeader = 0x59590101
+
+	// ShardExportMagicHeader is the first 8 bytes used to identify and
+	// validate a shard export file
+	ShardExportMagicHeader = 0x59590201
+
+	// maxShardExportHeaderSize is the largest shard export header accepted.
+	maxShardExportHeaderSize = 1 << 20
 )
 
 // Service manages the listener for the snapshot endpoint.
@@ -38,6 +47,8 @@ type Service struct {
 	MetaClient interface {
 		encoding.BinaryMarshaler
 		Database(name string) *meta.DatabaseInfo
+		ShardOwner(shardID uint64) (database, policy string, sgi *meta.ShardGroupInfo)
+		CreateShardGroup(database, policy string, timestamp time.Time) (*meta.ShardGroupInfo, error)
 	}
 
 	TSDBStore *tsdb.Store
@@ -110,7 +121,7 @@ func (s *Service) serve() {
 
 // handleConn processes conn. This is run in a separate goroutine.
 func (s *Service) handleConn(conn net.Conn) error {
-	r, err := s.readRequest(conn)
+	r, body, err := s.readRequest(conn)
 	if err != nil {
 		return fmt.Errorf("read request: %s", err)
 	}
@@ -128,6 +139,10 @@ func (s *Service) handleConn(conn net.Conn) error {
 		return s.writeDatabaseInfo(conn, r.Database)
 	case RequestRetentionPolicyInfo:
 		return s.writeRetentionPolicyInfo(conn, r.Database, r.RetentionPolicy)
+	case RequestShardExport:
+		return s.writeShardExport(conn, r.ShardID)
+	case RequestShardImport:
+		return s.importShard(conn, body, r.Database, r.RetentionPolicy)
 	default:
 		return fmt.Errorf("request type unknown: %v", r.Type)
 	}
@@ -251,13 +266,91 @@ func (s *Service) writeRetentionPolicyInfo(conn net.Conn, database, retentionPol
 	return nil
 }
 
-// readRequest Unmarshals a request object from the conn
-func (s *Service) readRequest(conn net.Conn) (Request, error) {
+// writeShardExport writes the header and data files of a shard into the
+// connection.
+func (s *Service) writeShardExport(conn net.Conn, shardID uint64) error {
+	database, policy, sgi := s.MetaClient.ShardOwner(shardID)
+	if sgi == nil || s.TSDBStore.Shard(shardID) == nil {
+		return fmt.Errorf("shard %d doesn't exist on this server", shardID)
+	}
+
+	// The header is only written once the backup starts writing, so a
+	// failed backup leaves the client without a header instead of with an
+	// export missing its data files.
+	w := &shardExportWriter{w: conn, header: &ShardExportHeader{
+		Database:        database,
+		RetentionPolicy: policy,
+		ShardID:         shardID,
+		StartTime:       sgi.StartTime,
+		EndTime:         sgi.EndTime,
+	}}
+	return s.TSDBStore.BackupShard(shardID, time.Time{}, w)
+}
+
+// importShard imports a shard export read from r into the shard covering its
+// time range in the given database and retention policy, creating the shard
+// if needed.  The outcome is written into the connection.
+func (s *Service) importShard(conn net.Conn, r io.Reader, database, policy string) error {
+	res := Response{}
+	id, err := s.importShardExport(r, database, policy)
+	if err != nil {
+		res.Err = err.Error()
+	}
+	res.ShardID = id
+
+	if err := json.NewEncoder(conn).Encode(res); err != nil {
+		return fmt.Errorf("encode resonse: %s", err.Error())
+	}
+	return err
+}
+
+func (s *Service) importShardExport(r io.Reader, database, policy string) (uint64, error) {
+	// Skip the newline written after the request by json.Encoder.
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(1); err == nil && b[0] == '\n' {
+		br.Discard(1)
+	}
+	r = br
+
+	h, err := readShardExportHeader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	// Default to the database and retention policy the shard was exported from.
+	if database == "" {
+		database = h.Database
+	}
+	if policy == "" {
+		policy = h.RetentionPolicy
+	}
+
+	sgi, err := s.MetaClient.CreateShardGroup(database, policy, h.StartTime)
+	if err != nil {
+		return 0, err
+	} else if h.StartTime.Before(sgi.StartTime) || h.EndTime.After(sgi.EndTime) {
+		return 0, fmt.Errorf("shard group %d of retention policy %s does not cover the exported time range %s to %s",
+			sgi.ID, policy, h.StartTime.Format(time.RFC3339), h.EndTime.Format(time.RFC3339))
+	} else if len(sgi.Shards) != 1 {
+		return 0, fmt.Errorf("shard group %d has %d shards, import requires exactly one", sgi.ID, len(sgi.Shards))
+	}
+
+	id := sgi.Shards[0].ID
+	if err := s.TSDBStore.CreateShard(database, policy, id); err != nil {
+		return id, err
+	}
+	return id, s.TSDBStore.ImportShard(id, r)
+}
+
+// readRequest Unmarshals a request object from the conn.  It returns a reader
+// for the rest of the data sent on the conn.
+func (s *Service) readRequest(conn net.Conn) (Request, io.Reader, error) {
 	var r Request
-	if err := json.NewDecoder(conn).Decode(&r); err != nil {
-		return r, err
+	dec := json.NewDecoder(conn)
+	if err := dec.Decode(&r); err != nil {
+		return r, nil, err
 	}
-	return r, nil
+	return r, io.MultiReader(dec.Buffered(), conn), nil
 }
 
 type RequestType uint8
@@ -267,6 +360,8 @@ const (
 	RequestMetastoreBackup
 	RequestDatabaseInfo
 	RequestRetentionPolicyInfo
+	RequestShardExport
+	RequestShardImport
 )
 
 // Request represents a request for a specific backup or for information
@@ -280,7 +375,81 @@ type Request struct {
 }
 
 // Response contains the relative paths for all the shards on this server
-// that are in the requested database or retention policy
+// that are in the requested database or retention policy, or the outcome of
+// a shard import
 type Response struct {
 	Paths []string
+
+	ShardID uint64 `json:",omitempty"`
+	Err     string `json:",omitempty"`
+}
+
+// ShardExportHeader holds the meta data of an exported shard.  It is written
+// before the data files of the shard in a shard export.
+type ShardExportHeader struct {
+	Database        string
+	RetentionPolicy string
+	ShardID         uint64
+	StartTime       time.Time
+	EndTime         time.Time
+}
+
+// writeShardExportHeader writes the magic header, the length of the encoded
+// header and the encoded header to w.
+func writeShardExportHeader(w io.Writer, h *ShardExportHeader) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], ShardExportMagicHeader)
+	binary.BigEndian.PutUint64(buf[8:], uint64(len(b)))
+	if _, err := w.Write(buf[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// shardExportWriter writes the header of a shard export before the first
+// write to w.
+type shardExportWriter struct {
+	w      io.Writer
+	header *ShardExportHeader
+}
+
+func (w *shardExportWriter) Write(p []byte) (int, error) {
+	if w.header != nil {
+		if err := writeShardExportHeader(w.w, w.header); err != nil {
+			return 0, err
+		}
+		w.header = nil
+	}
+	return w.w.Write(p)
+}
+
+// readShardExportHeader reads the header of a shard export from r.
+func readShardExportHeader(r io.Reader) (*ShardExportHeader, error) {
+	var buf [16]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, err
+	} else if binary.BigEndian.Uint64(buf[:8]) != ShardExportMagicHeader {
+		return nil, errors.New("invalid shard export")
+	}
+
+	n := binary.BigEndian.Uint64(buf[8:])
+	if n > maxShardExportHeaderSize {
+		return nil, errors.New("invalid shard export header size")
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+
+	var h ShardExportHeader
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
 }
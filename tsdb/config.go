@@ -1,8 +1,13 @@
 package tsdb
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/influxdata/influxdb/toml"
@@ -30,12 +35,118 @@ const (
 	DefaultCacheSnapshotWriteColdDuration = time.Duration(time.Hour)
 
 	// DefaultCompactFullWriteColdDuration is the duration at which the engine
-	// will compact all TSM files in a shard if it hasn't received a write or delete
+	// will compact all TSM files in a shard if it hasn't received a write or delete.
+	// A duration of zero disables full compactions of cold shards.
 	DefaultCompactFullWriteColdDuration = time.Duration(24 * time.Hour)
 
 	// DefaultMaxPointsPerBlock is the maximum number of points in an encoded
 	// block in a TSM file
 	DefaultMaxPointsPerBlock = 1000
+
+	// MinMaxPointsPerBlock is the smallest allowed max-points-per-block.  A
+	// file can only hold 65535 blocks per series, so very small blocks would
+	// limit how many points of a series a compaction can write to one file.
+	MinMaxPointsPerBlock = 100
+
+	// DefaultCompactLevel1FileCount is the number of level 1 TSM files (written
+	// from cache snapshots) that are compacted together into a level 2 file.
+	DefaultCompactLevel1FileCount = 2
+
+	// DefaultCompactLevel2FileCount is the number of level 2 TSM files that
+	// are compacted together into a level 3 file.
+	DefaultCompactLevel2FileCount = 2
+
+	// DefaultCompactLevel3FileCount is the number of level 3 TSM files that
+	// are compacted together into a level 4 file.
+	DefaultCompactLevel3FileCount = 4
+
+	// DefaultCompactLevel4FileCount is the maximum number of level 4 TSM
+	// generations that are compacted together in a single group.
+	DefaultCompactLevel4FileCount = 4
+
+	// DefaultCompactMaxFileSize is the size at which a TSM file is considered
+	// fully compacted. It is also the largest TSM file a compaction will write.
+	DefaultCompactMaxFileSize = 2048 * 1024 * 1024 // 2GB
+
+	// DefaultCompactBackoffCachePercent is how full the cache, as a percentage
+	// of cache-max-memory-size, must be before compactions above level 1 are
+	// deferred to give writes priority.
+	DefaultCompactBackoffCachePercent = 80
+
+	// DefaultCompactBackoffWALSyncLatency is the average WAL fsync latency
+	// above which compactions above level 1 are deferred.
+	DefaultCompactBackoffWALSyncLatency = 100 * time.Millisecond
+
+	// DefaultFloatEncoding is the encoding used for float field values in
+	// TSM files.
+	DefaultFloatEncoding = FloatEncodingGorilla
+
+	// DefaultMmapAdvice is the madvise advice given for the memory maps of
+	// TSM files.
+	DefaultMmapAdvice = MmapAdviceNormal
+
+	// DefaultTSMIndexCacheSize is the number of decoded index entries cached
+	// for each TSM file when its index is loaded lazily.
+	DefaultTSMIndexCacheSize = 4096
+
+	// DefaultMaxConcurrentShardOpens is the number of shards opened at once
+	// when the store is opened.
+	DefaultMaxConcurrentShardOpens = 4
+
+	// DefaultMaxSeriesPerDatabase is the maximum number of series a database
+	// can hold before writes creating new series are dropped.
+	DefaultMaxSeriesPerDatabase = 1000000
+
+	// DefaultMaxValuesPerTag is the maximum number of values a tag key of a
+	// measurement can have before writes adding new values are dropped.
+	DefaultMaxValuesPerTag = 100000
+
+	// DefaultFieldTypeConflict is how points are handled when the type of a
+	// field differs from the type already stored for it.
+	DefaultFieldTypeConflict = FieldTypeConflictReject
+)
+
+// Field type conflict policies.
+const (
+	// FieldTypeConflictReject fails the write.
+	FieldTypeConflictReject = "reject"
+
+	// FieldTypeConflictDrop drops the conflicting points and writes the
+	// rest, returning a partial write error.
+	FieldTypeConflictDrop = "drop-point"
+
+	// FieldTypeConflictCoerce converts integer values of float fields to
+	// floats.  Other conflicts fail the write.
+	FieldTypeConflictCoerce = "coerce"
+)
+
+// Float field value encodings.
+const (
+	// FloatEncodingGorilla XORs each value with the previous one as described
+	// in the Gorilla paper.  It works well for slowly changing values.
+	FloatEncodingGorilla = "gorilla"
+
+	// FloatEncodingRaw stores each value in 8 bytes.  It avoids the CPU cost
+	// of compression for values that do not compress, such as random doubles.
+	FloatEncodingRaw = "raw"
+)
+
+// Memory map advice for TSM files.
+const (
+	// MmapAdviceNormal leaves read-ahead to the operating system's default.
+	MmapAdviceNormal = "normal"
+
+	// MmapAdviceRandom disables read-ahead.  It suits hosts where the page
+	// cache is too small to hold the data being queried.
+	MmapAdviceRandom = "random"
+
+	// MmapAdviceSequential reads ahead aggressively and frees pages soon
+	// after they are read.
+	MmapAdviceSequential = "sequential"
+
+	// MmapAdviceWillNeed asks the operating system to read each file into
+	// the page cache when it is opened.
+	MmapAdviceWillNeed = "willneed"
 )
 
 // Config holds the configuration for the tsbd package.
@@ -50,6 +161,40 @@ type Config struct {
 	// Query logging
 	QueryLogEnabled bool `toml:"query-log-enabled"`
 
+	// Limits
+
+	// MaxSeriesPerDatabase is the maximum number of series in a database.
+	// Points that would create series beyond it are dropped.  Zero disables
+	// the limit.
+	MaxSeriesPerDatabase int `toml:"max-series-per-database"`
+
+	// MaxValuesPerTag is the maximum number of values of a tag key in a
+	// measurement.  Points that would add values beyond it are dropped.
+	// Zero disables the limit.
+	MaxValuesPerTag int `toml:"max-values-per-tag"`
+
+	// MaxSeriesPerShard is the maximum number of series in a shard.  Points
+	// that would add series to a shard beyond it are dropped.  Zero disables
+	// the limit.
+	MaxSeriesPerShard int `toml:"max-series-per-shard"`
+
+	// MaxShardDiskSize is the maximum size in bytes of the data files of a
+	// shard.  Writes to a shard that has reached it fail.  Zero disables the
+	// limit.
+	MaxShardDiskSize int64 `toml:"max-shard-disk-size"`
+
+	// FieldTypeConflict is how points whose field types conflict with the
+	// stored types are handled.  DatabaseFieldTypeConflicts overrides it per
+	// database with entries of the form "database=policy".
+	FieldTypeConflict          string   `toml:"field-type-conflict"`
+	DatabaseFieldTypeConflicts []string `toml:"database-field-type-conflicts"`
+
+	// SeriesIdleTimeout is how long a series may go without being written or
+	// queried before it is evicted from the in-memory index.  Evicted series
+	// are indexed in a file in their shard's directory and reloaded when a
+	// query may read them.  Zero disables eviction.
+	SeriesIdleTimeout toml.Duration `toml:"series-idle-timeout"`
+
 	// Compaction options for tsm1 (descriptions above with defaults)
 	CacheMaxMemorySize             uint64        `toml:"cache-max-memory-size"`
 	CacheSnapshotMemorySize        uint64        `toml:"cache-snapshot-memory-size"`
@@ -57,6 +202,90 @@ type Config struct {
 	CompactFullWriteColdDuration   toml.Duration `toml:"compact-full-write-cold-duration"`
 	MaxPointsPerBlock              int           `toml:"max-points-per-block"`
 
+	// Leveled compaction planner options (descriptions above with defaults)
+	CompactLevel1FileCount int    `toml:"compact-level1-file-count"`
+	CompactLevel2FileCount int    `toml:"compact-level2-file-count"`
+	CompactLevel3FileCount int    `toml:"compact-level3-file-count"`
+	CompactLevel4FileCount int    `toml:"compact-level4-file-count"`
+	CompactMaxFileSize     uint64 `toml:"compact-max-file-size"`
+
+	// Compaction backoff options (descriptions above with defaults)
+	CompactBackoffCachePercent   int           `toml:"compact-backoff-cache-percent"`
+	CompactBackoffWALSyncLatency toml.Duration `toml:"compact-backoff-wal-sync-latency"`
+
+	// CompactFullWindow restricts automatic full compactions to a daily window
+	// of local time, such as "01:00-05:00".  Empty allows them at any time.
+	CompactFullWindow string `toml:"compact-full-window"`
+
+	// CompactTempDir is where compactions write new TSM files before moving
+	// them into the shard directory.  Empty uses the shard directory.
+	CompactTempDir string `toml:"compact-temp-dir"`
+
+	// CompactVerify re-reads and verifies the files written by each
+	// compaction before they replace the files they were compacted from.
+	CompactVerify bool `toml:"compact-verify"`
+
+	// VerifyTSMOnOpen checks the checksum of every block of every TSM file
+	// when a shard is opened.  A shard with a corrupt file fails to open.
+	VerifyTSMOnOpen bool `toml:"verify-tsm-on-open"`
+
+	// FloatEncoding is the encoding of float field values written to TSM
+	// files.  DatabaseFloatEncodings overrides it per database with entries
+	// of the form "database=encoding".
+	FloatEncoding          string   `toml:"float-encoding"`
+	DatabaseFloatEncodings []string `toml:"database-float-encodings"`
+
+	// StringDictionaryEncoding writes string blocks with few distinct values
+	// using a dictionary.  Files containing such blocks cannot be read by
+	// versions without dictionary support.
+	StringDictionaryEncoding bool `toml:"string-dictionary-encoding"`
+
+	// MmapAdvice is the madvise advice given for the memory maps of TSM
+	// files.  DirectReads reads blocks with pread instead of through the
+	// memory map so that reading cold data does not fault in mapped pages.
+	MmapAdvice  string `toml:"mmap-advice"`
+	DirectReads bool   `toml:"direct-reads"`
+
+	// LazyTSMIndex releases the index pages of TSM files from memory once
+	// they are opened so that only the index data of queried series stays
+	// resident.  TSMIndexCacheSize is the number of decoded index entries
+	// kept in an LRU cache for each file when LazyTSMIndex is set.
+	LazyTSMIndex      bool `toml:"lazy-tsm-index"`
+	TSMIndexCacheSize int  `toml:"tsm-index-cache-size"`
+
+	// MaxConcurrentShardOpens is the number of shards opened at once when
+	// the store is opened.  Zero uses the number of CPUs, which speeds up
+	// startup on hosts whose disks can serve that many shards at once.
+	MaxConcurrentShardOpens int `toml:"max-concurrent-shard-opens"`
+
+	// LazyShardOpenAge defers opening shards whose files have not been
+	// modified within the duration until they are first written to or
+	// queried.  Zero opens every shard when the store is opened.
+	LazyShardOpenAge toml.Duration `toml:"lazy-shard-open-age"`
+
+	// CacheFlushOnShutdown writes the cache of every open shard to TSM files
+	// when the store is closed, so the WAL does not have to be replayed on
+	// the next start.
+	CacheFlushOnShutdown bool `toml:"cache-flush-on-shutdown"`
+
+	// ColdDir is a second directory shards can be moved to, typically on
+	// cheaper storage.  Shards that have not been modified within
+	// ColdShardAge are moved there automatically.  Zero disables automatic
+	// moves.
+	ColdDir      string        `toml:"cold-dir"`
+	ColdShardAge toml.Duration `toml:"cold-shard-age"`
+
+	// MlockTSMIndex locks the index pages of the TSM files of shards in the
+	// data directory in memory so that the operating system does not evict
+	// them.  Shards in ColdDir are not locked, so it requires ColdDir.
+	MlockTSMIndex bool `toml:"mlock-tsm-index"`
+
+	// EncryptionKeyFile is the path of a file holding the hex encoded AES
+	// key used to encrypt TSM blocks.  Empty disables encryption unless
+	// EncryptionKeyProvider is set.  The WAL is not encrypted, so points are
+	// stored in plaintext in WALDir until they are snapshotted to TSM files.
+	EncryptionKeyFile string `toml:"encryption-key-file"`
+
 	DataLoggingEnabled bool `toml:"data-logging-enabled"`
 }
 
@@ -69,10 +298,32 @@ func NewConfig() Config {
 
 		QueryLogEnabled: true,
 
+		MaxSeriesPerDatabase: DefaultMaxSeriesPerDatabase,
+		MaxValuesPerTag:      DefaultMaxValuesPerTag,
+		FieldTypeConflict:    DefaultFieldTypeConflict,
+
 		CacheMaxMemorySize:             DefaultCacheMaxMemorySize,
 		CacheSnapshotMemorySize:        DefaultCacheSnapshotMemorySize,
 		CacheSnapshotWriteColdDuration: toml.Duration(DefaultCacheSnapshotWriteColdDuration),
 		CompactFullWriteColdDuration:   toml.Duration(DefaultCompactFullWriteColdDuration),
+		MaxPointsPerBlock:              DefaultMaxPointsPerBlock,
+
+		CompactLevel1FileCount: DefaultCompactLevel1FileCount,
+		CompactLevel2FileCount: DefaultCompactLevel2FileCount,
+		CompactLevel3FileCount: DefaultCompactLevel3FileCount,
+		CompactLevel4FileCount: DefaultCompactLevel4FileCount,
+		CompactMaxFileSize:     DefaultCompactMaxFileSize,
+
+		CompactBackoffCachePercent:   DefaultCompactBackoffCachePercent,
+		CompactBackoffWALSyncLatency: toml.Duration(DefaultCompactBackoffWALSyncLatency),
+
+		FloatEncoding: DefaultFloatEncoding,
+
+		MmapAdvice: DefaultMmapAdvice,
+
+		TSMIndexCacheSize: DefaultTSMIndexCacheSize,
+
+		MaxConcurrentShardOpens: DefaultMaxConcurrentShardOpens,
 
 		DataLoggingEnabled: true,
 	}
@@ -86,6 +337,112 @@ func (c *Config) Validate() error {
 		return errors.New("Data.WALDir must be specified")
 	}
 
+	if c.CompactFullWriteColdDuration < 0 {
+		return errors.New("Data.CompactFullWriteColdDuration must not be negative")
+	}
+
+	if c.MaxPointsPerBlock != 0 && c.MaxPointsPerBlock < MinMaxPointsPerBlock {
+		return fmt.Errorf("Data.MaxPointsPerBlock must be at least %d", MinMaxPointsPerBlock)
+	}
+
+	for i, n := range []int{c.CompactLevel1FileCount, c.CompactLevel2FileCount, c.CompactLevel3FileCount, c.CompactLevel4FileCount} {
+		if n < 0 {
+			return fmt.Errorf("Data.CompactLevel%dFileCount must not be negative", i+1)
+		} else if n == 1 {
+			return fmt.Errorf("Data.CompactLevel%dFileCount must be at least 2", i+1)
+		}
+	}
+
+	if c.CompactMaxFileSize > DefaultCompactMaxFileSize {
+		return fmt.Errorf("Data.CompactMaxFileSize must not exceed %d", DefaultCompactMaxFileSize)
+	}
+
+	if c.CompactBackoffCachePercent < 0 || c.CompactBackoffCachePercent > 100 {
+		return errors.New("Data.CompactBackoffCachePercent must be between 0 and 100")
+	}
+
+	if c.CompactBackoffWALSyncLatency < 0 {
+		return errors.New("Data.CompactBackoffWALSyncLatency must not be negative")
+	}
+
+	if _, err := ParseTimeWindow(c.CompactFullWindow); err != nil {
+		return fmt.Errorf("Data.CompactFullWindow: %s", err)
+	}
+
+	if !validFloatEncoding(c.FloatEncoding) {
+		return fmt.Errorf("Data.FloatEncoding: unknown encoding %q", c.FloatEncoding)
+	}
+	for _, s := range c.DatabaseFloatEncodings {
+		db, enc, ok := parseDatabaseSetting(s)
+		if !ok || db == "" {
+			return fmt.Errorf("Data.DatabaseFloatEncodings: %q must be of the form database=encoding", s)
+		} else if !validFloatEncoding(enc) {
+			return fmt.Errorf("Data.DatabaseFloatEncodings: unknown encoding %q for database %s", enc, db)
+		}
+	}
+
+	switch c.MmapAdvice {
+	case "", MmapAdviceNormal, MmapAdviceRandom, MmapAdviceSequential, MmapAdviceWillNeed:
+	default:
+		return fmt.Errorf("Data.MmapAdvice: unknown advice %q", c.MmapAdvice)
+	}
+
+	if c.TSMIndexCacheSize < 0 {
+		return errors.New("Data.TSMIndexCacheSize must not be negative")
+	}
+
+	if c.MaxConcurrentShardOpens < 0 {
+		return errors.New("Data.MaxConcurrentShardOpens must not be negative")
+	}
+
+	if c.MaxSeriesPerDatabase < 0 {
+		return errors.New("Data.MaxSeriesPerDatabase must not be negative")
+	}
+
+	if c.MaxValuesPerTag < 0 {
+		return errors.New("Data.MaxValuesPerTag must not be negative")
+	}
+
+	if c.MaxSeriesPerShard < 0 {
+		return errors.New("Data.MaxSeriesPerShard must not be negative")
+	}
+
+	if c.MaxShardDiskSize < 0 {
+		return errors.New("Data.MaxShardDiskSize must not be negative")
+	}
+
+	if !validFieldTypeConflict(c.FieldTypeConflict) {
+		return fmt.Errorf("Data.FieldTypeConflict: unknown policy %q", c.FieldTypeConflict)
+	}
+	for _, s := range c.DatabaseFieldTypeConflicts {
+		db, policy, ok := parseDatabaseSetting(s)
+		if !ok || db == "" {
+			return fmt.Errorf("Data.DatabaseFieldTypeConflicts: %q must be of the form database=policy", s)
+		} else if !validFieldTypeConflict(policy) {
+			return fmt.Errorf("Data.DatabaseFieldTypeConflicts: unknown policy %q for database %s", policy, db)
+		}
+	}
+
+	if c.SeriesIdleTimeout < 0 {
+		return errors.New("Data.SeriesIdleTimeout must not be negative")
+	}
+
+	if c.LazyShardOpenAge < 0 {
+		return errors.New("Data.LazyShardOpenAge must not be negative")
+	}
+
+	if c.ColdShardAge < 0 {
+		return errors.New("Data.ColdShardAge must not be negative")
+	} else if c.ColdShardAge > 0 && c.ColdDir == "" {
+		return errors.New("Data.ColdShardAge requires Data.ColdDir")
+	}
+
+	if c.MlockTSMIndex && c.LazyTSMIndex {
+		return errors.New("Data.MlockTSMIndex and Data.LazyTSMIndex can not both be set")
+	} else if c.MlockTSMIndex && c.ColdDir == "" {
+		return errors.New("Data.MlockTSMIndex requires Data.ColdDir")
+	}
+
 	valid := false
 	for _, e := range RegisteredEngines() {
 		if e == c.Engine {
@@ -99,3 +456,145 @@ func (c *Config) Validate() error {
 
 	return nil
 }
+
+// EncryptionKeyProvider, when set, returns the key used to encrypt TSM blocks
+// in place of reading Config.EncryptionKeyFile.  Programs embedding the server
+// can set it to fetch keys from a key management service.
+var EncryptionKeyProvider func() ([]byte, error)
+
+// EncryptionKey returns the key used to encrypt TSM blocks, or nil if
+// encryption is disabled.
+func (c *Config) EncryptionKey() ([]byte, error) {
+	if EncryptionKeyProvider != nil {
+		return EncryptionKeyProvider()
+	} else if c.EncryptionKeyFile == "" {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadFile(c.EncryptionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", c.EncryptionKeyFile, err)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s: key must be 16, 24 or 32 bytes, got %d", c.EncryptionKeyFile, len(key))
+}
+
+// InColdDir returns true if path is within ColdDir.
+func (c *Config) InColdDir(path string) bool {
+	return c.ColdDir != "" && strings.HasPrefix(path, filepath.Clean(c.ColdDir)+string(os.PathSeparator))
+}
+
+// FloatEncodingFor returns the float encoding to use for database.
+func (c *Config) FloatEncodingFor(database string) string {
+	for _, s := range c.DatabaseFloatEncodings {
+		if db, enc, ok := parseDatabaseSetting(s); ok && db == database {
+			return enc
+		}
+	}
+	if c.FloatEncoding == "" {
+		return DefaultFloatEncoding
+	}
+	return c.FloatEncoding
+}
+
+func validFloatEncoding(s string) bool {
+	switch s {
+	case "", FloatEncodingGorilla, FloatEncodingRaw:
+		return true
+	}
+	return false
+}
+
+// FieldTypeConflictFor returns the field type conflict policy of database.
+func (c *Config) FieldTypeConflictFor(database string) string {
+	for _, s := range c.DatabaseFieldTypeConflicts {
+		if db, policy, ok := parseDatabaseSetting(s); ok && db == database {
+			return policy
+		}
+	}
+	if c.FieldTypeConflict == "" {
+		return DefaultFieldTypeConflict
+	}
+	return c.FieldTypeConflict
+}
+
+func validFieldTypeConflict(s string) bool {
+	switch s {
+	case "", FieldTypeConflictReject, FieldTypeConflictDrop, FieldTypeConflictCoerce:
+		return true
+	}
+	return false
+}
+
+// parseDatabaseSetting splits a "database=value" entry.
+func parseDatabaseSetting(s string) (database, value string, ok bool) {
+	i := strings.LastIndex(s, "=")
+	if i == -1 {
+		return "", "", false
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+}
+
+// TimeWindow is a daily window of local time.  Start and End are offsets from
+// midnight.  A window whose end is before its start spans midnight.
+type TimeWindow struct {
+	Start, End time.Duration
+}
+
+// ParseTimeWindow parses a window in the form "HH:MM-HH:MM".  An empty string
+// returns the zero window, which contains all times.
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	if s == "" {
+		return TimeWindow{}, nil
+	}
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", s)
+	}
+
+	var w TimeWindow
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", s)
+		}
+		d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			w.Start = d
+		} else {
+			w.End = d
+		}
+	}
+
+	if w.Start == w.End {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q, start and end must differ", s)
+	}
+	return w, nil
+}
+
+// IsZero returns true if the window is unset.
+func (w TimeWindow) IsZero() bool { return w.Start == 0 && w.End == 0 }
+
+// Contains returns true if t falls within the window.  The zero window
+// contains all times.
+func (w TimeWindow) Contains(t time.Time) bool {
+	if w.IsZero() {
+		return true
+	}
+
+	d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.Start < w.End {
+		return d >= w.Start && d < w.End
+	}
+	return d >= w.Start || d < w.End
+}
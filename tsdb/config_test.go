@@ -1,9 +1,13 @@
 package tsdb_test
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	itoml "github.com/influxdata/influxdb/toml"
 	"github.com/influxdata/influxdb/tsdb"
 )
 
@@ -13,6 +17,9 @@ func TestConfig_Parse(t *testing.T) {
 	if _, err := toml.Decode(`
 dir = "/var/lib/influxdb/data"
 wal-dir = "/var/lib/influxdb/wal"
+compact-full-write-cold-duration = "4h"
+compact-level1-file-count = 3
+compact-max-file-size = 1073741824
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -27,6 +34,21 @@ wal-dir = "/var/lib/influxdb/wal"
 	if got, exp := c.WALDir, "/var/lib/influxdb/wal"; got != exp {
 		t.Errorf("unexpected wal-dir:\n\nexp=%v\n\ngot=%v\n\n", exp, got)
 	}
+	if got, exp := time.Duration(c.CompactFullWriteColdDuration), 4*time.Hour; got != exp {
+		t.Errorf("unexpected compact-full-write-cold-duration:\n\nexp=%v\n\ngot=%v\n\n", exp, got)
+	}
+	if got, exp := c.CompactLevel1FileCount, 3; got != exp {
+		t.Errorf("unexpected compact-level1-file-count:\n\nexp=%v\n\ngot=%v\n\n", exp, got)
+	}
+	if got, exp := c.CompactLevel2FileCount, tsdb.DefaultCompactLevel2FileCount; got != exp {
+		t.Errorf("unexpected compact-level2-file-count:\n\nexp=%v\n\ngot=%v\n\n", exp, got)
+	}
+	if got, exp := c.CompactMaxFileSize, uint64(1073741824); got != exp {
+		t.Errorf("unexpected compact-max-file-size:\n\nexp=%v\n\ngot=%v\n\n", exp, got)
+	}
+	if got, exp := c.MaxConcurrentShardOpens, tsdb.DefaultMaxConcurrentShardOpens; got != exp {
+		t.Errorf("unexpected max-concurrent-shard-opens:\n\nexp=%v\n\ngot=%v\n\n", exp, got)
+	}
 }
 
 func TestConfig_Validate_Error(t *testing.T) {
@@ -41,8 +63,269 @@ func TestConfig_Validate_Error(t *testing.T) {
 	}
 
 	c.WALDir = "/var/lib/influxdb/wal"
+	c.CompactFullWriteColdDuration = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.CompactFullWriteColdDuration must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.CompactFullWriteColdDuration = 0
+	c.MaxPointsPerBlock = 10
+	if err := c.Validate(); err == nil || err.Error() != "Data.MaxPointsPerBlock must be at least 100" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.MaxPointsPerBlock = tsdb.DefaultMaxPointsPerBlock
+	c.CompactLevel3FileCount = 1
+	if err := c.Validate(); err == nil || err.Error() != "Data.CompactLevel3FileCount must be at least 2" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.CompactLevel3FileCount = tsdb.DefaultCompactLevel3FileCount
+	c.CompactLevel2FileCount = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.CompactLevel2FileCount must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.CompactLevel2FileCount = tsdb.DefaultCompactLevel2FileCount
+	c.CompactMaxFileSize = tsdb.DefaultCompactMaxFileSize + 1
+	if err := c.Validate(); err == nil || err.Error() != "Data.CompactMaxFileSize must not exceed 2147483648" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.CompactMaxFileSize = tsdb.DefaultCompactMaxFileSize
+	c.CompactBackoffCachePercent = 101
+	if err := c.Validate(); err == nil || err.Error() != "Data.CompactBackoffCachePercent must be between 0 and 100" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.CompactBackoffCachePercent = tsdb.DefaultCompactBackoffCachePercent
+	c.CompactBackoffWALSyncLatency = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.CompactBackoffWALSyncLatency must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.CompactBackoffWALSyncLatency = itoml.Duration(tsdb.DefaultCompactBackoffWALSyncLatency)
+	c.FloatEncoding = "zip"
+	if err := c.Validate(); err == nil || err.Error() != `Data.FloatEncoding: unknown encoding "zip"` {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.FloatEncoding = tsdb.DefaultFloatEncoding
+	c.DatabaseFloatEncodings = []string{"sensors"}
+	if err := c.Validate(); err == nil || err.Error() != `Data.DatabaseFloatEncodings: "sensors" must be of the form database=encoding` {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.DatabaseFloatEncodings = []string{"sensors=zip"}
+	if err := c.Validate(); err == nil || err.Error() != `Data.DatabaseFloatEncodings: unknown encoding "zip" for database sensors` {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.DatabaseFloatEncodings = nil
+	c.MmapAdvice = "dontneed"
+	if err := c.Validate(); err == nil || err.Error() != `Data.MmapAdvice: unknown advice "dontneed"` {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.MmapAdvice = tsdb.DefaultMmapAdvice
+	c.TSMIndexCacheSize = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.TSMIndexCacheSize must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.TSMIndexCacheSize = tsdb.DefaultTSMIndexCacheSize
+	c.MlockTSMIndex, c.LazyTSMIndex = true, true
+	if err := c.Validate(); err == nil || err.Error() != "Data.MlockTSMIndex and Data.LazyTSMIndex can not both be set" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.LazyTSMIndex = false
+	if err := c.Validate(); err == nil || err.Error() != "Data.MlockTSMIndex requires Data.ColdDir" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.MlockTSMIndex = false
+	c.MaxConcurrentShardOpens = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.MaxConcurrentShardOpens must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.MaxConcurrentShardOpens = 0
+	c.MaxSeriesPerDatabase = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.MaxSeriesPerDatabase must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.MaxSeriesPerDatabase = 0
+	c.MaxValuesPerTag = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.MaxValuesPerTag must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.MaxValuesPerTag = 0
+	c.MaxSeriesPerShard = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.MaxSeriesPerShard must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.MaxSeriesPerShard = 0
+	c.MaxShardDiskSize = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.MaxShardDiskSize must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.MaxShardDiskSize = 0
+	c.FieldTypeConflict = "ignore"
+	if err := c.Validate(); err == nil || err.Error() != `Data.FieldTypeConflict: unknown policy "ignore"` {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.FieldTypeConflict = tsdb.DefaultFieldTypeConflict
+	c.DatabaseFieldTypeConflicts = []string{"sensors"}
+	if err := c.Validate(); err == nil || err.Error() != `Data.DatabaseFieldTypeConflicts: "sensors" must be of the form database=policy` {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.DatabaseFieldTypeConflicts = []string{"sensors=ignore"}
+	if err := c.Validate(); err == nil || err.Error() != `Data.DatabaseFieldTypeConflicts: unknown policy "ignore" for database sensors` {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.DatabaseFieldTypeConflicts = nil
+	c.SeriesIdleTimeout = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.SeriesIdleTimeout must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.SeriesIdleTimeout = 0
+	c.LazyShardOpenAge = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.LazyShardOpenAge must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.LazyShardOpenAge = 0
+	c.ColdShardAge = -1
+	if err := c.Validate(); err == nil || err.Error() != "Data.ColdShardAge must not be negative" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.ColdShardAge = itoml.Duration(time.Hour)
+	if err := c.Validate(); err == nil || err.Error() != "Data.ColdShardAge requires Data.ColdDir" {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	c.ColdShardAge = 0
 	c.Engine = "fake1"
 	if err := c.Validate(); err == nil || err.Error() != "unrecognized engine fake1" {
 		t.Errorf("unexpected error: %s", err)
 	}
 }
+
+func TestConfig_InColdDir(t *testing.T) {
+	c := tsdb.NewConfig()
+	if c.InColdDir("/var/lib/influxdb/data/db0/rp0/1") {
+		t.Error("expected shard to be hot without a cold dir")
+	}
+
+	c.ColdDir = "/mnt/cold/"
+	if !c.InColdDir("/mnt/cold/db0/rp0/1") {
+		t.Error("expected shard in cold dir to be cold")
+	}
+	if c.InColdDir("/mnt/colder/db0/rp0/1") {
+		t.Error("expected shard in sibling dir to be hot")
+	}
+}
+
+func TestConfig_FloatEncodingFor(t *testing.T) {
+	c := tsdb.NewConfig()
+	c.DatabaseFloatEncodings = []string{"sensors=raw", "db=with=equals=gorilla"}
+
+	if got, exp := c.FloatEncodingFor("sensors"), tsdb.FloatEncodingRaw; got != exp {
+		t.Errorf("unexpected encoding for sensors: got %s, exp %s", got, exp)
+	}
+	if got, exp := c.FloatEncodingFor("db=with=equals"), tsdb.FloatEncodingGorilla; got != exp {
+		t.Errorf("unexpected encoding for db=with=equals: got %s, exp %s", got, exp)
+	}
+	if got, exp := c.FloatEncodingFor("telegraf"), tsdb.DefaultFloatEncoding; got != exp {
+		t.Errorf("unexpected encoding for telegraf: got %s, exp %s", got, exp)
+	}
+}
+
+func TestConfig_FieldTypeConflictFor(t *testing.T) {
+	c := tsdb.NewConfig()
+	c.DatabaseFieldTypeConflicts = []string{"sensors=coerce", "logs = drop-point"}
+
+	if got, exp := c.FieldTypeConflictFor("sensors"), tsdb.FieldTypeConflictCoerce; got != exp {
+		t.Errorf("unexpected policy for sensors: got %s, exp %s", got, exp)
+	}
+	if got, exp := c.FieldTypeConflictFor("logs"), tsdb.FieldTypeConflictDrop; got != exp {
+		t.Errorf("unexpected policy for logs: got %s, exp %s", got, exp)
+	}
+	if got, exp := c.FieldTypeConflictFor("telegraf"), tsdb.DefaultFieldTypeConflict; got != exp {
+		t.Errorf("unexpected policy for telegraf: got %s, exp %s", got, exp)
+	}
+}
+
+func TestConfig_EncryptionKey(t *testing.T) {
+	c := tsdb.NewConfig()
+	if key, err := c.EncryptionKey(); err != nil || key != nil {
+		t.Fatalf("unexpected key: %v %v", key, err)
+	}
+
+	f, err := ioutil.TempFile("", "influxdb-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("000102030405060708090a0b0c0d0e0f\n")
+	f.Close()
+
+	c.EncryptionKeyFile = f.Name()
+	if key, err := c.EncryptionKey(); err != nil || len(key) != 16 || key[15] != 15 {
+		t.Fatalf("unexpected key: %v %v", key, err)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte("0001"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.EncryptionKey(); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
+
+func TestParseTimeWindow(t *testing.T) {
+	for _, tt := range []struct {
+		s       string
+		in, out []string
+		err     string
+	}{
+		{s: "", in: []string{"00:00", "12:30", "23:59"}},
+		{s: "01:00-05:00", in: []string{"01:00", "04:59"}, out: []string{"00:59", "05:00", "13:00"}},
+		{s: "22:00-04:00", in: []string{"22:00", "23:59", "00:00", "03:59"}, out: []string{"04:00", "12:00", "21:59"}},
+		{s: "01:00", err: `invalid time window "01:00", expected HH:MM-HH:MM`},
+		{s: "01:00-25:00", err: `invalid time window "01:00-25:00", expected HH:MM-HH:MM`},
+		{s: "01:00-01:00", err: `invalid time window "01:00-01:00", start and end must differ`},
+	} {
+		w, err := tsdb.ParseTimeWindow(tt.s)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: unexpected error: %v", tt.s, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.s, err)
+			continue
+		}
+
+		for _, s := range tt.in {
+			if ts, _ := time.Parse("15:04", s); !w.Contains(ts) {
+				t.Errorf("%q: expected window to contain %s", tt.s, s)
+			}
+		}
+		for _, s := range tt.out {
+			if ts, _ := time.Parse("15:04", s); w.Contains(ts) {
+				t.Errorf("%q: expected window not to contain %s", tt.s, s)
+			}
+		}
+	}
+}
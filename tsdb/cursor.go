@@ -276,10 +276,10 @@ func (itr *FloatCursorIterator) Next() *influxql.FloatPoint {
 			}
 
 			// Read all auxilary fields.
-			for i, name := range itr.opt.Aux {
-				if v, ok := m[name]; ok {
+			for i, ref := range itr.opt.Aux {
+				if v, ok := m[ref.Val]; ok && ref.Type != influxql.Tag {
 					itr.point.Aux[i] = v
-				} else if s, ok := tags[name]; ok {
+				} else if s, ok := tags[ref.Val]; ok && isTagRef(ref) {
 					itr.point.Aux[i] = s
 				} else {
 					itr.point.Aux[i] = nil
@@ -306,9 +306,11 @@ func (itr *FloatCursorIterator) Next() *influxql.FloatPoint {
 		}
 
 		// Read all auxilary fields.
-		for i, name := range itr.opt.Aux {
-			if tagValue, ok := tags[name]; ok {
+		for i, ref := range itr.opt.Aux {
+			if tagValue, ok := tags[ref.Val]; ok && isTagRef(ref) {
 				itr.point.Aux[i] = tagValue
+			} else if ref.Type == influxql.Tag {
+				itr.point.Aux[i] = nil
 			} else {
 				itr.point.Aux[i] = value
 			}
@@ -317,3 +319,9 @@ func (itr *FloatCursorIterator) Next() *influxql.FloatPoint {
 		return &itr.point
 	}
 }
+
+// isTagRef returns true if ref may read a tag.  References qualified with a
+// field type only read fields.
+func isTagRef(ref influxql.VarRef) bool {
+	return ref.Type == influxql.Unknown || ref.Type == influxql.Tag
+}
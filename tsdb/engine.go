@@ -31,22 +31,82 @@ type Engine interface {
 	SetLogOutput(io.Writer)
 	LoadMetadataIndex(shardID uint64, index *DatabaseIndex) error
 
+	// LoadSeries adds the series with the given keys to the index.  Keys
+	// the engine holds no data for are skipped.
+	LoadSeries(shardID uint64, index *DatabaseIndex, keys []string) error
+
 	CreateIterator(opt influxql.IteratorOptions) (influxql.Iterator, error)
 	SeriesKeys(opt influxql.IteratorOptions) (influxql.SeriesList, error)
+	IteratorCost(opt influxql.IteratorOptions) (influxql.IteratorCost, error)
 	WritePoints(points []models.Point) error
 	ContainsSeries(keys []string) (map[string]bool, error)
+	ContainsSeriesRange(keys []string, min, max int64) (map[string]bool, error)
 	DeleteSeries(keys []string) error
 	DeleteSeriesRange(keys []string, min, max int64) error
 	DeleteMeasurement(name string, seriesKeys []string) error
 	SeriesCount() (n int, err error)
 	MeasurementFields(measurement string) *MeasurementFields
 
+	// DiskSize returns the size in bytes of the engine's data files.
+	DiskSize() int64
+
 	// Format will return the format for the engine
 	Format() EngineFormat
 
 	io.WriterTo
 
 	Backup(w io.Writer, basePath string, since time.Time) error
+
+	// Import adds the data files of a tar archive written by Backup to the
+	// engine.  The series of the imported files are not added to the index.
+	Import(r io.Reader) error
+
+	// FlushCache writes the cache to new data files and removes the WAL
+	// segments holding it.
+	FlushCache() error
+
+	// CreateSnapshot creates a point-in-time copy of the engine's data files
+	// using hard links and returns the path of the directory holding it.
+	CreateSnapshot() (string, error)
+
+	// ScheduleFullCompaction requests a full compaction of the engine's data files.
+	ScheduleFullCompaction() error
+
+	// Compactions returns the status of the compactions currently running.
+	Compactions() []CompactionStatus
+
+	// SetCompactionsEnabled pauses or resumes background compactions.
+	SetCompactionsEnabled(enabled bool)
+
+	// SetReadOnly makes the engine read-only or writable again.  A read-only
+	// engine flushes buffered writes to its data files, releases the
+	// structures used for writes and fails writes with ErrShardReadOnly.
+	SetReadOnly(readOnly bool) error
+}
+
+// CompactionStatus describes the progress of a running compaction.
+type CompactionStatus struct {
+	ShardID uint64    `json:"shardID"`
+	Level   int       `json:"level"` // zero for a full compaction
+	Files   []string  `json:"files"`
+	Start   time.Time `json:"start"`
+
+	// TotalBytes is the combined size of the input files and BytesProcessed
+	// is the number of bytes of their blocks read so far.  Input bytes are
+	// used since the output can be much smaller than the input, such as when
+	// blocks are merged or tombstoned values are dropped.
+	TotalBytes     int64 `json:"totalBytes"`
+	BytesProcessed int64 `json:"bytesProcessed"`
+}
+
+// ETA returns the estimated time remaining for the compaction, based on its
+// rate of progress so far. Zero is returned if no estimate can be made yet.
+func (s *CompactionStatus) ETA(now time.Time) time.Duration {
+	if s.BytesProcessed <= 0 || s.TotalBytes <= s.BytesProcessed {
+		return 0
+	}
+	elapsed := now.Sub(s.Start)
+	return time.Duration(float64(elapsed) * float64(s.TotalBytes-s.BytesProcessed) / float64(s.BytesProcessed))
 }
 
 // EngineFormat represents the format for an engine.
@@ -16,7 +16,7 @@ import (
 )
 
 var (
-	ErrCacheMemoryExceeded    = fmt.Errorf("cache maximum memory size exceeded")
+	ErrCacheMemoryExceeded    = influxdb.ErrCacheMemoryExceeded
 	ErrCacheInvalidCheckpoint = fmt.Errorf("invalid checkpoint")
 	ErrSnapshotInProgress     = fmt.Errorf("snapshot in progress")
 )
@@ -26,6 +26,10 @@ type entry struct {
 	mu       sync.RWMutex
 	values   Values // All stored values.
 	needSort bool   // true if the values are out of order and require deduping.
+
+	// minTime and maxTime are the bounds of the times of the values, so a
+	// time range can be checked without reading the values.
+	minTime, maxTime int64
 }
 
 // newEntry returns a new instance of entry.
@@ -41,12 +45,20 @@ func (e *entry) add(values []Value) {
 		needSort bool
 	)
 
+	minTime, maxTime := int64(math.MaxInt64), int64(math.MinInt64)
 	for _, v := range values {
-		if v.UnixNano() <= prevTime {
+		t := v.UnixNano()
+		if t <= prevTime {
 			needSort = true
-			break
 		}
-		prevTime = v.UnixNano()
+		prevTime = t
+
+		if t < minTime {
+			minTime = t
+		}
+		if t > maxTime {
+			maxTime = t
+		}
 	}
 
 	// if there are existing values make sure they're all less than the first of
@@ -57,6 +69,7 @@ func (e *entry) add(values []Value) {
 	}
 	if len(e.values) == 0 {
 		e.values = values
+		e.minTime, e.maxTime = minTime, maxTime
 	} else {
 		l := len(e.values)
 		lastValTime := e.values[l-1].UnixNano()
@@ -64,6 +77,13 @@ func (e *entry) add(values []Value) {
 			e.needSort = true
 		}
 		e.values = append(e.values, values...)
+
+		if minTime < e.minTime {
+			e.minTime = minTime
+		}
+		if maxTime > e.maxTime {
+			e.maxTime = maxTime
+		}
 	}
 	e.mu.Unlock()
 }
@@ -93,9 +113,40 @@ func (e *entry) count() int {
 func (e *entry) filter(min, max int64) {
 	e.mu.Lock()
 	e.values = e.values.Filter(min, max)
+
+	// The values may not be sorted yet so the bounds are found by scanning
+	// the remaining values.
+	e.minTime, e.maxTime = math.MaxInt64, math.MinInt64
+	for _, v := range e.values {
+		if t := v.UnixNano(); t < e.minTime {
+			e.minTime = t
+		}
+		if t := v.UnixNano(); t > e.maxTime {
+			e.maxTime = t
+		}
+	}
 	e.mu.Unlock()
 }
 
+// timeRange returns the bounds of the times of the entry's values.  ok is
+// false if the entry has no values.
+func (e *entry) timeRange() (min, max int64, ok bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.minTime, e.maxTime, len(e.values) > 0
+}
+
+// valuesRange returns the values between min and max inclusive.  The entry
+// must be deduplicated and e.mu must be held.
+func (e *entry) valuesRange(min, max int64) Values {
+	i := sort.Search(len(e.values), func(i int) bool { return e.values[i].UnixNano() >= min })
+	j := sort.Search(len(e.values), func(i int) bool { return e.values[i].UnixNano() > max })
+	if i >= j {
+		return nil
+	}
+	return e.values[i:j]
+}
+
 // size returns the size of this entry in bytes
 func (e *entry) size() int {
 	e.mu.RLock()
@@ -134,6 +185,11 @@ type Cache struct {
 	snapshotSize uint64
 	snapshotting bool
 
+	// snapshotTombstones holds the ranges of time deleted from the snapshot
+	// while it is being written.  They are hidden from queries and applied to
+	// the snapshot if writing it fails.
+	snapshotTombstones map[string][]TimeRange
+
 	// This number is the number of pending or failed WriteSnaphot attempts since the last successful one.
 	snapshotAttempts int
 
@@ -287,7 +343,16 @@ func (c *Cache) ClearSnapshot(success bool) {
 		c.snapshot = nil
 
 		c.updateSnapshots()
+	} else {
+		// The snapshot is kept to be written again so apply the deletes
+		// made while it was being written.
+		for k, tombstones := range c.snapshotTombstones {
+			for _, t := range tombstones {
+				c.snapshotSize -= c.snapshot.deleteRange(k, t.Min, t.Max)
+			}
+		}
 	}
+	c.snapshotTombstones = nil
 }
 
 // Size returns the number of point-calcuated bytes the cache currently uses.
@@ -317,9 +382,59 @@ func (c *Cache) Keys() []string {
 
 // Values returns a copy of all values, deduped and sorted, for the given key.
 func (c *Cache) Values(key string) Values {
+	return c.ValuesRange(key, math.MinInt64, math.MaxInt64)
+}
+
+// ValuesRange returns a copy of the values for key with times between min and
+// max.  Only the values in the range are copied, so cursors over a bounded
+// query do not copy the whole entry.
+func (c *Cache) ValuesRange(key string, min, max int64) Values {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.merged(key, min, max)
+}
+
+// ContainsRange returns true if key may have values between min and max
+// (inclusive).  Only the time bounds of the entries are checked so no values
+// are read, and a key is also reported if the range falls between two of its
+// values.
+func (c *Cache) ContainsRange(key string, min, max int64) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.merged(key)
+
+	if e := c.store[key]; e != nil {
+		if emin, emax, ok := e.timeRange(); ok && emin <= max && emax >= min {
+			return true
+		}
+	}
+
+	if c.snapshot == nil {
+		return false
+	}
+	e := c.snapshot.store[key]
+	if e == nil {
+		return false
+	}
+	emin, emax, ok := e.timeRange()
+	if !ok || emin > max || emax < min {
+		return false
+	}
+
+	// Deletes from a snapshot being written are only recorded as tombstones,
+	// so the key has no values in the range if a tombstone covers the part of
+	// the snapshot entry overlapping it.
+	if emin < min {
+		emin = min
+	}
+	if emax > max {
+		emax = max
+	}
+	for _, t := range c.snapshotTombstones[key] {
+		if t.Min <= emin && t.Max >= emax {
+			return false
+		}
+	}
+	return true
 }
 
 // Delete will remove the keys from the cache
@@ -334,22 +449,45 @@ func (c *Cache) DeleteRange(keys []string, min, max int64) {
 	defer c.mu.Unlock()
 
 	for _, k := range keys {
-		origSize := c.store[k].size()
-		if min == math.MinInt64 && max == math.MaxInt64 {
-			c.size -= uint64(origSize)
-			delete(c.store, k)
+		c.size -= c.deleteRange(k, min, max)
+
+		if c.snapshot == nil || c.snapshot.store[k] == nil {
 			continue
 		}
 
-		c.store[k].filter(min, max)
-		if c.store[k].count() == 0 {
-			delete(c.store, k)
-			c.size -= uint64(origSize)
+		// A snapshot being written is read without locks, so the delete is
+		// only recorded until the snapshot is cleared.
+		if c.snapshotting {
+			if c.snapshotTombstones == nil {
+				c.snapshotTombstones = make(map[string][]TimeRange)
+			}
+			c.snapshotTombstones[k] = append(c.snapshotTombstones[k], TimeRange{Min: min, Max: max})
 			continue
 		}
+		c.snapshotSize -= c.snapshot.deleteRange(k, min, max)
+	}
+}
+
+// deleteRange removes the values of key between min and max and returns the
+// number of bytes removed.  It assumes the lock has been taken.
+func (c *Cache) deleteRange(key string, min, max int64) uint64 {
+	e := c.store[key]
+	if e == nil {
+		return 0
+	}
 
-		c.size -= uint64(origSize - c.store[k].size())
+	origSize := e.size()
+	if min == math.MinInt64 && max == math.MaxInt64 {
+		delete(c.store, key)
+		return uint64(origSize)
 	}
+
+	e.filter(min, max)
+	if e.count() == 0 {
+		delete(c.store, key)
+		return uint64(origSize)
+	}
+	return uint64(origSize - e.size())
 }
 
 func (c *Cache) SetMaxSize(size uint64) {
@@ -358,11 +496,11 @@ func (c *Cache) SetMaxSize(size uint64) {
 	c.mu.Unlock()
 }
 
-// merged returns a copy of hot and snapshot values. The copy will be merged, deduped, and
-// sorted. It assumes all necessary locks have been taken. If the caller knows that the
-// the hot source data for the key will not be changed, it is safe to call this function
-// with a read-lock taken. Otherwise it must be called with a write-lock taken.
-func (c *Cache) merged(key string) Values {
+// merged returns a copy of hot and snapshot values between min and max. The copy will be
+// merged, deduped, and sorted. It assumes all necessary locks have been taken. If the caller
+// knows that the the hot source data for the key will not be changed, it is safe to call
+// this function with a read-lock taken. Otherwise it must be called with a write-lock taken.
+func (c *Cache) merged(key string, min, max int64) Values {
 	e := c.store[key]
 	if e == nil {
 		if c.snapshot == nil {
@@ -375,21 +513,34 @@ func (c *Cache) merged(key string) Values {
 
 	// Build the sequence of entries that will be returned, in the correct order.
 	// Calculate the required size of the destination buffer.
-	var entries []*entry
+	// The entries are read-locked until their values have been copied.
+	var entries []Values
 	sz := 0
 
 	if c.snapshot != nil {
 		snapshotEntries := c.snapshot.store[key]
 		if snapshotEntries != nil {
 			snapshotEntries.deduplicate() // guarantee we are deduplicated
-			entries = append(entries, snapshotEntries)
-			sz += snapshotEntries.count()
+			snapshotEntries.mu.RLock()
+			defer snapshotEntries.mu.RUnlock()
+			values := snapshotEntries.valuesRange(min, max)
+			if tombstones := c.snapshotTombstones[key]; len(tombstones) > 0 {
+				values = excludeTombstones(values, tombstones)
+			}
+			if len(values) > 0 {
+				entries = append(entries, values)
+				sz += len(values)
+			}
 		}
 	}
 
 	if e != nil {
-		entries = append(entries, e)
-		sz += e.count()
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		if values := e.valuesRange(min, max); len(values) > 0 {
+			entries = append(entries, values)
+			sz += len(values)
+		}
 	}
 
 	// Any entries? If not, return.
@@ -404,12 +555,10 @@ func (c *Cache) merged(key string) Values {
 	values := make(Values, sz)
 	n := 0
 	for _, e := range entries {
-		e.mu.RLock()
 		if !needSort && n > 0 {
-			needSort = values[n-1].UnixNano() >= e.values[0].UnixNano()
+			needSort = values[n-1].UnixNano() >= e[0].UnixNano()
 		}
-		n += copy(values[n:], e.values)
-		e.mu.RUnlock()
+		n += copy(values[n:], e)
 	}
 
 	if needSort {
@@ -419,6 +568,25 @@ func (c *Cache) merged(key string) Values {
 	return values
 }
 
+// excludeTombstones returns a copy of values without the values deleted by
+// tombstones.
+func excludeTombstones(values Values, tombstones []TimeRange) Values {
+	a := make(Values, 0, len(values))
+	for _, v := range values {
+		deleted := false
+		for _, t := range tombstones {
+			if v.UnixNano() >= t.Min && v.UnixNano() <= t.Max {
+				deleted = true
+				break
+			}
+		}
+		if !deleted {
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
 // Store returns the underlying cache store. This is not goroutine safe!
 // Protect access by using the Lock and Unlock functions on Cache.
 func (c *Cache) Store() map[string]*entry {
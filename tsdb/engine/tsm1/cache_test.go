@@ -146,6 +146,42 @@ func TestCache_DeleteRange_NoValues(t *testing.T) {
 		t.Fatalf("cache values mismatch: got %v, exp %v", got, exp)
 	}
 }
+
+// Ensure deletes made while a snapshot is written are hidden and are applied
+// to the snapshot if writing it fails.
+func TestCache_DeleteRange_Snapshot(t *testing.T) {
+	values := Values{NewValue(1, 1.0), NewValue(2, 2.0), NewValue(3, 3.0)}
+
+	c := NewCache(0, "")
+	if err := c.Write("foo", values); err != nil {
+		t.Fatalf("failed to write key foo to cache: %s", err.Error())
+	}
+
+	snapshot, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot cache: %v", err)
+	}
+
+	c.DeleteRange([]string{"foo", "bar"}, 2, 2)
+
+	// The snapshot being written must not be modified.
+	if got, exp := len(snapshot.values("foo")), 3; got != exp {
+		t.Fatalf("snapshot values mismatch: got %v, exp %v", got, exp)
+	}
+	if got, exp := c.Values("foo"), (Values{values[0], values[2]}); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("cache values mismatch: got %v, exp %v", got, exp)
+	}
+
+	c.ClearSnapshot(false)
+
+	if got, exp := len(snapshot.values("foo")), 2; got != exp {
+		t.Fatalf("snapshot values mismatch after failed write: got %v, exp %v", got, exp)
+	}
+	if got, exp := c.Values("foo"), (Values{values[0], values[2]}); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("cache values mismatch after failed write: got %v, exp %v", got, exp)
+	}
+}
+
 func TestCache_Cache_Delete(t *testing.T) {
 	v0 := NewValue(1, 1.0)
 	v1 := NewValue(2, 2.0)
@@ -245,6 +281,73 @@ func TestCache_CacheValues(t *testing.T) {
 	}
 }
 
+func TestCache_CacheValuesRange(t *testing.T) {
+	v0 := NewValue(1, 1.0)
+	v1 := NewValue(2, 2.0)
+	v2 := NewValue(3, 3.0)
+	v3 := NewValue(4, 4.0)
+	v4 := NewValue(2, 5.0)
+
+	c := NewCache(512, "")
+	if err := c.Write("foo", Values{v0, v1, v2}); err != nil {
+		t.Fatalf("failed to write 3 values, key foo to cache: %s", err.Error())
+	}
+	if _, err := c.Snapshot(); err != nil {
+		t.Fatalf("failed to snapshot cache: %v", err)
+	}
+	if err := c.Write("foo", Values{v3, v4}); err != nil {
+		t.Fatalf("failed to write 2 values, key foo to cache: %s", err.Error())
+	}
+
+	for _, tt := range []struct {
+		min, max int64
+		exp      Values
+	}{
+		{min: 0, max: 10, exp: Values{v0, v4, v2, v3}},
+		{min: 2, max: 3, exp: Values{v4, v2}},
+		{min: 4, max: 4, exp: Values{v3}},
+		{min: 5, max: 10, exp: nil},
+	} {
+		if got := c.ValuesRange("foo", tt.min, tt.max); !reflect.DeepEqual(tt.exp, got) {
+			t.Fatalf("values for %d-%d incorrect, exp: %v, got %v", tt.min, tt.max, tt.exp, got)
+		}
+	}
+}
+
+// Ensure the time ranges of keys are checked against the hot cache and the
+// snapshot, including the values deleted from either.
+func TestCache_ContainsRange(t *testing.T) {
+	c := NewCache(0, "")
+	if err := c.Write("foo", Values{NewValue(1, 1.0), NewValue(2, 2.0), NewValue(3, 3.0)}); err != nil {
+		t.Fatalf("failed to write key foo to cache: %s", err.Error())
+	}
+	if _, err := c.Snapshot(); err != nil {
+		t.Fatalf("failed to snapshot cache: %v", err)
+	}
+	if err := c.Write("foo", Values{NewValue(8, 8.0), NewValue(6, 6.0)}); err != nil {
+		t.Fatalf("failed to write key foo to cache: %s", err.Error())
+	}
+	c.DeleteRange([]string{"foo"}, 6, 6)
+	c.DeleteRange([]string{"foo"}, 1, 2)
+
+	for _, tt := range []struct {
+		key      string
+		min, max int64
+		exp      bool
+	}{
+		{key: "foo", min: 0, max: 0, exp: false},
+		{key: "foo", min: 1, max: 2, exp: false},
+		{key: "foo", min: 1, max: 3, exp: true},
+		{key: "foo", min: 4, max: 7, exp: false},
+		{key: "foo", min: 8, max: 10, exp: true},
+		{key: "bar", min: 0, max: 10, exp: false},
+	} {
+		if got := c.ContainsRange(tt.key, tt.min, tt.max); got != tt.exp {
+			t.Fatalf("contains %s for %d-%d incorrect, exp: %v, got %v", tt.key, tt.min, tt.max, tt.exp, got)
+		}
+	}
+}
+
 func TestCache_CacheSnapshot(t *testing.T) {
 	v0 := NewValue(2, 0.0)
 	v1 := NewValue(3, 2.0)
@@ -13,11 +13,15 @@ package tsm1
 // one-pass writing of a new TSM file.
 
 import (
+	"crypto/cipher"
 	"fmt"
+	"log"
 	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/influxdb/tsdb"
@@ -44,12 +48,21 @@ type CompactionGroup []string
 type CompactionPlanner interface {
 	Plan(lastWrite time.Time) []CompactionGroup
 	PlanLevel(level int) []CompactionGroup
+
+	// ForceFull causes the planner to return a full compaction plan the
+	// next time Plan is called.
+	ForceFull()
 }
 
 // DefaultPlanner implements CompactionPlanner using a strategy to roll up
 // multiple generations of TSM files into larger files in stages.  It attempts
 // to minimize the number of TSM files on disk while rolling up a bounder number
 // of files.
+//
+// Files written from a cache snapshot start at level 1.  Once enough files
+// exist at a level, they are compacted together into a single file at the
+// next level.  Files at level 4 and above are compacted by Plan in groups
+// until they reach the maximum file size.
 type DefaultPlanner struct {
 	FileStore interface {
 		Stats() []FileStat
@@ -63,6 +76,23 @@ type DefaultPlanner struct {
 	// should always be greater than the CacheFlushWriteColdDuraion
 	CompactFullWriteColdDuration time.Duration
 
+	// LevelFileCounts is the number of files at each level, starting at
+	// level 1, that are compacted together into the next level.  Levels
+	// without a positive count use the default for that level.
+	LevelFileCounts []int
+
+	// MaxFileSize is the size at which a TSM file is considered fully
+	// compacted and is skipped by the planner.  Zero uses the default.
+	MaxFileSize uint32
+
+	// MaxPointsPerBlock is the number of points in a full block.  Files over
+	// the max size whose first block is full are considered fully compacted.
+	// Zero uses the default.
+	MaxPointsPerBlock int
+
+	// Logger is used to log planning decisions.  If nil, nothing is logged.
+	Logger *log.Logger
+
 	// lastPlanCompactedFull will be true if the last time
 	// Plan was called, all files were over the max size
 	// or there was only one file
@@ -70,6 +100,57 @@ type DefaultPlanner struct {
 
 	// lastPlanCheck is the last time Plan was called
 	lastPlanCheck time.Time
+
+	mu sync.RWMutex
+	// forceFull causes the next full plan to include all files
+	// regardless of when the shard was last written.
+	forceFull bool
+}
+
+// defaultLevelFileCounts are the number of files compacted together at
+// levels 1 through 4.
+var defaultLevelFileCounts = []int{
+	tsdb.DefaultCompactLevel1FileCount,
+	tsdb.DefaultCompactLevel2FileCount,
+	tsdb.DefaultCompactLevel3FileCount,
+	tsdb.DefaultCompactLevel4FileCount,
+}
+
+// levelFileCount returns the number of files at level that are compacted together.
+func (c *DefaultPlanner) levelFileCount(level int) int {
+	if level < 1 {
+		level = 1
+	} else if level > len(defaultLevelFileCounts) {
+		level = len(defaultLevelFileCounts)
+	}
+
+	if level <= len(c.LevelFileCounts) && c.LevelFileCounts[level-1] > 0 {
+		return c.LevelFileCounts[level-1]
+	}
+	return defaultLevelFileCounts[level-1]
+}
+
+// maxFileSize returns the size at which a TSM file is considered fully compacted.
+func (c *DefaultPlanner) maxFileSize() uint64 {
+	if c.MaxFileSize > 0 {
+		return uint64(c.MaxFileSize)
+	}
+	return uint64(maxTSMFileSize)
+}
+
+// maxPointsPerBlock returns the number of points in a full block.
+func (c *DefaultPlanner) maxPointsPerBlock() int {
+	if c.MaxPointsPerBlock > 0 {
+		return c.MaxPointsPerBlock
+	}
+	return tsdb.DefaultMaxPointsPerBlock
+}
+
+// logf writes a planning decision to the logger, if one is set.
+func (c *DefaultPlanner) logf(format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+	}
 }
 
 // tsmGeneration represents the TSM files within a generation.
@@ -130,8 +211,25 @@ func (t *tsmGeneration) hasTombstones() bool {
 	return false
 }
 
+// ForceFull causes the next call to Plan to return all TSM files in the
+// shard as a single group, even if the shard is still receiving writes.
+func (c *DefaultPlanner) ForceFull() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.forceFull = true
+}
+
 // PlanLevel returns a set of TSM files to rewrite for a specific level
 func (c *DefaultPlanner) PlanLevel(level int) []CompactionGroup {
+	// Don't plan level compactions while a full compaction is pending so
+	// that the full compaction can pick up every generation.
+	c.mu.RLock()
+	if c.forceFull {
+		c.mu.RUnlock()
+		return nil
+	}
+	c.mu.RUnlock()
+
 	// Determine the generations from all files on disk.  We need to treat
 	// a generation conceptually as a single file even though it may be
 	// split across several files in sequence.
@@ -173,24 +271,23 @@ func (c *DefaultPlanner) PlanLevel(level int) []CompactionGroup {
 	}
 
 	if generations.hasTombstones() {
+		c.logf("planned level %d compaction of %d files to remove tombstoned data", level, len(cGroup))
 		return []CompactionGroup{cGroup}
 	}
 
-	// Ensure we have at least 2 generations.  For higher levels, we want to use more files to maximize
+	// Ensure we have enough files at this level.  For higher levels, we want to use more files to maximize
 	// the compression, but we don't want it unbounded since that can cause backups of compactions at that
-	// level.
+	// level.  By default:
 	// Level 1 -> 2
 	// Level 2 -> 2
 	// Level 3 -> 4
 	// Level 4 -> 4
-	limit := 2
-	if level%2 != 0 {
-		limit = level + 1
-	}
-
+	limit := c.levelFileCount(level)
 	if len(cGroup) < limit {
 		return nil
 	}
+
+	c.logf("planned level %d compaction of %d files (%d files at level, threshold %d)", level, limit, len(cGroup), limit)
 	return []CompactionGroup{cGroup[:limit]}
 
 }
@@ -200,6 +297,31 @@ func (c *DefaultPlanner) PlanLevel(level int) []CompactionGroup {
 func (c *DefaultPlanner) Plan(lastWrite time.Time) []CompactionGroup {
 	generations := c.findGenerations()
 
+	c.mu.Lock()
+	forceFull := c.forceFull
+	c.forceFull = false
+	c.mu.Unlock()
+
+	// If a full compaction was requested, compact every generation regardless
+	// of when the shard was last written.
+	if forceFull {
+		if len(generations) <= 1 && !generations.hasTombstones() {
+			return nil
+		}
+
+		var tsmFiles []string
+		for _, group := range generations {
+			for _, f := range group.files {
+				tsmFiles = append(tsmFiles, f.Path)
+			}
+		}
+		sort.Strings(tsmFiles)
+
+		c.lastPlanCompactedFull = true
+		c.logf("planned forced full compaction of %d files in %d generations", len(tsmFiles), len(generations))
+		return []CompactionGroup{tsmFiles}
+	}
+
 	// first check if we should be doing a full compaction because nothing has been written in a long time
 	if !c.lastPlanCompactedFull && c.CompactFullWriteColdDuration > 0 && time.Now().Sub(lastWrite) > c.CompactFullWriteColdDuration && len(generations) > 1 {
 		var tsmFiles []string
@@ -207,7 +329,7 @@ func (c *DefaultPlanner) Plan(lastWrite time.Time) []CompactionGroup {
 			var skip bool
 
 			// Skip the file if it's over the max size and contains a full block and it does not have any tombstones
-			if group.size() > uint64(maxTSMFileSize) && c.FileStore.BlockCount(group.files[0].Path, 1) == tsdb.DefaultMaxPointsPerBlock && !group.hasTombstones() {
+			if group.size() > c.maxFileSize() && c.FileStore.BlockCount(group.files[0].Path, 1) == c.maxPointsPerBlock() && !group.hasTombstones() {
 				skip = true
 			}
 
@@ -237,6 +359,7 @@ func (c *DefaultPlanner) Plan(lastWrite time.Time) []CompactionGroup {
 			return nil
 		}
 
+		c.logf("planned full compaction of %d files: no writes in %s", len(tsmFiles), c.CompactFullWriteColdDuration)
 		return []CompactionGroup{tsmFiles}
 	}
 
@@ -277,7 +400,7 @@ func (c *DefaultPlanner) Plan(lastWrite time.Time) []CompactionGroup {
 		}
 
 		// Skip the file if it's over the max size and contains a full block
-		if g.size() > uint64(maxTSMFileSize) && c.FileStore.BlockCount(g.files[0].Path, 1) == tsdb.DefaultMaxPointsPerBlock {
+		if g.size() > c.maxFileSize() && c.FileStore.BlockCount(g.files[0].Path, 1) == c.maxPointsPerBlock() {
 			start = i + 1
 		}
 
@@ -292,9 +415,9 @@ func (c *DefaultPlanner) Plan(lastWrite time.Time) []CompactionGroup {
 		}
 	}
 
-	// step is how may files to compact in a group.  We want to clamp it at 4 but also stil
-	// return groups smaller than 4.
-	step := 4
+	// step is how may files to compact in a group.  We want to clamp it at the level 4
+	// file count but also stil return groups smaller than that.
+	step := c.levelFileCount(4)
 	if step > end {
 		step = end
 	}
@@ -321,7 +444,7 @@ func (c *DefaultPlanner) Plan(lastWrite time.Time) []CompactionGroup {
 			}
 
 			// Skip the file if it's over the max size and it contains a full block
-			if gen.size() >= uint64(maxTSMFileSize) && c.FileStore.BlockCount(gen.files[0].Path, 1) == tsdb.DefaultMaxPointsPerBlock && !gen.hasTombstones() {
+			if gen.size() >= c.maxFileSize() && c.FileStore.BlockCount(gen.files[0].Path, 1) == c.maxPointsPerBlock() && !gen.hasTombstones() {
 				startIndex++
 				continue
 			}
@@ -369,6 +492,10 @@ func (c *DefaultPlanner) Plan(lastWrite time.Time) []CompactionGroup {
 		tsmFiles = append(tsmFiles, cGroup)
 	}
 
+	if len(tsmFiles) > 0 {
+		c.logf("planned %d level 4 compaction groups (threshold %d files per group)", len(tsmFiles), step)
+	}
+
 	c.lastPlanCompactedFull = false
 
 	return tsmFiles
@@ -406,25 +533,74 @@ func (c *DefaultPlanner) findGenerations() tsmGenerations {
 type Compactor struct {
 	Dir    string
 	Cancel chan struct{}
-	Size   int
+
+	// Size is the maximum number of points encoded in a block.  Zero uses
+	// the default.
+	Size int
+
+	// MaxFileSize is the size at which a new TSM file is started while
+	// compacting.  Zero uses the default.
+	MaxFileSize uint32
+
+	// TempDir is where new TSM files are written before they replace the
+	// files they were compacted from.  Empty uses Dir.
+	TempDir string
+
+	// Verify causes the files written by a compaction to be re-read and
+	// checked before they are returned.  Files that fail are removed.
+	Verify bool
+
+	// FloatEncoding is the encoding of float values in blocks the compactor
+	// encodes.  Blocks copied unchanged keep their encoding.  Empty uses
+	// gorilla compression.
+	FloatEncoding string
+
+	// StringDictionary allows string blocks the compactor encodes to use
+	// dictionary encoding, which older versions cannot read.
+	StringDictionary bool
+
+	// Cipher encrypts the blocks of the files written and decrypts the
+	// blocks of the files compacted.  Nil writes unencrypted files.
+	Cipher cipher.AEAD
 
 	FileStore interface {
 		NextGeneration() int
 	}
 }
 
+// NewCompactor returns a compactor of the TSM files of the shard at path
+// configured by c.  The cipher of encrypted shards must be set separately.
+func NewCompactor(path string, fs *FileStore, c tsdb.Config) *Compactor {
+	db, rp := tsdb.DecodeStorePath(path)
+	compactor := &Compactor{
+		Dir:           path,
+		FileStore:     fs,
+		Size:          c.MaxPointsPerBlock,
+		MaxFileSize:   uint32(c.CompactMaxFileSize),
+		Verify:        c.CompactVerify,
+		FloatEncoding: c.FloatEncodingFor(db),
+
+		StringDictionary: c.StringDictionaryEncoding,
+	}
+
+	// Keep each shard's temp files apart when using a separate temp directory.
+	if c.CompactTempDir != "" {
+		compactor.TempDir = filepath.Join(c.CompactTempDir, db, rp, filepath.Base(path))
+	}
+	return compactor
+}
+
 // WriteSnapshot will write a Cache snapshot to a new TSM files.
 func (c *Compactor) WriteSnapshot(cache *Cache) ([]string, error) {
-	iter := NewCacheKeyIterator(cache, tsdb.DefaultMaxPointsPerBlock)
+	iter := newCacheKeyIterator(cache, c.size(), c.encoding())
 	return c.writeNewFiles(c.FileStore.NextGeneration(), 0, iter)
 }
 
-// Compact will write multiple smaller TSM files into 1 or more larger files
-func (c *Compactor) compact(fast bool, tsmFiles []string) ([]string, error) {
-	size := c.Size
-	if size <= 0 {
-		size = tsdb.DefaultMaxPointsPerBlock
-	}
+// Compact will write multiple smaller TSM files into 1 or more larger files.  If
+// progress is not nil, it is updated as blocks are written.
+func (c *Compactor) compact(fast bool, tsmFiles []string, progress *compactionProgress) ([]string, error) {
+	size := c.size()
+
 	// The new compacted files need to added to the max generation in the
 	// set.  We need to find that max generation as well as the max sequence
 	// number to ensure we write to the next unique location.
@@ -445,6 +621,13 @@ func (c *Compactor) compact(fast bool, tsmFiles []string) ([]string, error) {
 		}
 	}
 
+	// Open the files in generation and sequence order, which the zero padded
+	// file names sort by.  When the same timestamp exists for a key in more
+	// than one file, the value from the newest file wins.  This matches how
+	// queries resolve duplicates across files before they are compacted.
+	tsmFiles = append([]string(nil), tsmFiles...)
+	sort.Strings(tsmFiles)
+
 	// For each TSM file, create a TSM reader
 	var trs []*TSMReader
 	for _, file := range tsmFiles {
@@ -453,7 +636,7 @@ func (c *Compactor) compact(fast bool, tsmFiles []string) ([]string, error) {
 			return nil, err
 		}
 
-		tr, err := NewTSMReader(f)
+		tr, err := NewTSMReaderWithOptions(f, TSMReaderOptions{Cipher: c.Cipher})
 		if err != nil {
 			return nil, err
 		}
@@ -465,33 +648,109 @@ func (c *Compactor) compact(fast bool, tsmFiles []string) ([]string, error) {
 		return nil, nil
 	}
 
-	tsm, err := NewTSMKeyIterator(size, fast, trs...)
+	tsm, err := newTSMKeyIterator(size, fast, c.encoding(), trs...)
+	if err != nil {
+		return nil, err
+	}
+
+	ki, _ := tsm.(*tsmKeyIterator)
+	if ki != nil && progress != nil {
+		ki.bytesRead = &progress.bytesRead
+	}
+
+	files, err := c.writeNewFiles(maxGeneration, maxSequence, tsm)
+	if ki != nil && progress != nil {
+		progress.duplicates = ki.duplicates
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return c.writeNewFiles(maxGeneration, maxSequence, tsm)
+	if c.Verify {
+		if err := verifyTSMFiles(files, c.Cipher); err != nil {
+			for _, f := range files {
+				os.Remove(f)
+			}
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// verifyTSMFiles opens each file and verifies all of its blocks.
+func verifyTSMFiles(files []string, aead cipher.AEAD) error {
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+
+		r, err := NewTSMReaderWithOptions(f, TSMReaderOptions{Cipher: aead})
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("verify %s: %v", file, err)
+		}
+
+		err = r.Verify()
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("verify: %v", err)
+		}
+	}
+	return nil
 }
 
 // Compact will write multiple smaller TSM files into 1 or more larger files
 func (c *Compactor) CompactFull(tsmFiles []string) ([]string, error) {
-	return c.compact(false, tsmFiles)
+	return c.compact(false, tsmFiles, nil)
 }
 
 // Compact will write multiple smaller TSM files into 1 or more larger files
 func (c *Compactor) CompactFast(tsmFiles []string) ([]string, error) {
-	return c.compact(true, tsmFiles)
+	return c.compact(true, tsmFiles, nil)
 }
 
 // Clone will return a new compactor that can be used even if the engine is closed
 func (c *Compactor) Clone() *Compactor {
 	return &Compactor{
-		Dir:       c.Dir,
-		FileStore: c.FileStore,
-		Cancel:    c.Cancel,
+		Dir:           c.Dir,
+		FileStore:     c.FileStore,
+		Cancel:        c.Cancel,
+		Size:          c.Size,
+		MaxFileSize:   c.MaxFileSize,
+		TempDir:       c.TempDir,
+		Verify:        c.Verify,
+		FloatEncoding: c.FloatEncoding,
+		Cipher:        c.Cipher,
+
+		StringDictionary: c.StringDictionary,
+	}
+}
+
+// encoding returns how the compactor encodes values into blocks.
+func (c *Compactor) encoding() blockEncoding {
+	return blockEncoding{
+		float:            floatEncodingType(c.FloatEncoding),
+		stringDictionary: c.StringDictionary,
 	}
 }
 
+// size returns the maximum number of points encoded in a block.
+func (c *Compactor) size() int {
+	if c.Size > 0 {
+		return c.Size
+	}
+	return tsdb.DefaultMaxPointsPerBlock
+}
+
+// tempDir returns the directory new TSM files are written to.
+func (c *Compactor) tempDir() string {
+	if c.TempDir != "" {
+		return c.TempDir
+	}
+	return c.Dir
+}
+
 // writeNewFiles will write from the iterator into new TSM files, rotating
 // to a new file when we've reached the max TSM file size
 func (c *Compactor) writeNewFiles(generation, sequence int, iter KeyIterator) ([]string, error) {
@@ -501,7 +760,7 @@ func (c *Compactor) writeNewFiles(generation, sequence int, iter KeyIterator) ([
 	for {
 		sequence++
 		// New TSM files are written to a temp file and renamed when fully completed.
-		fileName := filepath.Join(c.Dir, fmt.Sprintf("%09d-%09d.%s.tmp", generation, sequence, TSMFileExtension))
+		fileName := filepath.Join(c.tempDir(), fmt.Sprintf("%09d-%09d.%s.tmp", generation, sequence, TSMFileExtension))
 
 		// Write as much as possible to this file
 		err := c.write(fileName, iter)
@@ -546,10 +805,15 @@ func (c *Compactor) write(path string, iter KeyIterator) (err error) {
 	}
 
 	// Create the write for the new TSM file.
-	w, err := NewTSMWriter(fd)
+	w, err := NewEncryptedTSMWriter(fd, c.Cipher)
 	if err != nil {
 		return err
 	}
+
+	maxFileSize := c.MaxFileSize
+	if maxFileSize == 0 {
+		maxFileSize = maxTSMFileSize
+	}
 	defer func() {
 		closeErr := w.Close()
 		if err == nil {
@@ -572,14 +836,24 @@ func (c *Compactor) write(path string, iter KeyIterator) (err error) {
 			return err
 		}
 
-		// Write the key and value
-		if err := w.WriteBlock(key, minTime, maxTime, block); err != nil {
+		// Write the key and value, along with the summary of the block if the
+		// iterator knows it without decoding the block.
+		var s *BlockSummary
+		if bs, ok := iter.(blockSummarizer); ok {
+			s = bs.summary()
+		}
+		if sw, ok := w.(summaryBlockWriter); ok && s != nil {
+			err = sw.writeBlockWithSummary(key, minTime, maxTime, block, s)
+		} else {
+			err = w.WriteBlock(key, minTime, maxTime, block)
+		}
+		if err != nil {
 			return err
 		}
 
 		// If we have a max file size configured and we're over it, close out the file
 		// and return the error.
-		if w.Size() > maxTSMFileSize {
+		if w.Size() > maxFileSize {
 			if err := w.WriteIndex(); err != nil {
 				return err
 			}
@@ -595,6 +869,49 @@ func (c *Compactor) write(path string, iter KeyIterator) (err error) {
 	return nil
 }
 
+// compactionProgress tracks a running compaction so that it can be reported
+// while in progress.
+type compactionProgress struct {
+	level      int
+	files      []string
+	start      time.Time
+	totalBytes int64
+
+	// bytesRead is the number of bytes of blocks read from the input files.
+	// It is updated atomically by the key iterator.
+	bytesRead int64
+
+	// duplicates is the number of points with the same timestamp that were
+	// resolved by keeping the value from the newest file.
+	duplicates int64
+}
+
+// newCompactionProgress returns progress for a compaction of files at level.
+func newCompactionProgress(level int, files []string) *compactionProgress {
+	p := &compactionProgress{
+		level: level,
+		files: files,
+		start: time.Now(),
+	}
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			p.totalBytes += fi.Size()
+		}
+	}
+	return p
+}
+
+// status returns a snapshot of the compaction's progress.
+func (p *compactionProgress) status() tsdb.CompactionStatus {
+	return tsdb.CompactionStatus{
+		Level:          p.level,
+		Files:          p.files,
+		Start:          p.start,
+		TotalBytes:     p.totalBytes,
+		BytesProcessed: atomic.LoadInt64(&p.bytesRead),
+	}
+}
+
 // KeyIterator allows iteration over set of keys and values in sorted order.
 type KeyIterator interface {
 	Next() bool
@@ -602,6 +919,28 @@ type KeyIterator interface {
 	Close() error
 }
 
+// blockSummarizer is implemented by key iterators that know the summary of
+// the block last read without decoding it.
+type blockSummarizer interface {
+	summary() *BlockSummary
+}
+
+// encodeBlock encodes values into a block and summarizes them.
+func encodeBlock(values Values, encoding blockEncoding) ([]byte, *BlockSummary, error) {
+	b, err := values.encode(nil, encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	typ, err := BlockType(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := newBlockSummary(0, typ, values)
+	return b, &s, nil
+}
+
 // tsmKeyIterator implements the KeyIterator for set of TSMReaders.  Iteration produces
 // keys in sorted order and the values between the keys sorted and deduped.  If any of
 // the readers have associated tombstone entries, they are returned as part of iteration.
@@ -632,6 +971,9 @@ type tsmKeyIterator struct {
 	// size is the maximum number of values to encode in a single block
 	size int
 
+	// encoding is how values are compressed in blocks that are re-encoded.
+	encoding blockEncoding
+
 	// key is the current key lowest key across all readers that has not be fully exhausted
 	// of values.
 	key string
@@ -640,6 +982,14 @@ type tsmKeyIterator struct {
 	blocks    blocks
 
 	buf []blocks
+
+	// duplicates is the number of values dropped because a newer file
+	// contained a value for the same key and timestamp.
+	duplicates int64
+
+	// bytesRead, if not nil, is atomically incremented by the size of each
+	// block read from the readers.
+	bytesRead *int64
 }
 
 type block struct {
@@ -647,6 +997,10 @@ type block struct {
 	minTime, maxTime int64
 	b                []byte
 	tombstones       []TimeRange
+
+	// summary is the summary of the block if it is known, either from the
+	// file it was read from or from the values it was encoded from.
+	summary *BlockSummary
 }
 
 type blocks []*block
@@ -663,6 +1017,10 @@ func (a blocks) Less(i, j int) bool {
 func (a blocks) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 
 func NewTSMKeyIterator(size int, fast bool, readers ...*TSMReader) (KeyIterator, error) {
+	return newTSMKeyIterator(size, fast, blockEncoding{float: floatCompressedGorilla}, readers...)
+}
+
+func newTSMKeyIterator(size int, fast bool, encoding blockEncoding, readers ...*TSMReader) (KeyIterator, error) {
 	var iter []*BlockIterator
 	for _, r := range readers {
 		iter = append(iter, r.BlockIterator())
@@ -674,6 +1032,7 @@ func NewTSMKeyIterator(size int, fast bool, readers ...*TSMReader) (KeyIterator,
 		pos:       make([]int, len(readers)),
 		size:      size,
 		iterators: iter,
+		encoding:  encoding,
 		fast:      fast,
 		buf:       make([]blocks, len(iter)),
 	}, nil
@@ -689,106 +1048,119 @@ func (k *tsmKeyIterator) Next() bool {
 		}
 	}
 
-	// Read the next block from each TSM iterator
-	for i, v := range k.buf {
-		if v == nil {
-			iter := k.iterators[i]
-			if iter.Next() {
-				key, minTime, maxTime, _, b, err := iter.Read()
-				if err != nil {
-					k.err = err
-				}
+	for {
+		// Read the next block from each TSM iterator
+		for i := range k.buf {
+			k.fill(i)
+		}
 
-				// This block may have ranges of time removed from it that would
-				// reduce the block min and max time.
-				tombstones := iter.r.TombstoneRange(key)
-				minTime, maxTime = k.clampTombstoneRange(tombstones, minTime, maxTime)
-
-				k.buf[i] = append(k.buf[i], &block{
-					minTime:    minTime,
-					maxTime:    maxTime,
-					key:        key,
-					b:          b,
-					tombstones: tombstones,
-				})
-
-				blockKey := key
-				for iter.PeekNext() == blockKey {
-					iter.Next()
-					key, minTime, maxTime, _, b, err := iter.Read()
-					if err != nil {
-						k.err = err
-					}
-
-					tombstones := iter.r.TombstoneRange(key)
-					minTime, maxTime = k.clampTombstoneRange(tombstones, minTime, maxTime)
-
-					k.buf[i] = append(k.buf[i], &block{
-						minTime:    minTime,
-						maxTime:    maxTime,
-						key:        key,
-						b:          b,
-						tombstones: tombstones,
-					})
-				}
+		// Each reader could have a different key that it's currently at, need to find
+		// the next smallest one to keep the sort ordering.
+		var minKey string
+		for _, b := range k.buf {
+			// block could be nil if the iterator has been exhausted for that file
+			if len(b) == 0 {
+				continue
+			}
+			if minKey == "" || b[0].key < minKey {
+				minKey = b[0].key
 			}
 		}
-	}
 
-	// Each reader could have a different key that it's currently at, need to find
-	// the next smallest one to keep the sort ordering.
-	var minKey string
-	for _, b := range k.buf {
-		// block could be nil if the iterator has been exhausted for that file
-		if len(b) == 0 {
-			continue
+		// Now we need to find all blocks that match the min key so we can combine and dedupe
+		// the blocks if necessary
+		for i, b := range k.buf {
+			if len(b) == 0 {
+				continue
+			}
+			if b[0].key == minKey {
+				k.blocks = append(k.blocks, b...)
+				k.buf[i] = nil
+			}
 		}
-		if minKey == "" || b[0].key < minKey {
-			minKey = b[0].key
+
+		// No blocks left, we're done
+		if len(k.blocks) == 0 {
+			return false
 		}
-	}
 
-	// Now we need to find all blocks that match the min key so we can combine and dedupe
-	// the blocks if necessary
-	for i, b := range k.buf {
-		if len(b) == 0 {
-			continue
+		// Only one block and no tombstoned values, just return early everything after is wasted work
+		if len(k.blocks) == 1 && len(k.blocks[0].tombstones) == 0 {
+			return true
 		}
-		if b[0].key == minKey {
-			k.blocks = append(k.blocks, b...)
-			k.buf[i] = nil
+
+		// If we have more than one block or any partially tombstoned blocks, we many need to dedup
+		dedup := len(k.blocks[0].tombstones) > 0
+
+		if len(k.blocks) > 1 {
+			// Quickly scan each block to see if any overlap with the prior block, if they overlap then
+			// we need to dedup as there may be duplicate points now
+			for i := 1; !dedup && i < len(k.blocks); i++ {
+				if k.blocks[i].minTime <= k.blocks[i-1].maxTime || len(k.blocks[i].tombstones) > 0 {
+					dedup = true
+					break
+				}
+			}
 		}
-	}
 
-	// No blocks left, we're done
-	if len(k.blocks) == 0 {
-		return false
-	}
+		k.blocks = k.combine(dedup)
+		if len(k.blocks) > 0 || k.err != nil {
+			return len(k.blocks) > 0
+		}
 
-	// Only one block and no tombstoned values, just return early everything after is wasted work
-	if len(k.blocks) == 1 && len(k.blocks[0].tombstones) == 0 {
-		return true
+		// Every value for this key was removed by tombstones so move on to the next key.
 	}
+}
 
-	// If we have more than one block or any partially tombstoned blocks, we many need to dedup
-	dedup := len(k.blocks[0].tombstones) > 0
-
-	if len(k.blocks) > 1 {
-		dedup = len(k.blocks[0].tombstones) > 0
+// fill reads the blocks for the next key from the i'th reader into its buffer
+// if the buffer is empty.  Blocks that are entirely covered by a tombstone are
+// dropped without being decoded.
+func (k *tsmKeyIterator) fill(i int) {
+	iter := k.iterators[i]
+	for len(k.buf[i]) == 0 && iter.Next() {
+		key, minTime, maxTime, _, b, err := iter.Read()
+		if err != nil {
+			k.err = err
+		}
+		k.appendBlock(i, key, minTime, maxTime, b, iter.summary(), iter.r.TombstoneRange(key))
 
-		// Quickly scan each block to see if any overlap with the prior block, if they overlap then
-		// we need to dedup as there may be duplicate points now
-		for i := 1; !dedup && i < len(k.blocks); i++ {
-			if k.blocks[i].minTime <= k.blocks[i-1].maxTime || len(k.blocks[i].tombstones) > 0 {
-				dedup = true
-				break
+		blockKey := key
+		for iter.PeekNext() == blockKey {
+			iter.Next()
+			key, minTime, maxTime, _, b, err := iter.Read()
+			if err != nil {
+				k.err = err
 			}
+			k.appendBlock(i, key, minTime, maxTime, b, iter.summary(), iter.r.TombstoneRange(key))
 		}
 	}
+}
+
+// appendBlock adds a block to the i'th buffer along with the tombstones that
+// overlap it.  The block is skipped if a single tombstone covers all of it.
+func (k *tsmKeyIterator) appendBlock(i int, key string, minTime, maxTime int64, b []byte, summary *BlockSummary, tombstones []TimeRange) {
+	if k.bytesRead != nil {
+		atomic.AddInt64(k.bytesRead, int64(len(b)))
+	}
 
-	k.blocks = k.combine(dedup)
+	var overlapping []TimeRange
+	for _, t := range tombstones {
+		if t.Min <= minTime && t.Max >= maxTime {
+			return
+		}
+		if t.Min <= maxTime && t.Max >= minTime {
+			overlapping = append(overlapping, t)
+		}
+	}
 
-	return len(k.blocks) > 0
+	k.buf[i] = append(k.buf[i], &block{
+		minTime:    minTime,
+		maxTime:    maxTime,
+		key:        key,
+		b:          b,
+		tombstones: overlapping,
+		summary:    summary,
+	})
 }
 
 // combine returns a new set of blocks using the current blocks in the buffers.  If dedup
@@ -812,7 +1184,9 @@ func (k *tsmKeyIterator) combine(dedup bool) blocks {
 			decoded = append(decoded, v...)
 
 		}
+		n := len(decoded)
 		decoded = decoded.Deduplicate()
+		k.duplicates += int64(n - len(decoded))
 
 		// Since we combined multiple blocks, we could have more values than we should put into
 		// a single block.  We need to chunk them up into groups and re-encode them.
@@ -864,7 +1238,7 @@ func (k *tsmKeyIterator) combine(dedup bool) blocks {
 
 func (k *tsmKeyIterator) chunk(dst blocks, values []Value) blocks {
 	for len(values) > k.size {
-		cb, err := Values(values[:k.size]).Encode(nil)
+		cb, s, err := encodeBlock(values[:k.size], k.encoding)
 		if err != nil {
 			k.err = err
 			return nil
@@ -875,13 +1249,14 @@ func (k *tsmKeyIterator) chunk(dst blocks, values []Value) blocks {
 			maxTime: values[k.size-1].UnixNano(),
 			key:     k.blocks[0].key,
 			b:       cb,
+			summary: s,
 		})
 		values = values[k.size:]
 	}
 
 	// Re-encode the remaining values into the last block
 	if len(values) > 0 {
-		cb, err := Values(values).Encode(nil)
+		cb, s, err := encodeBlock(values, k.encoding)
 		if err != nil {
 			k.err = err
 			return nil
@@ -892,23 +1267,12 @@ func (k *tsmKeyIterator) chunk(dst blocks, values []Value) blocks {
 			maxTime: values[len(values)-1].UnixNano(),
 			key:     k.blocks[0].key,
 			b:       cb,
+			summary: s,
 		})
 	}
 	return dst
 }
 
-func (k *tsmKeyIterator) clampTombstoneRange(tombstones []TimeRange, minTime, maxTime int64) (int64, int64) {
-	for _, t := range tombstones {
-		if t.Min > minTime {
-			minTime = t.Min
-		}
-		if t.Max < maxTime {
-			maxTime = t.Max
-		}
-	}
-	return minTime, maxTime
-}
-
 func (k *tsmKeyIterator) Read() (string, int64, int64, []byte, error) {
 	if len(k.blocks) == 0 {
 		return "", 0, 0, nil, k.err
@@ -918,6 +1282,14 @@ func (k *tsmKeyIterator) Read() (string, int64, int64, []byte, error) {
 	return block.key, block.minTime, block.maxTime, block.b, k.err
 }
 
+// summary returns the summary of the block last read, if it is known.
+func (k *tsmKeyIterator) summary() *BlockSummary {
+	if len(k.blocks) == 0 {
+		return nil
+	}
+	return k.blocks[0].summary
+}
+
 func (k *tsmKeyIterator) Close() error {
 	k.values = nil
 	k.pos = nil
@@ -934,21 +1306,30 @@ type cacheKeyIterator struct {
 	cache *Cache
 	size  int
 
+	// encoding is how values are compressed in blocks.
+	encoding blockEncoding
+
 	k                string
 	order            []string
 	values           []Value
 	block            []byte
+	blockSummary     *BlockSummary
 	minTime, maxTime time.Time
 	err              error
 }
 
 func NewCacheKeyIterator(cache *Cache, size int) KeyIterator {
+	return newCacheKeyIterator(cache, size, blockEncoding{float: floatCompressedGorilla})
+}
+
+func newCacheKeyIterator(cache *Cache, size int, encoding blockEncoding) KeyIterator {
 	keys := cache.Keys()
 
 	return &cacheKeyIterator{
-		size:  size,
-		cache: cache,
-		order: keys,
+		size:     size,
+		cache:    cache,
+		order:    keys,
+		encoding: encoding,
 	}
 }
 
@@ -973,14 +1354,19 @@ func (c *cacheKeyIterator) Read() (string, int64, int64, []byte, error) {
 	var err error
 	if len(c.values) > c.size {
 		maxTime = c.values[c.size-1].UnixNano()
-		b, err = Values(c.values[:c.size]).Encode(nil)
+		b, c.blockSummary, err = encodeBlock(c.values[:c.size], c.encoding)
 	} else {
-		b, err = Values(c.values).Encode(nil)
+		b, c.blockSummary, err = encodeBlock(c.values, c.encoding)
 	}
 
 	return c.k, minTime, maxTime, b, err
 }
 
+// summary returns the summary of the block last read.
+func (c *cacheKeyIterator) summary() *BlockSummary {
+	return c.blockSummary
+}
+
 func (c *cacheKeyIterator) Close() error {
 	return nil
 }
@@ -0,0 +1,60 @@
+package tsm1
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Ensure compaction progress counts the bytes of the input blocks read, so the
+// estimate does not depend on how much smaller the output is.
+func TestCompactor_Progress_BytesRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tsm1-compact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var files []string
+	var blockBytes int64
+	for gen := 1; gen <= 2; gen++ {
+		path := filepath.Join(dir, fmt.Sprintf("%09d-%09d.%s", gen, 1, TSMFileExtension))
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewTSMWriter(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Both files hold the same points so the output is half the input.
+		values := Values{NewValue(1, 1.0), NewValue(2, 2.0)}
+		block, err := values.Encode(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blockBytes += int64(len(block))
+
+		if err := w.Write("cpu", values); err != nil {
+			t.Fatal(err)
+		} else if err := w.WriteIndex(); err != nil {
+			t.Fatal(err)
+		} else if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	c := &Compactor{Dir: dir}
+	progress := newCompactionProgress(1, files)
+	if _, err := c.compact(false, files, progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := progress.status(); s.BytesProcessed != blockBytes {
+		t.Fatalf("unexpected bytes processed: got %d, exp %d", s.BytesProcessed, blockBytes)
+	}
+}
@@ -77,6 +77,85 @@ func TestCompactor_Snapshot(t *testing.T) {
 	}
 }
 
+// Ensures a snapshot honors the compactor's max points per block.
+func TestCompactor_Snapshot_Size(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	c := tsm1.NewCache(0, "")
+	for i := 0; i < 5; i++ {
+		if err := c.Write("cpu,host=A#!~#value", []tsm1.Value{tsm1.NewValue(int64(i), float64(i))}); err != nil {
+			t.Fatalf("failed to write key to cache: %s", err.Error())
+		}
+	}
+
+	compactor := &tsm1.Compactor{
+		Dir:       dir,
+		FileStore: &fakeFileStore{},
+		Size:      2,
+	}
+
+	files, err := compactor.WriteSnapshot(c)
+	if err != nil {
+		t.Fatalf("unexpected error writing snapshot: %v", err)
+	}
+
+	r := MustOpenTSMReader(files[0])
+	defer r.Close()
+
+	if got, exp := len(r.Entries("cpu,host=A#!~#value")), 3; got != exp {
+		t.Fatalf("block count mismatch: got %v, exp %v", got, exp)
+	}
+}
+
+// Ensures a snapshot can store float values without compression.
+func TestCompactor_Snapshot_RawFloatEncoding(t *testing.T) {
+	var values []tsm1.Value
+	for i := 0; i < 1000; i++ {
+		values = append(values, tsm1.NewValue(int64(i), 1.5))
+	}
+
+	sizes := map[string]int64{}
+	for _, encoding := range []string{"gorilla", "raw"} {
+		dir := MustTempDir()
+		defer os.RemoveAll(dir)
+
+		c := tsm1.NewCache(0, "")
+		if err := c.Write("cpu,host=A#!~#value", values); err != nil {
+			t.Fatalf("failed to write key to cache: %s", err.Error())
+		}
+
+		compactor := &tsm1.Compactor{
+			Dir:           dir,
+			FileStore:     &fakeFileStore{},
+			FloatEncoding: encoding,
+		}
+
+		files, err := compactor.WriteSnapshot(c)
+		if err != nil {
+			t.Fatalf("unexpected error writing snapshot: %v", err)
+		}
+
+		r := MustOpenTSMReader(files[0])
+		got, err := r.ReadAll("cpu,host=A#!~#value")
+		if err != nil {
+			t.Fatalf("unexpected error reading: %v", err)
+		} else if len(got) != len(values) {
+			t.Fatalf("%s: values length mismatch: got %v, exp %v", encoding, len(got), len(values))
+		}
+		for i := range values {
+			assertValueEqual(t, got[i], values[i])
+		}
+		sizes[encoding] = int64(r.Size())
+		r.Close()
+	}
+
+	// Raw values take 8 bytes each while repeated values compress to a bit.
+	if sizes["raw"] < sizes["gorilla"]+7000 {
+		t.Fatalf("expected raw encoding to be larger: raw %d, gorilla %d", sizes["raw"], sizes["gorilla"])
+	}
+}
+
 // Ensures that a compaction will properly merge multiple TSM files
 func TestCompactor_CompactFull(t *testing.T) {
 	dir := MustTempDir()
@@ -359,6 +438,176 @@ func TestCompactor_CompactFull_TombstonedSkipBlock(t *testing.T) {
 	}
 }
 
+// Ensures that the value from the newest file wins when the same timestamp
+// exists in several files, regardless of the order the files are given in.
+func TestCompactor_CompactFull_DuplicateNewestWins(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	a1 := tsm1.NewValue(1, 1.1)
+	f1 := MustWriteTSM(dir, 1, map[string][]tsm1.Value{
+		"cpu,host=A#!~#value": []tsm1.Value{a1},
+	})
+
+	a2 := tsm1.NewValue(1, 1.2)
+	f2 := MustWriteTSM(dir, 2, map[string][]tsm1.Value{
+		"cpu,host=A#!~#value": []tsm1.Value{a2},
+	})
+
+	compactor := &tsm1.Compactor{
+		Dir:       dir,
+		FileStore: &fakeFileStore{},
+	}
+
+	files, err := compactor.CompactFull([]string{f2, f1})
+	if err != nil {
+		t.Fatalf("unexpected error compacting: %v", err)
+	}
+
+	if got, exp := len(files), 1; got != exp {
+		t.Fatalf("files length mismatch: got %v, exp %v", got, exp)
+	}
+
+	r := MustOpenTSMReader(files[0])
+	values, err := r.ReadAll("cpu,host=A#!~#value")
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+
+	if got, exp := len(values), 1; got != exp {
+		t.Fatalf("values length mismatch: got %v, exp %v", got, exp)
+	}
+	assertValueEqual(t, values[0], a2)
+}
+
+// Ensures that compactions keep the summaries of blocks copied unchanged and
+// summarize the blocks they encode, without decoding blocks to summarize them.
+func TestCompactor_CompactFast_BlockSummaries(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	f1 := MustWriteTSM(dir, 1, map[string][]tsm1.Value{
+		"cpu,host=A#!~#value":  []tsm1.Value{tsm1.NewValue(1, 1.5), tsm1.NewValue(2, 3.5)},
+		"disk,host=A#!~#value": []tsm1.Value{tsm1.NewValue(1, 1.0)},
+	})
+
+	// Write the second file with WriteBlock so its blocks have no summaries.
+	f2 := filepath.Join(dir, tsmFileName(2))
+	fd, err := os.Create(f2)
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %v", err)
+	}
+	w, err := tsm1.NewTSMWriter(fd)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+	for _, key := range []string{"disk,host=A#!~#value", "mem,host=A#!~#value"} {
+		block, err := tsm1.Values([]tsm1.Value{tsm1.NewValue(1, 2.0)}).Encode(nil)
+		if err != nil {
+			t.Fatalf("unexpected error encoding: %v", err)
+		}
+		if err := w.WriteBlock(key, 1, 1, block); err != nil {
+			t.Fatalf("unexpected error writing block: %v", err)
+		}
+	}
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	compactor := &tsm1.Compactor{
+		Dir:       dir,
+		FileStore: &fakeFileStore{},
+	}
+
+	files, err := compactor.CompactFast([]string{f1, f2})
+	if err != nil {
+		t.Fatalf("unexpected error compacting: %v", err)
+	}
+
+	r := MustOpenTSMReader(files[0])
+	defer r.Close()
+
+	// The cpu block is copied with the summary of the first file.
+	entries := r.Entries("cpu,host=A#!~#value")
+	if s, ok := r.BlockSummary(&entries[0]); !ok {
+		t.Fatal("expected summary for cpu")
+	} else if s.Offset != entries[0].Offset || s.Count != 2 || s.Sum != 5 {
+		t.Fatalf("unexpected summary for cpu: %+v", s)
+	}
+
+	// The disk blocks overlap, so they are merged, re-encoded and summarized.
+	entries = r.Entries("disk,host=A#!~#value")
+	if s, ok := r.BlockSummary(&entries[0]); !ok {
+		t.Fatal("expected summary for disk")
+	} else if s.Count != 1 || s.Sum != 2 {
+		t.Fatalf("unexpected summary for disk: %+v", s)
+	}
+
+	// The mem block is copied from a file without summaries.
+	entries = r.Entries("mem,host=A#!~#value")
+	if s, ok := r.BlockSummary(&entries[0]); ok {
+		t.Fatalf("unexpected summary for mem: %+v", s)
+	}
+}
+
+// Ensures that new files are written to the temp dir and moved into the
+// shard directory when they replace the compacted files.
+func TestCompactor_CompactFull_TempDir(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	tmpDir := MustTempDir()
+	defer os.RemoveAll(tmpDir)
+
+	a1 := tsm1.NewValue(1, 1.1)
+	f1 := MustWriteTSM(dir, 1, map[string][]tsm1.Value{
+		"cpu,host=A#!~#value": []tsm1.Value{a1},
+	})
+
+	a2 := tsm1.NewValue(2, 1.2)
+	f2 := MustWriteTSM(dir, 2, map[string][]tsm1.Value{
+		"cpu,host=A#!~#value": []tsm1.Value{a2},
+	})
+
+	fs := tsm1.NewFileStore(dir)
+	if err := fs.Open(); err != nil {
+		t.Fatalf("unexpected error opening file store: %v", err)
+	}
+	defer fs.Close()
+
+	compactor := &tsm1.Compactor{
+		Dir:       dir,
+		TempDir:   tmpDir,
+		FileStore: fs,
+	}
+
+	files, err := compactor.CompactFull([]string{f1, f2})
+	if err != nil {
+		t.Fatalf("unexpected error compacting: %v", err)
+	} else if got, exp := len(files), 1; got != exp {
+		t.Fatalf("files length mismatch: got %v, exp %v", got, exp)
+	} else if got, exp := filepath.Dir(files[0]), tmpDir; got != exp {
+		t.Fatalf("temp file dir mismatch: got %v, exp %v", got, exp)
+	}
+
+	if err := fs.Replace([]string{f1, f2}, files); err != nil {
+		t.Fatalf("unexpected error replacing files: %v", err)
+	}
+
+	stats := fs.Stats()
+	if got, exp := len(stats), 1; got != exp {
+		t.Fatalf("file count mismatch: got %v, exp %v", got, exp)
+	} else if got, exp := filepath.Dir(stats[0].Path), dir; got != exp {
+		t.Fatalf("file dir mismatch: got %v, exp %v", got, exp)
+	}
+
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Fatalf("expected temp file to be removed: %v", err)
+	}
+}
+
 // Ensures that a full compaction will decode and combine blocks with
 // partial tombstoned values
 func TestCompactor_CompactFull_TombstonedPartialBlock(t *testing.T) {
@@ -789,6 +1038,55 @@ func TestTSMKeyIterator_MultipleKeysDeleted(t *testing.T) {
 	}
 }
 
+// Ensures that a key whose values are all removed by tombstones does not end
+// iteration early and that the following keys are still returned.
+func TestTSMKeyIterator_KeyRangesDeleted(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	v1 := tsm1.NewValue(1, float64(1))
+	v2 := tsm1.NewValue(2, float64(2))
+	v3 := tsm1.NewValue(3, float64(3))
+	points1 := map[string][]tsm1.Value{
+		"cpu,host=A#!~#value": []tsm1.Value{v1, v2},
+		"cpu,host=B#!~#value": []tsm1.Value{v3},
+	}
+
+	r1 := MustTSMReader(dir, 1, points1)
+	r1.DeleteRange([]string{"cpu,host=A#!~#value"}, 1, 1)
+	r1.DeleteRange([]string{"cpu,host=A#!~#value"}, 2, 2)
+
+	iter, err := tsm1.NewTSMKeyIterator(1, false, r1)
+	if err != nil {
+		t.Fatalf("unexpected error creating WALKeyIterator: %v", err)
+	}
+
+	var keys []string
+	for iter.Next() {
+		key, _, _, block, err := iter.Read()
+		if err != nil {
+			t.Fatalf("unexpected error read: %v", err)
+		}
+
+		values, err := tsm1.DecodeBlock(block, nil)
+		if err != nil {
+			t.Fatalf("unexpected error decode: %v", err)
+		}
+
+		if got, exp := len(values), 1; got != exp {
+			t.Fatalf("values length mismatch: got %v, exp %v", got, exp)
+		}
+		assertValueEqual(t, values[0], v3)
+		keys = append(keys, key)
+	}
+
+	if got, exp := len(keys), 1; got != exp {
+		t.Fatalf("key count mismatch: got %v, exp %v", got, exp)
+	} else if keys[0] != "cpu,host=B#!~#value" {
+		t.Fatalf("key mismatch: got %v, exp %v", keys[0], "cpu,host=B#!~#value")
+	}
+}
+
 func TestCacheKeyIterator_Single(t *testing.T) {
 	v0 := tsm1.NewValue(1, 1.0)
 
@@ -1098,6 +1396,50 @@ func TestDefaultPlanner_PlanLevel_SmallestCompactionStep(t *testing.T) {
 	}
 }
 
+// Ensure that the planner honors a configured level file count
+func TestDefaultPlanner_PlanLevel_LevelFileCount(t *testing.T) {
+	data := []tsm1.FileStat{
+		tsm1.FileStat{
+			Path: "01-01.tsm1",
+			Size: 1 * 1024 * 1024,
+		},
+		tsm1.FileStat{
+			Path: "02-01.tsm1",
+			Size: 1 * 1024 * 1024,
+		},
+		tsm1.FileStat{
+			Path: "03-01.tsm1",
+			Size: 1 * 1024 * 1024,
+		},
+	}
+
+	cp := &tsm1.DefaultPlanner{
+		FileStore: &fakeFileStore{
+			PathsFn: func() []tsm1.FileStat {
+				return data
+			},
+		},
+		LevelFileCounts: []int{4},
+	}
+
+	// Only 3 level 1 files exist, so nothing should be planned.
+	if exp, got := 0, len(cp.PlanLevel(1)); got != exp {
+		t.Fatalf("tsm file plan length mismatch: got %v, exp %v", got, exp)
+	}
+
+	cp.LevelFileCounts = []int{3}
+	tsm := cp.PlanLevel(1)
+	if exp, got := 1, len(tsm); got != exp {
+		t.Fatalf("tsm file plan length mismatch: got %v, exp %v", got, exp)
+	}
+
+	for i, p := range data {
+		if got, exp := tsm[0][i], p.Path; got != exp {
+			t.Fatalf("tsm file mismatch: got %v, exp %v", got, exp)
+		}
+	}
+}
+
 // Ensure that the planner will compact all files if no writes
 // have happened in some interval
 func TestDefaultPlanner_Plan_FullOnCold(t *testing.T) {
@@ -1149,6 +1491,62 @@ func TestDefaultPlanner_Plan_FullOnCold(t *testing.T) {
 	}
 }
 
+// Ensure that the planner will compact all files when a full compaction
+// is forced, even if the shard is still receiving writes.
+func TestDefaultPlanner_Plan_ForceFull(t *testing.T) {
+	data := []tsm1.FileStat{
+		tsm1.FileStat{
+			Path: "01-04.tsm1",
+			Size: 2049 * 1024 * 1024,
+		},
+		tsm1.FileStat{
+			Path: "02-04.tsm1",
+			Size: 129 * 1024 * 1024,
+		},
+		tsm1.FileStat{
+			Path: "03-01.tsm1",
+			Size: 1 * 1024 * 1024,
+		},
+	}
+
+	cp := &tsm1.DefaultPlanner{
+		FileStore: &fakeFileStore{
+			PathsFn: func() []tsm1.FileStat {
+				return data
+			},
+			blockCount: 1000,
+		},
+		CompactFullWriteColdDuration: time.Hour,
+	}
+
+	cp.ForceFull()
+
+	// Level compactions should not be planned while a full compaction is pending.
+	if exp, got := 0, len(cp.PlanLevel(1)); got != exp {
+		t.Fatalf("tsm file length mismatch: got %v, exp %v", got, exp)
+	}
+
+	tsm := cp.Plan(time.Now())
+	if exp, got := 1, len(tsm); got != exp {
+		t.Fatalf("compaction group length mismatch: got %v, exp %v", got, exp)
+	}
+
+	if exp, got := len(data), len(tsm[0]); got != exp {
+		t.Fatalf("tsm file length mismatch: got %v, exp %v", got, exp)
+	}
+
+	for i, p := range data {
+		if got, exp := tsm[0][i], p.Path; got != exp {
+			t.Fatalf("tsm file mismatch: got %v, exp %v", got, exp)
+		}
+	}
+
+	// The forced plan should only be returned once.
+	if exp, got := 0, len(cp.Plan(time.Now())); got != exp {
+		t.Fatalf("compaction group length mismatch: got %v, exp %v", got, exp)
+	}
+}
+
 // Ensure that the planner will not return files that are over the max
 // allowable size
 func TestDefaultPlanner_Plan_SkipMaxSizeFiles(t *testing.T) {
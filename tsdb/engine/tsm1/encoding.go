@@ -102,19 +102,34 @@ func (a Values) Filter(min, max int64) Values {
 // Encode converts the values to a byte slice.  If there are no values,
 // this function panics.
 func (a Values) Encode(buf []byte) ([]byte, error) {
+	return a.encode(buf, blockEncoding{float: floatCompressedGorilla})
+}
+
+// blockEncoding is how values are compressed when they are encoded into a
+// block.
+type blockEncoding struct {
+	// float is the compression type of float values.
+	float byte
+
+	// stringDictionary allows string values to use dictionary encoding.
+	stringDictionary bool
+}
+
+// encode is like Encode but compresses values as described by encoding.
+func (a Values) encode(buf []byte, encoding blockEncoding) ([]byte, error) {
 	if len(a) == 0 {
 		panic("unable to encode block type")
 	}
 
 	switch a[0].(type) {
 	case *FloatValue:
-		return encodeFloatBlock(buf, a)
+		return encodeFloatBlock(buf, a, encoding.float)
 	case *IntegerValue:
 		return encodeIntegerBlock(buf, a)
 	case *BooleanValue:
 		return encodeBooleanBlock(buf, a)
 	case *StringValue:
-		return encodeStringBlock(buf, a)
+		return encodeStringBlock(buf, a, encoding.stringDictionary)
 	}
 
 	return nil, fmt.Errorf("unsupported value type %T", a[0])
@@ -265,7 +280,7 @@ func (f *FloatValue) String() string {
 	return fmt.Sprintf("%v %v", time.Unix(0, f.unixnano), f.value)
 }
 
-func encodeFloatBlock(buf []byte, values []Value) ([]byte, error) {
+func encodeFloatBlock(buf []byte, values []Value, typ byte) ([]byte, error) {
 	if len(values) == 0 {
 		return nil, nil
 	}
@@ -273,8 +288,9 @@ func encodeFloatBlock(buf []byte, values []Value) ([]byte, error) {
 	// A float block is encoded using different compression strategies
 	// for timestamps and values.
 
-	// Encode values using Gorilla float compression
-	venc := NewFloatEncoder()
+	// Encode values using Gorilla float compression unless raw values were
+	// requested.
+	venc := newFloatEncoder(typ)
 
 	// Encode timestamps using an adaptive encoder that uses delta-encoding,
 	// frame-or-reference and run length encoding.
@@ -668,9 +684,12 @@ func (f *StringValue) String() string {
 	return fmt.Sprintf("%v %v", time.Unix(0, f.unixnano), f.Value())
 }
 
-func encodeStringBlock(buf []byte, values []Value) ([]byte, error) {
+func encodeStringBlock(buf []byte, values []Value, dictionary bool) ([]byte, error) {
 	tsEnc := NewTimeEncoder()
 	vEnc := NewStringEncoder()
+	if dictionary {
+		vEnc = NewStringDictionaryEncoder()
+	}
 	for _, v := range values {
 		tsEnc.Write(v.UnixNano())
 		vEnc.Write(v.(*StringValue).value)
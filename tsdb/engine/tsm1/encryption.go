@@ -0,0 +1,78 @@
+package tsm1
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+Encrypted blocks replace the encoded block that follows the checksum with:
+
+┌──────────────────────────────────────────────┐
+│               Encrypted Block                │
+├─────────┬─────────┬──────────────────────────┤
+│  Type   │  Nonce  │   Sealed Encoded Block   │
+│ 1 byte  │12 bytes │         N bytes          │
+└─────────┴─────────┴──────────────────────────┘
+
+The type is BlockEncrypted and the sealed block is the AES-GCM encryption of
+the encoded block.  The offset of the block in the file and its series key
+are authenticated with the block, so a block moved to another offset or key
+fails to decrypt.  The checksum is of the encoded block before encryption.
+The index, which records the type of the encoded block, is not encrypted.
+*/
+
+// BlockEncrypted is the first byte of a block encrypted with AES-GCM.
+const BlockEncrypted = byte(0xFF)
+
+// ErrBlockEncrypted is returned when reading an encrypted block without a key.
+var ErrBlockEncrypted = errors.New("block is encrypted and no encryption key is configured")
+
+// NewCipher returns an AES-GCM cipher for encrypting blocks.  key must be 16,
+// 24 or 32 bytes long.
+func NewCipher(key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+// encryptBlock seals an encoded block of key, written at offset, with a
+// random nonce.
+func encryptBlock(aead cipher.AEAD, key string, offset int64, block []byte) ([]byte, error) {
+	n := aead.NonceSize()
+	buf := make([]byte, 1+n, 1+n+len(block)+aead.Overhead())
+	buf[0] = BlockEncrypted
+	if _, err := io.ReadFull(rand.Reader, buf[1:]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(buf, buf[1:1+n], block, blockData(key, offset)), nil
+}
+
+// decryptBlock returns the encoded block sealed in an encrypted block of key
+// read at offset.
+func decryptBlock(aead cipher.AEAD, key string, offset int64, block []byte) ([]byte, error) {
+	if aead == nil {
+		return nil, ErrBlockEncrypted
+	}
+
+	n := aead.NonceSize()
+	if len(block) < 1+n {
+		return nil, fmt.Errorf("encrypted block too short: %d", len(block))
+	}
+	return aead.Open(nil, block[1:1+n], block[1+n:], blockData(key, offset))
+}
+
+// blockData returns the additional data authenticated with a block: its
+// offset followed by its key.
+func blockData(key string, offset int64) []byte {
+	b := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(b, uint64(offset))
+	return append(b, key...)
+}
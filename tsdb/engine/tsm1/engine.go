@@ -2,16 +2,21 @@ package tsm1 // import "github.com/influxdata/influxdb/tsdb/engine/tsm1"
 
 import (
 	"archive/tar"
+	"expvar"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/tsdb"
@@ -32,6 +37,32 @@ const (
 	keyFieldSeparator = "#!~#"
 )
 
+// Statistics gathered by the engine for each compaction level.  The level is
+// prepended to each name, for example "tsmLevel1Compactions" and
+// "tsmFullCompactions".
+const (
+	statCompactions            = "Compactions"            // counter: Number of compactions completed.
+	statCompactionErrors       = "CompactionErrors"       // counter: Number of compactions that failed.
+	statCompactionDurationMs   = "CompactionDurationMs"   // counter: Total milliseconds spent compacting.
+	statCompactionBytesRead    = "CompactionBytesRead"    // counter: Total bytes of TSM files compacted.
+	statCompactionBytesWritten = "CompactionBytesWritten" // counter: Total bytes of TSM files written.
+)
+
+// Statistics gathered by the engine across all compaction levels.
+const (
+	statDuplicatePoints = "tsmDuplicatePoints" // counter: Points replaced by a newer value with the same timestamp while compacting.
+	statCursorsCreated  = "cursorsCreated"     // counter: Cursors created to read the values of a field for queries.
+)
+
+// compactionStatName returns the name of a compaction statistic for a level.
+// Level zero is used for full compactions.
+func compactionStatName(level int, name string) string {
+	if level == 0 {
+		return "tsmFull" + name
+	}
+	return fmt.Sprintf("tsmLevel%d%s", level, name)
+}
+
 // Engine represents a storage engine with compressed blocks.
 type Engine struct {
 	mu   sync.RWMutex
@@ -46,12 +77,28 @@ type Engine struct {
 	index             *tsdb.DatabaseIndex
 	measurementFields map[string]*tsdb.MeasurementFields
 
+	// keyIndexFingerprint is the fingerprint of the TSM files the key index
+	// file was last written or loaded for.
+	keyIndexFingerprint uint64
+
 	WAL            *WAL
 	Cache          *Cache
 	Compactor      *Compactor
 	CompactionPlan CompactionPlanner
 	FileStore      *FileStore
 
+	// compactions holds the progress of the compactions currently running.
+	compactionsMu sync.Mutex
+	compactions   map[*compactionProgress]struct{}
+	backingOff    bool
+
+	// compactionsDisabled is non-zero while compactions are paused.
+	compactionsDisabled int32
+
+	// readOnly is set when the cache has been written to TSM files and the
+	// WAL closed so that the engine only serves reads.
+	readOnly bool
+
 	MaxPointsPerBlock int
 
 	// CacheFlushMemorySizeThreshold specifies the minimum size threshodl for
@@ -62,6 +109,26 @@ type Engine struct {
 	// no writes have been committed to the WAL, the engine will write
 	// a snapshot of the cache to a TSM file
 	CacheFlushWriteColdDuration time.Duration
+
+	// CompactionBackoffCachePercent and CompactionBackoffWALSyncLatency are
+	// the cache fullness and WAL fsync latency above which compactions above
+	// level 1 are deferred until write load drops.  Zero disables each check.
+	CompactionBackoffCachePercent   int
+	CompactionBackoffWALSyncLatency time.Duration
+
+	// CompactFullWindow is the daily window of local time in which automatic
+	// full compactions may start.  Requested full compactions are not limited.
+	CompactFullWindow tsdb.TimeWindow
+
+	// fullCompactionRequested is non-zero when a full compaction has been
+	// requested by ScheduleFullCompaction and not yet planned.
+	fullCompactionRequested int32
+
+	// encryptionKey returns the key used to encrypt TSM blocks, or nil if
+	// encryption is disabled.
+	encryptionKey func() ([]byte, error)
+
+	statMap *expvar.Map
 }
 
 // NewEngine returns a new instance of Engine.
@@ -71,37 +138,67 @@ func NewEngine(path string, walPath string, opt tsdb.EngineOptions) tsdb.Engine
 
 	fs := NewFileStore(path)
 	fs.traceLogging = opt.Config.DataLoggingEnabled
+	fs.VerifyOnOpen = opt.Config.VerifyTSMOnOpen
+	fs.ReaderOptions = TSMReaderOptions{
+		MmapAdvice:  opt.Config.MmapAdvice,
+		DirectReads: opt.Config.DirectReads,
+
+		LazyIndex:      opt.Config.LazyTSMIndex,
+		IndexCacheSize: opt.Config.TSMIndexCacheSize,
+		LockIndex:      opt.Config.MlockTSMIndex && !opt.Config.InColdDir(path),
+	}
 
 	cache := NewCache(uint64(opt.Config.CacheMaxMemorySize), path)
 
-	c := &Compactor{
-		Dir:       path,
-		FileStore: fs,
-	}
-
 	e := &Engine{
 		path:              path,
 		measurementFields: make(map[string]*tsdb.MeasurementFields),
+		compactions:       make(map[*compactionProgress]struct{}),
+		statMap:           newEngineStatistics(path),
 
 		WAL:   w,
 		Cache: cache,
 
 		FileStore: fs,
-		Compactor: c,
+		Compactor: NewCompactor(path, fs, opt.Config),
 		CompactionPlan: &DefaultPlanner{
 			FileStore:                    fs,
 			CompactFullWriteColdDuration: time.Duration(opt.Config.CompactFullWriteColdDuration),
+			LevelFileCounts: []int{
+				opt.Config.CompactLevel1FileCount,
+				opt.Config.CompactLevel2FileCount,
+				opt.Config.CompactLevel3FileCount,
+				opt.Config.CompactLevel4FileCount,
+			},
+			MaxFileSize:       uint32(opt.Config.CompactMaxFileSize),
+			MaxPointsPerBlock: opt.Config.MaxPointsPerBlock,
 		},
 		MaxPointsPerBlock: opt.Config.MaxPointsPerBlock,
 
 		CacheFlushMemorySizeThreshold: opt.Config.CacheSnapshotMemorySize,
 		CacheFlushWriteColdDuration:   time.Duration(opt.Config.CacheSnapshotWriteColdDuration),
+
+		CompactionBackoffCachePercent:   opt.Config.CompactBackoffCachePercent,
+		CompactionBackoffWALSyncLatency: time.Duration(opt.Config.CompactBackoffWALSyncLatency),
+
+		encryptionKey: opt.Config.EncryptionKey,
 	}
+	e.CompactFullWindow, _ = tsdb.ParseTimeWindow(opt.Config.CompactFullWindow)
 	e.SetLogOutput(os.Stderr)
 
 	return e
 }
 
+// newEngineStatistics returns the statistics map for the engine at path.
+func newEngineStatistics(path string) *expvar.Map {
+	db, rp := tsdb.DecodeStorePath(path)
+	return influxdb.NewStatistics(
+		"tsm1_engine:"+path,
+		"tsm1_engine",
+		map[string]string{"path": path, "id": filepath.Base(path), "database": db, "retentionPolicy": rp},
+	)
+}
+
 // Path returns the path the engine was opened with.
 func (e *Engine) Path() string { return e.path }
 
@@ -140,16 +237,27 @@ func (e *Engine) Format() tsdb.EngineFormat {
 // Open opens and initializes the engine.
 func (e *Engine) Open() error {
 	e.done = make(chan struct{})
+	e.readOnly = false
 	e.Compactor.Cancel = e.done
 
 	if err := os.MkdirAll(e.path, 0777); err != nil {
 		return err
 	}
 
+	if e.Compactor.TempDir != "" {
+		if err := os.MkdirAll(e.Compactor.TempDir, 0777); err != nil {
+			return err
+		}
+	}
+
 	if err := e.cleanup(); err != nil {
 		return err
 	}
 
+	if err := e.loadEncryptionKey(); err != nil {
+		return err
+	}
+
 	if err := e.WAL.Open(); err != nil {
 		return err
 	}
@@ -172,6 +280,29 @@ func (e *Engine) Open() error {
 	return nil
 }
 
+// loadEncryptionKey sets up the encryption of TSM blocks if a key is
+// configured.
+func (e *Engine) loadEncryptionKey() error {
+	if e.encryptionKey == nil {
+		return nil
+	}
+
+	key, err := e.encryptionKey()
+	if err != nil {
+		return fmt.Errorf("load encryption key: %v", err)
+	} else if key == nil {
+		return nil
+	}
+
+	aead, err := NewCipher(key)
+	if err != nil {
+		return err
+	}
+	e.FileStore.ReaderOptions.Cipher = aead
+	e.Compactor.Cipher = aead
+	return nil
+}
+
 // Close closes the engine. Subsequent calls to Close are a nop.
 func (e *Engine) Close() error {
 	e.mu.RLock()
@@ -190,9 +321,24 @@ func (e *Engine) Close() error {
 	defer e.mu.Unlock()
 	e.done = nil // Ensures that the channel will not be closed again.
 
+	// Save the series of the TSM files so they don't have to be read from
+	// the files on the next open.
+	if e.index != nil && e.keyIndexEnabled() {
+		if fp := e.FileStore.fingerprint(); fp != e.keyIndexFingerprint {
+			if s, err := e.FileStore.seriesFields(); err != nil {
+				e.logger.Printf("error reading keys for key index: %v", err)
+			} else if err := e.saveKeyIndex(fp, s); err != nil {
+				e.logger.Printf("error writing key index: %v", err)
+			}
+		}
+	}
+
 	if err := e.FileStore.Close(); err != nil {
 		return err
 	}
+	if e.readOnly {
+		return nil
+	}
 	return e.WAL.Close()
 }
 
@@ -202,6 +348,9 @@ func (e *Engine) SetLogOutput(w io.Writer) {
 	e.logger = log.New(w, "[tsm1] ", log.LstdFlags)
 	e.WAL.SetLogOutput(w)
 	e.FileStore.SetLogOutput(w)
+	if p, ok := e.CompactionPlan.(*DefaultPlanner); ok {
+		p.Logger = log.New(w, "[tsm1 planner] ", log.LstdFlags)
+	}
 	e.logOutput = w
 }
 
@@ -210,18 +359,28 @@ func (e *Engine) LoadMetadataIndex(shardID uint64, index *tsdb.DatabaseIndex) er
 	// Save reference to index for iterator creation.
 	e.index = index
 
-	if err := e.FileStore.WalkKeys(func(key string, typ byte) error {
-		fieldType, err := tsmFieldTypeToInfluxQLDataType(typ)
-		if err != nil {
+	// Load the series of the TSM files from the key index file if it is
+	// current, otherwise read them from the files and save the key index.
+	fp := e.FileStore.fingerprint()
+	s, err := e.loadKeyIndex(fp)
+	if err != nil {
+		e.logger.Printf("error reading key index, rebuilding: %v", err)
+	}
+
+	if s == nil {
+		if s, err = e.FileStore.seriesFields(); err != nil {
 			return err
 		}
 
-		if err := e.addToIndexFromKey(shardID, key, fieldType, index); err != nil {
+		if err := e.saveKeyIndex(fp, s); err != nil {
+			e.logger.Printf("error writing key index: %v", err)
+		}
+	}
+
+	for _, seriesKey := range s.keys() {
+		if err := e.addSeriesToIndex(shardID, seriesKey, s[seriesKey], index); err != nil {
 			return err
 		}
-		return nil
-	}); err != nil {
-		return err
 	}
 
 	// load metadata from the Cache
@@ -244,39 +403,46 @@ func (e *Engine) LoadMetadataIndex(shardID uint64, index *tsdb.DatabaseIndex) er
 	return nil
 }
 
-// Backup will write a tar archive of any TSM files modified since the passed
-// in time to the passed in writer. The basePath will be prepended to the names
-// of the files in the archive. It will force a snapshot of the WAL first
-// then perform the backup with a read lock against the file store. This means
-// that new TSM files will not be able to be created in this shard while the
-// backup is running. For shards that are still acively getting writes, this
-// could cause the WAL to backup, increasing memory usage and evenutally rejecting writes.
+// CreateSnapshot writes the cache to a TSM file and creates a point-in-time
+// snapshot of the TSM and tombstone files using hard links.  It returns the
+// path of the directory holding the snapshot, which the caller must remove
+// when done.  Writes and compactions continue while the snapshot is used.
+func (e *Engine) CreateSnapshot() (string, error) {
+	if err := e.FlushCache(); err != nil {
+		return "", err
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.FileStore.CreateSnapshot()
+}
+
+// Backup will write a tar archive of any TSM and tombstone files modified
+// since the passed in time to the passed in writer. The basePath will be
+// prepended to the names of the files in the archive. The files are read
+// from a snapshot of the engine, so writes and compactions are not blocked
+// while the archive is written.
 func (e *Engine) Backup(w io.Writer, basePath string, since time.Time) error {
-	if err := e.WriteSnapshot(); err != nil {
+	path, err := e.CreateSnapshot()
+	if err != nil {
 		return err
 	}
-	e.FileStore.mu.RLock()
-	defer e.FileStore.mu.RUnlock()
+	defer os.RemoveAll(path)
 
-	var files []FileStat
-
-	// grab all the files and tombstones that have a modified time after since
-	for _, f := range e.FileStore.files {
-		if stat := f.Stats(); stat.LastModified > since.UnixNano() {
-			files = append(files, f.Stats())
-		}
-		for _, t := range f.TombstoneFiles() {
-			if t.LastModified > since.UnixNano() {
-				files = append(files, f.Stats())
-			}
-		}
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
 	}
 
 	tw := tar.NewWriter(w)
 	defer tw.Close()
 
-	for _, f := range files {
-		if err := e.writeFileToBackup(f, basePath, tw); err != nil {
+	// add all the files and tombstones that have a modified time after since
+	for _, fi := range fis {
+		if fi.ModTime().UnixNano() <= since.UnixNano() {
+			continue
+		}
+		if err := e.writeFileToBackup(filepath.Join(path, fi.Name()), fi, basePath, tw); err != nil {
 			return err
 		}
 	}
@@ -284,18 +450,105 @@ func (e *Engine) Backup(w io.Writer, basePath string, since time.Time) error {
 	return nil
 }
 
+// Import adds the TSM and tombstone files of a tar archive written by Backup
+// to the engine.  The files of each generation in the archive are given a new
+// generation so they do not collide with the files of the engine, and take
+// precedence over them for points with the same timestamp.
+func (e *Engine) Import(r io.Reader) error {
+	var tsmFiles, written []string
+	generations := make(map[int]int)
+
+	if err := func() error {
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			name := filepath.Base(hdr.Name)
+			ext := strings.TrimPrefix(filepath.Ext(name), ".")
+			if ext != TSMFileExtension && ext != "tombstone" {
+				continue
+			}
+
+			generation, sequence, err := ParseTSMFileName(name)
+			if err != nil {
+				return err
+			}
+			if _, ok := generations[generation]; !ok {
+				generations[generation] = e.FileStore.NextGeneration()
+			}
+			path := filepath.Join(e.path, fmt.Sprintf("%09d-%09d.%s", generations[generation], sequence, ext))
+
+			// TSM files are written with a temp extension and made live by the
+			// file store.  Tombstones must be in place before then.
+			tmp := path + "." + CompactionTempExtension
+			if err := writeImportFile(tmp, tr); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+			if ext == TSMFileExtension {
+				written = append(written, tmp, path)
+				tsmFiles = append(tsmFiles, tmp)
+				continue
+			}
+
+			written = append(written, path)
+			if err := renameFile(tmp, path); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+		}
+	}(); err != nil {
+		for _, path := range written {
+			os.Remove(path)
+		}
+		return err
+	}
+
+	if err := e.FileStore.Replace(nil, tsmFiles); err != nil {
+		for _, path := range written {
+			os.Remove(path)
+		}
+		return err
+	}
+	return nil
+}
+
+// writeImportFile writes the contents of r to a new file at path.
+func writeImportFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // writeFileToBackup will copy the file into the tar archive. Files will use the shardRelativePath
 // in their names. This should be the <db>/<retention policy>/<id> part of the path
-func (e *Engine) writeFileToBackup(f FileStat, shardRelativePath string, tw *tar.Writer) error {
+func (e *Engine) writeFileToBackup(path string, fi os.FileInfo, shardRelativePath string, tw *tar.Writer) error {
 	h := &tar.Header{
-		Name:    filepath.Join(shardRelativePath, filepath.Base(f.Path)),
-		ModTime: time.Unix(0, f.LastModified),
-		Size:    int64(f.Size),
+		Name:    filepath.Join(shardRelativePath, fi.Name()),
+		ModTime: fi.ModTime(),
+		Size:    fi.Size(),
 	}
 	if err := tw.WriteHeader(h); err != nil {
 		return err
 	}
-	fr, err := os.Open(f.Path)
+	fr, err := os.Open(path)
 	if err != nil {
 		return err
 	}
@@ -307,6 +560,71 @@ func (e *Engine) writeFileToBackup(f FileStat, shardRelativePath string, tw *tar
 	return err
 }
 
+// LoadSeries adds the series with the given keys, and the fields they have
+// values for, to the index.  Keys without values in the engine are skipped.
+func (e *Engine) LoadSeries(shardID uint64, index *tsdb.DatabaseIndex, keys []string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, seriesKey := range keys {
+		mf := e.measurementFields[tsdb.MeasurementFromSeriesKey(seriesKey)]
+		if mf == nil {
+			continue
+		}
+
+		for _, f := range mf.Fields() {
+			key := SeriesFieldKey(seriesKey, f.Name)
+			if !e.Cache.ContainsRange(key, math.MinInt64, math.MaxInt64) {
+				if _, err := e.FileStore.Type(key); err != nil {
+					continue
+				}
+			}
+
+			if err := e.addToIndexFromKey(shardID, key, f.Type, index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addSeriesToIndex adds a series with the fields and block types in fields
+// to the database index and measurement fields.
+func (e *Engine) addSeriesToIndex(shardID uint64, seriesKey string, fields map[string]byte, index *tsdb.DatabaseIndex) error {
+	measurement := tsdb.MeasurementFromSeriesKey(seriesKey)
+
+	m := index.CreateMeasurementIndexIfNotExists(measurement)
+
+	mf := e.measurementFields[measurement]
+	if mf == nil {
+		mf = tsdb.NewMeasurementFields()
+		e.measurementFields[measurement] = mf
+	}
+
+	for field, typ := range fields {
+		fieldType, err := tsmFieldTypeToInfluxQLDataType(typ)
+		if err != nil {
+			return err
+		}
+
+		m.SetFieldName(field)
+		if err := mf.CreateFieldIfNotExists(field, fieldType, false); err != nil {
+			return err
+		}
+	}
+
+	// ignore error because ParseKey returns "missing fields" and we don't have
+	// fields (in line protocol format) in the series key
+	_, tags, _ := models.ParseKey(seriesKey)
+
+	s := tsdb.NewSeries(seriesKey, tags)
+	s.InitializeShards()
+	index.CreateSeriesIndexIfNotExists(measurement, s)
+	index.AssignShard(seriesKey, shardID)
+
+	return nil
+}
+
 // addToIndexFromKey will pull the measurement name, series key, and field name from a composite key and add it to the
 // database index and measurement fields
 func (e *Engine) addToIndexFromKey(shardID uint64, key string, fieldType influxql.DataType, index *tsdb.DatabaseIndex) error {
@@ -333,7 +651,7 @@ func (e *Engine) addToIndexFromKey(shardID uint64, key string, fieldType influxq
 	s := tsdb.NewSeries(seriesKey, tags)
 	s.InitializeShards()
 	index.CreateSeriesIndexIfNotExists(measurement, s)
-	s.AssignShard(shardID)
+	index.AssignShard(seriesKey, shardID)
 
 	return nil
 }
@@ -352,6 +670,10 @@ func (e *Engine) WritePoints(points []models.Point) error {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
+	if e.readOnly {
+		return tsdb.ErrShardReadOnly
+	}
+
 	// first try to write to the cache
 	err := e.Cache.WriteMulti(values)
 	if err != nil {
@@ -389,6 +711,52 @@ func (e *Engine) ContainsSeries(keys []string) (map[string]bool, error) {
 	return keyMap, nil
 }
 
+// ContainsSeriesRange returns whether each of the series keys has values
+// between min and max (inclusive).  Only the time bounds of the cache entries
+// and the TSM index entries are checked, so no values are read and a series
+// is also reported if the range falls between two values of a block or cache
+// entry that spans it.
+func (e *Engine) ContainsSeriesRange(keys []string, min, max int64) (map[string]bool, error) {
+	keyMap := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		keyMap[k] = false
+	}
+
+	for _, k := range e.Cache.Keys() {
+		seriesKey, _ := seriesAndFieldFromCompositeKey(k)
+		if found, ok := keyMap[seriesKey]; ok && !found && e.Cache.ContainsRange(k, min, max) {
+			keyMap[seriesKey] = true
+		}
+	}
+
+	// Collect the keys of the series not found yet before checking their
+	// blocks so the file store is not locked while walking its keys.
+	var fileKeys []string
+	seen := make(map[string]struct{})
+	if err := e.FileStore.WalkKeys(func(k string, _ byte) error {
+		seriesKey, _ := seriesAndFieldFromCompositeKey(k)
+		if found, ok := keyMap[seriesKey]; !ok || found {
+			return nil
+		} else if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			fileKeys = append(fileKeys, k)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, k := range fileKeys {
+		seriesKey, _ := seriesAndFieldFromCompositeKey(k)
+		if keyMap[seriesKey] {
+			continue
+		} else if _, blocks, _ := e.FileStore.Cost(k, min, max); blocks > 0 {
+			keyMap[seriesKey] = true
+		}
+	}
+	return keyMap, nil
+}
+
 // DeleteSeries removes all series keys from the engine.
 func (e *Engine) DeleteSeries(seriesKeys []string) error {
 	return e.DeleteSeriesRange(seriesKeys, math.MinInt64, math.MaxInt64)
@@ -410,11 +778,36 @@ func (e *Engine) DeleteSeriesRange(seriesKeys []string, min, max int64) error {
 		keyMap[k] = struct{}{}
 	}
 
-	var deleteKeys []string
-	// go through the keys in the file store
+	// find the keys in the cache, including a snapshot being written
+	walKeys := make([]string, 0)
+	cacheKeys := make(map[string]struct{})
+	e.Cache.RLock()
+	stores := []map[string]*entry{e.Cache.Store()}
+	if e.Cache.snapshot != nil {
+		stores = append(stores, e.Cache.snapshot.store)
+	}
+	for _, s := range stores {
+		for k := range s {
+			seriesKey, _ := seriesAndFieldFromCompositeKey(k)
+			if _, ok := keyMap[seriesKey]; !ok {
+				continue
+			} else if _, ok := cacheKeys[k]; !ok {
+				cacheKeys[k] = struct{}{}
+				walKeys = append(walKeys, k)
+			}
+		}
+	}
+	e.Cache.RUnlock()
+
+	// go through the keys in the file store.  The cache keys are deleted from
+	// the file store as well so that any TSM files being written from a cache
+	// snapshot are tombstoned when they are added.
+	deleteKeys := append([]string(nil), walKeys...)
 	if err := e.FileStore.WalkKeys(func(k string, _ byte) error {
 		seriesKey, _ := seriesAndFieldFromCompositeKey(k)
-		if _, ok := keyMap[seriesKey]; ok {
+		if _, ok := keyMap[seriesKey]; !ok {
+			return nil
+		} else if _, ok := cacheKeys[k]; !ok {
 			deleteKeys = append(deleteKeys, k)
 		}
 		return nil
@@ -426,21 +819,12 @@ func (e *Engine) DeleteSeriesRange(seriesKeys []string, min, max int64) error {
 		return err
 	}
 
-	// find the keys in the cache and remove them
-	walKeys := make([]string, 0)
-	e.Cache.RLock()
-	s := e.Cache.Store()
-	for k, _ := range s {
-		seriesKey, _ := seriesAndFieldFromCompositeKey(k)
-		if _, ok := keyMap[seriesKey]; ok {
-			walKeys = append(walKeys, k)
-		}
-	}
-	e.Cache.RUnlock()
-
 	e.Cache.DeleteRange(walKeys, min, max)
 
-	// delete from the WAL
+	// delete from the WAL, which is closed once the engine is read-only
+	if e.readOnly {
+		return nil
+	}
 	_, err := e.WAL.DeleteRange(walKeys, min, max)
 
 	return err
@@ -460,10 +844,35 @@ func (e *Engine) SeriesCount() (n int, err error) {
 	return 0, nil
 }
 
+// DiskSize returns the size in bytes of the TSM files.  Data in the cache is
+// counted once it is written to a TSM file.
+func (e *Engine) DiskSize() int64 {
+	return e.FileStore.DiskSize()
+}
+
 func (e *Engine) WriteTo(w io.Writer) (n int64, err error) { panic("not implemented") }
 
 // WriteSnapshot will snapshot the cache and write a new TSM file with its contents, releasing the snapshot when done.
 func (e *Engine) WriteSnapshot() error {
+	return e.writeSnapshot(false)
+}
+
+// FlushCache writes the cache to TSM files like WriteSnapshot.  If a
+// snapshot is already being written, it waits for that snapshot to finish
+// and then writes the points cached since.
+func (e *Engine) FlushCache() error {
+	for {
+		err := e.WriteSnapshot()
+		if err != ErrSnapshotInProgress {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// writeSnapshot is like WriteSnapshot but also makes the engine read-only if
+// readOnly is set, so that no writes are accepted after the snapshot is taken.
+func (e *Engine) writeSnapshot(readOnly bool) error {
 	// Lock and grab the cache snapshot along with all the closed WAL
 	// filenames associated with the snapshot
 
@@ -475,32 +884,42 @@ func (e *Engine) WriteSnapshot() error {
 		}
 	}()
 
-	closedFiles, snapshot, compactor, err := func() ([]string, *Cache, *Compactor, error) {
+	closedFiles, snapshot, compactor, deletes, err := func() ([]string, *Cache, *Compactor, *deleteLog, error) {
 		e.mu.Lock()
 		defer e.mu.Unlock()
 
+		// The cache of a read-only engine is always empty.
+		if e.readOnly {
+			return nil, nil, nil, nil, nil
+		}
+		e.readOnly = readOnly
+
 		now := time.Now()
 		started = &now
 
 		if err := e.WAL.CloseSegment(); err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		segments, err := e.WAL.ClosedSegments()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		snapshot, err := e.Cache.Snapshot()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
-		return segments, snapshot, e.Compactor.Clone(), nil
+		// Deletes can't run while the lock is held, so every delete made
+		// after the snapshot is taken is recorded for the new files.
+		return segments, snapshot, e.Compactor.Clone(), e.FileStore.newDeleteLog(), nil
 	}()
 
 	if err != nil {
 		return err
+	} else if snapshot == nil {
+		return nil
 	}
 
 	// The snapshotted cache may have duplicate points and unsorted data.  We need to deduplicate
@@ -508,14 +927,16 @@ func (e *Engine) WriteSnapshot() error {
 	// holding the engine write lock.
 	snapshot.Deduplicate()
 
-	return e.writeSnapshotAndCommit(closedFiles, snapshot, compactor)
+	return e.writeSnapshotAndCommit(closedFiles, snapshot, compactor, deletes)
 }
 
-// writeSnapshotAndCommit will write the passed cache to a new TSM file and remove the closed WAL segments
-func (e *Engine) writeSnapshotAndCommit(closedFiles []string, snapshot *Cache, compactor *Compactor) (err error) {
+// writeSnapshotAndCommit will write the passed cache to a new TSM file and remove the closed WAL segments.
+// The deletes recorded since the snapshot was taken are applied to the new file.
+func (e *Engine) writeSnapshotAndCommit(closedFiles []string, snapshot *Cache, compactor *Compactor, deletes *deleteLog) (err error) {
 
 	defer func() {
 		if err != nil {
+			e.FileStore.closeDeleteLog(deletes)
 			e.Cache.ClearSnapshot(false)
 		}
 	}()
@@ -530,7 +951,7 @@ func (e *Engine) writeSnapshotAndCommit(closedFiles []string, snapshot *Cache, c
 	defer e.mu.RUnlock()
 
 	// update the file store with these new files
-	if err := e.FileStore.Replace(nil, newFiles); err != nil {
+	if err := e.FileStore.replace(deletes, nil, newFiles); err != nil {
 		e.logger.Printf("error adding new TSM files from snapshot: %v", err)
 		return err
 	}
@@ -545,6 +966,77 @@ func (e *Engine) writeSnapshotAndCommit(closedFiles []string, snapshot *Cache, c
 	return nil
 }
 
+// ScheduleFullCompaction snapshots the cache and requests that all TSM files
+// in the shard be compacted into a fully optimized set of files. The
+// compaction itself runs in the background.
+func (e *Engine) ScheduleFullCompaction() error {
+	// Write any data in the cache so it is included in the compaction.
+	if err := e.WriteSnapshot(); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&e.fullCompactionRequested, 1)
+	return nil
+}
+
+// Compactions returns the status of the compactions currently running.
+func (e *Engine) Compactions() []tsdb.CompactionStatus {
+	e.compactionsMu.Lock()
+	defer e.compactionsMu.Unlock()
+
+	a := make([]tsdb.CompactionStatus, 0, len(e.compactions))
+	for p := range e.compactions {
+		a = append(a, p.status())
+	}
+	return a
+}
+
+// compactGroup compacts a group of TSM files while tracking its progress.
+// A level of zero indicates a full compaction.  The deletes made to the group
+// while it is compacted are returned to be applied to the new files when they
+// replace the group.
+func (e *Engine) compactGroup(level int, fast bool, group CompactionGroup) ([]string, *deleteLog, error) {
+	progress := newCompactionProgress(level, group)
+
+	// Start recording deletes before the compactor reads the tombstones of
+	// the group.
+	deletes := e.FileStore.newDeleteLog()
+
+	e.compactionsMu.Lock()
+	e.compactions[progress] = struct{}{}
+	e.compactionsMu.Unlock()
+
+	defer func() {
+		e.compactionsMu.Lock()
+		delete(e.compactions, progress)
+		e.compactionsMu.Unlock()
+	}()
+
+	files, err := e.Compactor.compact(fast, group, progress)
+	if err != nil {
+		e.FileStore.closeDeleteLog(deletes)
+		e.statMap.Add(compactionStatName(level, statCompactionErrors), 1)
+		return nil, nil, err
+	}
+
+	var written int64
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			written += fi.Size()
+		}
+	}
+
+	e.statMap.Add(compactionStatName(level, statCompactions), 1)
+	e.statMap.Add(compactionStatName(level, statCompactionDurationMs), int64(time.Since(progress.start)/time.Millisecond))
+	e.statMap.Add(compactionStatName(level, statCompactionBytesRead), progress.totalBytes)
+	e.statMap.Add(compactionStatName(level, statCompactionBytesWritten), written)
+	if progress.duplicates > 0 {
+		e.statMap.Add(statDuplicatePoints, progress.duplicates)
+		e.logger.Printf("resolved %d duplicate points while compacting %d files, newest values kept", progress.duplicates, len(group))
+	}
+	return files, deletes, nil
+}
+
 // compactCache continually checks if the WAL cache should be written to disk
 func (e *Engine) compactCache() {
 	defer e.wg.Done()
@@ -579,6 +1071,123 @@ func (e *Engine) ShouldCompactCache(lastWriteTime time.Time) bool {
 		time.Now().Sub(lastWriteTime) > e.CacheFlushWriteColdDuration
 }
 
+// UnderWritePressure returns true if the cache is close to its limit or
+// WAL fsyncs are slow, in which case non-essential compactions should wait.
+func (e *Engine) UnderWritePressure() bool {
+	if pct := e.CompactionBackoffCachePercent; pct > 0 {
+		if max := e.Cache.MaxSize(); max > 0 && e.Cache.Size() >= max*uint64(pct)/100 {
+			return true
+		}
+	}
+
+	if d := e.CompactionBackoffWALSyncLatency; d > 0 && e.WAL.SyncLatency() >= d {
+		return true
+	}
+	return false
+}
+
+// SetReadOnly makes the engine read-only or writable again.  Making the
+// engine read-only writes the cache to a TSM file and closes and removes the
+// WAL, so the engine serves reads from its TSM files alone.  Compactions and
+// deletes continue while the engine is read-only.
+func (e *Engine) SetReadOnly(readOnly bool) error {
+	if !readOnly {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if !e.readOnly {
+			return nil
+		}
+
+		if err := e.WAL.Open(); err != nil {
+			return err
+		}
+		e.readOnly = false
+		e.logger.Printf("%s is writable", e.path)
+		return nil
+	}
+
+	e.mu.RLock()
+	done := e.readOnly
+	e.mu.RUnlock()
+	if done {
+		return nil
+	}
+
+	if err := e.writeSnapshot(true); err != nil {
+		e.mu.Lock()
+		e.readOnly = false
+		e.mu.Unlock()
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	segments, err := segmentFileNames(e.WAL.Path())
+	if err != nil {
+		return err
+	}
+	if err := e.WAL.Close(); err != nil {
+		return err
+	}
+	if err := e.WAL.Remove(segments); err != nil {
+		return err
+	}
+
+	e.logger.Printf("%s is read-only", e.path)
+	return nil
+}
+
+// SetCompactionsEnabled pauses or resumes background TSM compactions.
+// Compactions already running are allowed to finish.  Cache snapshots are
+// still written while paused so that writes are not blocked.
+func (e *Engine) SetCompactionsEnabled(enabled bool) {
+	var v int32
+	if !enabled {
+		v = 1
+	}
+	if atomic.SwapInt32(&e.compactionsDisabled, v) != v {
+		if enabled {
+			e.logger.Printf("compactions resumed")
+		} else {
+			e.logger.Printf("compactions paused")
+		}
+	}
+}
+
+// CompactionsEnabled returns true if background compactions are not paused.
+func (e *Engine) CompactionsEnabled() bool {
+	return atomic.LoadInt32(&e.compactionsDisabled) == 0
+}
+
+// backoffCompactions returns true if compactions at the given level should
+// be deferred because they are paused or because of write pressure.  Level 1
+// compactions are not deferred for write pressure to keep the number of TSM
+// files from growing unbounded.
+func (e *Engine) backoffCompactions(level int) bool {
+	if !e.CompactionsEnabled() {
+		return true
+	}
+
+	if level == 1 {
+		return false
+	}
+
+	pressure := e.UnderWritePressure()
+
+	e.compactionsMu.Lock()
+	defer e.compactionsMu.Unlock()
+	if pressure != e.backingOff {
+		if pressure {
+			e.logger.Printf("write pressure detected, deferring compactions above level 1")
+		} else {
+			e.logger.Printf("write pressure eased, resuming compactions")
+		}
+		e.backingOff = pressure
+	}
+	return pressure
+}
+
 func (e *Engine) compactTSMLevel(fast bool, level int) {
 	defer e.wg.Done()
 
@@ -588,6 +1197,11 @@ func (e *Engine) compactTSMLevel(fast bool, level int) {
 			return
 
 		default:
+			if e.backoffCompactions(level) {
+				time.Sleep(time.Second)
+				continue
+			}
+
 			tsmFiles := e.CompactionPlan.PlanLevel(level)
 
 			if len(tsmFiles) == 0 {
@@ -606,26 +1220,14 @@ func (e *Engine) compactTSMLevel(fast bool, level int) {
 						e.logger.Printf("compacting level %d group (%d) %s (#%d)", level, groupNum, f, i)
 					}
 
-					var files []string
-					var err error
-
-					if fast {
-						files, err = e.Compactor.CompactFast(group)
-						if err != nil {
-							e.logger.Printf("error compacting TSM files: %v", err)
-							time.Sleep(time.Second)
-							return
-						}
-					} else {
-						files, err = e.Compactor.CompactFull(group)
-						if err != nil {
-							e.logger.Printf("error compacting TSM files: %v", err)
-							time.Sleep(time.Second)
-							return
-						}
+					files, deletes, err := e.compactGroup(level, fast, group)
+					if err != nil {
+						e.logger.Printf("error compacting TSM files: %v", err)
+						time.Sleep(time.Second)
+						return
 					}
 
-					if err := e.FileStore.Replace(group, files); err != nil {
+					if err := e.FileStore.replace(deletes, group, files); err != nil {
 						e.logger.Printf("error replacing new TSM files: %v", err)
 						time.Sleep(time.Second)
 						return
@@ -652,6 +1254,23 @@ func (e *Engine) compactTSMFull() {
 			return
 
 		default:
+			if e.backoffCompactions(0) {
+				time.Sleep(time.Second)
+				continue
+			}
+
+			// Only start automatic full compactions inside the configured window.
+			// A requested full compaction is not forced on the planner until it
+			// is about to be planned, so level compactions are not held back
+			// while it is deferred.
+			requested := atomic.SwapInt32(&e.fullCompactionRequested, 0) == 1
+			if requested {
+				e.CompactionPlan.ForceFull()
+			} else if !e.CompactFullWindow.Contains(time.Now()) {
+				time.Sleep(time.Second)
+				continue
+			}
+
 			tsmFiles := e.CompactionPlan.Plan(e.WAL.LastWriteTime())
 
 			if len(tsmFiles) == 0 {
@@ -670,14 +1289,14 @@ func (e *Engine) compactTSMFull() {
 						e.logger.Printf("compacting full group (%d) %s (#%d)", groupNum, f, i)
 					}
 
-					files, err := e.Compactor.CompactFull(group)
+					files, deletes, err := e.compactGroup(0, false, group)
 					if err != nil {
 						e.logger.Printf("error compacting TSM files: %v", err)
 						time.Sleep(time.Second)
 						return
 					}
 
-					if err := e.FileStore.Replace(group, files); err != nil {
+					if err := e.FileStore.replace(deletes, group, files); err != nil {
 						e.logger.Printf("error replacing new TSM files: %v", err)
 						time.Sleep(time.Second)
 						return
@@ -725,8 +1344,17 @@ func (e *Engine) cleanup() error {
 		return fmt.Errorf("error getting compaction checkpoints: %s", err.Error())
 	}
 
+	if e.Compactor.TempDir != "" {
+		tmpFiles, err := filepath.Glob(filepath.Join(e.Compactor.TempDir, fmt.Sprintf("*.%s", CompactionTempExtension)))
+		if err != nil {
+			return fmt.Errorf("error getting compaction checkpoints: %s", err.Error())
+		}
+		files = append(files, tmpFiles...)
+	}
+
+	// Snapshot directories left behind by a crash are removed as well.
 	for _, f := range files {
-		if err := os.Remove(f); err != nil {
+		if err := os.RemoveAll(f); err != nil {
 			return fmt.Errorf("error removing temp compaction files: %v", err)
 		}
 	}
@@ -739,8 +1367,19 @@ func (e *Engine) KeyCursor(key string, t int64, ascending bool) *KeyCursor {
 	return e.FileStore.KeyCursor(key, t, ascending)
 }
 
+// keyCursorRange returns a cursor over the values of key between min and max.
+func (e *Engine) keyCursorRange(key string, min, max int64, ascending bool) *KeyCursor {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.FileStore.KeyCursorRange(key, min, max, ascending)
+}
+
 func (e *Engine) CreateIterator(opt influxql.IteratorOptions) (influxql.Iterator, error) {
 	if call, ok := opt.Expr.(*influxql.Call); ok {
+		if canUseBlockSummaries(call, opt) {
+			return e.createBlockSummaryIterator(call, opt)
+		}
+
 		refOpt := opt
 		refOpt.Expr = call.Args[0].(*influxql.VarRef)
 		inputs, err := e.createVarRefIterator(refOpt)
@@ -792,22 +1431,24 @@ func (e *Engine) SeriesKeys(opt influxql.IteratorOptions) (influxql.SeriesList,
 			// Determine the aux field types.
 			for _, seriesKey := range t.SeriesKeys {
 				tags := influxql.NewTags(e.index.TagsForSeries(seriesKey))
-				for i, field := range opt.Aux {
+				for i, ref := range opt.Aux {
 					typ := func() influxql.DataType {
 						mf := e.measurementFields[mm.Name]
 						if mf == nil {
 							return influxql.Unknown
 						}
 
-						f := mf.Field(field)
+						f := mf.Field(ref.Val)
 						if f == nil {
 							return influxql.Unknown
 						}
-						return f.Type
+						return influxql.QualifiedType(f.Type, ref.Type)
 					}()
 
-					if typ == influxql.Unknown {
-						if v := tags.Value(field); v != "" {
+					// Only references not qualified with a field type
+					// fall back to the tag.
+					if typ == influxql.Unknown && (ref.Type == influxql.Unknown || ref.Type == influxql.Tag) {
+						if v := tags.Value(ref.Val); v != "" {
 							// All tags are strings.
 							typ = influxql.String
 						}
@@ -826,10 +1467,78 @@ func (e *Engine) SeriesKeys(opt influxql.IteratorOptions) (influxql.SeriesList,
 	return seriesList, nil
 }
 
+// IteratorCost returns the estimated cost of creating an iterator for opt.
+// The cost is calculated from the index, the cache and the TSM file indexes
+// without decoding any blocks.
+func (e *Engine) IteratorCost(opt influxql.IteratorOptions) (influxql.IteratorCost, error) {
+	// Determine the fields read by the iterator.
+	var names []string
+	switch expr := opt.Expr.(type) {
+	case *influxql.VarRef:
+		names = append(names, expr.Val)
+	case *influxql.Call:
+		if ref, ok := expr.Args[0].(*influxql.VarRef); ok {
+			names = append(names, ref.Val)
+		}
+	}
+	for _, ref := range opt.Aux {
+		if ref.Type != influxql.Tag {
+			names = append(names, ref.Val)
+		}
+	}
+	names = append(names, influxql.ExprNames(opt.Condition)...)
+
+	var cost influxql.IteratorCost
+	mms := tsdb.Measurements(e.index.MeasurementsByName(influxql.Sources(opt.Sources).Names()))
+	for _, mm := range mms {
+		// Only fields are read from the cache and files. Tags come from the index.
+		var fields []string
+		if mf := e.measurementFields[mm.Name]; mf != nil {
+			seen := make(map[string]struct{}, len(names))
+			for _, name := range names {
+				if _, ok := seen[name]; ok || mf.Field(name) == nil {
+					continue
+				}
+				seen[name] = struct{}{}
+				fields = append(fields, name)
+			}
+		}
+
+		tagSets, err := mm.TagSets(opt.Dimensions, opt.Condition)
+		if err != nil {
+			return influxql.IteratorCost{}, err
+		}
+		tagSets = influxql.LimitTagSets(tagSets, opt.SLimit, opt.SOffset)
+
+		for _, t := range tagSets {
+			for _, seriesKey := range t.SeriesKeys {
+				cost.NumSeries++
+				for _, field := range fields {
+					key := SeriesFieldKey(seriesKey, field)
+					cost.CachedValues += len(e.Cache.ValuesRange(key, opt.StartTime, opt.EndTime))
+
+					e.mu.RLock()
+					files, blocks, size := e.FileStore.Cost(key, opt.StartTime, opt.EndTime)
+					e.mu.RUnlock()
+
+					cost.NumFiles += files
+					cost.BlocksRead += blocks
+					cost.BlockSize += size
+				}
+			}
+		}
+	}
+	return cost, nil
+}
+
 // createVarRefIterator creates an iterator for a variable reference.
 func (e *Engine) createVarRefIterator(opt influxql.IteratorOptions) ([]influxql.Iterator, error) {
 	ref, _ := opt.Expr.(*influxql.VarRef)
 
+	// No series or tag set needs to return more points than the limit and
+	// offset, so cursors can stop reading as soon as they have.
+	pushLimit := canPushDownLimit(opt)
+
 	var itrs []influxql.Iterator
 	if err := func() error {
 		mms := tsdb.Measurements(e.index.MeasurementsByName(influxql.Sources(opt.Sources).Names()))
@@ -865,10 +1574,14 @@ func (e *Engine) createVarRefIterator(opt influxql.IteratorOptions) ([]influxql.
 					} else if input == nil {
 						continue
 					}
+
+					if pushLimit {
+						input = newLimitIterator(input, opt)
+					}
 					inputs = append(inputs, input)
 				}
 
-				if len(inputs) > 0 && (opt.Limit > 0 || opt.Offset > 0) {
+				if len(inputs) > 0 && pushLimit {
 					var itr influxql.Iterator
 					if opt.MergeSorted() {
 						itr = influxql.NewSortedMergeIterator(inputs, opt)
@@ -904,16 +1617,22 @@ func (e *Engine) createVarRefSeriesIterator(ref *influxql.VarRef, mm *tsdb.Measu
 	if len(opt.Aux) > 0 {
 		aux = make([]cursorAt, len(opt.Aux))
 		for i := range aux {
+			ref := opt.Aux[i]
+
 			// Create cursor from field.
-			cur := e.buildCursor(mm.Name, seriesKey, opt.Aux[i], opt)
-			if cur != nil {
-				aux[i] = newBufCursor(cur, opt.Ascending)
-				continue
+			if ref.Type != influxql.Tag {
+				if cur := e.buildQualifiedCursor(mm.Name, seriesKey, ref.Val, ref.Type, opt); cur != nil {
+					aux[i] = newBufCursor(cur, opt.Ascending)
+					continue
+				}
 			}
 
-			// If field doesn't exist, use the tag value.
+			// If field doesn't exist, use the tag value unless the reference
+			// is qualified with a field type.
 			// However, if the tag value is blank then return a null.
-			if v := tags.Value(opt.Aux[i]); v == "" {
+			if ref.Type != influxql.Unknown && ref.Type != influxql.Tag {
+				aux[i] = &stringNilLiteralCursor{}
+			} else if v := tags.Value(ref.Val); v == "" {
 				aux[i] = &stringNilLiteralCursor{}
 			} else {
 				aux[i] = &stringLiteralCursor{value: v}
@@ -952,7 +1671,7 @@ func (e *Engine) createVarRefSeriesIterator(ref *influxql.VarRef, mm *tsdb.Measu
 	}
 
 	// Build main cursor.
-	cur := e.buildCursor(mm.Name, seriesKey, ref.Val, opt)
+	cur := e.buildQualifiedCursor(mm.Name, seriesKey, ref.Val, ref.Type, opt)
 
 	// If the field doesn't exist then don't build an iterator.
 	if cur == nil {
@@ -973,6 +1692,179 @@ func (e *Engine) createVarRefSeriesIterator(ref *influxql.VarRef, mm *tsdb.Measu
 	}
 }
 
+// canPushDownLimit returns true if the limit and offset of opt can be applied
+// to each series and tag set.  An offset without a limit must read every point
+// anyway and deduplicated points may be dropped after the limit is applied.
+func canPushDownLimit(opt influxql.IteratorOptions) bool {
+	return opt.Limit > 0 && !opt.Dedupe
+}
+
+// canUseBlockSummaries returns true if call can be computed from the block
+// summaries of TSM files for blocks that do not need to be filtered.
+func canUseBlockSummaries(call *influxql.Call, opt influxql.IteratorOptions) bool {
+	switch call.Name {
+	case "count", "sum", "mean", "min", "max", "first", "last":
+	default:
+		return false
+	}
+	return opt.Ascending && len(opt.Aux) == 0 && opt.Limit == 0 && opt.Offset == 0
+}
+
+// createBlockSummaryIterator creates an iterator for call that aggregates whole
+// blocks from their summaries and only decodes the blocks it must.  Each series
+// produces partial aggregates which are combined by applying the call again.
+func (e *Engine) createBlockSummaryIterator(call *influxql.Call, opt influxql.IteratorOptions) (influxql.Iterator, error) {
+	ref := call.Args[0].(*influxql.VarRef)
+
+	var itrs []influxql.Iterator
+	if err := func() error {
+		mms := tsdb.Measurements(e.index.MeasurementsByName(influxql.Sources(opt.Sources).Names()))
+
+		for _, mm := range mms {
+			tagSets, err := mm.TagSets(opt.Dimensions, opt.Condition)
+			if err != nil {
+				return err
+			}
+			tagSets = influxql.LimitTagSets(tagSets, opt.SLimit, opt.SOffset)
+
+			for _, t := range tagSets {
+				for i, seriesKey := range t.SeriesKeys {
+					inputs, err := e.createBlockSummarySeriesIterators(call, ref, mm, seriesKey, t, t.Filters[i], opt)
+					if err != nil {
+						return err
+					}
+					itrs = append(itrs, inputs...)
+				}
+			}
+		}
+		return nil
+	}(); err != nil {
+		influxql.Iterators(itrs).Close()
+		return nil, err
+	}
+
+	input := influxql.NewMergeIterator(itrs, opt)
+	if input == nil {
+		return nil, nil
+	}
+	if opt.InterruptCh != nil {
+		input = influxql.NewInterruptIterator(input, opt.InterruptCh)
+	}
+
+	// Partial counts are combined by summing them.
+	if call.Name == "count" {
+		opt.Expr = &influxql.Call{Name: "sum", Args: call.Args}
+	}
+	return influxql.NewCallIterator(input, opt)
+}
+
+// createBlockSummarySeriesIterators returns iterators of partial aggregates for
+// call over a single series.
+func (e *Engine) createBlockSummarySeriesIterators(call *influxql.Call, ref *influxql.VarRef, mm *tsdb.Measurement, seriesKey string, t *influxql.TagSet, filter influxql.Expr, opt influxql.IteratorOptions) ([]influxql.Iterator, error) {
+	refOpt := opt
+	refOpt.Expr = ref
+	refOpt.Condition = filter
+
+	var typ influxql.DataType
+	if mf := e.measurementFields[mm.Name]; mf != nil {
+		if f := mf.Field(ref.Val); f != nil && influxql.QualifiedType(f.Type, ref.Type) != influxql.Unknown {
+			typ = f.Type
+		}
+	}
+
+	// Points must be read individually when they are filtered on fields or
+	// when the field type has no numeric summary.
+	if filter != nil || (call.Name != "count" && typ != influxql.Float && typ != influxql.Integer) {
+		var conditionFields []string
+		if filter != nil {
+			conditionFields = influxql.ExprNames(filter)
+		}
+
+		itr, err := e.createVarRefSeriesIterator(ref, mm, seriesKey, t, filter, conditionFields, refOpt)
+		if err != nil || itr == nil {
+			return nil, err
+		}
+		callItr, err := influxql.NewCallIterator(itr, opt)
+		if err != nil {
+			itr.Close()
+			return nil, err
+		}
+		return []influxql.Iterator{callItr}, nil
+	} else if typ == influxql.Unknown {
+		return nil, nil
+	}
+
+	key := SeriesFieldKey(seriesKey, ref.Val)
+	cacheValues := e.Cache.ValuesRange(key, opt.StartTime, opt.EndTime)
+	summaries, keyCursor := e.summaryKeyCursor(key, opt.StartTime, opt.EndTime, func(entry *IndexEntry, s *BlockSummary) bool {
+		// The block must lie within the query and a single interval, and
+		// must not share any timestamps with points in the cache.
+		if entry.MinTime < opt.StartTime || entry.MaxTime > opt.EndTime {
+			return false
+		} else if (call.Name == "first" || call.Name == "last") && !s.HasEnds {
+			return false
+		}
+		minStart, _ := opt.Window(entry.MinTime)
+		maxStart, _ := opt.Window(entry.MaxTime)
+		if minStart != maxStart {
+			return false
+		}
+
+		i := sort.Search(len(cacheValues), func(i int) bool { return cacheValues[i].UnixNano() >= entry.MinTime })
+		return i == len(cacheValues) || cacheValues[i].UnixNano() > entry.MaxTime
+	})
+
+	tags := influxql.NewTags(e.index.TagsForSeries(seriesKey))
+	tags = tags.Subset(opt.Dimensions)
+
+	var itr influxql.Iterator
+	switch typ {
+	case influxql.Float:
+		itr = newFloatIterator(mm.Name, tags, refOpt, newFloatCursor(opt.SeekTime(), true, cacheValues, keyCursor), nil, nil, nil)
+	case influxql.Integer:
+		itr = newIntegerIterator(mm.Name, tags, refOpt, newIntegerCursor(opt.SeekTime(), true, cacheValues, keyCursor), nil, nil, nil)
+	case influxql.String:
+		itr = newStringIterator(mm.Name, tags, refOpt, newStringCursor(opt.SeekTime(), true, cacheValues, keyCursor), nil, nil, nil)
+	case influxql.Boolean:
+		itr = newBooleanIterator(mm.Name, tags, refOpt, newBooleanCursor(opt.SeekTime(), true, cacheValues, keyCursor), nil, nil, nil)
+	}
+
+	callItr, err := influxql.NewCallIterator(itr, opt)
+	if err != nil {
+		itr.Close()
+		return nil, err
+	}
+
+	if len(summaries) == 0 {
+		return []influxql.Iterator{callItr}, nil
+	}
+	return []influxql.Iterator{callItr, newSummaryIterator(mm.Name, tags, call.Name, typ, summaries)}, nil
+}
+
+// summaryKeyCursor returns the block summaries for key accepted by fn and a
+// cursor over the remaining blocks.
+func (e *Engine) summaryKeyCursor(key string, min, max int64, fn func(entry *IndexEntry, s *BlockSummary) bool) ([]BlockSummary, *KeyCursor) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.FileStore.SummaryKeyCursor(key, min, max, fn)
+}
+
+// buildQualifiedCursor creates an untyped cursor for a field if its type is
+// read by a reference qualified with qualifier.
+func (e *Engine) buildQualifiedCursor(measurement, seriesKey, field string, qualifier influxql.DataType, opt influxql.IteratorOptions) cursor {
+	if qualifier != influxql.Unknown {
+		mf := e.measurementFields[measurement]
+		if mf == nil {
+			return nil
+		}
+		f := mf.Field(field)
+		if f == nil || influxql.QualifiedType(f.Type, qualifier) == influxql.Unknown {
+			return nil
+		}
+	}
+	return e.buildCursor(measurement, seriesKey, field, opt)
+}
+
 // buildCursor creates an untyped cursor for a field.
 func (e *Engine) buildCursor(measurement, seriesKey, field string, opt influxql.IteratorOptions) cursor {
 	// Look up fields for measurement.
@@ -986,6 +1878,7 @@ func (e *Engine) buildCursor(measurement, seriesKey, field string, opt influxql.
 	if f == nil {
 		return nil
 	}
+	e.statMap.Add(statCursorsCreated, 1)
 
 	// Return appropriate cursor based on type.
 	switch f.Type {
@@ -1004,29 +1897,29 @@ func (e *Engine) buildCursor(measurement, seriesKey, field string, opt influxql.
 
 // buildFloatCursor creates a cursor for a float field.
 func (e *Engine) buildFloatCursor(measurement, seriesKey, field string, opt influxql.IteratorOptions) floatCursor {
-	cacheValues := e.Cache.Values(SeriesFieldKey(seriesKey, field))
-	keyCursor := e.KeyCursor(SeriesFieldKey(seriesKey, field), opt.SeekTime(), opt.Ascending)
+	cacheValues := e.Cache.ValuesRange(SeriesFieldKey(seriesKey, field), opt.StartTime, opt.EndTime)
+	keyCursor := e.keyCursorRange(SeriesFieldKey(seriesKey, field), opt.StartTime, opt.EndTime, opt.Ascending)
 	return newFloatCursor(opt.SeekTime(), opt.Ascending, cacheValues, keyCursor)
 }
 
 // buildIntegerCursor creates a cursor for an integer field.
 func (e *Engine) buildIntegerCursor(measurement, seriesKey, field string, opt influxql.IteratorOptions) integerCursor {
-	cacheValues := e.Cache.Values(SeriesFieldKey(seriesKey, field))
-	keyCursor := e.KeyCursor(SeriesFieldKey(seriesKey, field), opt.SeekTime(), opt.Ascending)
+	cacheValues := e.Cache.ValuesRange(SeriesFieldKey(seriesKey, field), opt.StartTime, opt.EndTime)
+	keyCursor := e.keyCursorRange(SeriesFieldKey(seriesKey, field), opt.StartTime, opt.EndTime, opt.Ascending)
 	return newIntegerCursor(opt.SeekTime(), opt.Ascending, cacheValues, keyCursor)
 }
 
 // buildStringCursor creates a cursor for a string field.
 func (e *Engine) buildStringCursor(measurement, seriesKey, field string, opt influxql.IteratorOptions) stringCursor {
-	cacheValues := e.Cache.Values(SeriesFieldKey(seriesKey, field))
-	keyCursor := e.KeyCursor(SeriesFieldKey(seriesKey, field), opt.SeekTime(), opt.Ascending)
+	cacheValues := e.Cache.ValuesRange(SeriesFieldKey(seriesKey, field), opt.StartTime, opt.EndTime)
+	keyCursor := e.keyCursorRange(SeriesFieldKey(seriesKey, field), opt.StartTime, opt.EndTime, opt.Ascending)
 	return newStringCursor(opt.SeekTime(), opt.Ascending, cacheValues, keyCursor)
 }
 
 // buildBooleanCursor creates a cursor for a boolean field.
 func (e *Engine) buildBooleanCursor(measurement, seriesKey, field string, opt influxql.IteratorOptions) booleanCursor {
-	cacheValues := e.Cache.Values(SeriesFieldKey(seriesKey, field))
-	keyCursor := e.KeyCursor(SeriesFieldKey(seriesKey, field), opt.SeekTime(), opt.Ascending)
+	cacheValues := e.Cache.ValuesRange(SeriesFieldKey(seriesKey, field), opt.StartTime, opt.EndTime)
+	keyCursor := e.keyCursorRange(SeriesFieldKey(seriesKey, field), opt.StartTime, opt.EndTime, opt.Ascending)
 	return newBooleanCursor(opt.SeekTime(), opt.Ascending, cacheValues, keyCursor)
 }
 
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -99,6 +100,74 @@ func TestEngine_LoadMetadataIndex(t *testing.T) {
 	}
 }
 
+// Ensure engine loads the metadata index from the key index file and
+// rebuilds it from the TSM files when the key index is stale or corrupt.
+func TestEngine_LoadMetadataIndex_KeyIndex(t *testing.T) {
+	e := MustOpenEngine()
+	defer e.Close()
+
+	if err := e.WritePointsString(
+		`cpu,host=A value=1.1 1000000000`,
+		`mem,host=A free=10i 1000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	e.MustWriteSnapshot()
+
+	// Reopening writes the key index for the new TSM file.
+	if err := e.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(e.root, "data", "keys.idx")
+	stale, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("key index not written: %s", err)
+	}
+
+	verify := func(keys ...string) {
+		index := tsdb.NewDatabaseIndex("db")
+		if err := e.LoadMetadataIndex(1, index); err != nil {
+			t.Fatal(err)
+		}
+
+		got := index.SeriesKeys()
+		sort.Strings(got)
+		for _, k := range got {
+			if !index.Series(k).Assigned(1) {
+				t.Fatalf("series not assigned to shard: %s", k)
+			}
+		}
+		if !reflect.DeepEqual(got, keys) {
+			t.Fatalf("unexpected series: exp %v, got %v", keys, got)
+		}
+
+		if f := e.MeasurementFields("mem").Field("free"); f == nil || f.Type != influxql.Integer {
+			t.Fatalf("unexpected field: %#v", f)
+		}
+	}
+	verify("cpu,host=A", "mem,host=A")
+
+	// A stale key index is ignored.
+	if err := e.WritePointsString(`cpu,host=B value=1.2 2000000000`); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	e.MustWriteSnapshot()
+	if err := e.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(path, stale, 0666); err != nil {
+		t.Fatal(err)
+	}
+	verify("cpu,host=A", "cpu,host=B", "mem,host=A")
+
+	// A corrupt key index is ignored.
+	if err := e.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(path, []byte("corrupt"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	verify("cpu,host=A", "cpu,host=B", "mem,host=A")
+}
+
 // Ensure that deletes only sent to the WAL will clear out the data from the cache on restart
 func TestEngine_DeleteWALLoadMetadata(t *testing.T) {
 	e := MustOpenEngine()
@@ -130,6 +199,154 @@ func TestEngine_DeleteWALLoadMetadata(t *testing.T) {
 	}
 }
 
+// Ensure the engine reports write pressure once the cache passes the backoff threshold.
+func TestEngine_UnderWritePressure(t *testing.T) {
+	e := NewEngine()
+	defer e.Close()
+
+	e.Cache = tsm1.NewCache(1024, "")
+	e.CompactionBackoffCachePercent = 50
+	e.CompactionBackoffWALSyncLatency = 0
+
+	if e.UnderWritePressure() {
+		t.Fatal("unexpected write pressure with an empty cache")
+	}
+
+	values := make([]tsm1.Value, 64)
+	for i := range values {
+		values[i] = tsm1.NewValue(int64(i), float64(i))
+	}
+	if err := e.Cache.Write("cpu,host=A#!~#value", values); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.UnderWritePressure() {
+		t.Fatal("expected write pressure with a full cache")
+	}
+
+	e.CompactionBackoffCachePercent = 0
+	if e.UnderWritePressure() {
+		t.Fatal("unexpected write pressure with backoff disabled")
+	}
+}
+
+// Ensure compactions can be paused and resumed.
+func TestEngine_SetCompactionsEnabled(t *testing.T) {
+	e := NewEngine()
+	defer e.Close()
+
+	if !e.CompactionsEnabled() {
+		t.Fatal("expected compactions to be enabled by default")
+	}
+
+	e.SetCompactionsEnabled(false)
+	if e.CompactionsEnabled() {
+		t.Fatal("expected compactions to be paused")
+	}
+
+	e.SetCompactionsEnabled(true)
+	if !e.CompactionsEnabled() {
+		t.Fatal("expected compactions to be resumed")
+	}
+}
+
+// Ensure that snapshots use the float encoding configured for the shard's database.
+func TestEngine_WriteSnapshot_DatabaseFloatEncoding(t *testing.T) {
+	root, err := ioutil.TempDir("", "tsm1-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	opt := tsdb.NewEngineOptions()
+	opt.Config.DatabaseFloatEncodings = []string{"db0=raw"}
+
+	var points []string
+	for i := 0; i < 1000; i++ {
+		points = append(points, fmt.Sprintf("cpu,host=A value=1.5 %d", (i+1)*1000000000))
+	}
+
+	sizes := map[string]uint32{}
+	for _, db := range []string{"db0", "db1"} {
+		e := tsm1.NewEngine(
+			filepath.Join(root, db, "rp0", "1"),
+			filepath.Join(root, db, "rp0", "wal"),
+			opt).(*tsm1.Engine)
+		if err := e.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := e.WritePoints(MustParsePointsString(strings.Join(points, "\n"))); err != nil {
+			t.Fatalf("failed to write points: %s", err.Error())
+		}
+		if err := e.WriteSnapshot(); err != nil {
+			t.Fatalf("failed to snapshot: %s", err.Error())
+		}
+
+		files := e.FileStore.Files()
+		if got, exp := len(files), 1; got != exp {
+			t.Fatalf("%s: file count mismatch: got %v, exp %v", db, got, exp)
+		}
+		sizes[db] = files[0].Size()
+
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Raw values take 8 bytes each while repeated values compress to a bit.
+	if sizes["db0"] < sizes["db1"]+7000 {
+		t.Fatalf("expected db0 snapshot to use raw encoding: db0 %d, db1 %d", sizes["db0"], sizes["db1"])
+	}
+}
+
+// Ensure that snapshots encode blocks of the configured maximum size.
+func TestEngine_WriteSnapshot_MaxPointsPerBlock(t *testing.T) {
+	root, err := ioutil.TempDir("", "tsm1-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	opt := tsdb.NewEngineOptions()
+	opt.Config.MaxPointsPerBlock = tsdb.MinMaxPointsPerBlock
+
+	e := tsm1.NewEngine(filepath.Join(root, "data"), filepath.Join(root, "wal"), opt).(*tsm1.Engine)
+	if err := e.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	var points []string
+	for i := 0; i < 250; i++ {
+		points = append(points, fmt.Sprintf("cpu,host=A value=%d.5 %d", i, (i+1)*1000000000))
+	}
+	if err := e.WritePoints(MustParsePointsString(strings.Join(points, "\n"))); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	if err := e.WriteSnapshot(); err != nil {
+		t.Fatalf("failed to snapshot: %s", err.Error())
+	}
+
+	files := e.FileStore.Files()
+	if got, exp := len(files), 1; got != exp {
+		t.Fatalf("file count mismatch: got %v, exp %v", got, exp)
+	}
+
+	entries := files[0].Entries("cpu,host=A#!~#value")
+	if got, exp := len(entries), 3; got != exp {
+		t.Fatalf("block count mismatch: got %v, exp %v", got, exp)
+	}
+	for i, exp := range []int{100, 100, 50} {
+		values, err := files[0].ReadAt("cpu,host=A#!~#value", &entries[i], nil)
+		if err != nil {
+			t.Fatalf("unexpected error reading block %d: %v", i, err)
+		} else if got := len(values); got != exp {
+			t.Fatalf("block %d length mismatch: got %v, exp %v", i, got, exp)
+		}
+	}
+}
+
 // Ensure that the engine will backup any TSM files created since the passed in time
 func TestEngine_Backup(t *testing.T) {
 	// Generate temporary file.
@@ -213,6 +430,73 @@ func TestEngine_Backup(t *testing.T) {
 	}
 }
 
+// Ensure that a snapshot of the engine is not changed by later deletes and
+// that snapshots left behind are removed when the engine is opened.
+func TestEngine_CreateSnapshot(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	walPath := filepath.Join(dir, "wal")
+
+	e := tsm1.NewEngine(dir, walPath, tsdb.NewEngineOptions()).(*tsm1.Engine)
+
+	// mock the planner so compactions don't run during the test
+	e.CompactionPlan = &mockPlanner{}
+
+	if err := e.Open(); err != nil {
+		t.Fatalf("failed to open tsm1 engine: %s", err.Error())
+	}
+	defer e.Close()
+
+	if err := e.WritePoints([]models.Point{
+		MustParsePointString("cpu,host=A value=1.1 1000000000"),
+		MustParsePointString("cpu,host=B value=1.2 2000000000"),
+	}); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	if err := e.WriteSnapshot(); err != nil {
+		t.Fatalf("failed to snapshot: %s", err.Error())
+	}
+	if err := e.DeleteSeries([]string{"cpu,host=A"}); err != nil {
+		t.Fatalf("failed to delete series: %s", err.Error())
+	}
+
+	path, err := e.CreateSnapshot()
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %s", err.Error())
+	}
+
+	before, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(before) != 2 {
+		t.Fatalf("snapshot file count wrong: exp: %d, got: %d", 2, len(before))
+	}
+
+	// Deleting the remaining series appends to the tombstone of the live file.
+	if err := e.DeleteSeries([]string{"cpu,host=B"}); err != nil {
+		t.Fatalf("failed to delete series: %s", err.Error())
+	}
+
+	after, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range before {
+		if before[i].Name() != after[i].Name() || before[i].Size() != after[i].Size() {
+			t.Fatalf("snapshot file changed: %s", before[i].Name())
+		}
+	}
+
+	// Reopening the engine removes the snapshot.
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := e.Open(); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected snapshot to be removed: %v", err)
+	}
+}
+
 // Ensure engine can create an ascending iterator for cached values.
 func TestEngine_CreateIterator_Cache_Ascending(t *testing.T) {
 	t.Parallel()
@@ -266,6 +550,88 @@ func TestEngine_CreateIterator_Cache_Ascending(t *testing.T) {
 	}
 }
 
+// Ensure aggregates combine block summaries with points that must be read.
+func TestEngine_CreateIterator_BlockSummary(t *testing.T) {
+	t.Parallel()
+
+	e := MustOpenEngine()
+	defer e.Close()
+
+	e.Index().CreateMeasurementIndexIfNotExists("cpu")
+	e.MeasurementFields("cpu").CreateFieldIfNotExists("value", influxql.Float, false)
+	e.Index().CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=A", map[string]string{"host": "A"}))
+	if err := e.WritePointsString(
+		`cpu,host=A value=4 1000000000`,
+		`cpu,host=A value=2 2000000000`,
+		`cpu,host=A value=6 3000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	e.MustWriteSnapshot()
+
+	if err := e.WritePointsString(
+		`cpu,host=A value=8 11000000000`,
+		`cpu,host=A value=1 12000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	e.MustWriteSnapshot()
+
+	// The cache overwrites a point in the second block so it must be read.
+	if err := e.WritePointsString(
+		`cpu,host=A value=3 12000000000`,
+		`cpu,host=A value=5 13000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+
+	for _, tt := range []struct {
+		expr  string
+		time  int64
+		value interface{}
+	}{
+		{expr: `count(value)`, time: 0, value: int64(6)},
+		{expr: `sum(value)`, time: 0, value: float64(28)},
+		{expr: `mean(value)`, time: 0, value: float64(28) / 6},
+		{expr: `min(value)`, time: 2000000000, value: float64(2)},
+		{expr: `max(value)`, time: 11000000000, value: float64(8)},
+		{expr: `first(value)`, time: 1000000000, value: float64(4)},
+		{expr: `last(value)`, time: 13000000000, value: float64(5)},
+	} {
+		itr, err := e.CreateIterator(influxql.IteratorOptions{
+			Expr:      influxql.MustParseExpr(tt.expr),
+			Sources:   []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+			StartTime: 0,
+			EndTime:   20000000000,
+			Ascending: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ts int64
+		var v interface{}
+		switch itr := itr.(type) {
+		case influxql.FloatIterator:
+			if p, err := itr.Next(); err != nil {
+				t.Fatalf("%s: unexpected error: %v", tt.expr, err)
+			} else if p != nil {
+				ts, v = p.Time, p.Value
+			}
+		case influxql.IntegerIterator:
+			if p, err := itr.Next(); err != nil {
+				t.Fatalf("%s: unexpected error: %v", tt.expr, err)
+			} else if p != nil {
+				ts, v = p.Time, p.Value
+			}
+		}
+		if ts != tt.time || v != tt.value {
+			t.Fatalf("%s: unexpected point: time=%d value=%v", tt.expr, ts, v)
+		}
+		itr.Close()
+	}
+}
+
 // Ensure engine can create an descending iterator for cached values.
 func TestEngine_CreateIterator_Cache_Descending(t *testing.T) {
 	t.Parallel()
@@ -428,6 +794,62 @@ func TestEngine_CreateIterator_TSM_Descending(t *testing.T) {
 }
 
 // Ensure engine can create an iterator with auxilary fields.
+// Ensure engine can create a bounded descending iterator over cached and tsm values.
+func TestEngine_CreateIterator_Descending_TimeRange(t *testing.T) {
+	t.Parallel()
+
+	e := MustOpenEngine()
+	defer e.Close()
+
+	e.Index().CreateMeasurementIndexIfNotExists("cpu")
+	e.MeasurementFields("cpu").CreateFieldIfNotExists("value", influxql.Float, false)
+	e.Index().CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=A", map[string]string{"host": "A"}))
+	if err := e.WritePointsString(
+		`cpu,host=A value=1.1 1000000000`,
+		`cpu,host=A value=1.2 2000000000`,
+		`cpu,host=A value=1.3 3000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	e.MustWriteSnapshot()
+
+	if err := e.WritePointsString(
+		`cpu,host=A value=2.2 2000000000`,
+		`cpu,host=A value=2.4 4000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+
+	itr, err := e.CreateIterator(influxql.IteratorOptions{
+		Expr:       influxql.MustParseExpr(`value`),
+		Dimensions: []string{"host"},
+		Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+		StartTime:  1500000000,
+		EndTime:    3500000000,
+		Ascending:  false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fitr := itr.(influxql.FloatIterator)
+
+	if p, err := fitr.Next(); err != nil {
+		t.Fatalf("unexpected error(0): %v", err)
+	} else if !reflect.DeepEqual(p, &influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 3000000000, Value: 1.3}) {
+		t.Fatalf("unexpected point(0): %v", p)
+	}
+	if p, err := fitr.Next(); err != nil {
+		t.Fatalf("unexpected error(1): %v", err)
+	} else if !reflect.DeepEqual(p, &influxql.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 2000000000, Value: 2.2}) {
+		t.Fatalf("unexpected point(1): %v", p)
+	}
+	if p, err := fitr.Next(); err != nil {
+		t.Fatalf("expected eof, got error: %v", err)
+	} else if p != nil {
+		t.Fatalf("expected eof: %v", p)
+	}
+}
+
 func TestEngine_CreateIterator_Aux(t *testing.T) {
 	t.Parallel()
 
@@ -450,7 +872,7 @@ func TestEngine_CreateIterator_Aux(t *testing.T) {
 
 	itr, err := e.CreateIterator(influxql.IteratorOptions{
 		Expr:       influxql.MustParseExpr(`value`),
-		Aux:        []string{"F"},
+		Aux:        []influxql.VarRef{{Val: "F"}},
 		Dimensions: []string{"host"},
 		Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
 		StartTime:  influxql.MinTime,
@@ -543,6 +965,185 @@ func TestEngine_CreateIterator_Condition(t *testing.T) {
 	}
 }
 
+// Ensure engine can estimate the cost of an iterator and count decoded blocks.
+func TestEngine_IteratorCost(t *testing.T) {
+	t.Parallel()
+
+	e := MustOpenEngine()
+	defer e.Close()
+
+	e.Index().CreateMeasurementIndexIfNotExists("cpu")
+	e.MeasurementFields("cpu").CreateFieldIfNotExists("value", influxql.Float, false)
+	e.Index().CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=A", map[string]string{"host": "A"}))
+	e.Index().CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=B", map[string]string{"host": "B"}))
+	if err := e.WritePointsString(
+		`cpu,host=A value=1.1 1000000000`,
+		`cpu,host=A value=1.2 2000000000`,
+		`cpu,host=A value=1.3 3000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	e.MustWriteSnapshot()
+
+	if err := e.WritePointsString(
+		`cpu,host=A value=1.4 4000000000`,
+		`cpu,host=B value=2.1 1000000000`,
+		`cpu,host=B value=2.2 2000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+
+	opt := influxql.IteratorOptions{
+		Expr:       influxql.MustParseExpr(`value`),
+		Dimensions: []string{"host"},
+		Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+		StartTime:  influxql.MinTime,
+		EndTime:    influxql.MaxTime,
+		Ascending:  true,
+	}
+
+	cost, err := e.IteratorCost(opt)
+	if err != nil {
+		t.Fatal(err)
+	} else if cost.NumSeries != 2 || cost.CachedValues != 3 || cost.NumFiles != 1 || cost.BlocksRead != 1 || cost.BlockSize <= 0 {
+		t.Fatalf("unexpected cost: %+v", cost)
+	}
+
+	itr, err := e.CreateIterator(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer itr.Close()
+
+	fitr := itr.(influxql.FloatIterator)
+	for {
+		if p, err := fitr.Next(); err != nil {
+			t.Fatal(err)
+		} else if p == nil {
+			break
+		}
+	}
+
+	if stats := itr.Stats(); stats.SeriesN != 2 || stats.PointN != 6 || stats.BlockN != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// Ensure engine stops decoding blocks once the limit and offset are reached.
+func TestEngine_CreateIterator_Limit(t *testing.T) {
+	t.Parallel()
+
+	e := MustOpenEngine()
+	defer e.Close()
+
+	e.Index().CreateMeasurementIndexIfNotExists("cpu")
+	e.MeasurementFields("cpu").CreateFieldIfNotExists("value", influxql.Float, false)
+	e.Index().CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=A", map[string]string{"host": "A"}))
+	for _, points := range [][]string{
+		{`cpu,host=A value=1.1 1000000000`, `cpu,host=A value=1.2 2000000000`},
+		{`cpu,host=A value=1.3 3000000000`, `cpu,host=A value=1.4 4000000000`},
+		{`cpu,host=A value=1.5 5000000000`, `cpu,host=A value=1.6 6000000000`},
+	} {
+		if err := e.WritePointsString(points...); err != nil {
+			t.Fatalf("failed to write points: %s", err.Error())
+		}
+		e.MustWriteSnapshot()
+	}
+
+	for _, tt := range []struct {
+		limit, offset int
+		descending    bool
+		values        []float64
+		blockN        int
+	}{
+		{limit: 2, values: []float64{1.1, 1.2}, blockN: 1},
+		{limit: 1, offset: 1, values: []float64{1.1, 1.2}, blockN: 1},
+		{limit: 2, offset: 1, values: []float64{1.1, 1.2, 1.3}, blockN: 2},
+		{offset: 1, values: []float64{1.1, 1.2, 1.3, 1.4, 1.5, 1.6}, blockN: 3},
+		{limit: 2, descending: true, values: []float64{1.6, 1.5}, blockN: 1},
+	} {
+		itr, err := e.CreateIterator(influxql.IteratorOptions{
+			Expr:      influxql.MustParseExpr(`value`),
+			Sources:   []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+			StartTime: influxql.MinTime,
+			EndTime:   influxql.MaxTime,
+			Ascending: !tt.descending,
+			Limit:     tt.limit,
+			Offset:    tt.offset,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Offsets are skipped by the query engine so they are returned too.
+		var values []float64
+		fitr := itr.(influxql.FloatIterator)
+		for {
+			p, err := fitr.Next()
+			if err != nil {
+				t.Fatal(err)
+			} else if p == nil {
+				break
+			}
+			values = append(values, p.Value)
+		}
+
+		itr.Close()
+
+		if !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("limit %d offset %d: unexpected values: %v", tt.limit, tt.offset, values)
+		} else if stats := itr.Stats(); stats.BlockN != tt.blockN {
+			t.Errorf("limit %d offset %d: unexpected stats: %+v", tt.limit, tt.offset, stats)
+		}
+	}
+}
+
+// Ensure engine can report the series with values in a time range from both
+// the TSM files and the cache.
+func TestEngine_ContainsSeriesRange(t *testing.T) {
+	t.Parallel()
+
+	e := MustOpenEngine()
+	defer e.Close()
+
+	e.Index().CreateMeasurementIndexIfNotExists("cpu")
+	e.MeasurementFields("cpu").CreateFieldIfNotExists("value", influxql.Float, false)
+	e.Index().CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=A", map[string]string{"host": "A"}))
+	e.Index().CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=B", map[string]string{"host": "B"}))
+	e.Index().CreateSeriesIndexIfNotExists("cpu", tsdb.NewSeries("cpu,host=C", map[string]string{"host": "C"}))
+	if err := e.WritePointsString(
+		`cpu,host=A value=1.1 1000000000`,
+		`cpu,host=A value=1.2 2000000000`,
+		`cpu,host=B value=2.1 5000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+	e.MustWriteSnapshot()
+
+	if err := e.WritePointsString(
+		`cpu,host=C value=3.1 8000000000`,
+	); err != nil {
+		t.Fatalf("failed to write points: %s", err.Error())
+	}
+
+	keys := []string{"cpu,host=A", "cpu,host=B", "cpu,host=C"}
+	for _, tt := range []struct {
+		min, max int64
+		exp      map[string]bool
+	}{
+		{min: influxql.MinTime, max: influxql.MaxTime, exp: map[string]bool{"cpu,host=A": true, "cpu,host=B": true, "cpu,host=C": true}},
+		{min: 2000000000, max: 5000000000, exp: map[string]bool{"cpu,host=A": true, "cpu,host=B": true, "cpu,host=C": false}},
+		{min: 3000000000, max: 9000000000, exp: map[string]bool{"cpu,host=A": false, "cpu,host=B": true, "cpu,host=C": true}},
+		{min: 9000000000, max: influxql.MaxTime, exp: map[string]bool{"cpu,host=A": false, "cpu,host=B": false, "cpu,host=C": false}},
+	} {
+		if m, err := e.ContainsSeriesRange(keys, tt.min, tt.max); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(m, tt.exp) {
+			t.Fatalf("unexpected series (%d-%d): %v", tt.min, tt.max, m)
+		}
+	}
+}
+
 func BenchmarkEngine_CreateIterator_Count_1K(b *testing.B) {
 	benchmarkEngineCreateIteratorCount(b, 1000)
 }
@@ -750,6 +1351,7 @@ type mockPlanner struct{}
 
 func (m *mockPlanner) Plan(lastWrite time.Time) []tsm1.CompactionGroup { return nil }
 func (m *mockPlanner) PlanLevel(level int) []tsm1.CompactionGroup      { return nil }
+func (m *mockPlanner) ForceFull()                                      {}
 
 // ParseTags returns an instance of Tags for a comma-delimited list of key/values.
 func ParseTags(s string) influxql.Tags {
@@ -12,6 +12,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/influxdata/influxdb"
@@ -27,11 +29,11 @@ type TSMFile interface {
 	Read(key string, t int64) ([]Value, error)
 
 	// ReadAt returns all the values in the block identified by entry.
-	ReadAt(entry *IndexEntry, values []Value) ([]Value, error)
-	ReadFloatBlockAt(entry *IndexEntry, tdec *TimeDecoder, vdec *FloatDecoder, values *[]FloatValue) ([]FloatValue, error)
-	ReadIntegerBlockAt(entry *IndexEntry, tdec *TimeDecoder, vdec *IntegerDecoder, values *[]IntegerValue) ([]IntegerValue, error)
-	ReadStringBlockAt(entry *IndexEntry, tdec *TimeDecoder, vdec *StringDecoder, values *[]StringValue) ([]StringValue, error)
-	ReadBooleanBlockAt(entry *IndexEntry, tdec *TimeDecoder, vdec *BooleanDecoder, values *[]BooleanValue) ([]BooleanValue, error)
+	ReadAt(key string, entry *IndexEntry, values []Value) ([]Value, error)
+	ReadFloatBlockAt(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *FloatDecoder, values *[]FloatValue) ([]FloatValue, error)
+	ReadIntegerBlockAt(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *IntegerDecoder, values *[]IntegerValue) ([]IntegerValue, error)
+	ReadStringBlockAt(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *StringDecoder, values *[]StringValue) ([]StringValue, error)
+	ReadBooleanBlockAt(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *BooleanDecoder, values *[]BooleanValue) ([]BooleanValue, error)
 
 	// Entries returns the index entries for all blocks for the given key.
 	Entries(key string) []IndexEntry
@@ -51,6 +53,10 @@ type TSMFile interface {
 	// TombstoneRange returns ranges of time that are deleted for the given key.
 	TombstoneRange(key string) []TimeRange
 
+	// BlockSummary returns the precomputed aggregates for a block.  It returns
+	// false if no summary was stored for the block.
+	BlockSummary(entry *IndexEntry) (BlockSummary, bool)
+
 	// KeyRange returns the min and max keys in the file.
 	KeyRange() (string, string)
 
@@ -98,6 +104,7 @@ type TSMFile interface {
 // Statistics gathered by the FileStore.
 const (
 	statFileStoreBytes = "diskBytes"
+	statBlocksDecoded  = "blocksDecoded" // Blocks read and decoded for queries.
 )
 
 type FileStore struct {
@@ -109,9 +116,22 @@ type FileStore struct {
 
 	files []TSMFile
 
+	// deleteLogs record the deletes made while new TSM files are written.
+	deleteLogs map[*deleteLog]struct{}
+
+	// lastSnapshotID is the id of the last snapshot directory created.
+	lastSnapshotID uint64
+
 	Logger       *log.Logger
 	traceLogging bool
 
+	// VerifyOnOpen causes Open to check the checksum of every block and fail
+	// if any file is corrupt.
+	VerifyOnOpen bool
+
+	// ReaderOptions controls how the TSM files are accessed.
+	ReaderOptions TSMReaderOptions
+
 	statMap *expvar.Map
 }
 
@@ -141,6 +161,7 @@ func NewFileStore(dir string) *FileStore {
 	return &FileStore{
 		dir:          dir,
 		lastModified: time.Now(),
+		deleteLogs:   make(map[*deleteLog]struct{}),
 		Logger:       log.New(os.Stderr, "[filestore] ", log.LstdFlags),
 		statMap: influxdb.NewStatistics(
 			"tsm1_filestore:"+dir,
@@ -156,6 +177,16 @@ func (f *FileStore) SetLogOutput(w io.Writer) {
 	f.Logger = log.New(w, "[filestore] ", log.LstdFlags)
 }
 
+// readerOptions returns the options TSM files are opened with.  Warnings
+// about the files are logged by the file store.
+func (f *FileStore) readerOptions() TSMReaderOptions {
+	opt := f.ReaderOptions
+	if opt.Logger == nil {
+		opt.Logger = f.Logger
+	}
+	return opt
+}
+
 // Returns the number of TSM files currently loaded
 func (f *FileStore) Count() int {
 	f.mu.RLock()
@@ -282,9 +313,44 @@ func (f *FileStore) DeleteRange(keys []string, min, max int64) error {
 			return err
 		}
 	}
+
+	for l := range f.deleteLogs {
+		l.deletes = append(l.deletes, deleteRange{keys: keys, min: min, max: max})
+	}
 	return nil
 }
 
+// deleteLog records the deletes made while new TSM files are written by a
+// cache snapshot or compaction.  The new files are written from data read
+// before the deletes, so the deletes are applied to them when they are added
+// to the FileStore.
+type deleteLog struct {
+	deletes []deleteRange
+}
+
+type deleteRange struct {
+	keys     []string
+	min, max int64
+}
+
+// newDeleteLog starts recording deletes for TSM files about to be written.
+// The log must be passed to replace or closed with closeDeleteLog.
+func (f *FileStore) newDeleteLog() *deleteLog {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l := &deleteLog{}
+	f.deleteLogs[l] = struct{}{}
+	return l
+}
+
+// closeDeleteLog stops recording deletes to l.
+func (f *FileStore) closeDeleteLog(l *deleteLog) {
+	f.mu.Lock()
+	delete(f.deleteLogs, l)
+	f.mu.Unlock()
+}
+
 func (f *FileStore) Open() error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -329,7 +395,7 @@ func (f *FileStore) Open() error {
 
 		go func(idx int, file *os.File) {
 			start := time.Now()
-			df, err := NewTSMReader(file)
+			df, err := NewTSMReaderWithOptions(file, f.readerOptions())
 			if f.traceLogging {
 				f.Logger.Printf("%s (#%d) opened in %v", file.Name(), idx, time.Now().Sub(start))
 			}
@@ -338,6 +404,14 @@ func (f *FileStore) Open() error {
 				readerC <- &res{r: df, err: fmt.Errorf("error opening memory map for file %s: %v", file.Name(), err)}
 				return
 			}
+
+			if f.VerifyOnOpen {
+				if err := df.Verify(); err != nil {
+					df.Close()
+					readerC <- &res{err: fmt.Errorf("error verifying file %s: %v", file.Name(), err)}
+					return
+				}
+			}
 			readerC <- &res{r: df}
 		}(i, file)
 	}
@@ -392,9 +466,134 @@ func (f *FileStore) Read(key string, t int64) ([]Value, error) {
 }
 
 func (f *FileStore) KeyCursor(key string, t int64, ascending bool) *KeyCursor {
+	if ascending {
+		return f.KeyCursorRange(key, t, math.MaxInt64, true)
+	}
+	return f.KeyCursorRange(key, math.MinInt64, t, false)
+}
+
+// KeyCursorRange returns a cursor over the blocks for key with values between
+// min and max, positioned at min if ascending or max if descending.  Files
+// whose time range lies outside min and max are not searched.
+func (f *FileStore) KeyCursorRange(key string, min, max int64, ascending bool) *KeyCursor {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	return newKeyCursor(f, key, t, ascending)
+	return newKeyCursor(f, key, min, max, ascending)
+}
+
+// Cost returns the number of files and blocks, and the total size of the
+// blocks, that would be read for key with values between min and max.
+func (f *FileStore) Cost(key string, min, max int64) (files, blocks, size int) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var prev TSMFile
+	for _, loc := range f.locations(key, min, max) {
+		if loc.r != prev {
+			files++
+			prev = loc.r
+		}
+		blocks++
+		size += int(loc.entry.Size)
+	}
+	return files, blocks, size
+}
+
+// SummaryKeyCursor returns the summaries of the blocks for key between min and
+// max that can be aggregated without being read and an ascending cursor over
+// the remaining blocks.  A block is only summarized if fn returns true for it
+// and its summary, no other block for the key overlaps it and no part of it has
+// been deleted.
+func (f *FileStore) SummaryKeyCursor(key string, min, max int64, fn func(entry *IndexEntry, s *BlockSummary) bool) ([]BlockSummary, *KeyCursor) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	locations := f.locations(key, min, max)
+	overlaps := overlappingLocations(locations)
+
+	var summaries []BlockSummary
+	var seeks []location
+	for i, loc := range locations {
+		if !overlaps[i] && !tombstoned(loc.r.TombstoneRange(key), &loc.entry) {
+			if s, ok := loc.r.BlockSummary(&loc.entry); ok && fn(&loc.entry, &s) {
+				summaries = append(summaries, s)
+				continue
+			}
+		}
+		seeks = append(seeks, loc)
+	}
+
+	c := &KeyCursor{
+		key:       key,
+		fs:        f,
+		seeks:     seeks,
+		ascending: true,
+	}
+	c.duplicates = c.hasOverlappingBlocks()
+	c.seek(min)
+	return summaries, c
+}
+
+// overlappingLocations returns whether each location's block overlaps the
+// time range of any other location.
+func overlappingLocations(locations []location) []bool {
+	overlaps := make([]bool, len(locations))
+
+	idx := make([]int, len(locations))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Sort(locationsByMinTime{locations, idx})
+
+	// Walk the blocks in time order grouping runs of overlapping blocks.
+	for i := 0; i < len(idx); {
+		j, max := i+1, locations[idx[i]].entry.MaxTime
+		for ; j < len(idx) && locations[idx[j]].entry.MinTime <= max; j++ {
+			if t := locations[idx[j]].entry.MaxTime; t > max {
+				max = t
+			}
+		}
+		if j-i > 1 {
+			for _, k := range idx[i:j] {
+				overlaps[k] = true
+			}
+		}
+		i = j
+	}
+	return overlaps
+}
+
+type locationsByMinTime struct {
+	locations []location
+	idx       []int
+}
+
+func (a locationsByMinTime) Len() int      { return len(a.idx) }
+func (a locationsByMinTime) Swap(i, j int) { a.idx[i], a.idx[j] = a.idx[j], a.idx[i] }
+func (a locationsByMinTime) Less(i, j int) bool {
+	return a.locations[a.idx[i]].entry.MinTime < a.locations[a.idx[j]].entry.MinTime
+}
+
+// tombstoned returns true if any of the tombstones overlap the block.
+func tombstoned(tombstones []TimeRange, entry *IndexEntry) bool {
+	for _, t := range tombstones {
+		if t.Min <= entry.MaxTime && t.Max >= entry.MinTime {
+			return true
+		}
+	}
+	return false
+}
+
+// DiskSize returns the combined size in bytes of the TSM files.
+func (f *FileStore) DiskSize() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var n int64
+	for _, file := range f.files {
+		n += int64(file.Size())
+	}
+	return n
 }
 
 func (f *FileStore) Stats() []FileStat {
@@ -409,9 +608,19 @@ func (f *FileStore) Stats() []FileStat {
 }
 
 func (f *FileStore) Replace(oldFiles, newFiles []string) error {
+	return f.replace(nil, oldFiles, newFiles)
+}
+
+// replace is like Replace but first applies the deletes recorded in l to the
+// new files and closes l.  l may be nil.
+func (f *FileStore) replace(l *deleteLog, oldFiles, newFiles []string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	if l != nil {
+		delete(f.deleteLogs, l)
+	}
+
 	f.lastModified = time.Now()
 
 	// Copy the current set of active files while we rename
@@ -427,9 +636,10 @@ func (f *FileStore) Replace(oldFiles, newFiles []string) error {
 	for _, file := range newFiles {
 		var newName = file
 		if strings.HasSuffix(file, ".tmp") {
-			// The new TSM files have a tmp extension.  First rename them.
-			newName = file[:len(file)-4]
-			if err := os.Rename(file, newName); err != nil {
+			// The new TSM files have a tmp extension and may have been written
+			// to a separate temp directory.  First move them into place.
+			newName = filepath.Join(f.dir, filepath.Base(file[:len(file)-4]))
+			if err := moveFile(file, newName); err != nil {
 				return err
 			}
 		}
@@ -439,10 +649,18 @@ func (f *FileStore) Replace(oldFiles, newFiles []string) error {
 			return err
 		}
 
-		tsm, err := NewTSMReader(fd)
+		tsm, err := NewTSMReaderWithOptions(fd, f.readerOptions())
 		if err != nil {
 			return err
 		}
+
+		if l != nil {
+			for _, d := range l.deletes {
+				if err := tsm.DeleteRange(d.keys, d.min, d.max); err != nil {
+					return err
+				}
+			}
+		}
 		updated = append(updated, tsm)
 	}
 
@@ -524,10 +742,9 @@ func (f *FileStore) BlockCount(path string, idx int) int {
 	return 0
 }
 
-// locations returns the files and index blocks for a key and time.  ascending indicates
-// whether the key will be scan in ascending time order or descenging time order.
-// This function assumes the read-lock has been taken.
-func (f *FileStore) locations(key string, t int64, ascending bool) []location {
+// locations returns the files and index blocks for a key with values between min
+// and max.  This function assumes the read-lock has been taken.
+func (f *FileStore) locations(key string, min, max int64) []location {
 	var locations []location
 
 	filesSnapshot := make([]TSMFile, len(f.files))
@@ -539,17 +756,14 @@ func (f *FileStore) locations(key string, t int64, ascending bool) []location {
 	for _, fd := range filesSnapshot {
 		minTime, maxTime := fd.TimeRange()
 
-		tombstones := fd.TombstoneRange(key)
-		// If we ascending and the max time of the file is before where we want to start
-		// skip it.
-		if ascending && maxTime < t {
-			continue
-			// If we are descending and the min time fo the file is after where we want to start,
-			// then skip it.
-		} else if !ascending && minTime > t {
+		// Skip files that hold no values in the time range without searching
+		// their index.
+		if maxTime < min || minTime > max {
 			continue
 		}
 
+		tombstones := fd.TombstoneRange(key)
+
 		// This file could potential contain points we are looking for so find the blocks for
 		// the given key.
 		fd.ReadEntries(key, &entries)
@@ -567,13 +781,8 @@ func (f *FileStore) locations(key string, t int64, ascending bool) []location {
 			if skip {
 				continue
 			}
-			// If we ascending and the max time of a block is before where we are looking, skip
-			// it since the data is out of our range
-			if ascending && ie.MaxTime < t {
-				continue
-				// If we descending and the min time of a block is after where we are looking, skip
-				// it since the data is out of our range
-			} else if !ascending && ie.MinTime > t {
+			// Skip blocks outside the time range.
+			if ie.MaxTime < min || ie.MinTime > max {
 				continue
 			}
 
@@ -631,6 +840,9 @@ type KeyCursor struct {
 	// If this is true, we need to scan the duplicate blocks and dedup the points
 	// as query time until they are compacted.
 	duplicates bool
+
+	// decodedN is the number of blocks decoded through the cursor.
+	decodedN int
 }
 
 type location struct {
@@ -643,15 +855,19 @@ type location struct {
 
 // newKeyCursor returns a new instance of KeyCursor.
 // This function assumes the read-lock has been taken.
-func newKeyCursor(fs *FileStore, key string, t int64, ascending bool) *KeyCursor {
+func newKeyCursor(fs *FileStore, key string, min, max int64, ascending bool) *KeyCursor {
 	c := &KeyCursor{
 		key:       key,
 		fs:        fs,
-		seeks:     fs.locations(key, t, ascending),
+		seeks:     fs.locations(key, min, max),
 		ascending: ascending,
 	}
 	c.duplicates = c.hasOverlappingBlocks()
-	c.seek(t)
+	if ascending {
+		c.seek(min)
+	} else {
+		c.seek(max)
+	}
 	return c
 }
 
@@ -824,8 +1040,10 @@ func (c *KeyCursor) ReadFloatBlock(tdec *TimeDecoder, fdec *FloatDecoder, buf *[
 	// First block is the oldest block containing the points we're search for.
 	first := &c.current[0]
 	*buf = (*buf)[:0]
-	values, err := first.r.ReadFloatBlockAt(&first.entry, tdec, fdec, buf)
+	values, err := first.r.ReadFloatBlockAt(c.key, &first.entry, tdec, fdec, buf)
 	first.read = true
+	c.fs.statMap.Add(statBlocksDecoded, 1)
+	c.decodedN++
 
 	tombstones := first.r.TombstoneRange(c.key)
 
@@ -845,7 +1063,9 @@ func (c *KeyCursor) ReadFloatBlock(tdec *TimeDecoder, fdec *FloatDecoder, buf *[
 			c.pos++
 
 			var a []FloatValue
-			v, err := cur.r.ReadFloatBlockAt(&cur.entry, tdec, fdec, &a)
+			c.fs.statMap.Add(statBlocksDecoded, 1)
+			c.decodedN++
+			v, err := cur.r.ReadFloatBlockAt(c.key, &cur.entry, tdec, fdec, &a)
 			if err != nil {
 				return nil, err
 			}
@@ -855,7 +1075,9 @@ func (c *KeyCursor) ReadFloatBlock(tdec *TimeDecoder, fdec *FloatDecoder, buf *[
 			c.pos--
 
 			var a []FloatValue
-			v, err := cur.r.ReadFloatBlockAt(&cur.entry, tdec, fdec, &a)
+			c.fs.statMap.Add(statBlocksDecoded, 1)
+			c.decodedN++
+			v, err := cur.r.ReadFloatBlockAt(c.key, &cur.entry, tdec, fdec, &a)
 			if err != nil {
 				return nil, err
 			}
@@ -876,8 +1098,10 @@ func (c *KeyCursor) ReadIntegerBlock(tdec *TimeDecoder, vdec *IntegerDecoder, bu
 	// First block is the oldest block containing the points we're search for.
 	first := &c.current[0]
 	*buf = (*buf)[:0]
-	values, err := first.r.ReadIntegerBlockAt(&first.entry, tdec, vdec, buf)
+	values, err := first.r.ReadIntegerBlockAt(c.key, &first.entry, tdec, vdec, buf)
 	first.read = true
+	c.fs.statMap.Add(statBlocksDecoded, 1)
+	c.decodedN++
 
 	tombstones := first.r.TombstoneRange(c.key)
 
@@ -897,7 +1121,9 @@ func (c *KeyCursor) ReadIntegerBlock(tdec *TimeDecoder, vdec *IntegerDecoder, bu
 			c.pos++
 
 			var a []IntegerValue
-			v, err := cur.r.ReadIntegerBlockAt(&cur.entry, tdec, vdec, &a)
+			c.fs.statMap.Add(statBlocksDecoded, 1)
+			c.decodedN++
+			v, err := cur.r.ReadIntegerBlockAt(c.key, &cur.entry, tdec, vdec, &a)
 			if err != nil {
 				return nil, err
 			}
@@ -907,7 +1133,9 @@ func (c *KeyCursor) ReadIntegerBlock(tdec *TimeDecoder, vdec *IntegerDecoder, bu
 			c.pos--
 
 			var a []IntegerValue
-			v, err := cur.r.ReadIntegerBlockAt(&cur.entry, tdec, vdec, &a)
+			c.fs.statMap.Add(statBlocksDecoded, 1)
+			c.decodedN++
+			v, err := cur.r.ReadIntegerBlockAt(c.key, &cur.entry, tdec, vdec, &a)
 			if err != nil {
 				return nil, err
 			}
@@ -928,8 +1156,10 @@ func (c *KeyCursor) ReadStringBlock(tdec *TimeDecoder, vdec *StringDecoder, buf
 	// First block is the oldest block containing the points we're search for.
 	first := &c.current[0]
 	*buf = (*buf)[:0]
-	values, err := first.r.ReadStringBlockAt(&first.entry, tdec, vdec, buf)
+	values, err := first.r.ReadStringBlockAt(c.key, &first.entry, tdec, vdec, buf)
 	first.read = true
+	c.fs.statMap.Add(statBlocksDecoded, 1)
+	c.decodedN++
 
 	tombstones := first.r.TombstoneRange(c.key)
 
@@ -947,7 +1177,9 @@ func (c *KeyCursor) ReadStringBlock(tdec *TimeDecoder, vdec *StringDecoder, buf
 			cur.read = true
 			c.pos++
 			var a []StringValue
-			v, err := cur.r.ReadStringBlockAt(&cur.entry, tdec, vdec, &a)
+			c.fs.statMap.Add(statBlocksDecoded, 1)
+			c.decodedN++
+			v, err := cur.r.ReadStringBlockAt(c.key, &cur.entry, tdec, vdec, &a)
 			if err != nil {
 				return nil, err
 			}
@@ -957,7 +1189,9 @@ func (c *KeyCursor) ReadStringBlock(tdec *TimeDecoder, vdec *StringDecoder, buf
 			c.pos--
 
 			var a []StringValue
-			v, err := cur.r.ReadStringBlockAt(&cur.entry, tdec, vdec, &a)
+			c.fs.statMap.Add(statBlocksDecoded, 1)
+			c.decodedN++
+			v, err := cur.r.ReadStringBlockAt(c.key, &cur.entry, tdec, vdec, &a)
 			if err != nil {
 				return nil, err
 			}
@@ -978,8 +1212,10 @@ func (c *KeyCursor) ReadBooleanBlock(tdec *TimeDecoder, vdec *BooleanDecoder, bu
 	// First block is the oldest block containing the points we're search for.
 	first := &c.current[0]
 	*buf = (*buf)[:0]
-	values, err := first.r.ReadBooleanBlockAt(&first.entry, tdec, vdec, buf)
+	values, err := first.r.ReadBooleanBlockAt(c.key, &first.entry, tdec, vdec, buf)
 	first.read = true
+	c.fs.statMap.Add(statBlocksDecoded, 1)
+	c.decodedN++
 
 	tombstones := first.r.TombstoneRange(c.key)
 
@@ -998,7 +1234,9 @@ func (c *KeyCursor) ReadBooleanBlock(tdec *TimeDecoder, vdec *BooleanDecoder, bu
 			c.pos++
 
 			var a []BooleanValue
-			v, err := cur.r.ReadBooleanBlockAt(&cur.entry, tdec, vdec, &a)
+			c.fs.statMap.Add(statBlocksDecoded, 1)
+			c.decodedN++
+			v, err := cur.r.ReadBooleanBlockAt(c.key, &cur.entry, tdec, vdec, &a)
 			if err != nil {
 				return nil, err
 			}
@@ -1008,7 +1246,9 @@ func (c *KeyCursor) ReadBooleanBlock(tdec *TimeDecoder, vdec *BooleanDecoder, bu
 			c.pos--
 
 			var a []BooleanValue
-			v, err := cur.r.ReadBooleanBlockAt(&cur.entry, tdec, vdec, &a)
+			c.fs.statMap.Add(statBlocksDecoded, 1)
+			c.decodedN++
+			v, err := cur.r.ReadBooleanBlockAt(c.key, &cur.entry, tdec, vdec, &a)
 			if err != nil {
 				return nil, err
 			}
@@ -1052,3 +1292,106 @@ type tsmReaders []TSMFile
 func (a tsmReaders) Len() int           { return len(a) }
 func (a tsmReaders) Less(i, j int) bool { return a[i].Path() < a[j].Path() }
 func (a tsmReaders) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// CreateSnapshot creates hard links to the current TSM files in a new
+// directory under the FileStore's directory and returns its path.  Tombstone
+// files are appended to in place, so they are copied instead of linked.  The
+// snapshot is not changed by later writes, deletes or compactions and the
+// caller must remove it when it is no longer needed.
+func (f *FileStore) CreateSnapshot() (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	id := atomic.AddUint64(&f.lastSnapshotID, 1)
+	path := filepath.Join(f.dir, fmt.Sprintf("snapshot-%d.%s", id, CompactionTempExtension))
+	if err := os.Mkdir(path, 0777); err != nil {
+		return "", err
+	}
+
+	if err := func() error {
+		for _, file := range f.files {
+			if err := os.Link(file.Path(), filepath.Join(path, filepath.Base(file.Path()))); err != nil {
+				return fmt.Errorf("error creating tsm hard link: %v", err)
+			}
+
+			for _, t := range file.TombstoneFiles() {
+				if err := copyFile(t.Path, filepath.Join(path, filepath.Base(t.Path))); err != nil {
+					return fmt.Errorf("error copying tombstone: %v", err)
+				}
+			}
+		}
+		return syncDir(path)
+	}(); err != nil {
+		os.RemoveAll(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// copyFile copies the file at oldpath to newpath, keeping its modification
+// time.
+func copyFile(oldpath, newpath string) error {
+	src, err := os.Open(oldpath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(newpath, os.O_CREATE|os.O_RDWR|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	} else if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(newpath, fi.ModTime(), fi.ModTime())
+}
+
+// moveFile renames oldpath to newpath.  If the paths are on different volumes,
+// the file is copied next to newpath and then renamed so that a partially
+// copied file is never visible under newpath.
+func moveFile(oldpath, newpath string) error {
+	err := os.Rename(oldpath, newpath)
+	if le, ok := err.(*os.LinkError); !ok || le.Err != syscall.EXDEV {
+		return err
+	}
+
+	src, err := os.Open(oldpath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := newpath + "." + CompactionTempExtension
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	} else if err := dst.Sync(); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	} else if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, newpath); err != nil {
+		return err
+	}
+	return os.Remove(oldpath)
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
@@ -832,6 +833,84 @@ func TestFileStore_SeekToDesc_End(t *testing.T) {
 	}
 }
 
+func TestFileStore_KeyCursorRange(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	fs := tsm1.NewFileStore(dir)
+
+	// Setup 3 files
+	data := []keyValues{
+		keyValues{"cpu", []tsm1.Value{tsm1.NewValue(0, 1.0)}},
+		keyValues{"cpu", []tsm1.Value{tsm1.NewValue(1, 2.0)}},
+		keyValues{"cpu", []tsm1.Value{tsm1.NewValue(2, 3.0)}},
+	}
+
+	files, err := newFiles(dir, data...)
+	if err != nil {
+		t.Fatalf("unexpected error creating files: %v", err)
+	}
+
+	var counted []*countingTSMFile
+	for _, f := range files {
+		c := &countingTSMFile{TSMFile: f}
+		counted = append(counted, c)
+		fs.Add(c)
+	}
+
+	for _, tt := range []struct {
+		min, max  int64
+		ascending bool
+		exp       []float64
+	}{
+		{min: 0, max: 1, ascending: true, exp: []float64{1.0, 2.0}},
+		{min: 1, max: 2, ascending: false, exp: []float64{3.0, 2.0}},
+	} {
+		for _, f := range counted {
+			f.reads = 0
+		}
+
+		buf := make([]tsm1.FloatValue, 1000)
+		c := fs.KeyCursorRange("cpu", tt.min, tt.max, tt.ascending)
+
+		var got []float64
+		for {
+			values, err := c.ReadFloatBlock(&tsm1.TimeDecoder{}, &tsm1.FloatDecoder{}, &buf)
+			if err != nil {
+				t.Fatalf("unexpected error reading values: %v", err)
+			} else if len(values) == 0 {
+				break
+			}
+			for _, v := range values {
+				got = append(got, v.Value().(float64))
+			}
+			c.Next()
+		}
+
+		if !reflect.DeepEqual(got, tt.exp) {
+			t.Fatalf("values mismatch(%d-%d): got %v, exp %v", tt.min, tt.max, got, tt.exp)
+		}
+
+		// The index of the file outside the range is not searched.
+		for i, f := range counted {
+			inRange := int64(i) >= tt.min && int64(i) <= tt.max
+			if inRange != (f.reads > 0) {
+				t.Fatalf("file %d searched %d times for range %d-%d", i, f.reads, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+// countingTSMFile counts the index lookups of a TSMFile.
+type countingTSMFile struct {
+	tsm1.TSMFile
+	reads int
+}
+
+func (f *countingTSMFile) ReadEntries(key string, entries *[]tsm1.IndexEntry) {
+	f.reads++
+	f.TSMFile.ReadEntries(key, entries)
+}
+
 func TestKeyCursor_TombstoneRange(t *testing.T) {
 	dir := MustTempDir()
 	defer os.RemoveAll(dir)
@@ -1083,6 +1162,45 @@ func TestFileStore_Open(t *testing.T) {
 	}
 }
 
+func TestFileStore_Open_Verify(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	data := []keyValues{
+		keyValues{"cpu", []tsm1.Value{tsm1.NewValue(0, 1.0)}},
+		keyValues{"mem", []tsm1.Value{tsm1.NewValue(0, 1.0)}},
+	}
+
+	files, err := newFileDir(dir, data...)
+	if err != nil {
+		fatal(t, "creating test files", err)
+	}
+
+	// Corrupt the block of the second file, after the 5 byte header and 4
+	// byte checksum.
+	fd, err := os.OpenFile(files[1], os.O_RDWR, 0666)
+	if err != nil {
+		fatal(t, "opening file", err)
+	}
+	if _, err := fd.WriteAt([]byte{0xff, 0xff}, 10); err != nil {
+		fatal(t, "corrupting file", err)
+	}
+	fd.Close()
+
+	fs := tsm1.NewFileStore(dir)
+	if err := fs.Open(); err != nil {
+		fatal(t, "opening file store", err)
+	}
+	fs.Close()
+
+	fs = tsm1.NewFileStore(dir)
+	fs.VerifyOnOpen = true
+	if err := fs.Open(); err == nil {
+		fs.Close()
+		t.Fatal("expected verify error")
+	}
+}
+
 func TestFileStore_Remove(t *testing.T) {
 	dir := MustTempDir()
 	defer os.RemoveAll(dir)
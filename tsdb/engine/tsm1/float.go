@@ -11,6 +11,7 @@ this version.
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"math"
 
@@ -20,13 +21,24 @@ import (
 
 const (
 	// floatUncompressed is an uncompressed format using 8 bytes per value.
-	// Not yet implemented.
 	floatUncompressed = 0
 
 	// floatCompressedGorilla is a compressed format using the gorilla paper encoding
 	floatCompressedGorilla = 1
+
+	// floatEncodingRaw is the configured name of the uncompressed format.
+	floatEncodingRaw = "raw"
 )
 
+// floatEncodingType returns the compression type for a float encoding name
+// from the configuration.
+func floatEncodingType(name string) byte {
+	if name == floatEncodingRaw {
+		return floatUncompressed
+	}
+	return floatCompressedGorilla
+}
+
 // uvnan is the constant returned from math.NaN().
 const uvnan = 0x7FF8000000000001
 
@@ -43,12 +55,22 @@ type FloatEncoder struct {
 
 	first    bool
 	finished bool
+
+	// typ is the compression type written in the header.
+	typ byte
 }
 
+// NewFloatEncoder returns an encoder using gorilla compression.
 func NewFloatEncoder() *FloatEncoder {
+	return newFloatEncoder(floatCompressedGorilla)
+}
+
+// newFloatEncoder returns an encoder for the compression type typ.
+func newFloatEncoder(typ byte) *FloatEncoder {
 	s := FloatEncoder{
 		first:   true,
 		leading: ^uint64(0),
+		typ:     typ,
 	}
 
 	s.bw = bitstream.NewWriter(&s.buf)
@@ -58,10 +80,15 @@ func NewFloatEncoder() *FloatEncoder {
 }
 
 func (s *FloatEncoder) Bytes() ([]byte, error) {
-	return append([]byte{floatCompressedGorilla << 4}, s.buf.Bytes()...), s.err
+	return append([]byte{s.typ << 4}, s.buf.Bytes()...), s.err
 }
 
 func (s *FloatEncoder) Finish() {
+	if s.typ == floatUncompressed {
+		s.finished = true
+		return
+	}
+
 	if !s.finished {
 		// write an end-of-stream record
 		s.finished = true
@@ -76,6 +103,14 @@ func (s *FloatEncoder) Push(v float64) {
 		s.err = fmt.Errorf("unsupported value: NaN")
 		return
 	}
+
+	if s.typ == floatUncompressed {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
+		s.buf.Write(buf[:])
+		return
+	}
+
 	if s.first {
 		// first point
 		s.val = v
@@ -137,13 +172,33 @@ type FloatDecoder struct {
 	first    bool
 	finished bool
 
+	// raw is true if the values are uncompressed.
+	raw bool
+
 	err error
 }
 
 // SetBytes initializes the decoder with b. Must call before calling Next().
 func (it *FloatDecoder) SetBytes(b []byte) error {
+	if len(b) == 0 {
+		return fmt.Errorf("float decoder: no data")
+	}
+
 	// first byte is the compression type.
-	// we currently just have gorilla compression.
+	switch b[0] >> 4 {
+	case floatUncompressed:
+		it.raw = true
+		it.b = b[1:]
+		it.first = true
+		it.finished = false
+		it.err = nil
+		return nil
+	case floatCompressedGorilla:
+		it.raw = false
+	default:
+		return fmt.Errorf("float decoder: unknown encoding %v", b[0]>>4)
+	}
+
 	it.br.Reset(b[1:])
 
 	v, err := it.br.ReadBits(64)
@@ -168,6 +223,19 @@ func (it *FloatDecoder) Next() bool {
 		return false
 	}
 
+	if it.raw {
+		if len(it.b) == 0 {
+			it.finished = true
+			return false
+		} else if len(it.b) < 8 {
+			it.err = fmt.Errorf("float decoder: %d trailing bytes", len(it.b))
+			return false
+		}
+		it.val = binary.BigEndian.Uint64(it.b)
+		it.b = it.b[8:]
+		return true
+	}
+
 	if it.first {
 		it.first = false
 
@@ -200,6 +200,28 @@ func TestFloatEncoder_Roundtrip_NaN(t *testing.T) {
 	}
 }
 
+func TestFloatDecoder_Raw_Truncated(t *testing.T) {
+	// An uncompressed block holding one value followed by a partial value.
+	b := []byte{0x00, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0, 0x40, 0x00, 0x00}
+
+	var it tsm1.FloatDecoder
+	if err := it.SetBytes(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !it.Next() {
+		t.Fatalf("expected next, got false: %v", it.Error())
+	} else if v := it.Values(); v != 1.0 {
+		t.Fatalf("unexpected value: got %v, exp %v", v, 1.0)
+	}
+
+	if it.Next() {
+		t.Fatalf("unexpected next value: got true, exp false")
+	} else if it.Error() == nil {
+		t.Fatalf("expected error for truncated value, got nil")
+	}
+}
+
 func Test_FloatEncoder_Quick(t *testing.T) {
 	quick.Check(func(values []float64) bool {
 
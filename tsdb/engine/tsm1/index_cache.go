@@ -0,0 +1,93 @@
+package tsm1
+
+import (
+	"container/list"
+	"sync"
+)
+
+// indexEntryCache is an LRU cache of the decoded index entries of keys.  Its
+// size is the total number of index entries it holds.
+type indexEntryCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	ll      *list.List
+	items   map[string]*list.Element
+}
+
+type indexEntryCacheItem struct {
+	key     string
+	entries []IndexEntry
+}
+
+// newIndexEntryCache returns a cache holding up to maxSize index entries.
+func newIndexEntryCache(maxSize int) *indexEntryCache {
+	return &indexEntryCache{
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached entries of key.  The returned slice must not be
+// modified.
+func (c *indexEntryCache) get(key string) ([]IndexEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*indexEntryCacheItem).entries, true
+}
+
+// add adds the entries of key to the cache and evicts the least recently used
+// keys until the cache is within its size.  Keys with more entries than the
+// cache holds are not added.
+func (c *indexEntryCache) add(key string, entries []IndexEntry) {
+	if len(entries) > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; ok {
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&indexEntryCacheItem{key: key, entries: entries})
+	c.size += len(entries)
+
+	for c.size > c.maxSize {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// remove removes keys from the cache.
+func (c *indexEntryCache) remove(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, k := range keys {
+		if e, ok := c.items[k]; ok {
+			c.removeElement(e)
+		}
+	}
+}
+
+func (c *indexEntryCache) removeElement(e *list.Element) {
+	item := e.Value.(*indexEntryCacheItem)
+	c.ll.Remove(e)
+	delete(c.items, item.key)
+	c.size -= len(item.entries)
+}
+
+// len returns the number of keys in the cache.
+func (c *indexEntryCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -26,6 +26,19 @@ type cursorAt interface {
 	nextAt(seek int64) interface{}
 }
 
+// blockCounter is implemented by cursors that decode blocks from TSM files.
+type blockCounter interface {
+	blockN() int
+}
+
+// cursorBlockN returns the number of blocks decoded by cur.
+func cursorBlockN(cur interface{}) int {
+	if bc, ok := cur.(blockCounter); ok {
+		return bc.blockN()
+	}
+	return 0
+}
+
 type nilCursor struct{}
 
 func (nilCursor) next() (int64, interface{}) { return tsdb.EOF, nil }
@@ -56,6 +69,9 @@ func (c *bufCursor) next() (int64, interface{}) {
 	return c.cur.next()
 }
 
+// blockN returns the number of blocks decoded by the underlying cursor.
+func (c *bufCursor) blockN() int { return cursorBlockN(c.cur) }
+
 // unread pushes k and v onto the buffer.
 func (c *bufCursor) unread(k int64, v interface{}) {
 	c.buf.key, c.buf.value = k, v
@@ -214,11 +230,25 @@ func (itr *floatIterator) Next() (*influxql.FloatPoint, error) {
 
 // copyStats copies from the itr stats buffer to the stats under lock.
 func (itr *floatIterator) copyStats() {
+	itr.statsBuf.BlockN = itr.blockN()
+
 	itr.statsLock.Lock()
 	itr.stats = itr.statsBuf
 	itr.statsLock.Unlock()
 }
 
+// blockN returns the number of blocks decoded by all cursors of the iterator.
+func (itr *floatIterator) blockN() int {
+	n := cursorBlockN(itr.cur)
+	for _, c := range itr.aux {
+		n += cursorBlockN(c)
+	}
+	for _, c := range itr.conds.curs {
+		n += cursorBlockN(c)
+	}
+	return n
+}
+
 // Stats returns stats on the points processed.
 func (itr *floatIterator) Stats() influxql.IteratorStats {
 	itr.statsLock.Lock()
@@ -227,8 +257,12 @@ func (itr *floatIterator) Stats() influxql.IteratorStats {
 	return stats
 }
 
-// Close closes the iterator.
-func (itr *floatIterator) Close() error { return nil }
+// Close closes the iterator.  The stats of an iterator that stopped before
+// the end of its cursors, such as one cut short by a limit, are copied too.
+func (itr *floatIterator) Close() error {
+	itr.copyStats()
+	return nil
+}
 
 // floatLimitIterator
 type floatLimitIterator struct {
@@ -249,8 +283,8 @@ func (itr *floatLimitIterator) Close() error                  { return itr.input
 
 func (itr *floatLimitIterator) Next() (*influxql.FloatPoint, error) {
 	for {
-		// Check if we are beyond the limit.
-		if (itr.n - itr.opt.Offset) > itr.opt.Limit {
+		// Stop reading once the limit has been reached.
+		if (itr.n - itr.opt.Offset) >= itr.opt.Limit {
 			return nil, nil
 		}
 
@@ -294,6 +328,7 @@ type floatAscendingCursor struct {
 		values    []FloatValue
 		pos       int
 		keyCursor *KeyCursor
+		pending   bool // next block is read when its values are needed
 	}
 }
 
@@ -338,8 +373,17 @@ func (c *floatAscendingCursor) peekTSM() (t int64, v float64) {
 // next returns the next key/value for the cursor.
 func (c *floatAscendingCursor) next() (int64, interface{}) { return c.nextFloat() }
 
+// blockN returns the number of blocks decoded from TSM files.
+func (c *floatAscendingCursor) blockN() int {
+	if c.tsm.keyCursor == nil {
+		return 0
+	}
+	return c.tsm.keyCursor.decodedN
+}
+
 // nextFloat returns the next key/value for the cursor.
 func (c *floatAscendingCursor) nextFloat() (int64, float64) {
+	c.readTSM()
 	ckey, cvalue := c.peekCache()
 	tkey, tvalue := c.peekTSM()
 
@@ -377,14 +421,20 @@ func (c *floatAscendingCursor) nextCache() {
 // nextTSM returns the next value from the TSM files.
 func (c *floatAscendingCursor) nextTSM() {
 	c.tsm.pos++
-	if c.tsm.pos >= len(c.tsm.values) {
-		c.tsm.keyCursor.Next()
-		c.tsm.values, _ = c.tsm.keyCursor.ReadFloatBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
-		if len(c.tsm.values) == 0 {
-			return
-		}
-		c.tsm.pos = 0
+	c.tsm.pending = c.tsm.pos >= len(c.tsm.values)
+}
+
+// readTSM reads the next block from the TSM files once the current block has
+// been read.  It is deferred until another value is needed so a cursor that
+// is no longer read does not decode another block.
+func (c *floatAscendingCursor) readTSM() {
+	if !c.tsm.pending {
+		return
 	}
+	c.tsm.pending = false
+	c.tsm.keyCursor.Next()
+	c.tsm.values, _ = c.tsm.keyCursor.ReadFloatBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
+	c.tsm.pos = 0
 }
 
 type floatDescendingCursor struct {
@@ -400,6 +450,7 @@ type floatDescendingCursor struct {
 		values    []FloatValue
 		pos       int
 		keyCursor *KeyCursor
+		pending   bool // next block is read when its values are needed
 	}
 }
 
@@ -450,8 +501,17 @@ func (c *floatDescendingCursor) peekTSM() (t int64, v float64) {
 // next returns the next key/value for the cursor.
 func (c *floatDescendingCursor) next() (int64, interface{}) { return c.nextFloat() }
 
+// blockN returns the number of blocks decoded from TSM files.
+func (c *floatDescendingCursor) blockN() int {
+	if c.tsm.keyCursor == nil {
+		return 0
+	}
+	return c.tsm.keyCursor.decodedN
+}
+
 // nextFloat returns the next key/value for the cursor.
 func (c *floatDescendingCursor) nextFloat() (int64, float64) {
+	c.readTSM()
 	ckey, cvalue := c.peekCache()
 	tkey, tvalue := c.peekTSM()
 
@@ -489,14 +549,20 @@ func (c *floatDescendingCursor) nextCache() {
 // nextTSM returns the next value from the TSM files.
 func (c *floatDescendingCursor) nextTSM() {
 	c.tsm.pos--
-	if c.tsm.pos < 0 {
-		c.tsm.keyCursor.Next()
-		c.tsm.values, _ = c.tsm.keyCursor.ReadFloatBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
-		if len(c.tsm.values) == 0 {
-			return
-		}
-		c.tsm.pos = len(c.tsm.values) - 1
+	c.tsm.pending = c.tsm.pos < 0
+}
+
+// readTSM reads the next block from the TSM files once the current block has
+// been read.  It is deferred until another value is needed so a cursor that
+// is no longer read does not decode another block.
+func (c *floatDescendingCursor) readTSM() {
+	if !c.tsm.pending {
+		return
 	}
+	c.tsm.pending = false
+	c.tsm.keyCursor.Next()
+	c.tsm.values, _ = c.tsm.keyCursor.ReadFloatBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
+	c.tsm.pos = len(c.tsm.values) - 1
 }
 
 // floatLiteralCursor represents a cursor that always returns a single value.
@@ -624,11 +690,25 @@ func (itr *integerIterator) Next() (*influxql.IntegerPoint, error) {
 
 // copyStats copies from the itr stats buffer to the stats under lock.
 func (itr *integerIterator) copyStats() {
+	itr.statsBuf.BlockN = itr.blockN()
+
 	itr.statsLock.Lock()
 	itr.stats = itr.statsBuf
 	itr.statsLock.Unlock()
 }
 
+// blockN returns the number of blocks decoded by all cursors of the iterator.
+func (itr *integerIterator) blockN() int {
+	n := cursorBlockN(itr.cur)
+	for _, c := range itr.aux {
+		n += cursorBlockN(c)
+	}
+	for _, c := range itr.conds.curs {
+		n += cursorBlockN(c)
+	}
+	return n
+}
+
 // Stats returns stats on the points processed.
 func (itr *integerIterator) Stats() influxql.IteratorStats {
 	itr.statsLock.Lock()
@@ -637,8 +717,12 @@ func (itr *integerIterator) Stats() influxql.IteratorStats {
 	return stats
 }
 
-// Close closes the iterator.
-func (itr *integerIterator) Close() error { return nil }
+// Close closes the iterator.  The stats of an iterator that stopped before
+// the end of its cursors, such as one cut short by a limit, are copied too.
+func (itr *integerIterator) Close() error {
+	itr.copyStats()
+	return nil
+}
 
 // integerLimitIterator
 type integerLimitIterator struct {
@@ -659,8 +743,8 @@ func (itr *integerLimitIterator) Close() error                  { return itr.inp
 
 func (itr *integerLimitIterator) Next() (*influxql.IntegerPoint, error) {
 	for {
-		// Check if we are beyond the limit.
-		if (itr.n - itr.opt.Offset) > itr.opt.Limit {
+		// Stop reading once the limit has been reached.
+		if (itr.n - itr.opt.Offset) >= itr.opt.Limit {
 			return nil, nil
 		}
 
@@ -704,6 +788,7 @@ type integerAscendingCursor struct {
 		values    []IntegerValue
 		pos       int
 		keyCursor *KeyCursor
+		pending   bool // next block is read when its values are needed
 	}
 }
 
@@ -748,8 +833,17 @@ func (c *integerAscendingCursor) peekTSM() (t int64, v int64) {
 // next returns the next key/value for the cursor.
 func (c *integerAscendingCursor) next() (int64, interface{}) { return c.nextInteger() }
 
+// blockN returns the number of blocks decoded from TSM files.
+func (c *integerAscendingCursor) blockN() int {
+	if c.tsm.keyCursor == nil {
+		return 0
+	}
+	return c.tsm.keyCursor.decodedN
+}
+
 // nextInteger returns the next key/value for the cursor.
 func (c *integerAscendingCursor) nextInteger() (int64, int64) {
+	c.readTSM()
 	ckey, cvalue := c.peekCache()
 	tkey, tvalue := c.peekTSM()
 
@@ -787,14 +881,20 @@ func (c *integerAscendingCursor) nextCache() {
 // nextTSM returns the next value from the TSM files.
 func (c *integerAscendingCursor) nextTSM() {
 	c.tsm.pos++
-	if c.tsm.pos >= len(c.tsm.values) {
-		c.tsm.keyCursor.Next()
-		c.tsm.values, _ = c.tsm.keyCursor.ReadIntegerBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
-		if len(c.tsm.values) == 0 {
-			return
-		}
-		c.tsm.pos = 0
+	c.tsm.pending = c.tsm.pos >= len(c.tsm.values)
+}
+
+// readTSM reads the next block from the TSM files once the current block has
+// been read.  It is deferred until another value is needed so a cursor that
+// is no longer read does not decode another block.
+func (c *integerAscendingCursor) readTSM() {
+	if !c.tsm.pending {
+		return
 	}
+	c.tsm.pending = false
+	c.tsm.keyCursor.Next()
+	c.tsm.values, _ = c.tsm.keyCursor.ReadIntegerBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
+	c.tsm.pos = 0
 }
 
 type integerDescendingCursor struct {
@@ -810,6 +910,7 @@ type integerDescendingCursor struct {
 		values    []IntegerValue
 		pos       int
 		keyCursor *KeyCursor
+		pending   bool // next block is read when its values are needed
 	}
 }
 
@@ -860,8 +961,17 @@ func (c *integerDescendingCursor) peekTSM() (t int64, v int64) {
 // next returns the next key/value for the cursor.
 func (c *integerDescendingCursor) next() (int64, interface{}) { return c.nextInteger() }
 
+// blockN returns the number of blocks decoded from TSM files.
+func (c *integerDescendingCursor) blockN() int {
+	if c.tsm.keyCursor == nil {
+		return 0
+	}
+	return c.tsm.keyCursor.decodedN
+}
+
 // nextInteger returns the next key/value for the cursor.
 func (c *integerDescendingCursor) nextInteger() (int64, int64) {
+	c.readTSM()
 	ckey, cvalue := c.peekCache()
 	tkey, tvalue := c.peekTSM()
 
@@ -899,14 +1009,20 @@ func (c *integerDescendingCursor) nextCache() {
 // nextTSM returns the next value from the TSM files.
 func (c *integerDescendingCursor) nextTSM() {
 	c.tsm.pos--
-	if c.tsm.pos < 0 {
-		c.tsm.keyCursor.Next()
-		c.tsm.values, _ = c.tsm.keyCursor.ReadIntegerBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
-		if len(c.tsm.values) == 0 {
-			return
-		}
-		c.tsm.pos = len(c.tsm.values) - 1
+	c.tsm.pending = c.tsm.pos < 0
+}
+
+// readTSM reads the next block from the TSM files once the current block has
+// been read.  It is deferred until another value is needed so a cursor that
+// is no longer read does not decode another block.
+func (c *integerDescendingCursor) readTSM() {
+	if !c.tsm.pending {
+		return
 	}
+	c.tsm.pending = false
+	c.tsm.keyCursor.Next()
+	c.tsm.values, _ = c.tsm.keyCursor.ReadIntegerBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
+	c.tsm.pos = len(c.tsm.values) - 1
 }
 
 // integerLiteralCursor represents a cursor that always returns a single value.
@@ -1034,11 +1150,25 @@ func (itr *stringIterator) Next() (*influxql.StringPoint, error) {
 
 // copyStats copies from the itr stats buffer to the stats under lock.
 func (itr *stringIterator) copyStats() {
+	itr.statsBuf.BlockN = itr.blockN()
+
 	itr.statsLock.Lock()
 	itr.stats = itr.statsBuf
 	itr.statsLock.Unlock()
 }
 
+// blockN returns the number of blocks decoded by all cursors of the iterator.
+func (itr *stringIterator) blockN() int {
+	n := cursorBlockN(itr.cur)
+	for _, c := range itr.aux {
+		n += cursorBlockN(c)
+	}
+	for _, c := range itr.conds.curs {
+		n += cursorBlockN(c)
+	}
+	return n
+}
+
 // Stats returns stats on the points processed.
 func (itr *stringIterator) Stats() influxql.IteratorStats {
 	itr.statsLock.Lock()
@@ -1047,8 +1177,12 @@ func (itr *stringIterator) Stats() influxql.IteratorStats {
 	return stats
 }
 
-// Close closes the iterator.
-func (itr *stringIterator) Close() error { return nil }
+// Close closes the iterator.  The stats of an iterator that stopped before
+// the end of its cursors, such as one cut short by a limit, are copied too.
+func (itr *stringIterator) Close() error {
+	itr.copyStats()
+	return nil
+}
 
 // stringLimitIterator
 type stringLimitIterator struct {
@@ -1069,8 +1203,8 @@ func (itr *stringLimitIterator) Close() error                  { return itr.inpu
 
 func (itr *stringLimitIterator) Next() (*influxql.StringPoint, error) {
 	for {
-		// Check if we are beyond the limit.
-		if (itr.n - itr.opt.Offset) > itr.opt.Limit {
+		// Stop reading once the limit has been reached.
+		if (itr.n - itr.opt.Offset) >= itr.opt.Limit {
 			return nil, nil
 		}
 
@@ -1114,6 +1248,7 @@ type stringAscendingCursor struct {
 		values    []StringValue
 		pos       int
 		keyCursor *KeyCursor
+		pending   bool // next block is read when its values are needed
 	}
 }
 
@@ -1158,8 +1293,17 @@ func (c *stringAscendingCursor) peekTSM() (t int64, v string) {
 // next returns the next key/value for the cursor.
 func (c *stringAscendingCursor) next() (int64, interface{}) { return c.nextString() }
 
+// blockN returns the number of blocks decoded from TSM files.
+func (c *stringAscendingCursor) blockN() int {
+	if c.tsm.keyCursor == nil {
+		return 0
+	}
+	return c.tsm.keyCursor.decodedN
+}
+
 // nextString returns the next key/value for the cursor.
 func (c *stringAscendingCursor) nextString() (int64, string) {
+	c.readTSM()
 	ckey, cvalue := c.peekCache()
 	tkey, tvalue := c.peekTSM()
 
@@ -1197,14 +1341,20 @@ func (c *stringAscendingCursor) nextCache() {
 // nextTSM returns the next value from the TSM files.
 func (c *stringAscendingCursor) nextTSM() {
 	c.tsm.pos++
-	if c.tsm.pos >= len(c.tsm.values) {
-		c.tsm.keyCursor.Next()
-		c.tsm.values, _ = c.tsm.keyCursor.ReadStringBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
-		if len(c.tsm.values) == 0 {
-			return
-		}
-		c.tsm.pos = 0
+	c.tsm.pending = c.tsm.pos >= len(c.tsm.values)
+}
+
+// readTSM reads the next block from the TSM files once the current block has
+// been read.  It is deferred until another value is needed so a cursor that
+// is no longer read does not decode another block.
+func (c *stringAscendingCursor) readTSM() {
+	if !c.tsm.pending {
+		return
 	}
+	c.tsm.pending = false
+	c.tsm.keyCursor.Next()
+	c.tsm.values, _ = c.tsm.keyCursor.ReadStringBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
+	c.tsm.pos = 0
 }
 
 type stringDescendingCursor struct {
@@ -1220,6 +1370,7 @@ type stringDescendingCursor struct {
 		values    []StringValue
 		pos       int
 		keyCursor *KeyCursor
+		pending   bool // next block is read when its values are needed
 	}
 }
 
@@ -1270,8 +1421,17 @@ func (c *stringDescendingCursor) peekTSM() (t int64, v string) {
 // next returns the next key/value for the cursor.
 func (c *stringDescendingCursor) next() (int64, interface{}) { return c.nextString() }
 
+// blockN returns the number of blocks decoded from TSM files.
+func (c *stringDescendingCursor) blockN() int {
+	if c.tsm.keyCursor == nil {
+		return 0
+	}
+	return c.tsm.keyCursor.decodedN
+}
+
 // nextString returns the next key/value for the cursor.
 func (c *stringDescendingCursor) nextString() (int64, string) {
+	c.readTSM()
 	ckey, cvalue := c.peekCache()
 	tkey, tvalue := c.peekTSM()
 
@@ -1309,14 +1469,20 @@ func (c *stringDescendingCursor) nextCache() {
 // nextTSM returns the next value from the TSM files.
 func (c *stringDescendingCursor) nextTSM() {
 	c.tsm.pos--
-	if c.tsm.pos < 0 {
-		c.tsm.keyCursor.Next()
-		c.tsm.values, _ = c.tsm.keyCursor.ReadStringBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
-		if len(c.tsm.values) == 0 {
-			return
-		}
-		c.tsm.pos = len(c.tsm.values) - 1
+	c.tsm.pending = c.tsm.pos < 0
+}
+
+// readTSM reads the next block from the TSM files once the current block has
+// been read.  It is deferred until another value is needed so a cursor that
+// is no longer read does not decode another block.
+func (c *stringDescendingCursor) readTSM() {
+	if !c.tsm.pending {
+		return
 	}
+	c.tsm.pending = false
+	c.tsm.keyCursor.Next()
+	c.tsm.values, _ = c.tsm.keyCursor.ReadStringBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
+	c.tsm.pos = len(c.tsm.values) - 1
 }
 
 // stringLiteralCursor represents a cursor that always returns a single value.
@@ -1444,11 +1610,25 @@ func (itr *booleanIterator) Next() (*influxql.BooleanPoint, error) {
 
 // copyStats copies from the itr stats buffer to the stats under lock.
 func (itr *booleanIterator) copyStats() {
+	itr.statsBuf.BlockN = itr.blockN()
+
 	itr.statsLock.Lock()
 	itr.stats = itr.statsBuf
 	itr.statsLock.Unlock()
 }
 
+// blockN returns the number of blocks decoded by all cursors of the iterator.
+func (itr *booleanIterator) blockN() int {
+	n := cursorBlockN(itr.cur)
+	for _, c := range itr.aux {
+		n += cursorBlockN(c)
+	}
+	for _, c := range itr.conds.curs {
+		n += cursorBlockN(c)
+	}
+	return n
+}
+
 // Stats returns stats on the points processed.
 func (itr *booleanIterator) Stats() influxql.IteratorStats {
 	itr.statsLock.Lock()
@@ -1457,8 +1637,12 @@ func (itr *booleanIterator) Stats() influxql.IteratorStats {
 	return stats
 }
 
-// Close closes the iterator.
-func (itr *booleanIterator) Close() error { return nil }
+// Close closes the iterator.  The stats of an iterator that stopped before
+// the end of its cursors, such as one cut short by a limit, are copied too.
+func (itr *booleanIterator) Close() error {
+	itr.copyStats()
+	return nil
+}
 
 // booleanLimitIterator
 type booleanLimitIterator struct {
@@ -1479,8 +1663,8 @@ func (itr *booleanLimitIterator) Close() error                  { return itr.inp
 
 func (itr *booleanLimitIterator) Next() (*influxql.BooleanPoint, error) {
 	for {
-		// Check if we are beyond the limit.
-		if (itr.n - itr.opt.Offset) > itr.opt.Limit {
+		// Stop reading once the limit has been reached.
+		if (itr.n - itr.opt.Offset) >= itr.opt.Limit {
 			return nil, nil
 		}
 
@@ -1524,6 +1708,7 @@ type booleanAscendingCursor struct {
 		values    []BooleanValue
 		pos       int
 		keyCursor *KeyCursor
+		pending   bool // next block is read when its values are needed
 	}
 }
 
@@ -1568,8 +1753,17 @@ func (c *booleanAscendingCursor) peekTSM() (t int64, v bool) {
 // next returns the next key/value for the cursor.
 func (c *booleanAscendingCursor) next() (int64, interface{}) { return c.nextBoolean() }
 
+// blockN returns the number of blocks decoded from TSM files.
+func (c *booleanAscendingCursor) blockN() int {
+	if c.tsm.keyCursor == nil {
+		return 0
+	}
+	return c.tsm.keyCursor.decodedN
+}
+
 // nextBoolean returns the next key/value for the cursor.
 func (c *booleanAscendingCursor) nextBoolean() (int64, bool) {
+	c.readTSM()
 	ckey, cvalue := c.peekCache()
 	tkey, tvalue := c.peekTSM()
 
@@ -1607,14 +1801,20 @@ func (c *booleanAscendingCursor) nextCache() {
 // nextTSM returns the next value from the TSM files.
 func (c *booleanAscendingCursor) nextTSM() {
 	c.tsm.pos++
-	if c.tsm.pos >= len(c.tsm.values) {
-		c.tsm.keyCursor.Next()
-		c.tsm.values, _ = c.tsm.keyCursor.ReadBooleanBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
-		if len(c.tsm.values) == 0 {
-			return
-		}
-		c.tsm.pos = 0
+	c.tsm.pending = c.tsm.pos >= len(c.tsm.values)
+}
+
+// readTSM reads the next block from the TSM files once the current block has
+// been read.  It is deferred until another value is needed so a cursor that
+// is no longer read does not decode another block.
+func (c *booleanAscendingCursor) readTSM() {
+	if !c.tsm.pending {
+		return
 	}
+	c.tsm.pending = false
+	c.tsm.keyCursor.Next()
+	c.tsm.values, _ = c.tsm.keyCursor.ReadBooleanBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
+	c.tsm.pos = 0
 }
 
 type booleanDescendingCursor struct {
@@ -1630,6 +1830,7 @@ type booleanDescendingCursor struct {
 		values    []BooleanValue
 		pos       int
 		keyCursor *KeyCursor
+		pending   bool // next block is read when its values are needed
 	}
 }
 
@@ -1680,8 +1881,17 @@ func (c *booleanDescendingCursor) peekTSM() (t int64, v bool) {
 // next returns the next key/value for the cursor.
 func (c *booleanDescendingCursor) next() (int64, interface{}) { return c.nextBoolean() }
 
+// blockN returns the number of blocks decoded from TSM files.
+func (c *booleanDescendingCursor) blockN() int {
+	if c.tsm.keyCursor == nil {
+		return 0
+	}
+	return c.tsm.keyCursor.decodedN
+}
+
 // nextBoolean returns the next key/value for the cursor.
 func (c *booleanDescendingCursor) nextBoolean() (int64, bool) {
+	c.readTSM()
 	ckey, cvalue := c.peekCache()
 	tkey, tvalue := c.peekTSM()
 
@@ -1719,14 +1929,20 @@ func (c *booleanDescendingCursor) nextCache() {
 // nextTSM returns the next value from the TSM files.
 func (c *booleanDescendingCursor) nextTSM() {
 	c.tsm.pos--
-	if c.tsm.pos < 0 {
-		c.tsm.keyCursor.Next()
-		c.tsm.values, _ = c.tsm.keyCursor.ReadBooleanBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
-		if len(c.tsm.values) == 0 {
-			return
-		}
-		c.tsm.pos = len(c.tsm.values) - 1
+	c.tsm.pending = c.tsm.pos < 0
+}
+
+// readTSM reads the next block from the TSM files once the current block has
+// been read.  It is deferred until another value is needed so a cursor that
+// is no longer read does not decode another block.
+func (c *booleanDescendingCursor) readTSM() {
+	if !c.tsm.pending {
+		return
 	}
+	c.tsm.pending = false
+	c.tsm.keyCursor.Next()
+	c.tsm.values, _ = c.tsm.keyCursor.ReadBooleanBlock(&c.tsm.tdec, &c.tsm.vdec, &c.tsm.buf)
+	c.tsm.pos = len(c.tsm.values) - 1
 }
 
 // booleanLiteralCursor represents a cursor that always returns a single value.
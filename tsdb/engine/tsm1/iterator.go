@@ -2,6 +2,7 @@ package tsm1
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/influxdata/influxdb/influxql"
 )
@@ -20,3 +21,125 @@ func newLimitIterator(input influxql.Iterator, opt influxql.IteratorOptions) inf
 		panic(fmt.Sprintf("unsupported limit iterator type: %T", input))
 	}
 }
+
+// newSummaryIterator returns an iterator with a partial aggregate of call for
+// each block summary.  The points are combined with the aggregates of the raw
+// points for the series by applying the call again.
+func newSummaryIterator(name string, tags influxql.Tags, call string, typ influxql.DataType, summaries []BlockSummary) influxql.Iterator {
+	switch {
+	case call == "count":
+		points := make([]influxql.IntegerPoint, len(summaries))
+		for i, s := range summaries {
+			points[i] = influxql.IntegerPoint{Name: name, Tags: tags, Time: s.MinTime, Value: int64(s.Count)}
+		}
+		return newIntegerSummaryIterator(points)
+	case call == "mean":
+		points := make([]influxql.FloatPoint, len(summaries))
+		for i, s := range summaries {
+			sum := s.Sum
+			if typ == influxql.Integer {
+				sum = float64(s.IntegerSum)
+			}
+			points[i] = influxql.FloatPoint{Name: name, Tags: tags, Time: s.MinTime, Value: sum / float64(s.Count), Aggregated: s.Count}
+		}
+		return newFloatSummaryIterator(points)
+	case typ == influxql.Integer:
+		points := make([]influxql.IntegerPoint, len(summaries))
+		for i, s := range summaries {
+			p := influxql.IntegerPoint{Name: name, Tags: tags, Time: s.MinTime, Value: s.IntegerSum}
+			switch call {
+			case "min":
+				p.Value = s.IntegerMin
+			case "max":
+				p.Time, p.Value = s.MaxTime, s.IntegerMax
+			case "first":
+				p.Time, p.Value = s.FirstTime, s.IntegerFirst
+			case "last":
+				p.Time, p.Value = s.LastTime, s.IntegerLast
+			}
+			points[i] = p
+		}
+		return newIntegerSummaryIterator(points)
+	default:
+		points := make([]influxql.FloatPoint, len(summaries))
+		for i, s := range summaries {
+			p := influxql.FloatPoint{Name: name, Tags: tags, Time: s.MinTime, Value: s.Sum}
+			switch call {
+			case "min":
+				p.Value = s.Min
+			case "max":
+				p.Time, p.Value = s.MaxTime, s.Max
+			case "first":
+				p.Time, p.Value = s.FirstTime, s.First
+			case "last":
+				p.Time, p.Value = s.LastTime, s.Last
+			}
+			points[i] = p
+		}
+		return newFloatSummaryIterator(points)
+	}
+}
+
+// floatSummaryIterator emits points built from block summaries.
+type floatSummaryIterator struct {
+	points []influxql.FloatPoint
+	stats  influxql.IteratorStats
+}
+
+func newFloatSummaryIterator(points []influxql.FloatPoint) *floatSummaryIterator {
+	sort.Sort(floatPointsByTime(points))
+	return &floatSummaryIterator{
+		points: points,
+		stats:  influxql.IteratorStats{PointN: len(points)},
+	}
+}
+
+func (itr *floatSummaryIterator) Next() (*influxql.FloatPoint, error) {
+	if len(itr.points) == 0 {
+		return nil, nil
+	}
+	p := &itr.points[0]
+	itr.points = itr.points[1:]
+	return p, nil
+}
+
+func (itr *floatSummaryIterator) Stats() influxql.IteratorStats { return itr.stats }
+func (itr *floatSummaryIterator) Close() error                  { return nil }
+
+type floatPointsByTime []influxql.FloatPoint
+
+func (a floatPointsByTime) Len() int           { return len(a) }
+func (a floatPointsByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a floatPointsByTime) Less(i, j int) bool { return a[i].Time < a[j].Time }
+
+// integerSummaryIterator emits points built from block summaries.
+type integerSummaryIterator struct {
+	points []influxql.IntegerPoint
+	stats  influxql.IteratorStats
+}
+
+func newIntegerSummaryIterator(points []influxql.IntegerPoint) *integerSummaryIterator {
+	sort.Sort(integerPointsByTime(points))
+	return &integerSummaryIterator{
+		points: points,
+		stats:  influxql.IteratorStats{PointN: len(points)},
+	}
+}
+
+func (itr *integerSummaryIterator) Next() (*influxql.IntegerPoint, error) {
+	if len(itr.points) == 0 {
+		return nil, nil
+	}
+	p := &itr.points[0]
+	itr.points = itr.points[1:]
+	return p, nil
+}
+
+func (itr *integerSummaryIterator) Stats() influxql.IteratorStats { return itr.stats }
+func (itr *integerSummaryIterator) Close() error                  { return nil }
+
+type integerPointsByTime []influxql.IntegerPoint
+
+func (a integerPointsByTime) Len() int           { return len(a) }
+func (a integerPointsByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a integerPointsByTime) Less(i, j int) bool { return a[i].Time < a[j].Time }
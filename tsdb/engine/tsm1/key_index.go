@@ -0,0 +1,272 @@
+package tsm1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The key index is a file in the shard directory recording the series and
+// fields found in the TSM files of the shard, so the in-memory index can be
+// loaded at open without walking and parsing every key of every TSM file.
+//
+// The file has the following layout:
+//
+//	┌────────┬─────────────┬────────────────────────────┬────────┐
+//	│ Magic  │ Fingerprint │           Series           │ CRC32  │
+//	│4 bytes │   8 bytes   │             N              │4 bytes │
+//	└────────┴─────────────┴────────────────────────────┴────────┘
+//
+// Each series is the uvarint length of the series key, the series key, the
+// uvarint number of fields and, for each field, the uvarint length of the field
+// name, the field name and the block type of the field.
+//
+// The fingerprint identifies the set of TSM and tombstone files the index was
+// built from.  If the files have changed since, the index is stale and is
+// rebuilt from the TSM files.
+const (
+	keyIndexFileName = "keys.idx"
+
+	keyIndexMagic uint32 = 0x16D11DF1
+)
+
+// errKeyIndexCorrupt is returned when a key index file cannot be decoded.
+var errKeyIndexCorrupt = fmt.Errorf("key index corrupt")
+
+// seriesFields maps each series key to its field names and block types.
+type seriesFields map[string]map[string]byte
+
+// add records the field and block type of a composite series and field key.
+func (s seriesFields) add(key string, typ byte) {
+	seriesKey, field := seriesAndFieldFromCompositeKey(key)
+	fields := s[seriesKey]
+	if fields == nil {
+		fields = make(map[string]byte)
+		s[seriesKey] = fields
+	}
+	fields[field] = typ
+}
+
+// keys returns the sorted series keys.
+func (s seriesFields) keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fingerprint returns a hash of the names, sizes and modification times of
+// the TSM and tombstone files.  It changes whenever the keys in the files may
+// have changed.
+func (f *FileStore) fingerprint() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	h := fnv.New64a()
+	for _, file := range f.files {
+		st := file.Stats()
+		fmt.Fprintf(h, "%s %d %d\n", filepath.Base(st.Path), st.Size, st.LastModified)
+		for _, t := range file.TombstoneFiles() {
+			fmt.Fprintf(h, "%s %d %d\n", filepath.Base(t.Path), t.Size, t.LastModified)
+		}
+	}
+	return h.Sum64()
+}
+
+// seriesFields returns the series and fields of all keys in the TSM files.
+func (f *FileStore) seriesFields() (seriesFields, error) {
+	s := make(seriesFields)
+	if err := f.WalkKeys(func(key string, typ byte) error {
+		s.add(key, typ)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeKeyIndex writes s to the key index file at path, tagged with the
+// fingerprint of the files it was built from.
+func writeKeyIndex(path string, fingerprint uint64, s seriesFields) error {
+	var buf bytes.Buffer
+	var scratch [binary.MaxVarintLen64]byte
+	writeString := func(v string) {
+		n := binary.PutUvarint(scratch[:], uint64(len(v)))
+		buf.Write(scratch[:n])
+		buf.WriteString(v)
+	}
+
+	binary.BigEndian.PutUint32(scratch[:4], keyIndexMagic)
+	buf.Write(scratch[:4])
+	binary.BigEndian.PutUint64(scratch[:8], fingerprint)
+	buf.Write(scratch[:8])
+
+	for _, k := range s.keys() {
+		writeString(k)
+
+		fields := s[k]
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		n := binary.PutUvarint(scratch[:], uint64(len(names)))
+		buf.Write(scratch[:n])
+		for _, name := range names {
+			writeString(name)
+			buf.WriteByte(fields[name])
+		}
+	}
+
+	binary.BigEndian.PutUint32(scratch[:4], crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(scratch[:4])
+
+	tmp := path + "." + CompactionTempExtension
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := renameFile(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// readKeyIndex reads the key index file at path.  It returns false if the
+// file does not exist or was not written for the files with fingerprint.
+func readKeyIndex(path string, fingerprint uint64) (seriesFields, bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	if len(b) < 16 || binary.BigEndian.Uint32(b[:4]) != keyIndexMagic {
+		return nil, false, errKeyIndexCorrupt
+	}
+
+	body, sum := b[:len(b)-4], binary.BigEndian.Uint32(b[len(b)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, false, errKeyIndexCorrupt
+	}
+
+	if binary.BigEndian.Uint64(body[4:12]) != fingerprint {
+		return nil, false, nil
+	}
+	body = body[12:]
+
+	readUvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(body)
+		if n <= 0 {
+			return 0, errKeyIndexCorrupt
+		}
+		body = body[n:]
+		return v, nil
+	}
+	readString := func() (string, error) {
+		n, err := readUvarint()
+		if err != nil {
+			return "", err
+		}
+		if uint64(len(body)) < n {
+			return "", errKeyIndexCorrupt
+		}
+		v := string(body[:n])
+		body = body[n:]
+		return v, nil
+	}
+
+	s := make(seriesFields)
+	for len(body) > 0 {
+		key, err := readString()
+		if err != nil {
+			return nil, false, err
+		}
+
+		n, err := readUvarint()
+		if err != nil {
+			return nil, false, err
+		}
+
+		fields := make(map[string]byte, n)
+		for i := uint64(0); i < n; i++ {
+			name, err := readString()
+			if err != nil {
+				return nil, false, err
+			}
+			if len(body) == 0 {
+				return nil, false, errKeyIndexCorrupt
+			}
+			fields[name] = body[0]
+			body = body[1:]
+		}
+		s[key] = fields
+	}
+
+	return s, true, nil
+}
+
+// loadKeyIndex returns the series and fields of the TSM files from the key
+// index file of the engine.  It returns nil if the file is missing or stale.
+func (e *Engine) loadKeyIndex(fingerprint uint64) (seriesFields, error) {
+	if !e.keyIndexEnabled() {
+		return nil, nil
+	}
+
+	s, ok, err := readKeyIndex(filepath.Join(e.path, keyIndexFileName), fingerprint)
+	if err != nil || !ok {
+		return nil, err
+	}
+	e.keyIndexFingerprint = fingerprint
+	return s, nil
+}
+
+// saveKeyIndex writes the series and fields of the TSM files with fingerprint
+// to the key index file of the engine.
+func (e *Engine) saveKeyIndex(fingerprint uint64, s seriesFields) error {
+	if !e.keyIndexEnabled() {
+		return nil
+	}
+
+	if err := writeKeyIndex(filepath.Join(e.path, keyIndexFileName), fingerprint, s); err != nil {
+		return err
+	}
+	e.keyIndexFingerprint = fingerprint
+	return nil
+}
+
+// keyIndexEnabled returns true if the key index file is used.  The file is not
+// encrypted, so it is not kept for shards with encrypted TSM files.
+func (e *Engine) keyIndexEnabled() bool {
+	return e.FileStore.ReaderOptions.Cipher == nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"syscall"
 
+	"github.com/influxdata/influxdb/tsdb"
 	"golang.org/x/sys/unix"
 )
 
@@ -30,3 +31,37 @@ func munmap(b []byte) (err error) {
 func madvise(b []byte, advice int) (err error) {
 	return unix.Madvise(b, advice)
 }
+
+// adviseMmap applies one of the tsdb.MmapAdvice* values to b.
+func adviseMmap(b []byte, advice string) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	switch advice {
+	case tsdb.MmapAdviceRandom:
+		return madvise(b, unix.MADV_RANDOM)
+	case tsdb.MmapAdviceSequential:
+		return madvise(b, unix.MADV_SEQUENTIAL)
+	case tsdb.MmapAdviceWillNeed:
+		return madvise(b, unix.MADV_WILLNEED)
+	}
+	return nil
+}
+
+// releaseMmap releases the pages of b from memory.  They are read back from
+// the file when next accessed.
+func releaseMmap(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return madvise(b, unix.MADV_DONTNEED)
+}
+
+// lockMmap locks the pages of b in memory.
+func lockMmap(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return unix.Mlock(b)
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"syscall"
 	"unsafe"
+
+	"github.com/influxdata/influxdb/tsdb"
 )
 
 func mmap(f *os.File, offset int64, length int) ([]byte, error) {
@@ -29,3 +31,37 @@ func madvise(b []byte, advice int) (err error) {
 	}
 	return
 }
+
+// adviseMmap applies one of the tsdb.MmapAdvice* values to b.
+func adviseMmap(b []byte, advice string) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	switch advice {
+	case tsdb.MmapAdviceRandom:
+		return madvise(b, syscall.MADV_RANDOM)
+	case tsdb.MmapAdviceSequential:
+		return madvise(b, syscall.MADV_SEQUENTIAL)
+	case tsdb.MmapAdviceWillNeed:
+		return madvise(b, syscall.MADV_WILLNEED)
+	}
+	return nil
+}
+
+// releaseMmap releases the pages of b from memory.  They are read back from
+// the file when next accessed.
+func releaseMmap(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return madvise(b, syscall.MADV_DONTNEED)
+}
+
+// lockMmap locks the pages of b in memory.
+func lockMmap(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return syscall.Mlock(b)
+}
@@ -115,3 +115,18 @@ func munmap(b []byte) (err error) {
 	}
 	return nil
 }
+
+// adviseMmap is a no-op on Windows, which has no equivalent of madvise.
+func adviseMmap(b []byte, advice string) error {
+	return nil
+}
+
+// releaseMmap is a no-op on Windows.
+func releaseMmap(b []byte) error {
+	return nil
+}
+
+// lockMmap is a no-op on Windows.
+func lockMmap(b []byte) error {
+	return nil
+}
@@ -2,13 +2,18 @@ package tsm1
 
 import (
 	"bytes"
+	"crypto/cipher"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"log"
 	"math"
 	"os"
 	"sort"
 	"sync"
+
+	"github.com/influxdata/influxdb/pkg/bloom"
 )
 
 type TSMReader struct {
@@ -143,30 +148,76 @@ func (b *BlockIterator) Read() (string, int64, int64, uint32, []byte, error) {
 	if b.err != nil {
 		return "", 0, 0, 0, nil, b.err
 	}
-	checksum, buf, err := b.r.readBytes(&b.entries[0], nil)
+	checksum, buf, err := b.r.readBytes(b.key, &b.entries[0], nil)
 	if err != nil {
 		return "", 0, 0, 0, nil, err
 	}
 	return b.key, b.entries[0].MinTime, b.entries[0].MaxTime, checksum, buf, err
 }
 
+// summary returns the summary stored for the current block, or nil if the
+// file has none for it or it lacks the first and last values.
+func (b *BlockIterator) summary() *BlockSummary {
+	s, ok := b.r.BlockSummary(&b.entries[0])
+	if !ok || !s.HasEnds {
+		return nil
+	}
+	return &s
+}
+
 // blockAccessor abstracts a method of accessing blocks from a
 // TSM file.
 type blockAccessor interface {
 	init() (*indirectIndex, error)
 	read(key string, timestamp int64) ([]Value, error)
 	readAll(key string) ([]Value, error)
-	readBlock(entry *IndexEntry, values []Value) ([]Value, error)
-	readFloatBlock(entry *IndexEntry, tdec *TimeDecoder, fdec *FloatDecoder, values *[]FloatValue) ([]FloatValue, error)
-	readIntegerBlock(entry *IndexEntry, tdec *TimeDecoder, vdec *IntegerDecoder, values *[]IntegerValue) ([]IntegerValue, error)
-	readStringBlock(entry *IndexEntry, tdec *TimeDecoder, vdec *StringDecoder, values *[]StringValue) ([]StringValue, error)
-	readBooleanBlock(entry *IndexEntry, tdec *TimeDecoder, vdec *BooleanDecoder, values *[]BooleanValue) ([]BooleanValue, error)
-	readBytes(entry *IndexEntry, buf []byte) (uint32, []byte, error)
+	readBlock(key string, entry *IndexEntry, values []Value) ([]Value, error)
+	readFloatBlock(key string, entry *IndexEntry, tdec *TimeDecoder, fdec *FloatDecoder, values *[]FloatValue) ([]FloatValue, error)
+	readIntegerBlock(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *IntegerDecoder, values *[]IntegerValue) ([]IntegerValue, error)
+	readStringBlock(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *StringDecoder, values *[]StringValue) ([]StringValue, error)
+	readBooleanBlock(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *BooleanDecoder, values *[]BooleanValue) ([]BooleanValue, error)
+	readBytes(key string, entry *IndexEntry, buf []byte) (uint32, []byte, error)
+	blockSummary(entry *IndexEntry) (BlockSummary, bool)
 	path() string
 	close() error
 }
 
+// TSMReaderOptions controls how a TSMReader accesses its file.
+type TSMReaderOptions struct {
+	// MmapAdvice is one of the tsdb.MmapAdvice* values and is applied to the
+	// file's memory map.  Empty leaves the operating system's default.
+	MmapAdvice string
+
+	// DirectReads reads blocks with pread instead of through the memory map.
+	DirectReads bool
+
+	// Cipher decrypts encrypted blocks.  Reading an encrypted block without
+	// it returns ErrBlockEncrypted.
+	Cipher cipher.AEAD
+
+	// LazyIndex releases the pages of the index from memory once the file
+	// is opened so that they are only read back for the keys queried.
+	// IndexCacheSize is the number of decoded index entries kept in an LRU
+	// cache when LazyIndex is set.  Zero disables the cache.
+	LazyIndex      bool
+	IndexCacheSize int
+
+	// LockIndex locks the pages of the index in memory so that they are not
+	// evicted.  It is ignored when LazyIndex is set.  A file whose index
+	// cannot be locked is opened anyway and the failure is logged to Logger.
+	LockIndex bool
+
+	// Logger receives warnings about the file.  Nil discards them.
+	Logger *log.Logger
+}
+
 func NewTSMReader(f *os.File) (*TSMReader, error) {
+	return NewTSMReaderWithOptions(f, TSMReaderOptions{})
+}
+
+// NewTSMReaderWithOptions returns a reader for f that accesses the file as
+// described by opt.
+func NewTSMReaderWithOptions(f *os.File, opt TSMReaderOptions) (*TSMReader, error) {
 	t := &TSMReader{}
 
 	stat, err := f.Stat()
@@ -176,7 +227,15 @@ func NewTSMReader(f *os.File) (*TSMReader, error) {
 	t.size = stat.Size()
 	t.lastModified = stat.ModTime().UnixNano()
 	t.accessor = &mmapAccessor{
-		f: f,
+		f:           f,
+		advice:      opt.MmapAdvice,
+		directReads: opt.DirectReads,
+		cipher:      opt.Cipher,
+
+		lazyIndex:      opt.LazyIndex,
+		indexCacheSize: opt.IndexCacheSize,
+		lockIndex:      opt.LockIndex,
+		logger:         opt.Logger,
 	}
 
 	index, err := t.accessor.init()
@@ -240,35 +299,35 @@ func (t *TSMReader) KeyAt(idx int) (string, byte) {
 	return t.index.KeyAt(idx)
 }
 
-func (t *TSMReader) ReadAt(entry *IndexEntry, vals []Value) ([]Value, error) {
+func (t *TSMReader) ReadAt(key string, entry *IndexEntry, vals []Value) ([]Value, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	return t.accessor.readBlock(entry, vals)
+	return t.accessor.readBlock(key, entry, vals)
 }
 
-func (t *TSMReader) ReadFloatBlockAt(entry *IndexEntry, tdec *TimeDecoder, vdec *FloatDecoder, vals *[]FloatValue) ([]FloatValue, error) {
+func (t *TSMReader) ReadFloatBlockAt(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *FloatDecoder, vals *[]FloatValue) ([]FloatValue, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.accessor.readFloatBlock(entry, tdec, vdec, vals)
+	return t.accessor.readFloatBlock(key, entry, tdec, vdec, vals)
 }
 
-func (t *TSMReader) ReadIntegerBlockAt(entry *IndexEntry, tdec *TimeDecoder, vdec *IntegerDecoder, vals *[]IntegerValue) ([]IntegerValue, error) {
+func (t *TSMReader) ReadIntegerBlockAt(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *IntegerDecoder, vals *[]IntegerValue) ([]IntegerValue, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.accessor.readIntegerBlock(entry, tdec, vdec, vals)
+	return t.accessor.readIntegerBlock(key, entry, tdec, vdec, vals)
 }
 
-func (t *TSMReader) ReadStringBlockAt(entry *IndexEntry, tdec *TimeDecoder, vdec *StringDecoder, vals *[]StringValue) ([]StringValue, error) {
+func (t *TSMReader) ReadStringBlockAt(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *StringDecoder, vals *[]StringValue) ([]StringValue, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.accessor.readStringBlock(entry, tdec, vdec, vals)
+	return t.accessor.readStringBlock(key, entry, tdec, vdec, vals)
 }
 
-func (t *TSMReader) ReadBooleanBlockAt(entry *IndexEntry, tdec *TimeDecoder, vdec *BooleanDecoder, vals *[]BooleanValue) ([]BooleanValue, error) {
+func (t *TSMReader) ReadBooleanBlockAt(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *BooleanDecoder, vals *[]BooleanValue) ([]BooleanValue, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.accessor.readBooleanBlock(entry, tdec, vdec, vals)
+	return t.accessor.readBooleanBlock(key, entry, tdec, vdec, vals)
 }
 
 func (t *TSMReader) Read(key string, timestamp int64) ([]Value, error) {
@@ -286,10 +345,10 @@ func (t *TSMReader) ReadAll(key string) ([]Value, error) {
 	return t.accessor.readAll(key)
 }
 
-func (t *TSMReader) readBytes(e *IndexEntry, b []byte) (uint32, []byte, error) {
+func (t *TSMReader) readBytes(key string, e *IndexEntry, b []byte) (uint32, []byte, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.accessor.readBytes(e, b)
+	return t.accessor.readBytes(key, e, b)
 }
 
 func (t *TSMReader) Type(key string) (byte, error) {
@@ -330,8 +389,15 @@ func (t *TSMReader) ContainsValue(key string, ts int64) bool {
 	return t.index.ContainsValue(key, ts)
 }
 
-// DeleteRange removes the given points for keys between minTime and maxTime
+// DeleteRange records a tombstone for the values of keys between minTime and
+// maxTime.  Keys without values in the range are skipped so that files holding
+// none of the deleted data do not get a tombstone and need compacting.
 func (t *TSMReader) DeleteRange(keys []string, minTime, maxTime int64) error {
+	keys = t.keysInRange(keys, minTime, maxTime)
+	if len(keys) == 0 {
+		return nil
+	}
+
 	if err := t.tombstoner.AddRange(keys, minTime, maxTime); err != nil {
 		return err
 	}
@@ -341,6 +407,11 @@ func (t *TSMReader) DeleteRange(keys []string, minTime, maxTime int64) error {
 }
 
 func (t *TSMReader) Delete(keys []string) error {
+	keys = t.keysInRange(keys, math.MinInt64, math.MaxInt64)
+	if len(keys) == 0 {
+		return nil
+	}
+
 	if err := t.tombstoner.Add(keys); err != nil {
 		return err
 	}
@@ -349,6 +420,20 @@ func (t *TSMReader) Delete(keys []string) error {
 	return nil
 }
 
+// keysInRange returns the keys that have a block overlapping min and max.
+func (t *TSMReader) keysInRange(keys []string, min, max int64) []string {
+	var a []string
+	for _, k := range keys {
+		for _, e := range t.index.Entries(k) {
+			if e.OverlapsTimeRange(min, max) {
+				a = append(a, k)
+				break
+			}
+		}
+	}
+	return a
+}
+
 // TimeRange returns the min and max time across all keys in the file.
 func (t *TSMReader) TimeRange() (int64, int64) {
 	return t.index.TimeRange()
@@ -430,6 +515,71 @@ func (t *TSMReader) BlockIterator() *BlockIterator {
 	}
 }
 
+// BlockSummary returns the precomputed aggregates for the block identified by
+// entry.  It returns false if the file was written without summaries.
+func (t *TSMReader) BlockSummary(entry *IndexEntry) (BlockSummary, bool) {
+	return t.accessor.blockSummary(entry)
+}
+
+// BlockError describes a block that failed verification.
+type BlockError struct {
+	Path             string
+	Key              string
+	MinTime, MaxTime int64
+	Err              error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("%s: key %q, time range %d-%d: %v", e.Path, e.Key, e.MinTime, e.MaxTime, e.Err)
+}
+
+// Verify checks that every block in the file matches its checksum and can
+// be decoded.  It returns the first block that fails.
+func (t *TSMReader) Verify() error {
+	if errs := t.verifyBlocks(true); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// VerifyBlocks checks every block in the file and returns an error for each
+// block that does not match its checksum or cannot be decoded.
+func (t *TSMReader) VerifyBlocks() []*BlockError {
+	return t.verifyBlocks(false)
+}
+
+func (t *TSMReader) verifyBlocks(first bool) []*BlockError {
+	var errs []*BlockError
+	iter := t.BlockIterator()
+	for iter.Next() {
+		entry := iter.entries[0]
+		_, _, _, checksum, buf, err := iter.Read()
+		if err != nil {
+			err = fmt.Errorf("read block: %v", err)
+		} else if exp := crc32.ChecksumIEEE(buf); checksum != exp {
+			err = fmt.Errorf("checksum mismatch: got %d, exp %d", checksum, exp)
+		} else if _, derr := DecodeBlock(buf, nil); derr != nil {
+			err = fmt.Errorf("decode block: %v", derr)
+		}
+
+		if err == nil {
+			continue
+		}
+
+		errs = append(errs, &BlockError{
+			Path:    t.Path(),
+			Key:     iter.key,
+			MinTime: entry.MinTime,
+			MaxTime: entry.MaxTime,
+			Err:     err,
+		})
+		if first {
+			break
+		}
+	}
+	return errs
+}
+
 // indirectIndex is a TSMIndex that uses a raw byte slice representation of an index.  This
 // implementation can be used for indexes that may be MMAPed into memory.
 type indirectIndex struct {
@@ -487,6 +637,13 @@ type indirectIndex struct {
 	// entry would exist here if a subset of the points for a key were deleted and the file
 	// had not be re-compacted to remove the points on disk.
 	tombstones map[string][]TimeRange
+
+	// filter is the bloom filter of keys stored in the file, if any.  Keys
+	// it does not contain are not searched for.
+	filter *bloom.Filter
+
+	// cache holds the decoded entries of recently read keys, if set.
+	cache *indexEntryCache
 }
 
 type TimeRange struct {
@@ -502,6 +659,10 @@ func NewIndirectIndex() *indirectIndex {
 // search returns the index of i in offsets for where key is located.  If key is not
 // in the index, len(index) is returned.
 func (d *indirectIndex) search(key []byte) int {
+	if d.filter != nil && !d.filter.Contains(key) {
+		return len(d.b)
+	}
+
 	// We use a binary search across our indirect offsets (pointers to all the keys
 	// in the index slice).
 	i := sort.Search(len(d.offsets), func(i int) bool {
@@ -540,9 +701,37 @@ func (d *indirectIndex) search(key []byte) int {
 
 // Entries returns all index entries for a key.
 func (d *indirectIndex) Entries(key string) []IndexEntry {
+	var entries []IndexEntry
+	d.ReadEntries(key, &entries)
+	return entries
+}
+
+// ReadEntries reads all index entries for a key into entries.
+func (d *indirectIndex) ReadEntries(key string, entries *[]IndexEntry) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
+	if d.cache == nil {
+		*entries = d.entries(key)
+		return
+	}
+
+	// The cached slice is shared so callers are given a copy of it.
+	cached, ok := d.cache.get(key)
+	if !ok {
+		cached = d.entries(key)
+		if cached == nil {
+			*entries = nil
+			return
+		}
+		d.cache.add(key, cached)
+	}
+	*entries = append((*entries)[:0], cached...)
+}
+
+// entries decodes the index entries for a key.  It must be called with d.mu
+// held.
+func (d *indirectIndex) entries(key string) []IndexEntry {
 	kb := []byte(key)
 
 	ofs := d.search(kb)
@@ -572,11 +761,6 @@ func (d *indirectIndex) Entries(key string) []IndexEntry {
 	return nil
 }
 
-// ReadEntries returns all index entries for a key.
-func (d *indirectIndex) ReadEntries(key string, entries *[]IndexEntry) {
-	*entries = d.Entries(key)
-}
-
 // Entry returns the index entry for the specified key and timestamp.  If no entry
 // matches the key an timestamp, nil is returned.
 func (d *indirectIndex) Entry(key string, timestamp int64) *IndexEntry {
@@ -644,6 +828,10 @@ func (d *indirectIndex) Delete(keys []string) {
 		offsets = append(offsets, int32(offset))
 	}
 	d.offsets = offsets
+
+	if d.cache != nil {
+		d.cache.remove(keys)
+	}
 }
 
 func (d *indirectIndex) DeleteRange(keys []string, minTime, maxTime int64) {
@@ -775,7 +963,7 @@ func (d *indirectIndex) UnmarshalBinary(b []byte) error {
 	d.b = b
 
 	//var minKey, maxKey []byte
-	var minTime, maxTime int64 = math.MaxInt64, 0
+	var minTime, maxTime int64 = math.MaxInt64, math.MinInt64
 
 	// To create our "indirect" index, we need to find the location of all the keys in
 	// the raw byte slice.  The keys are listed once each (in sorted order).  Following
@@ -838,14 +1026,50 @@ func (d *indirectIndex) Size() uint32 {
 	return uint32(len(d.b))
 }
 
+// readBloomFilter returns the bloom filter section that ends at end in b, or
+// nil if the file was written without one or its checksum does not match.
+func readBloomFilter(b []byte, end int64) *bloom.Filter {
+	if end < 5+bloomTrailerSize || end > int64(len(b)) {
+		return nil
+	}
+
+	trailer := b[end-bloomTrailerSize : end]
+	if binary.BigEndian.Uint32(trailer[8:12]) != BloomMagicNumber {
+		return nil
+	}
+
+	size := int64(binary.BigEndian.Uint32(trailer[0:4]))
+	start := end - bloomTrailerSize - size
+	if size < 2 || start < 5 {
+		return nil
+	}
+
+	section := b[start : end-bloomTrailerSize]
+	if crc32.ChecksumIEEE(section) != binary.BigEndian.Uint32(trailer[4:8]) {
+		return nil
+	}
+	return bloom.NewFilterBuffer(section[1:], uint64(section[0]))
+}
+
 // mmapAccess is mmap based block accessor.  It access blocks through an
 // MMAP file interface.
 type mmapAccessor struct {
 	mu sync.RWMutex
 
-	f     *os.File
-	b     []byte
-	index *indirectIndex
+	f         *os.File
+	b         []byte
+	index     *indirectIndex
+	summaries blockSummaries
+
+	advice      string
+	directReads bool
+	cipher      cipher.AEAD
+
+	lazyIndex      bool
+	indexCacheSize int
+	lockIndex      bool
+
+	logger *log.Logger
 }
 
 func (m *mmapAccessor) init() (*indirectIndex, error) {
@@ -872,6 +1096,12 @@ func (m *mmapAccessor) init() (*indirectIndex, error) {
 		return nil, err
 	}
 
+	if err := adviseMmap(m.b, m.advice); err != nil {
+		munmap(m.b)
+		m.b = nil
+		return nil, fmt.Errorf("init: madvise: %v", err)
+	}
+
 	indexOfsPos := len(m.b) - 8
 	indexStart := binary.BigEndian.Uint64(m.b[indexOfsPos : indexOfsPos+8])
 
@@ -879,29 +1109,96 @@ func (m *mmapAccessor) init() (*indirectIndex, error) {
 	if err := m.index.UnmarshalBinary(m.b[indexStart:indexOfsPos]); err != nil {
 		return nil, err
 	}
+	var summariesStart int64
+	m.summaries, summariesStart = readBlockSummaries(m.b, int64(indexStart))
+	m.index.filter = readBloomFilter(m.b, summariesStart)
+
+	if m.lazyIndex {
+		if m.indexCacheSize > 0 {
+			m.index.cache = newIndexEntryCache(m.indexCacheSize)
+		}
+
+		// The index was read in full to find its keys.  Release the pages
+		// that lie wholly within it so they are faulted back in only for the
+		// keys that are queried.
+		pageSize := uint64(os.Getpagesize())
+		start := (indexStart + pageSize - 1) &^ (pageSize - 1)
+		if start < uint64(indexOfsPos) {
+			if err := releaseMmap(m.b[start:indexOfsPos]); err != nil {
+				munmap(m.b)
+				m.b = nil
+				return nil, fmt.Errorf("init: madvise: %v", err)
+			}
+		}
+	} else if m.lockIndex {
+		// The lock is released when the file is unmapped.  The file is still
+		// usable without it, such as when the memlock limit is reached.
+		if err := lockMmap(m.b[indexStart:indexOfsPos]); err != nil && m.logger != nil {
+			m.logger.Printf("Failed to lock index of %s in memory: %v", m.f.Name(), err)
+		}
+	}
 
 	return m.index, nil
 }
 
+func (m *mmapAccessor) blockSummary(entry *IndexEntry) (BlockSummary, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.b == nil {
+		return BlockSummary{}, false
+	}
+	return m.summaries.find(entry.Offset)
+}
+
+// block returns the bytes of the block of key at entry, including its
+// checksum, decrypting the block if it is encrypted.  It must be called with
+// m.mu held.
+func (m *mmapAccessor) block(key string, entry *IndexEntry) ([]byte, error) {
+	if int64(len(m.b)) < entry.Offset+int64(entry.Size) {
+		return nil, ErrTSMClosed
+	}
+
+	var b []byte
+	if m.directReads {
+		b = make([]byte, entry.Size)
+		if _, err := m.f.ReadAt(b, entry.Offset); err != nil {
+			return nil, err
+		}
+	} else {
+		b = m.b[entry.Offset : entry.Offset+int64(entry.Size)]
+	}
+
+	if len(b) > 4 && b[4] == BlockEncrypted {
+		block, err := decryptBlock(m.cipher, key, entry.Offset, b[4:])
+		if err != nil {
+			return nil, err
+		}
+		return append(b[:4:4], block...), nil
+	}
+	return b, nil
+}
+
 func (m *mmapAccessor) read(key string, timestamp int64) ([]Value, error) {
 	entry := m.index.Entry(key, timestamp)
 	if entry == nil {
 		return nil, nil
 	}
 
-	return m.readBlock(entry, nil)
+	return m.readBlock(key, entry, nil)
 }
 
-func (m *mmapAccessor) readBlock(entry *IndexEntry, values []Value) ([]Value, error) {
+func (m *mmapAccessor) readBlock(key string, entry *IndexEntry, values []Value) ([]Value, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if int64(len(m.b)) < entry.Offset+int64(entry.Size) {
-		return nil, ErrTSMClosed
+	b, err := m.block(key, entry)
+	if err != nil {
+		return nil, err
 	}
+
 	//TODO: Validate checksum
-	var err error
-	values, err = DecodeBlock(m.b[entry.Offset+4:entry.Offset+int64(entry.Size)], values)
+	values, err = DecodeBlock(b[4:], values)
 	if err != nil {
 		return nil, err
 	}
@@ -909,16 +1206,17 @@ func (m *mmapAccessor) readBlock(entry *IndexEntry, values []Value) ([]Value, er
 	return values, nil
 }
 
-func (m *mmapAccessor) readFloatBlock(entry *IndexEntry, tdec *TimeDecoder, vdec *FloatDecoder, values *[]FloatValue) ([]FloatValue, error) {
+func (m *mmapAccessor) readFloatBlock(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *FloatDecoder, values *[]FloatValue) ([]FloatValue, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if int64(len(m.b)) < entry.Offset+int64(entry.Size) {
-		return nil, ErrTSMClosed
+	b, err := m.block(key, entry)
+	if err != nil {
+		return nil, err
 	}
 
 	//TODO: Validate checksum
-	a, err := DecodeFloatBlock(m.b[entry.Offset+4:entry.Offset+int64(entry.Size)], tdec, vdec, values)
+	a, err := DecodeFloatBlock(b[4:], tdec, vdec, values)
 	if err != nil {
 		return nil, err
 	}
@@ -926,15 +1224,17 @@ func (m *mmapAccessor) readFloatBlock(entry *IndexEntry, tdec *TimeDecoder, vdec
 	return a, nil
 }
 
-func (m *mmapAccessor) readIntegerBlock(entry *IndexEntry, tdec *TimeDecoder, vdec *IntegerDecoder, values *[]IntegerValue) ([]IntegerValue, error) {
+func (m *mmapAccessor) readIntegerBlock(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *IntegerDecoder, values *[]IntegerValue) ([]IntegerValue, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if int64(len(m.b)) < entry.Offset+int64(entry.Size) {
-		return nil, ErrTSMClosed
+	b, err := m.block(key, entry)
+	if err != nil {
+		return nil, err
 	}
+
 	//TODO: Validate checksum
-	a, err := DecodeIntegerBlock(m.b[entry.Offset+4:entry.Offset+int64(entry.Size)], tdec, vdec, values)
+	a, err := DecodeIntegerBlock(b[4:], tdec, vdec, values)
 	if err != nil {
 		return nil, err
 	}
@@ -942,15 +1242,17 @@ func (m *mmapAccessor) readIntegerBlock(entry *IndexEntry, tdec *TimeDecoder, vd
 	return a, nil
 }
 
-func (m *mmapAccessor) readStringBlock(entry *IndexEntry, tdec *TimeDecoder, vdec *StringDecoder, values *[]StringValue) ([]StringValue, error) {
+func (m *mmapAccessor) readStringBlock(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *StringDecoder, values *[]StringValue) ([]StringValue, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if int64(len(m.b)) < entry.Offset+int64(entry.Size) {
-		return nil, ErrTSMClosed
+	b, err := m.block(key, entry)
+	if err != nil {
+		return nil, err
 	}
+
 	//TODO: Validate checksum
-	a, err := DecodeStringBlock(m.b[entry.Offset+4:entry.Offset+int64(entry.Size)], tdec, vdec, values)
+	a, err := DecodeStringBlock(b[4:], tdec, vdec, values)
 	if err != nil {
 		return nil, err
 	}
@@ -958,15 +1260,17 @@ func (m *mmapAccessor) readStringBlock(entry *IndexEntry, tdec *TimeDecoder, vde
 	return a, nil
 }
 
-func (m *mmapAccessor) readBooleanBlock(entry *IndexEntry, tdec *TimeDecoder, vdec *BooleanDecoder, values *[]BooleanValue) ([]BooleanValue, error) {
+func (m *mmapAccessor) readBooleanBlock(key string, entry *IndexEntry, tdec *TimeDecoder, vdec *BooleanDecoder, values *[]BooleanValue) ([]BooleanValue, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if int64(len(m.b)) < entry.Offset+int64(entry.Size) {
-		return nil, ErrTSMClosed
+	b, err := m.block(key, entry)
+	if err != nil {
+		return nil, err
 	}
+
 	//TODO: Validate checksum
-	a, err := DecodeBooleanBlock(m.b[entry.Offset+4:entry.Offset+int64(entry.Size)], tdec, vdec, values)
+	a, err := DecodeBooleanBlock(b[4:], tdec, vdec, values)
 	if err != nil {
 		return nil, err
 	}
@@ -974,16 +1278,17 @@ func (m *mmapAccessor) readBooleanBlock(entry *IndexEntry, tdec *TimeDecoder, vd
 	return a, nil
 }
 
-func (m *mmapAccessor) readBytes(entry *IndexEntry, b []byte) (uint32, []byte, error) {
+func (m *mmapAccessor) readBytes(key string, entry *IndexEntry, b []byte) (uint32, []byte, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if int64(len(m.b)) < entry.Offset+int64(entry.Size) {
-		return 0, nil, ErrTSMClosed
+	block, err := m.block(key, entry)
+	if err != nil {
+		return 0, nil, err
 	}
 
 	// return the bytes after the 4 byte checksum
-	return binary.BigEndian.Uint32(m.b[entry.Offset : entry.Offset+4]), m.b[entry.Offset+4 : entry.Offset+int64(entry.Size)], nil
+	return binary.BigEndian.Uint32(block[:4]), block[4:], nil
 }
 
 // ReadAll returns all values for a key in all blocks.
@@ -999,7 +1304,6 @@ func (m *mmapAccessor) readAll(key string) ([]Value, error) {
 	defer m.mu.RUnlock()
 
 	var temp []Value
-	var err error
 	var values []Value
 	for _, block := range blocks {
 		var skip bool
@@ -1014,10 +1318,15 @@ func (m *mmapAccessor) readAll(key string) ([]Value, error) {
 		if skip {
 			continue
 		}
+		b, err := m.block(key, &block)
+		if err != nil {
+			return nil, err
+		}
+
 		//TODO: Validate checksum
 		temp = temp[:0]
-		// The +4 is the 4 byte checksum length
-		temp, err = DecodeBlock(m.b[block.Offset+4:block.Offset+int64(block.Size)], temp)
+		// The 4 is the 4 byte checksum length
+		temp, err = DecodeBlock(b[4:], temp)
 		if err != nil {
 			return nil, err
 		}
@@ -1054,6 +1363,7 @@ func (m *mmapAccessor) close() error {
 	}
 
 	m.b = nil
+	m.summaries = blockSummaries{}
 	return m.f.Close()
 }
 
@@ -1,11 +1,15 @@
 package tsm1_test
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"os"
 	"testing"
 
+	"github.com/influxdata/influxdb/tsdb"
 	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
 )
 
@@ -129,6 +133,487 @@ func TestTSMReader_MMAP_ReadAll(t *testing.T) {
 	}
 }
 
+func TestTSMReader_DirectReads(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+	defer f.Close()
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+
+	values := []tsm1.Value{tsm1.NewValue(1, 1.0), tsm1.NewValue(2, 2.0)}
+	if err := w.Write("cpu", values); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error open file: %v", err)
+	}
+
+	r, err := tsm1.NewTSMReaderWithOptions(f, tsm1.TSMReaderOptions{
+		MmapAdvice:  tsdb.MmapAdviceRandom,
+		DirectReads: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error created reader: %v", err)
+	}
+	defer r.Close()
+
+	readValues, err := r.ReadAll("cpu")
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+
+	if exp := len(values); exp != len(readValues) {
+		t.Fatalf("read values length mismatch: got %v, exp %v", len(readValues), exp)
+	}
+
+	for i, v := range values {
+		if v.Value() != readValues[i].Value() {
+			t.Fatalf("read value mismatch(%d): got %v, exp %v", i, readValues[i].Value(), v.Value())
+		}
+	}
+
+	if err := r.Verify(); err != nil {
+		t.Fatalf("unexpected error verifying: %v", err)
+	}
+}
+
+func TestTSMReader_LazyIndex(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+	defer f.Close()
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+
+	// Write enough keys for the index to span several pages.
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("cpu,host=server%04d#!~#value", i)
+		keys = append(keys, key)
+		if err := w.Write(key, []tsm1.Value{tsm1.NewValue(int64(i), float64(i))}); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error open file: %v", err)
+	}
+
+	r, err := tsm1.NewTSMReaderWithOptions(f, tsm1.TSMReaderOptions{
+		LazyIndex:      true,
+		IndexCacheSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error created reader: %v", err)
+	}
+	defer r.Close()
+
+	for pass := 0; pass < 2; pass++ {
+		for i, key := range keys {
+			entries := r.Entries(key)
+			if got, exp := len(entries), 1; got != exp {
+				t.Fatalf("entries length mismatch(%s): got %v, exp %v", key, got, exp)
+			}
+			if got, exp := entries[0].MinTime, int64(i); got != exp {
+				t.Fatalf("min time mismatch(%s): got %v, exp %v", key, got, exp)
+			}
+
+			// Modifying the returned entries must not modify the cache.
+			entries[0].MinTime = -1
+		}
+	}
+
+	values, err := r.ReadAll(keys[10])
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+	if got, exp := len(values), 1; got != exp {
+		t.Fatalf("read values length mismatch: got %v, exp %v", got, exp)
+	}
+
+	if err := r.Delete([]string{keys[999]}); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+	if r.Contains(keys[999]) {
+		t.Fatalf("expected %s to be deleted", keys[999])
+	}
+	if !r.Contains(keys[998]) {
+		t.Fatalf("expected %s to exist", keys[998])
+	}
+}
+
+func TestTSMReader_LockIndex(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+	defer f.Close()
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+
+	values := []tsm1.Value{tsm1.NewValue(1, 1.0), tsm1.NewValue(2, 2.0)}
+	if err := w.Write("cpu", values); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error open file: %v", err)
+	}
+
+	r, err := tsm1.NewTSMReaderWithOptions(f, tsm1.TSMReaderOptions{LockIndex: true})
+	if err != nil {
+		t.Fatalf("unexpected error created reader: %v", err)
+	}
+	defer r.Close()
+
+	readValues, err := r.ReadAll("cpu")
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+
+	if exp := len(values); exp != len(readValues) {
+		t.Fatalf("read values length mismatch: got %v, exp %v", len(readValues), exp)
+	}
+}
+
+func TestTSMReader_TimeRange(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+	defer f.Close()
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+
+	if err := w.Write("cpu", []tsm1.Value{tsm1.NewValue(-10, 1.0), tsm1.NewValue(-5, 2.0)}); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	if err := w.Write("mem", []tsm1.Value{tsm1.NewValue(-20, 1.0), tsm1.NewValue(-15, 2.0)}); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error open file: %v", err)
+	}
+
+	r, err := tsm1.NewTSMReader(f)
+	if err != nil {
+		t.Fatalf("unexpected error created reader: %v", err)
+	}
+	defer r.Close()
+
+	if min, max := r.TimeRange(); min != -20 || max != -5 {
+		t.Fatalf("time range mismatch: got %d-%d, exp -20--5", min, max)
+	}
+}
+
+func TestTSMReader_Verify(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+	defer f.Close()
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+
+	values := []tsm1.Value{tsm1.NewValue(1, 1.0), tsm1.NewValue(2, 2.0)}
+	if err := w.Write("cpu", values); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	r := MustOpenTSMReader(f.Name())
+	if err := r.Verify(); err != nil {
+		t.Fatalf("unexpected error verifying: %v", err)
+	}
+	r.Close()
+
+	// Corrupt the first block, after the 5 byte header and 4 byte checksum.
+	fd, err := os.OpenFile(f.Name(), os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatalf("unexpected error opening file: %v", err)
+	}
+	if _, err := fd.WriteAt([]byte{0xff, 0xff}, 10); err != nil {
+		t.Fatalf("unexpected error corrupting file: %v", err)
+	}
+	fd.Close()
+
+	r = MustOpenTSMReader(f.Name())
+	defer r.Close()
+	if err := r.Verify(); err == nil {
+		t.Fatal("expected verify error")
+	}
+
+	errs := r.VerifyBlocks()
+	if got, exp := len(errs), 1; got != exp {
+		t.Fatalf("block error count mismatch: got %v, exp %v", got, exp)
+	}
+	if got, exp := errs[0].Key, "cpu"; got != exp {
+		t.Fatalf("key mismatch: got %v, exp %v", got, exp)
+	}
+	if errs[0].MinTime != 1 || errs[0].MaxTime != 2 {
+		t.Fatalf("time range mismatch: got %d-%d, exp 1-2", errs[0].MinTime, errs[0].MaxTime)
+	}
+}
+
+func TestTSMReader_BlockSummary(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+	defer f.Close()
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+
+	if err := w.Write("cpu", []tsm1.Value{tsm1.NewValue(1, 3.0), tsm1.NewValue(2, 1.0), tsm1.NewValue(3, 5.0), tsm1.NewValue(4, 1.0)}); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	block, err := tsm1.Values([]tsm1.Value{tsm1.NewValue(10, int64(-2)), tsm1.NewValue(20, int64(7))}).Encode(nil)
+	if err != nil {
+		t.Fatalf("unexpected error encoding: %v", err)
+	}
+	if err := w.WriteBlock("mem", 10, 20, block); err != nil {
+		t.Fatalf("unexpected error writing block: %v", err)
+	}
+
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	r := MustOpenTSMReader(f.Name())
+	defer r.Close()
+
+	entries := r.Entries("cpu")
+	if s, ok := r.BlockSummary(&entries[0]); !ok {
+		t.Fatalf("expected summary for cpu")
+	} else if exp := (tsm1.BlockSummary{Offset: entries[0].Offset, Type: tsm1.BlockFloat64, Count: 4, MinTime: 2, Min: 1, MaxTime: 3, Max: 5, Sum: 10, HasEnds: true, FirstTime: 1, First: 3, LastTime: 4, Last: 1}); s != exp {
+		t.Fatalf("summary mismatch: got %+v, exp %+v", s, exp)
+	}
+
+	// Blocks written with WriteBlock are not decoded to summarize them.
+	entries = r.Entries("mem")
+	if s, ok := r.BlockSummary(&entries[0]); ok {
+		t.Fatalf("unexpected summary for mem: %+v", s)
+	}
+
+	// Blocks are still readable by walking the index.
+	if err := r.Verify(); err != nil {
+		t.Fatalf("unexpected error verifying: %v", err)
+	}
+}
+
+// Ensure summaries written without first and last values can still be read.
+func TestTSMReader_BlockSummary_Legacy(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+	if err := w.Write("cpu", []tsm1.Value{tsm1.NewValue(1, 3.0), tsm1.NewValue(2, 1.0)}); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error reading file: %v", err)
+	}
+
+	// Rewrite the single 85 byte summary in the 53 byte layout used before
+	// first and last values were recorded.  The summaries are followed by
+	// their trailer and the index.
+	indexStart := int(binary.BigEndian.Uint64(b[len(b)-8:]))
+	trailer := indexStart - 8
+	summary := trailer - 85
+
+	var legacy []byte
+	legacy = append(legacy, b[:summary+53]...)
+	legacy = append(legacy, b[trailer:trailer+4]...)
+	legacy = append(legacy, 0x16, 0xD1, 0x53, 0x55)
+	legacy = append(legacy, b[trailer+8:len(b)-8]...)
+
+	var footer [8]byte
+	binary.BigEndian.PutUint64(footer[:], uint64(indexStart-32))
+	legacy = append(legacy, footer[:]...)
+
+	if err := ioutil.WriteFile(f.Name(), legacy, 0666); err != nil {
+		t.Fatalf("unexpected error writing file: %v", err)
+	}
+
+	r := MustOpenTSMReader(f.Name())
+	defer r.Close()
+
+	entries := r.Entries("cpu")
+	if s, ok := r.BlockSummary(&entries[0]); !ok {
+		t.Fatalf("expected summary for cpu")
+	} else if exp := (tsm1.BlockSummary{Offset: entries[0].Offset, Type: tsm1.BlockFloat64, Count: 2, MinTime: 2, Min: 1, MaxTime: 1, Max: 3, Sum: 4}); s != exp {
+		t.Fatalf("summary mismatch: got %+v, exp %+v", s, exp)
+	}
+}
+
+func TestTSMReader_BloomFilter(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+	defer f.Close()
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("cpu,host=server%03d#!~#value", i)
+		if err := w.Write(key, []tsm1.Value{tsm1.NewValue(int64(i), float64(i))}); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	r := MustOpenTSMReader(f.Name())
+	defer r.Close()
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("cpu,host=server%03d#!~#value", i)
+		if !r.Contains(key) {
+			t.Fatalf("expected reader to contain %s", key)
+		} else if entries := r.Entries(key); len(entries) != 1 {
+			t.Fatalf("entries length mismatch for %s: got %v, exp %v", key, len(entries), 1)
+		}
+	}
+
+	for _, key := range []string{"cpu,host=server100#!~#value", "mem,host=server001#!~#value", ""} {
+		if r.Contains(key) {
+			t.Fatalf("expected reader not to contain %q", key)
+		}
+	}
+}
+
+// Ensure a bloom filter whose checksum does not match is ignored rather than
+// used to skip keys the file contains.
+func TestTSMReader_BloomFilter_Corrupt(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+	defer f.Close()
+
+	w, err := tsm1.NewTSMWriter(f)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+	if err := w.Write("cpu", []tsm1.Value{tsm1.NewValue(1, 1.0)}); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	// Clear the bits of the filter so it no longer contains the key.
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error reading file: %v", err)
+	}
+	var magic [4]byte
+	binary.BigEndian.PutUint32(magic[:], tsm1.BloomMagicNumber)
+	end := bytes.LastIndex(b, magic[:])
+	if end == -1 {
+		t.Fatal("bloom filter not found")
+	}
+	size := int(binary.BigEndian.Uint32(b[end-8 : end-4]))
+	for i := end - 8 - size + 1; i < end-8; i++ {
+		b[i] = 0
+	}
+	if err := ioutil.WriteFile(f.Name(), b, 0666); err != nil {
+		t.Fatalf("unexpected error writing file: %v", err)
+	}
+
+	r := MustOpenTSMReader(f.Name())
+	defer r.Close()
+	if !r.Contains("cpu") {
+		t.Fatal("expected reader to contain cpu")
+	}
+}
+
 func TestTSMReader_MMAP_Read(t *testing.T) {
 	dir := MustTempDir()
 	defer os.RemoveAll(dir)
@@ -375,6 +860,17 @@ func TestTSMReader_MMAP_TombstoneRange(t *testing.T) {
 		t.Fatalf("unexpected error created reader: %v", err)
 	}
 
+	// Deleting data the file does not hold does not add a tombstone.
+	if err := r.DeleteRange([]string{"cpu"}, 4, math.MaxInt64); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+	if err := r.DeleteRange([]string{"mem"}, 2, math.MaxInt64); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+	if r.HasTombstones() {
+		t.Fatal("expected no tombstones")
+	}
+
 	if err := r.DeleteRange([]string{"cpu"}, 2, math.MaxInt64); err != nil {
 		t.Fatalf("unexpected error deleting: %v", err)
 	}
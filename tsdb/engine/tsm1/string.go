@@ -4,6 +4,12 @@ package tsm1
 // appended to byte slice prefixed with a variable byte length followed by the string
 // bytes.  The bytes are compressed using snappy compressor and a 1 byte header is used
 // to indicate the type of encoding.
+//
+// Blocks with few distinct strings may use dictionary encoding instead.  The distinct
+// strings are written once, prefixed by their count, followed by the variable byte
+// encoded dictionary index of each value.  The result is also compressed with snappy.
+// Versions without dictionary support cannot read these blocks, so it is only used
+// by encoders returned by NewStringDictionaryEncoder.
 
 import (
 	"encoding/binary"
@@ -19,17 +25,37 @@ const (
 
 	// stringCompressedSnappy is a compressed encoding using Snappy compression
 	stringCompressedSnappy = 1
+
+	// stringCompressedDictionary is a dictionary encoding compressed using Snappy
+	stringCompressedDictionary = 2
+
+	// maxStringDictionarySize is the most distinct strings a block may have to use
+	// dictionary encoding.
+	maxStringDictionarySize = 256
 )
 
 type StringEncoder struct {
 	// The encoded bytes
 	bytes []byte
+
+	// dict maps each distinct string to its index in the dictionary and
+	// indexes holds the index of each value.  dict is nil if dictionary
+	// encoding is not used or there are too many distinct strings for it.
+	dict    map[string]int
+	words   []string
+	indexes []int
 }
 
 func NewStringEncoder() StringEncoder {
 	return StringEncoder{}
 }
 
+// NewStringDictionaryEncoder returns an encoder which uses dictionary encoding
+// for blocks with few distinct strings.
+func NewStringDictionaryEncoder() StringEncoder {
+	return StringEncoder{dict: make(map[string]int)}
+}
+
 func (e *StringEncoder) Write(s string) {
 	b := make([]byte, 10)
 	// Append the length of the string using variable byte encoding
@@ -38,32 +64,94 @@ func (e *StringEncoder) Write(s string) {
 
 	// Append the string bytes
 	e.bytes = append(e.bytes, s...)
+
+	if e.dict == nil {
+		return
+	}
+
+	idx, ok := e.dict[s]
+	if !ok {
+		if len(e.words) == maxStringDictionarySize {
+			e.dict, e.words, e.indexes = nil, nil, nil
+			return
+		}
+		idx = len(e.words)
+		e.dict[s] = idx
+		e.words = append(e.words, s)
+	}
+	e.indexes = append(e.indexes, idx)
 }
 
 func (e *StringEncoder) Bytes() ([]byte, error) {
+	// Use a dictionary when values repeat enough for it to be smaller.
+	if e.dict != nil && len(e.indexes) > 0 && len(e.words)*2 <= len(e.indexes) {
+		return e.dictionaryBytes(), nil
+	}
+
 	// Compress the currently appended bytes using snappy and prefix with
 	// a 1 byte header for future extension
 	data := snappy.Encode(nil, e.bytes)
 	return append([]byte{stringCompressedSnappy << 4}, data...), nil
 }
 
+// dictionaryBytes returns the values encoded as dictionary indexes.
+func (e *StringEncoder) dictionaryBytes() []byte {
+	var b []byte
+	var buf [binary.MaxVarintLen64]byte
+
+	i := binary.PutUvarint(buf[:], uint64(len(e.words)))
+	b = append(b, buf[:i]...)
+	for _, w := range e.words {
+		i = binary.PutUvarint(buf[:], uint64(len(w)))
+		b = append(b, buf[:i]...)
+		b = append(b, w...)
+	}
+
+	for _, idx := range e.indexes {
+		i = binary.PutUvarint(buf[:], uint64(idx))
+		b = append(b, buf[:i]...)
+	}
+
+	data := snappy.Encode(nil, b)
+	return append([]byte{stringCompressedDictionary << 4}, data...)
+}
+
 type StringDecoder struct {
 	b   []byte
 	l   int
 	i   int
 	err error
+
+	// dict holds the distinct strings of a dictionary encoded block.  When
+	// set, b holds the dictionary index of each value.
+	dict []string
 }
 
 // SetBytes initializes the decoder with bytes to read from.
 // This must be called before calling any other method.
 func (e *StringDecoder) SetBytes(b []byte) error {
-	// First byte stores the encoding type, only have snappy format
-	// currently so ignore for now.
+	if len(b) == 0 {
+		return fmt.Errorf("failed to decode string block: no data")
+	}
+
+	// First byte stores the encoding type.
+	typ := b[0] >> 4
+	if typ != stringCompressedSnappy && typ != stringCompressedDictionary {
+		return fmt.Errorf("failed to decode string block: unknown encoding %v", typ)
+	}
+
 	data, err := snappy.Decode(nil, b[1:])
 	if err != nil {
 		return fmt.Errorf("failed to decode string block: %v", err.Error())
 	}
 
+	e.dict = nil
+	if typ == stringCompressedDictionary {
+		if e.dict, data, err = readStringDictionary(data); err != nil {
+			return err
+		}
+	}
+
 	e.b = data
 	e.l = 0
 	e.i = 0
@@ -72,12 +160,44 @@ func (e *StringDecoder) SetBytes(b []byte) error {
 	return nil
 }
 
+// readStringDictionary reads the dictionary at the start of b and returns it
+// with the remaining bytes.
+func readStringDictionary(b []byte) ([]string, []byte, error) {
+	n, i := binary.Uvarint(b)
+	if i <= 0 || n > maxStringDictionarySize {
+		return nil, nil, fmt.Errorf("failed to decode string block: invalid dictionary size")
+	}
+	b = b[i:]
+
+	dict := make([]string, n)
+	for j := range dict {
+		length, i := binary.Uvarint(b)
+		if i <= 0 || uint64(len(b)-i) < length {
+			return nil, nil, fmt.Errorf("failed to decode string block: short dictionary")
+		}
+		dict[j] = string(b[i : i+int(length)])
+		b = b[i+int(length):]
+	}
+	return dict, b, nil
+}
+
 func (e *StringDecoder) Next() bool {
 	e.i += e.l
 	return e.i < len(e.b)
 }
 
 func (e *StringDecoder) Read() string {
+	if e.dict != nil {
+		idx, n := binary.Uvarint(e.b[e.i:])
+		e.l = n
+		if n <= 0 || idx >= uint64(len(e.dict)) {
+			e.err = fmt.Errorf("failed to decode string block: invalid dictionary index")
+			e.l = len(e.b) - e.i
+			return ""
+		}
+		return e.dict[idx]
+	}
+
 	// Read the length of the string
 	length, n := binary.Uvarint(e.b[e.i:])
 
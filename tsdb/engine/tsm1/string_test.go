@@ -86,6 +86,78 @@ func Test_StringEncoder_Multi_Compressed(t *testing.T) {
 	}
 }
 
+func Test_StringEncoder_Multi_Dictionary(t *testing.T) {
+	enc := NewStringDictionaryEncoder()
+
+	statuses := []string{"ok", "warn", "critical"}
+	values := make([]string, 100)
+	for i := range values {
+		values[i] = statuses[i%len(statuses)]
+		enc.Write(values[i])
+	}
+
+	b, err := enc.Bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b[0]>>4 != stringCompressedDictionary {
+		t.Fatalf("unexpected encoding: got %v, exp %v", b[0]>>4, stringCompressedDictionary)
+	}
+
+	var dec StringDecoder
+	if err := dec.SetBytes(b); err != nil {
+		t.Fatalf("unexpected error creating string decoder: %v", err)
+	}
+
+	for i, v := range values {
+		if !dec.Next() {
+			t.Fatalf("unexpected next value: got false, exp true")
+		}
+		if got := dec.Read(); v != got {
+			t.Fatalf("unexpected value at pos %d: got %v, exp %v", i, got, v)
+		}
+	}
+
+	if dec.Next() {
+		t.Fatalf("unexpected next value: got true, exp false")
+	} else if err := dec.Error(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_StringEncoder_Dictionary_Disabled(t *testing.T) {
+	enc := NewStringEncoder()
+	for i := 0; i < 100; i++ {
+		enc.Write("ok")
+	}
+
+	b, err := enc.Bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b[0]>>4 != stringCompressedSnappy {
+		t.Fatalf("unexpected encoding: got %v, exp %v", b[0]>>4, stringCompressedSnappy)
+	}
+}
+
+func Test_StringEncoder_Dictionary_TooManyWords(t *testing.T) {
+	enc := NewStringDictionaryEncoder()
+	for i := 0; i < 2*(maxStringDictionarySize+1); i++ {
+		enc.Write(fmt.Sprintf("value %d", i%(maxStringDictionarySize+1)))
+	}
+
+	b, err := enc.Bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b[0]>>4 != stringCompressedSnappy {
+		t.Fatalf("unexpected encoding: got %v, exp %v", b[0]>>4, stringCompressedSnappy)
+	}
+}
+
 func Test_StringEncoder_Quick(t *testing.T) {
 	quick.Check(func(values []string) bool {
 		expected := values
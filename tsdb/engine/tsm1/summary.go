@@ -0,0 +1,275 @@
+package tsm1
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+Files written by this version of the engine store a summary of every block in
+a section between the last block and the index.  Readers that do not know about
+the section ignore it since the footer still points at the start of the index.
+
+┌─────────────────────────────────────────────────────────────────────────────┐
+│                                 Summaries                                   │
+├────────┬──────┬───────┬────────┬───────┬────────┬───────┬───────┬────┬─────┤
+│ Offset │ Type │ Count │MinTime │  Min  │MaxTime │  Max  │  Sum  │... │Trail│
+│8 bytes │1 byte│4 bytes│8 bytes │8 bytes│8 bytes │8 bytes│8 bytes│    │8 b. │
+└────────┴──────┴───────┴────────┴───────┴────────┴───────┴───────┴────┴─────┘
+
+Each summary continues with the first and last values of the block.
+
+┌─────────────────────────────────────┐
+│          Summary (cont.)            │
+├─────────┬───────┬─────────┬─────────┤
+│FirstTime│ First │LastTime │  Last   │
+│ 8 bytes │8 bytes│ 8 bytes │ 8 bytes │
+└─────────┴───────┴─────────┴─────────┘
+
+Summaries are ordered by block offset.  Min, Max, Sum, First and Last hold
+float64 bits for float blocks and int64 values for integer blocks and are zero
+for other block types.  MinTime and MaxTime are the times of the first
+occurrence of the min and max values, or the time of the first value in the
+block for other block types.  The trailer is the number of summaries followed by
+a magic number.
+
+Files written before the first and last values were added use a different magic
+number and 53 byte summaries that end with Sum.  They are still read but cannot
+answer first or last.
+*/
+
+const (
+	// SummaryMagicNumber marks the end of the block summary section.
+	SummaryMagicNumber uint32 = 0x16D15356
+
+	// legacySummaryMagicNumber marks summary sections without first and
+	// last values.
+	legacySummaryMagicNumber uint32 = 0x16D15355
+
+	// Size in bytes of a block summary
+	blockSummarySize = 85
+
+	// Size in bytes of a block summary without first and last values
+	legacyBlockSummarySize = 53
+
+	// Size in bytes of the summary section trailer
+	summaryTrailerSize = 8
+)
+
+// BlockSummary holds aggregates of the values in a single block so that
+// queries can answer count, sum, mean, min, max, first and last without
+// decoding it.
+type BlockSummary struct {
+	// Offset is the file offset of the block being summarized.
+	Offset int64
+	Type   byte
+	Count  uint32
+
+	// Min, Max, Sum, First and Last are only set for float and integer blocks.
+	MinTime, MaxTime int64
+	Min, Max, Sum    float64
+
+	IntegerMin, IntegerMax, IntegerSum int64
+
+	// HasEnds is false for summaries read from files written before the
+	// first and last values were recorded.
+	HasEnds             bool
+	FirstTime, LastTime int64
+	First, Last         float64
+
+	IntegerFirst, IntegerLast int64
+}
+
+// newBlockSummary returns the summary for values written at offset.
+func newBlockSummary(offset int64, typ byte, values []Value) BlockSummary {
+	s := BlockSummary{Offset: offset, Type: typ, Count: uint32(len(values)), HasEnds: true}
+	if len(values) > 0 {
+		s.MinTime, s.MaxTime = values[0].UnixNano(), values[0].UnixNano()
+		s.FirstTime, s.LastTime = values[0].UnixNano(), values[len(values)-1].UnixNano()
+	}
+
+	for i, v := range values {
+		switch v := v.(type) {
+		case *FloatValue:
+			if i == 0 || v.value < s.Min {
+				s.Min, s.MinTime = v.value, v.unixnano
+			}
+			if i == 0 || v.value > s.Max {
+				s.Max, s.MaxTime = v.value, v.unixnano
+			}
+			s.Sum += v.value
+			if i == 0 {
+				s.First = v.value
+			}
+			s.Last = v.value
+		case *IntegerValue:
+			if i == 0 || v.value < s.IntegerMin {
+				s.IntegerMin, s.MinTime = v.value, v.unixnano
+			}
+			if i == 0 || v.value > s.IntegerMax {
+				s.IntegerMax, s.MaxTime = v.value, v.unixnano
+			}
+			s.IntegerSum += v.value
+			if i == 0 {
+				s.IntegerFirst = v.value
+			}
+			s.IntegerLast = v.value
+		}
+	}
+	return s
+}
+
+// AppendTo appends the binary encoding of the summary to b.
+func (s *BlockSummary) AppendTo(b []byte) []byte {
+	var buf [blockSummarySize]byte
+	binary.BigEndian.PutUint64(buf[0:8], uint64(s.Offset))
+	buf[8] = s.Type
+	binary.BigEndian.PutUint32(buf[9:13], s.Count)
+	binary.BigEndian.PutUint64(buf[13:21], uint64(s.MinTime))
+	binary.BigEndian.PutUint64(buf[29:37], uint64(s.MaxTime))
+	binary.BigEndian.PutUint64(buf[53:61], uint64(s.FirstTime))
+	binary.BigEndian.PutUint64(buf[69:77], uint64(s.LastTime))
+
+	switch s.Type {
+	case BlockFloat64:
+		binary.BigEndian.PutUint64(buf[21:29], math.Float64bits(s.Min))
+		binary.BigEndian.PutUint64(buf[37:45], math.Float64bits(s.Max))
+		binary.BigEndian.PutUint64(buf[45:53], math.Float64bits(s.Sum))
+		binary.BigEndian.PutUint64(buf[61:69], math.Float64bits(s.First))
+		binary.BigEndian.PutUint64(buf[77:85], math.Float64bits(s.Last))
+	case BlockInteger:
+		binary.BigEndian.PutUint64(buf[21:29], uint64(s.IntegerMin))
+		binary.BigEndian.PutUint64(buf[37:45], uint64(s.IntegerMax))
+		binary.BigEndian.PutUint64(buf[45:53], uint64(s.IntegerSum))
+		binary.BigEndian.PutUint64(buf[61:69], uint64(s.IntegerFirst))
+		binary.BigEndian.PutUint64(buf[77:85], uint64(s.IntegerLast))
+	}
+	return append(b, buf[:]...)
+}
+
+// UnmarshalBinary decodes a summary from b.
+func (s *BlockSummary) UnmarshalBinary(b []byte) error {
+	if len(b) < blockSummarySize {
+		return fmt.Errorf("unmarshalBinary: short buf: %v < %v", len(b), blockSummarySize)
+	}
+	if err := s.unmarshalLegacy(b); err != nil {
+		return err
+	}
+
+	s.HasEnds = true
+	s.FirstTime = int64(binary.BigEndian.Uint64(b[53:61]))
+	s.LastTime = int64(binary.BigEndian.Uint64(b[69:77]))
+
+	switch s.Type {
+	case BlockFloat64:
+		s.First = math.Float64frombits(binary.BigEndian.Uint64(b[61:69]))
+		s.Last = math.Float64frombits(binary.BigEndian.Uint64(b[77:85]))
+	case BlockInteger:
+		s.IntegerFirst = int64(binary.BigEndian.Uint64(b[61:69]))
+		s.IntegerLast = int64(binary.BigEndian.Uint64(b[77:85]))
+	}
+	return nil
+}
+
+// unmarshalLegacy decodes a summary without first and last values from b.
+func (s *BlockSummary) unmarshalLegacy(b []byte) error {
+	if len(b) < legacyBlockSummarySize {
+		return fmt.Errorf("unmarshalBinary: short buf: %v < %v", len(b), legacyBlockSummarySize)
+	}
+	*s = BlockSummary{
+		Offset:  int64(binary.BigEndian.Uint64(b[0:8])),
+		Type:    b[8],
+		Count:   binary.BigEndian.Uint32(b[9:13]),
+		MinTime: int64(binary.BigEndian.Uint64(b[13:21])),
+		MaxTime: int64(binary.BigEndian.Uint64(b[29:37])),
+	}
+
+	switch s.Type {
+	case BlockFloat64:
+		s.Min = math.Float64frombits(binary.BigEndian.Uint64(b[21:29]))
+		s.Max = math.Float64frombits(binary.BigEndian.Uint64(b[37:45]))
+		s.Sum = math.Float64frombits(binary.BigEndian.Uint64(b[45:53]))
+	case BlockInteger:
+		s.IntegerMin = int64(binary.BigEndian.Uint64(b[21:29]))
+		s.IntegerMax = int64(binary.BigEndian.Uint64(b[37:45]))
+		s.IntegerSum = int64(binary.BigEndian.Uint64(b[45:53]))
+	}
+	return nil
+}
+
+// blockSummaries is the summary section of a TSM file.
+type blockSummaries struct {
+	b    []byte
+	size int // size in bytes of each summary
+}
+
+// readBlockSummaries returns the summary section that ends at end in b, or
+// nil if the file was written without one.  It also returns the position where
+// the section starts.
+func readBlockSummaries(b []byte, end int64) (blockSummaries, int64) {
+	if end < 5+summaryTrailerSize || end > int64(len(b)) {
+		return blockSummaries{}, end
+	}
+
+	trailer := b[end-summaryTrailerSize : end]
+
+	var s blockSummaries
+	switch binary.BigEndian.Uint32(trailer[4:8]) {
+	case SummaryMagicNumber:
+		s.size = blockSummarySize
+	case legacySummaryMagicNumber:
+		s.size = legacyBlockSummarySize
+	default:
+		return blockSummaries{}, end
+	}
+
+	size := int64(binary.BigEndian.Uint32(trailer[0:4])) * int64(s.size)
+	start := end - summaryTrailerSize - size
+	if start < 5 {
+		return blockSummaries{}, end
+	}
+	s.b = b[start : end-summaryTrailerSize]
+
+	// Every block must lie between the header and the summaries.  Anything
+	// else means the magic number was a coincidence in the last block.
+	if s.len() > 0 {
+		if s.offset(0) < 5 || s.offset(s.len()-1) >= start {
+			return blockSummaries{}, end
+		}
+	}
+	return s, start
+}
+
+func (a blockSummaries) len() int {
+	if a.size == 0 {
+		return 0
+	}
+	return len(a.b) / a.size
+}
+
+func (a blockSummaries) offset(i int) int64 {
+	return int64(binary.BigEndian.Uint64(a.b[i*a.size:]))
+}
+
+// find returns the summary for the block at offset.
+func (a blockSummaries) find(offset int64) (BlockSummary, bool) {
+	n := a.len()
+	i := sort.Search(n, func(i int) bool { return a.offset(i) >= offset })
+	if i == n || a.offset(i) != offset {
+		return BlockSummary{}, false
+	}
+
+	var s BlockSummary
+	var err error
+	if a.size == legacyBlockSummarySize {
+		err = s.unmarshalLegacy(a.b[i*a.size:])
+	} else {
+		err = s.UnmarshalBinary(a.b[i*a.size:])
+	}
+	if err != nil {
+		return BlockSummary{}, false
+	}
+	return s, true
+}
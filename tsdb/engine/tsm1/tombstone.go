@@ -1,7 +1,9 @@
 package tsm1
 
 import (
+	"bufio"
 	"encoding/binary"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
@@ -18,6 +20,10 @@ type Tombstoner struct {
 	// Path is the location of the file to record tombstone. This should be the
 	// full path to a TSM file.
 	Path string
+
+	// size is the length of the complete entries in the tombstone file, or
+	// zero if it is not known yet.  New entries are appended at this offset.
+	size int64
 }
 
 type Tombstone struct {
@@ -35,6 +41,8 @@ func (t *Tombstoner) Add(keys []string) error {
 }
 
 // AddRange adds all keys to the tombstone specifying only the data between min and max to be removed.
+// Entries are appended to the tombstone file so the cost of a delete does not
+// grow with the number of earlier deletes.
 func (t *Tombstoner) AddRange(keys []string, min, max int64) error {
 	if len(keys) == 0 {
 		return nil
@@ -49,11 +57,7 @@ func (t *Tombstoner) AddRange(keys []string, min, max int64) error {
 		return nil
 	}
 
-	tombstones, err := t.readTombstone()
-	if err != nil {
-		return nil
-	}
-
+	var tombstones []Tombstone
 	for _, k := range keys {
 		tombstones = append(tombstones, Tombstone{
 			Key: k,
@@ -62,7 +66,20 @@ func (t *Tombstoner) AddRange(keys []string, min, max int64) error {
 		})
 	}
 
-	return t.writeTombstone(tombstones)
+	if t.size == 0 {
+		existing, size, v2, err := t.readTombstoneFile()
+		if err != nil {
+			return err
+		}
+
+		// Files in the first format are rewritten in the current one.
+		if !v2 && len(existing) > 0 {
+			return t.writeTombstone(append(existing, tombstones...))
+		}
+		t.size = size
+	}
+
+	return t.appendTombstone(tombstones)
 }
 
 func (t *Tombstoner) ReadAll() ([]Tombstone, error) {
@@ -75,6 +92,7 @@ func (t *Tombstoner) Delete() error {
 	if err := os.RemoveAll(t.tombstonePath()); err != nil {
 		return err
 	}
+	t.size = 0
 	return nil
 }
 
@@ -90,14 +108,15 @@ func (t *Tombstoner) HasTombstones() bool {
 
 // TombstoneFiles returns any tombstone files associated with this TSM file.
 func (t *Tombstoner) TombstoneFiles() []FileStat {
-	stat, err := os.Stat(t.tombstonePath())
+	path := t.tombstonePath()
+	stat, err := os.Stat(path)
 	if err != nil {
 		return nil
 	}
 
 	if stat.Size() > 0 {
 		return []FileStat{FileStat{
-			Path:         stat.Name(),
+			Path:         path,
 			LastModified: stat.ModTime().UnixNano(),
 			Size:         uint32(stat.Size())}}
 	}
@@ -112,32 +131,11 @@ func (t *Tombstoner) writeTombstone(tombstones []Tombstone) error {
 	}
 	defer tmp.Close()
 
-	var b [8]byte
-
-	binary.BigEndian.PutUint32(b[:4], v2header)
-	if _, err := tmp.Write(b[:4]); err != nil {
+	b := appendTombstones(appendTombstoneHeader(nil), tombstones)
+	if _, err := tmp.Write(b); err != nil {
 		return err
 	}
 
-	for _, t := range tombstones {
-		binary.BigEndian.PutUint32(b[:4], uint32(len(t.Key)))
-		if _, err := tmp.Write(b[:4]); err != nil {
-			return err
-		}
-		if _, err := tmp.Write([]byte(t.Key)); err != nil {
-			return err
-		}
-		binary.BigEndian.PutUint64(b[:], uint64(t.Min))
-		if _, err := tmp.Write(b[:]); err != nil {
-			return err
-		}
-
-		binary.BigEndian.PutUint64(b[:], uint64(t.Max))
-		if _, err := tmp.Write(b[:]); err != nil {
-			return err
-		}
-	}
-
 	// fsync the file to flush the write
 	if err := tmp.Sync(); err != nil {
 		return err
@@ -150,31 +148,108 @@ func (t *Tombstoner) writeTombstone(tombstones []Tombstone) error {
 		return err
 	}
 
-	return syncDir(filepath.Dir(t.tombstonePath()))
+	if err := syncDir(filepath.Dir(t.tombstonePath())); err != nil {
+		return err
+	}
+	t.size = int64(len(b))
+	return nil
 }
 
-func (t *Tombstoner) readTombstone() ([]Tombstone, error) {
-	f, err := os.Open(t.tombstonePath())
+// appendTombstone appends entries to the tombstone file, creating it if
+// needed.  Anything after the last complete entry, such as a partial entry
+// left by a crash during an earlier append, is discarded first.
+func (t *Tombstoner) appendTombstone(tombstones []Tombstone) error {
+	f, err := os.OpenFile(t.tombstonePath(), os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
 	defer f.Close()
 
-	if !os.IsNotExist(err) {
-		var b [4]byte
-		_, err := f.Read(b[:])
-		if err != nil {
-			return nil, err
-		}
+	if err := f.Truncate(t.size); err != nil {
+		return err
+	}
+	if _, err := f.Seek(t.size, os.SEEK_SET); err != nil {
+		return err
+	}
 
-		if _, err := f.Seek(0, os.SEEK_SET); err != nil {
-			return nil, err
-		}
+	var b []byte
+	if t.size == 0 {
+		b = appendTombstoneHeader(b)
+	}
+	b = appendTombstones(b, tombstones)
+
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+
+	// fsync the file to flush the write
+	if err := f.Sync(); err != nil {
+		return err
+	}
 
-		if binary.BigEndian.Uint32(b[:]) == v2header {
-			return t.readTombstoneV2(f)
+	// A new file must also be synced into its directory.
+	if t.size == 0 {
+		if err := syncDir(filepath.Dir(t.tombstonePath())); err != nil {
+			return err
 		}
+	}
+
+	t.size += int64(len(b))
+	return nil
+}
+
+func appendTombstoneHeader(b []byte) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v2header)
+	return append(b, buf[:]...)
+}
+
+func appendTombstones(b []byte, tombstones []Tombstone) []byte {
+	var buf [8]byte
+	for _, t := range tombstones {
+		binary.BigEndian.PutUint32(buf[:4], uint32(len(t.Key)))
+		b = append(b, buf[:4]...)
+		b = append(b, t.Key...)
+
+		binary.BigEndian.PutUint64(buf[:], uint64(t.Min))
+		b = append(b, buf[:]...)
+
+		binary.BigEndian.PutUint64(buf[:], uint64(t.Max))
+		b = append(b, buf[:]...)
+	}
+	return b
+}
+
+func (t *Tombstoner) readTombstone() ([]Tombstone, error) {
+	tombstones, _, _, err := t.readTombstoneFile()
+	return tombstones, err
+}
 
-		return t.readTombstoneV1(f)
+// readTombstoneFile returns the entries of the tombstone file, the length of
+// its complete entries and whether it is in the current format.
+func (t *Tombstoner) readTombstoneFile() ([]Tombstone, int64, bool, error) {
+	f, err := os.Open(t.tombstonePath())
+	if os.IsNotExist(err) {
+		return nil, 0, false, nil
+	} else if err != nil {
+		return nil, 0, false, err
+	}
+	defer f.Close()
+
+	var b [4]byte
+	if _, err := io.ReadFull(f, b[:]); err == nil && binary.BigEndian.Uint32(b[:]) == v2header {
+		tombstones, size, err := t.readTombstoneV2(f)
+		return tombstones, size, true, err
+	} else if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, 0, false, err
+	}
+
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return nil, 0, false, err
 	}
-	return nil, nil
+
+	tombstones, err := t.readTombstoneV1(f)
+	return tombstones, 0, false, err
 }
 
 // readTombstoneV1 reads the first version of tombstone files that were not
@@ -211,67 +286,45 @@ func (t *Tombstoner) readTombstoneV1(f *os.File) ([]Tombstone, error) {
 
 // readTombstoneV2 reads the second version of tombstone files that are capable
 // of storing keys and the range of time for the key that points were deleted. This
-// format is binary.
-func (t *Tombstoner) readTombstoneV2(f *os.File) ([]Tombstone, error) {
+// format is binary.  A partial entry at the end of the file, left by a crash
+// while appending, is ignored.  It also returns the length of the complete
+// entries including the header.
+func (t *Tombstoner) readTombstoneV2(f *os.File) ([]Tombstone, int64, error) {
 	// Skip header, already checked earlier
 	if _, err := f.Seek(4, os.SEEK_SET); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	n := int64(4)
 
-	fi, err := f.Stat()
-	if err != nil {
-		return nil, err
-	}
-	size := fi.Size()
-
+	r := bufio.NewReader(f)
 	tombstones := []Tombstone{}
-	var (
-		min, max int64
-		key      string
-	)
-	b := make([]byte, 4096)
+	var b [4]byte
 	for {
-		if n >= size {
-			return tombstones, nil
-		}
-
-		if _, err = f.Read(b[:4]); err != nil {
-			return nil, err
+		if _, err := io.ReadFull(r, b[:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return tombstones, n, nil
+		} else if err != nil {
+			return nil, 0, err
 		}
-		n += 4
 
-		keyLen := int(binary.BigEndian.Uint32(b[:4]))
-		if keyLen > len(b) {
-			b = make([]byte, keyLen)
+		keyLen := int(binary.BigEndian.Uint32(b[:]))
+		if keyLen > maxKeyLength {
+			return tombstones, n, nil
 		}
 
-		if _, err := f.Read(b[:keyLen]); err != nil {
-			return nil, err
+		entry := make([]byte, keyLen+16)
+		if _, err := io.ReadFull(r, entry); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return tombstones, n, nil
+		} else if err != nil {
+			return nil, 0, err
 		}
-		key = string(b[:keyLen])
-		n += int64(keyLen)
-
-		if _, err := f.Read(b[:8]); err != nil {
-			return nil, err
-		}
-		n += 8
-
-		min = int64(binary.BigEndian.Uint64(b[:8]))
-
-		if _, err := f.Read(b[:8]); err != nil {
-			return nil, err
-		}
-		n += 8
-		max = int64(binary.BigEndian.Uint64(b[:8]))
 
 		tombstones = append(tombstones, Tombstone{
-			Key: key,
-			Min: min,
-			Max: max,
+			Key: string(entry[:keyLen]),
+			Min: int64(binary.BigEndian.Uint64(entry[keyLen : keyLen+8])),
+			Max: int64(binary.BigEndian.Uint64(entry[keyLen+8:])),
 		})
+		n += int64(4 + len(entry))
 	}
-	return tombstones, nil
 }
 
 func (t *Tombstoner) tombstonePath() string {
@@ -3,6 +3,8 @@ package tsm1_test
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/influxdata/influxdb/tsdb/engine/tsm1"
@@ -124,6 +126,62 @@ func TestTombstoner_Delete(t *testing.T) {
 	}
 }
 
+func TestTombstoner_Add_Append(t *testing.T) {
+	dir := MustTempDir()
+	defer func() { os.RemoveAll(dir) }()
+
+	f := MustTempFile(dir)
+	ts := &tsm1.Tombstoner{Path: f.Name()}
+	if err := ts.Add([]string{"foo"}); err != nil {
+		fatal(t, "Add", err)
+	}
+	if err := ts.AddRange([]string{"bar"}, 1, 2); err != nil {
+		fatal(t, "AddRange", err)
+	}
+
+	// Simulate a crash part way through appending an entry.
+	path := f.Name()[:len(f.Name())-len(filepath.Ext(f.Name()))] + ".tombstone"
+	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		fatal(t, "open tombstone", err)
+	}
+	if _, err := fd.Write([]byte{0, 0, 0, 3, 'b'}); err != nil {
+		fatal(t, "write partial entry", err)
+	}
+	fd.Close()
+
+	// A new Tombstoner ignores the partial entry and appends after the last
+	// complete one.
+	ts = &tsm1.Tombstoner{Path: f.Name()}
+	entries, err := ts.ReadAll()
+	if err != nil {
+		fatal(t, "ReadAll", err)
+	}
+	if got, exp := len(entries), 2; got != exp {
+		t.Fatalf("length mismatch: got %v, exp %v", got, exp)
+	}
+
+	if err := ts.Add([]string{"baz"}); err != nil {
+		fatal(t, "Add", err)
+	}
+
+	entries, err = (&tsm1.Tombstoner{Path: f.Name()}).ReadAll()
+	if err != nil {
+		fatal(t, "ReadAll", err)
+	}
+
+	var keys []string
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+	}
+	if got, exp := keys, []string{"foo", "bar", "baz"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("keys mismatch: got %v, exp %v", got, exp)
+	}
+	if entries[1].Min != 1 || entries[1].Max != 2 {
+		t.Fatalf("time range mismatch: got %d-%d, exp 1-2", entries[1].Min, entries[1].Max)
+	}
+}
+
 func TestTombstoner_ReadV1(t *testing.T) {
 	dir := MustTempDir()
 	defer func() { os.RemoveAll(dir) }()
@@ -25,6 +25,10 @@ const (
 	// DefaultSegmentSize of 10MB is the size at which segment files will be rolled over
 	DefaultSegmentSize = 10 * 1024 * 1024
 
+	// DefaultSyncLatencyHalfLife is the time the WAL must go without writes
+	// for its average fsync latency to halve.
+	DefaultSyncLatencyHalfLife = 10 * time.Second
+
 	// FileExtension is the file extension we expect for wal segments
 	WALFileExtension = "wal"
 
@@ -90,6 +94,14 @@ type WAL struct {
 	// LoggingEnabled specifies if detailed logs should be output
 	LoggingEnabled bool
 
+	// SyncLatencyHalfLife is the time the WAL must go without writes for
+	// its average fsync latency to halve, so slow fsyncs during a burst of
+	// writes do not keep reporting pressure once the writes stop.
+	SyncLatencyHalfLife time.Duration
+
+	// syncLatency is a moving average of the time taken to fsync writes.
+	syncLatency time.Duration
+
 	statMap *expvar.Map
 }
 
@@ -99,10 +111,11 @@ func NewWAL(path string) *WAL {
 		path: path,
 
 		// these options should be overriden by any options in the config
-		LogOutput:   os.Stderr,
-		SegmentSize: DefaultSegmentSize,
-		logger:      log.New(os.Stderr, "[tsm1wal] ", log.LstdFlags),
-		closing:     make(chan struct{}),
+		LogOutput:           os.Stderr,
+		SegmentSize:         DefaultSegmentSize,
+		SyncLatencyHalfLife: DefaultSyncLatencyHalfLife,
+		logger:              log.New(os.Stderr, "[tsm1wal] ", log.LstdFlags),
+		closing:             make(chan struct{}),
 
 		statMap: influxdb.NewStatistics(
 			"tsm1_wal:"+path,
@@ -118,6 +131,30 @@ func (l *WAL) SetLogOutput(w io.Writer) {
 	l.logger = log.New(w, "[tsm1wal] ", log.LstdFlags)
 }
 
+// SyncLatency returns a moving average of the time taken to fsync each write
+// to the current segment file.  The average decays while no writes arrive.
+func (l *WAL) SyncLatency() time.Duration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.idleSyncLatency(time.Now())
+}
+
+// idleSyncLatency returns the average fsync latency halved for every
+// SyncLatencyHalfLife between the last write and now.
+func (l *WAL) idleSyncLatency(now time.Time) time.Duration {
+	if l.SyncLatencyHalfLife <= 0 || l.lastWriteTime.IsZero() {
+		return l.syncLatency
+	}
+
+	n := now.Sub(l.lastWriteTime) / l.SyncLatencyHalfLife
+	if n <= 0 {
+		return l.syncLatency
+	} else if n >= 63 {
+		return 0
+	}
+	return l.syncLatency >> uint(n)
+}
+
 // Path returns the path the log was initialized with.
 func (l *WAL) Path() string {
 	l.mu.RLock()
@@ -307,9 +344,15 @@ func (l *WAL) writeToLog(entry WALEntry) (int, error) {
 	curSize.Set(int64(l.currentSegmentWriter.size))
 	l.statMap.Set(statWALCurrentBytes, curSize)
 
-	l.lastWriteTime = time.Now()
+	// Decay the average for the time the log was idle before this write.
+	now := time.Now()
+	l.syncLatency = l.idleSyncLatency(now)
+	l.lastWriteTime = now
+
+	err = l.currentSegmentWriter.sync()
+	l.syncLatency += (time.Since(l.lastWriteTime) - l.syncLatency) / 8
 
-	return l.currentSegmentID, l.currentSegmentWriter.sync()
+	return l.currentSegmentID, err
 }
 
 // rollSegment closes the current segment and opens a new one if the current segment is over
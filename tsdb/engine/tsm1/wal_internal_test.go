@@ -0,0 +1,32 @@
+package tsm1
+
+import (
+	"testing"
+	"time"
+)
+
+// Ensure the average fsync latency halves for every half-life the WAL is
+// idle, so compactions deferred by a burst of writes resume on a cold shard.
+func TestWAL_SyncLatency_Idle(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &WAL{
+		SyncLatencyHalfLife: time.Second,
+		syncLatency:         8 * time.Millisecond,
+		lastWriteTime:       now,
+	}
+
+	for _, tt := range []struct {
+		idle time.Duration
+		exp  time.Duration
+	}{
+		{idle: 0, exp: 8 * time.Millisecond},
+		{idle: 500 * time.Millisecond, exp: 8 * time.Millisecond},
+		{idle: time.Second, exp: 4 * time.Millisecond},
+		{idle: 3 * time.Second, exp: time.Millisecond},
+		{idle: time.Hour, exp: 0},
+	} {
+		if got := l.idleSyncLatency(now.Add(tt.idle)); got != tt.exp {
+			t.Errorf("idle %s: unexpected sync latency: got %s, exp %s", tt.idle, got, tt.exp)
+		}
+	}
+}
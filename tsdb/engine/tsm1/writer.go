@@ -52,6 +52,24 @@ file, we can use the size to determine how much to read in a given IO.
 │ 2 bytes │ N bytes │1 byte│2 bytes│ 8 bytes │ 8 bytes │8 bytes │4 bytes │   │
 └─────────┴─────────┴──────┴───────┴─────────┴─────────┴────────┴────────┴───┘
 
+The index may be preceded by a bloom filter of the keys in the file followed
+by a section of block summaries (see summary.go).  The filter lets readers skip
+searching the index for keys the file does not contain.  Each section ends with
+its size and a magic number so readers that do not know about it ignore it.
+The filter also records the CRC32 of K and the bits, and is ignored unless it
+matches, so the end of a block that happens to look like a filter trailer is
+never used to skip keys.
+
+┌────────────────────────────────────────────────────┐
+│                    Bloom Filter                    │
+├──────┬──────────┬─────────────┬──────────┬─────────┤
+│  K   │   Bits   │    Size     │ Checksum │  Magic  │
+│1 byte│ N bytes  │   4 bytes   │ 4 bytes  │ 4 bytes │
+└──────┴──────────┴─────────────┴──────────┴─────────┘
+
+Blocks may be encrypted (see encryption.go), in which case the summaries are
+omitted.
+
 The last section is the footer that stores the offset of the start of the index.
 
 ┌─────────┐
@@ -65,14 +83,18 @@ The last section is the footer that stores the offset of the start of the index.
 import (
 	"bufio"
 	"bytes"
+	"crypto/cipher"
 	"encoding/binary"
 	"fmt"
 	"hash/crc32"
 	"io"
+	"math"
 	"os"
 	"sort"
 	"sync"
 	"time"
+
+	"github.com/influxdata/influxdb/pkg/bloom"
 )
 
 const (
@@ -96,6 +118,15 @@ const (
 
 	// max length of a key in an index entry (measurement + tags)
 	maxKeyLength = (1 << (2 * 8)) - 1
+
+	// BloomMagicNumber marks the end of the bloom filter section.
+	BloomMagicNumber uint32 = 0x16D1424D
+
+	// Size in bytes of the bloom filter section trailer
+	bloomTrailerSize = 12
+
+	// False positive rate of the bloom filter of keys in a file
+	bloomFalsePositiveRate = 0.01
 )
 
 var (
@@ -399,14 +430,52 @@ type tsmWriter struct {
 	w       *bufio.Writer
 	index   IndexWriter
 	n       int64
+
+	// summaries holds the encoded summary of each block written.
+	summaries []byte
+
+	// cipher encrypts blocks when set.
+	cipher cipher.AEAD
 }
 
 func NewTSMWriter(w io.Writer) (TSMWriter, error) {
+	return NewEncryptedTSMWriter(w, nil)
+}
+
+// NewEncryptedTSMWriter returns a writer that encrypts blocks with aead.
+// Block summaries are not written since they would reveal block contents.
+// A nil aead writes an unencrypted file.
+func NewEncryptedTSMWriter(w io.Writer, aead cipher.AEAD) (TSMWriter, error) {
 	index := &directIndex{
 		blocks: map[string]*indexEntries{},
 	}
 
-	return &tsmWriter{wrapped: w, w: bufio.NewWriterSize(w, 4*1024*1024), index: index}, nil
+	return &tsmWriter{wrapped: w, w: bufio.NewWriterSize(w, 4*1024*1024), index: index, cipher: aead}, nil
+}
+
+// writeBlock writes the checksum of an encoded block of key followed by the
+// block, encrypted if the writer has a cipher.  It returns the number of
+// bytes written.
+func (t *tsmWriter) writeBlock(key string, block []byte) (int, error) {
+	var checksum [crc32.Size]byte
+	binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(block))
+
+	if t.cipher != nil {
+		var err error
+		if block, err = encryptBlock(t.cipher, key, t.n, block); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := t.w.Write(checksum[:]); err != nil {
+		return 0, err
+	}
+
+	n, err := t.w.Write(block)
+	if err != nil {
+		return 0, err
+	}
+	return n + len(checksum), nil
 }
 
 func (t *tsmWriter) writeHeader() error {
@@ -449,29 +518,38 @@ func (t *tsmWriter) Write(key string, values Values) error {
 		return err
 	}
 
-	var checksum [crc32.Size]byte
-	binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(block))
-
-	_, err = t.w.Write(checksum[:])
-	if err != nil {
-		return err
-	}
-
-	n, err := t.w.Write(block)
+	n, err := t.writeBlock(key, block)
 	if err != nil {
 		return err
 	}
-	n += len(checksum)
 
 	// Record this block in index
 	t.index.Add(key, blockType, values[0].UnixNano(), values[len(values)-1].UnixNano(), t.n, uint32(n))
 
+	if t.cipher == nil {
+		s := newBlockSummary(t.n, blockType, values)
+		t.summaries = s.AppendTo(t.summaries)
+	}
+
 	// Increment file position pointer
 	t.n += int64(n)
 	return nil
 }
 
 func (t *tsmWriter) WriteBlock(key string, minTime, maxTime int64, block []byte) error {
+	return t.writeBlockWithSummary(key, minTime, maxTime, block, nil)
+}
+
+// summaryBlockWriter is implemented by TSM writers that can record the
+// summary of a block written with WriteBlock when it is already known.
+type summaryBlockWriter interface {
+	writeBlockWithSummary(key string, minTime, maxTime int64, block []byte, s *BlockSummary) error
+}
+
+// writeBlockWithSummary writes block like WriteBlock and records s as its
+// summary.  Blocks are not decoded to summarize them, so a nil s writes the
+// block without a summary and queries read it instead.
+func (t *tsmWriter) writeBlockWithSummary(key string, minTime, maxTime int64, block []byte, s *BlockSummary) error {
 	// Nothing to write
 	if len(block) == 0 {
 		return nil
@@ -489,23 +567,20 @@ func (t *tsmWriter) WriteBlock(key string, minTime, maxTime int64, block []byte)
 		}
 	}
 
-	var checksum [crc32.Size]byte
-	binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(block))
-
-	_, err = t.w.Write(checksum[:])
+	n, err := t.writeBlock(key, block)
 	if err != nil {
 		return err
 	}
 
-	n, err := t.w.Write(block)
-	if err != nil {
-		return err
-	}
-	n += len(checksum)
-
 	// Record this block in index
 	t.index.Add(key, blockType, minTime, maxTime, t.n, uint32(n))
 
+	if t.cipher == nil && s != nil {
+		summary := *s
+		summary.Offset = t.n
+		t.summaries = summary.AppendTo(t.summaries)
+	}
+
 	// Increment file position pointer (checksum + block len)
 	t.n += int64(n)
 
@@ -515,12 +590,18 @@ func (t *tsmWriter) WriteBlock(key string, minTime, maxTime int64, block []byte)
 // WriteIndex writes the index section of the file.  If there are no index entries to write,
 // this returns ErrNoValues
 func (t *tsmWriter) WriteIndex() error {
-	indexPos := t.n
-
 	if t.index.KeyCount() == 0 {
 		return ErrNoValues
 	}
 
+	if err := t.writeBloomFilter(); err != nil {
+		return err
+	}
+	if err := t.writeSummaries(); err != nil {
+		return err
+	}
+	indexPos := t.n
+
 	// Write the index
 	if err := t.index.WriteTo(t.w); err != nil {
 		return err
@@ -534,6 +615,62 @@ func (t *tsmWriter) WriteIndex() error {
 	return err
 }
 
+// writeBloomFilter writes a bloom filter of all keys in the index.
+func (t *tsmWriter) writeBloomFilter() error {
+	keys := t.index.Keys()
+	m, k := bloom.Estimate(uint64(len(keys)), bloomFalsePositiveRate)
+	if k > math.MaxUint8 {
+		k = math.MaxUint8
+	}
+
+	f := bloom.NewFilter(m, k)
+	for _, key := range keys {
+		f.Insert([]byte(key))
+	}
+
+	checksum := crc32.NewIEEE()
+	checksum.Write([]byte{byte(k)})
+	checksum.Write(f.Bytes())
+
+	var trailer [bloomTrailerSize]byte
+	binary.BigEndian.PutUint32(trailer[0:4], uint32(len(f.Bytes())+1))
+	binary.BigEndian.PutUint32(trailer[4:8], checksum.Sum32())
+	binary.BigEndian.PutUint32(trailer[8:12], BloomMagicNumber)
+
+	if _, err := t.w.Write([]byte{byte(k)}); err != nil {
+		return err
+	}
+	if _, err := t.w.Write(f.Bytes()); err != nil {
+		return err
+	}
+	if _, err := t.w.Write(trailer[:]); err != nil {
+		return err
+	}
+	t.n += int64(1 + len(f.Bytes()) + len(trailer))
+	return nil
+}
+
+// writeSummaries writes the block summary section that precedes the index.
+func (t *tsmWriter) writeSummaries() error {
+	if len(t.summaries) == 0 {
+		return nil
+	}
+
+	var trailer [summaryTrailerSize]byte
+	binary.BigEndian.PutUint32(trailer[0:4], uint32(len(t.summaries)/blockSummarySize))
+	binary.BigEndian.PutUint32(trailer[4:8], SummaryMagicNumber)
+
+	if _, err := t.w.Write(t.summaries); err != nil {
+		return err
+	}
+	if _, err := t.w.Write(trailer[:]); err != nil {
+		return err
+	}
+	t.n += int64(len(t.summaries) + len(trailer))
+	t.summaries = nil
+	return nil
+}
+
 func (t *tsmWriter) Close() error {
 	if err := t.w.Flush(); err != nil {
 		return err
@@ -552,7 +689,8 @@ func (t *tsmWriter) Close() error {
 }
 
 func (t *tsmWriter) Size() uint32 {
-	return uint32(t.n) + t.index.Size()
+	m, _ := bloom.Estimate(uint64(t.index.KeyCount()), bloomFalsePositiveRate)
+	return uint32(t.n) + uint32(m/8) + uint32(len(t.summaries)) + t.index.Size()
 }
 
 // verifyVersion will verify that the reader's bytes are a TSM byte
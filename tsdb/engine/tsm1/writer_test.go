@@ -630,3 +630,88 @@ func TestTSMWriter_Write_MaxKey(t *testing.T) {
 		t.Fatalf("expected max key length error writing key: %v", err)
 	}
 }
+
+func TestTSMWriter_Write_Encrypted(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	f := MustTempFile(dir)
+
+	aead, err := tsm1.NewCipher(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("unexpected error creating cipher: %v", err)
+	}
+
+	w, err := tsm1.NewEncryptedTSMWriter(f, aead)
+	if err != nil {
+		t.Fatalf("unexpected error creating writer: %v", err)
+	}
+
+	values := []tsm1.Value{tsm1.NewValue(0, "secret"), tsm1.NewValue(1, "secret")}
+	if err := w.Write("cpu", values); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	if err := w.WriteIndex(); err != nil {
+		t.Fatalf("unexpected error writing index: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+	if bytes.Contains(b, []byte("secret")) {
+		t.Fatal("expected values to be encrypted")
+	}
+
+	// Without the key the index is readable but the blocks are not.
+	fd, err := os.Open(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error open file: %v", err)
+	}
+	r, err := tsm1.NewTSMReader(fd)
+	if err != nil {
+		t.Fatalf("unexpected error created reader: %v", err)
+	}
+	if !r.Contains("cpu") {
+		t.Fatal("expected key to be found")
+	}
+	if _, err := r.ReadAll("cpu"); err != tsm1.ErrBlockEncrypted {
+		t.Fatalf("unexpected error reading: got %v, exp %v", err, tsm1.ErrBlockEncrypted)
+	}
+	r.Close()
+
+	fd, err = os.Open(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error open file: %v", err)
+	}
+	r, err = tsm1.NewTSMReaderWithOptions(fd, tsm1.TSMReaderOptions{Cipher: aead})
+	if err != nil {
+		t.Fatalf("unexpected error created reader: %v", err)
+	}
+	defer r.Close()
+
+	readValues, err := r.ReadAll("cpu")
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+	if len(readValues) != len(values) {
+		t.Fatalf("read values length mismatch: got %v, exp %v", len(readValues), len(values))
+	}
+	for i, v := range values {
+		if v.Value() != readValues[i].Value() {
+			t.Fatalf("read value mismatch(%d): got %v, exp %v", i, readValues[i].Value(), v.Value())
+		}
+	}
+
+	// A block is bound to its key, so it is not readable as another key's.
+	entries := r.Entries("cpu")
+	if _, err := r.ReadAt("mem", &entries[0], nil); err == nil {
+		t.Fatal("expected error reading block as another key")
+	}
+
+	if err := r.Verify(); err != nil {
+		t.Fatalf("unexpected error verifying: %v", err)
+	}
+}
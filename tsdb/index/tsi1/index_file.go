@@ -0,0 +1,544 @@
+// Package tsi1 implements a disk-backed series index.  An index file holds the
+// keys of a set of series and, for each measurement, the series in it and the
+// series having each of its tag values.  Files are memory mapped so that only
+// the parts being queried need to be resident, and recently used postings
+// lists are kept decoded in an LRU cache.
+//
+// Index files do not replace the in-memory index of a database, which still
+// holds every series written or queried recently.  Shards write the series
+// evicted from the in-memory index after Data.SeriesIdleTimeout to an index
+// file, so heap usage is proportional to the active series rather than to
+// all series, and look up evicted series in it when queried.
+package tsi1 // import "github.com/influxdata/influxdb/tsdb/index/tsi1"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/mmap"
+)
+
+/*
+An index file is laid out as:
+
+┌────────┬─────────────┬────────────────┬──────────────┬─────────────────────┬─────────┐
+│ Magic  │ Series Keys │ Series Offsets │ Measurements │ Measurement Offsets │ Trailer │
+│4 bytes │             │8 bytes each    │              │8 bytes each         │36 bytes │
+└────────┴─────────────┴────────────────┴──────────────┴─────────────────────┴─────────┘
+
+Series keys are sorted and the ID of a series is its position in that order.
+Measurements are sorted by name.  Each key and name is stored as a uvarint
+length followed by its bytes.
+
+Each measurement is stored as:
+
+┌──────┬──────────┬────────────┬─────────────────────────────────────────────┐
+│ Name │ Postings │ Tag Key N  │                Tag Keys ...                 │
+└──────┴──────────┴────────────┴─────────────────────────────────────────────┘
+
+Each tag key is its name, the uvarint size of the rest of its entry, the
+uvarint number of values, an 8 byte offset per value relative to the end of the
+offsets, and the values.  Values are sorted and each is its name followed by
+its postings.
+
+A postings list is the uvarint size of the list, the uvarint number of series
+IDs and the uvarint deltas of the sorted IDs.
+
+The trailer holds the position and count of the series offsets, the position
+and count of the measurement offsets, and the magic number.
+*/
+
+const (
+	// IndexFileMagic begins and ends every index file.
+	IndexFileMagic uint32 = 0x54534931
+
+	// DefaultPostingsCacheSize is the number of series IDs held in the cache
+	// of decoded postings lists of each index file.
+	DefaultPostingsCacheSize = 1 << 20
+
+	indexFileHeaderSize  = 4
+	indexFileTrailerSize = 36
+)
+
+// ErrInvalidIndexFile is returned when opening a file that is not an index
+// file or has been truncated.
+var ErrInvalidIndexFile = errors.New("tsi1: invalid index file")
+
+// IndexFileWriter builds an index file from a set of series.
+type IndexFileWriter struct {
+	series map[string]indexSeries
+}
+
+type indexSeries struct {
+	name string
+	tags models.Tags
+}
+
+// NewIndexFileWriter returns a new, empty IndexFileWriter.
+func NewIndexFileWriter() *IndexFileWriter {
+	return &IndexFileWriter{series: make(map[string]indexSeries)}
+}
+
+// Add adds the series with the measurement name and tags to the file.
+func (w *IndexFileWriter) Add(name string, tags models.Tags) {
+	key := string(models.MakeKey([]byte(name), tags))
+	if _, ok := w.series[key]; !ok {
+		w.series[key] = indexSeries{name: name, tags: tags}
+	}
+}
+
+// WriteTo writes the index file to wr.
+func (w *IndexFileWriter) WriteTo(wr io.Writer) (int64, error) {
+	keys := make([]string, 0, len(w.series))
+	for k := range w.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Build the postings of each measurement and tag value.  IDs are added in
+	// increasing order so every list is already sorted.
+	type measurement struct {
+		ids  []uint64
+		tags map[string]map[string][]uint64
+	}
+	measurements := make(map[string]*measurement)
+	for id, key := range keys {
+		s := w.series[key]
+		m := measurements[s.name]
+		if m == nil {
+			m = &measurement{tags: make(map[string]map[string][]uint64)}
+			measurements[s.name] = m
+		}
+		m.ids = append(m.ids, uint64(id))
+
+		for k, v := range s.tags {
+			values := m.tags[k]
+			if values == nil {
+				values = make(map[string][]uint64)
+				m.tags[k] = values
+			}
+			values[v] = append(values[v], uint64(id))
+		}
+	}
+
+	buf := appendUint32(nil, IndexFileMagic)
+
+	offsets := make([]uint64, len(keys))
+	for i, k := range keys {
+		offsets[i] = uint64(len(buf))
+		buf = appendString(buf, k)
+	}
+	seriesPos := len(buf)
+	for _, off := range offsets {
+		buf = appendUint64(buf, off)
+	}
+
+	names := make([]string, 0, len(measurements))
+	for name := range measurements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	offsets = offsets[:0]
+	for _, name := range names {
+		m := measurements[name]
+		offsets = append(offsets, uint64(len(buf)))
+		buf = appendString(buf, name)
+		buf = appendPostings(buf, m.ids)
+
+		tagKeys := make([]string, 0, len(m.tags))
+		for k := range m.tags {
+			tagKeys = append(tagKeys, k)
+		}
+		sort.Strings(tagKeys)
+
+		buf = appendUvarint(buf, uint64(len(tagKeys)))
+		for _, k := range tagKeys {
+			buf = appendString(buf, k)
+			entry := encodeTagValues(m.tags[k])
+			buf = appendUvarint(buf, uint64(len(entry)))
+			buf = append(buf, entry...)
+		}
+	}
+	measurementPos := len(buf)
+	for _, off := range offsets {
+		buf = appendUint64(buf, off)
+	}
+
+	buf = appendUint64(buf, uint64(seriesPos))
+	buf = appendUint64(buf, uint64(len(keys)))
+	buf = appendUint64(buf, uint64(measurementPos))
+	buf = appendUint64(buf, uint64(len(names)))
+	buf = appendUint32(buf, IndexFileMagic)
+
+	n, err := wr.Write(buf)
+	return int64(n), err
+}
+
+// encodeTagValues encodes the values of a tag key and their postings.
+func encodeTagValues(values map[string][]uint64) []byte {
+	names := make([]string, 0, len(values))
+	for v := range values {
+		names = append(names, v)
+	}
+	sort.Strings(names)
+
+	var data []byte
+	offsets := make([]uint64, len(names))
+	for i, v := range names {
+		offsets[i] = uint64(len(data))
+		data = appendString(data, v)
+		data = appendPostings(data, values[v])
+	}
+
+	b := appendUvarint(nil, uint64(len(names)))
+	for _, off := range offsets {
+		b = appendUint64(b, off)
+	}
+	return append(b, data...)
+}
+
+// IndexFile is a read-only, memory mapped index file.
+type IndexFile struct {
+	path string
+	data []byte
+
+	seriesOffsets      []byte
+	measurementOffsets []byte
+
+	cache *postingsCache
+}
+
+// OpenIndexFile opens the index file at path.  cacheSize is the number of
+// series IDs to keep in the cache of decoded postings lists.
+func OpenIndexFile(path string, cacheSize int) (*IndexFile, error) {
+	data, err := mmap.Map(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &IndexFile{
+		path:  path,
+		data:  data,
+		cache: newPostingsCache(cacheSize),
+	}
+	if err := f.init(); err != nil {
+		mmap.Unmap(data)
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *IndexFile) init() error {
+	b := f.data
+	if len(b) < indexFileHeaderSize+indexFileTrailerSize {
+		return ErrInvalidIndexFile
+	}
+	if binary.BigEndian.Uint32(b[0:4]) != IndexFileMagic || binary.BigEndian.Uint32(b[len(b)-4:]) != IndexFileMagic {
+		return ErrInvalidIndexFile
+	}
+
+	end := uint64(len(b) - indexFileTrailerSize)
+	t := b[end:]
+	seriesPos, seriesN := binary.BigEndian.Uint64(t[0:8]), binary.BigEndian.Uint64(t[8:16])
+	measurementPos, measurementN := binary.BigEndian.Uint64(t[16:24]), binary.BigEndian.Uint64(t[24:32])
+
+	if seriesPos > end || seriesN > (end-seriesPos)/8 {
+		return ErrInvalidIndexFile
+	} else if measurementPos > end || measurementN > (end-measurementPos)/8 {
+		return ErrInvalidIndexFile
+	}
+
+	f.seriesOffsets = b[seriesPos : seriesPos+seriesN*8]
+	f.measurementOffsets = b[measurementPos : measurementPos+measurementN*8]
+	return nil
+}
+
+// Close unmaps the file.
+func (f *IndexFile) Close() error {
+	data := f.data
+	f.data, f.seriesOffsets, f.measurementOffsets = nil, nil, nil
+	return mmap.Unmap(data)
+}
+
+// Path returns the path the file was opened with.
+func (f *IndexFile) Path() string { return f.path }
+
+// SeriesN returns the number of series in the file.
+func (f *IndexFile) SeriesN() int { return len(f.seriesOffsets) / 8 }
+
+// SeriesKey returns the key of the series with id, or an empty string if
+// there is no such series.
+func (f *IndexFile) SeriesKey(id uint64) string {
+	if id >= uint64(f.SeriesN()) {
+		return ""
+	}
+	return string(f.seriesKey(int(id)))
+}
+
+func (f *IndexFile) seriesKey(i int) []byte {
+	key, _, _ := readBytes(f.data, binary.BigEndian.Uint64(f.seriesOffsets[i*8:]))
+	return key
+}
+
+// SeriesID returns the ID of the series with key.
+func (f *IndexFile) SeriesID(key string) (uint64, bool) {
+	n := f.SeriesN()
+	k := []byte(key)
+	i := sort.Search(n, func(i int) bool { return bytes.Compare(f.seriesKey(i), k) >= 0 })
+	if i == n || !bytes.Equal(f.seriesKey(i), k) {
+		return 0, false
+	}
+	return uint64(i), true
+}
+
+// MeasurementNames returns the sorted names of the measurements in the file.
+func (f *IndexFile) MeasurementNames() []string {
+	n := len(f.measurementOffsets) / 8
+	names := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		name, _ := f.measurementAt(i)
+		names = append(names, string(name))
+	}
+	return names
+}
+
+// HasMeasurement returns true if the file has series in the measurement.
+func (f *IndexFile) HasMeasurement(name string) bool {
+	_, ok := f.measurement(name)
+	return ok
+}
+
+// measurementAt returns the name of the i'th measurement and the rest of its
+// entry.
+func (f *IndexFile) measurementAt(i int) ([]byte, []byte) {
+	name, rest, _ := readBytes(f.data, binary.BigEndian.Uint64(f.measurementOffsets[i*8:]))
+	return name, rest
+}
+
+// measurement returns the entry of the named measurement following its name.
+func (f *IndexFile) measurement(name string) ([]byte, bool) {
+	n := len(f.measurementOffsets) / 8
+	k := []byte(name)
+	i := sort.Search(n, func(i int) bool {
+		name, _ := f.measurementAt(i)
+		return bytes.Compare(name, k) >= 0
+	})
+	if i == n {
+		return nil, false
+	}
+	if name, rest := f.measurementAt(i); bytes.Equal(name, k) {
+		return rest, true
+	}
+	return nil, false
+}
+
+// MeasurementSeriesIDs returns the sorted IDs of the series in the
+// measurement.  The returned slice must not be modified.
+func (f *IndexFile) MeasurementSeriesIDs(name string) []uint64 {
+	cacheKey := name
+	if ids, ok := f.cache.get(cacheKey); ok {
+		return ids
+	}
+
+	b, ok := f.measurement(name)
+	if !ok {
+		return nil
+	}
+	postings, _, ok := readPostings(b)
+	if !ok {
+		return nil
+	}
+	ids := decodePostings(postings)
+	f.cache.add(cacheKey, ids)
+	return ids
+}
+
+// tagKeys calls fn with the name and values entry of each tag key of the
+// measurement until fn returns false.
+func (f *IndexFile) tagKeys(name string, fn func(key, values []byte) bool) {
+	b, ok := f.measurement(name)
+	if !ok {
+		return
+	}
+	if _, b, ok = readPostings(b); !ok {
+		return
+	}
+
+	n, b, ok := readUvarint(b)
+	for i := uint64(0); ok && i < n; i++ {
+		var key, values []byte
+		if key, b, ok = readBytes(b, 0); !ok {
+			return
+		}
+		if values, b, ok = readBytes(b, 0); !ok {
+			return
+		}
+		if !fn(key, values) {
+			return
+		}
+	}
+}
+
+// TagKeys returns the sorted tag keys of the measurement.
+func (f *IndexFile) TagKeys(name string) []string {
+	var keys []string
+	f.tagKeys(name, func(key, _ []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	return keys
+}
+
+// tagValues returns the number of values of a tag key, their offsets and
+// the data the offsets point into.
+func (f *IndexFile) tagValues(name, key string) (int, []byte, []byte) {
+	var entry []byte
+	k := []byte(key)
+	f.tagKeys(name, func(key, values []byte) bool {
+		if bytes.Equal(key, k) {
+			entry = values
+			return false
+		}
+		return true
+	})
+
+	n, b, ok := readUvarint(entry)
+	if !ok || n > uint64(len(b)/8) {
+		return 0, nil, nil
+	}
+	return int(n), b[:n*8], b[n*8:]
+}
+
+// TagValues returns the sorted values of the tag key in the measurement.
+func (f *IndexFile) TagValues(name, key string) []string {
+	n, offsets, data := f.tagValues(name, key)
+	values := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		v, _, _ := readBytes(data, binary.BigEndian.Uint64(offsets[i*8:]))
+		values = append(values, string(v))
+	}
+	return values
+}
+
+// TagValueSeriesIDs returns the sorted IDs of the series in the measurement
+// with the tag value.  The returned slice must not be modified.
+func (f *IndexFile) TagValueSeriesIDs(name, key, value string) []uint64 {
+	cacheKey := name + "\x00" + key + "\x00" + value
+	if ids, ok := f.cache.get(cacheKey); ok {
+		return ids
+	}
+
+	n, offsets, data := f.tagValues(name, key)
+	v := []byte(value)
+	valueAt := func(i int) ([]byte, []byte) {
+		v, rest, _ := readBytes(data, binary.BigEndian.Uint64(offsets[i*8:]))
+		return v, rest
+	}
+
+	i := sort.Search(n, func(i int) bool {
+		value, _ := valueAt(i)
+		return bytes.Compare(value, v) >= 0
+	})
+	if i == n {
+		return nil
+	}
+	value2, rest := valueAt(i)
+	if !bytes.Equal(value2, v) {
+		return nil
+	}
+
+	postings, _, ok := readPostings(rest)
+	if !ok {
+		return nil
+	}
+	ids := decodePostings(postings)
+	f.cache.add(cacheKey, ids)
+	return ids
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendPostings(b []byte, ids []uint64) []byte {
+	enc := appendUvarint(nil, uint64(len(ids)))
+	var prev uint64
+	for _, id := range ids {
+		enc = appendUvarint(enc, id-prev)
+		prev = id
+	}
+	b = appendUvarint(b, uint64(len(enc)))
+	return append(b, enc...)
+}
+
+func readUvarint(b []byte) (uint64, []byte, bool) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, false
+	}
+	return v, b[n:], true
+}
+
+// readBytes reads a length prefixed byte slice starting at pos in b and
+// returns it and the bytes following it.
+func readBytes(b []byte, pos uint64) ([]byte, []byte, bool) {
+	if pos > uint64(len(b)) {
+		return nil, nil, false
+	}
+	n, rest, ok := readUvarint(b[pos:])
+	if !ok || n > uint64(len(rest)) {
+		return nil, nil, false
+	}
+	return rest[:n], rest[n:], true
+}
+
+// readPostings returns the encoded postings list at the start of b and the
+// bytes following it.
+func readPostings(b []byte) ([]byte, []byte, bool) {
+	return readBytes(b, 0)
+}
+
+// decodePostings decodes an encoded postings list.  A corrupt list decodes to
+// the IDs read before the corruption.
+func decodePostings(b []byte) []uint64 {
+	n, b, ok := readUvarint(b)
+	if !ok || n > uint64(len(b)) {
+		return nil
+	}
+
+	ids := make([]uint64, 0, n)
+	var id uint64
+	for i := uint64(0); i < n; i++ {
+		var delta uint64
+		if delta, b, ok = readUvarint(b); !ok {
+			break
+		}
+		id += delta
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,152 @@
+package tsi1_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/tsdb/index/tsi1"
+)
+
+func TestIndexFile(t *testing.T) {
+	w := tsi1.NewIndexFileWriter()
+	w.Add("cpu", models.Tags{"host": "serverB", "region": "east"})
+	w.Add("cpu", models.Tags{"host": "serverA", "region": "west"})
+	w.Add("cpu", models.Tags{"host": "serverA", "region": "east"})
+	w.Add("mem", models.Tags{"host": "serverA"})
+	w.Add("disk", nil)
+	w.Add("cpu", models.Tags{"host": "serverA", "region": "west"})
+
+	f := MustOpenIndexFile(w, tsi1.DefaultPostingsCacheSize)
+	defer f.Close()
+
+	if got, exp := f.SeriesN(), 5; got != exp {
+		t.Fatalf("series count mismatch: got %v, exp %v", got, exp)
+	}
+
+	// Series IDs are assigned in key order.
+	for i, key := range []string{
+		"cpu,host=serverA,region=east",
+		"cpu,host=serverA,region=west",
+		"cpu,host=serverB,region=east",
+		"disk",
+		"mem,host=serverA",
+	} {
+		if got := f.SeriesKey(uint64(i)); got != key {
+			t.Fatalf("series key mismatch(%d): got %v, exp %v", i, got, key)
+		}
+		if id, ok := f.SeriesID(key); !ok || id != uint64(i) {
+			t.Fatalf("series id mismatch(%s): got %v/%v, exp %v", key, id, ok, i)
+		}
+	}
+	if _, ok := f.SeriesID("cpu,host=serverC"); ok {
+		t.Fatal("expected series to not exist")
+	}
+	if got := f.SeriesKey(5); got != "" {
+		t.Fatalf("expected no series key, got %v", got)
+	}
+
+	if got, exp := f.MeasurementNames(), []string{"cpu", "disk", "mem"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("measurement names mismatch: got %v, exp %v", got, exp)
+	}
+	if f.HasMeasurement("net") {
+		t.Fatal("expected measurement to not exist")
+	}
+
+	if got, exp := f.MeasurementSeriesIDs("cpu"), []uint64{0, 1, 2}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("measurement series mismatch: got %v, exp %v", got, exp)
+	}
+	if got := f.MeasurementSeriesIDs("net"); len(got) != 0 {
+		t.Fatalf("expected no series, got %v", got)
+	}
+
+	if got, exp := f.TagKeys("cpu"), []string{"host", "region"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("tag keys mismatch: got %v, exp %v", got, exp)
+	}
+	if got := f.TagKeys("disk"); len(got) != 0 {
+		t.Fatalf("expected no tag keys, got %v", got)
+	}
+
+	if got, exp := f.TagValues("cpu", "host"), []string{"serverA", "serverB"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("tag values mismatch: got %v, exp %v", got, exp)
+	}
+
+	for _, tt := range []struct {
+		name, key, value string
+		exp              []uint64
+	}{
+		{"cpu", "host", "serverA", []uint64{0, 1}},
+		{"cpu", "host", "serverB", []uint64{2}},
+		{"cpu", "region", "east", []uint64{0, 2}},
+		{"cpu", "region", "west", []uint64{1}},
+		{"mem", "host", "serverA", []uint64{4}},
+		{"cpu", "host", "serverC", nil},
+		{"cpu", "rack", "1", nil},
+	} {
+		// Read twice so the second read comes from the cache.
+		for i := 0; i < 2; i++ {
+			if got := f.TagValueSeriesIDs(tt.name, tt.key, tt.value); !reflect.DeepEqual(got, tt.exp) {
+				t.Fatalf("%s %s=%s: series mismatch: got %v, exp %v", tt.name, tt.key, tt.value, got, tt.exp)
+			}
+		}
+	}
+}
+
+func TestIndexFile_Invalid(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "index")
+	if err := ioutil.WriteFile(path, []byte("not an index file, but long enough to have a trailer"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tsi1.OpenIndexFile(path, 0); err != tsi1.ErrInvalidIndexFile {
+		t.Fatalf("unexpected error: got %v, exp %v", err, tsi1.ErrInvalidIndexFile)
+	}
+}
+
+// IndexFile is a test wrapper for tsi1.IndexFile that removes its file on close.
+type IndexFile struct {
+	*tsi1.IndexFile
+	dir string
+}
+
+// MustOpenIndexFile writes w to a temporary file and opens it.
+func MustOpenIndexFile(w *tsi1.IndexFileWriter, cacheSize int) *IndexFile {
+	dir := MustTempDir()
+	path := filepath.Join(dir, "index")
+
+	fd, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := w.WriteTo(fd); err != nil {
+		panic(err)
+	}
+	if err := fd.Close(); err != nil {
+		panic(err)
+	}
+
+	f, err := tsi1.OpenIndexFile(path, cacheSize)
+	if err != nil {
+		panic(err)
+	}
+	return &IndexFile{IndexFile: f, dir: dir}
+}
+
+func (f *IndexFile) Close() error {
+	defer os.RemoveAll(f.dir)
+	return f.IndexFile.Close()
+}
+
+func MustTempDir() string {
+	dir, err := ioutil.TempDir("", "tsi1-test")
+	if err != nil {
+		panic(err)
+	}
+	return dir
+}
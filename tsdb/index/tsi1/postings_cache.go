@@ -0,0 +1,77 @@
+package tsi1
+
+import (
+	"container/list"
+	"sync"
+)
+
+// postingsCache is an LRU cache of decoded postings lists.  Its size is the
+// total number of series IDs in the lists it holds.
+type postingsCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	ll      *list.List
+	items   map[string]*list.Element
+}
+
+type postingsCacheEntry struct {
+	key string
+	ids []uint64
+}
+
+// newPostingsCache returns a cache holding up to maxSize series IDs.  A
+// maxSize of zero or less disables caching.
+func newPostingsCache(maxSize int) *postingsCache {
+	return &postingsCache{
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+func (c *postingsCache) get(key string) ([]uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*postingsCacheEntry).ids, true
+}
+
+// add adds the list to the cache and evicts the least recently used lists
+// until the cache is within its size.  Lists larger than the cache are not
+// added.
+func (c *postingsCache) add(key string, ids []uint64) {
+	if len(ids) > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; ok {
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&postingsCacheEntry{key: key, ids: ids})
+	c.size += len(ids)
+
+	for c.size > c.maxSize {
+		e := c.ll.Back()
+		entry := e.Value.(*postingsCacheEntry)
+		c.ll.Remove(e)
+		delete(c.items, entry.key)
+		c.size -= len(entry.ids)
+	}
+}
+
+// len returns the number of lists in the cache.
+func (c *postingsCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
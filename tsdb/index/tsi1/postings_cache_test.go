@@ -0,0 +1,33 @@
+package tsi1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPostingsCache_Evict(t *testing.T) {
+	c := newPostingsCache(4)
+	c.add("a", []uint64{1, 2})
+	c.add("b", []uint64{3})
+	c.add("c", []uint64{4, 5, 6, 7, 8})
+
+	if got, exp := c.len(), 2; got != exp {
+		t.Fatalf("cache length mismatch: got %v, exp %v", got, exp)
+	}
+
+	// Touch a so that b is the least recently used.
+	if ids, ok := c.get("a"); !ok || !reflect.DeepEqual(ids, []uint64{1, 2}) {
+		t.Fatalf("unexpected cache entry: %v %v", ids, ok)
+	}
+
+	c.add("d", []uint64{9, 10})
+	if _, ok := c.get("b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	if _, ok := c.get("d"); !ok {
+		t.Fatal("expected d to be cached")
+	}
+}
@@ -1,11 +1,13 @@
 package tsdb
 
 import (
+	"errors"
 	"expvar"
 	"fmt"
 	"regexp"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/influxdb"
@@ -24,6 +26,8 @@ const (
 
 	statDatabaseSeries       = "numSeries"       // number of series in this database
 	statDatabaseMeasurements = "numMeasurements" // number of measurements in this database
+	statDatabaseMemoryBytes  = "memBytes"        // approximate bytes of series keys and tags held by the index
+	statDatabaseEvicted      = "seriesEvicted"   // number of idle series evicted from the index
 )
 
 // DatabaseIndex is the in memory index of a collection of measurements, time series, and their tags.
@@ -35,6 +39,19 @@ type DatabaseIndex struct {
 	series       map[string]*Series      // map series key to the Series object
 	lastID       uint64                  // last used series ID. They're in memory only for this shard
 
+	// seriesDropped is the number of series deleted from the series map since
+	// it was last allocated.  Go maps do not release memory as keys are deleted,
+	// so the map is copied once more series have been dropped than remain.
+	seriesDropped int
+
+	// shardSeriesN is the number of series assigned to each shard.
+	shardSeriesN map[uint64]int
+
+	// shardLoaded is the time, in nanoseconds, each shard was loaded into
+	// the index.  Series are not idle before the shards they were loaded
+	// from have been open for the idle timeout.
+	shardLoaded map[uint64]int64
+
 	name string // name of the database represented by this index
 
 	statMap *expvar.Map
@@ -45,6 +62,8 @@ func NewDatabaseIndex(name string) *DatabaseIndex {
 	return &DatabaseIndex{
 		measurements: make(map[string]*Measurement),
 		series:       make(map[string]*Series),
+		shardSeriesN: make(map[uint64]int),
+		shardLoaded:  make(map[uint64]int64),
 		name:         name,
 		statMap:      influxdb.NewStatistics("database:"+name, "database", map[string]string{"database": name}),
 	}
@@ -60,7 +79,7 @@ func (d *DatabaseIndex) Series(key string) *Series {
 
 func (d *DatabaseIndex) SeriesKeys() []string {
 	d.mu.RLock()
-	s := make([]string, len(d.series))
+	s := make([]string, 0, len(d.series))
 	for k := range d.series {
 		s = append(s, k)
 	}
@@ -132,6 +151,7 @@ func (d *DatabaseIndex) CreateSeriesIndexIfNotExists(measurementName string, ser
 	m.AddSeries(series)
 
 	d.statMap.Add(statDatabaseSeries, 1)
+	d.statMap.Add(statDatabaseMemoryBytes, int64(series.memSize()))
 
 	return series
 }
@@ -168,8 +188,10 @@ func (d *DatabaseIndex) CreateMeasurementIndexIfNotExists(name string) *Measurem
 // the given shardID
 func (d *DatabaseIndex) AssignShard(k string, shardID uint64) {
 	ss := d.Series(k)
-	if ss != nil {
-		ss.AssignShard(shardID)
+	if ss != nil && ss.assignShard(shardID) {
+		d.mu.Lock()
+		d.shardSeriesN[shardID]++
+		d.mu.Unlock()
 	}
 }
 
@@ -178,9 +200,10 @@ func (d *DatabaseIndex) AssignShard(k string, shardID uint64) {
 func (d *DatabaseIndex) UnassignShard(k string, shardID uint64) {
 	ss := d.Series(k)
 	if ss != nil {
-		if ss.Assigned(shardID) {
-			// Remove the shard from any series
-			ss.UnassignShard(shardID)
+		if ss.unassignShard(shardID) {
+			d.mu.Lock()
+			d.shardSeriesN[shardID]--
+			d.mu.Unlock()
 
 			// If this series no longer has shards assigned, remove the series
 			if ss.ShardN() == 0 {
@@ -197,8 +220,10 @@ func (d *DatabaseIndex) UnassignShard(k string, shardID uint64) {
 
 				// Remove the series key from the series index
 				d.mu.Lock()
-				delete(d.series, k)
-				d.statMap.Add(statDatabaseSeries, int64(-1))
+				if d.series[k] == ss {
+					d.removeSeries(ss)
+				}
+				d.shrink()
 				d.mu.Unlock()
 			}
 		}
@@ -212,6 +237,103 @@ func (d *DatabaseIndex) RemoveShard(shardID uint64) {
 	for _, k := range d.SeriesKeys() {
 		d.UnassignShard(k, shardID)
 	}
+
+	d.mu.Lock()
+	delete(d.shardSeriesN, shardID)
+	delete(d.shardLoaded, shardID)
+	d.mu.Unlock()
+}
+
+// touchShard records now, in nanoseconds, as the time the series of a shard
+// were loaded into the index.
+func (d *DatabaseIndex) touchShard(shardID uint64, now int64) {
+	d.mu.Lock()
+	d.shardLoaded[shardID] = now
+	d.mu.Unlock()
+}
+
+// EvictIdleSeries removes the series that have not been written or queried
+// since t from the index.  It returns the evicted series by the IDs of the
+// shards they were assigned to, so they can be reloaded when next queried.
+func (d *DatabaseIndex) EvictIdleSeries(t time.Time) map[uint64][]*Series {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cutoff := t.UnixNano()
+	evicted := make(map[uint64][]*Series)
+	for _, s := range d.series {
+		if atomic.LoadInt64(&s.lastAccess) >= cutoff {
+			continue
+		}
+
+		s.mu.RLock()
+		loaded := false
+		for id := range s.shardIDs {
+			loaded = loaded || d.shardLoaded[id] >= cutoff
+		}
+		if loaded {
+			s.mu.RUnlock()
+			continue
+		}
+		for id := range s.shardIDs {
+			evicted[id] = append(evicted[id], s)
+		}
+		s.mu.RUnlock()
+
+		d.unassignAllShards(s)
+		s.measurement.DropSeries(s)
+		d.removeSeries(s)
+		d.statMap.Add(statDatabaseEvicted, 1)
+
+		if !s.measurement.HasSeries() {
+			d.dropMeasurement(s.measurement.Name)
+		}
+	}
+	d.shrink()
+
+	return evicted
+}
+
+// ShardSeriesN returns the number of series assigned to a shard.
+func (d *DatabaseIndex) ShardSeriesN(shardID uint64) int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.shardSeriesN[shardID]
+}
+
+// removeSeries deletes s from the series map and updates the statistics.
+// The caller must hold the write lock.
+func (d *DatabaseIndex) removeSeries(s *Series) {
+	delete(d.series, s.Key)
+	d.seriesDropped++
+	d.statMap.Add(statDatabaseSeries, -1)
+	d.statMap.Add(statDatabaseMemoryBytes, -int64(s.memSize()))
+}
+
+// shrink reallocates the series map once more series have been dropped from
+// it than remain, releasing the memory held by the deleted entries.
+// The caller must hold the write lock.
+func (d *DatabaseIndex) shrink() {
+	if d.seriesDropped <= len(d.series) {
+		return
+	}
+
+	series := make(map[string]*Series, len(d.series))
+	for k, s := range d.series {
+		series[k] = s
+	}
+	d.series = series
+	d.seriesDropped = 0
+}
+
+// unassignAllShards removes the series from the per-shard series counts.
+// The caller must hold the write lock.
+func (d *DatabaseIndex) unassignAllShards(s *Series) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for id := range s.shardIDs {
+		d.shardSeriesN[id]--
+	}
 }
 
 // TagsForSeries returns the tag map for the passed in series
@@ -432,10 +554,11 @@ func (d *DatabaseIndex) dropMeasurement(name string) {
 
 	delete(d.measurements, name)
 	for _, s := range m.seriesByID {
-		delete(d.series, s.Key)
+		d.unassignAllShards(s)
+		d.removeSeries(s)
 	}
+	d.shrink()
 
-	d.statMap.Add(statDatabaseSeries, int64(-len(m.seriesByID)))
 	d.statMap.Add(statDatabaseMeasurements, -1)
 }
 
@@ -444,19 +567,15 @@ func (d *DatabaseIndex) DropSeries(keys []string) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	var (
-		mToDelete = map[string]struct{}{}
-		nDeleted  int64
-	)
-
+	mToDelete := map[string]struct{}{}
 	for _, k := range keys {
 		series := d.series[k]
 		if series == nil {
 			continue
 		}
 		series.measurement.DropSeries(series)
-		delete(d.series, k)
-		nDeleted++
+		d.unassignAllShards(series)
+		d.removeSeries(series)
 
 		// If there are no more series in the measurement then we'll
 		// remove it.
@@ -468,7 +587,7 @@ func (d *DatabaseIndex) DropSeries(keys []string) {
 	for mname := range mToDelete {
 		d.dropMeasurement(mname)
 	}
-	d.statMap.Add(statDatabaseSeries, -nDeleted)
+	d.shrink()
 }
 
 const (
@@ -488,6 +607,10 @@ type Measurement struct {
 	measurement         *Measurement
 	seriesByTagKeyValue map[string]map[string]SeriesIDs // map from tag key to value to sorted set of series ids
 	seriesIDs           SeriesIDs                       // sorted list of series IDs in this measurement
+
+	// seriesDropped is the number of series removed since the in-memory index
+	// fields were last allocated.
+	seriesDropped int
 }
 
 // NewMeasurement allocates and initializes a new Measurement.
@@ -641,7 +764,46 @@ func (m *Measurement) DropSeries(series *Series) {
 		}
 	}
 
-	return
+	// Once more series have been dropped than remain, copy the index so the
+	// memory of the dropped series is released.
+	m.seriesDropped++
+	if m.seriesDropped > len(m.seriesByID) {
+		m.shrink()
+	}
+}
+
+// shrink reallocates the in-memory index fields of the measurement to fit
+// the remaining series.  The caller must hold the write lock.
+func (m *Measurement) shrink() {
+	seriesByID := make(map[uint64]*Series, len(m.seriesByID))
+	for id, s := range m.seriesByID {
+		seriesByID[id] = s
+	}
+	m.seriesByID = seriesByID
+	m.seriesIDs = append(make(SeriesIDs, 0, len(m.seriesIDs)), m.seriesIDs...)
+
+	seriesByTagKeyValue := make(map[string]map[string]SeriesIDs, len(m.seriesByTagKeyValue))
+	for k, values := range m.seriesByTagKeyValue {
+		other := make(map[string]SeriesIDs, len(values))
+		for v, ids := range values {
+			other[v] = append(make(SeriesIDs, 0, len(ids)), ids...)
+		}
+		seriesByTagKeyValue[k] = other
+	}
+	m.seriesByTagKeyValue = seriesByTagKeyValue
+	m.seriesDropped = 0
+}
+
+// hasShard returns true if any series of the measurement is assigned to shardID.
+func (m *Measurement) hasShard(shardID uint64) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, s := range m.seriesByID {
+		if s.Assigned(shardID) {
+			return true
+		}
+	}
+	return false
 }
 
 // filters walks the where clause of a select statement and returns a map with all series ids
@@ -693,9 +855,11 @@ func (m *Measurement) TagSets(dimensions []string, condition influxql.Expr) ([]*
 	// For every series, get the tag values for the requested tag keys i.e. dimensions. This is the
 	// TagSet for that series. Series with the same TagSet are then grouped together, because for the
 	// purpose of GROUP BY they are part of the same composite series.
+	now := time.Now().UnixNano()
 	tagSets := make(map[string]*influxql.TagSet)
 	for id, filter := range filters {
 		s := m.seriesByID[id]
+		s.touch(now)
 		tags := make(map[string]string, len(dimensions))
 
 		// Build the TagSet for this series.
@@ -982,6 +1146,30 @@ func (fe FilterExprs) Len() int {
 	return len(fe)
 }
 
+// seriesIDsByCondition returns the IDs of the series matching condition, which
+// may only refer to tags.  All series are returned if condition is nil.
+func (m *Measurement) seriesIDsByCondition(condition influxql.Expr) (SeriesIDs, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if condition == nil {
+		return m.seriesIDs, nil
+	}
+
+	ids, filters, err := m.walkWhereForSeriesIds(condition)
+	if err != nil {
+		return nil, err
+	}
+
+	// Boolean literal true filters are returned for `WHERE tagKey = 'tagVal'`
+	// expressions.  Any other filter refers to a field.
+	filters.DeleteBoolLiteralTrues()
+	if filters.Len() > 0 {
+		return nil, errors.New("fields not supported in WHERE clause")
+	}
+	return ids, nil
+}
+
 // walkWhereForSeriesIds recursively walks the WHERE clause and returns an ordered set of series IDs and
 // a map from those series IDs to filter expressions that should be used to limit points returned in
 // the final query result.
@@ -1430,6 +1618,10 @@ func (a Measurements) union(other Measurements) Measurements {
 
 // Series belong to a Measurement and represent unique time series in a database
 type Series struct {
+	// lastAccess is the last time, in nanoseconds, the series was written or
+	// queried.  It is accessed atomically and kept first for 64-bit alignment.
+	lastAccess int64
+
 	mu          sync.RWMutex
 	Key         string
 	Tags        map[string]string
@@ -1448,15 +1640,35 @@ func NewSeries(key string, tags map[string]string) *Series {
 }
 
 func (s *Series) AssignShard(shardID uint64) {
+	s.assignShard(shardID)
+}
+
+// assignShard assigns the series to a shard and returns true if it was not
+// assigned to the shard already.
+func (s *Series) assignShard(shardID uint64) bool {
 	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shardIDs[shardID] {
+		return false
+	}
 	s.shardIDs[shardID] = true
-	s.mu.Unlock()
+	return true
 }
 
 func (s *Series) UnassignShard(shardID uint64) {
+	s.unassignShard(shardID)
+}
+
+// unassignShard removes the series from a shard and returns true if it was
+// assigned to the shard.
+func (s *Series) unassignShard(shardID uint64) bool {
 	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.shardIDs[shardID] {
+		return false
+	}
 	delete(s.shardIDs, shardID)
-	s.mu.Unlock()
+	return true
 }
 
 func (s *Series) Assigned(shardID uint64) bool {
@@ -1473,6 +1685,21 @@ func (s *Series) ShardN() int {
 	return n
 }
 
+// touch records now, in nanoseconds, as the last time the series was written
+// or queried.
+func (s *Series) touch(now int64) {
+	atomic.StoreInt64(&s.lastAccess, now)
+}
+
+// memSize returns the approximate number of bytes of the key and tags of the series.
+func (s *Series) memSize() int {
+	n := len(s.Key)
+	for k, v := range s.Tags {
+		n += len(k) + len(v)
+	}
+	return n
+}
+
 // MarshalBinary encodes the object to a binary format.
 func (s *Series) MarshalBinary() ([]byte, error) {
 	s.mu.RLock()
@@ -1695,6 +1922,22 @@ func (m *Measurement) TagValues(key string) []string {
 	return values
 }
 
+// TagValueN returns the number of values of the given tag key.
+func (m *Measurement) TagValueN(key string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.seriesByTagKeyValue[key])
+}
+
+// HasTagValue returns true if a series in this measurement has the value for
+// the given tag key.
+func (m *Measurement) HasTagValue(key, value string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.seriesByTagKeyValue[key][value]
+	return ok
+}
+
 // SetFieldName adds the field name to the measurement.
 func (m *Measurement) SetFieldName(name string) {
 	m.mu.RLock()
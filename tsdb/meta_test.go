@@ -3,6 +3,8 @@ package tsdb_test
 import (
 	"bytes"
 	"fmt"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/influxdata/influxdb/influxql"
@@ -92,6 +94,43 @@ func Test_SeriesIDs_Reject(t *testing.T) {
 }
 
 // Ensure tags can be marshaled into a byte slice.
+// Ensure dropping series removes them from the measurement and tag indexes.
+func TestDatabaseIndex_DropSeries(t *testing.T) {
+	idx := tsdb.NewDatabaseIndex("db0")
+	var keys []string
+	for i := 0; i < 10; i++ {
+		region := "uswest"
+		if i%2 == 0 {
+			region = "useast"
+		}
+		tags := map[string]string{"host": fmt.Sprintf("server%d", i), "region": region}
+		s := tsdb.NewSeries(fmt.Sprintf("cpu,host=server%d,region=%s", i, region), tags)
+		idx.CreateSeriesIndexIfNotExists("cpu", s)
+		keys = append(keys, s.Key)
+	}
+
+	// Drop enough series that the index is reallocated.
+	idx.DropSeries(keys[:7])
+
+	m := idx.Measurement("cpu")
+	hosts := m.TagValues("host")
+	sort.Strings(hosts)
+	if n := idx.SeriesN(); n != 3 {
+		t.Fatalf("unexpected series count: %d", n)
+	} else if exp, got := []string{"server7", "server8", "server9"}, hosts; !reflect.DeepEqual(exp, got) {
+		t.Fatalf("unexpected host values: exp=%v, got=%v", exp, got)
+	} else if !m.HasTagValue("region", "uswest") || !m.HasTagValue("region", "useast") {
+		t.Fatal("expected both regions to remain")
+	}
+
+	idx.DropSeries(keys[7:])
+	if m := idx.Measurement("cpu"); m != nil {
+		t.Fatal("expected measurement to be dropped")
+	} else if n := idx.SeriesN(); n != 0 {
+		t.Fatalf("unexpected series count: %d", n)
+	}
+}
+
 func TestMarshalTags(t *testing.T) {
 	for i, tt := range []struct {
 		tags   map[string]string
@@ -10,27 +10,38 @@ import (
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/tsdb/index/tsi1"
 	internal "github.com/influxdata/influxdb/tsdb/internal"
 )
 
 const (
-	statWriteReq        = "writeReq"
-	statSeriesCreate    = "seriesCreate"
-	statFieldsCreate    = "fieldsCreate"
-	statWritePointsFail = "writePointsFail"
-	statWritePointsOK   = "writePointsOk"
-	statWriteBytes      = "writeBytes"
+	statWriteReq           = "writeReq"
+	statSeriesCreate       = "seriesCreate"
+	statFieldsCreate       = "fieldsCreate"
+	statWritePointsFail    = "writePointsFail"
+	statWritePointsOK      = "writePointsOk"
+	statWritePointsDropped = "writePointsDropped"
+	statWritePointsCoerced = "writePointsCoerced" // Points with field values coerced to the stored type.
+	statWriteBytes         = "writeBytes"
+	statWritePointsBytes   = "writePointsBytes" // Bytes of line protocol of the points written.
+	statQueryReq           = "queryReq"         // Iterators created for queries.
 )
 
+// evictedIndexFileName is the name of the file in the shard directory that
+// indexes the series of the shard evicted from the in-memory index.
+const evictedIndexFileName = "evicted.tsi"
+
 var (
 	// ErrFieldOverflow is returned when too many fields are created on a measurement.
 	ErrFieldOverflow = errors.New("field overflow")
@@ -48,6 +59,9 @@ var (
 	// ErrEngineClosed is returned when a caller attempts indirectly to
 	// access the shard's underlying engine.
 	ErrEngineClosed = errors.New("engine is closed")
+
+	// ErrShardReadOnly is returned when writing to a read-only shard.
+	ErrShardReadOnly = errors.New("shard is read-only")
 )
 
 // A ShardError implements the error interface, and contains extra
@@ -69,11 +83,27 @@ func (e ShardError) Error() string {
 	return fmt.Sprintf("[shard %d] %s", e.id, e.Err)
 }
 
+// PartialWriteError is returned when some points of a write were dropped and
+// the rest were written.
+type PartialWriteError struct {
+	Reason  string
+	Dropped int
+}
+
+func (e PartialWriteError) Error() string {
+	return fmt.Sprintf("partial write: %s dropped=%d", e.Reason, e.Dropped)
+}
+
 // Shard represents a self-contained time series database. An inverted index of
 // the measurement and tag data is kept along with the raw time series data.
 // Data can be split across many shards. The query engine in TSDB is responsible
 // for combining the output of many shards into a single query result.
 type Shard struct {
+	// generation is incremented after every write to or delete from the
+	// shard.  It is accessed atomically so it is kept first in the struct to
+	// be 64-bit aligned.
+	generation uint64
+
 	index   *DatabaseIndex
 	path    string
 	walPath string
@@ -87,6 +117,22 @@ type Shard struct {
 	mu     sync.RWMutex
 	engine Engine
 
+	// deferred is set while opening the shard is deferred until it is first
+	// used.  compactionsDisabled and readOnly hold whether compactions are
+	// paused and whether the shard is read-only once a deferred shard is
+	// opened.
+	deferred            bool
+	compactionsDisabled bool
+	readOnly            bool
+
+	// evicted is set when series of the shard have been evicted from the
+	// index as idle.  They are reloaded when the shard is next queried.
+	// evictedIndex indexes the evicted series on disk so that queries only
+	// reload the series they may read.  It is nil if the evicted series are
+	// not known, in which case every query reloads them.
+	evicted      bool
+	evictedIndex *tsi1.IndexFile
+
 	// expvar-based stats.
 	statMap *expvar.Map
 
@@ -137,19 +183,28 @@ func (s *Shard) SetLogOutput(w io.Writer) {
 	}
 }
 
-// Path returns the path set on the shard when it was created.
-func (s *Shard) Path() string { return s.path }
+// Path returns the path of the shard's files.
+func (s *Shard) Path() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.path
+}
 
 // Open initializes and opens the shard's store.
 func (s *Shard) Open() error {
-	if err := func() error {
-		s.mu.Lock()
-		defer s.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.open()
+}
 
+// open opens the shard's store.  The caller must hold the write lock.
+func (s *Shard) open() error {
+	if err := func() error {
 		// Return if the shard is already open
 		if s.engine != nil {
 			return nil
 		}
+		s.deferred = false
 
 		// Initialize underlying engine.
 		e, err := NewEngine(s.path, s.walPath, s.options)
@@ -171,8 +226,24 @@ func (s *Shard) Open() error {
 		if err := s.engine.LoadMetadataIndex(s.id, s.index); err != nil {
 			return err
 		}
+		// The idle time of the loaded series starts when the shard is opened.
+		if s.options.Config.SeriesIdleTimeout > 0 {
+			s.index.touchShard(s.id, time.Now().UnixNano())
+		}
+		s.evicted = false
+		s.closeEvictedIndex()
 		s.logger.Printf("%s database index loaded in %s", s.path, time.Now().Sub(start))
 
+		if s.compactionsDisabled {
+			s.engine.SetCompactionsEnabled(false)
+		}
+
+		if s.readOnly {
+			if err := s.engine.SetReadOnly(true); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	}(); err != nil {
 		s.close()
@@ -190,12 +261,14 @@ func (s *Shard) Close() error {
 }
 
 func (s *Shard) close() error {
+	s.deferred = false
 	if s.engine == nil {
 		return nil
 	}
 
 	// Don't leak our shard ID and series keys in the index
 	s.index.RemoveShard(s.id)
+	s.closeEvictedIndex()
 
 	err := s.engine.Close()
 	if err == nil {
@@ -204,6 +277,359 @@ func (s *Shard) close() error {
 	return err
 }
 
+// move closes the shard, calls fn to move its files to path and reopens the
+// shard from path.  Callers block until the shard is reopened instead of
+// failing because it is closed.  If fn fails, the shard is reopened from its
+// current path.
+func (s *Shard) move(path string, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The series of the shard stay in the index while it is reopened.
+	open := s.engine != nil
+	if open {
+		s.closeEvictedIndex()
+		if err := s.engine.Close(); err != nil {
+			return err
+		}
+		s.engine = nil
+	}
+
+	err := fn()
+	if err == nil {
+		s.path = path
+	}
+
+	if open {
+		if oerr := s.open(); err == nil {
+			err = oerr
+		}
+	}
+	return err
+}
+
+// pauseCompactions pauses compactions in the open shard, or resumes them
+// unless they were disabled with SetCompactionsEnabled.
+func (s *Shard) pauseCompactions(paused bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.engine != nil {
+		s.engine.SetCompactionsEnabled(!paused && !s.compactionsDisabled)
+	}
+}
+
+// DeferOpen marks the shard to be opened when it is first used instead of
+// being opened now.
+func (s *Shard) DeferOpen() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.engine == nil {
+		s.deferred = true
+	}
+}
+
+// Deferred returns true if the shard has not been opened because opening it
+// was deferred until it is first used.
+func (s *Shard) Deferred() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deferred
+}
+
+// ready opens the shard if opening it was deferred and returns its engine.
+// It returns ErrEngineClosed if the shard is closed.
+func (s *Shard) ready() (Engine, error) {
+	if err := s.openDeferred(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	e := s.engine
+	s.mu.RUnlock()
+	if e == nil {
+		return nil, ErrEngineClosed
+	}
+	return e, nil
+}
+
+// openDeferred opens the shard if opening it was deferred.
+func (s *Shard) openDeferred() error {
+	if !s.Deferred() {
+		return nil
+	}
+
+	start := time.Now()
+	if err := s.Open(); err != nil {
+		return err
+	}
+	s.logger.Printf("%s opened on first use in %s", s.path, time.Now().Sub(start))
+	return nil
+}
+
+// setEvicted records that series of the shard were evicted from the index
+// and adds them to the evicted series index of the shard.
+func (s *Shard) setEvicted(series []*Series) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Series evicted earlier are unknown if they could not be indexed.
+	known := !s.evicted || s.evictedIndex != nil
+	s.evicted = true
+	if !known || s.encrypted() {
+		return
+	}
+
+	if err := s.indexEvicted(series); err != nil {
+		s.logger.Printf("%s error indexing evicted series: %s", s.path, err)
+		s.closeEvictedIndex()
+	}
+}
+
+// indexEvicted rewrites the evicted series index with series and the series
+// it already holds that have not been reloaded.  The caller must hold the
+// write lock.
+func (s *Shard) indexEvicted(series []*Series) error {
+	w := tsi1.NewIndexFileWriter()
+	if f := s.evictedIndex; f != nil {
+		for id := 0; id < f.SeriesN(); id++ {
+			key := f.SeriesKey(uint64(id))
+			if ss := s.index.Series(key); ss != nil && ss.Assigned(s.id) {
+				continue
+			}
+			_, tags, _ := models.ParseKey(key)
+			w.Add(MeasurementFromSeriesKey(key), tags)
+		}
+	}
+	for _, ss := range series {
+		w.Add(ss.measurement.Name, models.Tags(ss.Tags))
+	}
+
+	path := filepath.Join(s.path, evictedIndexFileName)
+	tmp := path + ".tmp"
+	fd, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := w.WriteTo(fd); err != nil {
+		fd.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := fd.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	s.closeEvictedIndex()
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	f, err := tsi1.OpenIndexFile(path, tsi1.DefaultPostingsCacheSize)
+	if err != nil {
+		return err
+	}
+	s.evictedIndex = f
+	return nil
+}
+
+// closeEvictedIndex closes and removes the evicted series index.  The caller
+// must hold the write lock.
+func (s *Shard) closeEvictedIndex() {
+	if s.evictedIndex != nil {
+		if err := s.evictedIndex.Close(); err != nil {
+			s.logger.Printf("%s error closing evicted series index: %s", s.path, err)
+		}
+		s.evictedIndex = nil
+	}
+
+	if err := os.Remove(filepath.Join(s.path, evictedIndexFileName)); err != nil && !os.IsNotExist(err) {
+		s.logger.Printf("%s error removing evicted series index: %s", s.path, err)
+	}
+}
+
+// encrypted returns true if the data files of the shard are encrypted.  The
+// evicted series index is not kept for them as it would store series keys in
+// the clear.
+func (s *Shard) encrypted() bool {
+	return EncryptionKeyProvider != nil || s.options.Config.EncryptionKeyFile != ""
+}
+
+// loadEvicted opens the shard if opening it was deferred and adds the series
+// of the shard evicted from the index back to it, so that queries see every
+// series of the shard.
+func (s *Shard) loadEvicted() error {
+	if err := s.openDeferred(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.evicted || s.engine == nil {
+		return nil
+	}
+
+	start := time.Now()
+	if err := s.engine.LoadMetadataIndex(s.id, s.index); err != nil {
+		return err
+	}
+	s.evicted = false
+	s.closeEvictedIndex()
+	s.logger.Printf("%s evicted series reloaded in %s", s.path, time.Now().Sub(start))
+	return nil
+}
+
+// loadEvictedSeries is like loadEvicted but, if the evicted series are
+// indexed, only reloads the evicted series of the measurements read from
+// sources that may match cond.  Reloading does not touch the series, so the
+// ones the query does not read are evicted again.
+func (s *Shard) loadEvictedSeries(sources influxql.Sources, cond influxql.Expr) error {
+	if err := s.openDeferred(); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	evicted, f := s.evicted, s.evictedIndex
+	all := f == nil
+	var keys []string
+	if evicted && f != nil {
+		names, ok := sourceMeasurementNames(f, sources)
+		all = !ok
+
+		n := 0
+		for _, name := range names {
+			ids := evictedSeriesIDs(f, name, cond)
+			n += len(ids)
+			for _, id := range ids {
+				key := f.SeriesKey(id)
+				if ss := s.index.Series(key); ss == nil || !ss.Assigned(s.id) {
+					keys = append(keys, key)
+				}
+			}
+		}
+
+		// Reloading every indexed series is done as a full reload so the
+		// evicted series index is removed.
+		all = all || n == f.SeriesN()
+	}
+	s.mu.RUnlock()
+
+	if !evicted {
+		return nil
+	} else if all {
+		return s.loadEvicted()
+	} else if len(keys) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.evicted || s.engine == nil {
+		return nil
+	}
+	return s.engine.LoadSeries(s.id, s.index, keys)
+}
+
+// sourceMeasurementNames returns the measurements of f read from sources.  It
+// returns false if a source reads every measurement, as system sources do.
+func sourceMeasurementNames(f *tsi1.IndexFile, sources influxql.Sources) ([]string, bool) {
+	set := make(map[string]struct{})
+	for _, src := range sources {
+		m, ok := src.(*influxql.Measurement)
+		if !ok || influxql.IsSystemName(m.Name) {
+			return nil, false
+		}
+
+		if m.Regex == nil {
+			if f.HasMeasurement(m.Name) {
+				set[m.Name] = struct{}{}
+			}
+			continue
+		}
+		for _, name := range f.MeasurementNames() {
+			if m.Regex.Val.MatchString(name) {
+				set[name] = struct{}{}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	return names, true
+}
+
+// evictedSeriesIDs returns the IDs of the series of measurement name in f
+// that may match cond.
+func evictedSeriesIDs(f *tsi1.IndexFile, name string, cond influxql.Expr) SeriesIDs {
+	ids := SeriesIDs(f.MeasurementSeriesIDs(name))
+	if tagIDs, ok := evictedTagSeriesIDs(f, name, cond); ok {
+		return ids.Intersect(tagIDs)
+	}
+	return ids
+}
+
+// evictedTagSeriesIDs returns the IDs of the series of measurement name in f
+// matched by the tag comparisons of cond.  Only tag comparisons joined by AND
+// narrow the series; it returns false if cond does not narrow them.
+func evictedTagSeriesIDs(f *tsi1.IndexFile, name string, cond influxql.Expr) (SeriesIDs, bool) {
+	switch e := cond.(type) {
+	case *influxql.ParenExpr:
+		return evictedTagSeriesIDs(f, name, e.Expr)
+	case *influxql.BinaryExpr:
+		switch e.Op {
+		case influxql.AND:
+			lids, lok := evictedTagSeriesIDs(f, name, e.LHS)
+			rids, rok := evictedTagSeriesIDs(f, name, e.RHS)
+			if lok && rok {
+				return lids.Intersect(rids), true
+			} else if lok {
+				return lids, true
+			}
+			return rids, rok
+		case influxql.EQ, influxql.EQREGEX:
+			ref, ok := e.LHS.(*influxql.VarRef)
+			lit := e.RHS
+			if !ok {
+				ref, ok = e.RHS.(*influxql.VarRef)
+				lit = e.LHS
+			}
+
+			// The key may name a field unless it is a tag key of the measurement.
+			if !ok || (ref.Type != influxql.Unknown && ref.Type != influxql.Tag) {
+				return nil, false
+			}
+			keys := f.TagKeys(name)
+			if i := sort.SearchStrings(keys, ref.Val); i == len(keys) || keys[i] != ref.Val {
+				return nil, false
+			}
+
+			// Series without the tag match an empty value.
+			switch lit := lit.(type) {
+			case *influxql.StringLiteral:
+				if lit.Val == "" {
+					return nil, false
+				}
+				return SeriesIDs(f.TagValueSeriesIDs(name, ref.Val, lit.Val)), true
+			case *influxql.RegexLiteral:
+				if lit.Val.MatchString("") {
+					return nil, false
+				}
+				var ids SeriesIDs
+				for _, v := range f.TagValues(name, ref.Val) {
+					if lit.Val.MatchString(v) {
+						ids = ids.Union(f.TagValueSeriesIDs(name, ref.Val, v))
+					}
+				}
+				return ids, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // closed determines if the Shard is closed.
 func (s *Shard) closed() bool {
 	s.mu.RLock()
@@ -214,6 +640,12 @@ func (s *Shard) closed() bool {
 
 // DiskSize returns the size on disk of this shard
 func (s *Shard) DiskSize() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.engine != nil {
+		return s.engine.DiskSize(), nil
+	}
+
 	stats, err := os.Stat(s.path)
 	if err != nil {
 		return 0, err
@@ -246,19 +678,35 @@ type SeriesCreate struct {
 
 // WritePoints will write the raw data points and any new metadata to the index in the shard
 func (s *Shard) WritePoints(points []models.Point) error {
-	if s.closed() {
-		return ErrEngineClosed
+	if _, err := s.ready(); err != nil {
+		return err
 	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	if s.readOnly {
+		return ErrShardReadOnly
+	}
+
+	// The generation is changed once the points are written, so results read
+	// before they were are not taken to be current.
+	defer atomic.AddUint64(&s.generation, 1)
+
 	s.statMap.Add(statWriteReq, 1)
 
-	fieldsToCreate, err := s.validateSeriesAndFields(points)
-	if err != nil {
+	if n := s.options.Config.MaxShardDiskSize; n > 0 {
+		if size := s.engine.DiskSize(); size >= n {
+			s.statMap.Add(statWritePointsFail, 1)
+			return fmt.Errorf("%s: (%d) shard=%d size=%d", influxdb.ErrMaxShardDiskSizeExceeded, n, s.id, size)
+		}
+	}
+
+	points, fieldsToCreate, err := s.validateSeriesAndFields(points)
+	if _, ok := err.(PartialWriteError); !ok && err != nil {
 		return err
 	}
+	writeErr := err
 	s.statMap.Add(statFieldsCreate, int64(len(fieldsToCreate)))
 
 	// add any new fields and keep track of what needs to be saved
@@ -273,53 +721,187 @@ func (s *Shard) WritePoints(points []models.Point) error {
 	}
 	s.statMap.Add(statWritePointsOK, int64(len(points)))
 
-	return nil
+	var n int
+	for _, p := range points {
+		n += p.StringSize()
+	}
+	s.statMap.Add(statWritePointsBytes, int64(n))
+
+	return writeErr
 }
 
 func (s *Shard) ContainsSeries(seriesKeys []string) (map[string]bool, error) {
-	if s.closed() {
-		return nil, ErrEngineClosed
+	e, err := s.ready()
+	if err != nil {
+		return nil, err
 	}
 
-	return s.engine.ContainsSeries(seriesKeys)
+	return e.ContainsSeries(seriesKeys)
+}
+
+// ContainsSeriesRange returns whether each of the series keys has values
+// between min and max (inclusive).
+func (s *Shard) ContainsSeriesRange(seriesKeys []string, min, max int64) (map[string]bool, error) {
+	e, err := s.ready()
+	if err != nil {
+		return nil, err
+	}
+
+	return e.ContainsSeriesRange(seriesKeys, min, max)
 }
 
 // DeleteSeries deletes a list of series.
 func (s *Shard) DeleteSeries(seriesKeys []string) error {
-	if s.closed() {
-		return ErrEngineClosed
+	e, err := s.ready()
+	if err != nil {
+		return err
 	}
-	if err := s.engine.DeleteSeries(seriesKeys); err != nil {
+	if err := e.DeleteSeries(seriesKeys); err != nil {
 		return err
 	}
+	atomic.AddUint64(&s.generation, 1)
 	return nil
 }
 
 // DeleteSeriesRange deletes all values from for seriesKeys between min and max (inclusive)
 func (s *Shard) DeleteSeriesRange(seriesKeys []string, min, max int64) error {
-	if s.closed() {
-		return ErrEngineClosed
+	e, err := s.ready()
+	if err != nil {
+		return err
 	}
-	if err := s.engine.DeleteSeriesRange(seriesKeys, min, max); err != nil {
+	if err := e.DeleteSeriesRange(seriesKeys, min, max); err != nil {
 		return err
 	}
+	atomic.AddUint64(&s.generation, 1)
 
 	return nil
 }
 
 // DeleteMeasurement deletes a measurement and all underlying series.
 func (s *Shard) DeleteMeasurement(name string, seriesKeys []string) error {
-	if s.closed() {
-		return ErrEngineClosed
+	e, err := s.ready()
+	if err != nil {
+		return err
 	}
 
-	if err := s.engine.DeleteMeasurement(name, seriesKeys); err != nil {
+	if err := e.DeleteMeasurement(name, seriesKeys); err != nil {
 		return err
 	}
+	atomic.AddUint64(&s.generation, 1)
 
 	return nil
 }
 
+// Generation returns a number incremented after every write to or delete
+// from the shard, so callers can tell whether its data has changed.
+func (s *Shard) Generation() uint64 {
+	return atomic.LoadUint64(&s.generation)
+}
+
+// CreateSnapshot creates a point-in-time snapshot of the shard's data files
+// using hard links and returns the path of the directory holding it.
+func (s *Shard) CreateSnapshot() (string, error) {
+	e, err := s.ready()
+	if err != nil {
+		return "", err
+	}
+	return e.CreateSnapshot()
+}
+
+// Import adds the data files of a tar archive written by a shard backup to the
+// shard and adds their series to the index.
+func (s *Shard) Import(r io.Reader) error {
+	e, err := s.ready()
+	if err != nil {
+		return err
+	} else if s.ReadOnly() {
+		return ErrShardReadOnly
+	}
+
+	if err := e.Import(r); err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.generation, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.engine.LoadMetadataIndex(s.id, s.index)
+}
+
+// ScheduleFullCompaction requests a full compaction of the shard's data files.
+func (s *Shard) ScheduleFullCompaction() error {
+	e, err := s.ready()
+	if err != nil {
+		return err
+	}
+	return e.ScheduleFullCompaction()
+}
+
+// SetCompactionsEnabled pauses or resumes compactions in the shard.  A
+// deferred shard applies the setting once it is opened.
+func (s *Shard) SetCompactionsEnabled(enabled bool) error {
+	s.mu.Lock()
+	s.compactionsDisabled = !enabled
+	deferred := s.deferred
+	s.mu.Unlock()
+
+	if deferred {
+		return nil
+	} else if s.closed() {
+		return ErrEngineClosed
+	}
+	s.engine.SetCompactionsEnabled(enabled)
+	return nil
+}
+
+// FlushCache writes the cached writes of the shard to its data files.  A
+// shard that has not been opened yet has nothing cached.
+func (s *Shard) FlushCache() error {
+	if s.Deferred() {
+		return nil
+	} else if s.closed() {
+		return ErrEngineClosed
+	}
+	return s.engine.FlushCache()
+}
+
+// SetReadOnly makes the shard read-only or writable again.  Writes to a
+// read-only shard fail with ErrShardReadOnly.  A deferred shard applies the
+// setting once it is opened.
+func (s *Shard) SetReadOnly(readOnly bool) error {
+	s.mu.Lock()
+	s.readOnly = readOnly
+	deferred := s.deferred
+	s.mu.Unlock()
+
+	if deferred {
+		return nil
+	} else if s.closed() {
+		return ErrEngineClosed
+	}
+	return s.engine.SetReadOnly(readOnly)
+}
+
+// ReadOnly returns true if the shard is read-only.
+func (s *Shard) ReadOnly() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readOnly
+}
+
+// Compactions returns the status of the compactions running in the shard.
+func (s *Shard) Compactions() []CompactionStatus {
+	if s.closed() {
+		return nil
+	}
+
+	a := s.engine.Compactions()
+	for i := range a {
+		a[i].ShardID = s.id
+	}
+	return a
+}
+
 func (s *Shard) createFieldsAndMeasurements(fieldsToCreate []*FieldCreate) error {
 	if len(fieldsToCreate) == 0 {
 		return nil
@@ -342,15 +924,66 @@ func (s *Shard) createFieldsAndMeasurements(fieldsToCreate []*FieldCreate) error
 	return nil
 }
 
-// validateSeriesAndFields checks which series and fields are new and whose metadata should be saved and indexed
-func (s *Shard) validateSeriesAndFields(points []models.Point) ([]*FieldCreate, error) {
+// validateSeriesAndFields checks the fields of points against the shard's
+// and adds new series to the index.  It returns the points to write, which
+// exclude points dropped because they would exceed the series limit of the
+// database or because of field type conflicts, and have field values coerced
+// according to the field type conflict policy of the database.  A
+// PartialWriteError is returned along with the points when any are dropped.
+func (s *Shard) validateSeriesAndFields(points []models.Point) ([]models.Point, []*FieldCreate, error) {
 	var fieldsToCreate []*FieldCreate
+	var dropped int
+	var reason string
+
+	// valid is only allocated once a point is dropped or replaced.
+	var valid []models.Point
+	copyValid := func(i int) {
+		if valid == nil {
+			valid = make([]models.Point, i, len(points))
+			copy(valid, points[:i])
+		}
+	}
+	drop := func(i int, r string) {
+		copyValid(i)
+		if dropped == 0 {
+			reason = r
+		}
+		dropped++
+	}
+
+	policy := s.options.Config.FieldTypeConflictFor(s.database)
+	now := time.Now().UnixNano()
 
 	// get the shard mutex for locally defined fields
-	for _, p := range points {
+	for i, p := range points {
+		// see if the field definitions need to be saved to the shard
+		mf := s.engine.MeasurementFields(p.Name())
+
+		// Resolve field type conflicts before the series is indexed so that
+		// dropped points do not create series.
+		if mf != nil {
+			np, r, err := s.checkFieldTypes(p, mf, policy)
+			if err != nil {
+				return nil, nil, err
+			} else if np == nil {
+				drop(i, r)
+				continue
+			} else if np != p {
+				copyValid(i)
+				p = np
+			}
+		}
+
 		// see if the series should be added to the index
 		key := string(p.Key())
 		ss := s.index.Series(key)
+		if ss == nil || !ss.Assigned(s.id) {
+			if r := s.checkSeriesLimits(p, key, ss == nil); r != "" {
+				drop(i, r)
+				continue
+			}
+		}
+
 		if ss == nil {
 			ss = NewSeries(key, p.Tags())
 			s.statMap.Add(statSeriesCreate, 1)
@@ -358,9 +991,11 @@ func (s *Shard) validateSeriesAndFields(points []models.Point) ([]*FieldCreate,
 
 		ss = s.index.CreateSeriesIndexIfNotExists(p.Name(), ss)
 		s.index.AssignShard(ss.Key, s.id)
+		ss.touch(now)
 
-		// see if the field definitions need to be saved to the shard
-		mf := s.engine.MeasurementFields(p.Name())
+		if valid != nil {
+			valid = append(valid, p)
+		}
 
 		if mf == nil {
 			for name, value := range p.Fields() {
@@ -369,52 +1004,138 @@ func (s *Shard) validateSeriesAndFields(points []models.Point) ([]*FieldCreate,
 			continue // skip validation since all fields are new
 		}
 
-		// validate field types and encode data
+		// Field types were checked above, so only new fields remain.
 		for name, value := range p.Fields() {
-			if f := mf.Field(name); f != nil {
-				// Field present in shard metadata, make sure there is no type conflict.
-				if f.Type != influxql.InspectDataType(value) {
-					return nil, fmt.Errorf("field type conflict: input field \"%s\" on measurement \"%s\" is type %T, already exists as type %s", name, p.Name(), value, f.Type)
-				}
+			if mf.Field(name) != nil {
+				continue
+			}
+			fieldsToCreate = append(fieldsToCreate, &FieldCreate{p.Name(), &Field{Name: name, Type: influxql.InspectDataType(value)}})
+		}
+	}
+
+	if valid != nil {
+		points = valid
+	}
+	if dropped > 0 {
+		s.statMap.Add(statWritePointsDropped, int64(dropped))
+		return points, fieldsToCreate, PartialWriteError{Reason: reason, Dropped: dropped}
+	}
+	return points, fieldsToCreate, nil
+}
+
+// checkFieldTypes checks the types of the fields of p against those stored in
+// mf and resolves conflicts according to policy.  It returns p, a copy of p
+// with coerced field values, or a nil point and the reason p is dropped.  An
+// error is returned if a conflict fails the write.
+func (s *Shard) checkFieldTypes(p models.Point, mf *MeasurementFields, policy string) (models.Point, string, error) {
+	var coerced models.Fields
+	fields := p.Fields()
+	for name, value := range fields {
+		f := mf.Field(name)
+		if f == nil {
+			continue
+		}
+
+		typ := influxql.InspectDataType(value)
+		if f.Type == typ {
+			continue // Field is present, and it's of the same type. Nothing more to do.
+		}
 
-				continue // Field is present, and it's of the same type. Nothing more to do.
+		if policy == FieldTypeConflictCoerce && f.Type == influxql.Float && typ == influxql.Integer {
+			if coerced == nil {
+				coerced = make(models.Fields, len(fields))
+				for k, v := range fields {
+					coerced[k] = v
+				}
 			}
+			coerced[name] = float64(value.(int64))
+			continue
+		}
 
-			fieldsToCreate = append(fieldsToCreate, &FieldCreate{p.Name(), &Field{Name: name, Type: influxql.InspectDataType(value)}})
+		err := fmt.Errorf("field type conflict: input field \"%s\" on measurement \"%s\" is type %T, already exists as type %s", name, p.Name(), value, f.Type)
+		if policy == FieldTypeConflictDrop {
+			return nil, err.Error(), nil
 		}
+		return nil, "", err
+	}
+
+	if coerced == nil {
+		return p, "", nil
 	}
 
-	return fieldsToCreate, nil
+	np, err := models.NewPoint(p.Name(), p.Tags(), coerced, p.Time())
+	if err != nil {
+		return nil, "", err
+	}
+	s.statMap.Add(statWritePointsCoerced, 1)
+	return np, "", nil
+}
+
+// checkSeriesLimits returns why creating the series key of p would exceed the
+// limits of the database, or an empty string if it would not.
+func (s *Shard) checkSeriesLimits(p models.Point, key string, newSeries bool) string {
+	if n := s.options.Config.MaxSeriesPerShard; n > 0 && s.index.ShardSeriesN(s.id) >= n {
+		return fmt.Sprintf("%s: (%d) shard=%d %s", influxdb.ErrMaxSeriesPerShardExceeded, n, s.id, key)
+	}
+
+	if !newSeries {
+		return ""
+	}
+
+	if n := s.options.Config.MaxSeriesPerDatabase; n > 0 && s.index.SeriesN() >= n {
+		return fmt.Sprintf("%s: (%d) %s", influxdb.ErrMaxSeriesPerDatabaseExceeded, n, key)
+	}
+
+	if n := s.options.Config.MaxValuesPerTag; n > 0 {
+		mm := s.index.Measurement(p.Name())
+		if mm == nil {
+			return ""
+		}
+
+		for k, v := range p.Tags() {
+			if mm.TagValueN(k) >= n && !mm.HasTagValue(k, v) {
+				return fmt.Sprintf("%s: (%d) measurement=%q tag=%q value=%q", influxdb.ErrMaxValuesPerTagExceeded, n, p.Name(), k, v)
+			}
+		}
+	}
+	return ""
 }
 
 // SeriesCount returns the number of series buckets on the shard.
 func (s *Shard) SeriesCount() (int, error) {
-	if s.closed() {
-		return 0, ErrEngineClosed
+	e, err := s.ready()
+	if err != nil {
+		return 0, err
 	}
-	return s.engine.SeriesCount()
+	return e.SeriesCount()
 }
 
 // WriteTo writes the shard's data to w.
 func (s *Shard) WriteTo(w io.Writer) (int64, error) {
-	if s.closed() {
-		return 0, ErrEngineClosed
+	e, err := s.ready()
+	if err != nil {
+		return 0, err
 	}
-	n, err := s.engine.WriteTo(w)
+	n, err := e.WriteTo(w)
 	s.statMap.Add(statWriteBytes, int64(n))
 	return n, err
 }
 
 // CreateIterator returns an iterator for the data in the shard.
 func (s *Shard) CreateIterator(opt influxql.IteratorOptions) (influxql.Iterator, error) {
-	if s.closed() {
-		return nil, ErrEngineClosed
+	if err := s.loadEvictedSeries(opt.Sources, opt.Condition); err != nil {
+		return nil, err
+	}
+	e, err := s.ready()
+	if err != nil {
+		return nil, err
 	}
+	s.statMap.Add(statQueryReq, 1)
 
 	if influxql.Sources(opt.Sources).HasSystemSource() {
 		return s.createSystemIterator(opt)
 	}
-	return s.engine.CreateIterator(opt)
+	return e.CreateIterator(opt)
 }
 
 // createSystemIterator returns an iterator for a system source.
@@ -443,6 +1164,10 @@ func (s *Shard) createSystemIterator(opt influxql.IteratorOptions) (influxql.Ite
 
 // FieldDimensions returns unique sets of fields and dimensions across a list of sources.
 func (s *Shard) FieldDimensions(sources influxql.Sources) (fields, dimensions map[string]struct{}, err error) {
+	if err := s.loadEvictedSeries(sources, nil); err != nil {
+		return nil, nil, err
+	}
+
 	fields = make(map[string]struct{})
 	dimensions = make(map[string]struct{})
 
@@ -470,8 +1195,12 @@ func (s *Shard) FieldDimensions(sources influxql.Sources) (fields, dimensions ma
 
 // SeriesKeys returns a list of series in the shard.
 func (s *Shard) SeriesKeys(opt influxql.IteratorOptions) (influxql.SeriesList, error) {
-	if s.closed() {
-		return nil, ErrEngineClosed
+	if err := s.loadEvictedSeries(opt.Sources, opt.Condition); err != nil {
+		return nil, err
+	}
+	e, err := s.ready()
+	if err != nil {
+		return nil, err
 	}
 
 	if influxql.Sources(opt.Sources).HasSystemSource() {
@@ -489,12 +1218,39 @@ func (s *Shard) SeriesKeys(opt influxql.IteratorOptions) (influxql.SeriesList, e
 		return []influxql.Series{{Aux: auxFields}}, nil
 	}
 
-	return s.engine.SeriesKeys(opt)
+	return e.SeriesKeys(opt)
+}
+
+// IteratorCost returns the estimated cost of creating an iterator for opt.
+func (s *Shard) IteratorCost(opt influxql.IteratorOptions) (influxql.IteratorCost, error) {
+	if err := s.loadEvictedSeries(opt.Sources, opt.Condition); err != nil {
+		return influxql.IteratorCost{}, err
+	}
+	e, err := s.ready()
+	if err != nil {
+		return influxql.IteratorCost{}, err
+	}
+
+	// System sources are generated from the index and have no read cost.
+	if influxql.Sources(opt.Sources).HasSystemSource() {
+		return influxql.IteratorCost{NumShards: 1}, nil
+	}
+
+	cost, err := e.IteratorCost(opt)
+	if err != nil {
+		return influxql.IteratorCost{}, err
+	}
+	cost.NumShards = 1
+	return cost, nil
 }
 
 // ExpandSources expands regex sources and removes duplicates.
 // NOTE: sources must be normalized (db and rp set) before calling this function.
 func (s *Shard) ExpandSources(sources influxql.Sources) (influxql.Sources, error) {
+	if err := s.loadEvictedSeries(sources, nil); err != nil {
+		return nil, err
+	}
+
 	// Use a map as a set to prevent duplicates.
 	set := map[string]influxql.Source{}
 
@@ -631,6 +1387,17 @@ func (m *MeasurementFields) Field(name string) *Field {
 	return f
 }
 
+// Fields returns the fields of the measurement.
+func (m *MeasurementFields) Fields() []*Field {
+	m.mu.RLock()
+	a := make([]*Field, 0, len(m.fields))
+	for _, f := range m.fields {
+		a = append(a, f)
+	}
+	m.mu.RUnlock()
+	return a
+}
+
 // Field represents a series field.
 type Field struct {
 	ID   uint8             `json:"id,omitempty"`
@@ -920,14 +1687,104 @@ func (ic *shardIteratorCreator) SeriesKeys(opt influxql.IteratorOptions) (influx
 func (ic *shardIteratorCreator) ExpandSources(sources influxql.Sources) (influxql.Sources, error) {
 	return ic.sh.ExpandSources(sources)
 }
+func (ic *shardIteratorCreator) IteratorCost(opt influxql.IteratorOptions) (influxql.IteratorCost, error) {
+	return ic.sh.IteratorCost(opt)
+}
 
+// fieldKeysIterator emits the field keys of the measurements in a shard along
+// with the type of each field in the shard.
+type fieldKeysIterator struct {
+	sh     *Shard
+	mms    Measurements           // remaining measurements
+	points []*influxql.FloatPoint // current measurement's fields
+	opt    influxql.IteratorOptions
+}
+
+// NewFieldKeysIterator returns a new instance of the field keys iterator.
 func NewFieldKeysIterator(sh *Shard, opt influxql.IteratorOptions) (influxql.Iterator, error) {
-	fn := func(m *Measurement) []string {
-		keys := m.FieldNames()
-		sort.Strings(keys)
-		return keys
+	// The shards have already been selected by the time range so only the
+	// measurement name is left to filter on.
+	opt.Condition = conditionWithoutTime(opt.Condition)
+
+	itr := &fieldKeysIterator{sh: sh, opt: opt}
+
+	// Retrieve measurements from shard. Filter if condition specified.
+	if opt.Condition == nil {
+		itr.mms = sh.index.Measurements()
+	} else {
+		mms, _, err := sh.index.measurementsByExpr(opt.Condition)
+		if err != nil {
+			return nil, err
+		}
+		itr.mms = mms
 	}
-	return newMeasurementKeysIterator(sh, fn, opt)
+
+	// Sort measurements by name.
+	sort.Sort(itr.mms)
+
+	return itr, nil
+}
+
+// Stats returns stats about the points processed.
+func (itr *fieldKeysIterator) Stats() influxql.IteratorStats { return influxql.IteratorStats{} }
+
+// Close closes the iterator.
+func (itr *fieldKeysIterator) Close() error { return nil }
+
+// Next emits the next field key and type.
+func (itr *fieldKeysIterator) Next() (*influxql.FloatPoint, error) {
+	for len(itr.points) == 0 {
+		if len(itr.mms) == 0 {
+			return nil, nil
+		}
+		itr.points = itr.fieldPoints(itr.mms[0])
+		itr.mms = itr.mms[1:]
+	}
+
+	p := itr.points[0]
+	itr.points = itr.points[1:]
+	return p, nil
+}
+
+// fieldPoints returns a point for each field of mm stored in the shard.  The
+// index is shared by the shards of the database so fields only written to
+// other shards are skipped.
+//
+// The time of a point is the position of its field in the sorted field names
+// of the index followed by its type.  It is not returned but keeps the points
+// of all shards sorted by key and type when they are merged.
+func (itr *fieldKeysIterator) fieldPoints(mm *Measurement) []*influxql.FloatPoint {
+	names := mm.FieldNames()
+	sort.Strings(names)
+
+	mf := itr.sh.engine.MeasurementFields(mm.Name)
+	if mf == nil {
+		return nil
+	}
+
+	points := make([]*influxql.FloatPoint, 0, len(names))
+	for i, name := range names {
+		f := mf.Field(name)
+		if f == nil {
+			continue
+		}
+
+		p := &influxql.FloatPoint{
+			Name: mm.Name,
+			Time: int64(i)<<8 | int64(f.Type),
+			Aux:  make([]interface{}, len(itr.opt.Aux)),
+		}
+		for j, ref := range itr.opt.Aux {
+			switch ref.Val {
+			case "fieldKey":
+				p.Aux[j] = f.Name
+			case "fieldType":
+				p.Aux[j] = f.Type.String()
+			}
+		}
+		points = append(points, p)
+	}
+	return points
 }
 
 // MeasurementIterator represents a string iterator that emits all measurement names in a shard.
@@ -991,10 +1848,17 @@ type seriesIterator struct {
 
 	point influxql.FloatPoint // reusable point
 	opt   influxql.IteratorOptions
+
+	sh     *Shard
+	active bool // only emit series with values in the time range
 }
 
 // NewSeriesIterator returns a new instance of SeriesIterator.
 func NewSeriesIterator(sh *Shard, opt influxql.IteratorOptions) (influxql.Iterator, error) {
+	// A condition on time selects the series with values in the time range.
+	active := influxql.HasTimeExpr(opt.Condition)
+	opt.Condition = conditionWithoutTime(opt.Condition)
+
 	// Only equality operators are allowed.
 	var err error
 	influxql.WalkFunc(opt.Condition, func(n influxql.Node) {
@@ -1021,7 +1885,9 @@ func NewSeriesIterator(sh *Shard, opt influxql.IteratorOptions) (influxql.Iterat
 		point: influxql.FloatPoint{
 			Aux: make([]interface{}, len(opt.Aux)),
 		},
-		opt: opt,
+		opt:    opt,
+		sh:     sh,
+		active: active,
 	}, nil
 }
 
@@ -1050,7 +1916,7 @@ func (itr *seriesIterator) Next() (*influxql.FloatPoint, error) {
 
 		// Write auxiliary fields.
 		for i, f := range itr.opt.Aux {
-			switch f {
+			switch f.Val {
 			case "key":
 				itr.point.Aux[i] = key
 			}
@@ -1080,6 +1946,22 @@ func (itr *seriesIterator) nextKeys() error {
 			continue
 		}
 		itr.keys.buf = mm.AppendSeriesKeysByID(itr.keys.buf, ids)
+
+		if itr.active {
+			contains, err := itr.sh.ContainsSeriesRange(itr.keys.buf, itr.opt.StartTime, itr.opt.EndTime)
+			if err != nil {
+				return err
+			}
+			keys := itr.keys.buf[:0]
+			for _, key := range itr.keys.buf {
+				if contains[key] {
+					keys = append(keys, key)
+				}
+			}
+			if itr.keys.buf = keys; len(keys) == 0 {
+				continue
+			}
+		}
 		sort.Strings(itr.keys.buf)
 
 		return nil
@@ -1096,9 +1978,9 @@ func NewTagKeysIterator(sh *Shard, opt influxql.IteratorOptions) (influxql.Itera
 
 // tagValuesIterator emits key/tag values
 type tagValuesIterator struct {
-	series []*Series // remaining series
-	keys   []string  // tag keys to select from a series
-	fields []string  // fields to emit (key or value)
+	series []*Series         // remaining series
+	keys   []string          // tag keys to select from a series
+	fields []influxql.VarRef // fields to emit (key or value)
 	buf    struct {
 		s    *Series  // current series
 		keys []string // current tag's keys
@@ -1111,6 +1993,13 @@ func NewTagValuesIterator(sh *Shard, opt influxql.IteratorOptions) (influxql.Ite
 		return nil, errors.New("a condition is required")
 	}
 
+	// A condition on time selects the values of the series with values in
+	// the time range.
+	active := influxql.HasTimeExpr(opt.Condition)
+	if opt.Condition = conditionWithoutTime(opt.Condition); opt.Condition == nil {
+		return nil, errors.New("a condition is required")
+	}
+
 	mms, ok, err := sh.index.measurementsByExpr(opt.Condition)
 	if err != nil {
 		return nil, err
@@ -1151,8 +2040,22 @@ func NewTagValuesIterator(sh *Shard, opt influxql.IteratorOptions) (influxql.Ite
 			return nil, err
 		}
 
-		for _, id := range ids {
-			series = append(series, mm.SeriesByID(id))
+		if !active {
+			for _, id := range ids {
+				series = append(series, mm.SeriesByID(id))
+			}
+			continue
+		}
+
+		keys := mm.AppendSeriesKeysByID(nil, ids)
+		contains, err := sh.ContainsSeriesRange(keys, opt.StartTime, opt.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		for i, id := range ids {
+			if contains[keys[i]] {
+				series = append(series, mm.SeriesByID(id))
+			}
 		}
 	}
 
@@ -1194,7 +2097,7 @@ func (itr *tagValuesIterator) Next() (*influxql.FloatPoint, error) {
 		// Prepare auxiliary fields.
 		auxFields := make([]interface{}, len(itr.fields))
 		for i, f := range itr.fields {
-			switch f {
+			switch f.Val {
 			case "_tagKey":
 				auxFields[i] = key
 			case "value":
@@ -1213,6 +2116,32 @@ func (itr *tagValuesIterator) Next() (*influxql.FloatPoint, error) {
 	}
 }
 
+// conditionWithoutTime returns a copy of the condition of a system source
+// without its comparisons of time.  The time range is applied through the
+// start and end time of the iterator options instead.
+func conditionWithoutTime(cond influxql.Expr) influxql.Expr {
+	if !influxql.HasTimeExpr(cond) {
+		return cond
+	}
+
+	cond = influxql.RewriteExpr(influxql.CloneExpr(cond), func(e influxql.Expr) influxql.Expr {
+		if e, ok := e.(*influxql.BinaryExpr); ok {
+			for _, side := range []influxql.Expr{e.LHS, e.RHS} {
+				if ref, ok := side.(*influxql.VarRef); ok && strings.ToLower(ref.Val) == "time" {
+					return &influxql.BooleanLiteral{Val: true}
+				}
+			}
+		}
+		return e
+	})
+
+	cond = influxql.Reduce(cond, nil)
+	if lit, ok := cond.(*influxql.BooleanLiteral); ok && lit.Val {
+		return nil
+	}
+	return cond
+}
+
 // measurementKeyFunc is the function called by measurementKeysIterator.
 type measurementKeyFunc func(m *Measurement) []string
 
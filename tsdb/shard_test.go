@@ -1,16 +1,20 @@
 package tsdb_test
 
 import (
+	"expvar"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/pkg/deep"
@@ -154,6 +158,259 @@ func TestShardWriteAddNewField(t *testing.T) {
 
 // Ensures that when a shard is closed, it removes any series meta-data
 // from the index.
+// Ensure points creating series beyond the database's limit are dropped.
+func TestShard_WritePoints_MaxSeriesPerDatabase(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+	tmpShard := path.Join(tmpDir, "shard")
+	tmpWal := path.Join(tmpDir, "wal")
+
+	index := tsdb.NewDatabaseIndex("db")
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+	opts.Config.MaxSeriesPerDatabase = 2
+
+	sh := tsdb.NewShard(1, index, tmpShard, tmpWal, opts)
+	if err := sh.Open(); err != nil {
+		t.Fatalf("error opening shard: %s", err.Error())
+	}
+	defer sh.Close()
+
+	points := []models.Point{
+		models.MustNewPoint("cpu", map[string]string{"host": "serverA"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("cpu", map[string]string{"host": "serverB"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("cpu", map[string]string{"host": "serverC"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("cpu", map[string]string{"host": "serverA"}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+	}
+
+	err := sh.WritePoints(points)
+	if perr, ok := err.(tsdb.PartialWriteError); !ok {
+		t.Fatalf("expected partial write error, got: %v", err)
+	} else if perr.Dropped != 1 {
+		t.Fatalf("unexpected dropped count: %d", perr.Dropped)
+	} else if !strings.Contains(perr.Error(), "cpu,host=serverC") {
+		t.Fatalf("expected error to name the dropped series: %s", perr)
+	}
+
+	if n := index.SeriesN(); n != 2 {
+		t.Fatalf("unexpected series count: %d", n)
+	} else if index.Series("cpu,host=serverC") != nil {
+		t.Fatal("unexpected series in index")
+	}
+
+	// Writes to existing series still succeed.
+	if err := sh.WritePoints(points[:2]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+
+// Ensure points adding series to a shard beyond the limit are dropped, even
+// when the series exist in other shards of the database.
+func TestShard_WritePoints_MaxSeriesPerShard(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+
+	index := tsdb.NewDatabaseIndex("db")
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+	opts.Config.MaxSeriesPerShard = 2
+
+	sh1 := tsdb.NewShard(1, index, path.Join(tmpDir, "shard1"), path.Join(tmpDir, "wal1"), opts)
+	sh2 := tsdb.NewShard(2, index, path.Join(tmpDir, "shard2"), path.Join(tmpDir, "wal2"), opts)
+	for _, sh := range []*tsdb.Shard{sh1, sh2} {
+		if err := sh.Open(); err != nil {
+			t.Fatalf("error opening shard: %s", err.Error())
+		}
+		defer sh.Close()
+	}
+
+	points := []models.Point{
+		models.MustNewPoint("cpu", map[string]string{"host": "serverA"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("cpu", map[string]string{"host": "serverB"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("cpu", map[string]string{"host": "serverC"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+	}
+
+	if err := sh1.WritePoints(points[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second shard holds serverC and one of the series of the first.
+	if err := sh2.WritePoints([]models.Point{points[2], points[0]}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := sh1.WritePoints(points)
+	if perr, ok := err.(tsdb.PartialWriteError); !ok {
+		t.Fatalf("expected partial write error, got: %v", err)
+	} else if perr.Dropped != 1 {
+		t.Fatalf("unexpected dropped count: %d", perr.Dropped)
+	} else if !strings.Contains(perr.Error(), "max series per shard exceeded: (2) shard=1 cpu,host=serverC") {
+		t.Fatalf("expected error to name the dropped series: %s", perr)
+	} else if !influxdb.IsClientError(err) {
+		t.Fatal("expected client error")
+	}
+
+	if n := index.ShardSeriesN(1); n != 2 {
+		t.Fatalf("unexpected shard 1 series count: %d", n)
+	} else if n := index.ShardSeriesN(2); n != 2 {
+		t.Fatalf("unexpected shard 2 series count: %d", n)
+	}
+
+	// Dropping a series frees room for another.
+	index.DropSeries([]string{"cpu,host=serverB"})
+	if n := index.ShardSeriesN(1); n != 1 {
+		t.Fatalf("unexpected shard 1 series count: %d", n)
+	} else if err := sh1.WritePoints(points[2:]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure writes fail once a shard's data files reach the size limit.
+func TestShard_WritePoints_MaxShardDiskSize(t *testing.T) {
+	sh := NewShard()
+	sh.Shard = tsdb.NewShard(1, tsdb.NewDatabaseIndex("db"), filepath.Join(sh.path, "data"), filepath.Join(sh.path, "wal"), func() tsdb.EngineOptions {
+		opts := tsdb.NewEngineOptions()
+		opts.Config.WALDir = filepath.Join(sh.path, "wal")
+		opts.Config.MaxShardDiskSize = 1
+		return opts
+	}())
+	if err := sh.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer sh.Close()
+
+	// Writes are accepted until the cache is written to a TSM file.
+	sh.MustWritePointsString(`cpu,host=serverA value=100 1`)
+	dir, err := sh.CreateSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(dir)
+
+	if size, err := sh.DiskSize(); err != nil {
+		t.Fatal(err)
+	} else if size == 0 {
+		t.Fatal("expected non-zero disk size")
+	}
+
+	err = sh.WritePointsString(`cpu,host=serverA value=200 2`)
+	if err == nil || !strings.Contains(err.Error(), "max shard disk size exceeded: (1) shard=1") {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !influxdb.IsClientError(err) {
+		t.Fatal("expected client error")
+	}
+}
+
+// Ensure field type conflicts are handled by the policy of the database.
+func TestShard_WritePoints_FieldTypeConflict(t *testing.T) {
+	for _, tt := range []struct {
+		policy  string
+		err     string
+		written string
+		dropped string
+		coerced string
+	}{
+		{policy: tsdb.FieldTypeConflictReject, err: `field type conflict: input field "value" on measurement "cpu" is type int64, already exists as type float`, written: "1", dropped: "0", coerced: "0"},
+		{policy: tsdb.FieldTypeConflictDrop, err: `partial write: field type conflict: input field "value" on measurement "cpu" is type int64, already exists as type float dropped=1`, written: "2", dropped: "1", coerced: "0"},
+		{policy: tsdb.FieldTypeConflictCoerce, written: "3", dropped: "0", coerced: "1"},
+	} {
+		func() {
+			sh := NewShard()
+			index := tsdb.NewDatabaseIndex("db0")
+			dataPath := filepath.Join(sh.path, "db0", "rp0", "1")
+			sh.Shard = tsdb.NewShard(1, index, dataPath, filepath.Join(sh.path, "wal"), func() tsdb.EngineOptions {
+				opts := tsdb.NewEngineOptions()
+				opts.Config.WALDir = filepath.Join(sh.path, "wal")
+				opts.Config.DatabaseFieldTypeConflicts = []string{"db0=" + tt.policy}
+				return opts
+			}())
+			if err := sh.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer sh.Close()
+
+			sh.MustWritePointsString(`cpu,host=serverA value=1.5 1`)
+			err := sh.WritePointsString(`
+cpu,host=serverC value=2i 2
+cpu,host=serverB value=3.5 3
+`)
+			if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+				t.Fatalf("%s: unexpected error: %v", tt.policy, err)
+			} else if err != nil && !influxdb.IsClientError(err) {
+				t.Fatalf("%s: expected client error", tt.policy)
+			}
+
+			// Rejected and dropped points must not create their series.
+			if ss := index.Series("cpu,host=serverC"); (ss != nil) != (tt.policy == tsdb.FieldTypeConflictCoerce) {
+				t.Fatalf("%s: unexpected series: %v", tt.policy, ss)
+			}
+
+			values := expvar.Get(fmt.Sprintf("shard:%s:1", dataPath)).(*expvar.Map).Get("values").(*expvar.Map)
+			for name, exp := range map[string]string{
+				"writePointsOk":      tt.written,
+				"writePointsDropped": tt.dropped,
+				"writePointsCoerced": tt.coerced,
+			} {
+				if v := values.Get(name); (v == nil && exp != "0") || (v != nil && v.String() != exp) {
+					t.Errorf("%s: unexpected %s: exp %s, got %v", tt.policy, name, exp, v)
+				}
+			}
+
+			// Floats can not be coerced to integers.
+			sh.MustWritePointsString(`mem value=1i 1`)
+			if err := sh.WritePointsString(`mem value=1.5 2`); tt.policy != tsdb.FieldTypeConflictDrop && (err == nil || !strings.Contains(err.Error(), "field type conflict")) {
+				t.Fatalf("%s: unexpected error: %v", tt.policy, err)
+			}
+		}()
+	}
+}
+
+// Ensure points adding tag values beyond the limit are dropped.
+func TestShard_WritePoints_MaxValuesPerTag(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir("", "shard_test")
+	defer os.RemoveAll(tmpDir)
+	tmpShard := path.Join(tmpDir, "shard")
+	tmpWal := path.Join(tmpDir, "wal")
+
+	index := tsdb.NewDatabaseIndex("db")
+	opts := tsdb.NewEngineOptions()
+	opts.Config.WALDir = filepath.Join(tmpDir, "wal")
+	opts.Config.MaxValuesPerTag = 2
+
+	sh := tsdb.NewShard(1, index, tmpShard, tmpWal, opts)
+	if err := sh.Open(); err != nil {
+		t.Fatalf("error opening shard: %s", err.Error())
+	}
+	defer sh.Close()
+
+	points := []models.Point{
+		models.MustNewPoint("cpu", map[string]string{"host": "serverA"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("cpu", map[string]string{"host": "serverB"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("cpu", map[string]string{"host": "serverC"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("cpu", map[string]string{"host": "serverA", "region": "west"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		models.MustNewPoint("mem", map[string]string{"host": "serverC"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+	}
+
+	err := sh.WritePoints(points)
+	if perr, ok := err.(tsdb.PartialWriteError); !ok {
+		t.Fatalf("expected partial write error, got: %v", err)
+	} else if perr.Dropped != 1 {
+		t.Fatalf("unexpected dropped count: %d", perr.Dropped)
+	} else if !strings.Contains(perr.Error(), `tag="host" value="serverC"`) {
+		t.Fatalf("expected error to name the dropped tag: %s", perr)
+	}
+
+	// New series with existing tag values and other measurements are written.
+	if n := index.SeriesN(); n != 4 {
+		t.Fatalf("unexpected series count: %d", n)
+	} else if index.Series("cpu,host=serverA,region=west") == nil || index.Series("mem,host=serverC") == nil {
+		t.Fatal("expected series in index")
+	} else if index.Series("cpu,host=serverC") != nil {
+		t.Fatal("unexpected series in index")
+	}
+}
+
 func TestShard_Close_RemoveIndex(t *testing.T) {
 	tmpDir, _ := ioutil.TempDir("", "shard_test")
 	defer os.RemoveAll(tmpDir)
@@ -219,7 +476,7 @@ cpu,host=serverB,region=uswest value=25  0
 	// Create iterator.
 	itr, err := sh.CreateIterator(influxql.IteratorOptions{
 		Expr:       influxql.MustParseExpr(`value`),
-		Aux:        []string{"val2"},
+		Aux:        []influxql.VarRef{{Val: "val2"}},
 		Dimensions: []string{"host"},
 		Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
 		Ascending:  true,
@@ -270,6 +527,173 @@ cpu,host=serverB,region=uswest value=25  0
 	}
 }
 
+// Ensure a read-only shard rejects writes, removes its WAL and serves reads.
+func TestShard_SetReadOnly(t *testing.T) {
+	sh := NewShard()
+	if err := sh.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer sh.Close()
+
+	sh.MustWritePointsString(`cpu,host=serverA value=100 1`)
+
+	if err := sh.SetReadOnly(true); err != nil {
+		t.Fatal(err)
+	} else if !sh.ReadOnly() {
+		t.Fatal("expected shard to be read-only")
+	}
+
+	if err := sh.WritePointsString(`cpu,host=serverA value=200 2`); err != tsdb.ErrShardReadOnly {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files, err := filepath.Glob(filepath.Join(sh.path, "wal", "*.wal")); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 0 {
+		t.Fatalf("unexpected WAL segments: %v", files)
+	}
+
+	// The shard stays read-only when it is reopened.
+	if err := sh.Shard.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := sh.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() []float64 {
+		itr, err := sh.CreateIterator(influxql.IteratorOptions{
+			Expr:      influxql.MustParseExpr(`value`),
+			Sources:   []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+			Ascending: true,
+			StartTime: influxql.MinTime,
+			EndTime:   influxql.MaxTime,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer itr.Close()
+
+		var a []float64
+		for {
+			p, err := itr.(influxql.FloatIterator).Next()
+			if err != nil {
+				t.Fatal(err)
+			} else if p == nil {
+				return a
+			}
+			a = append(a, p.Value)
+		}
+	}
+	if got := read(); !reflect.DeepEqual(got, []float64{100}) {
+		t.Fatalf("unexpected values: %v", got)
+	}
+	if err := sh.WritePointsString(`cpu,host=serverA value=200 2`); err != tsdb.ErrShardReadOnly {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Writes succeed once the shard is writable again.
+	if err := sh.SetReadOnly(false); err != nil {
+		t.Fatal(err)
+	} else if err := sh.WritePointsString(`cpu,host=serverA value=200 2`); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); !reflect.DeepEqual(got, []float64{100, 200}) {
+		t.Fatalf("unexpected values: %v", got)
+	}
+}
+
+// Ensure the generation of a shard changes with writes and deletes only.
+func TestShard_Generation(t *testing.T) {
+	sh := NewShard()
+	if err := sh.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer sh.Close()
+
+	gen := sh.Generation()
+	sh.MustWritePointsString(`cpu,host=serverA value=100 1`)
+	if g := sh.Generation(); g == gen {
+		t.Fatal("expected generation to change after write")
+	} else {
+		gen = g
+	}
+
+	if _, err := sh.CreateIterator(influxql.IteratorOptions{
+		Expr:      influxql.MustParseExpr(`value`),
+		Sources:   []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+		Ascending: true,
+		StartTime: influxql.MinTime,
+		EndTime:   influxql.MaxTime,
+	}); err != nil {
+		t.Fatal(err)
+	} else if g := sh.Generation(); g != gen {
+		t.Fatal("expected generation not to change after read")
+	}
+
+	if err := sh.DeleteSeriesRange([]string{"cpu,host=serverA"}, 0, 1); err != nil {
+		t.Fatal(err)
+	} else if g := sh.Generation(); g == gen {
+		t.Fatal("expected generation to change after delete")
+	}
+}
+
+// Ensure a shard records statistics for writes and queries.
+func TestShard_Statistics(t *testing.T) {
+	sh := NewShard()
+	if err := sh.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer sh.Close()
+
+	sh.MustWritePointsString(`cpu,host=serverA value=100 1`)
+
+	// Write the cache to a TSM file so reads decode blocks.
+	dir, err := sh.CreateSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(dir)
+
+	itr, err := sh.CreateIterator(influxql.IteratorOptions{
+		Expr:      influxql.MustParseExpr(`value`),
+		Sources:   []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+		Ascending: true,
+		StartTime: influxql.MinTime,
+		EndTime:   influxql.MaxTime,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := itr.(influxql.FloatIterator).Next(); err != nil {
+		t.Fatal(err)
+	} else if p == nil || p.Value != 100 {
+		t.Fatalf("unexpected point: %s", spew.Sdump(p))
+	}
+	itr.Close()
+
+	stat := func(key, name string) string {
+		v := expvar.Get(key)
+		if v == nil {
+			t.Fatalf("statistics not found: %s", key)
+		}
+		return v.(*expvar.Map).Get("values").(*expvar.Map).Get(name).String()
+	}
+
+	dataPath := filepath.Join(sh.path, "data")
+	for _, tt := range []struct {
+		key, name, exp string
+	}{
+		{key: fmt.Sprintf("shard:%s:0", dataPath), name: "writePointsOk", exp: "1"},
+		{key: fmt.Sprintf("shard:%s:0", dataPath), name: "writePointsBytes", exp: strconv.Itoa(len("cpu,host=serverA value=100 1000000000"))},
+		{key: fmt.Sprintf("shard:%s:0", dataPath), name: "queryReq", exp: "1"},
+		{key: "tsm1_engine:" + dataPath, name: "cursorsCreated", exp: "1"},
+		{key: "tsm1_filestore:" + dataPath, name: "blocksDecoded", exp: "1"},
+	} {
+		if got := stat(tt.key, tt.name); got != tt.exp {
+			t.Errorf("%s: unexpected value: exp %s, got %s", tt.name, tt.exp, got)
+		}
+	}
+}
+
 // Ensure a shard can create iterators for its underlying data.
 func TestShard_CreateIterator_Descending(t *testing.T) {
 	sh := NewShard()
@@ -295,7 +719,7 @@ cpu,host=serverB,region=uswest value=25  0
 	// Create iterator.
 	itr, err := sh.CreateIterator(influxql.IteratorOptions{
 		Expr:       influxql.MustParseExpr(`value`),
-		Aux:        []string{"val2"},
+		Aux:        []influxql.VarRef{{Val: "val2"}},
 		Dimensions: []string{"host"},
 		Sources:    []influxql.Source{&influxql.Measurement{Name: "cpu"}},
 		Ascending:  false,
@@ -520,12 +944,16 @@ func (sh *Shard) Close() error {
 // MustWritePointsString parses the line protocol (with second precision) and
 // inserts the resulting points into the shard. Panic on error.
 func (sh *Shard) MustWritePointsString(s string) {
-	a, err := models.ParsePointsWithPrecision([]byte(strings.TrimSpace(s)), time.Time{}, "s")
-	if err != nil {
+	if err := sh.WritePointsString(s); err != nil {
 		panic(err)
 	}
+}
 
-	if err := sh.WritePoints(a); err != nil {
-		panic(err)
+// WritePointsString parses a string buffer and writes the points.
+func (sh *Shard) WritePointsString(s string) error {
+	a, err := models.ParsePointsWithPrecision([]byte(strings.TrimSpace(s)), time.Time{}, "s")
+	if err != nil {
+		return err
 	}
+	return sh.WritePoints(a)
 }
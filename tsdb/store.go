@@ -6,8 +6,10 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/monitor/diagnostics"
 )
 
 var (
@@ -27,6 +30,16 @@ var (
 
 const (
 	maintenanceCheckInterval = time.Minute
+
+	// shardOpenProgressInterval is how often the progress of opening shards
+	// is logged.
+	shardOpenProgressInterval = 10 * time.Second
+
+	// shardMoveTmpExt and shardMoveOldExt are the extensions of the
+	// destination and source directories of a shard while it is moved
+	// between the data and cold data directories.
+	shardMoveTmpExt = ".tmp"
+	shardMoveOldExt = ".moved"
 )
 
 // Store manages shards and indexes for databases.
@@ -45,6 +58,10 @@ type Store struct {
 	// logOutput is where output from the underlying databases will go.
 	logOutput io.Writer
 
+	// compactionsDisabled is set when compactions are paused for every
+	// shard so that newly created shards are paused as well.
+	compactionsDisabled bool
+
 	closing chan struct{}
 	wg      sync.WaitGroup
 	opened  bool
@@ -95,6 +112,13 @@ func (s *Store) Open() error {
 		return err
 	}
 
+	if dir := s.EngineOptions.Config.ColdDir; dir != "" {
+		s.Logger.Printf("Using cold data dir: %v", dir)
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return err
+		}
+	}
+
 	// TODO: Start AE for Node
 	if err := s.loadIndexes(); err != nil {
 		return err
@@ -104,22 +128,45 @@ func (s *Store) Open() error {
 		return err
 	}
 
+	if s.EngineOptions.Config.ColdDir != "" && s.EngineOptions.Config.ColdShardAge > 0 {
+		s.wg.Add(1)
+		go s.monitorColdShards()
+	}
+
+	if s.EngineOptions.Config.SeriesIdleTimeout > 0 {
+		s.wg.Add(1)
+		go s.monitorIdleSeries()
+	}
+
 	s.opened = true
 
 	return nil
 }
 
-func (s *Store) loadIndexes() error {
-	dbs, err := ioutil.ReadDir(s.path)
-	if err != nil {
-		return err
+// roots returns the directories holding shards: the data directory and the
+// cold data directory, if one is configured.
+func (s *Store) roots() []string {
+	if dir := s.EngineOptions.Config.ColdDir; dir != "" {
+		return []string{s.path, dir}
 	}
-	for _, db := range dbs {
-		if !db.IsDir() {
-			s.Logger.Printf("Skipping database dir: %s. Not a directory", db.Name())
-			continue
+	return []string{s.path}
+}
+
+func (s *Store) loadIndexes() error {
+	for _, root := range s.roots() {
+		dbs, err := ioutil.ReadDir(root)
+		if err != nil {
+			return err
+		}
+		for _, db := range dbs {
+			if !db.IsDir() {
+				s.Logger.Printf("Skipping database dir: %s. Not a directory", db.Name())
+				continue
+			}
+			if _, ok := s.databaseIndexes[db.Name()]; !ok {
+				s.databaseIndexes[db.Name()] = NewDatabaseIndex(db.Name())
+			}
 		}
-		s.databaseIndexes[db.Name()] = NewDatabaseIndex(db.Name())
 	}
 	return nil
 }
@@ -131,71 +178,161 @@ func (s *Store) loadShards() error {
 		err error
 	}
 
-	throttle := newthrottle(4)
+	concurrency := s.EngineOptions.Config.MaxConcurrentShardOpens
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	throttle := newthrottle(concurrency)
+	lazyAge := time.Duration(s.EngineOptions.Config.LazyShardOpenAge)
 
 	resC := make(chan *res)
 	var n int
 
-	// loop through the current database indexes
-	for db := range s.databaseIndexes {
-		rps, err := ioutil.ReadDir(filepath.Join(s.path, db))
-		if err != nil {
-			return err
-		}
+	// Shards found under more than one root are only opened from the first.
+	seen := make(map[string]string)
 
-		for _, rp := range rps {
-			// retention policies should be directories.  Skip anything that is not a dir.
-			if !rp.IsDir() {
-				s.Logger.Printf("Skipping retention policy dir: %s. Not a directory", rp.Name())
+	// loop through the current database indexes
+	for _, root := range s.roots() {
+		for db := range s.databaseIndexes {
+			rps, err := ioutil.ReadDir(filepath.Join(root, db))
+			if os.IsNotExist(err) {
 				continue
-			}
-
-			shards, err := ioutil.ReadDir(filepath.Join(s.path, db, rp.Name()))
-			if err != nil {
+			} else if err != nil {
 				return err
 			}
-			for _, sh := range shards {
-				n++
-				go func(index *DatabaseIndex, db, rp, sh string) {
-					throttle.take()
-					defer throttle.release()
-
-					start := time.Now()
-					path := filepath.Join(s.path, db, rp, sh)
-					walPath := filepath.Join(s.EngineOptions.Config.WALDir, db, rp, sh)
-
-					// Shard file names are numeric shardIDs
-					shardID, err := strconv.ParseUint(sh, 10, 64)
-					if err != nil {
-						resC <- &res{err: fmt.Errorf("%s is not a valid ID. Skipping shard.", sh)}
-						return
-					}
 
-					shard := NewShard(shardID, s.databaseIndexes[db], path, walPath, s.EngineOptions)
-					shard.SetLogOutput(s.logOutput)
+			for _, rp := range rps {
+				// retention policies should be directories.  Skip anything that is not a dir.
+				if !rp.IsDir() {
+					s.Logger.Printf("Skipping retention policy dir: %s. Not a directory", rp.Name())
+					continue
+				}
 
-					err = shard.Open()
-					if err != nil {
-						resC <- &res{err: fmt.Errorf("Failed to open shard: %d: %s", shardID, err)}
-						return
+				shards, err := ioutil.ReadDir(filepath.Join(root, db, rp.Name()))
+				if err != nil {
+					return err
+				}
+				for _, sh := range shards {
+					path := filepath.Join(root, db, rp.Name(), sh.Name())
+
+					// Remove the leftovers of an interrupted shard move.
+					if ext := filepath.Ext(path); ext == shardMoveTmpExt || ext == shardMoveOldExt {
+						s.Logger.Printf("Removing %s left by an interrupted shard move", path)
+						if err := os.RemoveAll(path); err != nil {
+							return err
+						}
+						continue
 					}
 
-					resC <- &res{s: shard}
-					s.Logger.Printf("%s opened in %s", path, time.Now().Sub(start))
-				}(s.databaseIndexes[db], db, rp.Name(), sh.Name())
+					key := filepath.Join(db, rp.Name(), sh.Name())
+					if other, ok := seen[key]; ok {
+						s.Logger.Printf("Skipping %s: shard already loaded from %s", path, other)
+						continue
+					}
+					seen[key] = path
+
+					n++
+					go func(path, db, rp, sh string) {
+						throttle.take()
+						defer throttle.release()
+
+						start := time.Now()
+						walPath := filepath.Join(s.EngineOptions.Config.WALDir, db, rp, sh)
+
+						// Shard file names are numeric shardIDs
+						shardID, err := strconv.ParseUint(sh, 10, 64)
+						if err != nil {
+							resC <- &res{err: fmt.Errorf("%s is not a valid ID. Skipping shard.", sh)}
+							return
+						}
+
+						shard := NewShard(shardID, s.databaseIndexes[db], path, walPath, s.EngineOptions)
+						shard.SetLogOutput(s.logOutput)
+
+						// Shards that have not been modified recently are opened
+						// when they are first used.
+						if lazyAge > 0 && time.Since(lastModified(path, walPath)) > lazyAge {
+							shard.DeferOpen()
+							resC <- &res{s: shard}
+							s.Logger.Printf("%s not modified in %s, deferring open", path, lazyAge)
+							return
+						}
+
+						err = shard.Open()
+						if err != nil {
+							resC <- &res{err: fmt.Errorf("Failed to open shard: %d: %s", shardID, err)}
+							return
+						}
+
+						resC <- &res{s: shard}
+						s.Logger.Printf("%s opened in %s", path, time.Now().Sub(start))
+					}(path, db, rp.Name(), sh.Name())
+				}
 			}
 		}
 	}
 
-	for i := 0; i < n; i++ {
-		res := <-resC
-		if res.err != nil {
-			s.Logger.Println(res.err)
-			continue
+	if n > 0 {
+		s.Logger.Printf("Opening %d shards, %d at a time", n, concurrency)
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(shardOpenProgressInterval)
+	defer ticker.Stop()
+
+	var failed int
+	for i := 0; i < n; {
+		select {
+		case res := <-resC:
+			i++
+			if res.err != nil {
+				s.Logger.Println(res.err)
+				failed++
+				continue
+			}
+			s.shards[res.s.id] = res.s
+		case <-ticker.C:
+			s.Logger.Printf("Opened %d of %d shards in %s", i, n, time.Since(start))
 		}
-		s.shards[res.s.id] = res.s
 	}
 	close(resC)
+
+	if n > 0 {
+		s.Logger.Printf("Opened %d shards in %s, %d failed", n-failed, time.Since(start), failed)
+	}
+	return nil
+}
+
+// lastModified returns the latest modification time of the files under paths.
+// Paths that do not exist are ignored.
+func lastModified(paths ...string) time.Time {
+	var t time.Time
+	for _, path := range paths {
+		filepath.Walk(path, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if fi.ModTime().After(t) {
+				t = fi.ModTime()
+			}
+			return nil
+		})
+	}
+	return t
+}
+
+// openDeferredShards opens the shards of database whose opening was deferred
+// and reloads evicted series so that all series are in the database index.
+// The caller must hold the store lock.
+func (s *Store) openDeferredShards(database string) error {
+	for _, sh := range s.shards {
+		if sh.database != database {
+			continue
+		}
+		if err := sh.loadEvicted(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -210,6 +347,10 @@ func (s *Store) Close() error {
 	}
 	s.wg.Wait()
 
+	if s.EngineOptions.Config.CacheFlushOnShutdown {
+		s.flushShards()
+	}
+
 	for _, sh := range s.shards {
 		if err := sh.Close(); err != nil {
 			return err
@@ -255,6 +396,23 @@ func (s *Store) Shards(ids []uint64) []*Shard {
 	return a
 }
 
+// ShardGenerations returns the generation of each shard in ids.  It returns
+// false if any of the shards is not in the store, since the data of a shard
+// held by another node can change without its generation being seen here.
+func (s *Store) ShardGenerations(ids []uint64) ([]uint64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a := make([]uint64, len(ids))
+	for i, id := range ids {
+		sh, ok := s.shards[id]
+		if !ok {
+			return nil, false
+		}
+		a[i] = sh.Generation()
+	}
+	return a, true
+}
+
 // ShardN returns the number of shards in the store.
 func (s *Store) ShardN() int {
 	s.mu.RLock()
@@ -302,12 +460,300 @@ func (s *Store) CreateShard(database, retentionPolicy string, shardID uint64) er
 	if err := shard.Open(); err != nil {
 		return err
 	}
+	if s.compactionsDisabled {
+		if err := shard.SetCompactionsEnabled(false); err != nil {
+			return err
+		}
+	}
 
 	s.shards[shardID] = shard
 
 	return nil
 }
 
+// MoveShard moves the files of a shard to the cold data directory if cold is
+// true, or back to the data directory otherwise.  Files moved to another file
+// system are copied while the shard keeps serving queries and writes.  The
+// shard is then closed only to copy the files changed since and is reopened
+// from its new location.  Callers block on the shard meanwhile.
+func (s *Store) MoveShard(shardID uint64, cold bool) error {
+	root := s.path
+	if cold {
+		if root = s.EngineOptions.Config.ColdDir; root == "" {
+			return errors.New("cold data directory not configured")
+		}
+	}
+
+	s.mu.RLock()
+	sh, ok := s.shards[shardID]
+	if !ok {
+		s.mu.RUnlock()
+		return ErrShardNotFound
+	} else if s.isCold(sh) == cold {
+		s.mu.RUnlock()
+		return nil
+	}
+	src := sh.path
+	s.mu.RUnlock()
+
+	start := time.Now()
+	path := filepath.Join(root, sh.database, sh.retentionPolicy, strconv.FormatUint(shardID, 10))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("move shard %d: %s", shardID, err)
+	}
+
+	// Copy to a temporary directory first so that an interrupted move never
+	// leaves a partial shard under its final name.  Compactions are paused so
+	// files are not replaced while they are copied.
+	tmp := path + shardMoveTmpExt
+	rename := sameFileSystem(filepath.Dir(src), filepath.Dir(path))
+	if !rename {
+		sh.pauseCompactions(true)
+		defer sh.pauseCompactions(false)
+
+		if err := os.RemoveAll(tmp); err != nil {
+			return fmt.Errorf("move shard %d: %s", shardID, err)
+		}
+		if err := syncDir(src, tmp); err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("move shard %d: %s", shardID, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The shard may have been deleted or moved while its files were copied.
+	if s.shards[shardID] != sh || sh.path != src {
+		return os.RemoveAll(tmp)
+	}
+
+	if err := sh.move(path, func() error {
+		if rename {
+			return os.Rename(src, path)
+		}
+
+		// Copy what changed since, such as new snapshots and tombstones.
+		if err := syncDir(src, tmp); err != nil {
+			return err
+		} else if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+		return os.Rename(src, src+shardMoveOldExt)
+	}); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("move shard %d: %s", shardID, err)
+	}
+
+	if !rename {
+		if err := os.RemoveAll(src + shardMoveOldExt); err != nil {
+			s.Logger.Printf("Failed to remove moved shard %d from %s: %s", shardID, src, err)
+		}
+	}
+
+	s.Logger.Printf("Moved shard %d to %s in %s", shardID, path, time.Since(start))
+	return nil
+}
+
+// isCold returns true if the files of sh are in the cold data directory.
+func (s *Store) isCold(sh *Shard) bool {
+	return s.EngineOptions.Config.InColdDir(sh.path)
+}
+
+// monitorColdShards periodically moves shards that have not been modified
+// within the cold shard age to the cold data directory.
+func (s *Store) monitorColdShards() {
+	defer s.wg.Done()
+
+	age := time.Duration(s.EngineOptions.Config.ColdShardAge)
+	t := time.NewTicker(maintenanceCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-t.C:
+			s.mu.RLock()
+			var ids []uint64
+			for id, sh := range s.shards {
+				if !s.isCold(sh) && time.Since(lastModified(sh.path, sh.walPath)) > age {
+					ids = append(ids, id)
+				}
+			}
+			s.mu.RUnlock()
+
+			for _, id := range ids {
+				if err := s.MoveShard(id, true); err != nil {
+					s.Logger.Printf("Failed to move shard %d to cold data dir: %s", id, err)
+				}
+			}
+		}
+	}
+}
+
+// monitorIdleSeries periodically evicts series that have not been written or
+// queried within the series idle timeout from the database indexes.
+func (s *Store) monitorIdleSeries() {
+	defer s.wg.Done()
+
+	timeout := time.Duration(s.EngineOptions.Config.SeriesIdleTimeout)
+	t := time.NewTicker(maintenanceCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-t.C:
+			s.EvictIdleSeries(time.Now().Add(-timeout))
+		}
+	}
+}
+
+// EvictIdleSeries evicts the series not written or queried since t from the
+// database indexes and marks their shards so the series are reloaded when
+// next queried.
+func (s *Store) EvictIdleSeries(t time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, db := range s.databaseIndexes {
+		for id, series := range db.EvictIdleSeries(t) {
+			if sh := s.shards[id]; sh != nil {
+				sh.setEvicted(series)
+			}
+		}
+	}
+}
+
+// flushShards writes the caches of the open shards to their data files.  A
+// shard that fails to flush keeps its data in the WAL, so errors are only
+// logged.  The caller must hold the store lock.
+func (s *Store) flushShards() {
+	concurrency := s.EngineOptions.Config.MaxConcurrentShardOpens
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	throttle := newthrottle(concurrency)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, sh := range s.shards {
+		wg.Add(1)
+		go func(sh *Shard) {
+			defer wg.Done()
+			throttle.take()
+			defer throttle.release()
+
+			if err := sh.FlushCache(); err != nil && err != ErrEngineClosed {
+				s.Logger.Printf("error flushing cache of shard %d: %s", sh.id, err)
+			}
+		}(sh)
+	}
+	wg.Wait()
+	s.Logger.Printf("flushed shard caches in %s", time.Now().Sub(start))
+}
+
+// sameFileSystem returns true if files can be renamed from the directory a
+// to the directory b.
+func sameFileSystem(a, b string) bool {
+	f, err := ioutil.TempFile(a, ".move")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	target := filepath.Join(b, filepath.Base(f.Name()))
+	if err := os.Rename(f.Name(), target); err != nil {
+		return false
+	}
+	os.Remove(target)
+	return true
+}
+
+// syncDir copies the files under src to dst that are missing from dst or
+// differ in size or modification time, and removes the files under dst that
+// are not under src.  Files removed from src while it is copied are skipped.
+func syncDir(src, dst string) error {
+	if err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if fi.IsDir() {
+			return os.MkdirAll(target, fi.Mode())
+		}
+
+		if tfi, err := os.Stat(target); err == nil && tfi.Size() == fi.Size() && tfi.ModTime().Equal(fi.ModTime()) {
+			return nil
+		}
+		if err := copyFile(path, target, fi); os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return os.Chtimes(target, fi.ModTime(), fi.ModTime())
+	}); err != nil {
+		return err
+	}
+
+	return filepath.Walk(dst, func(path string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(src, rel)); !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		} else if fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// copyFile copies the file at src, whose info is fi, to dst.
+func copyFile(src, dst string, fi os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // DeleteShard removes a shard from disk.
 func (s *Store) DeleteShard(shardID uint64) error {
 	s.mu.Lock()
@@ -336,10 +782,105 @@ func (s *Store) deleteShard(shardID uint64) error {
 		return err
 	}
 
+	// Remove the shard's compaction temp directory if one is configured.
+	if dir := s.EngineOptions.Config.CompactTempDir; dir != "" {
+		db, rp := DecodeStorePath(sh.path)
+		if err := os.RemoveAll(filepath.Join(dir, db, rp, filepath.Base(sh.path))); err != nil {
+			return err
+		}
+	}
+
 	delete(s.shards, shardID)
 	return nil
 }
 
+// ScheduleFullCompaction requests a full compaction of the shard with the given id.
+func (s *Store) ScheduleFullCompaction(shardID uint64) error {
+	sh := s.Shard(shardID)
+	if sh == nil {
+		return ErrShardNotFound
+	}
+	return sh.ScheduleFullCompaction()
+}
+
+// SetShardReadOnly makes the shard with the given id read-only or writable
+// again.
+func (s *Store) SetShardReadOnly(shardID uint64, readOnly bool) error {
+	sh := s.Shard(shardID)
+	if sh == nil {
+		return ErrShardNotFound
+	}
+	return sh.SetReadOnly(readOnly)
+}
+
+// SetCompactionsEnabled pauses or resumes compactions for the shard with the
+// given id.  A shard id of zero applies to all shards, including shards
+// created later.
+func (s *Store) SetCompactionsEnabled(shardID uint64, enabled bool) error {
+	if shardID != 0 {
+		sh := s.Shard(shardID)
+		if sh == nil {
+			return ErrShardNotFound
+		}
+		return sh.SetCompactionsEnabled(enabled)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compactionsDisabled = !enabled
+	for _, sh := range s.shards {
+		if err := sh.SetCompactionsEnabled(enabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compactions returns the status of the compactions running across all shards.
+func (s *Store) Compactions() []CompactionStatus {
+	s.mu.RLock()
+	shards := s.shardsSlice()
+	s.mu.RUnlock()
+
+	var a []CompactionStatus
+	for _, sh := range shards {
+		a = append(a, sh.Compactions()...)
+	}
+	sort.Sort(compactionStatuses(a))
+	return a
+}
+
+// CompactionDiagnostics returns diagnostics for the running compactions.
+func (s *Store) CompactionDiagnostics() (*diagnostics.Diagnostics, error) {
+	d := diagnostics.NewDiagnostics([]string{"shard", "level", "files", "bytes_processed", "bytes_total", "duration", "eta"})
+
+	now := time.Now()
+	for _, c := range s.Compactions() {
+		d.AddRow([]interface{}{
+			c.ShardID,
+			c.Level,
+			len(c.Files),
+			c.BytesProcessed,
+			c.TotalBytes,
+			now.Sub(c.Start).String(),
+			c.ETA(now).String(),
+		})
+	}
+	return d, nil
+}
+
+// compactionStatuses sorts compactions by shard and start time.
+type compactionStatuses []CompactionStatus
+
+func (a compactionStatuses) Len() int      { return len(a) }
+func (a compactionStatuses) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a compactionStatuses) Less(i, j int) bool {
+	if a[i].ShardID != a[j].ShardID {
+		return a[i].ShardID < a[j].ShardID
+	}
+	return a[i].Start.Before(a[j].Start)
+}
+
 // ShardIteratorCreator returns an iterator creator for a shard.
 func (s *Store) ShardIteratorCreator(id uint64) influxql.IteratorCreator {
 	sh := s.Shard(id)
@@ -364,8 +905,10 @@ func (s *Store) DeleteDatabase(name string) error {
 		}
 	}
 
-	if err := os.RemoveAll(filepath.Join(s.path, name)); err != nil {
-		return err
+	for _, root := range s.roots() {
+		if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+			return err
+		}
 	}
 	if err := os.RemoveAll(filepath.Join(s.EngineOptions.Config.WALDir, name)); err != nil {
 		return err
@@ -394,8 +937,10 @@ func (s *Store) DeleteRetentionPolicy(database, name string) error {
 	}
 
 	// Remove the rentention policy folder.
-	if err := os.RemoveAll(filepath.Join(s.path, database, name)); err != nil {
-		return err
+	for _, root := range s.roots() {
+		if err := os.RemoveAll(filepath.Join(root, database, name)); err != nil {
+			return err
+		}
 	}
 
 	// Remove the retention policy folder from the the WAL.
@@ -413,6 +958,10 @@ func (s *Store) DeleteMeasurement(database, name string) error {
 		return nil
 	}
 
+	if err := s.openDeferredShards(database); err != nil {
+		return err
+	}
+
 	// Find the measurement.
 	m := db.Measurement(name)
 	if m == nil {
@@ -513,12 +1062,37 @@ func (s *Store) BackupShard(id uint64, since time.Time, w io.Writer) error {
 		return fmt.Errorf("shard %d doesn't exist on this server", id)
 	}
 
-	path, err := relativePath(s.path, shard.path)
+	path, err := s.shardRelativePath(shard)
+	if err != nil {
+		return err
+	}
+
+	e, err := shard.ready()
 	if err != nil {
 		return err
 	}
+	return e.Backup(w, path, since)
+}
 
-	return shard.engine.Backup(w, path, since)
+// ImportShard adds the data files of a tar archive written by BackupShard to
+// the shard with the given id.
+func (s *Store) ImportShard(id uint64, r io.Reader) error {
+	shard := s.Shard(id)
+	if shard == nil {
+		return fmt.Errorf("shard %d doesn't exist on this server", id)
+	}
+	return shard.Import(r)
+}
+
+// CreateShardSnapshot creates a point-in-time snapshot of the data files of
+// the shard with the given id using hard links and returns the path of the
+// directory holding it.  The caller must remove the directory when done.
+func (s *Store) CreateShardSnapshot(id uint64) (string, error) {
+	sh := s.Shard(id)
+	if sh == nil {
+		return "", ErrShardNotFound
+	}
+	return sh.CreateSnapshot()
 }
 
 // ShardRelativePath will return the relative path to the shard. i.e. <database>/<retention>/<id>
@@ -527,7 +1101,16 @@ func (s *Store) ShardRelativePath(id uint64) (string, error) {
 	if shard == nil {
 		return "", fmt.Errorf("shard %d doesn't exist on this server", id)
 	}
-	return relativePath(s.path, shard.path)
+	return s.shardRelativePath(shard)
+}
+
+// shardRelativePath returns the path of sh relative to the directory holding
+// it.
+func (s *Store) shardRelativePath(sh *Shard) (string, error) {
+	if s.isCold(sh) {
+		return relativePath(s.EngineOptions.Config.ColdDir, sh.path)
+	}
+	return relativePath(s.path, sh.path)
 }
 
 // DeleteSeries loops through the local shards and deletes the series data and metadata for the passed in series keys
@@ -541,11 +1124,22 @@ func (s *Store) DeleteSeries(database string, sources []influxql.Source, conditi
 	}
 	sources = a
 
-	// Determine deletion time range.
-	min, max, err := influxql.TimeRangeAsEpochNano(condition)
+	// Determine deletion time range.  An unbounded side deletes every value
+	// on that side, including values before the epoch or in the future.
+	tmin, tmax, err := influxql.TimeRange(condition)
 	if err != nil {
 		return err
 	}
+	min, max := int64(math.MinInt64), int64(math.MaxInt64)
+	if !tmin.IsZero() {
+		min = tmin.UnixNano()
+	}
+	if !tmax.IsZero() {
+		max = tmax.UnixNano()
+	}
+	if min > max {
+		return nil
+	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -556,6 +1150,10 @@ func (s *Store) DeleteSeries(database string, sources []influxql.Source, conditi
 		return nil
 	}
 
+	if err := s.openDeferredShards(database); err != nil {
+		return err
+	}
+
 	measurements, err := measurementsFromSourcesOrDB(db, sources...)
 	if err != nil {
 		return err
@@ -609,24 +1207,177 @@ func (s *Store) deleteSeries(database string, seriesKeys []string, min, max int6
 		if sh.database != database {
 			continue
 		}
-		if err := sh.DeleteSeriesRange(seriesKeys, min, max); err != nil {
+		if err := s.deleteShardSeries(db, sh, seriesKeys, min, max); err != nil {
 			return err
 		}
+	}
 
-		// The keys we passed in may be fully deleted from the shard, if so,
-		// we need to remove the shard from all the meta data indexes
-		existing, err := sh.ContainsSeries(seriesKeys)
-		if err != nil {
+	return nil
+}
+
+// DeleteMeasurementRange deletes the data of a measurement between min and
+// max from the given shards of a database.
+func (s *Store) DeleteMeasurementRange(database, name string, shardIDs []uint64, min, max int64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	db := s.databaseIndexes[database]
+	if db == nil {
+		return nil
+	}
+
+	var shards []*Shard
+	for _, id := range shardIDs {
+		sh := s.shards[id]
+		if sh == nil || sh.database != database {
+			continue
+		}
+		if err := sh.loadEvicted(); err != nil {
 			return err
 		}
+		shards = append(shards, sh)
+	}
 
-		for k, exists := range existing {
-			if !exists {
-				db.UnassignShard(k, sh.id)
+	m := db.Measurement(name)
+	if m == nil {
+		return nil
+	}
+	seriesKeys := m.SeriesKeys()
+
+	for _, sh := range shards {
+		if err := s.deleteShardSeries(db, sh, seriesKeys, min, max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteShardSeries deletes the series data between min and max from a shard
+// and removes the series left without data from the database index.
+func (s *Store) deleteShardSeries(db *DatabaseIndex, sh *Shard, seriesKeys []string, min, max int64) error {
+	if err := sh.DeleteSeriesRange(seriesKeys, min, max); err != nil {
+		return err
+	}
+
+	// The keys we passed in may be fully deleted from the shard, if so,
+	// we need to remove the shard from all the meta data indexes
+	existing, err := sh.ContainsSeries(seriesKeys)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]struct{})
+	for k, exists := range existing {
+		if !exists {
+			db.UnassignShard(k, sh.id)
+			names[MeasurementFromSeriesKey(k)] = struct{}{}
+		}
+	}
+
+	// Release the fields of measurements left without series in the shard.
+	for name := range names {
+		if m := db.Measurement(name); m != nil && m.hasShard(sh.id) {
+			continue
+		}
+		if err := sh.DeleteMeasurement(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SeriesCardinality returns the number of series in each measurement of
+// database that match sources and condition.
+func (s *Store) SeriesCardinality(database string, sources influxql.Sources, condition influxql.Expr) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	if err := s.walkSeriesIDs(database, sources, condition, func(m *Measurement, ids SeriesIDs) {
+		counts[m.Name] = int64(len(ids))
+	}); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// MeasurementCardinality returns the number of measurements in database that
+// match sources and have series matching condition.
+func (s *Store) MeasurementCardinality(database string, sources influxql.Sources, condition influxql.Expr) (int64, error) {
+	var n int64
+	if err := s.walkSeriesIDs(database, sources, condition, func(m *Measurement, ids SeriesIDs) {
+		if len(ids) > 0 {
+			n++
+		}
+	}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// TagValuesCardinality returns the number of distinct values of tagKeys in
+// each measurement of database that match sources, counting only the series
+// matching condition.
+func (s *Store) TagValuesCardinality(database string, sources influxql.Sources, tagKeys []string, condition influxql.Expr) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	if err := s.walkSeriesIDs(database, sources, condition, func(m *Measurement, ids SeriesIDs) {
+		values := make(map[string]map[string]struct{}, len(tagKeys))
+		for _, id := range ids {
+			ss := m.SeriesByID(id)
+			if ss == nil {
+				continue
 			}
+			for _, k := range tagKeys {
+				v, ok := ss.Tags[k]
+				if !ok {
+					continue
+				}
+				if values[k] == nil {
+					values[k] = make(map[string]struct{})
+				}
+				values[k][v] = struct{}{}
+			}
+		}
+
+		var n int64
+		for _, a := range values {
+			n += int64(len(a))
 		}
+		if n > 0 {
+			counts[m.Name] = n
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// walkSeriesIDs calls fn with the IDs of the series matching condition in
+// each measurement of database that matches sources.  Shards of the database
+// whose opening was deferred are opened first so that their series are
+// counted.
+func (s *Store) walkSeriesIDs(database string, sources influxql.Sources, condition influxql.Expr, fn func(m *Measurement, ids SeriesIDs)) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	db := s.databaseIndexes[database]
+	if db == nil {
+		return influxql.ErrDatabaseNotFound(database)
+	}
+
+	if err := s.openDeferredShards(database); err != nil {
+		return err
+	}
+
+	measurements, err := measurementsFromSourcesOrDB(db, sources...)
+	if err != nil {
+		return err
 	}
 
+	for _, m := range measurements {
+		ids, err := m.seriesIDsByCondition(condition)
+		if err != nil {
+			return err
+		}
+		fn(m, ids)
+	}
 	return nil
 }
 
@@ -771,14 +1522,22 @@ func relativePath(storePath, shardPath string) (string, error) {
 func measurementsFromSourcesOrDB(db *DatabaseIndex, sources ...influxql.Source) (Measurements, error) {
 	var measurements Measurements
 	if len(sources) > 0 {
+		seen := make(map[string]struct{})
 		for _, source := range sources {
 			if m, ok := source.(*influxql.Measurement); ok {
-				measurement := db.measurements[m.Name]
-				if measurement == nil {
-					continue
+				var a Measurements
+				if m.Regex != nil {
+					a = db.MeasurementsByRegex(m.Regex.Val)
+				} else if measurement := db.Measurement(m.Name); measurement != nil {
+					a = Measurements{measurement}
 				}
 
-				measurements = append(measurements, measurement)
+				for _, measurement := range a {
+					if _, ok := seen[measurement.Name]; !ok {
+						seen[measurement.Name] = struct{}{}
+						measurements = append(measurements, measurement)
+					}
+				}
 			} else {
 				return nil, errors.New("identifiers in FROM clause must be measurement names")
 			}
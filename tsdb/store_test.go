@@ -1,11 +1,16 @@
 package tsdb_test
 
 import (
+	"bytes"
+	"expvar"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,6 +18,7 @@ import (
 	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/pkg/deep"
+	"github.com/influxdata/influxdb/toml"
 	"github.com/influxdata/influxdb/tsdb"
 )
 
@@ -136,6 +142,258 @@ func TestStore_DeleteShard(t *testing.T) {
 	}
 }
 
+// Ensure the store can move a shard between the data and cold data directories.
+func TestStore_MoveShard(t *testing.T) {
+	coldDir, err := ioutil.TempDir("", "influxdb-tsdb-cold-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(coldDir)
+
+	s := NewStore()
+	s.EngineOptions.Config.ColdDir = coldDir
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 1, "cpu,host=serverA value=1 0")
+	s.MustCreateShardWithData("db0", "rp0", 2, "cpu,host=serverB value=1 0")
+
+	if err := s.MoveShard(1, true); err != nil {
+		t.Fatal(err)
+	} else if path := s.Shard(1).Path(); path != filepath.Join(coldDir, "db0", "rp0", "1") {
+		t.Fatalf("unexpected shard path: %s", path)
+	} else if _, err := os.Stat(filepath.Join(s.Path(), "db0", "rp0", "1")); !os.IsNotExist(err) {
+		t.Fatalf("expected shard to be removed from data dir: %v", err)
+	} else if m, err := s.Shard(1).ContainsSeries([]string{"cpu,host=serverA"}); err != nil || !m["cpu,host=serverA"] {
+		t.Fatalf("expected series in shard: %v", err)
+	} else if rel, err := s.ShardRelativePath(1); err != nil || rel != filepath.Join("db0", "rp0", "1") {
+		t.Fatalf("unexpected relative path: %s, %v", rel, err)
+	}
+
+	// Reopen the store and verify shards are loaded from both directories.
+	if err := s.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.Store = tsdb.NewStore(s.Path())
+	s.EngineOptions.Config.WALDir = filepath.Join(s.Path(), "wal")
+	s.EngineOptions.Config.ColdDir = coldDir
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	} else if n := s.ShardN(); n != 2 {
+		t.Fatalf("unexpected shard count: %d", n)
+	} else if m := s.Measurement("db0", "cpu"); m == nil || len(m.SeriesKeys()) != 2 {
+		t.Fatalf("unexpected measurement: %v", m)
+	}
+
+	// Move the shard back.
+	if err := s.MoveShard(1, false); err != nil {
+		t.Fatal(err)
+	} else if path := s.Shard(1).Path(); path != filepath.Join(s.Path(), "db0", "rp0", "1") {
+		t.Fatalf("unexpected shard path: %s", path)
+	} else if m, err := s.Shard(1).ContainsSeries([]string{"cpu,host=serverA"}); err != nil || !m["cpu,host=serverA"] {
+		t.Fatalf("expected series in shard: %v", err)
+	}
+
+	if err := s.MoveShard(3, true); err != tsdb.ErrShardNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a shard serves queries and writes while it is moved.
+func TestStore_MoveShard_Concurrent(t *testing.T) {
+	coldDir, err := ioutil.TempDir("", "influxdb-tsdb-cold-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(coldDir)
+
+	s := NewStore()
+	s.EngineOptions.Config.ColdDir = coldDir
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 1, "cpu,host=serverA value=0 0")
+
+	opt := influxql.IteratorOptions{
+		Expr:      influxql.MustParseExpr(`value`),
+		Sources:   []influxql.Source{&influxql.Measurement{Name: "cpu"}},
+		Ascending: true,
+		StartTime: influxql.MinTime,
+		EndTime:   influxql.MaxTime,
+	}
+	count := func() (int, error) {
+		itr, err := s.Shard(1).CreateIterator(opt)
+		if err != nil {
+			return 0, err
+		}
+		defer itr.Close()
+
+		var n int
+		for fitr := itr.(influxql.FloatIterator); ; n++ {
+			if p, err := fitr.Next(); err != nil {
+				return 0, err
+			} else if p == nil {
+				return n, nil
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	errC := make(chan error, 2)
+	writes := 1
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			p := models.MustNewPoint("cpu", models.Tags{"host": "serverA"}, models.Fields{"value": float64(i)}, time.Unix(int64(i), 0))
+			if err := s.WriteToShard(1, []models.Point{p}); err != nil {
+				errC <- fmt.Errorf("write: %s", err)
+				return
+			}
+			writes++
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if _, err := count(); err != nil {
+				errC <- fmt.Errorf("query: %s", err)
+				return
+			}
+		}
+	}()
+
+	for _, cold := range []bool{true, false, true} {
+		if err := s.MoveShard(1, cold); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	select {
+	case err := <-errC:
+		t.Fatal(err)
+	default:
+	}
+
+	if path := s.Shard(1).Path(); path != filepath.Join(coldDir, "db0", "rp0", "1") {
+		t.Fatalf("unexpected shard path: %s", path)
+	} else if n, err := count(); err != nil {
+		t.Fatal(err)
+	} else if n != writes {
+		t.Fatalf("unexpected point count: got %d, exp %d", n, writes)
+	}
+}
+
+// Ensure the store opens shards concurrently and logs its progress.
+func TestStore_Open_Concurrent(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	for i := 1; i <= 10; i++ {
+		if err := s.CreateShard(fmt.Sprintf("db%d", i%2), "rp0", uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	s.Store = tsdb.NewStore(s.Path())
+	s.EngineOptions.Config.WALDir = filepath.Join(s.Path(), "wal")
+	s.EngineOptions.Config.MaxConcurrentShardOpens = 2
+	s.SetLogOutput(&buf)
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	} else if n := s.ShardN(); n != 10 {
+		t.Fatalf("unexpected shard count: %d", n)
+	}
+
+	if !strings.Contains(buf.String(), "shards, 2 at a time") {
+		t.Fatalf("expected progress log, got: %s", buf.String())
+	} else if !strings.Contains(buf.String(), "Opened 10 shards in") {
+		t.Fatalf("expected summary log, got: %s", buf.String())
+	}
+}
+
+// Ensure shards that have not been modified recently are opened on first use.
+func TestStore_Open_LazyShards(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 1, "cpu,host=serverA value=1 0")
+	s.MustCreateShardWithData("db0", "rp0", 2, "mem,host=serverA value=1 0")
+	if err := s.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Age every file of the store.
+	old := time.Now().Add(-48 * time.Hour)
+	if err := filepath.Walk(s.Path(), func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, old, old)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Store = tsdb.NewStore(s.Path())
+	s.EngineOptions.Config.WALDir = filepath.Join(s.Path(), "wal")
+	s.EngineOptions.Config.LazyShardOpenAge = toml.Duration(time.Hour)
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	} else if n := s.ShardN(); n != 2 {
+		t.Fatalf("unexpected shard count: %d", n)
+	}
+
+	if !s.Shard(1).Deferred() || !s.Shard(2).Deferred() {
+		t.Fatal("expected shards to be deferred")
+	} else if m := s.Measurement("db0", "cpu"); m != nil {
+		t.Fatal("unexpected measurement in index before open")
+	}
+
+	// Writing to a deferred shard opens it and loads its series.
+	s.MustWriteToShardString(1, "cpu,host=serverB value=2 10")
+	if s.Shard(1).Deferred() {
+		t.Fatal("expected shard 1 to be opened")
+	} else if m := s.Measurement("db0", "cpu"); m == nil || len(m.SeriesKeys()) != 2 {
+		t.Fatalf("unexpected measurement: %v", m)
+	} else if !s.Shard(2).Deferred() {
+		t.Fatal("expected shard 2 to remain deferred")
+	}
+
+	// Deletes open the remaining shards of the database.
+	if err := s.DeleteMeasurement("db0", "mem"); err != nil {
+		t.Fatal(err)
+	} else if s.Shard(2).Deferred() {
+		t.Fatal("expected shard 2 to be opened")
+	} else if m := s.Measurement("db0", "mem"); m != nil {
+		t.Fatal("expected measurement to be deleted")
+	}
+}
+
 // Ensure the store reports an error when it can't open a database directory.
 func TestStore_Open_InvalidDatabaseFile(t *testing.T) {
 	s := NewStore()
@@ -196,6 +454,334 @@ func TestStore_Open_InvalidShard(t *testing.T) {
 	}
 }
 
+// Ensure the store deletes values outside of the epoch and now when the
+// time range of a delete is unbounded.
+func TestStore_DeleteSeries_TimeRange(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	future := time.Now().Add(24 * time.Hour).Unix()
+	s.MustCreateShardWithData("db0", "rp0", 0,
+		`cpu,host=serverA value=1 -10`,
+		`cpu,host=serverA value=2 10`,
+		`cpu,host=serverA value=3 20`,
+		fmt.Sprintf(`cpu,host=serverA value=4 %d`, future),
+	)
+
+	// Delete the values before 20s.
+	if err := s.DeleteSeries("db0", nil, influxql.MustParseExpr(`time < '1970-01-01T00:00:20Z'`)); err != nil {
+		t.Fatal(err)
+	} else if ok, err := s.Shard(0).ContainsSeries([]string{"cpu,host=serverA"}); err != nil {
+		t.Fatal(err)
+	} else if !ok["cpu,host=serverA"] {
+		t.Fatal("expected series to exist")
+	}
+
+	// Delete the remaining values, including the one in the future.
+	if err := s.DeleteSeries("db0", []influxql.Source{&influxql.Measurement{Name: "cpu"}}, influxql.MustParseExpr(`time >= '1970-01-01T00:00:20Z'`)); err != nil {
+		t.Fatal(err)
+	} else if ok, err := s.Shard(0).ContainsSeries([]string{"cpu,host=serverA"}); err != nil {
+		t.Fatal(err)
+	} else if ok["cpu,host=serverA"] {
+		t.Fatal("expected series to be deleted")
+	} else if ss := s.DatabaseIndex("db0").Series("cpu,host=serverA"); ss != nil {
+		t.Fatal("expected series to be removed from the index")
+	}
+}
+
+// Ensure dropping series releases the index entries, statistics and fields.
+func TestStore_DeleteSeries_ReleasesIndex(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 0,
+		`cpu,host=serverA value=1 0`,
+		`cpu,host=serverB value=2 0`,
+		`mem,host=serverA value=3 0`,
+	)
+
+	stat := func(name string) string {
+		return expvar.Get("database:db0").(*expvar.Map).Get("values").(*expvar.Map).Get(name).String()
+	}
+	if v := stat("memBytes"); v != "81" {
+		t.Fatalf("unexpected memBytes: %s", v)
+	}
+
+	if err := s.DeleteSeries("db0", []influxql.Source{&influxql.Measurement{Name: "cpu"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	index := s.DatabaseIndex("db0")
+	if m := index.Measurement("cpu"); m != nil {
+		t.Fatal("expected measurement to be removed from the index")
+	} else if n := index.SeriesN(); n != 1 {
+		t.Fatalf("unexpected series count: %d", n)
+	} else if v := stat("numSeries"); v != "1" {
+		t.Fatalf("unexpected numSeries: %s", v)
+	} else if v := stat("numMeasurements"); v != "1" {
+		t.Fatalf("unexpected numMeasurements: %s", v)
+	} else if v := stat("memBytes"); v != "27" {
+		t.Fatalf("unexpected memBytes: %s", v)
+	}
+
+	// The field types of the dropped measurement are forgotten.
+	s.MustWriteToShardString(0, `cpu,host=serverA value="on" 10`)
+}
+
+// Ensure the store can delete the old data of a measurement from some shards.
+func TestStore_DeleteMeasurementRange(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 0,
+		`cpu,host=serverA value=1 10`,
+		`cpu,host=serverB value=2 10`,
+		`cpu,host=serverB value=3 30`,
+		`mem,host=serverA value=4 10`,
+	)
+	s.MustCreateShardWithData("db0", "rp0", 1,
+		`cpu,host=serverA value=5 10`,
+	)
+
+	// Delete the cpu data before 20s from the first shard only.
+	if err := s.DeleteMeasurementRange("db0", "cpu", []uint64{0}, math.MinInt64, int64(20*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.Shard(0).ContainsSeries([]string{"cpu,host=serverA", "cpu,host=serverB", "mem,host=serverA"}); err != nil {
+		t.Fatal(err)
+	} else if exp := map[string]bool{"cpu,host=serverA": false, "cpu,host=serverB": true, "mem,host=serverA": true}; !reflect.DeepEqual(ok, exp) {
+		t.Fatalf("unexpected series in shard 0: %v", ok)
+	} else if ok, err := s.Shard(1).ContainsSeries([]string{"cpu,host=serverA"}); err != nil {
+		t.Fatal(err)
+	} else if !ok["cpu,host=serverA"] {
+		t.Fatal("expected series to remain in shard 1")
+	}
+
+	// The series remains in the index while another shard holds its data.
+	if ss := s.DatabaseIndex("db0").Series("cpu,host=serverA"); ss == nil {
+		t.Fatal("expected series to remain in the index")
+	} else if ss.Assigned(0) {
+		t.Fatal("expected series to be unassigned from shard 0")
+	}
+
+	// Unknown databases and measurements are ignored.
+	if err := s.DeleteMeasurementRange("db1", "cpu", []uint64{0}, math.MinInt64, math.MaxInt64); err != nil {
+		t.Fatal(err)
+	} else if err := s.DeleteMeasurementRange("db0", "disk", []uint64{0}, math.MinInt64, math.MaxInt64); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure idle series are evicted from the index and reloaded when queried.
+func TestStore_EvictIdleSeries(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 0,
+		`cpu,host=serverA value=1 0`,
+		`cpu,host=serverB value=2 0`,
+	)
+	index := s.DatabaseIndex("db0")
+
+	// Series accessed after the cutoff are kept.
+	s.EvictIdleSeries(time.Now().Add(-time.Hour))
+	if n := index.SeriesN(); n != 2 {
+		t.Fatalf("unexpected series count: %d", n)
+	}
+
+	s.EvictIdleSeries(time.Now().Add(time.Hour))
+	if n := index.SeriesN(); n != 0 {
+		t.Fatalf("unexpected series count after eviction: %d", n)
+	} else if m := index.Measurement("cpu"); m != nil {
+		t.Fatal("expected measurement to be evicted")
+	} else if v := expvar.Get("database:db0").(*expvar.Map).Get("values").(*expvar.Map).Get("seriesEvicted").String(); v != "2" {
+		t.Fatalf("unexpected seriesEvicted: %s", v)
+	}
+
+	// Querying the shard reloads the evicted series.
+	if counts, err := s.SeriesCardinality("db0", nil, nil); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(counts, map[string]int64{"cpu": 2}) {
+		t.Fatalf("unexpected series cardinality: %v", counts)
+	} else if ss := index.Series("cpu,host=serverA"); ss == nil || !ss.Assigned(0) {
+		t.Fatal("expected series to be reloaded")
+	}
+
+	// Writing an evicted series adds it back to the index.
+	s.EvictIdleSeries(time.Now().Add(time.Hour))
+	s.MustWriteToShardString(0, `cpu,host=serverA value=3 10`)
+	if n := index.SeriesN(); n != 1 {
+		t.Fatalf("unexpected series count after write: %d", n)
+	}
+}
+
+// Ensure the caches of the shards are written to TSM files when the store is
+// closed with CacheFlushOnShutdown set.
+func TestStore_Close_FlushCache(t *testing.T) {
+	s := NewStore()
+	s.EngineOptions.Config.CacheFlushOnShutdown = true
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(s.Path())
+
+	s.MustCreateShardWithData("db0", "rp0", 1, `cpu,host=serverA value=1 0`)
+	if err := s.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, err := filepath.Glob(filepath.Join(s.Path(), "db0", "rp0", "1", "*.tsm")); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 1 {
+		t.Fatalf("unexpected TSM files: %v", files)
+	}
+
+	// The WAL holds nothing left to replay.
+	segments, err := filepath.Glob(filepath.Join(s.EngineOptions.Config.WALDir, "db0", "rp0", "1", "*.wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range segments {
+		if fi, err := os.Stat(path); err != nil {
+			t.Fatal(err)
+		} else if fi.Size() != 0 {
+			t.Fatalf("unexpected WAL segment %s of %d bytes", path, fi.Size())
+		}
+	}
+
+	// The data is still readable after reopening.
+	if err := s.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Store.Close()
+	if n := s.DatabaseIndex("db0").SeriesN(); n != 1 {
+		t.Fatalf("unexpected series count: %d", n)
+	}
+}
+
+// Ensure evicted series are only reloaded by queries of their measurements.
+func TestStore_EvictIdleSeries_Measurements(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 0, `cpu,host=serverA value=1 0`)
+	index := s.DatabaseIndex("db0")
+	sh := s.Shard(0)
+
+	s.EvictIdleSeries(time.Now().Add(time.Hour))
+	path := filepath.Join(sh.Path(), "evicted.tsi")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected evicted series index: %s", err)
+	}
+
+	// Querying another measurement does not reload the evicted series.
+	s.MustWriteToShardString(0, `mem,host=serverA value=2 0`)
+	if _, _, err := sh.FieldDimensions(influxql.Sources{&influxql.Measurement{Name: "mem"}}); err != nil {
+		t.Fatal(err)
+	} else if ss := index.Series("cpu,host=serverA"); ss != nil {
+		t.Fatal("expected series to stay evicted")
+	}
+
+	// Querying the measurement of the evicted series reloads them.
+	if _, _, err := sh.FieldDimensions(influxql.Sources{&influxql.Measurement{Name: "cpu"}}); err != nil {
+		t.Fatal(err)
+	} else if ss := index.Series("cpu,host=serverA"); ss == nil || !ss.Assigned(0) {
+		t.Fatal("expected series to be reloaded")
+	} else if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected evicted series index to be removed: %v", err)
+	}
+}
+
+// Ensure queries only reload the evicted series they may read, without
+// touching them.
+func TestStore_EvictIdleSeries_Condition(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 0,
+		`cpu,host=serverA value=1 0`,
+		`cpu,host=serverB value=2 0`,
+		`cpu,host=serverC value=3 0`,
+		`mem,host=serverA value=4 0`,
+	)
+	index := s.DatabaseIndex("db0")
+	sh := s.Shard(0)
+	s.EvictIdleSeries(time.Now().Add(time.Hour))
+
+	// Only the series matching the tag conditions are reloaded.
+	opt := influxql.IteratorOptions{
+		Sources:   influxql.Sources{&influxql.Measurement{Name: "cpu"}},
+		Condition: influxql.MustParseExpr(`host =~ /server[AB]/ AND time >= 0`),
+	}
+	if _, err := sh.SeriesKeys(opt); err != nil {
+		t.Fatal(err)
+	}
+	for key, loaded := range map[string]bool{
+		"cpu,host=serverA": true,
+		"cpu,host=serverB": true,
+		"cpu,host=serverC": false,
+		"mem,host=serverA": false,
+	} {
+		if ss := index.Series(key); (ss != nil && ss.Assigned(0)) != loaded {
+			t.Fatalf("unexpected series %s loaded: %v", key, !loaded)
+		}
+	}
+
+	// Series reloaded but not read by a query are evicted again.
+	if _, _, err := sh.FieldDimensions(influxql.Sources{&influxql.Measurement{Name: "cpu"}}); err != nil {
+		t.Fatal(err)
+	} else if ss := index.Series("cpu,host=serverC"); ss == nil {
+		t.Fatal("expected series to be reloaded")
+	}
+	s.EvictIdleSeries(time.Now().Add(-time.Hour))
+	if n := index.SeriesN(); n != 2 {
+		t.Fatalf("unexpected series count: %d", n)
+	} else if ss := index.Series("cpu,host=serverC"); ss != nil {
+		t.Fatal("expected series to be evicted again")
+	}
+}
+
+// Ensure the store can count series, measurements and tag values.
+func TestStore_Cardinality(t *testing.T) {
+	s := MustOpenStore()
+	defer s.Close()
+
+	s.MustCreateShardWithData("db0", "rp0", 0,
+		`cpu,host=serverA,region=uswest value=1 0`,
+		`cpu,host=serverB,region=uswest value=2 0`,
+		`cpu,host=serverC,region=useast value=3 0`,
+		`mem,host=serverA value=4 0`,
+	)
+
+	if n, err := s.MeasurementCardinality("db0", nil, nil); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("unexpected measurement cardinality: %d", n)
+	}
+
+	cond := influxql.MustParseExpr(`region = 'uswest'`)
+	if counts, err := s.SeriesCardinality("db0", nil, nil); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(counts, map[string]int64{"cpu": 3, "mem": 1}) {
+		t.Fatalf("unexpected series cardinality: %v", counts)
+	} else if counts, err := s.SeriesCardinality("db0", influxql.Sources{&influxql.Measurement{Name: "cpu"}}, cond); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(counts, map[string]int64{"cpu": 2}) {
+		t.Fatalf("unexpected series cardinality: %v", counts)
+	}
+
+	if counts, err := s.TagValuesCardinality("db0", nil, []string{"host", "region"}, nil); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(counts, map[string]int64{"cpu": 5, "mem": 1}) {
+		t.Fatalf("unexpected tag values cardinality: %v", counts)
+	}
+
+	if _, err := s.SeriesCardinality("db1", nil, nil); err == nil || err.Error() != "database not found: db1" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 // Ensure shards can create iterators.
 func TestShards_CreateIterator(t *testing.T) {
 	s := MustOpenStore()